@@ -66,17 +66,29 @@ func main() {
 	sig := <-quit
 	config.Logger.Info("shutdown signal received", "signal", sig.String())
 
-	// Graceful shutdown: allow up to 10 seconds for in-flight requests to complete.
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	// Graceful shutdown: stop accepting connections at once, give in-flight
+	// requests the grace period, then cancel the rest so their streams end
+	// with a terminal error event instead of a dropped socket.
+	grace := time.Duration(app.Store.RuntimeShutdownGraceSeconds()) * time.Second
+	config.Logger.Info("draining in-flight requests", "inflight", app.Drainer.Inflight(), "grace", grace)
+	ctx, cancel := context.WithTimeout(context.Background(), grace+shutdownForceWait)
 	defer cancel()
+	shutdownErr := make(chan error, 1)
+	go func() { shutdownErr <- srv.Shutdown(ctx) }()
 
-	if err := srv.Shutdown(ctx); err != nil {
+	drained, forced := app.Drainer.Drain(grace, shutdownForceWait)
+	config.Logger.Info("in-flight requests finished", "drained", drained, "force_closed", forced)
+	if err := <-shutdownErr; err != nil {
 		config.Logger.Error("graceful shutdown failed, forcing exit", "error", err)
 		os.Exit(1)
 	}
 	config.Logger.Info("server gracefully stopped")
 }
 
+// shutdownForceWait is how long cancelled requests get to write their final
+// event once the grace period has run out.
+const shutdownForceWait = 5 * time.Second
+
 func detectLANIPv4() string {
 	ifaces, err := net.Interfaces()
 	if err != nil {
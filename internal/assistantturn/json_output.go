@@ -0,0 +1,390 @@
+package assistantturn
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+
+	"ds2api/internal/promptcompat"
+)
+
+const (
+	CodeInvalidJSONOutput  = "invalid_json_output"
+	CodeJSONSchemaMismatch = "json_schema_mismatch"
+)
+
+var jsonTrailingCommaPattern = regexp.MustCompile(`,(\s*[}\]])`)
+
+// IsResponseFormatError reports whether err came from JSON-mode output
+// validation, which callers may retry once with a stricter instruction.
+func IsResponseFormatError(err *OutputError) bool {
+	return err != nil && (err.Code == CodeInvalidJSONOutput || err.Code == CodeJSONSchemaMismatch)
+}
+
+// applyResponseFormat repairs JSON-mode text in place and reports an
+// OutputError when it still does not parse or match the requested schema.
+func applyResponseFormat(turn *Turn, format promptcompat.ResponseFormat) *OutputError {
+	if turn == nil || !format.IsJSON() || len(turn.ToolCalls) > 0 || strings.TrimSpace(turn.Text) == "" {
+		return nil
+	}
+	repaired, value, ok := RepairJSONOutput(turn.Text)
+	if !ok {
+		return &OutputError{Status: http.StatusBadRequest, Message: "Model output is not valid JSON for response_format.", Code: CodeInvalidJSONOutput}
+	}
+	turn.Text = repaired
+	return checkResponseFormatSchema(value, format)
+}
+
+// validateStreamedResponseFormat checks JSON-mode text that has already been
+// sent to the client. Nothing can be repaired at that point, so the text must
+// decode as it was streamed; fences or surrounding prose fail validation.
+func validateStreamedResponseFormat(text string, format promptcompat.ResponseFormat) *OutputError {
+	if !format.IsJSON() || strings.TrimSpace(text) == "" {
+		return nil
+	}
+	value, ok := decodeJSONValue(strings.TrimSpace(text))
+	if !ok {
+		return &OutputError{Status: http.StatusBadRequest, Message: "Streamed model output is not valid JSON for response_format.", Code: CodeInvalidJSONOutput}
+	}
+	return checkResponseFormatSchema(value, format)
+}
+
+func checkResponseFormatSchema(value any, format promptcompat.ResponseFormat) *OutputError {
+	if format.Type != promptcompat.ResponseFormatJSONSchema {
+		return nil
+	}
+	if err := validateJSONSchema(value, format.Schema, "$"); err != nil {
+		return &OutputError{Status: http.StatusBadRequest, Message: "Model output does not match response_format json_schema: " + err.Error(), Code: CodeJSONSchemaMismatch}
+	}
+	return nil
+}
+
+// RepairJSONOutput extracts a JSON value from model text by stripping
+// markdown fences and surrounding prose, then dropping trailing commas as a
+// last resort. It returns the JSON text, the decoded value and whether a valid
+// value was found.
+func RepairJSONOutput(text string) (string, any, bool) {
+	candidate := stripJSONCodeFence(strings.TrimSpace(text))
+	if value, ok := decodeJSONValue(candidate); ok {
+		return candidate, value, true
+	}
+	if extracted := extractBalancedJSON(candidate); extracted != "" {
+		if value, ok := decodeJSONValue(extracted); ok {
+			return extracted, value, true
+		}
+		candidate = extracted
+	}
+	fixed := jsonTrailingCommaPattern.ReplaceAllString(candidate, "$1")
+	if value, ok := decodeJSONValue(fixed); ok {
+		return fixed, value, true
+	}
+	return "", nil, false
+}
+
+func stripJSONCodeFence(text string) string {
+	start := strings.Index(text, "```")
+	if start < 0 {
+		return text
+	}
+	body := text[start+3:]
+	if nl := strings.IndexByte(body, '\n'); nl >= 0 {
+		body = body[nl+1:]
+	} else {
+		return text
+	}
+	if end := strings.Index(body, "```"); end >= 0 {
+		body = body[:end]
+	}
+	return strings.TrimSpace(body)
+}
+
+func decodeJSONValue(text string) (any, bool) {
+	if text == "" {
+		return nil, false
+	}
+	dec := json.NewDecoder(strings.NewReader(text))
+	dec.UseNumber()
+	var value any
+	if err := dec.Decode(&value); err != nil {
+		return nil, false
+	}
+	if dec.More() {
+		return nil, false
+	}
+	return value, true
+}
+
+// extractBalancedJSON returns the first top-level {...} or [...] span,
+// skipping brackets inside JSON strings.
+func extractBalancedJSON(text string) string {
+	start := strings.IndexAny(text, "{[")
+	if start < 0 {
+		return ""
+	}
+	depth := 0
+	inString := false
+	escaped := false
+	for i := start; i < len(text); i++ {
+		c := text[i]
+		if inString {
+			switch {
+			case escaped:
+				escaped = false
+			case c == '\\':
+				escaped = true
+			case c == '"':
+				inString = false
+			}
+			continue
+		}
+		switch c {
+		case '"':
+			inString = true
+		case '{', '[':
+			depth++
+		case '}', ']':
+			depth--
+			if depth == 0 {
+				return text[start : i+1]
+			}
+		}
+	}
+	return ""
+}
+
+// validateJSONSchema checks the subset of JSON Schema that structured output
+// clients rely on: type, enum, const, properties/required,
+// additionalProperties=false, items, array/string length bounds, numeric
+// bounds and anyOf/oneOf/allOf.
+func validateJSONSchema(value any, schema map[string]any, path string) error {
+	if len(schema) == 0 {
+		return nil
+	}
+	if err := validateSchemaType(value, schema["type"], path); err != nil {
+		return err
+	}
+	if enum, ok := schema["enum"].([]any); ok && !jsonValueIn(value, enum) {
+		return fmt.Errorf("%s is not one of the allowed enum values", path)
+	}
+	if c, ok := schema["const"]; ok && !jsonValuesEqual(value, c) {
+		return fmt.Errorf("%s does not equal the required const value", path)
+	}
+	for _, key := range []string{"allOf", "anyOf", "oneOf"} {
+		subs, ok := schema[key].([]any)
+		if !ok || len(subs) == 0 {
+			continue
+		}
+		matches := 0
+		var firstErr error
+		for _, sub := range subs {
+			subSchema, _ := sub.(map[string]any)
+			if err := validateJSONSchema(value, subSchema, path); err != nil {
+				if firstErr == nil {
+					firstErr = err
+				}
+				continue
+			}
+			matches++
+		}
+		switch {
+		case key == "allOf" && matches != len(subs):
+			return firstErr
+		case key == "anyOf" && matches == 0:
+			return fmt.Errorf("%s does not match any anyOf schema", path)
+		case key == "oneOf" && matches != 1:
+			return fmt.Errorf("%s must match exactly one oneOf schema", path)
+		}
+	}
+	switch v := value.(type) {
+	case map[string]any:
+		return validateSchemaObject(v, schema, path)
+	case []any:
+		return validateSchemaArray(v, schema, path)
+	case string:
+		if n, ok := schemaInt(schema["minLength"]); ok && len([]rune(v)) < n {
+			return fmt.Errorf("%s is shorter than minLength %d", path, n)
+		}
+		if n, ok := schemaInt(schema["maxLength"]); ok && len([]rune(v)) > n {
+			return fmt.Errorf("%s is longer than maxLength %d", path, n)
+		}
+	case json.Number:
+		f, err := v.Float64()
+		if err != nil {
+			return fmt.Errorf("%s is not a valid number", path)
+		}
+		if min, ok := schemaFloat(schema["minimum"]); ok && f < min {
+			return fmt.Errorf("%s is less than minimum %v", path, min)
+		}
+		if max, ok := schemaFloat(schema["maximum"]); ok && f > max {
+			return fmt.Errorf("%s is greater than maximum %v", path, max)
+		}
+	}
+	return nil
+}
+
+func validateSchemaObject(obj map[string]any, schema map[string]any, path string) error {
+	props, _ := schema["properties"].(map[string]any)
+	if required, ok := schema["required"].([]any); ok {
+		for _, item := range required {
+			name, _ := item.(string)
+			if _, present := obj[name]; name != "" && !present {
+				return fmt.Errorf("%s is missing required property %q", path, name)
+			}
+		}
+	}
+	keys := make([]string, 0, len(obj))
+	for k := range obj {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		propSchema, known := props[k].(map[string]any)
+		if !known {
+			if allowed, ok := schema["additionalProperties"].(bool); ok && !allowed {
+				return fmt.Errorf("%s has unexpected property %q", path, k)
+			}
+			if extra, ok := schema["additionalProperties"].(map[string]any); ok {
+				propSchema = extra
+			}
+		}
+		if err := validateJSONSchema(obj[k], propSchema, path+"."+k); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func validateSchemaArray(items []any, schema map[string]any, path string) error {
+	if n, ok := schemaInt(schema["minItems"]); ok && len(items) < n {
+		return fmt.Errorf("%s has fewer than minItems %d", path, n)
+	}
+	if n, ok := schemaInt(schema["maxItems"]); ok && len(items) > n {
+		return fmt.Errorf("%s has more than maxItems %d", path, n)
+	}
+	itemSchema, _ := schema["items"].(map[string]any)
+	for i, item := range items {
+		if err := validateJSONSchema(item, itemSchema, fmt.Sprintf("%s[%d]", path, i)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func validateSchemaType(value any, raw any, path string) error {
+	var types []string
+	switch t := raw.(type) {
+	case string:
+		types = []string{t}
+	case []any:
+		for _, item := range t {
+			if s, ok := item.(string); ok {
+				types = append(types, s)
+			}
+		}
+	}
+	if len(types) == 0 {
+		return nil
+	}
+	for _, t := range types {
+		if jsonValueHasType(value, t) {
+			return nil
+		}
+	}
+	return fmt.Errorf("%s must be of type %s", path, strings.Join(types, " or "))
+}
+
+func jsonValueHasType(value any, typ string) bool {
+	switch typ {
+	case "object":
+		_, ok := value.(map[string]any)
+		return ok
+	case "array":
+		_, ok := value.([]any)
+		return ok
+	case "string":
+		_, ok := value.(string)
+		return ok
+	case "boolean":
+		_, ok := value.(bool)
+		return ok
+	case "null":
+		return value == nil
+	case "number":
+		_, ok := value.(json.Number)
+		return ok
+	case "integer":
+		n, ok := value.(json.Number)
+		if !ok {
+			return false
+		}
+		f, err := n.Float64()
+		return err == nil && f == math.Trunc(f)
+	default:
+		return true
+	}
+}
+
+func jsonValueIn(value any, candidates []any) bool {
+	for _, c := range candidates {
+		if jsonValuesEqual(value, c) {
+			return true
+		}
+	}
+	return false
+}
+
+func jsonValuesEqual(a, b any) bool {
+	left, errA := json.Marshal(normalizeJSONNumbers(a))
+	right, errB := json.Marshal(normalizeJSONNumbers(b))
+	return errA == nil && errB == nil && string(left) == string(right)
+}
+
+func normalizeJSONNumbers(v any) any {
+	switch x := v.(type) {
+	case json.Number:
+		if f, err := x.Float64(); err == nil {
+			return f
+		}
+		return x.String()
+	case map[string]any:
+		out := make(map[string]any, len(x))
+		for k, item := range x {
+			out[k] = normalizeJSONNumbers(item)
+		}
+		return out
+	case []any:
+		out := make([]any, len(x))
+		for i, item := range x {
+			out[i] = normalizeJSONNumbers(item)
+		}
+		return out
+	default:
+		return v
+	}
+}
+
+func schemaInt(v any) (int, bool) {
+	f, ok := schemaFloat(v)
+	if !ok {
+		return 0, false
+	}
+	return int(f), true
+}
+
+func schemaFloat(v any) (float64, bool) {
+	switch x := v.(type) {
+	case float64:
+		return x, true
+	case int:
+		return float64(x), true
+	case json.Number:
+		f, err := x.Float64()
+		return f, err == nil
+	default:
+		return 0, false
+	}
+}
@@ -0,0 +1,59 @@
+package assistantturn
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+
+	"ds2api/internal/config"
+	"ds2api/internal/toolcall"
+)
+
+// toolArgumentsError checks a parsed call's arguments against the parameter
+// schema its tool declared (required fields, enums, nested object and array
+// shapes) and returns the first mismatch, or nil when the call conforms or the
+// tool declared no schema.
+func toolArgumentsError(call toolcall.ParsedToolCall, toolsRaw any) error {
+	schema := declaredToolSchema(call.Name, toolsRaw)
+	if len(schema) == 0 {
+		return nil
+	}
+	b, err := json.Marshal(call.Input)
+	if err != nil {
+		return err
+	}
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+	var input any
+	if err := dec.Decode(&input); err != nil {
+		return err
+	}
+	return validateJSONSchema(input, schema, "$")
+}
+
+// logToolArgumentMismatches reports calls whose arguments do not match the
+// declared schema. The calls are still returned: the client's tool runner
+// owns rejecting bad input, and the log shows where the prompt falls short.
+func logToolArgumentMismatches(calls []toolcall.ParsedToolCall, toolsRaw any) {
+	for _, call := range calls {
+		if err := toolArgumentsError(call, toolsRaw); err != nil {
+			config.Logger.Warn("[tool_call] arguments do not match the declared parameter schema", "tool", call.Name, "error", err)
+		}
+	}
+}
+
+func declaredToolSchema(name string, toolsRaw any) map[string]any {
+	tools, _ := toolsRaw.([]any)
+	for _, item := range tools {
+		tool, ok := item.(map[string]any)
+		if !ok {
+			continue
+		}
+		toolName, _, schema := toolcall.ExtractToolMeta(tool)
+		if strings.EqualFold(strings.TrimSpace(toolName), strings.TrimSpace(name)) {
+			out, _ := schema.(map[string]any)
+			return out
+		}
+	}
+	return nil
+}
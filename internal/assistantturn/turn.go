@@ -17,7 +17,9 @@ const (
 	StopReasonStop          StopReason = "stop"
 	StopReasonToolCalls     StopReason = "tool_calls"
 	StopReasonContentFilter StopReason = "content_filter"
-	StopReasonError         StopReason = "error"
+	// StopReasonLength marks text cut at the request's max_tokens budget.
+	StopReasonLength StopReason = "length"
+	StopReasonError  StopReason = "error"
 )
 
 type Usage struct {
@@ -49,6 +51,9 @@ type Turn struct {
 	StopReason        StopReason
 	Usage             Usage
 	Error             *OutputError
+	// BannedWordsFiltered reports that Text had `logit_bias` banned words
+	// removed; the non-stream runtime regenerates once when it is set.
+	BannedWordsFiltered bool
 }
 
 type FinalizeOptions struct {
@@ -74,6 +79,14 @@ type BuildOptions struct {
 	ToolNames             []string
 	ToolsRaw              any
 	ToolChoice            promptcompat.ToolChoicePolicy
+	// ToolCallFormat is the tool call format the request captured; empty
+	// means the active one.
+	ToolCallFormat string
+	// ResponseFormat enables JSON-mode repair and validation of collected
+	// (non-stream) text. Streamed text is validated as sent, without repair.
+	ResponseFormat promptcompat.ResponseFormat
+	// BannedWords are removed from collected (non-stream) text.
+	BannedWords []string
 }
 
 type StreamSnapshot struct {
@@ -88,6 +101,7 @@ type StreamSnapshot struct {
 	AlreadyEmittedCalls   bool
 	AdditionalToolCalls   []toolcall.ParsedToolCall
 	AlreadyEmittedToolRaw bool
+	OutputLimitReached    bool
 }
 
 func BuildTurnFromCollected(result sse.CollectResult, opts BuildOptions) Turn {
@@ -96,12 +110,18 @@ func BuildTurnFromCollected(result sse.CollectResult, opts BuildOptions) Turn {
 	if opts.SearchEnabled {
 		text = shared.ReplaceCitationMarkersWithLinks(text, result.CitationLinks)
 	}
+	text, bannedFiltered := sse.FilterBannedWords(text, opts.BannedWords)
 
-	parsed := shared.DetectAssistantToolCalls(result.Text, text, result.Thinking, result.ToolDetectionThinking, opts.ToolNames)
+	parsed := detectToolCalls(result.Text, text, result.Thinking, result.ToolDetectionThinking, opts)
+	text = stripResidualToolMarkup(text, opts)
 	calls := toolcall.NormalizeParsedToolCallsForSchemas(parsed.Calls, opts.ToolsRaw)
 	parsed.Calls = calls
+	logToolArgumentMismatches(calls, opts.ToolsRaw)
 
 	stopReason := StopReasonStop
+	if result.OutputLimitReached {
+		stopReason = StopReasonLength
+	}
 	if result.ContentFilter {
 		stopReason = StopReasonContentFilter
 	}
@@ -124,8 +144,14 @@ func BuildTurnFromCollected(result sse.CollectResult, opts BuildOptions) Turn {
 		ResponseMessageID: result.ResponseMessageID,
 		StopReason:        stopReason,
 	}
+	turn.BannedWordsFiltered = bannedFiltered
 	turn.Usage = BuildUsage(opts.Model, opts.Prompt, thinking, text, opts.RefFileTokens)
 	turn.Error = ValidateTurn(turn, opts.ToolChoice)
+	// Text cut at max_tokens is returned as-is with finish_reason "length",
+	// so JSON mode does not reject or retry the truncated answer.
+	if turn.Error == nil && stopReason != StopReasonLength {
+		turn.Error = applyResponseFormat(&turn, opts.ResponseFormat)
+	}
 	if turn.Error != nil {
 		turn.StopReason = StopReasonError
 	}
@@ -139,15 +165,20 @@ func BuildTurnFromStreamSnapshot(snapshot StreamSnapshot, opts BuildOptions) Tur
 		text = shared.ReplaceCitationMarkersWithLinks(text, snapshot.CitationLinks)
 	}
 
-	parsed := shared.DetectAssistantToolCalls(snapshot.RawText, text, snapshot.RawThinking, snapshot.DetectionThinking, opts.ToolNames)
+	parsed := detectToolCalls(snapshot.RawText, text, snapshot.RawThinking, snapshot.DetectionThinking, opts)
+	text = stripResidualToolMarkup(text, opts)
 	calls := parsed.Calls
-	if len(calls) == 0 && len(snapshot.AdditionalToolCalls) > 0 {
+	if len(calls) == 0 && len(snapshot.AdditionalToolCalls) > 0 && !opts.ToolChoice.IsNone() {
 		calls = snapshot.AdditionalToolCalls
 	}
 	calls = toolcall.NormalizeParsedToolCallsForSchemas(calls, opts.ToolsRaw)
 	parsed.Calls = calls
+	logToolArgumentMismatches(calls, opts.ToolsRaw)
 
 	stopReason := StopReasonStop
+	if snapshot.OutputLimitReached {
+		stopReason = StopReasonLength
+	}
 	if snapshot.ContentFilter {
 		stopReason = StopReasonContentFilter
 	}
@@ -174,12 +205,38 @@ func BuildTurnFromStreamSnapshot(snapshot StreamSnapshot, opts BuildOptions) Tur
 	if !snapshot.AlreadyEmittedCalls && !snapshot.AlreadyEmittedToolRaw {
 		turn.Error = ValidateTurn(turn, opts.ToolChoice)
 	}
+	if turn.Error == nil && stopReason == StopReasonStop {
+		turn.Error = validateStreamedResponseFormat(text, opts.ResponseFormat)
+	}
 	if turn.Error != nil && len(calls) == 0 {
 		turn.StopReason = StopReasonError
 	}
 	return turn
 }
 
+// detectToolCalls honors tool_choice=none by never promoting tool-shaped
+// output into calls; stray markup is still stripped from visible text.
+func detectToolCalls(rawText, visibleText, rawThinking, detectionThinking string, opts BuildOptions) toolcall.ToolCallParseResult {
+	if opts.ToolChoice.IsNone() {
+		return toolcall.ToolCallParseResult{}
+	}
+	format := opts.ToolCallFormat
+	if format == "" {
+		format = toolcall.ActiveCallFormat()
+	}
+	return shared.DetectAssistantToolCalls(rawText, visibleText, rawThinking, detectionThinking, opts.ToolNames, format)
+}
+
+// stripResidualToolMarkup removes tool markup left in the visible text of a
+// request that declared tools: the markup of a call that was parsed must not
+// also show up as content, and markup that failed to parse is noise.
+func stripResidualToolMarkup(text string, opts BuildOptions) string {
+	if len(opts.ToolNames) == 0 {
+		return text
+	}
+	return toolcall.StripToolMarkup(text)
+}
+
 func BuildUsage(model, prompt, thinking, text string, refFileTokens int) Usage {
 	inputTokens := util.CountPromptTokens(prompt, model) + refFileTokens
 	reasoningTokens := util.CountOutputTokens(thinking, model)
@@ -254,6 +311,21 @@ func FinalizeTurn(turn Turn, opts FinalizeOptions) FinalOutcome {
 	}
 }
 
+// CombineChoiceUsage totals usage across `n` choices generated from the same
+// prompt: prompt tokens are counted once and completion tokens are summed.
+func CombineChoiceUsage(usages []Usage) Usage {
+	if len(usages) == 0 {
+		return Usage{}
+	}
+	out := Usage{InputTokens: usages[0].InputTokens}
+	for _, u := range usages {
+		out.OutputTokens += u.OutputTokens
+		out.ReasoningTokens += u.ReasoningTokens
+	}
+	out.TotalTokens = out.InputTokens + out.OutputTokens
+	return out
+}
+
 func OpenAIChatUsage(turn Turn) map[string]any {
 	return map[string]any{
 		"prompt_tokens":     turn.Usage.InputTokens,
@@ -273,12 +345,21 @@ func OpenAIResponsesUsage(turn Turn) map[string]any {
 	}
 }
 
+// FinishReason is the single place a turn's OpenAI finish_reason is decided.
+// Tool calls win over everything, so a turn that called tools never reports
+// "stop" or "length"; a filtered turn reports "content_filter" even when it
+// kept some visible text, then a max_tokens cutoff reports "length".
 func FinishReason(turn Turn) string {
+	if len(turn.ToolCalls) > 0 {
+		return "tool_calls"
+	}
 	switch turn.StopReason {
 	case StopReasonToolCalls:
 		return "tool_calls"
 	case StopReasonContentFilter:
 		return "content_filter"
+	case StopReasonLength:
+		return "length"
 	default:
 		return "stop"
 	}
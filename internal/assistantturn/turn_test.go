@@ -2,10 +2,12 @@ package assistantturn
 
 import (
 	"net/http"
+	"strings"
 	"testing"
 
 	"ds2api/internal/promptcompat"
 	"ds2api/internal/sse"
+	"ds2api/internal/toolcall"
 )
 
 func TestBuildTurnFromCollectedTextCitation(t *testing.T) {
@@ -64,6 +66,18 @@ func TestBuildTurnFromCollectedToolCall(t *testing.T) {
 	}
 }
 
+func TestBuildTurnFromCollectedRepairedCallIsNotDuplicatedIntoText(t *testing.T) {
+	turn := BuildTurnFromCollected(sse.CollectResult{
+		Text: "Listing files.\n<|DSML|invoke name=\"Bash\"><|DSML|parameter name=\"command\"><![CDATA[ls]]></|DSML|parameter></|DSML|invoke>\n</|DSML|tool_calls>",
+	}, BuildOptions{ToolNames: []string{"Bash"}})
+	if len(turn.ToolCalls) != 1 {
+		t.Fatalf("expected the missing-wrapper call to be repaired, got %d", len(turn.ToolCalls))
+	}
+	if strings.TrimSpace(turn.Text) != "Listing files." {
+		t.Fatalf("expected the call markup to be stripped from text, got %q", turn.Text)
+	}
+}
+
 func TestBuildTurnFromCollectedThinkingOnlyIsEmptyOutput(t *testing.T) {
 	turn := BuildTurnFromCollected(sse.CollectResult{Thinking: "hidden"}, BuildOptions{})
 	if turn.Error == nil || turn.Error.Code != "upstream_empty_output" {
@@ -147,3 +161,153 @@ func TestFinalizeTurnContentFilterOutcome(t *testing.T) {
 		t.Fatalf("expected content filter failure, got %#v", outcome)
 	}
 }
+
+func TestFinishReasonCoversEveryTermination(t *testing.T) {
+	cases := []struct {
+		name   string
+		result sse.CollectResult
+		want   string
+	}{
+		{"natural", sse.CollectResult{Text: "done"}, "stop"},
+		{"max_tokens", sse.CollectResult{Text: "cut", OutputLimitReached: true}, "length"},
+		{"filtered partial text", sse.CollectResult{Text: "partial", ContentFilter: true}, "content_filter"},
+		{"tool call at max_tokens", sse.CollectResult{Text: `<tool_calls><invoke name="Search"><parameter name="q">x</parameter></invoke></tool_calls>`, OutputLimitReached: true}, "tool_calls"},
+	}
+	for _, tc := range cases {
+		turn := BuildTurnFromCollected(tc.result, BuildOptions{ToolNames: []string{"Search"}})
+		if got := FinishReason(turn); got != tc.want {
+			t.Fatalf("%s: finish reason=%q want %q", tc.name, got, tc.want)
+		}
+		if got := FinalizeTurn(turn, FinalizeOptions{}).FinishReason; got != tc.want {
+			t.Fatalf("%s: finalized finish reason=%q want %q", tc.name, got, tc.want)
+		}
+	}
+	// Tool calls win even when the stop reason was recorded before they were.
+	if got := FinishReason(Turn{StopReason: StopReasonLength, ToolCalls: []toolcall.ParsedToolCall{{Name: "Search"}}}); got != "tool_calls" {
+		t.Fatalf("expected tool_calls to win over length, got %q", got)
+	}
+}
+
+func TestBuildTurnFromCollectedToolChoiceNoneRefusesStrayToolMarkup(t *testing.T) {
+	raw := `Sure.<tool_calls><invoke name="Write"><parameter name="content">x</parameter></invoke></tool_calls>`
+	turn := BuildTurnFromCollected(sse.CollectResult{Text: raw}, BuildOptions{
+		ToolNames:  []string{"Write"},
+		ToolChoice: promptcompat.ToolChoicePolicy{Mode: promptcompat.ToolChoiceNone},
+	})
+	if len(turn.ToolCalls) != 0 {
+		t.Fatalf("expected tool_choice=none to refuse tool calls, got %#v", turn.ToolCalls)
+	}
+	if turn.StopReason != StopReasonStop || turn.Error != nil {
+		t.Fatalf("expected plain stop turn, got stop=%q err=%#v", turn.StopReason, turn.Error)
+	}
+	if strings.TrimSpace(turn.Text) != "Sure." {
+		t.Fatalf("expected surrounding text to stay visible with markup stripped, got %q", turn.Text)
+	}
+}
+
+func TestBuildTurnFromCollectedRepairsJSONModeOutput(t *testing.T) {
+	turn := BuildTurnFromCollected(sse.CollectResult{
+		Text: "Here you go:\n```json\n{\"city\": \"Paris\", \"tags\": [\"a\",],}\n```\nHope that helps!",
+	}, BuildOptions{Model: "deepseek-v4-flash", ResponseFormat: promptcompat.ResponseFormat{Type: promptcompat.ResponseFormatJSONObject}})
+	if turn.Error != nil {
+		t.Fatalf("unexpected error: %#v", turn.Error)
+	}
+	if turn.Text != `{"city": "Paris", "tags": ["a"]}` {
+		t.Fatalf("unexpected repaired text: %q", turn.Text)
+	}
+}
+
+func TestBuildTurnFromCollectedRejectsUnparseableJSONModeOutput(t *testing.T) {
+	turn := BuildTurnFromCollected(sse.CollectResult{Text: "I cannot answer that."}, BuildOptions{
+		Model:          "deepseek-v4-flash",
+		ResponseFormat: promptcompat.ResponseFormat{Type: promptcompat.ResponseFormatJSONObject},
+	})
+	if turn.Error == nil || turn.Error.Code != CodeInvalidJSONOutput || turn.Error.Status != http.StatusBadRequest {
+		t.Fatalf("expected invalid_json_output error, got %#v", turn.Error)
+	}
+}
+
+func TestBuildTurnFromCollectedValidatesJSONSchema(t *testing.T) {
+	format := promptcompat.ResponseFormat{Type: promptcompat.ResponseFormatJSONSchema, Schema: map[string]any{
+		"type":                 "object",
+		"required":             []any{"city", "population"},
+		"additionalProperties": false,
+		"properties": map[string]any{
+			"city":       map[string]any{"type": "string"},
+			"population": map[string]any{"type": "integer", "minimum": float64(0)},
+		},
+	}}
+	ok := BuildTurnFromCollected(sse.CollectResult{Text: `{"city":"Paris","population":2100000}`}, BuildOptions{ResponseFormat: format})
+	if ok.Error != nil {
+		t.Fatalf("expected schema match, got %#v", ok.Error)
+	}
+	bad := BuildTurnFromCollected(sse.CollectResult{Text: `{"city":"Paris","population":"many"}`}, BuildOptions{ResponseFormat: format})
+	if bad.Error == nil || bad.Error.Code != CodeJSONSchemaMismatch || !strings.Contains(bad.Error.Message, "$.population") {
+		t.Fatalf("expected schema mismatch on population, got %#v", bad.Error)
+	}
+	extra := BuildTurnFromCollected(sse.CollectResult{Text: `{"city":"Paris","population":1,"mayor":"x"}`}, BuildOptions{ResponseFormat: format})
+	if extra.Error == nil || extra.Error.Code != CodeJSONSchemaMismatch {
+		t.Fatalf("expected additionalProperties violation, got %#v", extra.Error)
+	}
+}
+
+func TestToolArgumentsErrorChecksDeclaredSchema(t *testing.T) {
+	tools := []any{map[string]any{
+		"type": "function",
+		"function": map[string]any{
+			"name": "write_file",
+			"parameters": map[string]any{
+				"type":     "object",
+				"required": []any{"path"},
+				"properties": map[string]any{
+					"path": map[string]any{"type": "string"},
+					"mode": map[string]any{"type": "string", "enum": []any{"overwrite", "append"}},
+				},
+			},
+		},
+	}}
+	ok := toolcall.ParsedToolCall{Name: "Write_File", Input: map[string]any{"path": "/tmp/a", "mode": "append"}}
+	if err := toolArgumentsError(ok, tools); err != nil {
+		t.Fatalf("unexpected mismatch: %v", err)
+	}
+	for _, input := range []map[string]any{{"mode": "append"}, {"path": "/tmp/a", "mode": "truncate"}} {
+		if err := toolArgumentsError(toolcall.ParsedToolCall{Name: "write_file", Input: input}, tools); err == nil {
+			t.Fatalf("%v: expected a schema mismatch", input)
+		}
+	}
+	if err := toolArgumentsError(toolcall.ParsedToolCall{Name: "unknown", Input: map[string]any{}}, tools); err != nil {
+		t.Fatalf("undeclared tools should not be checked, got %v", err)
+	}
+}
+
+func TestBuildTurnFromStreamSnapshotValidatesJSONModeWithoutRepair(t *testing.T) {
+	format := promptcompat.ResponseFormat{Type: promptcompat.ResponseFormatJSONObject}
+	ok := BuildTurnFromStreamSnapshot(StreamSnapshot{VisibleText: `{"city":"Paris"}`}, BuildOptions{ResponseFormat: format})
+	if ok.Error != nil || ok.StopReason != StopReasonStop {
+		t.Fatalf("expected valid streamed JSON to pass, got stop=%q err=%#v", ok.StopReason, ok.Error)
+	}
+	fenced := BuildTurnFromStreamSnapshot(StreamSnapshot{VisibleText: "```json\n{\"city\":\"Paris\"}\n```"}, BuildOptions{ResponseFormat: format})
+	if fenced.Error == nil || fenced.Error.Code != CodeInvalidJSONOutput || fenced.StopReason != StopReasonError {
+		t.Fatalf("expected fenced streamed text to fail validation, got stop=%q err=%#v", fenced.StopReason, fenced.Error)
+	}
+	truncated := BuildTurnFromStreamSnapshot(StreamSnapshot{VisibleText: `{"city":`, OutputLimitReached: true}, BuildOptions{ResponseFormat: format})
+	if truncated.Error != nil || truncated.StopReason != StopReasonLength {
+		t.Fatalf("expected truncated stream to end with length, got stop=%q err=%#v", truncated.StopReason, truncated.Error)
+	}
+}
+
+func TestBuildTurnFromStreamSnapshotParsesWithCapturedCallFormat(t *testing.T) {
+	toolcall.ConfigurePrompt(toolcall.CallFormatDSML, nil)
+	t.Cleanup(func() { toolcall.ConfigurePrompt("", nil) })
+
+	raw := `{"tool_calls": [{"name": "read_file", "arguments": {"path": "README.md"}}]}`
+	opts := BuildOptions{ToolNames: []string{"read_file"}}
+	if turn := BuildTurnFromStreamSnapshot(StreamSnapshot{RawText: raw}, opts); len(turn.ToolCalls) != 0 {
+		t.Fatalf("expected the active dsml format to ignore JSON calls, got %#v", turn.ToolCalls)
+	}
+	opts.ToolCallFormat = toolcall.CallFormatJSON
+	turn := BuildTurnFromStreamSnapshot(StreamSnapshot{RawText: raw}, opts)
+	if len(turn.ToolCalls) != 1 || turn.ToolCalls[0].Input["path"] != "README.md" {
+		t.Fatalf("expected the captured json format to parse the call, got %#v", turn.ToolCalls)
+	}
+}
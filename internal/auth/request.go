@@ -20,7 +20,10 @@ const authCtxKey ctxKey = "auth_context"
 
 var (
 	ErrUnauthorized = errors.New("unauthorized: missing auth token")
-	ErrNoAccount    = errors.New("no accounts configured or all accounts are busy")
+	// ErrInvalidAPIKey is returned for tokens that are not configured keys
+	// while runtime.require_api_key is on.
+	ErrInvalidAPIKey = errors.New("invalid api key: the token is not a configured DS2API key")
+	ErrNoAccount     = errors.New("no accounts configured or all accounts are busy")
 )
 
 type RequestAuth struct {
@@ -31,6 +34,7 @@ type RequestAuth struct {
 	TargetAccount  string
 	Account        config.Account
 	TriedAccounts  map[string]bool
+	AllowedModels  []string // caller key's model allowlist; empty allows all
 	resolver       *Resolver
 }
 
@@ -62,6 +66,9 @@ func (r *Resolver) Determine(req *http.Request) (*RequestAuth, error) {
 	callerID := callerTokenID(callerKey)
 	ctx := req.Context()
 	if !r.Store.HasAPIKey(callerKey) {
+		if r.Store.RuntimeRequireAPIKey() {
+			return nil, ErrInvalidAPIKey
+		}
 		return &RequestAuth{
 			UseConfigToken: false,
 			DeepSeekToken:  callerKey,
@@ -75,6 +82,7 @@ func (r *Resolver) Determine(req *http.Request) (*RequestAuth, error) {
 	if err != nil {
 		return nil, err
 	}
+	a.AllowedModels = r.Store.APIKeyModels(callerKey)
 	return a, nil
 }
 
@@ -133,12 +141,36 @@ func (r *Resolver) DetermineCaller(req *http.Request) (*RequestAuth, error) {
 		resolver:       r,
 		TriedAccounts:  map[string]bool{},
 	}
-	if r == nil || r.Store == nil || !r.Store.HasAPIKey(callerKey) {
+	switch {
+	case r == nil || r.Store == nil:
+		a.DeepSeekToken = callerKey
+	case r.Store.HasAPIKey(callerKey):
+		a.AllowedModels = r.Store.APIKeyModels(callerKey)
+	case r.Store.RuntimeRequireAPIKey():
+		return nil, ErrInvalidAPIKey
+	default:
 		a.DeepSeekToken = callerKey
 	}
 	return a, nil
 }
 
+// AllowsModel reports whether the caller key may use a model. An allowlist
+// entry matches the requested name (so aliases can be granted) or the
+// resolved DeepSeek model.
+func (a *RequestAuth) AllowsModel(requested, resolved string) bool {
+	if a == nil || len(a.AllowedModels) == 0 {
+		return true
+	}
+	requested = strings.ToLower(strings.TrimSpace(requested))
+	resolved = strings.ToLower(strings.TrimSpace(resolved))
+	for _, model := range a.AllowedModels {
+		if model == requested || model == resolved {
+			return true
+		}
+	}
+	return false
+}
+
 func WithAuth(ctx context.Context, a *RequestAuth) context.Context {
 	return context.WithValue(ctx, authCtxKey, a)
 }
@@ -167,7 +199,7 @@ func (r *Resolver) RefreshToken(ctx context.Context, a *RequestAuth) bool {
 	_ = r.Store.UpdateAccountToken(a.AccountID, "")
 	a.Account.Token = ""
 	if err := r.loginAndPersist(ctx, a); err != nil {
-		config.Logger.Error("[refresh_token] failed", "account", a.AccountID, "error", err)
+		config.Logger.ErrorContext(ctx, "[refresh_token] failed", "account", a.AccountID, "error", err)
 		return false
 	}
 	return true
@@ -250,6 +282,12 @@ func extractCallerToken(req *http.Request) string {
 	return strings.TrimSpace(req.URL.Query().Get("api_key"))
 }
 
+// CallerID returns a stable, non-reversible identifier for the credential on
+// req, or "" when it carries none.
+func CallerID(req *http.Request) string {
+	return callerTokenID(extractCallerToken(req))
+}
+
 func callerTokenID(token string) string {
 	token = strings.TrimSpace(token)
 	if token == "" {
@@ -395,3 +395,67 @@ func TestDetermineManagedAccountReturnsLastEnsureErrorWhenAllFail(t *testing.T)
 		t.Fatalf("expected auth-style ensure error, got ErrNoAccount")
 	}
 }
+
+func TestDetermineRejectsUnknownTokenWhenAPIKeyRequired(t *testing.T) {
+	t.Setenv("DS2API_CONFIG_JSON", `{
+		"keys":["managed-key"],
+		"accounts":[{"email":"acc@example.com","password":"pwd","token":"account-token"}],
+		"runtime":{"require_api_key":true}
+	}`)
+	store := config.LoadStore()
+	r := NewResolver(store, account.NewPool(store), func(_ context.Context, _ config.Account) (string, error) {
+		return "fresh-token", nil
+	})
+	req, _ := http.NewRequest(http.MethodPost, "/v1/chat/completions", nil)
+	req.Header.Set("Authorization", "Bearer sk-unknown")
+
+	if _, err := r.Determine(req); !errors.Is(err, ErrInvalidAPIKey) {
+		t.Fatalf("expected ErrInvalidAPIKey, got %v", err)
+	}
+	if _, err := r.DetermineCaller(req); !errors.Is(err, ErrInvalidAPIKey) {
+		t.Fatalf("expected ErrInvalidAPIKey from DetermineCaller, got %v", err)
+	}
+
+	req.Header.Set("Authorization", "Bearer managed-key")
+	a, err := r.Determine(req)
+	if err != nil {
+		t.Fatalf("managed key should still be accepted: %v", err)
+	}
+	r.Release(a)
+}
+
+func TestDetermineCarriesKeyModelAllowlist(t *testing.T) {
+	t.Setenv("DS2API_CONFIG_JSON", `{
+		"api_keys":[{"key":"restricted-key","models":[" GPT-4o ","deepseek-v4-flash"]},{"key":"open-key"}],
+		"accounts":[{"email":"acc@example.com","password":"pwd","token":"account-token"}]
+	}`)
+	store := config.LoadStore()
+	r := NewResolver(store, account.NewPool(store), func(_ context.Context, _ config.Account) (string, error) {
+		return "fresh-token", nil
+	})
+	req, _ := http.NewRequest(http.MethodPost, "/v1/chat/completions", nil)
+	req.Header.Set("Authorization", "Bearer restricted-key")
+	a, err := r.Determine(req)
+	if err != nil {
+		t.Fatalf("determine failed: %v", err)
+	}
+	defer r.Release(a)
+	if !a.AllowsModel("gpt-4o", "deepseek-v4-flash") {
+		t.Fatalf("expected alias entry to allow gpt-4o")
+	}
+	if !a.AllowsModel("my-flash", "deepseek-v4-flash") {
+		t.Fatalf("expected resolved model entry to allow any alias of deepseek-v4-flash")
+	}
+	if a.AllowsModel("deepseek-v4-pro", "deepseek-v4-pro") {
+		t.Fatalf("expected deepseek-v4-pro to be rejected, allowlist=%v", a.AllowedModels)
+	}
+
+	req.Header.Set("Authorization", "Bearer open-key")
+	open, err := r.DetermineCaller(req)
+	if err != nil {
+		t.Fatalf("determine caller failed: %v", err)
+	}
+	if !open.AllowsModel("deepseek-v4-pro", "deepseek-v4-pro") {
+		t.Fatalf("expected key without allowlist to allow every model")
+	}
+}
@@ -0,0 +1,163 @@
+package completionruntime
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"ds2api/internal/auth"
+	"ds2api/internal/config"
+	"ds2api/internal/metrics"
+)
+
+// apiBackendHTTPClient has no client timeout: completions stream for as long
+// as the request deadline on the context allows.
+var apiBackendHTTPClient = &http.Client{}
+
+// apiBackendPassThroughKeys are the sampling parameters forwarded from the
+// completion payload to an API backend.
+var apiBackendPassThroughKeys = []string{
+	"temperature",
+	"top_p",
+	"max_tokens",
+	"max_completion_tokens",
+	"presence_penalty",
+	"frequency_penalty",
+	"stop",
+	"seed",
+}
+
+// apiBackendCaller serves CallCompletion from an OpenAI-compatible chat
+// completions API instead of the DeepSeek web client. The built prompt is
+// sent as a single user message and the streamed answer is rewritten into
+// the DeepSeek web SSE format, so the rest of the runtime parses it like
+// any other upstream response. The other DeepSeekCaller methods still go to
+// the web client.
+type apiBackendCaller struct {
+	DeepSeekCaller
+	name    string
+	backend config.APIBackendConfig
+}
+
+func (c apiBackendCaller) CallCompletion(ctx context.Context, _ *auth.RequestAuth, payload map[string]any, _ string, _ int) (*http.Response, error) {
+	prompt, _ := payload["prompt"].(string)
+	reqBody := map[string]any{
+		"model":    c.backend.ModelFor(thinkingEnabled(payload)),
+		"messages": []any{map[string]any{"role": "user", "content": prompt}},
+		"stream":   true,
+	}
+	for _, k := range apiBackendPassThroughKeys {
+		if v, ok := payload[k]; ok {
+			reqBody[k] = v
+		}
+	}
+	body, err := json.Marshal(reqBody)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, strings.TrimRight(c.backend.BaseURL, "/")+"/chat/completions", bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("api backend %q: %w", c.name, err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "text/event-stream")
+	req.Header.Set("Authorization", "Bearer "+c.backend.APIKey)
+
+	started := time.Now()
+	resp, err := apiBackendHTTPClient.Do(req)
+	if err != nil {
+		metrics.ObserveUpstream(ctx, time.Since(started), 0, err)
+		config.Logger.WarnContext(ctx, "[completion_runtime_api_backend] backend request failed", "backend", c.name, "error", err, "elapsed", time.Since(started))
+		return nil, err
+	}
+	metrics.ObserveUpstream(ctx, time.Since(started), resp.StatusCode, nil)
+	if resp.StatusCode == http.StatusOK {
+		resp.Body = newAPIBackendStream(resp.Body, thinkingEnabled(payload))
+	}
+	return resp, nil
+}
+
+func thinkingEnabled(payload map[string]any) bool {
+	thinking, _ := payload["thinking_enabled"].(bool)
+	return thinking
+}
+
+// apiBackendStream is the translated body of a streamed backend response.
+// Closing it also closes the backend body so the translating goroutine stops.
+type apiBackendStream struct {
+	*io.PipeReader
+	upstream io.Closer
+}
+
+func newAPIBackendStream(upstream io.ReadCloser, thinking bool) io.ReadCloser {
+	pr, pw := io.Pipe()
+	go func() {
+		defer func() { _ = upstream.Close() }()
+		pw.CloseWithError(translateAPIBackendStream(pw, upstream, thinking))
+	}()
+	return apiBackendStream{PipeReader: pr, upstream: upstream}
+}
+
+func (s apiBackendStream) Close() error {
+	_ = s.PipeReader.Close()
+	return s.upstream.Close()
+}
+
+// translateAPIBackendStream rewrites OpenAI chat completion chunks into
+// DeepSeek web SSE lines: content becomes response/content, reasoning
+// becomes response/thinking_content when thinking is on, and [DONE] becomes
+// the FINISHED status.
+func translateAPIBackendStream(w io.Writer, r io.Reader, thinking bool) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64<<10), 8<<20)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		data, ok := strings.CutPrefix(line, "data:")
+		if !ok {
+			continue
+		}
+		data = strings.TrimSpace(data)
+		if data == "[DONE]" {
+			return writeWebSSE(w, "response/status", "FINISHED")
+		}
+		var chunk struct {
+			Choices []struct {
+				Delta struct {
+					Content          string `json:"content"`
+					ReasoningContent string `json:"reasoning_content"`
+				} `json:"delta"`
+			} `json:"choices"`
+		}
+		if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+			continue
+		}
+		for _, choice := range chunk.Choices {
+			if thinking && choice.Delta.ReasoningContent != "" {
+				if err := writeWebSSE(w, "response/thinking_content", choice.Delta.ReasoningContent); err != nil {
+					return err
+				}
+			}
+			if choice.Delta.Content != "" {
+				if err := writeWebSSE(w, "response/content", choice.Delta.Content); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return scanner.Err()
+}
+
+func writeWebSSE(w io.Writer, path, value string) error {
+	b, err := json.Marshal(map[string]string{"p": path, "v": value})
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "data: %s\n\n", b)
+	return err
+}
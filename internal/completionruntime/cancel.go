@@ -0,0 +1,39 @@
+package completionruntime
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"github.com/go-chi/chi/v5/middleware"
+
+	"ds2api/internal/assistantturn"
+	"ds2api/internal/config"
+	"ds2api/internal/httpapi/requestctx"
+)
+
+// contextOutputError maps a finished request context to the error surfaced
+// instead of whatever the interrupted upstream call returned, so a timeout is
+// not misreported as an auth or upstream failure. It returns nil while ctx is
+// still live.
+func contextOutputError(ctx context.Context, opts Options, surface string) *assistantturn.OutputError {
+	reason := requestctx.CancelReason(ctx)
+	if reason == "" {
+		return nil
+	}
+	config.Logger.InfoContext(ctx, "[completion_runtime] request cancelled", "trace_id", traceIDFor(ctx, opts), "surface", surface, "reason", reason)
+	switch reason {
+	case requestctx.ReasonDeadlineExceeded:
+		return &assistantturn.OutputError{Status: http.StatusGatewayTimeout, Message: requestctx.TimeoutMessage, Code: requestctx.CodeRequestTimeout}
+	case requestctx.ReasonServerShutdown:
+		return &assistantturn.OutputError{Status: http.StatusServiceUnavailable, Message: requestctx.ShutdownMessage, Code: requestctx.CodeServerShutdown}
+	}
+	return &assistantturn.OutputError{Status: 499, Message: "request context cancelled", Code: "context_cancelled"}
+}
+
+func traceIDFor(ctx context.Context, opts Options) string {
+	if traceID := strings.TrimSpace(opts.TraceID); traceID != "" {
+		return traceID
+	}
+	return middleware.GetReqID(ctx)
+}
@@ -0,0 +1,103 @@
+package completionruntime
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+
+	"ds2api/internal/assistantturn"
+	"ds2api/internal/auth"
+	"ds2api/internal/config"
+	"ds2api/internal/promptcompat"
+)
+
+// maxParallelChoices bounds how many generations of one `n > 1` request
+// talk to DeepSeek at the same time on the request's account.
+const maxParallelChoices = 4
+
+// ExecuteNonStreamChoices runs stdReq.Choices independent generations of the
+// same prompt and returns them in choice-index order. Each choice gets its own
+// upstream session and keeps the usual empty-output and JSON-mode retries,
+// but account switching is disabled because the choices share one
+// RequestAuth. The first failing choice by index fails the request.
+func ExecuteNonStreamChoices(ctx context.Context, ds DeepSeekCaller, a *auth.RequestAuth, stdReq promptcompat.StandardRequest, opts Options) ([]NonStreamResult, *assistantturn.OutputError) {
+	n := max(stdReq.Choices, 1)
+	if n == 1 {
+		result, outErr := ExecuteNonStreamWithRetry(ctx, ds, a, stdReq, opts)
+		return []NonStreamResult{result}, outErr
+	}
+	opts.DisableAccountSwitch = true
+	results := make([]NonStreamResult, n)
+	errs := make([]*assistantturn.OutputError, n)
+	runChoices(n, func(i int) {
+		results[i], errs[i] = ExecuteNonStreamWithRetry(ctx, ds, a, stdReq, opts)
+	})
+	for _, outErr := range errs {
+		if outErr != nil {
+			return results, outErr
+		}
+	}
+	return results, nil
+}
+
+// StartCompletionChoices opens stdReq.Choices upstream completions for a
+// streamed `n > 1` request. All of them must start with a 200 response before
+// anything is written to the client; otherwise every opened body is closed
+// and the first failure by index is returned.
+func StartCompletionChoices(ctx context.Context, ds DeepSeekCaller, a *auth.RequestAuth, stdReq promptcompat.StandardRequest, opts Options) ([]StartResult, *assistantturn.OutputError) {
+	n := max(stdReq.Choices, 1)
+	opts.DisableAccountSwitch = true
+	starts := make([]StartResult, n)
+	errs := make([]*assistantturn.OutputError, n)
+	runChoices(n, func(i int) {
+		starts[i], errs[i] = StartCompletion(ctx, ds, a, stdReq, opts)
+		if errs[i] == nil && starts[i].Response.StatusCode != http.StatusOK {
+			errs[i] = upstreamStatusError(starts[i].Response, stdReq.Surface)
+			starts[i].Response = nil
+		}
+	})
+	for _, outErr := range errs {
+		if outErr == nil {
+			continue
+		}
+		for _, start := range starts {
+			if start.Response != nil {
+				discardUpstreamResponse(start.Response, stdReq.Surface)
+			}
+		}
+		return starts, outErr
+	}
+	return starts, nil
+}
+
+func runChoices(n int, run func(i int)) {
+	sem := make(chan struct{}, min(n, maxParallelChoices))
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			run(i)
+		}(i)
+	}
+	wg.Wait()
+}
+
+func upstreamStatusError(resp *http.Response, surface string) *assistantturn.OutputError {
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		config.Logger.Warn("[completion_runtime] error body read failed", "surface", surface, "error", err)
+	}
+	if err := resp.Body.Close(); err != nil {
+		config.Logger.Warn("[completion_runtime] response body close failed", "surface", surface, "error", err)
+	}
+	message := strings.TrimSpace(string(body))
+	if message == "" {
+		message = http.StatusText(resp.StatusCode)
+	}
+	return &assistantturn.OutputError{Status: resp.StatusCode, Message: message, Code: "error"}
+}
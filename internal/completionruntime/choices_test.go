@@ -0,0 +1,108 @@
+package completionruntime
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+
+	"ds2api/internal/auth"
+	dsclient "ds2api/internal/deepseek/client"
+	"ds2api/internal/promptcompat"
+)
+
+// concurrentDeepSeekCaller answers every completion with a distinct body so
+// parallel choices can be told apart.
+type concurrentDeepSeekCaller struct {
+	mu       sync.Mutex
+	sessions int
+	calls    int
+	failCall int
+	bodies   []*closeTrackingBody
+}
+
+type closeTrackingBody struct {
+	io.Reader
+	closed bool
+}
+
+func (b *closeTrackingBody) Close() error {
+	b.closed = true
+	return nil
+}
+
+func (f *concurrentDeepSeekCaller) CreateSession(context.Context, *auth.RequestAuth, int) (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.sessions++
+	return fmt.Sprintf("session-%d", f.sessions), nil
+}
+
+func (f *concurrentDeepSeekCaller) GetPow(context.Context, *auth.RequestAuth, int) (string, error) {
+	return "pow", nil
+}
+
+func (f *concurrentDeepSeekCaller) UploadFile(context.Context, *auth.RequestAuth, dsclient.UploadFileRequest, int) (*dsclient.UploadFileResult, error) {
+	return &dsclient.UploadFileResult{ID: "file-runtime-1"}, nil
+}
+
+func (f *concurrentDeepSeekCaller) CallCompletion(context.Context, *auth.RequestAuth, map[string]any, string, int) (*http.Response, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.calls++
+	status := http.StatusOK
+	if f.calls == f.failCall {
+		status = http.StatusBadRequest
+	}
+	body := &closeTrackingBody{Reader: strings.NewReader(fmt.Sprintf("data: {\"p\":\"response/content\",\"v\":\"answer %d\"}\n\n", f.calls))}
+	f.bodies = append(f.bodies, body)
+	return &http.Response{StatusCode: status, Header: make(http.Header), Body: body}, nil
+}
+
+func TestExecuteNonStreamChoicesRunsOneGenerationPerChoice(t *testing.T) {
+	ds := &concurrentDeepSeekCaller{}
+	stdReq := promptcompat.StandardRequest{
+		Surface:         "test",
+		ResponseModel:   "deepseek-v4-flash",
+		PromptTokenText: "prompt",
+		FinalPrompt:     "final prompt",
+		Choices:         6,
+	}
+	results, outErr := ExecuteNonStreamChoices(context.Background(), ds, &auth.RequestAuth{}, stdReq, Options{})
+	if outErr != nil {
+		t.Fatalf("unexpected output error: %#v", outErr)
+	}
+	if len(results) != 6 || ds.calls != 6 || ds.sessions != 6 {
+		t.Fatalf("expected six independent generations, got results=%d calls=%d sessions=%d", len(results), ds.calls, ds.sessions)
+	}
+	seen := map[string]bool{}
+	for i, result := range results {
+		if result.Turn.Text == "" {
+			t.Fatalf("choice %d has no text", i)
+		}
+		seen[result.Turn.Text] = true
+	}
+	if len(seen) != 6 {
+		t.Fatalf("expected distinct outputs per choice, got %#v", seen)
+	}
+}
+
+func TestStartCompletionChoicesClosesOpenedBodiesOnFailure(t *testing.T) {
+	ds := &concurrentDeepSeekCaller{failCall: 2}
+	stdReq := promptcompat.StandardRequest{Surface: "test", FinalPrompt: "final prompt", Choices: 3}
+	_, outErr := StartCompletionChoices(context.Background(), ds, &auth.RequestAuth{}, stdReq, Options{})
+	if outErr == nil || outErr.Status != http.StatusBadRequest {
+		t.Fatalf("expected the failing choice to fail the request, got %#v", outErr)
+	}
+	if len(ds.bodies) != 3 {
+		t.Fatalf("expected three upstream calls, got %d", len(ds.bodies))
+	}
+	for i, body := range ds.bodies {
+		if !body.closed {
+			t.Fatalf("upstream body %d was left open", i)
+		}
+	}
+}
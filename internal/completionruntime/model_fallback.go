@@ -0,0 +1,109 @@
+package completionruntime
+
+import (
+	"context"
+	"net/http"
+
+	"ds2api/internal/assistantturn"
+	"ds2api/internal/auth"
+	"ds2api/internal/config"
+	"ds2api/internal/metrics"
+	"ds2api/internal/promptcompat"
+)
+
+// callCompletionWithModelFallback sends the completion for stdReq and, when
+// the upstream call fails hard, replays the same built prompt against each
+// model of stdReq.FallbackModels in turn. A "backend:<name>" link sends the
+// prompt to that configured API backend instead of the DeepSeek web client;
+// it is skipped when the request references uploaded files, which only the
+// web client can read. It runs before any response body reaches a consumer,
+// so switching models never mixes output. A key with a model allowlist only
+// falls back to entries the list names itself. The returned request carries
+// the model or backend that finally served the call.
+func callCompletionWithModelFallback(ctx context.Context, ds DeepSeekCaller, a *auth.RequestAuth, stdReq promptcompat.StandardRequest, sessionID, pow string, maxAttempts int, opts Options) (promptcompat.StandardRequest, map[string]any, *http.Response, *assistantturn.OutputError) {
+	metrics.SetModel(ctx, stdReq.ResolvedModel)
+	payload := stdReq.CompletionPayload(sessionID)
+	resp, callErr := callCompletionWithUpstreamRetry(ctx, ds, a, payload, pow, maxAttempts, opts, stdReq.Surface)
+	primary := servingModel(stdReq)
+	for callErr != nil && len(stdReq.FallbackModels) > 0 && isModelFallbackError(callErr) && ctx.Err() == nil {
+		failed := servingModel(stdReq)
+		next := stdReq.FallbackModels[0]
+		if !a.AllowsModel(next, next) {
+			stdReq.FallbackModels = stdReq.FallbackModels[1:]
+			config.Logger.InfoContext(ctx, "[completion_runtime_model_fallback] fallback model not allowed for this key; skipping", "trace_id", traceIDFor(ctx, opts), "surface", stdReq.Surface, "skipped_model", next)
+			continue
+		}
+		caller := ds
+		if name, ok := config.FallbackBackendName(next); ok {
+			backend, found := stdReq.FallbackBackends[name]
+			if !found || len(stdReq.RefFileIDs) > 0 {
+				stdReq.FallbackModels = stdReq.FallbackModels[1:]
+				config.Logger.InfoContext(ctx, "[completion_runtime_model_fallback] fallback backend cannot serve this request; skipping", "trace_id", traceIDFor(ctx, opts), "surface", stdReq.Surface, "skipped_backend", name, "configured", found, "ref_files", len(stdReq.RefFileIDs))
+				continue
+			}
+			stdReq = withNextFallbackBackend(stdReq, name, backend)
+			caller = apiBackendCaller{DeepSeekCaller: ds, name: name, backend: backend}
+		} else {
+			stdReq = withNextFallbackModel(stdReq)
+		}
+		config.Logger.WarnContext(ctx, "[completion_runtime_model_fallback] model failed upstream; trying next fallback", "trace_id", traceIDFor(ctx, opts), "surface", stdReq.Surface, "failed_model", failed, "next_model", servingModel(stdReq), "status", callErr.Status)
+		metrics.SetModel(ctx, servingModel(stdReq))
+		payload = stdReq.CompletionPayload(sessionID)
+		resp, callErr = callCompletionWithUpstreamRetry(ctx, caller, a, payload, pow, maxAttempts, opts, stdReq.Surface)
+	}
+	if callErr == nil && servingModel(stdReq) != primary {
+		config.Logger.InfoContext(ctx, "[completion_runtime_model_fallback] served by fallback model", "trace_id", traceIDFor(ctx, opts), "surface", stdReq.Surface, "requested_model", stdReq.RequestedModel, "primary_model", primary, "served_model", servingModel(stdReq))
+	}
+	return stdReq, payload, resp, callErr
+}
+
+// servingModel names what serves stdReq in logs and metrics: the resolved
+// DeepSeek model, or "backend:<name>" for an API backend.
+func servingModel(stdReq promptcompat.StandardRequest) string {
+	if stdReq.Backend != "" {
+		return config.FallbackBackendPrefix + stdReq.Backend
+	}
+	return stdReq.ResolvedModel
+}
+
+// isModelFallbackError reports a hard upstream failure: a 5xx status or a
+// connection error that survived the upstream retries. Cancellation, rate
+// limits and account errors keep the current model.
+func isModelFallbackError(outErr *assistantturn.OutputError) bool {
+	return outErr != nil && outErr.Code == "upstream_error" && outErr.Status >= http.StatusInternalServerError
+}
+
+// withNextFallbackModel switches stdReq to its first fallback model. The
+// prompt is kept as built; thinking is dropped for -nothinking models and
+// search follows the new model. A response model that echoed the resolved
+// name follows the switch.
+func withNextFallbackModel(stdReq promptcompat.StandardRequest) promptcompat.StandardRequest {
+	next := stdReq.FallbackModels[0]
+	stdReq.FallbackModels = stdReq.FallbackModels[1:]
+	echoed := stdReq.ResponseModel == stdReq.ResolvedModel
+	if stdReq.Backend != "" {
+		echoed = stdReq.ResponseModel == stdReq.FallbackBackends[stdReq.Backend].ModelFor(stdReq.Thinking)
+	}
+	if echoed {
+		stdReq.ResponseModel = next
+	}
+	stdReq.ResolvedModel = next
+	stdReq.Backend = ""
+	_, stdReq.Search, _ = config.GetModelConfig(next)
+	if config.IsNoThinkingModel(next) {
+		stdReq.Thinking = false
+	}
+	return stdReq
+}
+
+// withNextFallbackBackend switches stdReq to the API backend that heads its
+// fallback chain. The resolved model stays for prompt and usage accounting;
+// a response model that echoed it names the backend model instead.
+func withNextFallbackBackend(stdReq promptcompat.StandardRequest, name string, backend config.APIBackendConfig) promptcompat.StandardRequest {
+	stdReq.FallbackModels = stdReq.FallbackModels[1:]
+	if stdReq.ResponseModel == stdReq.ResolvedModel {
+		stdReq.ResponseModel = backend.ModelFor(stdReq.Thinking)
+	}
+	stdReq.Backend = name
+	return stdReq
+}
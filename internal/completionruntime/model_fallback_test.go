@@ -0,0 +1,191 @@
+package completionruntime
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"ds2api/internal/auth"
+	"ds2api/internal/config"
+	"ds2api/internal/promptcompat"
+)
+
+func TestStartCompletionFailsOverToFallbackModel(t *testing.T) {
+	withFastUpstreamRetry(t)
+	ds := &flakyDeepSeekCaller{fakeDeepSeekCaller: fakeDeepSeekCaller{responses: []*http.Response{
+		sseHTTPResponse(http.StatusBadGateway, `down`),
+		sseHTTPResponse(http.StatusBadGateway, `down`),
+		sseHTTPResponse(http.StatusOK, `data: {"p":"response/content","v":"ok"}`),
+	}}}
+	stdReq := promptcompat.StandardRequest{
+		Surface:        "test",
+		RequestedModel: "gpt-4o",
+		ResolvedModel:  "deepseek-v4-pro",
+		ResponseModel:  "deepseek-v4-pro",
+		FallbackModels: []string{"deepseek-v4-flash-nothinking"},
+		FinalPrompt:    "built prompt",
+		Thinking:       true,
+	}
+	start, outErr := StartCompletion(context.Background(), ds, &auth.RequestAuth{}, stdReq, Options{UpstreamRetryMaxAttempts: 2})
+	if outErr != nil {
+		t.Fatalf("unexpected output error: %#v", outErr)
+	}
+	defer func() { _ = start.Response.Body.Close() }()
+	if start.Request.ResolvedModel != "deepseek-v4-flash-nothinking" || start.Request.ResponseModel != "deepseek-v4-flash-nothinking" || start.Request.Thinking {
+		t.Fatalf("expected the fallback model to serve the request, got %#v", start.Request)
+	}
+	if len(ds.payloads) != 3 {
+		t.Fatalf("expected 3 upstream calls, got %d", len(ds.payloads))
+	}
+	if ds.payloads[0]["model_type"] != "expert" || ds.payloads[2]["model_type"] != "default" {
+		t.Fatalf("expected the model type to switch, got %v then %v", ds.payloads[0]["model_type"], ds.payloads[2]["model_type"])
+	}
+	if ds.payloads[2]["prompt"] != "built prompt" || ds.payloads[2]["thinking_enabled"] != false {
+		t.Fatalf("expected the same prompt without thinking, got %#v", ds.payloads[2])
+	}
+}
+
+func TestStartCompletionReportsLastFallbackFailure(t *testing.T) {
+	withFastUpstreamRetry(t)
+	ds := &flakyDeepSeekCaller{fakeDeepSeekCaller: fakeDeepSeekCaller{responses: []*http.Response{
+		sseHTTPResponse(http.StatusBadGateway, `down`),
+		sseHTTPResponse(http.StatusServiceUnavailable, `busy`),
+	}}}
+	stdReq := promptcompat.StandardRequest{Surface: "test", ResolvedModel: "deepseek-v4-pro", FallbackModels: []string{"deepseek-v4-flash"}}
+	start, outErr := StartCompletion(context.Background(), ds, &auth.RequestAuth{}, stdReq, Options{UpstreamRetryMaxAttempts: 1})
+	if outErr == nil || outErr.Status != http.StatusServiceUnavailable {
+		t.Fatalf("expected the last fallback's 503, got %#v", outErr)
+	}
+	if start.Request.ResolvedModel != "deepseek-v4-flash" || len(ds.payloads) != 2 {
+		t.Fatalf("expected one call per model, got model %q after %d calls", start.Request.ResolvedModel, len(ds.payloads))
+	}
+}
+
+func TestStartCompletionKeepsModelOnClientErrors(t *testing.T) {
+	ds := &flakyDeepSeekCaller{fakeDeepSeekCaller: fakeDeepSeekCaller{responses: []*http.Response{
+		sseHTTPResponse(http.StatusBadRequest, `bad`),
+	}}}
+	stdReq := promptcompat.StandardRequest{Surface: "test", ResolvedModel: "deepseek-v4-pro", FallbackModels: []string{"deepseek-v4-flash"}}
+	start, outErr := StartCompletion(context.Background(), ds, &auth.RequestAuth{}, stdReq, Options{})
+	if outErr != nil {
+		t.Fatalf("unexpected output error: %#v", outErr)
+	}
+	defer func() { _ = start.Response.Body.Close() }()
+	if start.Request.ResolvedModel != "deepseek-v4-pro" || len(ds.payloads) != 1 {
+		t.Fatalf("expected no failover for a 4xx, got model %q after %d calls", start.Request.ResolvedModel, len(ds.payloads))
+	}
+}
+
+func TestStartCompletionSkipsFallbackModelsOutsideAllowlist(t *testing.T) {
+	withFastUpstreamRetry(t)
+	ds := &flakyDeepSeekCaller{fakeDeepSeekCaller: fakeDeepSeekCaller{responses: []*http.Response{
+		sseHTTPResponse(http.StatusBadGateway, `down`),
+		sseHTTPResponse(http.StatusOK, `data: {"p":"response/content","v":"ok"}`),
+	}}}
+	stdReq := promptcompat.StandardRequest{
+		Surface:        "test",
+		RequestedModel: "gpt-4o",
+		ResolvedModel:  "deepseek-v4-flash",
+		FallbackModels: []string{"deepseek-v4-pro"},
+	}
+	a := &auth.RequestAuth{AllowedModels: []string{"gpt-4o"}}
+	start, outErr := StartCompletion(context.Background(), ds, a, stdReq, Options{UpstreamRetryMaxAttempts: 1})
+	if outErr == nil || outErr.Status != http.StatusBadGateway {
+		t.Fatalf("expected the primary's 502 once the only fallback is skipped, got %#v", outErr)
+	}
+	if len(ds.payloads) != 1 || start.Request.ResolvedModel != "deepseek-v4-flash" {
+		t.Fatalf("expected the disallowed fallback never to be called, got %d calls ending on %q", len(ds.payloads), start.Request.ResolvedModel)
+	}
+
+	ds = &flakyDeepSeekCaller{fakeDeepSeekCaller: fakeDeepSeekCaller{responses: []*http.Response{
+		sseHTTPResponse(http.StatusBadGateway, `down`),
+		sseHTTPResponse(http.StatusOK, `data: {"p":"response/content","v":"ok"}`),
+	}}}
+	stdReq.FallbackModels = []string{"deepseek-v4-pro", "deepseek-v4-flash-nothinking"}
+	a.AllowedModels = []string{"gpt-4o", "deepseek-v4-flash-nothinking"}
+	start, outErr = StartCompletion(context.Background(), ds, a, stdReq, Options{UpstreamRetryMaxAttempts: 1})
+	if outErr != nil {
+		t.Fatalf("unexpected output error: %#v", outErr)
+	}
+	defer func() { _ = start.Response.Body.Close() }()
+	if start.Request.ResolvedModel != "deepseek-v4-flash-nothinking" || len(ds.payloads) != 2 {
+		t.Fatalf("expected to skip to the allowed fallback, got %q after %d calls", start.Request.ResolvedModel, len(ds.payloads))
+	}
+}
+
+func TestExecuteNonStreamFailsOverToAPIBackend(t *testing.T) {
+	withFastUpstreamRetry(t)
+	var got map[string]any
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/chat/completions" || r.Header.Get("Authorization") != "Bearer sk-test" {
+			t.Errorf("unexpected backend call %s with auth %q", r.URL.Path, r.Header.Get("Authorization"))
+		}
+		_ = json.NewDecoder(r.Body).Decode(&got)
+		w.Header().Set("Content-Type", "text/event-stream")
+		_, _ = io.WriteString(w, "data: {\"choices\":[{\"delta\":{\"reasoning_content\":\"hmm\"}}]}\n\n")
+		_, _ = io.WriteString(w, "data: {\"choices\":[{\"delta\":{\"content\":\"from \"}}]}\n\n")
+		_, _ = io.WriteString(w, "data: {\"choices\":[{\"delta\":{\"content\":\"backend\"},\"finish_reason\":\"stop\"}]}\n\n")
+		_, _ = io.WriteString(w, "data: [DONE]\n\n")
+	}))
+	defer backend.Close()
+	ds := &flakyDeepSeekCaller{fakeDeepSeekCaller: fakeDeepSeekCaller{responses: []*http.Response{
+		sseHTTPResponse(http.StatusBadGateway, `down`),
+	}}}
+	stdReq := promptcompat.StandardRequest{
+		Surface:        "test",
+		RequestedModel: "deepseek-v4-pro",
+		ResolvedModel:  "deepseek-v4-pro",
+		ResponseModel:  "deepseek-v4-pro",
+		FallbackModels: []string{"backend:official"},
+		FallbackBackends: map[string]config.APIBackendConfig{
+			"official": {BaseURL: backend.URL + "/v1", APIKey: "sk-test", Model: "deepseek-chat", ThinkingModel: "deepseek-reasoner"},
+		},
+		FinalPrompt: "built prompt",
+		Thinking:    true,
+		PassThrough: map[string]any{"temperature": 0.2},
+	}
+	result, outErr := ExecuteNonStreamWithRetry(context.Background(), ds, &auth.RequestAuth{}, stdReq, Options{UpstreamRetryMaxAttempts: 1, RetryEnabled: true})
+	if outErr != nil {
+		t.Fatalf("unexpected output error: %#v", outErr)
+	}
+	if result.Turn.Text != "from backend" || result.Turn.Thinking != "hmm" {
+		t.Fatalf("expected the backend answer, got text %q thinking %q", result.Turn.Text, result.Turn.Thinking)
+	}
+	if len(ds.payloads) != 1 {
+		t.Fatalf("expected one web call before the backend, got %d", len(ds.payloads))
+	}
+	if result.Turn.Model != "deepseek-reasoner" {
+		t.Fatalf("expected the backend model in the response, got %q", result.Turn.Model)
+	}
+	if got["model"] != "deepseek-reasoner" || got["temperature"] != 0.2 || got["stream"] != true {
+		t.Fatalf("unexpected backend request %#v", got)
+	}
+	messages, _ := got["messages"].([]any)
+	if len(messages) != 1 || messages[0].(map[string]any)["content"] != "built prompt" {
+		t.Fatalf("expected the built prompt as one user message, got %#v", got["messages"])
+	}
+}
+
+func TestStartCompletionSkipsAPIBackendForUploadedFiles(t *testing.T) {
+	withFastUpstreamRetry(t)
+	ds := &flakyDeepSeekCaller{fakeDeepSeekCaller: fakeDeepSeekCaller{responses: []*http.Response{
+		sseHTTPResponse(http.StatusBadGateway, `down`),
+	}}}
+	stdReq := promptcompat.StandardRequest{
+		Surface:          "test",
+		ResolvedModel:    "deepseek-v4-pro",
+		FallbackModels:   []string{"backend:official"},
+		FallbackBackends: map[string]config.APIBackendConfig{"official": {BaseURL: "http://127.0.0.1:1", APIKey: "sk-test", Model: "deepseek-chat"}},
+		RefFileIDs:       []string{"file-1"},
+	}
+	start, outErr := StartCompletion(context.Background(), ds, &auth.RequestAuth{}, stdReq, Options{UpstreamRetryMaxAttempts: 1})
+	if outErr == nil || outErr.Status != http.StatusBadGateway {
+		t.Fatalf("expected the primary's 502, got %#v", outErr)
+	}
+	if start.Request.Backend != "" {
+		t.Fatalf("expected the backend to be skipped, got %q", start.Request.Backend)
+	}
+}
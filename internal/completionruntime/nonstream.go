@@ -13,6 +13,7 @@ import (
 	dsclient "ds2api/internal/deepseek/client"
 	"ds2api/internal/httpapi/openai/history"
 	"ds2api/internal/httpapi/openai/shared"
+	"ds2api/internal/metrics"
 	"ds2api/internal/promptcompat"
 	"ds2api/internal/sse"
 )
@@ -29,7 +30,16 @@ type Options struct {
 	MaxAttempts           int
 	RetryEnabled          bool
 	RetryMaxAttempts      int
-	CurrentInputFile      history.CurrentInputConfigReader
+	// UpstreamRetryMaxAttempts caps transient-failure retries of the initial
+	// completion call; zero uses the default of 3.
+	UpstreamRetryMaxAttempts int
+	// TraceID correlates upstream retry log lines; empty falls back to the
+	// chi request ID on the context.
+	TraceID          string
+	CurrentInputFile history.CurrentInputConfigReader
+	// DisableAccountSwitch keeps every retry on the current account; set
+	// when several generations share one RequestAuth concurrently.
+	DisableAccountSwitch bool
 }
 
 type NonStreamResult struct {
@@ -59,16 +69,21 @@ func StartCompletion(ctx context.Context, ds DeepSeekCaller, a *auth.RequestAuth
 	}
 	sessionID, err := ds.CreateSession(ctx, a, maxAttempts)
 	if err != nil {
+		if cancelErr := contextOutputError(ctx, opts, stdReq.Surface); cancelErr != nil {
+			return StartResult{Request: stdReq}, cancelErr
+		}
 		return StartResult{Request: stdReq}, authOutputError(a)
 	}
 	pow, err := ds.GetPow(ctx, a, maxAttempts)
 	if err != nil {
-		return StartResult{SessionID: sessionID, Request: stdReq}, &assistantturn.OutputError{Status: http.StatusUnauthorized, Message: "Failed to get PoW (invalid token or unknown error).", Code: "error"}
+		if cancelErr := contextOutputError(ctx, opts, stdReq.Surface); cancelErr != nil {
+			return StartResult{SessionID: sessionID, Request: stdReq}, cancelErr
+		}
+		return StartResult{SessionID: sessionID, Request: stdReq}, powOutputError(err)
 	}
-	payload := stdReq.CompletionPayload(sessionID)
-	resp, err := ds.CallCompletion(ctx, a, payload, pow, maxAttempts)
-	if err != nil {
-		return StartResult{SessionID: sessionID, Payload: payload, Pow: pow, Request: stdReq}, &assistantturn.OutputError{Status: http.StatusInternalServerError, Message: "Failed to get completion.", Code: "error"}
+	stdReq, payload, resp, callErr := callCompletionWithModelFallback(ctx, ds, a, stdReq, sessionID, pow, maxAttempts, opts)
+	if callErr != nil {
+		return StartResult{SessionID: sessionID, Payload: payload, Pow: pow, Request: stdReq}, callErr
 	}
 	return StartResult{SessionID: sessionID, Payload: payload, Pow: pow, Response: resp, Request: stdReq}, nil
 }
@@ -95,6 +110,11 @@ func ExecuteNonStreamWithRetry(ctx context.Context, ds DeepSeekCaller, a *auth.R
 
 func ExecuteNonStreamStartedWithRetry(ctx context.Context, ds DeepSeekCaller, a *auth.RequestAuth, start StartResult, opts Options) (NonStreamResult, *assistantturn.OutputError) {
 	stdReq := start.Request
+	if stdReq.Backend != "" {
+		// Empty-output, format and account retries continue a DeepSeek web
+		// session, which an API backend does not have.
+		opts.RetryEnabled = false
+	}
 	maxAttempts := opts.MaxAttempts
 	if maxAttempts <= 0 {
 		maxAttempts = 3
@@ -105,6 +125,8 @@ func ExecuteNonStreamStartedWithRetry(ctx context.Context, ds DeepSeekCaller, a
 
 	attempts := 0
 	accountSwitchAttempted := false
+	formatRetryAttempted := false
+	bannedRetryAttempted := false
 	currentResp := start.Response
 	usagePrompt := stdReq.PromptTokenText
 	accumulatedThinking := ""
@@ -112,14 +134,19 @@ func ExecuteNonStreamStartedWithRetry(ctx context.Context, ds DeepSeekCaller, a
 	accumulatedToolDetectionThinking := ""
 	for {
 		turn, outErr := collectAttempt(currentResp, stdReq, usagePrompt, opts)
+		// A disconnect or deadline mid-collection leaves a truncated turn that
+		// must not be mistaken for empty output and retried.
+		if cancelErr := contextOutputError(ctx, opts, stdReq.Surface); cancelErr != nil {
+			return NonStreamResult{SessionID: sessionID, Payload: payload, Turn: turn, Attempts: attempts}, cancelErr
+		}
 		if outErr != nil {
-			if canRetryOnAlternateAccount(ctx, a, outErr, opts.RetryEnabled, &accountSwitchAttempted) {
+			if canRetryOnAlternateAccount(ctx, a, outErr, opts.RetryEnabled && !opts.DisableAccountSwitch, &accountSwitchAttempted) {
 				switched, switchErr := startStandardCompletionOnAlternateAccount(ctx, ds, a, stdReq, opts, maxAttempts)
 				if switchErr != nil {
 					return NonStreamResult{SessionID: sessionID, Payload: payload, Attempts: attempts}, switchErr
 				}
 				if switched.Response != nil {
-					config.Logger.Info("[completion_runtime_account_switch_retry] retrying after 429", "surface", stdReq.Surface, "stream", false, "account", a.AccountID)
+					config.Logger.InfoContext(ctx, "[completion_runtime_account_switch_retry] retrying after 429", "surface", stdReq.Surface, "stream", false, "account", a.AccountID)
 					sessionID = switched.SessionID
 					payload = switched.Payload
 					pow = switched.Pow
@@ -146,20 +173,58 @@ func ExecuteNonStreamStartedWithRetry(ctx context.Context, ds DeepSeekCaller, a
 			ContentFilter:         turn.ContentFilter,
 			CitationLinks:         turn.CitationLinks,
 			ResponseMessageID:     turn.ResponseMessageID,
+			OutputLimitReached:    turn.StopReason == assistantturn.StopReasonLength,
 		}, buildOptions(stdReq, usagePrompt, opts))
 
+		if opts.RetryEnabled && !formatRetryAttempted && assistantturn.IsResponseFormatError(turn.Error) {
+			formatRetryAttempted = true
+			config.Logger.InfoContext(ctx, "[completion_runtime_json_retry] retrying invalid JSON-mode output", "surface", stdReq.Surface, "code", turn.Error.Code, "parent_message_id", turn.ResponseMessageID)
+			nextResp, retryErr := callInstructionRetry(ctx, ds, a, payload, pow, turn.ResponseMessageID, promptcompat.JSONFormatRetryInstruction, maxAttempts, opts, "[completion_runtime_json_retry]", stdReq.Surface)
+			if retryErr != nil {
+				return NonStreamResult{SessionID: sessionID, Payload: payload, Turn: turn, Attempts: attempts}, retryErr
+			}
+			usagePrompt = usagePrompt + "\n" + shared.AppendRetrySuffix(usagePrompt, promptcompat.JSONFormatRetryInstruction)
+			accumulatedThinking = ""
+			accumulatedRawThinking = ""
+			accumulatedToolDetectionThinking = ""
+			currentResp = nextResp
+			continue
+		}
+
+		// logit_bias cannot be enforced upstream: when a banned word still
+		// slipped through, regenerate once and otherwise keep the filtered text.
+		// A regeneration the upstream could not serve also keeps it, but a
+		// hook refusal or cancellation is reported as on the first attempt.
+		if opts.RetryEnabled && !bannedRetryAttempted && turn.Error == nil && turn.BannedWordsFiltered {
+			bannedRetryAttempted = true
+			config.Logger.InfoContext(ctx, "[completion_runtime_logit_bias_retry] regenerating output that used banned words", "surface", stdReq.Surface, "parent_message_id", turn.ResponseMessageID)
+			instruction := promptcompat.BannedWordsRetryInstruction(stdReq.BannedWords)
+			nextResp, retryErr := callInstructionRetry(ctx, ds, a, payload, pow, turn.ResponseMessageID, instruction, maxAttempts, opts, "[completion_runtime_logit_bias_retry]", stdReq.Surface)
+			if retryErr == nil {
+				usagePrompt = usagePrompt + "\n" + shared.AppendRetrySuffix(usagePrompt, instruction)
+				accumulatedThinking = ""
+				accumulatedRawThinking = ""
+				accumulatedToolDetectionThinking = ""
+				currentResp = nextResp
+				continue
+			}
+			if !isUpstreamUnavailable(retryErr) {
+				return NonStreamResult{SessionID: sessionID, Payload: payload, Turn: turn, Attempts: attempts}, retryErr
+			}
+		}
+
 		retryMax := opts.RetryMaxAttempts
 		if retryMax <= 0 {
 			retryMax = shared.EmptyOutputRetryMaxAttempts()
 		}
 		if !opts.RetryEnabled || !assistantturn.ShouldRetryEmptyOutput(turn, attempts, retryMax) {
-			if canRetryOnAlternateAccount(ctx, a, turn.Error, opts.RetryEnabled, &accountSwitchAttempted) {
+			if canRetryOnAlternateAccount(ctx, a, turn.Error, opts.RetryEnabled && !opts.DisableAccountSwitch, &accountSwitchAttempted) {
 				switched, switchErr := startStandardCompletionOnAlternateAccount(ctx, ds, a, stdReq, opts, maxAttempts)
 				if switchErr != nil {
 					return NonStreamResult{SessionID: sessionID, Payload: payload, Turn: turn, Attempts: attempts}, switchErr
 				}
 				if switched.Response != nil {
-					config.Logger.Info("[completion_runtime_account_switch_retry] retrying after 429", "surface", stdReq.Surface, "stream", false, "account", a.AccountID)
+					config.Logger.InfoContext(ctx, "[completion_runtime_account_switch_retry] retrying after 429", "surface", stdReq.Surface, "stream", false, "account", a.AccountID)
 					sessionID = switched.SessionID
 					payload = switched.Payload
 					pow = switched.Pow
@@ -171,18 +236,22 @@ func ExecuteNonStreamStartedWithRetry(ctx context.Context, ds DeepSeekCaller, a
 					continue
 				}
 			}
+			recordTurnMetrics(ctx, turn)
 			return NonStreamResult{SessionID: sessionID, Payload: payload, Turn: turn, Attempts: attempts}, turn.Error
 		}
 
 		attempts++
-		config.Logger.Info("[completion_runtime_empty_retry] attempting synthetic retry", "surface", stdReq.Surface, "stream", false, "retry_attempt", attempts, "parent_message_id", turn.ResponseMessageID)
+		config.Logger.InfoContext(ctx, "[completion_runtime_empty_retry] attempting synthetic retry", "surface", stdReq.Surface, "stream", false, "retry_attempt", attempts, "parent_message_id", turn.ResponseMessageID)
 		retryPow, powErr := ds.GetPow(ctx, a, maxAttempts)
 		if powErr != nil {
-			config.Logger.Warn("[completion_runtime_empty_retry] retry PoW fetch failed, falling back to original PoW", "surface", stdReq.Surface, "retry_attempt", attempts, "error", powErr)
+			config.Logger.WarnContext(ctx, "[completion_runtime_empty_retry] retry PoW fetch failed, falling back to original PoW", "surface", stdReq.Surface, "retry_attempt", attempts, "error", powErr)
 			retryPow = pow
 		}
 		retryPayload := shared.ClonePayloadForEmptyOutputRetry(payload, turn.ResponseMessageID)
 		nextResp, err := ds.CallCompletion(ctx, a, retryPayload, retryPow, maxAttempts)
+		if hookErr := hookOutputError(err); hookErr != nil {
+			return NonStreamResult{SessionID: sessionID, Payload: payload, Turn: turn, Attempts: attempts}, hookErr
+		}
 		if err != nil {
 			return NonStreamResult{SessionID: sessionID, Payload: payload, Turn: turn, Attempts: attempts}, &assistantturn.OutputError{Status: http.StatusInternalServerError, Message: "Failed to get completion.", Code: "error"}
 		}
@@ -191,8 +260,21 @@ func ExecuteNonStreamStartedWithRetry(ctx context.Context, ds DeepSeekCaller, a
 	}
 }
 
+// recordTurnMetrics reports the final turn of a non-stream generation:
+// generated tokens on success, or a parse failure when JSON-mode output could
+// not be repaired.
+func recordTurnMetrics(ctx context.Context, turn assistantturn.Turn) {
+	if turn.Error == nil {
+		metrics.AddGeneratedTokens(ctx, turn.Usage.OutputTokens)
+		return
+	}
+	if assistantturn.IsResponseFormatError(turn.Error) {
+		metrics.RecordError(ctx, metrics.ErrorParseFailure)
+	}
+}
+
 func canRetryOnAlternateAccount(ctx context.Context, a *auth.RequestAuth, outErr *assistantturn.OutputError, retryEnabled bool, attempted *bool) bool {
-	if outErr == nil || outErr.Status != http.StatusTooManyRequests {
+	if outErr == nil || outErr.Status != http.StatusTooManyRequests || outErr.Code == codeUpstreamBusy {
 		return false
 	}
 	if !retryEnabled || attempted == nil || *attempted {
@@ -217,16 +299,36 @@ func startStandardCompletionOnAlternateAccount(ctx context.Context, ds DeepSeekC
 	}
 	pow, err := ds.GetPow(ctx, a, maxAttempts)
 	if err != nil {
-		return StartResult{SessionID: sessionID}, &assistantturn.OutputError{Status: http.StatusUnauthorized, Message: "Failed to get PoW (invalid token or unknown error).", Code: "error"}
+		return StartResult{SessionID: sessionID}, powOutputError(err)
 	}
+	metrics.SetModel(ctx, stdReq.ResolvedModel)
 	payload := stdReq.CompletionPayload(sessionID)
-	resp, err := ds.CallCompletion(ctx, a, payload, pow, maxAttempts)
-	if err != nil {
-		return StartResult{SessionID: sessionID, Payload: payload, Pow: pow}, &assistantturn.OutputError{Status: http.StatusInternalServerError, Message: "Failed to get completion.", Code: "error"}
+	resp, callErr := callCompletionWithUpstreamRetry(ctx, ds, a, payload, pow, maxAttempts, opts, stdReq.Surface)
+	if callErr != nil {
+		return StartResult{SessionID: sessionID, Payload: payload, Pow: pow}, callErr
 	}
 	return StartResult{SessionID: sessionID, Payload: payload, Pow: pow, Response: resp, Request: stdReq}, nil
 }
 
+// callInstructionRetry re-asks the model as a continuation of
+// parentMessageID with instruction appended to the prompt. The call goes
+// through callCompletionWithUpstreamRetry, so transient failures, upstream
+// busy and hook refusals are handled as on the first attempt.
+func callInstructionRetry(ctx context.Context, ds DeepSeekCaller, a *auth.RequestAuth, payload map[string]any, pow string, parentMessageID int, instruction string, maxAttempts int, opts Options, tag, surface string) (*http.Response, *assistantturn.OutputError) {
+	retryPow, powErr := ds.GetPow(ctx, a, maxAttempts)
+	if powErr != nil {
+		config.Logger.WarnContext(ctx, tag+" retry PoW fetch failed, falling back to original PoW", "surface", surface, "error", powErr)
+		retryPow = pow
+	}
+	retryPayload := shared.ClonePayloadWithRetrySuffix(payload, parentMessageID, instruction)
+	resp, callErr := callCompletionWithUpstreamRetry(ctx, ds, a, retryPayload, retryPow, maxAttempts, opts, surface)
+	if callErr != nil {
+		config.Logger.WarnContext(ctx, tag+" retry request failed", "surface", surface, "status", callErr.Status, "code", callErr.Code)
+		return nil, callErr
+	}
+	return resp, nil
+}
+
 func reuploadCurrentInputFileForAccount(ctx context.Context, ds DeepSeekCaller, a *auth.RequestAuth, stdReq promptcompat.StandardRequest, opts Options) (promptcompat.StandardRequest, *assistantturn.OutputError) {
 	if opts.CurrentInputFile == nil || !stdReq.CurrentInputFileApplied {
 		return stdReq, nil
@@ -253,7 +355,8 @@ func collectAttempt(resp *http.Response, stdReq promptcompat.StandardRequest, us
 		}
 		return assistantturn.Turn{}, &assistantturn.OutputError{Status: resp.StatusCode, Message: message, Code: "error"}
 	}
-	result := sse.CollectStream(resp, stdReq.Thinking, false)
+	limiter := sse.NewOutputTokenLimiter(stdReq.MaxOutputTokens, stdReq.ResponseModel)
+	result := sse.CollectStreamWithLimits(resp, stdReq.Thinking, false, stdReq.StopSequences, limiter)
 	return assistantturn.BuildTurnFromCollected(result, buildOptions(stdReq, usagePrompt, opts)), nil
 }
 
@@ -267,6 +370,9 @@ func buildOptions(stdReq promptcompat.StandardRequest, prompt string, opts Optio
 		ToolNames:             stdReq.ToolNames,
 		ToolsRaw:              stdReq.ToolsRaw,
 		ToolChoice:            stdReq.ToolChoice,
+		ToolCallFormat:        stdReq.ToolPrompt.Format,
+		ResponseFormat:        stdReq.ResponseFormat,
+		BannedWords:           stdReq.BannedWords,
 	}
 }
 
@@ -2,16 +2,21 @@ package completionruntime
 
 import (
 	"context"
+	"errors"
 	"io"
 	"net/http"
 	"strings"
 	"testing"
+	"time"
 
 	"ds2api/internal/account"
+	"ds2api/internal/assistantturn"
 	"ds2api/internal/auth"
 	"ds2api/internal/config"
 	dsclient "ds2api/internal/deepseek/client"
+	"ds2api/internal/httpapi/requestctx"
 	"ds2api/internal/promptcompat"
+	"ds2api/internal/upstreamhook"
 )
 
 type fakeDeepSeekCaller struct {
@@ -331,3 +336,233 @@ func sseHTTPResponse(status int, lines ...string) *http.Response {
 		Body:       io.NopCloser(strings.NewReader(body)),
 	}
 }
+
+func TestExecuteNonStreamWithRetryRetriesInvalidJSONModeOutputOnce(t *testing.T) {
+	ds := &fakeDeepSeekCaller{responses: []*http.Response{
+		sseHTTPResponse(http.StatusOK, `data: {"response_message_id":7,"p":"response/content","v":"Sorry, here is prose."}`),
+		sseHTTPResponse(http.StatusOK, `data: {"response_message_id":8,"p":"response/content","v":"{\"ok\":true}"}`),
+	}}
+	stdReq := promptcompat.StandardRequest{
+		Surface:         "test",
+		ResponseModel:   "deepseek-v4-flash",
+		PromptTokenText: "prompt",
+		FinalPrompt:     "final prompt",
+		ResponseFormat:  promptcompat.ResponseFormat{Type: promptcompat.ResponseFormatJSONObject},
+	}
+	result, outErr := ExecuteNonStreamWithRetry(context.Background(), ds, &auth.RequestAuth{}, stdReq, Options{RetryEnabled: true})
+	if outErr != nil {
+		t.Fatalf("unexpected output error: %#v", outErr)
+	}
+	if result.Turn.Text != `{"ok":true}` {
+		t.Fatalf("unexpected text: %q", result.Turn.Text)
+	}
+	if len(ds.payloads) != 2 {
+		t.Fatalf("expected one JSON-mode retry, got %d calls", len(ds.payloads))
+	}
+	retryPrompt, _ := ds.payloads[1]["prompt"].(string)
+	if !strings.Contains(retryPrompt, promptcompat.JSONFormatRetryInstruction) || ds.payloads[1]["parent_message_id"] != 7 {
+		t.Fatalf("unexpected retry payload: %#v", ds.payloads[1])
+	}
+}
+
+func TestExecuteNonStreamWithRetryReturnsJSONModeErrorAfterRetry(t *testing.T) {
+	ds := &fakeDeepSeekCaller{responses: []*http.Response{
+		sseHTTPResponse(http.StatusOK, `data: {"p":"response/content","v":"not json"}`),
+		sseHTTPResponse(http.StatusOK, `data: {"p":"response/content","v":"still not json"}`),
+	}}
+	stdReq := promptcompat.StandardRequest{
+		Surface:        "test",
+		ResponseModel:  "deepseek-v4-flash",
+		FinalPrompt:    "final prompt",
+		ResponseFormat: promptcompat.ResponseFormat{Type: promptcompat.ResponseFormatJSONObject},
+	}
+	_, outErr := ExecuteNonStreamWithRetry(context.Background(), ds, &auth.RequestAuth{}, stdReq, Options{RetryEnabled: true})
+	if outErr == nil || outErr.Status != http.StatusBadRequest || outErr.Code != "invalid_json_output" {
+		t.Fatalf("expected invalid_json_output after bounded retry, got %#v", outErr)
+	}
+	if len(ds.payloads) != 2 {
+		t.Fatalf("expected exactly two upstream calls, got %d", len(ds.payloads))
+	}
+}
+
+func TestExecuteNonStreamWithRetryRetriesTransientFailureOfJSONModeRetry(t *testing.T) {
+	withFastUpstreamRetry(t)
+	ds := &flakyDeepSeekCaller{
+		errs: []error{nil, errors.New("connection reset")},
+		fakeDeepSeekCaller: fakeDeepSeekCaller{responses: []*http.Response{
+			sseHTTPResponse(http.StatusOK, `data: {"response_message_id":7,"p":"response/content","v":"not json"}`),
+			sseHTTPResponse(http.StatusOK, `data: {"response_message_id":8,"p":"response/content","v":"{\"ok\":true}"}`),
+		}},
+	}
+	stdReq := promptcompat.StandardRequest{
+		Surface:        "test",
+		ResponseModel:  "deepseek-v4-flash",
+		FinalPrompt:    "final prompt",
+		ResponseFormat: promptcompat.ResponseFormat{Type: promptcompat.ResponseFormatJSONObject},
+	}
+	result, outErr := ExecuteNonStreamWithRetry(context.Background(), ds, &auth.RequestAuth{}, stdReq, Options{RetryEnabled: true, UpstreamRetryMaxAttempts: 2})
+	if outErr != nil {
+		t.Fatalf("unexpected output error: %#v", outErr)
+	}
+	if result.Turn.Text != `{"ok":true}` || ds.calls != 3 {
+		t.Fatalf("expected the JSON-mode retry to survive a transient failure, got %q after %d calls", result.Turn.Text, ds.calls)
+	}
+}
+
+func TestExecuteNonStreamWithRetryTruncatesAtStopSequence(t *testing.T) {
+	ds := &fakeDeepSeekCaller{responses: []*http.Response{
+		sseHTTPResponse(http.StatusOK, `data: {"p":"response/content","v":"one two"}`, `data: {"p":"response/content","v":" STOP three"}`),
+	}}
+	stdReq := promptcompat.StandardRequest{
+		Surface:         "test",
+		ResponseModel:   "deepseek-v4-flash",
+		PromptTokenText: "prompt",
+		FinalPrompt:     "final prompt",
+		StopSequences:   []string{" STOP"},
+	}
+	result, outErr := ExecuteNonStreamWithRetry(context.Background(), ds, &auth.RequestAuth{}, stdReq, Options{})
+	if outErr != nil {
+		t.Fatalf("unexpected output error: %#v", outErr)
+	}
+	if result.Turn.Text != "one two" {
+		t.Fatalf("expected text truncated before stop sequence, got %q", result.Turn.Text)
+	}
+	if got := assistantturn.FinishReason(result.Turn); got != "stop" {
+		t.Fatalf("expected finish reason stop, got %q", got)
+	}
+}
+
+type contextBoundBody struct{ ctx context.Context }
+
+func (b contextBoundBody) Read([]byte) (int, error) {
+	<-b.ctx.Done()
+	return 0, b.ctx.Err()
+}
+
+func (contextBoundBody) Close() error { return nil }
+
+func TestExecuteNonStreamWithRetryReportsDeadlineWithoutEmptyRetry(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	ds := &fakeDeepSeekCaller{responses: []*http.Response{{
+		StatusCode: http.StatusOK,
+		Header:     make(http.Header),
+		Body:       contextBoundBody{ctx: ctx},
+	}}}
+	_, outErr := ExecuteNonStreamWithRetry(ctx, ds, &auth.RequestAuth{}, promptcompat.StandardRequest{Surface: "test"}, Options{RetryEnabled: true})
+	if outErr == nil || outErr.Status != http.StatusGatewayTimeout || outErr.Code != "request_timeout" {
+		t.Fatalf("expected 504 request_timeout, got %#v", outErr)
+	}
+	if len(ds.payloads) != 1 {
+		t.Fatalf("expected no retry after the deadline, got %d completion calls", len(ds.payloads))
+	}
+}
+
+func TestExecuteNonStreamWithRetryReportsServerShutdown(t *testing.T) {
+	ctx, cancel := context.WithCancelCause(context.Background())
+	defer cancel(nil)
+	time.AfterFunc(20*time.Millisecond, func() { cancel(requestctx.ErrServerShutdown) })
+	ds := &fakeDeepSeekCaller{responses: []*http.Response{{
+		StatusCode: http.StatusOK,
+		Header:     make(http.Header),
+		Body:       contextBoundBody{ctx: ctx},
+	}}}
+	_, outErr := ExecuteNonStreamWithRetry(ctx, ds, &auth.RequestAuth{}, promptcompat.StandardRequest{Surface: "test"}, Options{RetryEnabled: true})
+	if outErr == nil || outErr.Status != http.StatusServiceUnavailable || outErr.Code != requestctx.CodeServerShutdown {
+		t.Fatalf("expected 503 server_shutdown, got %#v", outErr)
+	}
+}
+
+func TestExecuteNonStreamWithRetryCutsAtMaxOutputTokens(t *testing.T) {
+	ds := &fakeDeepSeekCaller{responses: []*http.Response{
+		sseHTTPResponse(http.StatusOK, `data: {"p":"response/content","v":"one"}`, `data: {"p":"response/content","v":" two three four five six seven eight nine ten eleven twelve"}`),
+	}}
+	stdReq := promptcompat.StandardRequest{
+		Surface:         "test",
+		ResponseModel:   "deepseek-v4-flash",
+		PromptTokenText: "prompt",
+		FinalPrompt:     "final prompt",
+		MaxOutputTokens: 2,
+	}
+	result, outErr := ExecuteNonStreamWithRetry(context.Background(), ds, &auth.RequestAuth{}, stdReq, Options{RetryEnabled: true})
+	if outErr != nil {
+		t.Fatalf("unexpected output error: %#v", outErr)
+	}
+	if !strings.HasPrefix(result.Turn.Text, "one") || strings.Contains(result.Turn.Text, "twelve") {
+		t.Fatalf("expected text cut at the token budget, got %q", result.Turn.Text)
+	}
+	if got := assistantturn.FinishReason(result.Turn); got != "length" {
+		t.Fatalf("expected finish reason length, got %q", got)
+	}
+}
+
+func TestExecuteNonStreamWithRetryRegeneratesOnceWhenBannedWordAppears(t *testing.T) {
+	ds := &fakeDeepSeekCaller{responses: []*http.Response{
+		sseHTTPResponse(http.StatusOK, `data: {"response_message_id":7,"p":"response/content","v":"A very good answer."}`),
+		sseHTTPResponse(http.StatusOK, `data: {"response_message_id":8,"p":"response/content","v":"Still very good."}`),
+	}}
+	stdReq := promptcompat.StandardRequest{
+		Surface:         "test",
+		ResponseModel:   "deepseek-v4-flash",
+		PromptTokenText: "prompt",
+		FinalPrompt:     "final prompt",
+		BannedWords:     []string{"very"},
+	}
+	result, outErr := ExecuteNonStreamWithRetry(context.Background(), ds, &auth.RequestAuth{}, stdReq, Options{RetryEnabled: true})
+	if outErr != nil {
+		t.Fatalf("unexpected output error: %#v", outErr)
+	}
+	if len(ds.payloads) != 2 {
+		t.Fatalf("expected exactly one banned-word regeneration, got %d calls", len(ds.payloads))
+	}
+	retryPrompt, _ := ds.payloads[1]["prompt"].(string)
+	if !strings.Contains(retryPrompt, `"very"`) || ds.payloads[1]["parent_message_id"] != 7 {
+		t.Fatalf("unexpected retry payload: %#v", ds.payloads[1])
+	}
+	if result.Turn.Text != "Still good." {
+		t.Fatalf("expected banned word filtered after the bounded retry, got %q", result.Turn.Text)
+	}
+}
+
+func TestExecuteNonStreamWithRetryBannedWordRetryMapsErrorsLikeFirstAttempt(t *testing.T) {
+	withFastUpstreamRetry(t)
+	t.Cleanup(upstreamhook.Register(refusingHook{}))
+	hookErr := upstreamhook.RunBefore(context.Background(), &upstreamhook.Request{})
+	stdReq := promptcompat.StandardRequest{
+		Surface:         "test",
+		ResponseModel:   "deepseek-v4-flash",
+		PromptTokenText: "prompt",
+		FinalPrompt:     "final prompt",
+		BannedWords:     []string{"very"},
+	}
+	first := func() *http.Response {
+		return sseHTTPResponse(http.StatusOK, `data: {"response_message_id":7,"p":"response/content","v":"A very good answer."}`)
+	}
+
+	flaky := &flakyDeepSeekCaller{
+		errs:               []error{nil, errors.New("connection reset")},
+		fakeDeepSeekCaller: fakeDeepSeekCaller{responses: []*http.Response{first(), sseHTTPResponse(http.StatusOK, `data: {"response_message_id":8,"p":"response/content","v":"A good answer."}`)}},
+	}
+	result, outErr := ExecuteNonStreamWithRetry(context.Background(), flaky, &auth.RequestAuth{}, stdReq, Options{RetryEnabled: true, UpstreamRetryMaxAttempts: 2})
+	if outErr != nil || result.Turn.Text != "A good answer." || flaky.calls != 3 {
+		t.Fatalf("expected a transient failure to be retried, got %q %#v after %d calls", result.Turn.Text, outErr, flaky.calls)
+	}
+
+	down := &flakyDeepSeekCaller{
+		errs:               []error{nil, errors.New("connection reset")},
+		fakeDeepSeekCaller: fakeDeepSeekCaller{responses: []*http.Response{first()}},
+	}
+	result, outErr = ExecuteNonStreamWithRetry(context.Background(), down, &auth.RequestAuth{}, stdReq, Options{RetryEnabled: true, UpstreamRetryMaxAttempts: 1})
+	if outErr != nil || result.Turn.Text != "A good answer." {
+		t.Fatalf("expected the filtered text when the regeneration fails upstream, got %q %#v", result.Turn.Text, outErr)
+	}
+
+	refused := &flakyDeepSeekCaller{
+		errs:               []error{nil, hookErr},
+		fakeDeepSeekCaller: fakeDeepSeekCaller{responses: []*http.Response{first()}},
+	}
+	_, outErr = ExecuteNonStreamWithRetry(context.Background(), refused, &auth.RequestAuth{}, stdReq, Options{RetryEnabled: true, UpstreamRetryMaxAttempts: 2})
+	if outErr == nil || outErr.Code != "deployment_policy" || refused.calls != 2 {
+		t.Fatalf("expected the hook refusal to be reported, got %#v after %d calls", outErr, refused.calls)
+	}
+}
@@ -23,6 +23,12 @@ type StreamRetryOptions struct {
 	UsagePrompt      string
 	Request          promptcompat.StandardRequest
 	CurrentInputFile history.CurrentInputConfigReader
+	// UpstreamRetryMaxAttempts caps transient-failure retries when a fresh
+	// completion is started on an alternate account.
+	UpstreamRetryMaxAttempts int
+	TraceID                  string
+	// DisableAccountSwitch mirrors Options.DisableAccountSwitch.
+	DisableAccountSwitch bool
 }
 
 type StreamRetryHooks struct {
@@ -44,6 +50,10 @@ func ExecuteStreamWithRetry(ctx context.Context, ds DeepSeekCaller, a *auth.Requ
 	if surface == "" {
 		surface = "completion"
 	}
+	if opts.Request.Backend != "" {
+		// A request served by an API backend has no web session to retry in.
+		opts.RetryEnabled = false
+	}
 	maxAttempts := opts.MaxAttempts
 	if maxAttempts <= 0 {
 		maxAttempts = 3
@@ -58,7 +68,7 @@ func ExecuteStreamWithRetry(ctx context.Context, ds DeepSeekCaller, a *auth.Requ
 	currentResp := initialResp
 	currentPayload := clonePayload(payload)
 	for {
-		allowAccountSwitch := opts.RetryEnabled && attempts >= retryMax && !accountSwitchAttempted && a != nil && a.UseConfigToken
+		allowAccountSwitch := opts.RetryEnabled && !opts.DisableAccountSwitch && attempts >= retryMax && !accountSwitchAttempted && a != nil && a.UseConfigToken
 		terminalWritten, retryable := hooks.ConsumeAttempt(currentResp, opts.RetryEnabled && (attempts < retryMax || allowAccountSwitch))
 		if terminalWritten {
 			if hooks.OnTerminal != nil {
@@ -74,7 +84,7 @@ func ExecuteStreamWithRetry(ctx context.Context, ds DeepSeekCaller, a *auth.Requ
 		}
 
 		if attempts >= retryMax {
-			if canRetryOnAlternateAccount(ctx, a, &assistantturn.OutputError{Status: http.StatusTooManyRequests}, opts.RetryEnabled, &accountSwitchAttempted) {
+			if canRetryOnAlternateAccount(ctx, a, &assistantturn.OutputError{Status: http.StatusTooManyRequests}, opts.RetryEnabled && !opts.DisableAccountSwitch, &accountSwitchAttempted) {
 				switched, switchErr := startPayloadCompletionOnAlternateAccount(ctx, ds, a, payload, opts, maxAttempts)
 				if switchErr != nil {
 					if hooks.OnRetryFailure != nil {
@@ -83,7 +93,7 @@ func ExecuteStreamWithRetry(ctx context.Context, ds DeepSeekCaller, a *auth.Requ
 					return
 				}
 				if switched.Response != nil {
-					config.Logger.Info("[completion_runtime_account_switch_retry] retrying after 429", "surface", surface, "stream", opts.Stream, "account", a.AccountID)
+					config.Logger.InfoContext(ctx, "[completion_runtime_account_switch_retry] retrying after 429", "surface", surface, "stream", opts.Stream, "account", a.AccountID)
 					currentResp = switched.Response
 					currentPayload = switched.Payload
 					pow = switched.Pow
@@ -107,24 +117,28 @@ func ExecuteStreamWithRetry(ctx context.Context, ds DeepSeekCaller, a *auth.Requ
 		if hooks.ParentMessageID != nil {
 			parentMessageID = hooks.ParentMessageID()
 		}
-		config.Logger.Info("[completion_runtime_empty_retry] attempting synthetic retry", "surface", surface, "stream", opts.Stream, "retry_attempt", attempts, "parent_message_id", parentMessageID)
+		config.Logger.InfoContext(ctx, "[completion_runtime_empty_retry] attempting synthetic retry", "surface", surface, "stream", opts.Stream, "retry_attempt", attempts, "parent_message_id", parentMessageID)
 		retryPow, powErr := ds.GetPow(ctx, a, maxAttempts)
 		if powErr != nil {
-			config.Logger.Warn("[completion_runtime_empty_retry] retry PoW fetch failed, falling back to original PoW", "surface", surface, "stream", opts.Stream, "retry_attempt", attempts, "error", powErr)
+			config.Logger.WarnContext(ctx, "[completion_runtime_empty_retry] retry PoW fetch failed, falling back to original PoW", "surface", surface, "stream", opts.Stream, "retry_attempt", attempts, "error", powErr)
 			retryPow = pow
 		}
 		nextResp, err := ds.CallCompletion(ctx, a, shared.ClonePayloadForEmptyOutputRetry(currentPayload, parentMessageID), retryPow, maxAttempts)
 		if err != nil {
 			if hooks.OnRetryFailure != nil {
-				hooks.OnRetryFailure(http.StatusInternalServerError, "Failed to get completion.", "error")
+				if hookErr := hookOutputError(err); hookErr != nil {
+					hooks.OnRetryFailure(hookErr.Status, hookErr.Message, hookErr.Code)
+				} else {
+					hooks.OnRetryFailure(http.StatusInternalServerError, "Failed to get completion.", "error")
+				}
 			}
-			config.Logger.Warn("[completion_runtime_empty_retry] retry request failed", "surface", surface, "stream", opts.Stream, "retry_attempt", attempts, "error", err)
+			config.Logger.WarnContext(ctx, "[completion_runtime_empty_retry] retry request failed", "surface", surface, "stream", opts.Stream, "retry_attempt", attempts, "error", err)
 			return
 		}
 		if nextResp.StatusCode != http.StatusOK {
 			body, readErr := io.ReadAll(nextResp.Body)
 			if readErr != nil {
-				config.Logger.Warn("[completion_runtime_empty_retry] retry error body read failed", "surface", surface, "stream", opts.Stream, "retry_attempt", attempts, "error", readErr)
+				config.Logger.WarnContext(ctx, "[completion_runtime_empty_retry] retry error body read failed", "surface", surface, "stream", opts.Stream, "retry_attempt", attempts, "error", readErr)
 			}
 			closeRetryBody(surface, nextResp.Body)
 			msg := strings.TrimSpace(string(body))
@@ -153,7 +167,7 @@ func startPayloadCompletionOnAlternateAccount(ctx context.Context, ds DeepSeekCa
 	}
 	pow, err := ds.GetPow(ctx, a, maxAttempts)
 	if err != nil {
-		return StartResult{SessionID: sessionID}, &assistantturn.OutputError{Status: http.StatusUnauthorized, Message: "Failed to get PoW (invalid token or unknown error).", Code: "error"}
+		return StartResult{SessionID: sessionID}, powOutputError(err)
 	}
 	nextPayload := clonePayload(payload)
 	if opts.CurrentInputFile != nil && opts.Request.CurrentInputFileApplied {
@@ -165,9 +179,9 @@ func startPayloadCompletionOnAlternateAccount(ctx context.Context, ds DeepSeekCa
 	}
 	nextPayload["chat_session_id"] = sessionID
 	delete(nextPayload, "parent_message_id")
-	resp, err := ds.CallCompletion(ctx, a, nextPayload, pow, maxAttempts)
-	if err != nil {
-		return StartResult{SessionID: sessionID, Payload: nextPayload, Pow: pow}, &assistantturn.OutputError{Status: http.StatusInternalServerError, Message: "Failed to get completion.", Code: "error"}
+	resp, callErr := callCompletionWithUpstreamRetry(ctx, ds, a, nextPayload, pow, maxAttempts, Options{UpstreamRetryMaxAttempts: opts.UpstreamRetryMaxAttempts, TraceID: opts.TraceID}, opts.Surface)
+	if callErr != nil {
+		return StartResult{SessionID: sessionID, Payload: nextPayload, Pow: pow}, callErr
 	}
 	return StartResult{SessionID: sessionID, Payload: nextPayload, Pow: pow, Response: resp}, nil
 }
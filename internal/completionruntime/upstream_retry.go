@@ -0,0 +1,162 @@
+package completionruntime
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"time"
+
+	"ds2api/internal/assistantturn"
+	"ds2api/internal/auth"
+	"ds2api/internal/config"
+	dsclient "ds2api/internal/deepseek/client"
+	"ds2api/internal/upstreamhook"
+)
+
+const defaultUpstreamRetryMaxAttempts = 3
+
+// codeUpstreamBusy marks the local 429 from the upstream concurrency limiter;
+// switching accounts cannot help with it.
+const codeUpstreamBusy = "rate_limit_exceeded"
+
+var (
+	upstreamRetryBaseDelay = 250 * time.Millisecond
+	upstreamRetryMaxDelay  = 4 * time.Second
+)
+
+// callCompletionWithUpstreamRetry sends the completion request and retries
+// connection errors, 429 and 5xx responses with exponential backoff and
+// jitter. It only runs before a response body is handed to a consumer, so no
+// output can have reached the client yet; once a 200 response is returned the
+// caller owns the stream and no further upstream retries happen.
+//
+// When every attempt fails, the final upstream status is reported in the
+// OutputError; connection errors map to 502. A final 429 response is returned
+// unchanged instead so the existing account-switch handling still applies. A
+// full upstream concurrency queue is a local 429 and is reported at once, and
+// so is a call refused by an upstream hook.
+func callCompletionWithUpstreamRetry(ctx context.Context, ds DeepSeekCaller, a *auth.RequestAuth, payload map[string]any, pow string, maxAttempts int, opts Options, surface string) (*http.Response, *assistantturn.OutputError) {
+	retryMax := opts.UpstreamRetryMaxAttempts
+	if retryMax <= 0 {
+		retryMax = defaultUpstreamRetryMaxAttempts
+	}
+	traceID := traceIDFor(ctx, opts)
+	for attempt := 1; ; attempt++ {
+		resp, err := ds.CallCompletion(ctx, a, payload, pow, maxAttempts)
+		if err == nil && !isRetryableUpstreamStatus(resp.StatusCode) {
+			return resp, nil
+		}
+		if hookErr := hookOutputError(err); hookErr != nil {
+			config.Logger.WarnContext(ctx, "[completion_runtime_upstream_retry] upstream hook stopped the call", "trace_id", traceID, "surface", surface, "status", hookErr.Status, "error", err)
+			return nil, hookErr
+		}
+		if errors.Is(err, dsclient.ErrUpstreamBusy) {
+			config.Logger.WarnContext(ctx, "[completion_runtime_upstream_retry] upstream concurrency limit reached", "trace_id", traceID, "surface", surface)
+			return nil, &assistantturn.OutputError{Status: http.StatusTooManyRequests, Message: dsclient.ErrUpstreamBusy.Error(), Code: codeUpstreamBusy}
+		}
+		status := 0
+		if err == nil {
+			status = resp.StatusCode
+		}
+		if attempt >= retryMax || ctx.Err() != nil {
+			if cancelErr := contextOutputError(ctx, opts, surface); cancelErr != nil {
+				discardUpstreamResponse(resp, surface)
+				return nil, cancelErr
+			}
+			if err != nil {
+				config.Logger.WarnContext(ctx, "[completion_runtime_upstream_retry] giving up", "trace_id", traceID, "surface", surface, "attempt", attempt, "error", err)
+				return nil, &assistantturn.OutputError{Status: http.StatusBadGateway, Message: fmt.Sprintf("Failed to get completion: upstream request failed after %d attempt(s).", attempt), Code: "upstream_error"}
+			}
+			config.Logger.WarnContext(ctx, "[completion_runtime_upstream_retry] giving up", "trace_id", traceID, "surface", surface, "attempt", attempt, "status", status)
+			if status == http.StatusTooManyRequests {
+				return resp, nil
+			}
+			discardUpstreamResponse(resp, surface)
+			return nil, &assistantturn.OutputError{Status: status, Message: fmt.Sprintf("Failed to get completion: upstream returned status %d after %d attempt(s).", status, attempt), Code: "upstream_error"}
+		}
+		if err == nil {
+			discardUpstreamResponse(resp, surface)
+		}
+		delay := upstreamRetryDelay(attempt)
+		config.Logger.InfoContext(ctx, "[completion_runtime_upstream_retry] retrying transient upstream failure", "trace_id", traceID, "surface", surface, "attempt", attempt, "max_attempts", retryMax, "status", status, "error", err, "delay", delay)
+		if !sleepWithContext(ctx, delay) {
+			if cancelErr := contextOutputError(ctx, opts, surface); cancelErr != nil {
+				return nil, cancelErr
+			}
+			return nil, &assistantturn.OutputError{Status: http.StatusBadGateway, Message: fmt.Sprintf("Failed to get completion: request canceled while retrying upstream (last status %d).", status), Code: "upstream_error"}
+		}
+	}
+}
+
+// CodePowSolveFailed marks a PoW challenge that could not be solved. Unlike
+// other PoW failures it says nothing about the caller's token, so it is a
+// retryable 503 rather than a 401.
+const CodePowSolveFailed = "pow_solve_failed"
+
+func powOutputError(err error) *assistantturn.OutputError {
+	if dsclient.IsPowSolveError(err) {
+		return &assistantturn.OutputError{Status: http.StatusServiceUnavailable, Message: "Failed to solve the DeepSeek PoW challenge; retry the request.", Code: CodePowSolveFailed}
+	}
+	return &assistantturn.OutputError{Status: http.StatusUnauthorized, Message: "Failed to get PoW (invalid token or unknown error).", Code: "error"}
+}
+
+// hookOutputError maps an error returned by an upstream hook to the error
+// sent to the client, or returns nil for any other error.
+func hookOutputError(err error) *assistantturn.OutputError {
+	if !upstreamhook.IsHookError(err) {
+		return nil
+	}
+	hookErr := upstreamhook.AsError(err)
+	return &assistantturn.OutputError{Status: hookErr.Status, Message: hookErr.Message, Code: hookErr.Code}
+}
+
+// isUpstreamUnavailable reports an error from callCompletionWithUpstreamRetry
+// that means the upstream could not serve the call, as opposed to a hook
+// refusal or the request being cancelled.
+func isUpstreamUnavailable(outErr *assistantturn.OutputError) bool {
+	return outErr != nil && (outErr.Code == "upstream_error" || outErr.Code == codeUpstreamBusy)
+}
+
+func isRetryableUpstreamStatus(status int) bool {
+	return status == http.StatusTooManyRequests || status >= http.StatusInternalServerError
+}
+
+// upstreamRetryDelay returns an equal-jitter exponential backoff for the
+// given 1-based attempt number: a uniform delay in [ceiling/2, ceiling],
+// where the ceiling doubles per attempt up to upstreamRetryMaxDelay. Keeping
+// half the ceiling fixed guarantees a minimum wait between retries.
+func upstreamRetryDelay(attempt int) time.Duration {
+	ceiling := upstreamRetryBaseDelay << (attempt - 1)
+	if ceiling <= 0 || ceiling > upstreamRetryMaxDelay {
+		ceiling = upstreamRetryMaxDelay
+	}
+	half := ceiling / 2
+	return half + rand.N(half+1)
+}
+
+func sleepWithContext(ctx context.Context, d time.Duration) bool {
+	if d <= 0 {
+		return ctx.Err() == nil
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
+
+func discardUpstreamResponse(resp *http.Response, surface string) {
+	if resp == nil || resp.Body == nil {
+		return
+	}
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	if err := resp.Body.Close(); err != nil {
+		config.Logger.Warn("[completion_runtime_upstream_retry] response body close failed", "surface", surface, "error", err)
+	}
+}
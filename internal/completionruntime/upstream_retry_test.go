@@ -0,0 +1,180 @@
+package completionruntime
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"ds2api/internal/auth"
+	dsclient "ds2api/internal/deepseek/client"
+	"ds2api/internal/promptcompat"
+	"ds2api/internal/upstreamhook"
+)
+
+type flakyDeepSeekCaller struct {
+	fakeDeepSeekCaller
+	errs  []error
+	calls int
+}
+
+func (f *flakyDeepSeekCaller) CallCompletion(ctx context.Context, a *auth.RequestAuth, payload map[string]any, pow string, maxAttempts int) (*http.Response, error) {
+	f.calls++
+	if len(f.errs) > 0 {
+		err := f.errs[0]
+		f.errs = f.errs[1:]
+		if err != nil {
+			return nil, err
+		}
+	}
+	return f.fakeDeepSeekCaller.CallCompletion(ctx, a, payload, pow, maxAttempts)
+}
+
+func withFastUpstreamRetry(t *testing.T) {
+	t.Helper()
+	base, maxDelay := upstreamRetryBaseDelay, upstreamRetryMaxDelay
+	upstreamRetryBaseDelay, upstreamRetryMaxDelay = time.Millisecond, 2*time.Millisecond
+	t.Cleanup(func() {
+		upstreamRetryBaseDelay, upstreamRetryMaxDelay = base, maxDelay
+	})
+}
+
+func TestStartCompletionRetriesTransientUpstreamFailures(t *testing.T) {
+	withFastUpstreamRetry(t)
+	ds := &flakyDeepSeekCaller{
+		errs: []error{errors.New("connection reset"), nil, nil},
+		fakeDeepSeekCaller: fakeDeepSeekCaller{responses: []*http.Response{
+			sseHTTPResponse(http.StatusBadGateway, `upstream down`),
+			sseHTTPResponse(http.StatusOK, `data: {"p":"response/content","v":"ok"}`),
+		}},
+	}
+	start, outErr := StartCompletion(context.Background(), ds, &auth.RequestAuth{}, promptcompat.StandardRequest{Surface: "test"}, Options{UpstreamRetryMaxAttempts: 3})
+	if outErr != nil {
+		t.Fatalf("unexpected output error: %#v", outErr)
+	}
+	defer func() { _ = start.Response.Body.Close() }()
+	if start.Response.StatusCode != http.StatusOK {
+		t.Fatalf("expected final 200 response, got %d", start.Response.StatusCode)
+	}
+	if ds.calls != 3 {
+		t.Fatalf("expected 3 upstream calls, got %d", ds.calls)
+	}
+}
+
+func TestStartCompletionSurfacesFinalUpstreamStatusAfterRetries(t *testing.T) {
+	withFastUpstreamRetry(t)
+	ds := &flakyDeepSeekCaller{fakeDeepSeekCaller: fakeDeepSeekCaller{responses: []*http.Response{
+		sseHTTPResponse(http.StatusInternalServerError, `boom`),
+		sseHTTPResponse(http.StatusServiceUnavailable, `busy`),
+	}}}
+	_, outErr := StartCompletion(context.Background(), ds, &auth.RequestAuth{}, promptcompat.StandardRequest{Surface: "test"}, Options{UpstreamRetryMaxAttempts: 2})
+	if outErr == nil {
+		t.Fatal("expected output error after exhausting retries")
+	}
+	if outErr.Status != http.StatusServiceUnavailable || outErr.Code != "upstream_error" {
+		t.Fatalf("unexpected error status/code: %#v", outErr)
+	}
+	if !strings.Contains(outErr.Message, "status 503") || !strings.Contains(outErr.Message, "2 attempt(s)") {
+		t.Fatalf("expected final upstream status in message, got %q", outErr.Message)
+	}
+	if ds.calls != 2 {
+		t.Fatalf("expected retries capped at 2 calls, got %d", ds.calls)
+	}
+}
+
+func TestStartCompletionDoesNotRetryClientErrors(t *testing.T) {
+	withFastUpstreamRetry(t)
+	ds := &flakyDeepSeekCaller{fakeDeepSeekCaller: fakeDeepSeekCaller{responses: []*http.Response{
+		sseHTTPResponse(http.StatusBadRequest, `bad request`),
+	}}}
+	start, outErr := StartCompletion(context.Background(), ds, &auth.RequestAuth{}, promptcompat.StandardRequest{Surface: "test"}, Options{UpstreamRetryMaxAttempts: 3})
+	if outErr != nil {
+		t.Fatalf("unexpected output error: %#v", outErr)
+	}
+	defer func() { _ = start.Response.Body.Close() }()
+	if start.Response.StatusCode != http.StatusBadRequest || ds.calls != 1 {
+		t.Fatalf("expected single pass-through 400, got status=%d calls=%d", start.Response.StatusCode, ds.calls)
+	}
+}
+
+func TestStartCompletionMapsExhaustedConnectionErrorsToBadGateway(t *testing.T) {
+	withFastUpstreamRetry(t)
+	ds := &flakyDeepSeekCaller{errs: []error{errors.New("dial failed"), errors.New("dial failed")}}
+	_, outErr := StartCompletion(context.Background(), ds, &auth.RequestAuth{}, promptcompat.StandardRequest{Surface: "test"}, Options{UpstreamRetryMaxAttempts: 2})
+	if outErr == nil || outErr.Status != http.StatusBadGateway {
+		t.Fatalf("expected 502 output error, got %#v", outErr)
+	}
+}
+
+func TestStartCompletionRejectsBusyUpstreamWithoutRetry(t *testing.T) {
+	withFastUpstreamRetry(t)
+	ds := &flakyDeepSeekCaller{errs: []error{dsclient.ErrUpstreamBusy, nil}}
+	_, outErr := StartCompletion(context.Background(), ds, &auth.RequestAuth{UseConfigToken: true}, promptcompat.StandardRequest{Surface: "test"}, Options{UpstreamRetryMaxAttempts: 3, RetryEnabled: true})
+	if outErr == nil || outErr.Status != http.StatusTooManyRequests || outErr.Code != "rate_limit_exceeded" {
+		t.Fatalf("expected local 429 rate_limit_exceeded, got %#v", outErr)
+	}
+	if ds.calls != 1 {
+		t.Fatalf("expected no retry of a full upstream queue, got %d calls", ds.calls)
+	}
+}
+
+func TestUpstreamRetryDelayUsesEqualJitterWithinCeiling(t *testing.T) {
+	for _, attempt := range []int{1, 2, 3, 4, 5, 8, 64} {
+		ceiling := upstreamRetryMaxDelay
+		if attempt < 32 && upstreamRetryBaseDelay<<(attempt-1) < ceiling {
+			ceiling = upstreamRetryBaseDelay << (attempt - 1)
+		}
+		lower, upper := ceiling, time.Duration(0)
+		for i := 0; i < 500; i++ {
+			d := upstreamRetryDelay(attempt)
+			if d < ceiling/2 || d > ceiling {
+				t.Fatalf("attempt %d delay %v outside [%v, %v]", attempt, d, ceiling/2, ceiling)
+			}
+			lower, upper = min(lower, d), max(upper, d)
+		}
+		// 500 uniform samples land in the outer quarters of the range with
+		// overwhelming probability, so a fixed or one-sided delay fails here.
+		if lower > ceiling/2+ceiling/8 || upper < ceiling-ceiling/8 {
+			t.Fatalf("attempt %d delays spread over [%v, %v], want most of [%v, %v]", attempt, lower, upper, ceiling/2, ceiling)
+		}
+	}
+}
+
+func TestPowOutputErrorSeparatesSolveFailures(t *testing.T) {
+	solve := powOutputError(fmt.Errorf("get pow: %w", &dsclient.RequestFailure{Op: "get pow", Kind: dsclient.FailurePowSolve, Message: "bad challenge"}))
+	if solve.Status != http.StatusServiceUnavailable || solve.Code != CodePowSolveFailed {
+		t.Fatalf("expected 503 pow_solve_failed, got %#v", solve)
+	}
+	other := powOutputError(errors.New("token expired"))
+	if other.Status != http.StatusUnauthorized {
+		t.Fatalf("expected other PoW errors to keep 401, got %#v", other)
+	}
+}
+
+type refusingHook struct {
+	upstreamhook.Nop
+}
+
+func (refusingHook) BeforeUpstream(context.Context, *upstreamhook.Request) error {
+	return &upstreamhook.Error{Status: http.StatusForbidden, Code: "deployment_policy", Message: "prompt refused by deployment policy"}
+}
+
+func TestStartCompletionReportsUpstreamHookErrorWithoutRetrying(t *testing.T) {
+	withFastUpstreamRetry(t)
+	t.Cleanup(upstreamhook.Register(refusingHook{}))
+	hookErr := upstreamhook.RunBefore(context.Background(), &upstreamhook.Request{})
+	ds := &flakyDeepSeekCaller{errs: []error{hookErr}}
+	_, outErr := StartCompletion(context.Background(), ds, &auth.RequestAuth{}, promptcompat.StandardRequest{Surface: "test", FallbackModels: []string{"deepseek-v4-flash"}}, Options{UpstreamRetryMaxAttempts: 3})
+	if outErr == nil {
+		t.Fatal("expected the hook error to stop the call")
+	}
+	if outErr.Status != http.StatusForbidden || outErr.Code != "deployment_policy" || outErr.Message != "prompt refused by deployment policy" {
+		t.Fatalf("unexpected output error: %#v", outErr)
+	}
+	if ds.calls != 1 {
+		t.Fatalf("expected a single upstream call, got %d", ds.calls)
+	}
+}
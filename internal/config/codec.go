@@ -29,10 +29,13 @@ func (c Config) MarshalJSON() ([]byte, error) {
 	if len(c.ModelAliases) > 0 {
 		m["model_aliases"] = c.ModelAliases
 	}
+	if strings.TrimSpace(c.ModelRouting.DefaultModel) != "" || strings.TrimSpace(c.ModelRouting.ResponseModel) != "" || len(c.ModelRouting.Fallbacks) > 0 || len(c.ModelRouting.Backends) > 0 {
+		m["model_routing"] = c.ModelRouting
+	}
 	if strings.TrimSpace(c.Admin.PasswordHash) != "" || c.Admin.JWTExpireHours > 0 || c.Admin.JWTValidAfterUnix > 0 {
 		m["admin"] = c.Admin
 	}
-	if c.Runtime.AccountMaxInflight > 0 || c.Runtime.AccountMaxQueue > 0 || c.Runtime.GlobalMaxInflight > 0 || c.Runtime.TokenRefreshIntervalHours > 0 {
+	if c.Runtime.AccountMaxInflight > 0 || c.Runtime.AccountMaxQueue > 0 || c.Runtime.GlobalMaxInflight > 0 || c.Runtime.TokenRefreshIntervalHours > 0 || c.Runtime.UpstreamRetryMaxAttempts > 0 || c.Runtime.RequestTimeoutSeconds > 0 || c.Runtime.MaxCompletionChoices > 0 || c.Runtime.ReadinessCacheSeconds > 0 || c.Runtime.MaxRequestBodyMB > 0 || c.Runtime.ShutdownGraceSeconds > 0 || c.Runtime.UpstreamMaxInflight > 0 || c.Runtime.UpstreamMaxQueue > 0 || c.Runtime.ContextMaxTokens > 0 || c.Runtime.ContextTrimStrategy != "" || c.Runtime.RequireAPIKey != nil || c.Runtime.StrictSamplingParams != nil || c.Runtime.RemoteImageFetch != nil || c.Runtime.MergeConsecutiveMessages != nil {
 		m["runtime"] = c.Runtime
 	}
 	if c.Responses.StoreTTLSeconds > 0 {
@@ -48,6 +51,24 @@ func (c Config) MarshalJSON() ([]byte, error) {
 	if c.ThinkingInjection.Enabled != nil || strings.TrimSpace(c.ThinkingInjection.Prompt) != "" {
 		m["thinking_injection"] = c.ThinkingInjection
 	}
+	if c.RequestLog.Enabled != nil || strings.TrimSpace(c.RequestLog.Redaction) != "" || c.RequestLog.BodySampleRate != 0 {
+		m["request_log"] = c.RequestLog
+	}
+	if c.Idempotency.Enabled != nil || c.Idempotency.HashBody != nil || c.Idempotency.TTLSeconds != 0 || c.Idempotency.MaxEntries != 0 {
+		m["idempotency"] = c.Idempotency
+	}
+	if strings.TrimSpace(c.ToolPrompt.TemplateFile) != "" || strings.TrimSpace(c.ToolPrompt.Format) != "" {
+		m["tool_prompt"] = c.ToolPrompt
+	}
+	if c.RateLimit.Enabled != nil || c.RateLimit.RequestsPerMinute != 0 || c.RateLimit.TokensPerMinute != 0 || len(c.RateLimit.Users) > 0 {
+		m["rate_limit"] = c.RateLimit
+	}
+	if len(c.CORS.AllowedOrigins) > 0 || len(c.CORS.AllowedHeaders) > 0 || len(c.CORS.AllowedMethods) > 0 {
+		m["cors"] = c.CORS
+	}
+	if c.Batch.MaxWorkers != 0 || c.Batch.MaxRequests != 0 || c.Batch.MaxBatches != 0 || c.Batch.TTLSeconds != 0 {
+		m["batch"] = c.Batch
+	}
 	if strings.TrimSpace(c.Vercel.Token) != "" || strings.TrimSpace(c.Vercel.ProjectID) != "" || strings.TrimSpace(c.Vercel.TeamID) != "" {
 		m["vercel"] = NormalizeVercelConfig(c.Vercel)
 	}
@@ -91,6 +112,10 @@ func (c *Config) UnmarshalJSON(b []byte) error {
 			if err := json.Unmarshal(v, &c.ModelAliases); err != nil {
 				return fmt.Errorf("invalid field %q: %w", k, err)
 			}
+		case "model_routing":
+			if err := json.Unmarshal(v, &c.ModelRouting); err != nil {
+				return fmt.Errorf("invalid field %q: %w", k, err)
+			}
 		case "admin":
 			if err := json.Unmarshal(v, &c.Admin); err != nil {
 				return fmt.Errorf("invalid field %q: %w", k, err)
@@ -128,6 +153,30 @@ func (c *Config) UnmarshalJSON(b []byte) error {
 			if err := json.Unmarshal(v, &c.ThinkingInjection); err != nil {
 				return fmt.Errorf("invalid field %q: %w", k, err)
 			}
+		case "request_log":
+			if err := json.Unmarshal(v, &c.RequestLog); err != nil {
+				return fmt.Errorf("invalid field %q: %w", k, err)
+			}
+		case "idempotency":
+			if err := json.Unmarshal(v, &c.Idempotency); err != nil {
+				return fmt.Errorf("invalid field %q: %w", k, err)
+			}
+		case "tool_prompt":
+			if err := json.Unmarshal(v, &c.ToolPrompt); err != nil {
+				return fmt.Errorf("invalid field %q: %w", k, err)
+			}
+		case "rate_limit":
+			if err := json.Unmarshal(v, &c.RateLimit); err != nil {
+				return fmt.Errorf("invalid field %q: %w", k, err)
+			}
+		case "cors":
+			if err := json.Unmarshal(v, &c.CORS); err != nil {
+				return fmt.Errorf("invalid field %q: %w", k, err)
+			}
+		case "batch":
+			if err := json.Unmarshal(v, &c.Batch); err != nil {
+				return fmt.Errorf("invalid field %q: %w", k, err)
+			}
 		case "vercel":
 			if err := json.Unmarshal(v, &c.Vercel); err != nil {
 				return fmt.Errorf("invalid field %q: %w", k, err)
@@ -154,10 +203,11 @@ func (c *Config) UnmarshalJSON(b []byte) error {
 func (c Config) Clone() Config {
 	clone := Config{
 		Keys:         slices.Clone(c.Keys),
-		APIKeys:      slices.Clone(c.APIKeys),
+		APIKeys:      cloneAPIKeys(c.APIKeys),
 		Accounts:     slices.Clone(c.Accounts),
 		Proxies:      slices.Clone(c.Proxies),
 		ModelAliases: cloneStringMap(c.ModelAliases),
+		ModelRouting: c.ModelRouting,
 		Admin:        c.Admin,
 		Runtime:      c.Runtime,
 		Responses:    c.Responses,
@@ -171,17 +221,70 @@ func (c Config) Clone() Config {
 			Enabled: cloneBoolPtr(c.ThinkingInjection.Enabled),
 			Prompt:  c.ThinkingInjection.Prompt,
 		},
+		RequestLog: RequestLogConfig{
+			Enabled:        cloneBoolPtr(c.RequestLog.Enabled),
+			Redaction:      c.RequestLog.Redaction,
+			BodySampleRate: c.RequestLog.BodySampleRate,
+		},
+		Idempotency: IdempotencyConfig{
+			Enabled:    cloneBoolPtr(c.Idempotency.Enabled),
+			HashBody:   cloneBoolPtr(c.Idempotency.HashBody),
+			TTLSeconds: c.Idempotency.TTLSeconds,
+			MaxEntries: c.Idempotency.MaxEntries,
+		},
+		ToolPrompt: c.ToolPrompt,
+		RateLimit: RateLimitConfig{
+			Enabled:           cloneBoolPtr(c.RateLimit.Enabled),
+			RequestsPerMinute: c.RateLimit.RequestsPerMinute,
+			TokensPerMinute:   c.RateLimit.TokensPerMinute,
+		},
+		CORS: CORSConfig{
+			AllowedOrigins: slices.Clone(c.CORS.AllowedOrigins),
+			AllowedHeaders: slices.Clone(c.CORS.AllowedHeaders),
+			AllowedMethods: slices.Clone(c.CORS.AllowedMethods),
+		},
+		Batch:            c.Batch,
 		Vercel:           c.Vercel,
 		VercelSyncHash:   c.VercelSyncHash,
 		VercelSyncTime:   c.VercelSyncTime,
 		AdditionalFields: map[string]any{},
 	}
+	clone.Runtime.RequireAPIKey = cloneBoolPtr(c.Runtime.RequireAPIKey)
+	clone.Runtime.StrictSamplingParams = cloneBoolPtr(c.Runtime.StrictSamplingParams)
+	clone.Runtime.RemoteImageFetch = cloneBoolPtr(c.Runtime.RemoteImageFetch)
+	clone.Runtime.MergeConsecutiveMessages = cloneBoolPtr(c.Runtime.MergeConsecutiveMessages)
+	if len(c.ModelRouting.Fallbacks) > 0 {
+		clone.ModelRouting.Fallbacks = make(map[string][]string, len(c.ModelRouting.Fallbacks))
+		for model, chain := range c.ModelRouting.Fallbacks {
+			clone.ModelRouting.Fallbacks[model] = slices.Clone(chain)
+		}
+	}
+	if len(c.ModelRouting.Backends) > 0 {
+		clone.ModelRouting.Backends = make(map[string]APIBackendConfig, len(c.ModelRouting.Backends))
+		for name, backend := range c.ModelRouting.Backends {
+			clone.ModelRouting.Backends[name] = backend
+		}
+	}
+	if len(c.RateLimit.Users) > 0 {
+		clone.RateLimit.Users = make(map[string]RateLimitRule, len(c.RateLimit.Users))
+		for user, rule := range c.RateLimit.Users {
+			clone.RateLimit.Users[user] = rule
+		}
+	}
 	for k, v := range c.AdditionalFields {
 		clone.AdditionalFields[k] = v
 	}
 	return clone
 }
 
+func cloneAPIKeys(in []APIKey) []APIKey {
+	out := slices.Clone(in)
+	for i := range out {
+		out[i].Models = slices.Clone(out[i].Models)
+	}
+	return out
+}
+
 func cloneStringMap(in map[string]string) map[string]string {
 	if len(in) == 0 {
 		return nil
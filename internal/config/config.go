@@ -13,6 +13,7 @@ type Config struct {
 	Accounts          []Account               `json:"accounts,omitempty"`
 	Proxies           []Proxy                 `json:"proxies,omitempty"`
 	ModelAliases      map[string]string       `json:"model_aliases,omitempty"`
+	ModelRouting      ModelRoutingConfig      `json:"model_routing,omitempty"`
 	Admin             AdminConfig             `json:"admin,omitempty"`
 	Runtime           RuntimeConfig           `json:"runtime,omitempty"`
 	Responses         ResponsesConfig         `json:"responses,omitempty"`
@@ -20,6 +21,12 @@ type Config struct {
 	AutoDelete        AutoDeleteConfig        `json:"auto_delete"`
 	CurrentInputFile  CurrentInputFileConfig  `json:"current_input_file,omitempty"`
 	ThinkingInjection ThinkingInjectionConfig `json:"thinking_injection,omitempty"`
+	RequestLog        RequestLogConfig        `json:"request_log,omitempty"`
+	Idempotency       IdempotencyConfig       `json:"idempotency,omitempty"`
+	ToolPrompt        ToolPromptConfig        `json:"tool_prompt,omitempty"`
+	RateLimit         RateLimitConfig         `json:"rate_limit,omitempty"`
+	CORS              CORSConfig              `json:"cors,omitempty"`
+	Batch             BatchConfig             `json:"batch,omitempty"`
 	Vercel            VercelConfig            `json:"vercel,omitempty"`
 	VercelSyncHash    string                  `json:"_vercel_sync_hash,omitempty"`
 	VercelSyncTime    int64                   `json:"_vercel_sync_time,omitempty"`
@@ -40,6 +47,10 @@ type APIKey struct {
 	Key    string `json:"key"`
 	Name   string `json:"name,omitempty"`
 	Remark string `json:"remark,omitempty"`
+	// Models optionally restricts the key to these model names; an entry
+	// matches either the requested name (alias) or the resolved DeepSeek
+	// model. Empty allows every model.
+	Models []string `json:"models,omitempty"`
 }
 
 type Proxy struct {
@@ -142,6 +153,72 @@ func (c *Config) normalizeModelAliases() {
 	}
 }
 
+// ModelRoutingConfig controls what happens around model_aliases: the target
+// for names that are neither a DeepSeek model nor an alias, and which name is
+// echoed back in the response `model` field.
+type ModelRoutingConfig struct {
+	DefaultModel  string `json:"default_model,omitempty"`
+	ResponseModel string `json:"response_model,omitempty"`
+	// Fallbacks maps a requested model name (alias or DeepSeek model) to the
+	// ordered targets tried when the primary upstream call fails. An entry is
+	// a DeepSeek model or alias on the web backend, or "backend:<name>" for
+	// one of Backends.
+	Fallbacks map[string][]string `json:"fallbacks,omitempty"`
+	// Backends are API backends a fallback chain can switch to, keyed by the
+	// name used in "backend:<name>" entries.
+	Backends map[string]APIBackendConfig `json:"backends,omitempty"`
+}
+
+// APIBackendConfig is an OpenAI-compatible chat completions endpoint, such as
+// the official DeepSeek API, called as POST {base_url}/chat/completions.
+// ThinkingModel, when set, serves requests with thinking enabled; Model
+// serves the rest.
+type APIBackendConfig struct {
+	BaseURL       string `json:"base_url"`
+	APIKey        string `json:"api_key,omitempty"`
+	Model         string `json:"model"`
+	ThinkingModel string `json:"thinking_model,omitempty"`
+}
+
+// ModelFor returns the backend model serving a request with or without
+// thinking.
+func (b APIBackendConfig) ModelFor(thinking bool) string {
+	if thinking && b.ThinkingModel != "" {
+		return b.ThinkingModel
+	}
+	return b.Model
+}
+
+// FallbackBackendPrefix marks a model_routing.fallbacks entry that names one
+// of model_routing.backends instead of a DeepSeek model.
+const FallbackBackendPrefix = "backend:"
+
+// FallbackBackendName returns the backend name of a "backend:<name>" fallback
+// entry.
+func FallbackBackendName(entry string) (string, bool) {
+	entry = strings.TrimSpace(entry)
+	if len(entry) < len(FallbackBackendPrefix) || !strings.EqualFold(entry[:len(FallbackBackendPrefix)], FallbackBackendPrefix) {
+		return "", false
+	}
+	name := strings.TrimSpace(entry[len(FallbackBackendPrefix):])
+	return name, name != ""
+}
+
+const (
+	// ModelResponseRequested echoes the model name the client sent.
+	ModelResponseRequested = "requested"
+	// ModelResponseResolved echoes the DeepSeek model the request was routed to.
+	ModelResponseResolved = "resolved"
+)
+
+// Strategies for runtime.context_trim_strategy.
+const (
+	// ContextTrimDropOldest removes the oldest messages outright.
+	ContextTrimDropOldest = "drop_oldest"
+	// ContextTrimSummarizeOldest replaces them with a short extract.
+	ContextTrimSummarizeOldest = "summarize_oldest"
+)
+
 type AdminConfig struct {
 	PasswordHash      string `json:"password_hash,omitempty"`
 	JWTExpireHours    int    `json:"jwt_expire_hours,omitempty"`
@@ -153,6 +230,41 @@ type RuntimeConfig struct {
 	AccountMaxQueue           int `json:"account_max_queue,omitempty"`
 	GlobalMaxInflight         int `json:"global_max_inflight,omitempty"`
 	TokenRefreshIntervalHours int `json:"token_refresh_interval_hours,omitempty"`
+	UpstreamRetryMaxAttempts  int `json:"upstream_retry_max_attempts,omitempty"`
+	RequestTimeoutSeconds     int `json:"request_timeout_seconds,omitempty"`
+	MaxCompletionChoices      int `json:"max_completion_choices,omitempty"`
+	// ReadinessCacheSeconds is how long a /readyz upstream probe result is
+	// reused before the next probe.
+	ReadinessCacheSeconds int `json:"readiness_cache_seconds,omitempty"`
+	// MaxRequestBodyMB caps every non-multipart request body; larger bodies
+	// are rejected with 413 before they are buffered or decoded.
+	MaxRequestBodyMB int `json:"max_request_body_mb,omitempty"`
+	// ShutdownGraceSeconds is how long in-flight requests may keep running
+	// after a shutdown signal before they are cancelled.
+	ShutdownGraceSeconds int `json:"shutdown_grace_seconds,omitempty"`
+	// UpstreamMaxInflight caps concurrent DeepSeek completion streams across
+	// all accounts; zero means unlimited. UpstreamMaxQueue bounds how many
+	// more requests may wait for a slot before getting a 429.
+	UpstreamMaxInflight int `json:"upstream_max_inflight,omitempty"`
+	UpstreamMaxQueue    int `json:"upstream_max_queue,omitempty"`
+	// ContextMaxTokens is the prompt token budget; longer conversations lose
+	// their oldest messages according to ContextTrimStrategy. Zero disables
+	// trimming.
+	ContextMaxTokens    int    `json:"context_max_tokens,omitempty"`
+	ContextTrimStrategy string `json:"context_trim_strategy,omitempty"`
+	// RequireAPIKey rejects callers whose token is not a configured key
+	// instead of forwarding it upstream as a direct DeepSeek token.
+	RequireAPIKey *bool `json:"require_api_key,omitempty"`
+	// StrictSamplingParams rejects out-of-range temperature, top_p and
+	// penalty values with a 400 instead of clamping them.
+	StrictSamplingParams *bool `json:"strict_sampling_params,omitempty"`
+	// RemoteImageFetch lets image_url parts name remote http(s) URLs that
+	// the server downloads. Addresses that resolve to loopback, private,
+	// link-local, multicast or unspecified IPs are always refused.
+	RemoteImageFetch *bool `json:"remote_image_fetch,omitempty"`
+	// MergeConsecutiveMessages folds adjacent messages from the same role
+	// into one prompt block. Tool results always stay separate.
+	MergeConsecutiveMessages *bool `json:"merge_consecutive_messages,omitempty"`
 }
 
 type ResponsesConfig struct {
@@ -161,8 +273,26 @@ type ResponsesConfig struct {
 
 type EmbeddingsConfig struct {
 	Provider string `json:"provider,omitempty"`
+	// BaseURL, APIKey and Model configure the "openai" provider, which calls
+	// an OpenAI-compatible POST {base_url}/embeddings. An empty Model forwards
+	// the client's model name.
+	BaseURL string `json:"base_url,omitempty"`
+	APIKey  string `json:"api_key,omitempty"`
+	Model   string `json:"model,omitempty"`
+	// MaxInputs and MaxTokens cap one request's batch size and estimated
+	// input tokens; zero uses the defaults.
+	MaxInputs int `json:"max_inputs,omitempty"`
+	MaxTokens int `json:"max_tokens,omitempty"`
 }
 
+const (
+	EmbeddingsProviderOpenAI      = "openai"
+	DefaultEmbeddingsMaxInputs    = 2048
+	DefaultEmbeddingsMaxTokens    = 300000
+	embeddingsMaxInputsUpperBound = 100000
+	embeddingsMaxTokensUpperBound = 10000000
+)
+
 type AutoDeleteConfig struct {
 	Mode     string `json:"mode,omitempty"`
 	Sessions bool   `json:"sessions,omitempty"`
@@ -178,6 +308,100 @@ type ThinkingInjectionConfig struct {
 	Prompt  string `json:"prompt,omitempty"`
 }
 
+// RequestLogConfig controls the per-request log lines written by the
+// request log middleware. Enabled and BodySampleRate fall back to their
+// environment variables when unset.
+type RequestLogConfig struct {
+	Enabled *bool `json:"enabled,omitempty"`
+	// Redaction is how message content appears in logged bodies: "hash"
+	// (default), "omit" or "none" for verbatim bodies.
+	Redaction string `json:"redaction,omitempty"`
+	// BodySampleRate is the fraction (0-1) of successful requests that log
+	// full bodies. Failed requests always do.
+	BodySampleRate float64 `json:"body_sample_rate,omitempty"`
+}
+
+const (
+	RequestLogRedactionHash = "hash"
+	RequestLogRedactionOmit = "omit"
+	RequestLogRedactionNone = "none"
+)
+
+// IdempotencyConfig controls replay of duplicate API requests. Requests
+// carrying an Idempotency-Key header are deduplicated; with HashBody set,
+// requests without one are keyed by their body instead. Unset fields fall
+// back to their environment variables.
+type IdempotencyConfig struct {
+	Enabled  *bool `json:"enabled,omitempty"`
+	HashBody *bool `json:"hash_body,omitempty"`
+	// TTLSeconds is how long a completed response stays replayable.
+	TTLSeconds int `json:"ttl_seconds,omitempty"`
+	// MaxEntries bounds the in-memory store; the oldest keys go first.
+	MaxEntries int `json:"max_entries,omitempty"`
+}
+
+// ToolPromptConfig selects how tools are presented to the model and how its
+// tool calls are parsed. Both are read once at startup; unset fields fall
+// back to their environment variables.
+type ToolPromptConfig struct {
+	// TemplateFile is a Go text/template that replaces the built-in tool
+	// prompt. It is validated at startup and a bad template aborts it.
+	TemplateFile string `json:"template_file,omitempty"`
+	// Format is the tool call syntax the parser expects: dsml or json.
+	Format string `json:"format,omitempty"`
+}
+
+// Formats for tool_prompt.format.
+const (
+	ToolCallFormatDSML = "dsml"
+	ToolCallFormatJSON = "json"
+)
+
+// RateLimitConfig caps requests and tokens per minute for each caller. Every
+// request counts against its API key, else its IP address; a request `user`
+// field (Claude's metadata.user_id) also counts against that user's bucket
+// under the key. Unset fields fall back to their environment
+// variables; a zero limit leaves that dimension unlimited.
+type RateLimitConfig struct {
+	Enabled           *bool `json:"enabled,omitempty"`
+	RequestsPerMinute int   `json:"requests_per_minute,omitempty"`
+	TokensPerMinute   int   `json:"tokens_per_minute,omitempty"`
+	// Users overrides the default limits of specific users' buckets; a zero
+	// field inherits the default. The key's bucket still applies.
+	Users map[string]RateLimitRule `json:"users,omitempty"`
+}
+
+type RateLimitRule struct {
+	RequestsPerMinute int `json:"requests_per_minute,omitempty"`
+	TokensPerMinute   int `json:"tokens_per_minute,omitempty"`
+}
+
+// CORSConfig controls cross-origin access for browser clients. With no
+// allowed origins no CORS headers are sent, so browsers block cross-origin
+// calls. AllowedOrigins entries are exact origins, "*" for any origin, or a
+// single-wildcard pattern such as "https://*.example.com". Empty header and
+// method lists keep the built-in defaults.
+type CORSConfig struct {
+	AllowedOrigins []string `json:"allowed_origins,omitempty"`
+	AllowedHeaders []string `json:"allowed_headers,omitempty"`
+	AllowedMethods []string `json:"allowed_methods,omitempty"`
+}
+
+// BatchConfig bounds the /v1/batches queue. Unset fields fall back to their
+// environment variables.
+type BatchConfig struct {
+	// MaxWorkers caps how many batch requests run at once across all
+	// batches, leaving the rest of the account pool to interactive traffic.
+	MaxWorkers int `json:"max_workers,omitempty"`
+	// MaxRequests caps the requests accepted in one batch.
+	MaxRequests int `json:"max_requests,omitempty"`
+	// MaxBatches bounds the in-memory store; the oldest finished batches go
+	// first.
+	MaxBatches int `json:"max_batches,omitempty"`
+	// TTLSeconds is how long a finished batch and its results are kept.
+	TTLSeconds int `json:"ttl_seconds,omitempty"`
+}
+
 type VercelConfig struct {
 	Token     string `json:"token,omitempty"`
 	ProjectID string `json:"project_id,omitempty"`
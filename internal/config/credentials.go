@@ -67,6 +67,7 @@ func normalizeAPIKeys(items []APIKey) []APIKey {
 			Key:    key,
 			Name:   strings.TrimSpace(item.Name),
 			Remark: strings.TrimSpace(item.Remark),
+			Models: NormalizeAPIKeyModels(item.Models),
 		})
 	}
 	if len(out) == 0 {
@@ -95,6 +96,7 @@ func apiKeysFromStrings(keys []string, meta map[string]APIKey) []APIKey {
 				Key:    key,
 				Name:   strings.TrimSpace(item.Name),
 				Remark: strings.TrimSpace(item.Remark),
+				Models: NormalizeAPIKeyModels(item.Models),
 			})
 			continue
 		}
@@ -141,6 +143,7 @@ func apiKeyMap(items []APIKey) map[string]APIKey {
 			Key:    key,
 			Name:   strings.TrimSpace(item.Name),
 			Remark: strings.TrimSpace(item.Remark),
+			Models: NormalizeAPIKeyModels(item.Models),
 		}
 	}
 	return out
@@ -153,6 +156,32 @@ func equalAPIKeys(a, b []APIKey) bool {
 	return slices.EqualFunc(a, b, func(x, y APIKey) bool {
 		return strings.TrimSpace(x.Key) == strings.TrimSpace(y.Key) &&
 			strings.TrimSpace(x.Name) == strings.TrimSpace(y.Name) &&
-			strings.TrimSpace(x.Remark) == strings.TrimSpace(y.Remark)
+			strings.TrimSpace(x.Remark) == strings.TrimSpace(y.Remark) &&
+			slices.Equal(NormalizeAPIKeyModels(x.Models), NormalizeAPIKeyModels(y.Models))
 	})
 }
+
+// NormalizeAPIKeyModels lowercases, trims and dedupes a key's model
+// allowlist, returning nil when nothing remains.
+func NormalizeAPIKeyModels(models []string) []string {
+	if len(models) == 0 {
+		return nil
+	}
+	out := make([]string, 0, len(models))
+	seen := make(map[string]struct{}, len(models))
+	for _, model := range models {
+		model = lower(strings.TrimSpace(model))
+		if model == "" {
+			continue
+		}
+		if _, ok := seen[model]; ok {
+			continue
+		}
+		seen[model] = struct{}{}
+		out = append(out, model)
+	}
+	if len(out) == 0 {
+		return nil
+	}
+	return out
+}
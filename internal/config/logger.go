@@ -1,9 +1,12 @@
 package config
 
 import (
+	"context"
 	"log/slog"
 	"os"
 	"strings"
+
+	"github.com/go-chi/chi/v5/middleware"
 )
 
 var Logger = newLogger()
@@ -21,9 +24,40 @@ func newLogger() *slog.Logger {
 		level.Set(slog.LevelInfo)
 	}
 	h := slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: level})
-	return slog.New(h)
+	return slog.New(traceHandler{h})
 }
 
 func RefreshLogger() {
 	Logger = newLogger()
 }
+
+// traceHandler adds the request's trace_id to records logged with a request
+// context (Logger.InfoContext and friends) that do not already carry one.
+type traceHandler struct {
+	slog.Handler
+}
+
+func (h traceHandler) Handle(ctx context.Context, r slog.Record) error {
+	if id := middleware.GetReqID(ctx); id != "" && !hasTraceAttr(r) {
+		r = r.Clone()
+		r.AddAttrs(slog.String("trace_id", id))
+	}
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h traceHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return traceHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h traceHandler) WithGroup(name string) slog.Handler {
+	return traceHandler{h.Handler.WithGroup(name)}
+}
+
+func hasTraceAttr(r slog.Record) bool {
+	found := false
+	r.Attrs(func(a slog.Attr) bool {
+		found = a.Key == "trace_id"
+		return !found
+	})
+	return found
+}
@@ -0,0 +1,34 @@
+package config
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+func TestTraceHandlerAddsTraceIDFromContext(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(traceHandler{slog.NewTextHandler(&buf, nil)})
+	ctx := context.WithValue(context.Background(), middleware.RequestIDKey, "req-1")
+
+	logger.InfoContext(ctx, "with context")
+	logger.InfoContext(ctx, "explicit", "trace_id", "req-2")
+	logger.Info("without context")
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("expected three lines, got %q", buf.String())
+	}
+	if !strings.Contains(lines[0], "trace_id=req-1") {
+		t.Fatalf("expected the context trace_id, got %q", lines[0])
+	}
+	if strings.Count(lines[1], "trace_id=") != 1 || !strings.Contains(lines[1], "trace_id=req-2") {
+		t.Fatalf("expected the explicit trace_id only, got %q", lines[1])
+	}
+	if strings.Contains(lines[2], "trace_id=") {
+		t.Fatalf("expected no trace_id without a context, got %q", lines[2])
+	}
+}
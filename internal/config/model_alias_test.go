@@ -156,3 +156,125 @@ func TestClaudeModelsResponsePaginationFields(t *testing.T) {
 		t.Fatalf("expected has_more in response: %#v", resp)
 	}
 }
+
+type mockModelRouting struct {
+	mockModelAliasReader
+	defaultModel string
+	responseMode string
+}
+
+func (m mockModelRouting) ModelDefaultTarget() string { return m.defaultModel }
+func (m mockModelRouting) ModelResponseMode() string  { return m.responseMode }
+
+func TestResolveRequestModelFallsBackToDefaultTarget(t *testing.T) {
+	store := mockModelRouting{mockModelAliasReader: mockModelAliasReader{"my-alias": "deepseek-v4-pro"}, defaultModel: "my-alias"}
+	got, ok := ResolveRequestModel(store, "totally-unknown-model")
+	if !ok || got != "deepseek-v4-pro" {
+		t.Fatalf("expected unknown model routed to default alias target, got ok=%v model=%q", ok, got)
+	}
+	got, ok = ResolveRequestModel(store, "gpt-4o")
+	if !ok || got != "deepseek-v4-flash" {
+		t.Fatalf("expected mapped alias to win over the default, got ok=%v model=%q", ok, got)
+	}
+}
+
+func TestResolveRequestModelWithoutDefaultRejectsUnknown(t *testing.T) {
+	if got, ok := ResolveRequestModel(mockModelRouting{}, "totally-unknown-model"); ok {
+		t.Fatalf("expected unknown model rejected without a default, got %q", got)
+	}
+}
+
+func TestResponseModelNameHonorsMode(t *testing.T) {
+	if got := ResponseModelName(mockModelRouting{}, "gpt-4o", "deepseek-v4-flash"); got != "gpt-4o" {
+		t.Fatalf("expected requested name by default, got %q", got)
+	}
+	if got := ResponseModelName(mockModelRouting{responseMode: ModelResponseResolved}, "gpt-4o", "deepseek-v4-flash"); got != "deepseek-v4-flash" {
+		t.Fatalf("expected resolved name, got %q", got)
+	}
+}
+
+func TestValidateModelRoutingConfig(t *testing.T) {
+	if err := ValidateModelRoutingConfig(ModelRoutingConfig{DefaultModel: "custom", ResponseModel: "resolved"}, map[string]string{"custom": "deepseek-v4-flash"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := ValidateModelRoutingConfig(ModelRoutingConfig{DefaultModel: "nope"}, nil); err == nil {
+		t.Fatal("expected error for unresolvable default_model")
+	}
+	if err := ValidateModelRoutingConfig(ModelRoutingConfig{ResponseModel: "alias"}, nil); err == nil {
+		t.Fatal("expected error for unknown response_model")
+	}
+	if err := ValidateModelRoutingConfig(ModelRoutingConfig{Fallbacks: map[string][]string{"gpt-4o": {"deepseek-v4-pro", "nope"}}}, nil); err == nil {
+		t.Fatal("expected error for an unresolvable fallback entry")
+	}
+}
+
+type mockModelFallbacks struct {
+	mockModelAliasReader
+	chains   map[string][]string
+	backends map[string]APIBackendConfig
+}
+
+func (m mockModelFallbacks) ModelFallbacks(requested string) []string { return m.chains[requested] }
+
+func (m mockModelFallbacks) FallbackBackend(name string) (APIBackendConfig, bool) {
+	backend, ok := m.backends[name]
+	return backend, ok
+}
+
+func TestResolveModelFallbacks(t *testing.T) {
+	store := mockModelFallbacks{
+		mockModelAliasReader: mockModelAliasReader{"backup": "deepseek-v4-flash"},
+		chains: map[string][]string{
+			"gpt-4o":          {"deepseek-v4-pro", "backup", "deepseek-v4-flash", "nope"},
+			"deepseek-v4-pro": {"deepseek-v4-flash-nothinking"},
+		},
+	}
+	got := ResolveModelFallbacks(store, "gpt-4o", "deepseek-v4-pro")
+	if len(got) != 1 || got[0] != "deepseek-v4-flash" {
+		t.Fatalf("expected resolved, deduplicated chain without the primary, got %#v", got)
+	}
+	got = ResolveModelFallbacks(store, "claude-sonnet-4-5", "deepseek-v4-pro")
+	if len(got) != 1 || got[0] != "deepseek-v4-flash-nothinking" {
+		t.Fatalf("expected the resolved model's chain, got %#v", got)
+	}
+	if got := ResolveModelFallbacks(store, "deepseek-v4-flash", "deepseek-v4-flash"); got != nil {
+		t.Fatalf("expected no chain, got %#v", got)
+	}
+}
+
+func TestResolveModelFallbacksKeepsConfiguredBackends(t *testing.T) {
+	official := APIBackendConfig{BaseURL: "https://api.deepseek.com", APIKey: "sk-test", Model: "deepseek-chat"}
+	store := mockModelFallbacks{
+		chains: map[string][]string{
+			"gpt-4o": {"deepseek-v4-flash", "backend:official", "backend:missing", "Backend:official"},
+		},
+		backends: map[string]APIBackendConfig{"official": official},
+	}
+	got := ResolveModelFallbacks(store, "gpt-4o", "deepseek-v4-pro")
+	if len(got) != 2 || got[0] != "deepseek-v4-flash" || got[1] != "backend:official" {
+		t.Fatalf("expected the model then the configured backend, got %#v", got)
+	}
+	backends := ResolveFallbackBackends(store, "gpt-4o", "deepseek-v4-pro")
+	if len(backends) != 1 || backends["official"] != official {
+		t.Fatalf("expected the official backend snapshot, got %#v", backends)
+	}
+	if got := ResolveFallbackBackends(store, "deepseek-v4-flash", "deepseek-v4-flash"); got != nil {
+		t.Fatalf("expected no backends, got %#v", got)
+	}
+}
+
+func TestValidateModelRoutingConfigChecksBackends(t *testing.T) {
+	backends := map[string]APIBackendConfig{"official": {BaseURL: "https://api.deepseek.com", Model: "deepseek-chat"}}
+	if err := ValidateModelRoutingConfig(ModelRoutingConfig{Fallbacks: map[string][]string{"gpt-4o": {"backend:official"}}, Backends: backends}, nil); err != nil {
+		t.Fatalf("expected a configured backend to validate, got %v", err)
+	}
+	if err := ValidateModelRoutingConfig(ModelRoutingConfig{Fallbacks: map[string][]string{"gpt-4o": {"backend:other"}}, Backends: backends}, nil); err == nil {
+		t.Fatal("expected error for an unknown backend")
+	}
+	if err := ValidateModelRoutingConfig(ModelRoutingConfig{Backends: map[string]APIBackendConfig{"x": {BaseURL: "ftp://h", Model: "m"}}}, nil); err == nil {
+		t.Fatal("expected error for a non-http base_url")
+	}
+	if err := ValidateModelRoutingConfig(ModelRoutingConfig{Backends: map[string]APIBackendConfig{"x": {BaseURL: "https://h"}}}, nil); err == nil {
+		t.Fatal("expected error for a missing model")
+	}
+}
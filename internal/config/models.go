@@ -1,6 +1,8 @@
 package config
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"strings"
 	"time"
 )
@@ -13,10 +15,22 @@ type ModelInfo struct {
 	Permission []any  `json:"permission,omitempty"`
 }
 type OllamaModelInfo struct {
-	Name       string `json:"name"`
-	Model      string `json:"model"`
-	Size       int64  `json:"size"`
-	ModifiedAt string `json:"modified_at"`
+	Name       string             `json:"name"`
+	Model      string             `json:"model"`
+	Size       int64              `json:"size"`
+	Digest     string             `json:"digest"`
+	ModifiedAt string             `json:"modified_at"`
+	Details    OllamaModelDetails `json:"details"`
+}
+
+// OllamaModelDetails fills the `details` object Ollama clients expect in
+// /api/tags; remote models have no local format or quantization.
+type OllamaModelDetails struct {
+	Format            string   `json:"format"`
+	Family            string   `json:"family"`
+	Families          []string `json:"families"`
+	ParameterSize     string   `json:"parameter_size"`
+	QuantizationLevel string   `json:"quantization_level"`
 }
 type OllamaCapabilitiesModelInfo struct {
 	ID           string   `json:"id"`
@@ -27,6 +41,20 @@ type ModelAliasReader interface {
 	ModelAliases() map[string]string
 }
 
+// ModelRoutingReader extends alias lookup with the model_routing settings
+// used by request normalization.
+type ModelRoutingReader interface {
+	ModelAliasReader
+	ModelDefaultTarget() string
+	ModelResponseMode() string
+}
+
+// staticModelAliases adapts a plain alias map, e.g. while validating a config
+// that is not loaded into a Store yet.
+type staticModelAliases map[string]string
+
+func (m staticModelAliases) ModelAliases() map[string]string { return m }
+
 const noThinkingModelSuffix = "-nothinking"
 
 var deepSeekBaseModels = []ModelInfo{
@@ -246,6 +274,99 @@ func ResolveModel(store ModelAliasReader, requested string) (string, bool) {
 	return "", false
 }
 
+// ResolveRequestModel resolves the model of an incoming completion request.
+// Names that are neither a DeepSeek model nor an alias fall back to the
+// configured default target; with no default they are reported as not found.
+func ResolveRequestModel(store ModelRoutingReader, requested string) (string, bool) {
+	if store == nil {
+		return ResolveModel(nil, requested)
+	}
+	if resolved, ok := ResolveModel(store, requested); ok {
+		return resolved, true
+	}
+	if strings.TrimSpace(requested) == "" {
+		return "", false
+	}
+	target := store.ModelDefaultTarget()
+	if target == "" {
+		return "", false
+	}
+	return ResolveModel(store, target)
+}
+
+// ModelFallbackReader exposes the model_routing.fallbacks chains and the
+// backends they can name.
+type ModelFallbackReader interface {
+	ModelAliasReader
+	ModelFallbacks(requested string) []string
+	FallbackBackend(name string) (APIBackendConfig, bool)
+}
+
+// ResolveModelFallbacks returns the targets to try, in order, when the
+// upstream call for resolved fails: DeepSeek models, and "backend:<name>"
+// entries for configured backends. A chain configured for the requested name
+// wins over one configured for the resolved model; entries that do not
+// resolve or repeat an earlier target are skipped.
+func ResolveModelFallbacks(store ModelFallbackReader, requested, resolved string) []string {
+	if store == nil {
+		return nil
+	}
+	chain := store.ModelFallbacks(requested)
+	if len(chain) == 0 && !strings.EqualFold(strings.TrimSpace(requested), resolved) {
+		chain = store.ModelFallbacks(resolved)
+	}
+	seen := map[string]struct{}{resolved: {}}
+	out := make([]string, 0, len(chain))
+	for _, target := range chain {
+		model, ok := ResolveModel(store, target)
+		if name, isBackend := FallbackBackendName(target); isBackend {
+			_, ok = store.FallbackBackend(name)
+			model = FallbackBackendPrefix + name
+		}
+		if !ok {
+			continue
+		}
+		if _, dup := seen[model]; dup {
+			continue
+		}
+		seen[model] = struct{}{}
+		out = append(out, model)
+	}
+	if len(out) == 0 {
+		return nil
+	}
+	return out
+}
+
+// ResolveFallbackBackends returns the configured backends named by the
+// fallback chain of requested, keyed by name, so a request keeps the
+// settings it started with.
+func ResolveFallbackBackends(store ModelFallbackReader, requested, resolved string) map[string]APIBackendConfig {
+	var out map[string]APIBackendConfig
+	for _, target := range ResolveModelFallbacks(store, requested, resolved) {
+		name, ok := FallbackBackendName(target)
+		if !ok {
+			continue
+		}
+		if backend, ok := store.FallbackBackend(name); ok {
+			if out == nil {
+				out = map[string]APIBackendConfig{}
+			}
+			out[name] = backend
+		}
+	}
+	return out
+}
+
+// ResponseModelName picks the name echoed in the response `model` field.
+func ResponseModelName(store ModelRoutingReader, requested, resolved string) string {
+	requested = strings.TrimSpace(requested)
+	if requested == "" || (store != nil && store.ModelResponseMode() == ModelResponseResolved) {
+		return resolved
+	}
+	return requested
+}
+
 func lower(s string) string {
 	b := []byte(s)
 	for i, c := range b {
@@ -320,11 +441,17 @@ func mapToOllamaModels(models []ModelInfo) []OllamaModelInfo {
 		if model.Created > 0 {
 			modifiedAt = time.Unix(model.Created, 0).Format(time.RFC3339)
 		}
+		digest := sha256.Sum256([]byte(model.ID))
 		ollamaModel := OllamaModelInfo{
 			Name:       model.ID,
 			Model:      model.ID,
 			Size:       0,
+			Digest:     hex.EncodeToString(digest[:]),
 			ModifiedAt: modifiedAt,
+			Details: OllamaModelDetails{
+				Family:   "deepseek",
+				Families: []string{"deepseek"},
+			},
 		}
 		out = append(out, ollamaModel)
 	}
@@ -11,13 +11,14 @@ import (
 )
 
 type Store struct {
-	mu      sync.RWMutex
-	cfg     Config
-	path    string
-	fromEnv bool
-	keyMap  map[string]struct{} // O(1) API key lookup index
-	accMap  map[string]int      // O(1) account lookup: identifier -> slice index
-	accTest map[string]string   // runtime-only account test status cache
+	mu        sync.RWMutex
+	cfg       Config
+	path      string
+	fromEnv   bool
+	keyMap    map[string]struct{} // O(1) API key lookup index
+	keyModels map[string][]string // model allowlists of restricted API keys
+	accMap    map[string]int      // O(1) account lookup: identifier -> slice index
+	accTest   map[string]string   // runtime-only account test status cache
 }
 
 func LoadStore() *Store {
@@ -150,6 +151,14 @@ func (s *Store) HasAPIKey(k string) bool {
 	return ok
 }
 
+// APIKeyModels returns the model allowlist of a configured key, or nil when
+// the key may use every model.
+func (s *Store) APIKeyModels(k string) []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return slices.Clone(s.keyModels[k])
+}
+
 func (s *Store) Keys() []string {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
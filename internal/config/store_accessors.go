@@ -2,8 +2,11 @@ package config
 
 import (
 	"os"
+	"path/filepath"
+	"slices"
 	"strconv"
 	"strings"
+	"sync"
 )
 
 func (s *Store) ModelAliases() map[string]string {
@@ -21,6 +24,57 @@ func (s *Store) ModelAliases() map[string]string {
 	return out
 }
 
+// ModelDefaultTarget is the model used for names that match neither a DeepSeek
+// model nor an alias; empty means such requests fail with model_not_found.
+func (s *Store) ModelDefaultTarget() string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if v := strings.TrimSpace(s.cfg.ModelRouting.DefaultModel); v != "" {
+		return v
+	}
+	return strings.TrimSpace(os.Getenv("DS2API_DEFAULT_MODEL"))
+}
+
+// ModelFallbacks returns the configured fallback chain for a requested model
+// name, matched case-insensitively.
+func (s *Store) ModelFallbacks(requested string) []string {
+	requested = lower(strings.TrimSpace(requested))
+	if requested == "" {
+		return nil
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for model, chain := range s.cfg.ModelRouting.Fallbacks {
+		if lower(strings.TrimSpace(model)) == requested {
+			return slices.Clone(chain)
+		}
+	}
+	return nil
+}
+
+// FallbackBackend returns the model_routing.backends entry called name.
+func (s *Store) FallbackBackend(name string) (APIBackendConfig, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	backend, ok := s.cfg.ModelRouting.Backends[strings.TrimSpace(name)]
+	return backend, ok
+}
+
+// ModelResponseMode reports which name the response `model` field echoes:
+// ModelResponseRequested (default) or ModelResponseResolved.
+func (s *Store) ModelResponseMode() string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	mode := strings.ToLower(strings.TrimSpace(s.cfg.ModelRouting.ResponseModel))
+	if mode == "" {
+		mode = strings.ToLower(strings.TrimSpace(os.Getenv("DS2API_RESPONSE_MODEL")))
+	}
+	if mode == ModelResponseResolved {
+		return ModelResponseResolved
+	}
+	return ModelResponseRequested
+}
+
 func (s *Store) ToolcallMode() string {
 	return "feature_match"
 }
@@ -38,12 +92,254 @@ func (s *Store) ResponsesStoreTTLSeconds() int {
 	return 900
 }
 
+// RuntimeRequireAPIKey reports whether callers must present a configured key.
+// When false (default), unknown tokens are forwarded as direct DeepSeek tokens.
+func (s *Store) RuntimeRequireAPIKey() bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.cfg.Runtime.RequireAPIKey != nil {
+		return *s.cfg.Runtime.RequireAPIKey
+	}
+	switch strings.ToLower(strings.TrimSpace(os.Getenv("DS2API_REQUIRE_API_KEY"))) {
+	case "1", "true", "yes", "on":
+		return true
+	}
+	return false
+}
+
+// RuntimeStrictSamplingParams reports whether out-of-range sampling values
+// are rejected. When false (default), they are clamped into range.
+func (s *Store) RuntimeStrictSamplingParams() bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.cfg.Runtime.StrictSamplingParams != nil {
+		return *s.cfg.Runtime.StrictSamplingParams
+	}
+	switch strings.ToLower(strings.TrimSpace(os.Getenv("DS2API_STRICT_SAMPLING_PARAMS"))) {
+	case "1", "true", "yes", "on":
+		return true
+	}
+	return false
+}
+
+// RuntimeRemoteImageFetch reports whether remote http(s) image URLs in
+// message content are downloaded. It is off by default.
+func (s *Store) RuntimeRemoteImageFetch() bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.cfg.Runtime.RemoteImageFetch != nil {
+		return *s.cfg.Runtime.RemoteImageFetch
+	}
+	switch strings.ToLower(strings.TrimSpace(os.Getenv("DS2API_REMOTE_IMAGE_FETCH"))) {
+	case "1", "true", "yes", "on":
+		return true
+	}
+	return false
+}
+
+// RuntimeMergeConsecutiveMessages reports whether adjacent messages from the
+// same role are merged when the prompt is built. It is on by default.
+func (s *Store) RuntimeMergeConsecutiveMessages() bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.cfg.Runtime.MergeConsecutiveMessages != nil {
+		return *s.cfg.Runtime.MergeConsecutiveMessages
+	}
+	switch strings.ToLower(strings.TrimSpace(os.Getenv("DS2API_MERGE_CONSECUTIVE_MESSAGES"))) {
+	case "0", "false", "no", "off":
+		return false
+	}
+	return true
+}
+
+// RuntimeMaxCompletionChoices caps the chat completions `n` parameter; each
+// choice is a separate upstream generation.
+func (s *Store) RuntimeMaxCompletionChoices() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.cfg.Runtime.MaxCompletionChoices > 0 {
+		return s.cfg.Runtime.MaxCompletionChoices
+	}
+	if raw := strings.TrimSpace(os.Getenv("DS2API_MAX_COMPLETION_CHOICES")); raw != "" {
+		if n, err := strconv.Atoi(raw); err == nil && n > 0 {
+			return n
+		}
+	}
+	return 4
+}
+
+// RuntimeReadinessCacheSeconds is how long /readyz reuses its last upstream
+// probe result.
+func (s *Store) RuntimeReadinessCacheSeconds() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.cfg.Runtime.ReadinessCacheSeconds > 0 {
+		return s.cfg.Runtime.ReadinessCacheSeconds
+	}
+	if raw := strings.TrimSpace(os.Getenv("DS2API_READINESS_CACHE_SECONDS")); raw != "" {
+		if n, err := strconv.Atoi(raw); err == nil && n > 0 {
+			return n
+		}
+	}
+	return 10
+}
+
+// RuntimeMaxRequestBodyBytes is the largest request body the server accepts.
+// The default is 8 MiB and the ceiling 100 MiB. ValidateConfig rejects a
+// configured value above the ceiling; an out-of-range
+// DS2API_MAX_REQUEST_BODY_MB is logged once and falls back to the default.
+// ValidateJSONUTF8 and the bodyreplay middleware hold each accepted body in
+// memory, so the limit also bounds per-request memory; raise it only as far
+// as large inline attachments need.
+func (s *Store) RuntimeMaxRequestBodyBytes() int64 {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	mb := s.cfg.Runtime.MaxRequestBodyMB
+	if mb <= 0 {
+		if raw := strings.TrimSpace(os.Getenv("DS2API_MAX_REQUEST_BODY_MB")); raw != "" {
+			n, err := strconv.Atoi(raw)
+			if err != nil || ValidateIntRange("DS2API_MAX_REQUEST_BODY_MB", n, 1, maxRequestBodyMB, true) != nil {
+				warnMaxRequestBodyEnvOnce.Do(func() {
+					Logger.Warn("[config] DS2API_MAX_REQUEST_BODY_MB out of range, using the default", "value", raw, "min", 1, "max", maxRequestBodyMB, "default", defaultRequestBodyMB)
+				})
+			} else {
+				mb = n
+			}
+		}
+	}
+	if mb > maxRequestBodyMB {
+		warnMaxRequestBodyConfigOnce.Do(func() {
+			Logger.Warn("[config] runtime.max_request_body_mb out of range, clamping", "value", mb, "max", maxRequestBodyMB)
+		})
+		mb = maxRequestBodyMB
+	}
+	if mb <= 0 {
+		mb = defaultRequestBodyMB
+	}
+	return int64(mb) << 20
+}
+
+const (
+	defaultRequestBodyMB = 8
+	maxRequestBodyMB     = 100
+)
+
+var (
+	warnMaxRequestBodyEnvOnce    sync.Once
+	warnMaxRequestBodyConfigOnce sync.Once
+)
+
+// RuntimeShutdownGraceSeconds is how long a shutting-down server lets
+// in-flight requests finish before cancelling them.
+func (s *Store) RuntimeShutdownGraceSeconds() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.cfg.Runtime.ShutdownGraceSeconds > 0 {
+		return s.cfg.Runtime.ShutdownGraceSeconds
+	}
+	if raw := strings.TrimSpace(os.Getenv("DS2API_SHUTDOWN_GRACE_SECONDS")); raw != "" {
+		if n, err := strconv.Atoi(raw); err == nil && n > 0 {
+			return n
+		}
+	}
+	return 10
+}
+
+// RuntimeUpstreamMaxInflight caps concurrent DeepSeek completion streams; zero
+// disables the limiter.
+func (s *Store) RuntimeUpstreamMaxInflight() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.cfg.Runtime.UpstreamMaxInflight > 0 {
+		return s.cfg.Runtime.UpstreamMaxInflight
+	}
+	if raw := strings.TrimSpace(os.Getenv("DS2API_UPSTREAM_MAX_INFLIGHT")); raw != "" {
+		if n, err := strconv.Atoi(raw); err == nil && n > 0 {
+			return n
+		}
+	}
+	return 0
+}
+
+// RuntimeUpstreamMaxQueue bounds the requests waiting for an upstream slot;
+// it defaults to defaultSize.
+func (s *Store) RuntimeUpstreamMaxQueue(defaultSize int) int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.cfg.Runtime.UpstreamMaxQueue > 0 {
+		return s.cfg.Runtime.UpstreamMaxQueue
+	}
+	if raw := strings.TrimSpace(os.Getenv("DS2API_UPSTREAM_MAX_QUEUE")); raw != "" {
+		if n, err := strconv.Atoi(raw); err == nil && n >= 0 {
+			return n
+		}
+	}
+	if defaultSize < 0 {
+		return 0
+	}
+	return defaultSize
+}
+
+// RuntimeContextMaxTokens is the prompt token budget enforced by context
+// trimming; zero disables it.
+func (s *Store) RuntimeContextMaxTokens() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.cfg.Runtime.ContextMaxTokens > 0 {
+		return s.cfg.Runtime.ContextMaxTokens
+	}
+	if raw := strings.TrimSpace(os.Getenv("DS2API_CONTEXT_MAX_TOKENS")); raw != "" {
+		if n, err := strconv.Atoi(raw); err == nil && n > 0 {
+			return n
+		}
+	}
+	return 0
+}
+
+// RuntimeContextTrimStrategy picks how over-budget history is shortened; it
+// defaults to dropping the oldest messages.
+func (s *Store) RuntimeContextTrimStrategy() string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	strategy := strings.ToLower(strings.TrimSpace(s.cfg.Runtime.ContextTrimStrategy))
+	if strategy == "" {
+		strategy = strings.ToLower(strings.TrimSpace(os.Getenv("DS2API_CONTEXT_TRIM_STRATEGY")))
+	}
+	if strategy == ContextTrimSummarizeOldest {
+		return strategy
+	}
+	return ContextTrimDropOldest
+}
+
 func (s *Store) EmbeddingsProvider() string {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 	return strings.TrimSpace(s.cfg.Embeddings.Provider)
 }
 
+// EmbeddingsSettings returns the embeddings backend settings with limits
+// defaulted. The API key falls back to DS2API_EMBEDDINGS_API_KEY so it can
+// stay out of the config file.
+func (s *Store) EmbeddingsSettings() EmbeddingsConfig {
+	s.mu.RLock()
+	cfg := s.cfg.Embeddings
+	s.mu.RUnlock()
+	cfg.Provider = strings.TrimSpace(cfg.Provider)
+	cfg.BaseURL = strings.TrimSpace(cfg.BaseURL)
+	cfg.Model = strings.TrimSpace(cfg.Model)
+	cfg.APIKey = strings.TrimSpace(cfg.APIKey)
+	if cfg.APIKey == "" {
+		cfg.APIKey = strings.TrimSpace(os.Getenv("DS2API_EMBEDDINGS_API_KEY"))
+	}
+	if cfg.MaxInputs <= 0 {
+		cfg.MaxInputs = DefaultEmbeddingsMaxInputs
+	}
+	if cfg.MaxTokens <= 0 {
+		cfg.MaxTokens = DefaultEmbeddingsMaxTokens
+	}
+	return cfg
+}
+
 func (s *Store) AutoDeleteMode() string {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
@@ -141,6 +437,38 @@ func (s *Store) RuntimeTokenRefreshIntervalHours() int {
 	return 6
 }
 
+// RuntimeUpstreamRetryMaxAttempts caps how many times a completion request is
+// sent upstream when DeepSeek fails transiently before any output is streamed.
+func (s *Store) RuntimeUpstreamRetryMaxAttempts() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.cfg.Runtime.UpstreamRetryMaxAttempts > 0 {
+		return s.cfg.Runtime.UpstreamRetryMaxAttempts
+	}
+	if raw := strings.TrimSpace(os.Getenv("DS2API_UPSTREAM_RETRY_MAX_ATTEMPTS")); raw != "" {
+		if n, err := strconv.Atoi(raw); err == nil && n > 0 {
+			return n
+		}
+	}
+	return 3
+}
+
+// RuntimeRequestTimeoutSeconds bounds the whole lifetime of one API request,
+// including any upstream retries and the streamed response.
+func (s *Store) RuntimeRequestTimeoutSeconds() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.cfg.Runtime.RequestTimeoutSeconds > 0 {
+		return s.cfg.Runtime.RequestTimeoutSeconds
+	}
+	if raw := strings.TrimSpace(os.Getenv("DS2API_REQUEST_TIMEOUT_SECONDS")); raw != "" {
+		if n, err := strconv.Atoi(raw); err == nil && n > 0 {
+			return n
+		}
+	}
+	return 900
+}
+
 func (s *Store) AutoDeleteSessions() bool {
 	return s.AutoDeleteMode() != "none"
 }
@@ -174,3 +502,206 @@ func (s *Store) ThinkingInjectionPrompt() string {
 	defer s.mu.RUnlock()
 	return strings.TrimSpace(s.cfg.ThinkingInjection.Prompt)
 }
+
+// RequestLogSettings returns the request log configuration with defaults
+// applied: disabled, "hash" redaction and no sampled success bodies. Each
+// field falls back to its DS2API_REQUEST_LOG* environment variable.
+func (s *Store) RequestLogSettings() RequestLogConfig {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	cfg := s.cfg.RequestLog
+	out := RequestLogConfig{Redaction: RequestLogRedactionHash}
+	enabled := false
+	if cfg.Enabled != nil {
+		enabled = *cfg.Enabled
+	} else {
+		switch strings.ToLower(strings.TrimSpace(os.Getenv("DS2API_REQUEST_LOG"))) {
+		case "1", "true", "yes", "on":
+			enabled = true
+		}
+	}
+	out.Enabled = &enabled
+	redaction := strings.ToLower(strings.TrimSpace(cfg.Redaction))
+	if redaction == "" {
+		redaction = strings.ToLower(strings.TrimSpace(os.Getenv("DS2API_REQUEST_LOG_REDACTION")))
+	}
+	switch redaction {
+	case RequestLogRedactionOmit, RequestLogRedactionNone:
+		out.Redaction = redaction
+	}
+	out.BodySampleRate = cfg.BodySampleRate
+	if out.BodySampleRate == 0 {
+		if raw := strings.TrimSpace(os.Getenv("DS2API_REQUEST_LOG_BODY_SAMPLE_RATE")); raw != "" {
+			if f, err := strconv.ParseFloat(raw, 64); err == nil && f >= 0 && f <= 1 {
+				out.BodySampleRate = f
+			}
+		}
+	}
+	return out
+}
+
+// IdempotencySettings returns the idempotency configuration with defaults
+// applied: disabled, header keys only, a 600 second TTL and 1000 entries.
+// Each field falls back to its DS2API_IDEMPOTENCY* environment variable.
+func (s *Store) IdempotencySettings() IdempotencyConfig {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	cfg := s.cfg.Idempotency
+	envBool := func(key string) bool {
+		switch strings.ToLower(strings.TrimSpace(os.Getenv(key))) {
+		case "1", "true", "yes", "on":
+			return true
+		}
+		return false
+	}
+	envInt := func(key string, fallback, max int) int {
+		if n, err := strconv.Atoi(strings.TrimSpace(os.Getenv(key))); err == nil && n > 0 && n <= max {
+			return n
+		}
+		return fallback
+	}
+	enabled := envBool("DS2API_IDEMPOTENCY")
+	if cfg.Enabled != nil {
+		enabled = *cfg.Enabled
+	}
+	hashBody := envBool("DS2API_IDEMPOTENCY_HASH_BODY")
+	if cfg.HashBody != nil {
+		hashBody = *cfg.HashBody
+	}
+	out := IdempotencyConfig{
+		Enabled:    &enabled,
+		HashBody:   &hashBody,
+		TTLSeconds: cfg.TTLSeconds,
+		MaxEntries: cfg.MaxEntries,
+	}
+	if out.TTLSeconds <= 0 {
+		out.TTLSeconds = envInt("DS2API_IDEMPOTENCY_TTL_SECONDS", 600, 86400)
+	}
+	if out.MaxEntries <= 0 {
+		out.MaxEntries = envInt("DS2API_IDEMPOTENCY_MAX_ENTRIES", 1000, 100000)
+	}
+	return out
+}
+
+// ToolPromptSettings returns the tool prompt configuration. The template path
+// falls back to DS2API_TOOL_PROMPT_TEMPLATE_FILE and is resolved against the
+// working directory; the format falls back to DS2API_TOOL_CALL_FORMAT and
+// defaults to dsml.
+func (s *Store) ToolPromptSettings() ToolPromptConfig {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	out := ToolPromptConfig{
+		TemplateFile: strings.TrimSpace(s.cfg.ToolPrompt.TemplateFile),
+		Format:       strings.ToLower(strings.TrimSpace(s.cfg.ToolPrompt.Format)),
+	}
+	if out.TemplateFile == "" {
+		out.TemplateFile = strings.TrimSpace(os.Getenv("DS2API_TOOL_PROMPT_TEMPLATE_FILE"))
+	}
+	if out.TemplateFile != "" && !filepath.IsAbs(out.TemplateFile) {
+		out.TemplateFile = filepath.Join(BaseDir(), out.TemplateFile)
+	}
+	if out.Format == "" {
+		out.Format = strings.ToLower(strings.TrimSpace(os.Getenv("DS2API_TOOL_CALL_FORMAT")))
+	}
+	if out.Format != ToolCallFormatJSON {
+		out.Format = ToolCallFormatDSML
+	}
+	return out
+}
+
+// RateLimitSettings returns the per-caller rate limits. Enabled and the
+// default limits fall back to DS2API_RATE_LIMIT, DS2API_RATE_LIMIT_RPM and
+// DS2API_RATE_LIMIT_TPM; per-user overrides come from config only.
+func (s *Store) RateLimitSettings() RateLimitConfig {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	cfg := s.cfg.RateLimit
+	enabled := false
+	switch strings.ToLower(strings.TrimSpace(os.Getenv("DS2API_RATE_LIMIT"))) {
+	case "1", "true", "yes", "on":
+		enabled = true
+	}
+	if cfg.Enabled != nil {
+		enabled = *cfg.Enabled
+	}
+	envInt := func(key string, max int) int {
+		if n, err := strconv.Atoi(strings.TrimSpace(os.Getenv(key))); err == nil && n > 0 && n <= max {
+			return n
+		}
+		return 0
+	}
+	out := RateLimitConfig{
+		Enabled:           &enabled,
+		RequestsPerMinute: cfg.RequestsPerMinute,
+		TokensPerMinute:   cfg.TokensPerMinute,
+	}
+	if out.RequestsPerMinute <= 0 {
+		out.RequestsPerMinute = envInt("DS2API_RATE_LIMIT_RPM", 1000000)
+	}
+	if out.TokensPerMinute <= 0 {
+		out.TokensPerMinute = envInt("DS2API_RATE_LIMIT_TPM", 1000000000)
+	}
+	if len(cfg.Users) > 0 {
+		out.Users = make(map[string]RateLimitRule, len(cfg.Users))
+		for user, rule := range cfg.Users {
+			out.Users[user] = rule
+		}
+	}
+	return out
+}
+
+// CORSSettings returns the cross-origin policy. Each list falls back to its
+// comma-separated environment variable (DS2API_CORS_ALLOWED_ORIGINS,
+// DS2API_CORS_ALLOWED_HEADERS, DS2API_CORS_ALLOWED_METHODS) when unset in
+// config.
+func (s *Store) CORSSettings() CORSConfig {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	cfg := s.cfg.CORS
+	list := func(configured []string, key string) []string {
+		if len(configured) == 0 {
+			configured = strings.Split(os.Getenv(key), ",")
+		}
+		out := make([]string, 0, len(configured))
+		for _, v := range configured {
+			if v = strings.TrimSpace(v); v != "" {
+				out = append(out, v)
+			}
+		}
+		return out
+	}
+	return CORSConfig{
+		AllowedOrigins: list(cfg.AllowedOrigins, "DS2API_CORS_ALLOWED_ORIGINS"),
+		AllowedHeaders: list(cfg.AllowedHeaders, "DS2API_CORS_ALLOWED_HEADERS"),
+		AllowedMethods: list(cfg.AllowedMethods, "DS2API_CORS_ALLOWED_METHODS"),
+	}
+}
+
+// BatchSettings returns the batch queue configuration with defaults applied:
+// 2 workers, 1000 requests per batch, 100 stored batches and a 24 hour
+// retention. Each field falls back to its DS2API_BATCH_* environment
+// variable.
+func (s *Store) BatchSettings() BatchConfig {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	out := s.cfg.Batch
+	envInt := func(key string, fallback, min, max int) int {
+		if n, err := strconv.Atoi(strings.TrimSpace(os.Getenv(key))); err == nil && n >= min && n <= max {
+			return n
+		}
+		return fallback
+	}
+	if out.MaxWorkers <= 0 {
+		out.MaxWorkers = envInt("DS2API_BATCH_MAX_WORKERS", 2, 1, 64)
+	}
+	if out.MaxRequests <= 0 {
+		out.MaxRequests = envInt("DS2API_BATCH_MAX_REQUESTS", 1000, 1, 50000)
+	}
+	if out.MaxBatches <= 0 {
+		out.MaxBatches = envInt("DS2API_BATCH_MAX_BATCHES", 100, 1, 10000)
+	}
+	if out.TTLSeconds <= 0 {
+		out.TTLSeconds = envInt("DS2API_BATCH_TTL_SECONDS", 86400, 60, 604800)
+	}
+	return out
+}
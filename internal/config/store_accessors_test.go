@@ -44,3 +44,46 @@ func TestStoreThinkingInjectionAccessors(t *testing.T) {
 		t.Fatalf("thinking injection prompt=%q want custom thinking prompt", got)
 	}
 }
+
+func TestStoreRequestLogSettingsDefaultsAndEnvFallback(t *testing.T) {
+	t.Setenv("DS2API_REQUEST_LOG", "")
+	t.Setenv("DS2API_REQUEST_LOG_REDACTION", "")
+	t.Setenv("DS2API_REQUEST_LOG_BODY_SAMPLE_RATE", "")
+	store := &Store{cfg: Config{}}
+	got := store.RequestLogSettings()
+	if *got.Enabled || got.Redaction != RequestLogRedactionHash || got.BodySampleRate != 0 {
+		t.Fatalf("unexpected defaults: enabled=%v %#v", *got.Enabled, got)
+	}
+
+	t.Setenv("DS2API_REQUEST_LOG", "true")
+	t.Setenv("DS2API_REQUEST_LOG_REDACTION", "omit")
+	t.Setenv("DS2API_REQUEST_LOG_BODY_SAMPLE_RATE", "0.25")
+	got = store.RequestLogSettings()
+	if !*got.Enabled || got.Redaction != RequestLogRedactionOmit || got.BodySampleRate != 0.25 {
+		t.Fatalf("expected env fallback, got enabled=%v %#v", *got.Enabled, got)
+	}
+
+	disabled := false
+	store.cfg.RequestLog = RequestLogConfig{Enabled: &disabled, Redaction: "none", BodySampleRate: 0.5}
+	got = store.RequestLogSettings()
+	if *got.Enabled || got.Redaction != RequestLogRedactionNone || got.BodySampleRate != 0.5 {
+		t.Fatalf("expected config to take precedence, got enabled=%v %#v", *got.Enabled, got)
+	}
+}
+
+func TestStoreMaxRequestBodyIgnoresOutOfRangeEnv(t *testing.T) {
+	store := &Store{cfg: Config{}}
+	if got := store.RuntimeMaxRequestBodyBytes(); got != 8<<20 {
+		t.Fatalf("default limit=%d want the 8 MiB default", got)
+	}
+	t.Setenv("DS2API_MAX_REQUEST_BODY_MB", "32")
+	if got := store.RuntimeMaxRequestBodyBytes(); got != 32<<20 {
+		t.Fatalf("env limit=%d want=%d", got, 32<<20)
+	}
+	for _, raw := range []string{"500", "0", "abc"} {
+		t.Setenv("DS2API_MAX_REQUEST_BODY_MB", raw)
+		if got := store.RuntimeMaxRequestBodyBytes(); got != 8<<20 {
+			t.Fatalf("env %q: limit=%d want the 8 MiB default", raw, got)
+		}
+	}
+}
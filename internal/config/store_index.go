@@ -7,6 +7,12 @@ func (s *Store) rebuildIndexes() {
 	for _, k := range s.cfg.Keys {
 		s.keyMap[k] = struct{}{}
 	}
+	s.keyModels = make(map[string][]string)
+	for _, item := range s.cfg.APIKeys {
+		if models := NormalizeAPIKeyModels(item.Models); len(models) > 0 {
+			s.keyModels[item.Key] = models
+		}
+	}
 	s.accMap = make(map[string]int, len(s.cfg.Accounts))
 	s.accTest = make(map[string]string, len(s.cfg.Accounts))
 	for i, acc := range s.cfg.Accounts {
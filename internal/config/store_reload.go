@@ -0,0 +1,124 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"os"
+	"sort"
+	"strings"
+)
+
+// Reload re-reads the config from the source the store was loaded from
+// (DS2API_CONFIG_JSON for env-backed stores, the config file otherwise),
+// validates it and swaps it in for new requests. check, when non-nil, sees a
+// detached store holding the candidate config and can veto the swap. apply,
+// when non-nil, runs right after the swap while the store lock is still held,
+// so state kept outside the store (such as the tool prompt) always matches the
+// config that won, even when reloads race. Neither may call back into s.
+// Nothing is written back to the source, and account tokens obtained at
+// runtime carry over to accounts that are still present. On any error the
+// running config is left untouched. It returns the config that was replaced
+// and the one now active.
+func (s *Store) Reload(check func(next *Store) error, apply func()) (Config, Config, error) {
+	s.mu.RLock()
+	fromEnv, path := s.fromEnv, s.path
+	s.mu.RUnlock()
+
+	next, err := readConfigSource(fromEnv, path)
+	if err != nil {
+		return Config{}, Config{}, err
+	}
+	next.NormalizeCredentials()
+	if err := ValidateConfig(next); err != nil {
+		return Config{}, Config{}, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	prev := s.cfg.Clone()
+	carryAccountTokens(&next, prev)
+	candidate := &Store{cfg: next.Clone(), path: path, fromEnv: fromEnv}
+	candidate.rebuildIndexes()
+	if check != nil {
+		if err := check(candidate); err != nil {
+			return Config{}, Config{}, err
+		}
+	}
+	s.cfg = next
+	s.rebuildIndexes()
+	if apply != nil {
+		apply()
+	}
+	return prev, next.Clone(), nil
+}
+
+func readConfigSource(fromEnv bool, path string) (Config, error) {
+	if !fromEnv {
+		return loadConfigFromFile(path)
+	}
+	raw := strings.TrimSpace(os.Getenv("DS2API_CONFIG_JSON"))
+	if raw == "" {
+		return Config{}, errors.New("DS2API_CONFIG_JSON is empty")
+	}
+	cfg, err := parseConfigString(raw)
+	if err != nil {
+		return Config{}, err
+	}
+	cfg.ClearAccountTokens()
+	cfg.DropInvalidAccounts()
+	return cfg, nil
+}
+
+func carryAccountTokens(next *Config, prev Config) {
+	tokens := make(map[string]string, len(prev.Accounts))
+	for _, acc := range prev.Accounts {
+		if id := acc.Identifier(); id != "" && strings.TrimSpace(acc.Token) != "" {
+			tokens[id] = acc.Token
+		}
+	}
+	for i, acc := range next.Accounts {
+		if strings.TrimSpace(acc.Token) != "" {
+			continue
+		}
+		if token, ok := tokens[acc.Identifier()]; ok {
+			next.Accounts[i].Token = token
+		}
+	}
+}
+
+// ChangedSections lists the top-level config keys whose JSON form differs
+// between prev and next, sorted. Values are never included, so the result is
+// safe to return to clients.
+func ChangedSections(prev, next Config) []string {
+	a, errA := configSections(prev)
+	b, errB := configSections(next)
+	if errA != nil || errB != nil {
+		return nil
+	}
+	changed := []string{}
+	for key, value := range b {
+		if !bytes.Equal(a[key], value) {
+			changed = append(changed, key)
+		}
+	}
+	for key := range a {
+		if _, ok := b[key]; !ok {
+			changed = append(changed, key)
+		}
+	}
+	sort.Strings(changed)
+	return changed
+}
+
+func configSections(cfg Config) (map[string]json.RawMessage, error) {
+	b, err := json.Marshal(cfg)
+	if err != nil {
+		return nil, err
+	}
+	out := map[string]json.RawMessage{}
+	if err := json.Unmarshal(b, &out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
@@ -0,0 +1,137 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func writeReloadFixture(t *testing.T, path, raw string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(raw), 0o644); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+}
+
+func TestStoreReloadSwapsFileConfigWithoutWritingBack(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	writeReloadFixture(t, path, `{"keys":["k1"],"accounts":[{"email":"a@example.com","password":"p"}],"model_aliases":{"fast":"deepseek-v4-flash"}}`)
+	t.Setenv("DS2API_CONFIG_JSON", "")
+	t.Setenv("DS2API_CONFIG_PATH", path)
+	store, err := LoadStoreWithError()
+	if err != nil {
+		t.Fatalf("load store: %v", err)
+	}
+	if err := store.UpdateAccountToken("a@example.com", "runtime-token"); err != nil {
+		t.Fatalf("set token: %v", err)
+	}
+
+	raw := `{"keys":["k1","k2"],"accounts":[{"email":"a@example.com","password":"p"}],"model_aliases":{"fast":"deepseek-v4-pro"},"rate_limit":{"enabled":true,"requests_per_minute":30}}`
+	writeReloadFixture(t, path, raw)
+	prev, next, err := store.Reload(nil, nil)
+	if err != nil {
+		t.Fatalf("reload: %v", err)
+	}
+	if !store.HasAPIKey("k2") || store.Snapshot().ModelAliases["fast"] != "deepseek-v4-pro" {
+		t.Fatalf("reloaded config not active: %#v", store.Snapshot())
+	}
+	if acc, _ := store.FindAccount("a@example.com"); acc.Token != "runtime-token" {
+		t.Fatalf("expected the runtime token to survive the reload, got %q", acc.Token)
+	}
+	if got := ChangedSections(prev, next); !slices.Equal(got, []string{"api_keys", "keys", "model_aliases", "rate_limit"}) {
+		t.Fatalf("unexpected changed sections: %v", got)
+	}
+	if b, _ := os.ReadFile(path); string(b) != raw {
+		t.Fatalf("reload must not rewrite the source, got %s", b)
+	}
+}
+
+func TestStoreReloadKeepsRunningConfigOnInvalidSource(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	writeReloadFixture(t, path, `{"keys":["k1"]}`)
+	t.Setenv("DS2API_CONFIG_JSON", "")
+	t.Setenv("DS2API_CONFIG_PATH", path)
+	store, err := LoadStoreWithError()
+	if err != nil {
+		t.Fatalf("load store: %v", err)
+	}
+
+	for _, raw := range []string{`{"keys":[`, `{"keys":["k2"],"runtime":{"account_max_inflight":-1}}`} {
+		writeReloadFixture(t, path, raw)
+		if _, _, err := store.Reload(nil, nil); err == nil {
+			t.Fatalf("expected %s to be rejected", raw)
+		}
+		if !store.HasAPIKey("k1") || store.HasAPIKey("k2") {
+			t.Fatalf("running config changed after a rejected reload of %s", raw)
+		}
+	}
+
+	writeReloadFixture(t, path, `{"keys":["k2"]}`)
+	vetoed := errors.New("vetoed")
+	if _, _, err := store.Reload(func(next *Store) error {
+		if !next.HasAPIKey("k2") {
+			t.Fatal("check should see the candidate config")
+		}
+		return vetoed
+	}, func() {
+		t.Fatal("apply must not run for a vetoed reload")
+	}); !errors.Is(err, vetoed) {
+		t.Fatalf("expected the check error, got %v", err)
+	}
+	if store.HasAPIKey("k2") {
+		t.Fatal("a vetoed reload must not be applied")
+	}
+}
+
+func TestStoreReloadRereadsEnvSource(t *testing.T) {
+	t.Setenv("DS2API_ENV_WRITEBACK", "")
+	t.Setenv("DS2API_CONFIG_JSON", `{"keys":["k1"]}`)
+	store, err := LoadStoreWithError()
+	if err != nil {
+		t.Fatalf("load store: %v", err)
+	}
+	t.Setenv("DS2API_CONFIG_JSON", `{"keys":["k1"],"model_aliases":{"fast":"deepseek-v4-flash"}}`)
+	prev, next, err := store.Reload(nil, nil)
+	if err != nil {
+		t.Fatalf("reload: %v", err)
+	}
+	if got := ChangedSections(prev, next); !slices.Equal(got, []string{"model_aliases"}) {
+		t.Fatalf("unexpected changed sections: %v", got)
+	}
+	if !store.IsEnvBacked() {
+		t.Fatal("expected the store to stay env-backed")
+	}
+}
+
+func TestStoreReloadRunsApplyInsideTheSwap(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	writeReloadFixture(t, path, `{"keys":["k1"]}`)
+	t.Setenv("DS2API_CONFIG_JSON", "")
+	t.Setenv("DS2API_CONFIG_PATH", path)
+	store, err := LoadStoreWithError()
+	if err != nil {
+		t.Fatalf("load store: %v", err)
+	}
+
+	writeReloadFixture(t, path, `{"keys":["k2"]}`)
+	applied := false
+	if _, _, err := store.Reload(nil, func() {
+		applied = true
+		// The new config is already in place, and the lock is still held so
+		// no other reload can swap in between.
+		if _, ok := store.keyMap["k2"]; !ok {
+			t.Fatal("apply should run after the swap")
+		}
+		if store.mu.TryRLock() {
+			store.mu.RUnlock()
+			t.Fatal("apply should run while the store lock is held")
+		}
+	}); err != nil {
+		t.Fatalf("reload: %v", err)
+	}
+	if !applied {
+		t.Fatal("apply was not called")
+	}
+}
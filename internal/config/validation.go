@@ -2,6 +2,7 @@ package config
 
 import (
 	"fmt"
+	"net/url"
 	"strings"
 )
 
@@ -9,6 +10,9 @@ func ValidateConfig(c Config) error {
 	if err := ValidateProxyConfig(c.Proxies); err != nil {
 		return err
 	}
+	if err := ValidateModelRoutingConfig(c.ModelRouting, c.ModelAliases); err != nil {
+		return err
+	}
 	if err := ValidateAdminConfig(c.Admin); err != nil {
 		return err
 	}
@@ -27,6 +31,24 @@ func ValidateConfig(c Config) error {
 	if err := ValidateCurrentInputFileConfig(c.CurrentInputFile); err != nil {
 		return err
 	}
+	if err := ValidateRequestLogConfig(c.RequestLog); err != nil {
+		return err
+	}
+	if err := ValidateIdempotencyConfig(c.Idempotency); err != nil {
+		return err
+	}
+	if err := ValidateToolPromptConfig(c.ToolPrompt); err != nil {
+		return err
+	}
+	if err := ValidateRateLimitConfig(c.RateLimit); err != nil {
+		return err
+	}
+	if err := ValidateCORSConfig(c.CORS); err != nil {
+		return err
+	}
+	if err := ValidateBatchConfig(c.Batch); err != nil {
+		return err
+	}
 	if err := ValidateAccountProxyReferences(c.Accounts, c.Proxies); err != nil {
 		return err
 	}
@@ -79,6 +101,51 @@ func ValidateAccountProxyReferences(accounts []Account, proxies []Proxy) error {
 	return nil
 }
 
+// ValidateModelRoutingConfig checks that default_model and every fallback
+// entry resolve to a DeepSeek model through the built-in and configured
+// aliases, or name a configured backend.
+func ValidateModelRoutingConfig(routing ModelRoutingConfig, aliases map[string]string) error {
+	if target := strings.TrimSpace(routing.DefaultModel); target != "" {
+		if _, ok := ResolveModel(staticModelAliases(aliases), target); !ok {
+			return fmt.Errorf("model_routing.default_model %q is not a supported model or alias", target)
+		}
+	}
+	switch strings.ToLower(strings.TrimSpace(routing.ResponseModel)) {
+	case "", ModelResponseRequested, ModelResponseResolved:
+	default:
+		return fmt.Errorf("model_routing.response_model must be one of requested, resolved")
+	}
+	for requested, chain := range routing.Fallbacks {
+		if strings.TrimSpace(requested) == "" {
+			return fmt.Errorf("model_routing.fallbacks keys must not be empty")
+		}
+		for _, target := range chain {
+			if name, ok := FallbackBackendName(target); ok {
+				if _, ok := routing.Backends[name]; !ok {
+					return fmt.Errorf("model_routing.fallbacks[%q] entry %q names an unknown backend", requested, target)
+				}
+				continue
+			}
+			if _, ok := ResolveModel(staticModelAliases(aliases), target); !ok {
+				return fmt.Errorf("model_routing.fallbacks[%q] entry %q is not a supported model or alias", requested, target)
+			}
+		}
+	}
+	for name, backend := range routing.Backends {
+		if strings.TrimSpace(name) == "" {
+			return fmt.Errorf("model_routing.backends keys must not be empty")
+		}
+		u, err := url.Parse(strings.TrimSpace(backend.BaseURL))
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("model_routing.backends[%q].base_url must be an http(s) URL", name)
+		}
+		if strings.TrimSpace(backend.Model) == "" {
+			return fmt.Errorf("model_routing.backends[%q].model is required", name)
+		}
+	}
+	return nil
+}
+
 func ValidateAdminConfig(admin AdminConfig) error {
 	return ValidateIntRange("admin.jwt_expire_hours", admin.JWTExpireHours, 1, 720, false)
 }
@@ -96,6 +163,36 @@ func ValidateRuntimeConfig(runtime RuntimeConfig) error {
 	if err := ValidateIntRange("runtime.token_refresh_interval_hours", runtime.TokenRefreshIntervalHours, 1, 720, false); err != nil {
 		return err
 	}
+	if err := ValidateIntRange("runtime.upstream_retry_max_attempts", runtime.UpstreamRetryMaxAttempts, 1, 10, false); err != nil {
+		return err
+	}
+	if err := ValidateIntRange("runtime.request_timeout_seconds", runtime.RequestTimeoutSeconds, 30, 86400, false); err != nil {
+		return err
+	}
+	if err := ValidateIntRange("runtime.max_completion_choices", runtime.MaxCompletionChoices, 1, 16, false); err != nil {
+		return err
+	}
+	if err := ValidateIntRange("runtime.readiness_cache_seconds", runtime.ReadinessCacheSeconds, 1, 3600, false); err != nil {
+		return err
+	}
+	if err := ValidateIntRange("runtime.max_request_body_mb", runtime.MaxRequestBodyMB, 1, maxRequestBodyMB, false); err != nil {
+		return err
+	}
+	if err := ValidateIntRange("runtime.shutdown_grace_seconds", runtime.ShutdownGraceSeconds, 1, 3600, false); err != nil {
+		return err
+	}
+	if err := ValidateIntRange("runtime.upstream_max_inflight", runtime.UpstreamMaxInflight, 1, 200000, false); err != nil {
+		return err
+	}
+	if err := ValidateIntRange("runtime.upstream_max_queue", runtime.UpstreamMaxQueue, 1, 200000, false); err != nil {
+		return err
+	}
+	if err := ValidateIntRange("runtime.context_max_tokens", runtime.ContextMaxTokens, 256, 10000000, false); err != nil {
+		return err
+	}
+	if err := ValidateContextTrimStrategy(runtime.ContextTrimStrategy); err != nil {
+		return err
+	}
 	if runtime.AccountMaxInflight > 0 && runtime.GlobalMaxInflight > 0 && runtime.GlobalMaxInflight < runtime.AccountMaxInflight {
 		return fmt.Errorf("runtime.global_max_inflight must be >= runtime.account_max_inflight")
 	}
@@ -107,7 +204,25 @@ func ValidateResponsesConfig(responses ResponsesConfig) error {
 }
 
 func ValidateEmbeddingsConfig(embeddings EmbeddingsConfig) error {
-	return ValidateTrimmedString("embeddings.provider", embeddings.Provider, false)
+	if err := ValidateTrimmedString("embeddings.provider", embeddings.Provider, false); err != nil {
+		return err
+	}
+	if err := ValidateIntRange("embeddings.max_inputs", embeddings.MaxInputs, 1, embeddingsMaxInputsUpperBound, false); err != nil {
+		return err
+	}
+	if err := ValidateIntRange("embeddings.max_tokens", embeddings.MaxTokens, 1, embeddingsMaxTokensUpperBound, false); err != nil {
+		return err
+	}
+	if baseURL := strings.TrimSpace(embeddings.BaseURL); baseURL != "" {
+		u, err := url.Parse(baseURL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("embeddings.base_url must be an http(s) URL")
+		}
+	}
+	if strings.EqualFold(strings.TrimSpace(embeddings.Provider), EmbeddingsProviderOpenAI) && strings.TrimSpace(embeddings.BaseURL) == "" {
+		return fmt.Errorf("embeddings.base_url is required for provider %q", EmbeddingsProviderOpenAI)
+	}
+	return nil
 }
 
 func ValidateAutoDeleteConfig(autoDelete AutoDeleteConfig) error {
@@ -121,6 +236,105 @@ func ValidateCurrentInputFileConfig(currentInputFile CurrentInputFileConfig) err
 	return nil
 }
 
+func ValidateRequestLogConfig(requestLog RequestLogConfig) error {
+	switch strings.ToLower(strings.TrimSpace(requestLog.Redaction)) {
+	case "", RequestLogRedactionHash, RequestLogRedactionOmit, RequestLogRedactionNone:
+	default:
+		return fmt.Errorf("request_log.redaction must be one of %s, %s, %s", RequestLogRedactionHash, RequestLogRedactionOmit, RequestLogRedactionNone)
+	}
+	if requestLog.BodySampleRate < 0 || requestLog.BodySampleRate > 1 {
+		return fmt.Errorf("request_log.body_sample_rate must be between 0 and 1")
+	}
+	return nil
+}
+
+func ValidateIdempotencyConfig(idempotency IdempotencyConfig) error {
+	if err := ValidateIntRange("idempotency.ttl_seconds", idempotency.TTLSeconds, 1, 86400, false); err != nil {
+		return err
+	}
+	return ValidateIntRange("idempotency.max_entries", idempotency.MaxEntries, 1, 100000, false)
+}
+
+func ValidateToolPromptConfig(toolPrompt ToolPromptConfig) error {
+	switch strings.ToLower(strings.TrimSpace(toolPrompt.Format)) {
+	case "", ToolCallFormatDSML, ToolCallFormatJSON:
+		return nil
+	default:
+		return fmt.Errorf("tool_prompt.format must be one of %s, %s", ToolCallFormatDSML, ToolCallFormatJSON)
+	}
+}
+
+func ValidateRateLimitConfig(rateLimit RateLimitConfig) error {
+	if err := ValidateIntRange("rate_limit.requests_per_minute", rateLimit.RequestsPerMinute, 1, 1000000, false); err != nil {
+		return err
+	}
+	if err := ValidateIntRange("rate_limit.tokens_per_minute", rateLimit.TokensPerMinute, 1, 1000000000, false); err != nil {
+		return err
+	}
+	for user, rule := range rateLimit.Users {
+		if strings.TrimSpace(user) == "" {
+			return fmt.Errorf("rate_limit.users keys must be non-empty")
+		}
+		if err := ValidateIntRange("rate_limit.users."+user+".requests_per_minute", rule.RequestsPerMinute, 1, 1000000, false); err != nil {
+			return err
+		}
+		if err := ValidateIntRange("rate_limit.users."+user+".tokens_per_minute", rule.TokensPerMinute, 1, 1000000000, false); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func ValidateBatchConfig(batch BatchConfig) error {
+	if err := ValidateIntRange("batch.max_workers", batch.MaxWorkers, 1, 64, false); err != nil {
+		return err
+	}
+	if err := ValidateIntRange("batch.max_requests", batch.MaxRequests, 1, 50000, false); err != nil {
+		return err
+	}
+	if err := ValidateIntRange("batch.max_batches", batch.MaxBatches, 1, 10000, false); err != nil {
+		return err
+	}
+	return ValidateIntRange("batch.ttl_seconds", batch.TTLSeconds, 60, 604800, false)
+}
+
+// ValidateCORSConfig checks that origins are "*" or scheme://host[:port]
+// with at most one wildcard, and that headers and methods are HTTP tokens.
+func ValidateCORSConfig(cors CORSConfig) error {
+	for _, origin := range cors.AllowedOrigins {
+		origin = strings.TrimSpace(origin)
+		if origin == "*" {
+			continue
+		}
+		scheme, host, ok := strings.Cut(origin, "://")
+		if !ok || scheme == "" || host == "" || strings.Contains(host, "/") || strings.Count(origin, "*") > 1 || strings.Contains(scheme, "*") {
+			return fmt.Errorf("cors.allowed_origins entry %q must be \"*\" or scheme://host[:port] with at most one *", origin)
+		}
+	}
+	for name, values := range map[string][]string{"cors.allowed_headers": cors.AllowedHeaders, "cors.allowed_methods": cors.AllowedMethods} {
+		for _, v := range values {
+			if v = strings.TrimSpace(v); v != "*" && !isHTTPToken(v) {
+				return fmt.Errorf("%s entry %q is not a valid HTTP token", name, v)
+			}
+		}
+	}
+	return nil
+}
+
+func isHTTPToken(v string) bool {
+	if v == "" {
+		return false
+	}
+	for i := 0; i < len(v); i++ {
+		c := v[i]
+		if (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9') || strings.IndexByte("!#$%&'*+-.^_`|~", c) >= 0 {
+			continue
+		}
+		return false
+	}
+	return true
+}
+
 func ValidateIntRange(name string, value, min, max int, required bool) error {
 	if value == 0 && !required {
 		return nil
@@ -142,6 +356,15 @@ func ValidateTrimmedString(name, value string, required bool) error {
 	return nil
 }
 
+func ValidateContextTrimStrategy(strategy string) error {
+	switch strings.ToLower(strings.TrimSpace(strategy)) {
+	case "", ContextTrimDropOldest, ContextTrimSummarizeOldest:
+		return nil
+	default:
+		return fmt.Errorf("runtime.context_trim_strategy must be one of %s, %s", ContextTrimDropOldest, ContextTrimSummarizeOldest)
+	}
+}
+
 func ValidateAutoDeleteMode(mode string) error {
 	mode = strings.ToLower(strings.TrimSpace(mode))
 	switch mode {
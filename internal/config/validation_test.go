@@ -24,6 +24,11 @@ func TestValidateConfigRejectsInvalidValues(t *testing.T) {
 			}},
 			want: "runtime.global_max_inflight must be >= runtime.account_max_inflight",
 		},
+		{
+			name: "runtime max request body",
+			cfg:  Config{Runtime: RuntimeConfig{MaxRequestBodyMB: 101}},
+			want: "runtime.max_request_body_mb",
+		},
 		{
 			name: "responses",
 			cfg:  Config{Responses: ResponsesConfig{StoreTTLSeconds: 10}},
@@ -34,6 +39,21 @@ func TestValidateConfigRejectsInvalidValues(t *testing.T) {
 			cfg:  Config{Embeddings: EmbeddingsConfig{Provider: "   "}},
 			want: "embeddings.provider",
 		},
+		{
+			name: "embeddings openai without base url",
+			cfg:  Config{Embeddings: EmbeddingsConfig{Provider: "openai"}},
+			want: "embeddings.base_url is required",
+		},
+		{
+			name: "embeddings base url scheme",
+			cfg:  Config{Embeddings: EmbeddingsConfig{Provider: "openai", BaseURL: "ftp://example.com"}},
+			want: "embeddings.base_url",
+		},
+		{
+			name: "embeddings max inputs",
+			cfg:  Config{Embeddings: EmbeddingsConfig{MaxInputs: -1}},
+			want: "embeddings.max_inputs",
+		},
 		{
 			name: "auto delete",
 			cfg:  Config{AutoDelete: AutoDeleteConfig{Mode: "maybe"}},
@@ -44,6 +64,41 @@ func TestValidateConfigRejectsInvalidValues(t *testing.T) {
 			cfg:  Config{CurrentInputFile: CurrentInputFileConfig{MinChars: -1}},
 			want: "current_input_file.min_chars",
 		},
+		{
+			name: "request log redaction",
+			cfg:  Config{RequestLog: RequestLogConfig{Redaction: "mask"}},
+			want: "request_log.redaction",
+		},
+		{
+			name: "request log sample rate",
+			cfg:  Config{RequestLog: RequestLogConfig{BodySampleRate: 1.5}},
+			want: "request_log.body_sample_rate",
+		},
+		{
+			name: "idempotency ttl",
+			cfg:  Config{Idempotency: IdempotencyConfig{TTLSeconds: -5}},
+			want: "idempotency.ttl_seconds",
+		},
+		{
+			name: "batch workers",
+			cfg:  Config{Batch: BatchConfig{MaxWorkers: 100}},
+			want: "batch.max_workers",
+		},
+		{
+			name: "rate limit user override",
+			cfg:  Config{RateLimit: RateLimitConfig{Users: map[string]RateLimitRule{"alice": {RequestsPerMinute: -1}}}},
+			want: "rate_limit.users.alice.requests_per_minute",
+		},
+		{
+			name: "cors origin path",
+			cfg:  Config{CORS: CORSConfig{AllowedOrigins: []string{"https://app.example.com/ui"}}},
+			want: "cors.allowed_origins",
+		},
+		{
+			name: "tool prompt format",
+			cfg:  Config{ToolPrompt: ToolPromptConfig{Format: "react"}},
+			want: "tool_prompt.format",
+		},
 	}
 
 	for _, tc := range tests {
@@ -7,6 +7,7 @@ import (
 	"fmt"
 	"net/http"
 	"strings"
+	"time"
 	"unicode"
 
 	"ds2api/internal/auth"
@@ -61,7 +62,7 @@ func (c *Client) CreateSession(ctx context.Context, a *auth.RequestAuth, maxAtte
 		headers := c.authHeaders(a.DeepSeekToken)
 		resp, status, err := c.postJSONWithStatus(ctx, clients.regular, clients.fallback, dsprotocol.DeepSeekCreateSessionURL, headers, map[string]any{"agent": "chat"})
 		if err != nil {
-			config.Logger.Warn("[create_session] request error", "error", err, "account", a.AccountID)
+			config.Logger.WarnContext(ctx, "[create_session] request error", "error", err, "account", a.AccountID)
 			attempts++
 			continue
 		}
@@ -72,7 +73,7 @@ func (c *Client) CreateSession(ctx context.Context, a *auth.RequestAuth, maxAtte
 				return sessionID, nil
 			}
 		}
-		config.Logger.Warn("[create_session] failed", "status", status, "code", code, "biz_code", bizCode, "msg", msg, "biz_msg", bizMsg, "use_config_token", a.UseConfigToken, "account", a.AccountID)
+		config.Logger.WarnContext(ctx, "[create_session] failed", "status", status, "code", code, "biz_code", bizCode, "msg", msg, "biz_msg", bizMsg, "use_config_token", a.UseConfigToken, "account", a.AccountID)
 		if a.UseConfigToken {
 			if !refreshed && shouldAttemptRefresh(status, code, bizCode, msg, bizMsg) {
 				if c.Auth.RefreshToken(ctx, a) {
@@ -95,14 +96,52 @@ func (c *Client) GetPow(ctx context.Context, a *auth.RequestAuth, maxAttempts in
 	return c.GetPowForTarget(ctx, a, dsprotocol.DeepSeekCompletionTargetPath, maxAttempts)
 }
 
+// GetPowForTarget returns a solved PoW header for targetPath. Answers are
+// cached per token until their challenge expires, and concurrent callers for
+// the same token share a single solve.
 func (c *Client) GetPowForTarget(ctx context.Context, a *auth.RequestAuth, targetPath string, maxAttempts int) (string, error) {
-	if maxAttempts <= 0 {
-		maxAttempts = c.maxRetries
-	}
 	targetPath = strings.TrimSpace(targetPath)
 	if targetPath == "" {
 		targetPath = dsprotocol.DeepSeekCompletionTargetPath
 	}
+	key := newPowCacheKey(a, targetPath)
+	for {
+		header, wait, own := c.pow.lookup(key)
+		if header != "" {
+			return header, nil
+		}
+		if own != nil {
+			header, expires, err := c.solvePowForTarget(ctx, a, targetPath, maxAttempts)
+			c.pow.finish(own, newPowCacheKey(a, targetPath), header, expires, err)
+			return header, err
+		}
+		select {
+		case <-wait.done:
+		case <-ctx.Done():
+			return "", ctx.Err()
+		}
+		switch {
+		case wait.err == nil && wait.key == key:
+			return wait.header, nil
+		case wait.err == nil, errors.Is(wait.err, context.Canceled), errors.Is(wait.err, context.DeadlineExceeded):
+			// The shared solve ended on another account, or its caller went
+			// away; solve for this caller instead.
+			continue
+		default:
+			return "", wait.err
+		}
+	}
+}
+
+// invalidatePow forgets a cached PoW header that DeepSeek rejected.
+func (c *Client) invalidatePow(a *auth.RequestAuth, targetPath, header string) {
+	c.pow.invalidate(newPowCacheKey(a, targetPath), header)
+}
+
+func (c *Client) solvePowForTarget(ctx context.Context, a *auth.RequestAuth, targetPath string, maxAttempts int) (string, time.Time, error) {
+	if maxAttempts <= 0 {
+		maxAttempts = c.maxRetries
+	}
 	clients := c.requestClientsForAuth(ctx, a)
 	attempts := 0
 	refreshed := false
@@ -112,7 +151,7 @@ func (c *Client) GetPowForTarget(ctx context.Context, a *auth.RequestAuth, targe
 		headers := c.authHeaders(a.DeepSeekToken)
 		resp, status, err := c.postJSONWithStatus(ctx, clients.regular, clients.fallback, dsprotocol.DeepSeekCreatePowURL, headers, map[string]any{"target_path": targetPath})
 		if err != nil {
-			config.Logger.Warn("[get_pow] request error", "error", err, "account", a.AccountID, "target_path", targetPath)
+			config.Logger.WarnContext(ctx, "[get_pow] request error", "error", err, "account", a.AccountID, "target_path", targetPath)
 			lastFailureKind = FailureUnknown
 			lastFailureMessage = err.Error()
 			attempts++
@@ -125,12 +164,20 @@ func (c *Client) GetPowForTarget(ctx context.Context, a *auth.RequestAuth, targe
 			challenge, _ := bizData["challenge"].(map[string]any)
 			answer, err := ComputePow(ctx, challenge)
 			if err != nil {
+				if ctx.Err() != nil {
+					return "", time.Time{}, ctx.Err()
+				}
+				// A fresh challenge may be solvable where this one was not.
+				config.Logger.WarnContext(ctx, "[get_pow] solve failed", "error", err, "account", a.AccountID, "target_path", targetPath)
+				lastFailureKind = FailurePowSolve
+				lastFailureMessage = err.Error()
 				attempts++
 				continue
 			}
-			return BuildPowHeader(challenge, answer)
+			header, err := BuildPowHeader(challenge, answer)
+			return header, powChallengeExpiry(challenge), err
 		}
-		config.Logger.Warn("[get_pow] failed", "status", status, "code", code, "biz_code", bizCode, "msg", msg, "biz_msg", bizMsg, "use_config_token", a.UseConfigToken, "account", a.AccountID, "target_path", targetPath)
+		config.Logger.WarnContext(ctx, "[get_pow] failed", "status", status, "code", code, "biz_code", bizCode, "msg", msg, "biz_msg", bizMsg, "use_config_token", a.UseConfigToken, "account", a.AccountID, "target_path", targetPath)
 		lastFailureMessage = failureMessage(msg, bizMsg, "get pow failed")
 		if isTokenInvalid(status, code, bizCode, msg, bizMsg) || isAuthIndicativeBizFailure(msg, bizMsg) {
 			lastFailureKind = authFailureKind(a.UseConfigToken)
@@ -153,9 +200,9 @@ func (c *Client) GetPowForTarget(ctx context.Context, a *auth.RequestAuth, targe
 		attempts++
 	}
 	if lastFailureKind != FailureUnknown {
-		return "", &RequestFailure{Op: "get pow", Kind: lastFailureKind, Message: lastFailureMessage}
+		return "", time.Time{}, &RequestFailure{Op: "get pow", Kind: lastFailureKind, Message: lastFailureMessage}
 	}
-	return "", errors.New("get pow failed")
+	return "", time.Time{}, errors.New("get pow failed")
 }
 
 func (c *Client) authHeaders(token string) map[string]string {
@@ -5,21 +5,64 @@ import (
 	"context"
 	dsprotocol "ds2api/internal/deepseek/protocol"
 	"encoding/json"
+	"io"
 	"net/http"
+	"regexp"
+	"strings"
+	"time"
 
 	"ds2api/internal/auth"
 	"ds2api/internal/config"
 	trans "ds2api/internal/deepseek/transport"
+	"ds2api/internal/metrics"
+	"ds2api/internal/upstreamhook"
 )
 
+// CallCompletion sends the completion request. When DeepSeek rejects the PoW
+// answer, typically because a cached one outlived its challenge, the answer
+// is dropped from the cache and the request is sent once more with a fresh
+// solve.
 func (c *Client) CallCompletion(ctx context.Context, a *auth.RequestAuth, payload map[string]any, powResp string, maxAttempts int) (*http.Response, error) {
-	_ = maxAttempts
+	resp, err := c.callCompletionOnce(ctx, a, payload, powResp)
+	if err != nil || !powRejected(resp) {
+		return resp, err
+	}
+	config.Logger.InfoContext(ctx, "[completion] PoW answer rejected, solving a fresh challenge", "account", a.AccountID)
+	c.invalidatePow(a, dsprotocol.DeepSeekCompletionTargetPath, powResp)
+	fresh, err := c.GetPow(ctx, a, maxAttempts)
+	if err != nil {
+		return nil, err
+	}
+	return c.callCompletionOnce(ctx, a, payload, fresh)
+}
+
+func (c *Client) callCompletionOnce(ctx context.Context, a *auth.RequestAuth, payload map[string]any, powResp string) (*http.Response, error) {
 	clients := c.requestClientsForAuth(ctx, a)
 	headers := c.authHeaders(a.DeepSeekToken)
 	headers["x-ds-pow-response"] = powResp
+	payload, headers, err := applyBeforeUpstreamHooks(ctx, payload, headers)
+	if err != nil {
+		return nil, err
+	}
+	limit, maxQueue := c.upstreamLimits()
+	release, err := c.limiter.acquire(ctx, limit, maxQueue)
+	if err != nil {
+		return nil, err
+	}
 	captureSession := c.capture.Start("deepseek_completion", dsprotocol.DeepSeekCompletionURL, a.AccountID, payload)
+	started := time.Now()
 	resp, err := c.streamPostOnce(ctx, clients.stream, dsprotocol.DeepSeekCompletionURL, headers, payload)
 	if err != nil {
+		release()
+		metrics.ObserveUpstream(ctx, time.Since(started), 0, err)
+		return nil, err
+	}
+	metrics.ObserveUpstream(ctx, time.Since(started), resp.StatusCode, nil)
+	if err := upstreamhook.RunAfter(ctx, resp); err != nil {
+		if closeErr := resp.Body.Close(); closeErr != nil {
+			config.Logger.WarnContext(ctx, "[completion] response body close failed", "error", closeErr)
+		}
+		release()
 		return nil, err
 	}
 	if captureSession != nil {
@@ -28,9 +71,84 @@ func (c *Client) CallCompletion(ctx context.Context, a *auth.RequestAuth, payloa
 	if resp.StatusCode == http.StatusOK {
 		resp = c.wrapCompletionWithAutoContinue(ctx, a, payload, powResp, resp)
 	}
+	resp.Body = newLimitedBody(ctx, resp.Body, release)
 	return resp, nil
 }
 
+// applyBeforeUpstreamHooks lets the registered upstream hooks rewrite the
+// payload and headers of one completion call. Without hooks both are
+// returned as they are.
+func applyBeforeUpstreamHooks(ctx context.Context, payload map[string]any, headers map[string]string) (map[string]any, map[string]string, error) {
+	if !upstreamhook.Active() {
+		return payload, headers, nil
+	}
+	header := make(http.Header, len(headers))
+	for k, v := range headers {
+		header.Set(k, v)
+	}
+	payload, err := upstreamhook.ApplyToPayload(ctx, payload, header)
+	if err != nil {
+		return nil, nil, err
+	}
+	out := make(map[string]string, len(header))
+	for k := range header {
+		out[k] = header.Get(k)
+	}
+	return payload, out, nil
+}
+
+// maxPowRejectionPeek bounds how much of a non-stream completion response is
+// read to check for a PoW rejection.
+const maxPowRejectionPeek = 64 << 10
+
+// powRejectionPattern matches an error message that says the PoW answer
+// itself was refused, such as INVALID_POW_RESPONSE or "pow verification
+// failed". A message that merely mentions PoW does not count.
+var powRejectionPattern = func() *regexp.Regexp {
+	const (
+		pow    = `(pow|proof[ _-]?of[ _-]?work)`
+		reject = `(invalid|expired|wrong|incorrect|failed|rejected)`
+		part   = `([ _-]+(response|answer|challenge|verification|check))?`
+	)
+	return regexp.MustCompile(`(?i)(^|[^a-z])(` + reject + `[ _-]+` + pow + `|` + pow + part + `[ _-]+` + reject + `)([^a-z]|$)`)
+}()
+
+// powRejected reports whether resp is DeepSeek refusing the PoW answer, and
+// closes it if so. Event streams are never inspected; other bodies are
+// peeked at and restored untouched when they are something else.
+func powRejected(resp *http.Response) bool {
+	if resp == nil || resp.Body == nil {
+		return false
+	}
+	if resp.StatusCode == http.StatusOK && !strings.Contains(strings.ToLower(resp.Header.Get("Content-Type")), "json") {
+		return false
+	}
+	peek, _ := io.ReadAll(io.LimitReader(resp.Body, maxPowRejectionPeek))
+	parsed := map[string]any{}
+	_ = json.Unmarshal(peek, &parsed)
+	code, bizCode, msg, bizMsg := extractResponseStatus(parsed)
+	failed := resp.StatusCode != http.StatusOK || code != 0 || bizCode != 0
+	if failed && powRejectionPattern.MatchString(msg+" "+bizMsg) {
+		_ = resp.Body.Close()
+		return true
+	}
+	resp.Body = peekedBody{Reader: io.MultiReader(bytes.NewReader(peek), resp.Body), Closer: resp.Body}
+	return false
+}
+
+type peekedBody struct {
+	io.Reader
+	io.Closer
+}
+
+func (c *Client) upstreamLimits() (limit, maxQueue int) {
+	if c.Store == nil {
+		return 0, 0
+	}
+	limit = c.Store.RuntimeUpstreamMaxInflight()
+	return limit, c.Store.RuntimeUpstreamMaxQueue(limit)
+}
+
 func (c *Client) streamPost(ctx context.Context, doer trans.Doer, url string, headers map[string]string, payload any) (*http.Response, error) {
 	return c.streamPostWithFallback(ctx, doer, url, headers, payload, true)
 }
@@ -56,7 +174,7 @@ func (c *Client) streamPostWithFallback(ctx context.Context, doer trans.Doer, ur
 	resp, err := doer.Do(req)
 	if err != nil {
 		if allowFallback {
-			config.Logger.Warn("[deepseek] fingerprint stream request failed, fallback to std transport", "url", url, "error", err)
+			config.Logger.WarnContext(ctx, "[deepseek] fingerprint stream request failed, fallback to std transport", "url", url, "error", err)
 			req2, reqErr := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(b))
 			if reqErr != nil {
 				return nil, reqErr
@@ -2,11 +2,15 @@ package client
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
+	"io"
 	"net/http"
+	"strings"
 	"testing"
 
 	"ds2api/internal/auth"
+	"ds2api/internal/upstreamhook"
 )
 
 func TestCallCompletionDoesNotFallbackForNonIdempotentCompletion(t *testing.T) {
@@ -34,3 +38,75 @@ func TestCallCompletionDoesNotFallbackForNonIdempotentCompletion(t *testing.T) {
 		t.Fatal("completion fallback should not be called for a non-idempotent request")
 	}
 }
+
+type preambleHook struct {
+	upstreamhook.Nop
+}
+
+func (preambleHook) BeforeUpstream(_ context.Context, req *upstreamhook.Request) error {
+	req.Prompt = "Deployment preamble.\n\n" + req.Prompt
+	req.Params["search_enabled"] = false
+	req.Header.Set("X-Deployment", "eu-1")
+	return nil
+}
+
+func (preambleHook) AfterUpstream(_ context.Context, resp *http.Response) error {
+	resp.Header.Del("Set-Cookie")
+	return nil
+}
+
+func TestCallCompletionRunsUpstreamHooks(t *testing.T) {
+	t.Cleanup(upstreamhook.Register(preambleHook{}))
+	var sent *http.Request
+	var body map[string]any
+	client := &Client{
+		stream: doerFunc(func(req *http.Request) (*http.Response, error) {
+			sent = req
+			if err := json.NewDecoder(req.Body).Decode(&body); err != nil {
+				t.Fatalf("decode request body: %v", err)
+			}
+			header := http.Header{}
+			header.Set("Content-Type", "text/event-stream")
+			header.Set("Set-Cookie", "session=1")
+			return &http.Response{StatusCode: http.StatusOK, Header: header, Body: io.NopCloser(strings.NewReader("data: [DONE]\n\n"))}, nil
+		}),
+	}
+	resp, err := client.CallCompletion(context.Background(), &auth.RequestAuth{DeepSeekToken: "token"}, map[string]any{"prompt": "hello", "search_enabled": true}, "pow", 3)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if body["prompt"] != "Deployment preamble.\n\nhello" || body["search_enabled"] != false {
+		t.Fatalf("hook changes were not sent: %#v", body)
+	}
+	if sent.Header.Get("X-Deployment") != "eu-1" || sent.Header.Get("Authorization") != "Bearer token" || sent.Header.Get("X-Ds-Pow-Response") != "pow" {
+		t.Fatalf("unexpected request headers: %#v", sent.Header)
+	}
+	if resp.Header.Get("Set-Cookie") != "" {
+		t.Fatalf("expected AfterUpstream to strip the header, got %q", resp.Header.Get("Set-Cookie"))
+	}
+}
+
+type blockingHook struct {
+	upstreamhook.Nop
+}
+
+func (blockingHook) BeforeUpstream(context.Context, *upstreamhook.Request) error {
+	return errors.New("blocked")
+}
+
+func TestCallCompletionStopsWhenHookFails(t *testing.T) {
+	t.Cleanup(upstreamhook.Register(blockingHook{}))
+	called := false
+	client := &Client{stream: doerFunc(func(*http.Request) (*http.Response, error) {
+		called = true
+		return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader(""))}, nil
+	})}
+	_, err := client.CallCompletion(context.Background(), &auth.RequestAuth{DeepSeekToken: "token"}, map[string]any{"prompt": "hello"}, "pow", 3)
+	if !upstreamhook.IsHookError(err) {
+		t.Fatalf("expected a hook error, got %v", err)
+	}
+	if called {
+		t.Fatal("upstream must not be called once a hook fails")
+	}
+}
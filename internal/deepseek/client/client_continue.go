@@ -41,7 +41,7 @@ func (c *Client) wrapCompletionWithAutoContinue(ctx context.Context, a *auth.Req
 	if sessionID == "" {
 		return resp
 	}
-	config.Logger.Debug("[auto_continue] wrapping completion response", "session_id", sessionID)
+	config.Logger.DebugContext(ctx, "[auto_continue] wrapping completion response", "session_id", sessionID)
 	resp.Body = newAutoContinueBody(ctx, resp.Body, sessionID, defaultAutoContinueLimit, func(ctx context.Context, sessionID string, responseMessageID int) (*http.Response, error) {
 		return c.callContinue(ctx, a, sessionID, responseMessageID, powResp)
 	})
@@ -61,7 +61,7 @@ func (c *Client) callContinue(ctx context.Context, a *auth.RequestAuth, sessionI
 		"message_id":         responseMessageID,
 		"fallback_to_resume": true,
 	}
-	config.Logger.Info("[auto_continue] calling continue", "session_id", sessionID, "message_id", responseMessageID)
+	config.Logger.InfoContext(ctx, "[auto_continue] calling continue", "session_id", sessionID, "message_id", responseMessageID)
 	captureSession := c.capture.Start("deepseek_continue", dsprotocol.DeepSeekContinueURL, a.AccountID, payload)
 	resp, err := c.streamPost(ctx, clients.stream, dsprotocol.DeepSeekContinueURL, headers, payload)
 	if err != nil {
@@ -107,10 +107,10 @@ func pumpAutoContinue(ctx context.Context, pw *io.PipeWriter, initial io.ReadClo
 		}
 		if state.shouldContinue() && rounds < maxRounds {
 			rounds++
-			config.Logger.Info("[auto_continue] continuing", "round", rounds, "session_id", state.sessionID, "message_id", state.responseMessageID, "status", state.lastStatus)
+			config.Logger.InfoContext(ctx, "[auto_continue] continuing", "round", rounds, "session_id", state.sessionID, "message_id", state.responseMessageID, "status", state.lastStatus)
 			nextResp, err := openContinue(ctx, state.sessionID, state.responseMessageID)
 			if err != nil {
-				config.Logger.Warn("[auto_continue] continue request failed", "round", rounds, "error", err)
+				config.Logger.WarnContext(ctx, "[auto_continue] continue request failed", "round", rounds, "error", err)
 				_ = pw.CloseWithError(err)
 				return
 			}
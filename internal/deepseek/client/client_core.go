@@ -25,6 +25,8 @@ type Client struct {
 	fallback   *http.Client
 	fallbackS  *http.Client
 	maxRetries int
+	limiter    upstreamLimiter
+	pow        powCache
 
 	proxyClientsMu sync.RWMutex
 	proxyClients   map[string]requestClients
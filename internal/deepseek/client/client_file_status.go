@@ -54,7 +54,7 @@ func (c *Client) waitForUploadedFile(ctx context.Context, a *auth.RequestAuth, r
 			lastErr = fmt.Errorf("status=%s", strings.TrimSpace(result.Status))
 		} else if err != nil {
 			lastErr = err
-			config.Logger.Debug("[upload_file] waiting for file readiness", "file_id", result.ID, "attempt", attempt+1, "error", err)
+			config.Logger.DebugContext(ctx, "[upload_file] waiting for file readiness", "file_id", result.ID, "attempt", attempt+1, "error", err)
 		}
 
 		if attempt < fileReadyPollAttempts-1 {
@@ -7,6 +7,8 @@ import (
 	"strings"
 
 	"github.com/andybalholm/brotli"
+
+	"ds2api/internal/util"
 )
 
 func readResponseBody(resp *http.Response) ([]byte, error) {
@@ -27,10 +29,7 @@ func readResponseBody(resp *http.Response) ([]byte, error) {
 }
 
 func preview(b []byte) string {
-	s := strings.TrimSpace(string(b))
-	if len(s) > 160 {
-		return s[:160]
-	}
+	s, _ := util.TruncateUTF8Bytes(strings.TrimSpace(string(b)), 160)
 	return s
 }
 
@@ -37,7 +37,7 @@ func (c *Client) postJSONWithStatus(ctx context.Context, doer trans.Doer, fallba
 	}
 	resp, err := doer.Do(req)
 	if err != nil {
-		config.Logger.Warn("[deepseek] fingerprint request failed, fallback to std transport", "url", url, "error", err)
+		config.Logger.WarnContext(ctx, "[deepseek] fingerprint request failed, fallback to std transport", "url", url, "error", err)
 		req2, reqErr := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(b))
 		if reqErr != nil {
 			return nil, 0, reqErr
@@ -58,7 +58,7 @@ func (c *Client) postJSONWithStatus(ctx context.Context, doer trans.Doer, fallba
 	out := map[string]any{}
 	if len(payloadBytes) > 0 {
 		if err := json.Unmarshal(payloadBytes, &out); err != nil {
-			config.Logger.Warn("[deepseek] json parse failed", "url", url, "status", resp.StatusCode, "content_encoding", resp.Header.Get("Content-Encoding"), "preview", preview(payloadBytes))
+			config.Logger.WarnContext(ctx, "[deepseek] json parse failed", "url", url, "status", resp.StatusCode, "content_encoding", resp.Header.Get("Content-Encoding"), "preview", preview(payloadBytes))
 		}
 	}
 	return out, resp.StatusCode, nil
@@ -75,7 +75,7 @@ func (c *Client) getJSONWithStatus(ctx context.Context, doer trans.Doer, url str
 	}
 	resp, err := doer.Do(req)
 	if err != nil {
-		config.Logger.Warn("[deepseek] fingerprint GET request failed, fallback to std transport", "url", url, "error", err)
+		config.Logger.WarnContext(ctx, "[deepseek] fingerprint GET request failed, fallback to std transport", "url", url, "error", err)
 		req2, reqErr := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
 		if reqErr != nil {
 			return nil, 0, reqErr
@@ -96,7 +96,7 @@ func (c *Client) getJSONWithStatus(ctx context.Context, doer trans.Doer, url str
 	out := map[string]any{}
 	if len(payloadBytes) > 0 {
 		if err := json.Unmarshal(payloadBytes, &out); err != nil {
-			config.Logger.Warn("[deepseek] json parse failed", "url", url, "status", resp.StatusCode, "content_encoding", resp.Header.Get("Content-Encoding"), "preview", preview(payloadBytes))
+			config.Logger.WarnContext(ctx, "[deepseek] json parse failed", "url", url, "status", resp.StatusCode, "content_encoding", resp.Header.Get("Content-Encoding"), "preview", preview(payloadBytes))
 		}
 	}
 	return out, resp.StatusCode, nil
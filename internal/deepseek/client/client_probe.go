@@ -0,0 +1,52 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"ds2api/internal/auth"
+	dsprotocol "ds2api/internal/deepseek/protocol"
+)
+
+// Probe is the readiness check behind /readyz. With managed accounts it
+// fetches one session page for the first account, logging in first when the
+// account has no token yet, which proves both that DeepSeek is reachable and
+// that the token is accepted. Without accounts only reachability is checked.
+func (c *Client) Probe(ctx context.Context) error {
+	accounts := c.Store.Accounts()
+	if len(accounts) == 0 {
+		_, status, err := c.getJSONWithStatus(ctx, c.regular, dsprotocol.DeepSeekFetchSessionURL, dsprotocol.BaseHeaders)
+		if err != nil {
+			return fmt.Errorf("deepseek unreachable: %w", err)
+		}
+		if status >= http.StatusInternalServerError {
+			return fmt.Errorf("deepseek unavailable: status=%d", status)
+		}
+		return nil
+	}
+	acc := accounts[0]
+	for _, candidate := range accounts {
+		if strings.TrimSpace(candidate.Token) != "" {
+			acc = candidate
+			break
+		}
+	}
+	id := acc.Identifier()
+	token := strings.TrimSpace(acc.Token)
+	if token == "" {
+		var err error
+		if token, err = c.Login(ctx, acc); err != nil {
+			return fmt.Errorf("account %s: %w", id, err)
+		}
+		if err := c.Store.UpdateAccountToken(id, token); err != nil {
+			return fmt.Errorf("account %s: persist token: %w", id, err)
+		}
+	}
+	ctx = auth.WithAuth(ctx, &auth.RequestAuth{Account: acc, AccountID: id})
+	if _, err := c.GetSessionCountForToken(ctx, token); err != nil {
+		return fmt.Errorf("account %s: %w", id, err)
+	}
+	return nil
+}
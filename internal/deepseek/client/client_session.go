@@ -54,7 +54,7 @@ func (c *Client) GetSessionCount(ctx context.Context, a *auth.RequestAuth, maxAt
 
 		resp, status, err := c.getJSONWithStatus(ctx, clients.regular, reqURL, headers)
 		if err != nil {
-			config.Logger.Warn("[get_session_count] request error", "error", err, "account", a.AccountID)
+			config.Logger.WarnContext(ctx, "[get_session_count] request error", "error", err, "account", a.AccountID)
 			attempts++
 			continue
 		}
@@ -83,7 +83,7 @@ func (c *Client) GetSessionCount(ctx context.Context, a *auth.RequestAuth, maxAt
 		}
 
 		stats.ErrorMessage = fmt.Sprintf("status=%d, code=%d, msg=%s", status, code, msg)
-		config.Logger.Warn("[get_session_count] failed", "status", status, "code", code, "biz_code", bizCode, "msg", msg, "biz_msg", bizMsg, "account", a.AccountID)
+		config.Logger.WarnContext(ctx, "[get_session_count] failed", "status", status, "code", code, "biz_code", bizCode, "msg", msg, "biz_msg", bizMsg, "account", a.AccountID)
 
 		if a.UseConfigToken {
 			if isTokenInvalid(status, code, bizCode, msg, bizMsg) && !refreshed {
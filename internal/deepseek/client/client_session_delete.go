@@ -46,7 +46,7 @@ func (c *Client) DeleteSession(ctx context.Context, a *auth.RequestAuth, session
 
 		resp, status, err := c.postJSONWithStatus(ctx, clients.regular, clients.fallback, dsprotocol.DeepSeekDeleteSessionURL, headers, payload)
 		if err != nil {
-			config.Logger.Warn("[delete_session] request error", "error", err, "session_id", sessionID)
+			config.Logger.WarnContext(ctx, "[delete_session] request error", "error", err, "session_id", sessionID)
 			attempts++
 			continue
 		}
@@ -58,7 +58,7 @@ func (c *Client) DeleteSession(ctx context.Context, a *auth.RequestAuth, session
 		}
 
 		result.ErrorMessage = fmt.Sprintf("status=%d, code=%d, msg=%s", status, code, msg)
-		config.Logger.Warn("[delete_session] failed", "status", status, "code", code, "biz_code", bizCode, "msg", msg, "biz_msg", bizMsg, "session_id", sessionID)
+		config.Logger.WarnContext(ctx, "[delete_session] failed", "status", status, "code", code, "biz_code", bizCode, "msg", msg, "biz_msg", bizMsg, "session_id", sessionID)
 
 		if a.UseConfigToken {
 			if isTokenInvalid(status, code, bizCode, msg, bizMsg) && !refreshed {
@@ -123,14 +123,14 @@ func (c *Client) DeleteAllSessions(ctx context.Context, a *auth.RequestAuth) err
 
 	resp, status, err := c.postJSONWithStatus(ctx, clients.regular, clients.fallback, dsprotocol.DeepSeekDeleteAllSessionsURL, headers, payload)
 	if err != nil {
-		config.Logger.Warn("[delete_all_sessions] request error", "error", err)
+		config.Logger.WarnContext(ctx, "[delete_all_sessions] request error", "error", err)
 		return err
 	}
 
 	code := intFrom(resp["code"])
 	if status != http.StatusOK || code != 0 {
 		msg, _ := resp["msg"].(string)
-		config.Logger.Warn("[delete_all_sessions] failed", "status", status, "code", code, "msg", msg)
+		config.Logger.WarnContext(ctx, "[delete_all_sessions] failed", "status", status, "code", code, "msg", msg)
 		return fmt.Errorf("request failed: status=%d, code=%d, msg=%s", status, code, msg)
 	}
 
@@ -145,14 +145,14 @@ func (c *Client) DeleteAllSessionsForToken(ctx context.Context, token string) er
 
 	resp, status, err := c.postJSONWithStatus(ctx, clients.regular, clients.fallback, dsprotocol.DeepSeekDeleteAllSessionsURL, headers, payload)
 	if err != nil {
-		config.Logger.Warn("[delete_all_sessions_for_token] request error", "error", err)
+		config.Logger.WarnContext(ctx, "[delete_all_sessions_for_token] request error", "error", err)
 		return err
 	}
 
 	code := intFrom(resp["code"])
 	if status != http.StatusOK || code != 0 {
 		msg, _ := resp["msg"].(string)
-		config.Logger.Warn("[delete_all_sessions_for_token] failed", "status", status, "code", code, "msg", msg)
+		config.Logger.WarnContext(ctx, "[delete_all_sessions_for_token] failed", "status", status, "code", code, "msg", msg)
 		return fmt.Errorf("request failed: status=%d, code=%d, msg=%s", status, code, msg)
 	}
 
@@ -94,7 +94,7 @@ func (c *Client) UploadFile(ctx context.Context, a *auth.RequestAuth, req Upload
 		headers["x-thinking-enabled"] = "1"
 		resp, err := c.doUpload(ctx, clients.regular, clients.fallback, dsprotocol.DeepSeekUploadFileURL, headers, body)
 		if err != nil {
-			config.Logger.Warn("[upload_file] request error", "error", err, "account", a.AccountID, "filename", filename)
+			config.Logger.WarnContext(ctx, "[upload_file] request error", "error", err, "account", a.AccountID, "filename", filename)
 			return nil, err
 		}
 		if captureSession != nil {
@@ -110,7 +110,7 @@ func (c *Client) UploadFile(ctx context.Context, a *auth.RequestAuth, req Upload
 		parsed := map[string]any{}
 		if len(payloadBytes) > 0 {
 			if err := json.Unmarshal(payloadBytes, &parsed); err != nil {
-				config.Logger.Warn("[upload_file] json parse failed", "status", resp.StatusCode, "preview", preview(payloadBytes))
+				config.Logger.WarnContext(ctx, "[upload_file] json parse failed", "status", resp.StatusCode, "preview", preview(payloadBytes))
 			}
 		}
 		code, bizCode, msg, bizMsg := extractResponseStatus(parsed)
@@ -138,7 +138,8 @@ func (c *Client) UploadFile(ctx context.Context, a *auth.RequestAuth, req Upload
 			}
 			return result, nil
 		}
-		config.Logger.Warn("[upload_file] failed", "status", resp.StatusCode, "code", code, "biz_code", bizCode, "msg", msg, "biz_msg", bizMsg, "account", a.AccountID, "filename", filename)
+		config.Logger.WarnContext(ctx, "[upload_file] failed", "status", resp.StatusCode, "code", code, "biz_code", bizCode, "msg", msg, "biz_msg", bizMsg, "account", a.AccountID, "filename", filename)
+		c.invalidatePow(a, dsprotocol.DeepSeekUploadTargetPath, powHeader)
 		powHeader = ""
 		lastFailureMessage = failureMessage(msg, bizMsg, "upload file failed")
 		if isTokenInvalid(resp.StatusCode, code, bizCode, msg, bizMsg) || isAuthIndicativeBizFailure(msg, bizMsg) {
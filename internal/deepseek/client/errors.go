@@ -11,6 +11,9 @@ const (
 	FailureUnknown             FailureKind = ""
 	FailureDirectUnauthorized  FailureKind = "direct_unauthorized"
 	FailureManagedUnauthorized FailureKind = "managed_unauthorized"
+	// FailurePowSolve means DeepSeek issued PoW challenges that could not be
+	// solved. It is a server-side failure, not a problem with the request.
+	FailurePowSolve FailureKind = "pow_solve"
 )
 
 type RequestFailure struct {
@@ -44,3 +47,10 @@ func IsDirectUnauthorizedError(err error) bool {
 	var failure *RequestFailure
 	return errors.As(err, &failure) && failure.Kind == FailureDirectUnauthorized
 }
+
+// IsPowSolveError reports whether err is a PoW challenge that could not be
+// solved, which callers surface as a retryable server error.
+func IsPowSolveError(err error) bool {
+	var failure *RequestFailure
+	return errors.As(err, &failure) && failure.Kind == FailurePowSolve
+}
@@ -0,0 +1,151 @@
+package client
+
+import (
+	"crypto/sha256"
+	"sync"
+	"time"
+
+	"ds2api/internal/auth"
+)
+
+// powExpirySafety is subtracted from a challenge's expiry so a cached answer
+// is never sent just as it lapses.
+const powExpirySafety = 30 * time.Second
+
+// powCacheMaxEntries caps the cached headers. Tokens that stop being used
+// would otherwise keep their entries until a lookup for the same key.
+const powCacheMaxEntries = 1024
+
+// powCache keeps solved PoW headers per token and target path until their
+// challenge expires, and lets concurrent callers share one solve. The zero
+// value is ready to use.
+type powCache struct {
+	mu       sync.Mutex
+	entries  map[powCacheKey]powCacheEntry
+	inflight map[powCacheKey]*powSolve
+	now      func() time.Time
+}
+
+type powCacheKey struct {
+	token      [sha256.Size]byte
+	targetPath string
+}
+
+type powCacheEntry struct {
+	header  string
+	expires time.Time
+}
+
+// powSolve is one in-progress solve that other callers wait on.
+type powSolve struct {
+	done   chan struct{}
+	header string
+	err    error
+	// key is where the result is valid, which differs from the starting key
+	// when the solve switched accounts on the way.
+	key powCacheKey
+}
+
+func newPowCacheKey(a *auth.RequestAuth, targetPath string) powCacheKey {
+	token := ""
+	if a != nil {
+		token = a.DeepSeekToken
+	}
+	return powCacheKey{token: sha256.Sum256([]byte(token)), targetPath: targetPath}
+}
+
+func (p *powCache) clock() time.Time {
+	if p.now != nil {
+		return p.now()
+	}
+	return time.Now()
+}
+
+// lookup returns a live cached header for key, or the solve already running
+// for it. With neither, the caller becomes the solver: it gets a fresh
+// powSolve to run and must pass it to finish.
+func (p *powCache) lookup(key powCacheKey) (header string, wait *powSolve, own *powSolve) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if e, ok := p.entries[key]; ok {
+		if p.clock().Before(e.expires) {
+			return e.header, nil, nil
+		}
+		delete(p.entries, key)
+	}
+	if s, ok := p.inflight[key]; ok {
+		return "", s, nil
+	}
+	if p.inflight == nil {
+		p.inflight = map[powCacheKey]*powSolve{}
+	}
+	s := &powSolve{done: make(chan struct{}), key: key}
+	p.inflight[key] = s
+	return "", nil, s
+}
+
+// finish publishes a solve started by lookup and caches a successful header
+// under resultKey until expires.
+func (p *powCache) finish(s *powSolve, resultKey powCacheKey, header string, expires time.Time, err error) {
+	p.mu.Lock()
+	delete(p.inflight, s.key)
+	if err == nil && p.clock().Before(expires) {
+		if p.entries == nil {
+			p.entries = map[powCacheKey]powCacheEntry{}
+		}
+		if _, ok := p.entries[resultKey]; !ok && len(p.entries) >= powCacheMaxEntries {
+			p.evictLocked()
+		}
+		p.entries[resultKey] = powCacheEntry{header: header, expires: expires}
+	}
+	p.mu.Unlock()
+	s.header, s.err, s.key = header, err, resultKey
+	close(s.done)
+}
+
+// evictLocked makes room for one entry: it sweeps every expired entry and,
+// if the cache is still full, drops the one closest to expiry.
+func (p *powCache) evictLocked() {
+	now := p.clock()
+	var oldest powCacheKey
+	var oldestExpires time.Time
+	for key, e := range p.entries {
+		if !now.Before(e.expires) {
+			delete(p.entries, key)
+			continue
+		}
+		if oldestExpires.IsZero() || e.expires.Before(oldestExpires) {
+			oldest, oldestExpires = key, e.expires
+		}
+	}
+	if len(p.entries) >= powCacheMaxEntries {
+		delete(p.entries, oldest)
+	}
+}
+
+// invalidate drops the cached header for key if it is still header, so a
+// rejected answer is solved afresh without discarding a newer one.
+func (p *powCache) invalidate(key powCacheKey, header string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if e, ok := p.entries[key]; ok && e.header == header {
+		delete(p.entries, key)
+	}
+}
+
+// powChallengeExpiry turns a challenge's expire_at, in seconds or
+// milliseconds, into the time a cached answer stops being used. A challenge
+// without an expiry yields the zero time, so its answer is not cached.
+func powChallengeExpiry(challenge map[string]any) time.Time {
+	expireAt := toInt64(challenge["expire_at"], 0)
+	if expireAt <= 0 {
+		return time.Time{}
+	}
+	var at time.Time
+	if expireAt > 1e12 {
+		at = time.UnixMilli(expireAt)
+	} else {
+		at = time.Unix(expireAt, 0)
+	}
+	return at.Add(-powExpirySafety)
+}
@@ -0,0 +1,213 @@
+package client
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"ds2api/internal/auth"
+	"ds2api/pow"
+)
+
+// powChallengeResponse answers create_pow_challenge with a challenge whose
+// answer is answer, solvable within a small difficulty.
+func powChallengeResponse(algorithm string, answer int64, expireAt int64) *http.Response {
+	hash := pow.DeepSeekHashV1([]byte(pow.BuildPrefix("salt", expireAt) + strconv.FormatInt(answer, 10)))
+	body, _ := json.Marshal(map[string]any{
+		"code": 0,
+		"data": map[string]any{"biz_code": 0, "biz_data": map[string]any{"challenge": map[string]any{
+			"algorithm":   algorithm,
+			"challenge":   hex.EncodeToString(hash[:]),
+			"salt":        "salt",
+			"expire_at":   expireAt,
+			"difficulty":  1000,
+			"signature":   "sig",
+			"target_path": "/api/v0/chat/completion",
+		}}},
+	})
+	return &http.Response{StatusCode: http.StatusOK, Header: http.Header{"Content-Type": []string{"application/json"}}, Body: io.NopCloser(strings.NewReader(string(body)))}
+}
+
+func decodePowAnswer(t *testing.T, header string) float64 {
+	t.Helper()
+	raw, err := base64.StdEncoding.DecodeString(header)
+	if err != nil {
+		t.Fatalf("decode pow header %q: %v", header, err)
+	}
+	var payload map[string]any
+	if err := json.Unmarshal(raw, &payload); err != nil {
+		t.Fatalf("decode pow header %q: %v", header, err)
+	}
+	answer, _ := payload["answer"].(float64)
+	return answer
+}
+
+func TestGetPowCachesAnswerAndSharesConcurrentSolves(t *testing.T) {
+	var challenges atomic.Int32
+	expireAt := time.Now().Add(5 * time.Minute).Unix()
+	client := &Client{maxRetries: 3, regular: doerFunc(func(*http.Request) (*http.Response, error) {
+		challenges.Add(1)
+		time.Sleep(20 * time.Millisecond)
+		return powChallengeResponse("DeepSeekHashV1", 42, expireAt), nil
+	})}
+	a := &auth.RequestAuth{DeepSeekToken: "token"}
+
+	var wg sync.WaitGroup
+	headers := make([]string, 5)
+	for i := range headers {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			headers[i], _ = client.GetPow(context.Background(), &auth.RequestAuth{DeepSeekToken: "token"}, 3)
+		}(i)
+	}
+	wg.Wait()
+	if got := challenges.Load(); got != 1 {
+		t.Fatalf("expected concurrent callers to share one solve, got %d challenges", got)
+	}
+	for _, h := range headers {
+		if h == "" || h != headers[0] || decodePowAnswer(t, h) != 42 {
+			t.Fatalf("expected every caller to get the solved header, got %q", headers)
+		}
+	}
+	if h, err := client.GetPow(context.Background(), a, 3); err != nil || h != headers[0] || challenges.Load() != 1 {
+		t.Fatalf("expected the cached answer to be reused, got %q %v after %d challenges", h, err, challenges.Load())
+	}
+	if _, err := client.GetPow(context.Background(), &auth.RequestAuth{DeepSeekToken: "other"}, 3); err != nil || challenges.Load() != 2 {
+		t.Fatalf("expected another token to solve its own challenge, got %v after %d challenges", err, challenges.Load())
+	}
+
+	client.pow.now = func() time.Time { return time.Unix(expireAt, 0) }
+	if _, err := client.GetPow(context.Background(), a, 3); err != nil || challenges.Load() != 3 {
+		t.Fatalf("expected an expired answer to be solved again, got %v after %d challenges", err, challenges.Load())
+	}
+}
+
+func TestGetPowReportsUnsolvableChallengeAsPowSolveError(t *testing.T) {
+	client := &Client{maxRetries: 2, regular: doerFunc(func(*http.Request) (*http.Response, error) {
+		return powChallengeResponse("UnknownHash", 1, time.Now().Add(time.Minute).Unix()), nil
+	})}
+	_, err := client.GetPow(context.Background(), &auth.RequestAuth{DeepSeekToken: "token"}, 2)
+	if !IsPowSolveError(err) {
+		t.Fatalf("expected a pow solve error, got %v", err)
+	}
+	if IsManagedUnauthorizedError(err) || IsDirectUnauthorizedError(err) {
+		t.Fatalf("expected the solve failure not to read as an auth failure, got %v", err)
+	}
+}
+
+func TestCallCompletionRetriesRejectedPowWithFreshSolve(t *testing.T) {
+	expireAt := time.Now().Add(5 * time.Minute).Unix()
+	var challenges atomic.Int32
+	var sentPow []string
+	client := &Client{
+		maxRetries: 3,
+		regular: doerFunc(func(*http.Request) (*http.Response, error) {
+			challenges.Add(1)
+			return powChallengeResponse("DeepSeekHashV1", 7, expireAt), nil
+		}),
+		stream: doerFunc(func(req *http.Request) (*http.Response, error) {
+			sentPow = append(sentPow, req.Header.Get("x-ds-pow-response"))
+			if len(sentPow) == 1 {
+				return &http.Response{
+					StatusCode: http.StatusOK,
+					Header:     http.Header{"Content-Type": []string{"application/json"}},
+					Body:       io.NopCloser(strings.NewReader(`{"code":0,"data":{"biz_code":40301,"biz_msg":"INVALID_POW_RESPONSE"}}`)),
+				}, nil
+			}
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Header:     http.Header{"Content-Type": []string{"text/event-stream"}},
+				Body:       io.NopCloser(strings.NewReader("data: [DONE]\n\n")),
+			}, nil
+		}),
+	}
+	a := &auth.RequestAuth{DeepSeekToken: "token"}
+	stale := "stale-pow"
+	client.pow.entries = map[powCacheKey]powCacheEntry{
+		newPowCacheKey(a, "/api/v0/chat/completion"): {header: stale, expires: time.Now().Add(time.Minute)},
+	}
+
+	resp, err := client.CallCompletion(context.Background(), a, map[string]any{"prompt": "hi"}, stale, 3)
+	if err != nil {
+		t.Fatalf("expected the retried completion to succeed, got %v", err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+	if !strings.Contains(string(body), "[DONE]") {
+		t.Fatalf("expected the second response body, got %q", body)
+	}
+	if len(sentPow) != 2 || sentPow[0] != stale || sentPow[1] == stale || decodePowAnswer(t, sentPow[1]) != 7 {
+		t.Fatalf("expected the retry to carry a freshly solved answer, got %q", sentPow)
+	}
+	if challenges.Load() != 1 {
+		t.Fatalf("expected one fresh challenge, got %d", challenges.Load())
+	}
+}
+
+func TestPowRejectedLeavesOtherResponsesReadable(t *testing.T) {
+	resp := &http.Response{
+		StatusCode: http.StatusTooManyRequests,
+		Header:     http.Header{"Content-Type": []string{"application/json"}},
+		Body:       io.NopCloser(strings.NewReader(`{"code":42900,"msg":"rate limited, please slow down"}`)),
+	}
+	if powRejected(resp) {
+		t.Fatal("expected a non-PoW error not to count as a rejection")
+	}
+	if body, _ := io.ReadAll(resp.Body); !strings.Contains(string(body), "rate limited") {
+		t.Fatalf("expected the body to be restored, got %q", body)
+	}
+}
+
+func TestPowCacheEvictsExpiredThenOldestWhenFull(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	p := &powCache{now: func() time.Time { return now }}
+	key := func(i int) powCacheKey {
+		return newPowCacheKey(&auth.RequestAuth{DeepSeekToken: strconv.Itoa(i)}, "/api/v0/chat/completion")
+	}
+	for i := 0; i < powCacheMaxEntries; i++ {
+		_, _, own := p.lookup(key(i))
+		p.finish(own, key(i), "h", now.Add(time.Duration(i+1)*time.Second), nil)
+	}
+	now = now.Add(2 * time.Second)
+	_, _, own := p.lookup(key(-1))
+	p.finish(own, key(-1), "h", now.Add(time.Hour), nil)
+	if _, ok := p.entries[key(0)]; ok || len(p.entries) != powCacheMaxEntries-1 {
+		t.Fatalf("expected expired entries to be swept, got %d entries", len(p.entries))
+	}
+	for i := 0; len(p.entries) < powCacheMaxEntries; i++ {
+		_, _, own := p.lookup(key(-2 - i))
+		p.finish(own, key(-2-i), "h", now.Add(time.Hour), nil)
+	}
+	_, _, own = p.lookup(key(-10000))
+	p.finish(own, key(-10000), "h", now.Add(time.Hour), nil)
+	if _, ok := p.entries[key(2)]; ok || len(p.entries) != powCacheMaxEntries {
+		t.Fatalf("expected the entry closest to expiry to be evicted, got %d entries", len(p.entries))
+	}
+}
+
+func TestPowRejectionPatternMatchesOnlyRefusedAnswers(t *testing.T) {
+	for msg, want := range map[string]bool{
+		"INVALID_POW_RESPONSE":               true,
+		"PoW verification failed":            true,
+		"proof of work expired":              true,
+		"pow-answer incorrect":               true,
+		"rate limited, please slow down":     false,
+		"invalid power setting":              false,
+		"pow challenge required for this op": false,
+		"session expired":                    false,
+	} {
+		if got := powRejectionPattern.MatchString(msg); got != want {
+			t.Errorf("powRejectionPattern.MatchString(%q) = %v, want %v", msg, got, want)
+		}
+	}
+}
@@ -230,7 +230,7 @@ func TestProxyConnectivity(ctx context.Context, proxyCfg config.Proxy) map[strin
 	}
 	defer func() {
 		if closeErr := resp.Body.Close(); closeErr != nil {
-			config.Logger.Warn("[proxy] close response body failed", "proxy_id", proxyCfg.ID, "error", closeErr)
+			config.Logger.WarnContext(ctx, "[proxy] close response body failed", "proxy_id", proxyCfg.ID, "error", closeErr)
 		}
 	}()
 
@@ -0,0 +1,137 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sync"
+
+	"ds2api/internal/metrics"
+)
+
+// ErrUpstreamBusy is returned by CallCompletion when every upstream slot is
+// taken and the wait queue is full.
+var ErrUpstreamBusy = errors.New("too many concurrent upstream requests, please retry later")
+
+// upstreamLimiter caps how many completion streams are open against DeepSeek
+// at once. Callers beyond the limit wait in FIFO order up to maxQueue; a freed
+// slot is handed straight to the oldest waiter so new arrivals cannot barge
+// ahead. A limit of zero only counts streams for metrics.
+type upstreamLimiter struct {
+	mu       sync.Mutex
+	limit    int
+	maxQueue int
+	inflight int
+	waiters  []chan struct{}
+}
+
+// configureLocked applies the current limits; raising the limit admits
+// queued waiters right away.
+func (l *upstreamLimiter) configureLocked(limit, maxQueue int) {
+	l.limit, l.maxQueue = max(limit, 0), max(maxQueue, 0)
+	for len(l.waiters) > 0 && (l.limit == 0 || l.inflight < l.limit) {
+		l.inflight++
+		l.popWaiterLocked()
+	}
+}
+
+// acquire takes a slot under the given limits, waiting while ctx allows.
+// Limits are passed on every call so runtime setting changes apply without a
+// restart. The returned release is idempotent.
+func (l *upstreamLimiter) acquire(ctx context.Context, limit, maxQueue int) (func(), error) {
+	l.mu.Lock()
+	l.configureLocked(limit, maxQueue)
+	if l.limit == 0 || l.inflight < l.limit {
+		l.inflight++
+		l.publishLocked()
+		l.mu.Unlock()
+		return l.releaseFunc(), nil
+	}
+	if len(l.waiters) >= l.maxQueue {
+		l.mu.Unlock()
+		return nil, ErrUpstreamBusy
+	}
+	waiter := make(chan struct{})
+	l.waiters = append(l.waiters, waiter)
+	l.publishLocked()
+	l.mu.Unlock()
+
+	select {
+	case <-waiter:
+		return l.releaseFunc(), nil
+	case <-ctx.Done():
+		l.mu.Lock()
+		removed := l.removeWaiterLocked(waiter)
+		l.publishLocked()
+		l.mu.Unlock()
+		if !removed {
+			// The slot was handed over while ctx was being canceled.
+			l.releaseFunc()()
+		}
+		return nil, ctx.Err()
+	}
+}
+
+func (l *upstreamLimiter) releaseFunc() func() {
+	var once sync.Once
+	return func() {
+		once.Do(l.release)
+	}
+}
+
+func (l *upstreamLimiter) release() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if len(l.waiters) > 0 && (l.limit == 0 || l.inflight <= l.limit) {
+		l.popWaiterLocked()
+	} else if l.inflight > 0 {
+		l.inflight--
+	}
+	l.publishLocked()
+}
+
+func (l *upstreamLimiter) popWaiterLocked() {
+	waiter := l.waiters[0]
+	l.waiters = l.waiters[1:]
+	close(waiter)
+}
+
+func (l *upstreamLimiter) removeWaiterLocked(waiter chan struct{}) bool {
+	for i, w := range l.waiters {
+		if w == waiter {
+			l.waiters = append(l.waiters[:i], l.waiters[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+func (l *upstreamLimiter) publishLocked() {
+	metrics.SetUpstreamConcurrency(l.inflight, len(l.waiters))
+}
+
+// stats reports the open upstream streams and queued waiters.
+func (l *upstreamLimiter) stats() (inflight, queued int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.inflight, len(l.waiters)
+}
+
+// limitedBody gives the slot back when the consumer closes the stream or the
+// request context ends, whichever happens first, so a disconnected client
+// frees its slot without waiting for the handler to unwind.
+type limitedBody struct {
+	io.ReadCloser
+	release func()
+	stop    func() bool
+}
+
+func newLimitedBody(ctx context.Context, body io.ReadCloser, release func()) *limitedBody {
+	return &limitedBody{ReadCloser: body, release: release, stop: context.AfterFunc(ctx, release)}
+}
+
+func (b *limitedBody) Close() error {
+	b.stop()
+	b.release()
+	return b.ReadCloser.Close()
+}
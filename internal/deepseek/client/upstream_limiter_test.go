@@ -0,0 +1,96 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestUpstreamLimiterRejectsBeyondQueueDepth(t *testing.T) {
+	var l upstreamLimiter
+	release, err := l.acquire(context.Background(), 1, 1)
+	if err != nil {
+		t.Fatalf("first acquire: %v", err)
+	}
+	got := make(chan error, 1)
+	go func() {
+		r, err := l.acquire(context.Background(), 1, 1)
+		if err == nil {
+			r()
+		}
+		got <- err
+	}()
+	waitForQueued(t, &l, 1)
+	if _, err := l.acquire(context.Background(), 1, 1); !errors.Is(err, ErrUpstreamBusy) {
+		t.Fatalf("expected ErrUpstreamBusy with a full queue, got %v", err)
+	}
+	release()
+	release()
+	if err := <-got; err != nil {
+		t.Fatalf("queued acquire: %v", err)
+	}
+	if inflight, queued := l.stats(); inflight != 0 || queued != 0 {
+		t.Fatalf("expected idle limiter, got inflight=%d queued=%d", inflight, queued)
+	}
+}
+
+func TestUpstreamLimiterCanceledWaiterLeavesQueue(t *testing.T) {
+	var l upstreamLimiter
+	release, _ := l.acquire(context.Background(), 1, 4)
+	defer release()
+	ctx, cancel := context.WithCancel(context.Background())
+	got := make(chan error, 1)
+	go func() {
+		_, err := l.acquire(ctx, 1, 4)
+		got <- err
+	}()
+	waitForQueued(t, &l, 1)
+	cancel()
+	if err := <-got; !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+	if _, queued := l.stats(); queued != 0 {
+		t.Fatalf("expected canceled waiter removed, got queued=%d", queued)
+	}
+}
+
+func TestLimitedBodyReleasesSlotOnClientDisconnect(t *testing.T) {
+	var l upstreamLimiter
+	ctx, cancel := context.WithCancel(context.Background())
+	release, _ := l.acquire(ctx, 1, 0)
+	body := newLimitedBody(ctx, io.NopCloser(strings.NewReader("data")), release)
+	cancel()
+	deadline := time.Now().Add(time.Second)
+	for {
+		if inflight, _ := l.stats(); inflight == 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("slot not released after context cancellation")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if err := body.Close(); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+	if inflight, _ := l.stats(); inflight != 0 {
+		t.Fatalf("expected close after cancel not to double release, got inflight=%d", inflight)
+	}
+}
+
+func waitForQueued(t *testing.T, l *upstreamLimiter, n int) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for {
+		if _, queued := l.stats(); queued == n {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("expected %d queued waiter(s)", n)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
@@ -0,0 +1,24 @@
+package openai
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+
+	"ds2api/internal/config"
+	"ds2api/internal/version"
+)
+
+// SystemFingerprint returns the `system_fingerprint` echoed on chat
+// completions. It is derived from the DeepSeek backend model and the adapter
+// version only, so identical requests always report the same value and it
+// changes when either the backend model or the deployed build changes.
+func SystemFingerprint(model string) string {
+	backend, ok := config.ResolveModel(nil, model)
+	if !ok {
+		backend = strings.ToLower(strings.TrimSpace(model))
+	}
+	current, _ := version.Current()
+	sum := sha256.Sum256([]byte(backend + "|" + current))
+	return "fp_" + hex.EncodeToString(sum[:])[:12]
+}
@@ -12,6 +12,20 @@ func BuildChatCompletion(completionID, model, finalPrompt, finalThinking, finalT
 }
 
 func BuildChatCompletionWithToolCalls(completionID, model, finalPrompt, finalThinking, finalText string, detected []toolcall.ParsedToolCall, toolsRaw any) map[string]any {
+	return map[string]any{
+		"id":                 completionID,
+		"object":             "chat.completion",
+		"created":            time.Now().Unix(),
+		"model":              model,
+		"system_fingerprint": SystemFingerprint(model),
+		"choices":            []map[string]any{BuildChatCompletionChoice(0, finalThinking, finalText, detected, toolsRaw)},
+		"usage":              BuildChatUsageForModel(model, finalPrompt, finalThinking, finalText, 0),
+	}
+}
+
+// BuildChatCompletionChoice renders one entry of a non-stream `choices`
+// array; `n > 1` responses call it once per generation.
+func BuildChatCompletionChoice(index int, finalThinking, finalText string, detected []toolcall.ParsedToolCall, toolsRaw any) map[string]any {
 	finishReason := "stop"
 	messageObj := map[string]any{"role": "assistant", "content": finalText}
 	if strings.TrimSpace(finalThinking) != "" {
@@ -22,15 +36,7 @@ func BuildChatCompletionWithToolCalls(completionID, model, finalPrompt, finalThi
 		messageObj["tool_calls"] = toolcall.FormatOpenAIToolCalls(detected, toolsRaw)
 		messageObj["content"] = nil
 	}
-
-	return map[string]any{
-		"id":      completionID,
-		"object":  "chat.completion",
-		"created": time.Now().Unix(),
-		"model":   model,
-		"choices": []map[string]any{{"index": 0, "message": messageObj, "finish_reason": finishReason}},
-		"usage":   BuildChatUsageForModel(model, finalPrompt, finalThinking, finalText, 0),
-	}
+	return map[string]any{"index": index, "message": messageObj, "finish_reason": finishReason}
 }
 
 func BuildChatStreamDeltaChoice(index int, delta map[string]any) map[string]any {
@@ -50,14 +56,49 @@ func BuildChatStreamFinishChoice(index int, finishReason string) map[string]any
 
 func BuildChatStreamChunk(completionID string, created int64, model string, choices []map[string]any, usage map[string]any) map[string]any {
 	out := map[string]any{
-		"id":      completionID,
-		"object":  "chat.completion.chunk",
-		"created": created,
-		"model":   model,
-		"choices": choices,
+		"id":                 completionID,
+		"object":             "chat.completion.chunk",
+		"created":            created,
+		"model":              model,
+		"system_fingerprint": SystemFingerprint(model),
+		"choices":            choices,
 	}
 	if len(usage) > 0 {
 		out["usage"] = usage
 	}
 	return out
 }
+
+// ConvertToLegacyFunctionCalls rewrites a chat completion or stream chunk for
+// clients that declared tools with the deprecated `functions` field: the
+// first tool call of each message or delta becomes `function_call` and the
+// "tool_calls" finish reason becomes "function_call". The legacy shape holds
+// one call per message, so any further calls are dropped.
+func ConvertToLegacyFunctionCalls(body map[string]any) {
+	choices, _ := body["choices"].([]map[string]any)
+	for _, choice := range choices {
+		if choice["finish_reason"] == "tool_calls" {
+			choice["finish_reason"] = "function_call"
+		}
+		for _, key := range []string{"message", "delta"} {
+			msg, _ := choice[key].(map[string]any)
+			if msg == nil {
+				continue
+			}
+			calls, ok := msg["tool_calls"].([]map[string]any)
+			if !ok {
+				continue
+			}
+			delete(msg, "tool_calls")
+			for _, call := range calls {
+				if index, hasIndex := call["index"].(int); hasIndex && index != 0 {
+					continue
+				}
+				if fn, _ := call["function"].(map[string]any); fn != nil {
+					msg["function_call"] = fn
+				}
+				break
+			}
+		}
+	}
+}
@@ -0,0 +1,39 @@
+package openai
+
+import "time"
+
+// BuildTextCompletionChoice renders one entry of a legacy /v1/completions
+// `choices` array. logprobs is always null because the backend has none.
+func BuildTextCompletionChoice(index int, text string, finishReason any) map[string]any {
+	return map[string]any{"text": text, "index": index, "logprobs": nil, "finish_reason": finishReason}
+}
+
+// BuildTextCompletion renders a non-stream legacy completion response.
+func BuildTextCompletion(completionID, model string, choices []map[string]any, usage map[string]any) map[string]any {
+	return map[string]any{
+		"id":                 completionID,
+		"object":             "text_completion",
+		"created":            time.Now().Unix(),
+		"model":              model,
+		"system_fingerprint": SystemFingerprint(model),
+		"choices":            choices,
+		"usage":              usage,
+	}
+}
+
+// BuildTextCompletionChunk renders one legacy completion stream chunk; the
+// stream reuses the non-stream object name, as OpenAI does.
+func BuildTextCompletionChunk(completionID string, created int64, model string, choices []map[string]any, usage map[string]any) map[string]any {
+	out := map[string]any{
+		"id":                 completionID,
+		"object":             "text_completion",
+		"created":            created,
+		"model":              model,
+		"system_fingerprint": SystemFingerprint(model),
+		"choices":            choices,
+	}
+	if len(usage) > 0 {
+		out["usage"] = usage
+	}
+	return out
+}
@@ -125,3 +125,11 @@ func normalizeJSONString(raw string) string {
 	}
 	return string(b)
 }
+
+// MarkResponseIncomplete turns a rendered response into the OpenAI
+// `incomplete` shape, e.g. with reason "max_output_tokens" when the answer
+// was cut at the output budget.
+func MarkResponseIncomplete(response map[string]any, reason string) {
+	response["status"] = "incomplete"
+	response["incomplete_details"] = map[string]any{"reason": reason}
+}
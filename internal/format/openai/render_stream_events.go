@@ -167,3 +167,14 @@ func BuildResponsesCompletedPayload(response map[string]any) map[string]any {
 		"response":    response,
 	}
 }
+
+// BuildResponsesIncompletePayload is the terminal event for a response marked
+// with MarkResponseIncomplete.
+func BuildResponsesIncompletePayload(response map[string]any) map[string]any {
+	responseID, _ := response["id"].(string)
+	return map[string]any{
+		"type":        "response.incomplete",
+		"response_id": responseID,
+		"response":    response,
+	}
+}
@@ -204,3 +204,43 @@ func TestBuildChatUsageForModelUsesConservativePromptCount(t *testing.T) {
 		t.Fatalf("expected total tokens to add up, got usage=%#v", usage)
 	}
 }
+
+func TestBuildChatCompletionSurfacesReasoningContentOnlyWhenPresent(t *testing.T) {
+	withThinking := BuildChatCompletionWithToolCalls("chatcmpl-test", "deepseek-v4-flash", "prompt", "step by step", "answer", nil, nil)
+	choices, _ := withThinking["choices"].([]map[string]any)
+	message, _ := choices[0]["message"].(map[string]any)
+	if message["reasoning_content"] != "step by step" {
+		t.Fatalf("expected reasoning_content to carry thinking text, got %#v", message)
+	}
+	if message["content"] != "answer" {
+		t.Fatalf("expected final answer to stay in content, got %#v", message)
+	}
+
+	for _, thinking := range []string{"", " \n "} {
+		obj := BuildChatCompletionWithToolCalls("chatcmpl-test", "deepseek-v4-flash-nothinking", "prompt", thinking, "answer", nil, nil)
+		choices, _ := obj["choices"].([]map[string]any)
+		message, _ := choices[0]["message"].(map[string]any)
+		if _, ok := message["reasoning_content"]; ok {
+			t.Fatalf("expected reasoning_content to be omitted for thinking=%q, got %#v", thinking, message)
+		}
+	}
+}
+
+func TestChatCompletionEchoesStableSystemFingerprint(t *testing.T) {
+	first := BuildChatCompletion("chatcmpl_a", "deepseek-v4-flash", "prompt", "", "one", nil, nil)
+	second := BuildChatCompletion("chatcmpl_b", "deepseek-v4-flash", "prompt", "", "two", nil, nil)
+	fp, _ := first["system_fingerprint"].(string)
+	if !strings.HasPrefix(fp, "fp_") || fp != second["system_fingerprint"] {
+		t.Fatalf("expected a stable fp_ fingerprint, got %#v and %#v", first["system_fingerprint"], second["system_fingerprint"])
+	}
+	chunk := BuildChatStreamChunk("chatcmpl_a", 1, "deepseek-v4-flash", nil, nil)
+	if chunk["system_fingerprint"] != fp {
+		t.Fatalf("expected stream chunk to echo %q, got %#v", fp, chunk["system_fingerprint"])
+	}
+	if other := SystemFingerprint("deepseek-v4-pro"); other == fp {
+		t.Fatalf("expected a different backend model to change the fingerprint")
+	}
+	if alias := SystemFingerprint("gpt-4o"); alias != SystemFingerprint("deepseek-v4-flash") {
+		t.Fatalf("expected an alias to share its backend model fingerprint")
+	}
+}
@@ -88,12 +88,39 @@ func (h *Handler) configImport(w http.ResponseWriter, r *http.Request) {
 					next.ModelAliases[k] = v
 				}
 			}
+			if strings.TrimSpace(incoming.ModelRouting.DefaultModel) != "" {
+				next.ModelRouting.DefaultModel = incoming.ModelRouting.DefaultModel
+			}
+			if strings.TrimSpace(incoming.ModelRouting.ResponseModel) != "" {
+				next.ModelRouting.ResponseModel = incoming.ModelRouting.ResponseModel
+			}
+			if len(incoming.ModelRouting.Fallbacks) > 0 {
+				next.ModelRouting.Fallbacks = incoming.ModelRouting.Fallbacks
+			}
+			if len(incoming.ModelRouting.Backends) > 0 {
+				next.ModelRouting.Backends = incoming.ModelRouting.Backends
+			}
 			if incoming.Responses.StoreTTLSeconds > 0 {
 				next.Responses.StoreTTLSeconds = incoming.Responses.StoreTTLSeconds
 			}
 			if strings.TrimSpace(incoming.Embeddings.Provider) != "" {
 				next.Embeddings.Provider = incoming.Embeddings.Provider
 			}
+			if strings.TrimSpace(incoming.Embeddings.BaseURL) != "" {
+				next.Embeddings.BaseURL = incoming.Embeddings.BaseURL
+			}
+			if strings.TrimSpace(incoming.Embeddings.APIKey) != "" {
+				next.Embeddings.APIKey = incoming.Embeddings.APIKey
+			}
+			if strings.TrimSpace(incoming.Embeddings.Model) != "" {
+				next.Embeddings.Model = incoming.Embeddings.Model
+			}
+			if incoming.Embeddings.MaxInputs > 0 {
+				next.Embeddings.MaxInputs = incoming.Embeddings.MaxInputs
+			}
+			if incoming.Embeddings.MaxTokens > 0 {
+				next.Embeddings.MaxTokens = incoming.Embeddings.MaxTokens
+			}
 			incomingVercel := config.NormalizeVercelConfig(incoming.Vercel)
 			if strings.TrimSpace(incomingVercel.Token) != "" || strings.TrimSpace(incomingVercel.ProjectID) != "" || strings.TrimSpace(incomingVercel.TeamID) != "" {
 				next.Vercel = incomingVercel
@@ -119,6 +146,96 @@ func (h *Handler) configImport(w http.ResponseWriter, r *http.Request) {
 			if incoming.Runtime.TokenRefreshIntervalHours > 0 {
 				next.Runtime.TokenRefreshIntervalHours = incoming.Runtime.TokenRefreshIntervalHours
 			}
+			if incoming.Runtime.UpstreamRetryMaxAttempts > 0 {
+				next.Runtime.UpstreamRetryMaxAttempts = incoming.Runtime.UpstreamRetryMaxAttempts
+			}
+			if incoming.Runtime.RequestTimeoutSeconds > 0 {
+				next.Runtime.RequestTimeoutSeconds = incoming.Runtime.RequestTimeoutSeconds
+			}
+			if incoming.Runtime.MaxCompletionChoices > 0 {
+				next.Runtime.MaxCompletionChoices = incoming.Runtime.MaxCompletionChoices
+			}
+			if incoming.Runtime.ReadinessCacheSeconds > 0 {
+				next.Runtime.ReadinessCacheSeconds = incoming.Runtime.ReadinessCacheSeconds
+			}
+			if incoming.Runtime.MaxRequestBodyMB > 0 {
+				next.Runtime.MaxRequestBodyMB = incoming.Runtime.MaxRequestBodyMB
+			}
+			if incoming.Runtime.ShutdownGraceSeconds > 0 {
+				next.Runtime.ShutdownGraceSeconds = incoming.Runtime.ShutdownGraceSeconds
+			}
+			if incoming.Runtime.RequireAPIKey != nil {
+				next.Runtime.RequireAPIKey = incoming.Runtime.RequireAPIKey
+			}
+			if incoming.Runtime.StrictSamplingParams != nil {
+				next.Runtime.StrictSamplingParams = incoming.Runtime.StrictSamplingParams
+			}
+			if incoming.Runtime.RemoteImageFetch != nil {
+				next.Runtime.RemoteImageFetch = incoming.Runtime.RemoteImageFetch
+			}
+			if incoming.Runtime.MergeConsecutiveMessages != nil {
+				next.Runtime.MergeConsecutiveMessages = incoming.Runtime.MergeConsecutiveMessages
+			}
+			if incoming.RequestLog.Enabled != nil {
+				next.RequestLog.Enabled = incoming.RequestLog.Enabled
+			}
+			if strings.TrimSpace(incoming.RequestLog.Redaction) != "" {
+				next.RequestLog.Redaction = incoming.RequestLog.Redaction
+			}
+			if incoming.RequestLog.BodySampleRate > 0 {
+				next.RequestLog.BodySampleRate = incoming.RequestLog.BodySampleRate
+			}
+			if incoming.Idempotency.Enabled != nil {
+				next.Idempotency.Enabled = incoming.Idempotency.Enabled
+			}
+			if incoming.Idempotency.HashBody != nil {
+				next.Idempotency.HashBody = incoming.Idempotency.HashBody
+			}
+			if incoming.Idempotency.TTLSeconds > 0 {
+				next.Idempotency.TTLSeconds = incoming.Idempotency.TTLSeconds
+			}
+			if incoming.Idempotency.MaxEntries > 0 {
+				next.Idempotency.MaxEntries = incoming.Idempotency.MaxEntries
+			}
+			if strings.TrimSpace(incoming.ToolPrompt.TemplateFile) != "" {
+				next.ToolPrompt.TemplateFile = incoming.ToolPrompt.TemplateFile
+			}
+			if strings.TrimSpace(incoming.ToolPrompt.Format) != "" {
+				next.ToolPrompt.Format = incoming.ToolPrompt.Format
+			}
+			if incoming.RateLimit.Enabled != nil {
+				next.RateLimit.Enabled = incoming.RateLimit.Enabled
+			}
+			if incoming.RateLimit.RequestsPerMinute > 0 {
+				next.RateLimit.RequestsPerMinute = incoming.RateLimit.RequestsPerMinute
+			}
+			if incoming.RateLimit.TokensPerMinute > 0 {
+				next.RateLimit.TokensPerMinute = incoming.RateLimit.TokensPerMinute
+			}
+			if len(incoming.RateLimit.Users) > 0 {
+				next.RateLimit.Users = incoming.RateLimit.Users
+			}
+			if len(incoming.CORS.AllowedOrigins) > 0 {
+				next.CORS.AllowedOrigins = incoming.CORS.AllowedOrigins
+			}
+			if len(incoming.CORS.AllowedHeaders) > 0 {
+				next.CORS.AllowedHeaders = incoming.CORS.AllowedHeaders
+			}
+			if len(incoming.CORS.AllowedMethods) > 0 {
+				next.CORS.AllowedMethods = incoming.CORS.AllowedMethods
+			}
+			if incoming.Batch.MaxWorkers > 0 {
+				next.Batch.MaxWorkers = incoming.Batch.MaxWorkers
+			}
+			if incoming.Batch.MaxRequests > 0 {
+				next.Batch.MaxRequests = incoming.Batch.MaxRequests
+			}
+			if incoming.Batch.MaxBatches > 0 {
+				next.Batch.MaxBatches = incoming.Batch.MaxBatches
+			}
+			if incoming.Batch.TTLSeconds > 0 {
+				next.Batch.TTLSeconds = incoming.Batch.TTLSeconds
+			}
 		}
 
 		normalizeSettingsConfig(&next)
@@ -0,0 +1,96 @@
+package configmgmt
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+	"sort"
+	"text/template"
+
+	"ds2api/internal/config"
+	"ds2api/internal/toolcall"
+)
+
+// reloadConfig re-reads the config source and the tool prompt template and
+// swaps both in for new requests. The tool prompt is installed inside the
+// store's reload critical section, so concurrent reloads cannot leave the
+// store and the template out of step. Requests already running keep the
+// settings they captured when they started (StandardRequest.ToolPrompt). The
+// candidate is validated first, so a bad file or template leaves the running
+// config in place.
+func (h *Handler) reloadConfig(w http.ResponseWriter, _ *http.Request) {
+	var (
+		toolPrompt   config.ToolPromptConfig
+		toolTemplate *template.Template
+	)
+	prev, next, err := h.Store.Reload(func(candidate *config.Store) error {
+		toolPrompt = candidate.ToolPromptSettings()
+		tmpl, err := toolcall.LoadPromptTemplate(toolPrompt.TemplateFile)
+		if err != nil {
+			return fmt.Errorf("load tool prompt template: %w", err)
+		}
+		toolTemplate = tmpl
+		return nil
+	}, func() {
+		toolcall.ConfigurePrompt(toolPrompt.Format, toolTemplate)
+	})
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]any{"detail": "配置重载失败，当前配置未变更: " + err.Error()})
+		return
+	}
+
+	changed := config.ChangedSections(prev, next)
+	switch {
+	case slices.Contains(changed, "accounts"):
+		h.Pool.Reset()
+	case slices.Contains(changed, "runtime"):
+		h.applyRuntimeLimits()
+	}
+
+	source := "file"
+	if h.Store.IsEnvBacked() {
+		source = "env"
+	}
+	writeJSON(w, http.StatusOK, map[string]any{
+		"success":       true,
+		"message":       "配置已重载",
+		"source":        source,
+		"changed":       changed,
+		"model_aliases": modelAliasChanges(prev.ModelAliases, next.ModelAliases),
+		"tool_prompt": map[string]any{
+			"format":        toolPrompt.Format,
+			"template_file": toolPrompt.TemplateFile,
+		},
+	})
+}
+
+func (h *Handler) applyRuntimeLimits() {
+	maxPer := h.Store.RuntimeAccountMaxInflight()
+	recommended := maxPer
+	if n := len(h.Store.Accounts()); n > 0 {
+		recommended = n * maxPer
+	}
+	h.Pool.ApplyRuntimeLimits(maxPer, h.Store.RuntimeAccountMaxQueue(recommended), h.Store.RuntimeGlobalMaxInflight(recommended))
+}
+
+func modelAliasChanges(prev, next map[string]string) map[string][]string {
+	out := map[string][]string{"added": {}, "removed": {}, "updated": {}}
+	for alias, target := range next {
+		old, ok := prev[alias]
+		switch {
+		case !ok:
+			out["added"] = append(out["added"], alias)
+		case old != target:
+			out["updated"] = append(out["updated"], alias)
+		}
+	}
+	for alias := range prev {
+		if _, ok := next[alias]; !ok {
+			out["removed"] = append(out["removed"], alias)
+		}
+	}
+	for _, names := range out {
+		sort.Strings(names)
+	}
+	return out
+}
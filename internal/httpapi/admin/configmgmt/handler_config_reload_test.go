@@ -0,0 +1,109 @@
+package configmgmt
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+
+	"ds2api/internal/account"
+	"ds2api/internal/config"
+	"ds2api/internal/toolcall"
+)
+
+func newReloadTestHandler(t *testing.T, raw string) (*Handler, string) {
+	t.Helper()
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.json")
+	if err := os.WriteFile(path, []byte(raw), 0o644); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	t.Setenv("DS2API_CONFIG_JSON", "")
+	t.Setenv("DS2API_CONFIG_PATH", path)
+	t.Setenv("DS2API_TOOL_PROMPT_TEMPLATE_FILE", "")
+	store, err := config.LoadStoreWithError()
+	if err != nil {
+		t.Fatalf("load store: %v", err)
+	}
+	t.Cleanup(func() { toolcall.ConfigurePrompt("", nil) })
+	return &Handler{Store: store, Pool: account.NewPool(store)}, dir
+}
+
+func postReload(h *Handler) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	h.reloadConfig(rec, httptest.NewRequest(http.MethodPost, "/admin/reload", nil))
+	return rec
+}
+
+func TestReloadConfigAppliesNewSectionsAndTemplate(t *testing.T) {
+	h, dir := newReloadTestHandler(t, `{"keys":["k1"],"model_aliases":{"fast":"deepseek-v4-flash","old":"deepseek-v4-flash"}}`)
+	tmplPath := filepath.Join(dir, "tools.tmpl")
+	if err := os.WriteFile(tmplPath, []byte(`Tools: {{range .ToolNames}}{{.}} {{end}}`), 0o644); err != nil {
+		t.Fatalf("write template: %v", err)
+	}
+	raw := `{"keys":["k1"],"model_aliases":{"fast":"deepseek-v4-pro","new":"deepseek-v4-flash"},"rate_limit":{"enabled":true,"requests_per_minute":10},"tool_prompt":{"format":"json","template_file":` + strconv.Quote(tmplPath) + `}}`
+	if err := os.WriteFile(h.Store.ConfigPath(), []byte(raw), 0o644); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+
+	rec := postReload(h)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status=%d body=%s", rec.Code, rec.Body.String())
+	}
+	var resp struct {
+		Source       string              `json:"source"`
+		Changed      []string            `json:"changed"`
+		ModelAliases map[string][]string `json:"model_aliases"`
+		ToolPrompt   map[string]string   `json:"tool_prompt"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if resp.Source != "file" || !slices.Equal(resp.Changed, []string{"model_aliases", "rate_limit", "tool_prompt"}) {
+		t.Fatalf("unexpected summary: %s", rec.Body.String())
+	}
+	if !slices.Equal(resp.ModelAliases["added"], []string{"new"}) || !slices.Equal(resp.ModelAliases["removed"], []string{"old"}) || !slices.Equal(resp.ModelAliases["updated"], []string{"fast"}) {
+		t.Fatalf("unexpected alias changes: %#v", resp.ModelAliases)
+	}
+	if resp.ToolPrompt["format"] != "json" || resp.ToolPrompt["template_file"] != tmplPath {
+		t.Fatalf("unexpected tool prompt summary: %#v", resp.ToolPrompt)
+	}
+	if toolcall.ActiveCallFormat() != toolcall.CallFormatJSON {
+		t.Fatalf("tool call format not swapped: %q", toolcall.ActiveCallFormat())
+	}
+	if out, ok := toolcall.RenderPromptTemplate(toolcall.PromptData{ToolNames: []string{"search"}}); !ok || !strings.Contains(out, "Tools: search") {
+		t.Fatalf("reloaded template not active: %q %v", out, ok)
+	}
+	if !*h.Store.(*config.Store).RateLimitSettings().Enabled {
+		t.Fatal("rate limit settings not reloaded")
+	}
+}
+
+func TestReloadConfigRejectsBrokenTemplateWithoutApplying(t *testing.T) {
+	h, dir := newReloadTestHandler(t, `{"keys":["k1"],"model_aliases":{"fast":"deepseek-v4-flash"}}`)
+	tmplPath := filepath.Join(dir, "tools.tmpl")
+	if err := os.WriteFile(tmplPath, []byte(`{{range .ToolNames}`), 0o644); err != nil {
+		t.Fatalf("write template: %v", err)
+	}
+	raw := `{"keys":["k2"],"model_aliases":{"fast":"deepseek-v4-pro"},"tool_prompt":{"format":"json","template_file":` + strconv.Quote(tmplPath) + `}}`
+	if err := os.WriteFile(h.Store.ConfigPath(), []byte(raw), 0o644); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+
+	rec := postReload(h)
+	if rec.Code != http.StatusBadRequest || !strings.Contains(rec.Body.String(), "tool prompt template") {
+		t.Fatalf("status=%d body=%s", rec.Code, rec.Body.String())
+	}
+	snap := h.Store.Snapshot()
+	if !h.Store.(*config.Store).HasAPIKey("k1") || h.Store.(*config.Store).HasAPIKey("k2") || snap.ModelAliases["fast"] != "deepseek-v4-flash" {
+		t.Fatalf("running config changed after a rejected reload: %#v", snap)
+	}
+	if toolcall.ActiveCallFormat() != toolcall.CallFormatDSML || toolcall.HasPromptTemplate() {
+		t.Fatal("tool prompt changed after a rejected reload")
+	}
+}
@@ -82,6 +82,7 @@ func (h *Handler) addKey(w http.ResponseWriter, r *http.Request) {
 	key = strings.TrimSpace(key)
 	name := fieldString(req, "name")
 	remark := fieldString(req, "remark")
+	models := config.NormalizeAPIKeyModels(fieldStringList(req, "models"))
 	if key == "" {
 		writeJSON(w, http.StatusBadRequest, map[string]any{"detail": "Key 不能为空"})
 		return
@@ -92,7 +93,7 @@ func (h *Handler) addKey(w http.ResponseWriter, r *http.Request) {
 				return fmt.Errorf("key 已存在")
 			}
 		}
-		c.APIKeys = append(c.APIKeys, config.APIKey{Key: key, Name: name, Remark: remark})
+		c.APIKeys = append(c.APIKeys, config.APIKey{Key: key, Name: name, Remark: remark, Models: models})
 		return nil
 	})
 	if err != nil {
@@ -116,6 +117,8 @@ func (h *Handler) updateKey(w http.ResponseWriter, r *http.Request) {
 	}
 	name, nameOK := fieldStringOptional(req, "name")
 	remark, remarkOK := fieldStringOptional(req, "remark")
+	_, modelsOK := req["models"]
+	models := config.NormalizeAPIKeyModels(fieldStringList(req, "models"))
 
 	err := h.Store.Update(func(c *config.Config) error {
 		idx := -1
@@ -134,6 +137,9 @@ func (h *Handler) updateKey(w http.ResponseWriter, r *http.Request) {
 		if remarkOK {
 			c.APIKeys[idx].Remark = remark
 		}
+		if modelsOK {
+			c.APIKeys[idx].Models = models
+		}
 		return nil
 	})
 	if err != nil {
@@ -10,6 +10,7 @@ func RegisterRoutes(r chi.Router, h *Handler) {
 	r.Get("/config", h.getConfig)
 	r.Post("/config", h.updateConfig)
 	r.Post("/config/import", h.configImport)
+	r.Post("/reload", h.reloadConfig)
 	r.Get("/config/export", h.configExport)
 	r.Get("/export", h.exportConfig)
 	r.Post("/keys", h.addKey)
@@ -20,6 +21,7 @@ func RegisterRoutes(r chi.Router, h *Handler) {
 
 func (h *Handler) GetConfig(w http.ResponseWriter, r *http.Request)    { h.getConfig(w, r) }
 func (h *Handler) UpdateConfig(w http.ResponseWriter, r *http.Request) { h.updateConfig(w, r) }
+func (h *Handler) ReloadConfig(w http.ResponseWriter, r *http.Request) { h.reloadConfig(w, r) }
 func (h *Handler) ConfigImport(w http.ResponseWriter, r *http.Request) { h.configImport(w, r) }
 func (h *Handler) BatchImport(w http.ResponseWriter, r *http.Request)  { h.batchImport(w, r) }
 func (h *Handler) AddKey(w http.ResponseWriter, r *http.Request)       { h.addKey(w, r) }
@@ -133,6 +133,45 @@ func TestUpdateSettingsAllowsEmptyEmbeddingsProvider(t *testing.T) {
 	}
 }
 
+func TestUpdateSettingsEmbeddingsBackendAndMaskedAPIKey(t *testing.T) {
+	h := newAdminTestHandler(t, `{"keys":["k1"]}`)
+	rejected := map[string]any{"embeddings": map[string]any{"provider": "openai"}}
+	b, _ := json.Marshal(rejected)
+	rec := httptest.NewRecorder()
+	h.updateSettings(rec, httptest.NewRequest(http.MethodPut, "/admin/settings", bytes.NewReader(b)))
+	if rec.Code != http.StatusBadRequest || !bytes.Contains(rec.Body.Bytes(), []byte("embeddings.base_url")) {
+		t.Fatalf("expected openai provider without base_url to be rejected, got %d body=%s", rec.Code, rec.Body.String())
+	}
+
+	payload := map[string]any{"embeddings": map[string]any{
+		"provider":   "openai",
+		"base_url":   "https://embed.example.com/v1",
+		"api_key":    "sk-secret",
+		"max_inputs": 16,
+	}}
+	b, _ = json.Marshal(payload)
+	rec = httptest.NewRecorder()
+	h.updateSettings(rec, httptest.NewRequest(http.MethodPut, "/admin/settings", bytes.NewReader(b)))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d body=%s", rec.Code, rec.Body.String())
+	}
+	if got := h.Store.Snapshot().Embeddings; got.BaseURL != "https://embed.example.com/v1" || got.APIKey != "sk-secret" || got.MaxInputs != 16 {
+		t.Fatalf("unexpected embeddings settings: %#v", got)
+	}
+
+	rec = httptest.NewRecorder()
+	h.getSettings(rec, httptest.NewRequest(http.MethodGet, "/admin/settings", nil))
+	if bytes.Contains(rec.Body.Bytes(), []byte("sk-secret")) {
+		t.Fatalf("expected api key to be masked, got %s", rec.Body.String())
+	}
+	var out map[string]any
+	_ = json.Unmarshal(rec.Body.Bytes(), &out)
+	embeddings, _ := out["embeddings"].(map[string]any)
+	if embeddings["has_api_key"] != true || embeddings["max_tokens"] != float64(300000) {
+		t.Fatalf("unexpected embeddings read payload: %#v", embeddings)
+	}
+}
+
 func TestUpdateSettingsValidationWithMergedRuntimeSnapshot(t *testing.T) {
 	h := newAdminTestHandler(t, `{
 		"keys":["k1"],
@@ -21,7 +21,7 @@ func boolFrom(v any) bool {
 	}
 }
 
-func parseSettingsUpdateRequest(req map[string]any) (*config.AdminConfig, *config.RuntimeConfig, *config.ResponsesConfig, *config.EmbeddingsConfig, *config.AutoDeleteConfig, *config.CurrentInputFileConfig, *config.ThinkingInjectionConfig, map[string]string, error) {
+func parseSettingsUpdateRequest(req map[string]any) (*config.AdminConfig, *config.RuntimeConfig, *config.ResponsesConfig, *config.EmbeddingsConfig, *config.AutoDeleteConfig, *config.CurrentInputFileConfig, *config.ThinkingInjectionConfig, map[string]string, *config.ModelRoutingConfig, error) {
 	var (
 		adminCfg        *config.AdminConfig
 		runtimeCfg      *config.RuntimeConfig
@@ -31,6 +31,7 @@ func parseSettingsUpdateRequest(req map[string]any) (*config.AdminConfig, *confi
 		currentInputCfg *config.CurrentInputFileConfig
 		thinkingInjCfg  *config.ThinkingInjectionConfig
 		aliasMap        map[string]string
+		routingCfg      *config.ModelRoutingConfig
 	)
 
 	if raw, ok := req["admin"].(map[string]any); ok {
@@ -38,7 +39,7 @@ func parseSettingsUpdateRequest(req map[string]any) (*config.AdminConfig, *confi
 		if v, exists := raw["jwt_expire_hours"]; exists {
 			n := intFrom(v)
 			if err := config.ValidateIntRange("admin.jwt_expire_hours", n, 1, 720, true); err != nil {
-				return nil, nil, nil, nil, nil, nil, nil, nil, err
+				return nil, nil, nil, nil, nil, nil, nil, nil, nil, err
 			}
 			cfg.JWTExpireHours = n
 		}
@@ -50,33 +51,119 @@ func parseSettingsUpdateRequest(req map[string]any) (*config.AdminConfig, *confi
 		if v, exists := raw["account_max_inflight"]; exists {
 			n := intFrom(v)
 			if err := config.ValidateIntRange("runtime.account_max_inflight", n, 1, 256, true); err != nil {
-				return nil, nil, nil, nil, nil, nil, nil, nil, err
+				return nil, nil, nil, nil, nil, nil, nil, nil, nil, err
 			}
 			cfg.AccountMaxInflight = n
 		}
 		if v, exists := raw["account_max_queue"]; exists {
 			n := intFrom(v)
 			if err := config.ValidateIntRange("runtime.account_max_queue", n, 1, 200000, true); err != nil {
-				return nil, nil, nil, nil, nil, nil, nil, nil, err
+				return nil, nil, nil, nil, nil, nil, nil, nil, nil, err
 			}
 			cfg.AccountMaxQueue = n
 		}
 		if v, exists := raw["global_max_inflight"]; exists {
 			n := intFrom(v)
 			if err := config.ValidateIntRange("runtime.global_max_inflight", n, 1, 200000, true); err != nil {
-				return nil, nil, nil, nil, nil, nil, nil, nil, err
+				return nil, nil, nil, nil, nil, nil, nil, nil, nil, err
 			}
 			cfg.GlobalMaxInflight = n
 		}
 		if v, exists := raw["token_refresh_interval_hours"]; exists {
 			n := intFrom(v)
 			if err := config.ValidateIntRange("runtime.token_refresh_interval_hours", n, 1, 720, true); err != nil {
-				return nil, nil, nil, nil, nil, nil, nil, nil, err
+				return nil, nil, nil, nil, nil, nil, nil, nil, nil, err
 			}
 			cfg.TokenRefreshIntervalHours = n
 		}
+		if v, exists := raw["upstream_retry_max_attempts"]; exists {
+			n := intFrom(v)
+			if err := config.ValidateIntRange("runtime.upstream_retry_max_attempts", n, 1, 10, true); err != nil {
+				return nil, nil, nil, nil, nil, nil, nil, nil, nil, err
+			}
+			cfg.UpstreamRetryMaxAttempts = n
+		}
+		if v, exists := raw["request_timeout_seconds"]; exists {
+			n := intFrom(v)
+			if err := config.ValidateIntRange("runtime.request_timeout_seconds", n, 30, 86400, true); err != nil {
+				return nil, nil, nil, nil, nil, nil, nil, nil, nil, err
+			}
+			cfg.RequestTimeoutSeconds = n
+		}
+		if v, exists := raw["max_completion_choices"]; exists {
+			n := intFrom(v)
+			if err := config.ValidateIntRange("runtime.max_completion_choices", n, 1, 16, true); err != nil {
+				return nil, nil, nil, nil, nil, nil, nil, nil, nil, err
+			}
+			cfg.MaxCompletionChoices = n
+		}
+		if v, exists := raw["readiness_cache_seconds"]; exists {
+			n := intFrom(v)
+			if err := config.ValidateIntRange("runtime.readiness_cache_seconds", n, 1, 3600, true); err != nil {
+				return nil, nil, nil, nil, nil, nil, nil, nil, nil, err
+			}
+			cfg.ReadinessCacheSeconds = n
+		}
+		if v, exists := raw["max_request_body_mb"]; exists {
+			n := intFrom(v)
+			if err := config.ValidateIntRange("runtime.max_request_body_mb", n, 1, 100, true); err != nil {
+				return nil, nil, nil, nil, nil, nil, nil, nil, nil, err
+			}
+			cfg.MaxRequestBodyMB = n
+		}
+		if v, exists := raw["shutdown_grace_seconds"]; exists {
+			n := intFrom(v)
+			if err := config.ValidateIntRange("runtime.shutdown_grace_seconds", n, 1, 3600, true); err != nil {
+				return nil, nil, nil, nil, nil, nil, nil, nil, nil, err
+			}
+			cfg.ShutdownGraceSeconds = n
+		}
+		if v, exists := raw["upstream_max_inflight"]; exists {
+			n := intFrom(v)
+			if err := config.ValidateIntRange("runtime.upstream_max_inflight", n, 1, 200000, false); err != nil {
+				return nil, nil, nil, nil, nil, nil, nil, nil, nil, err
+			}
+			cfg.UpstreamMaxInflight = n
+		}
+		if v, exists := raw["upstream_max_queue"]; exists {
+			n := intFrom(v)
+			if err := config.ValidateIntRange("runtime.upstream_max_queue", n, 1, 200000, false); err != nil {
+				return nil, nil, nil, nil, nil, nil, nil, nil, nil, err
+			}
+			cfg.UpstreamMaxQueue = n
+		}
+		if v, exists := raw["context_max_tokens"]; exists {
+			n := intFrom(v)
+			if err := config.ValidateIntRange("runtime.context_max_tokens", n, 256, 10000000, false); err != nil {
+				return nil, nil, nil, nil, nil, nil, nil, nil, nil, err
+			}
+			cfg.ContextMaxTokens = n
+		}
+		if v, exists := raw["context_trim_strategy"]; exists && v != nil {
+			strategy := strings.ToLower(strings.TrimSpace(fmt.Sprintf("%v", v)))
+			if err := config.ValidateContextTrimStrategy(strategy); err != nil {
+				return nil, nil, nil, nil, nil, nil, nil, nil, nil, err
+			}
+			cfg.ContextTrimStrategy = strategy
+		}
+		if v, exists := raw["require_api_key"]; exists {
+			b := boolFrom(v)
+			cfg.RequireAPIKey = &b
+		}
+		if v, exists := raw["strict_sampling_params"]; exists {
+			b := boolFrom(v)
+			cfg.StrictSamplingParams = &b
+		}
+		if v, exists := raw["remote_image_fetch"]; exists {
+			b := boolFrom(v)
+			cfg.RemoteImageFetch = &b
+		}
+		if v, exists := raw["merge_consecutive_messages"]; exists {
+			b := boolFrom(v)
+			cfg.MergeConsecutiveMessages = &b
+		}
 		if cfg.AccountMaxInflight > 0 && cfg.GlobalMaxInflight > 0 && cfg.GlobalMaxInflight < cfg.AccountMaxInflight {
-			return nil, nil, nil, nil, nil, nil, nil, nil, fmt.Errorf("runtime.global_max_inflight must be >= runtime.account_max_inflight")
+			return nil, nil, nil, nil, nil, nil, nil, nil, nil, fmt.Errorf("runtime.global_max_inflight must be >= runtime.account_max_inflight")
 		}
 		runtimeCfg = cfg
 	}
@@ -86,7 +173,7 @@ func parseSettingsUpdateRequest(req map[string]any) (*config.AdminConfig, *confi
 		if v, exists := raw["store_ttl_seconds"]; exists {
 			n := intFrom(v)
 			if err := config.ValidateIntRange("responses.store_ttl_seconds", n, 30, 86400, true); err != nil {
-				return nil, nil, nil, nil, nil, nil, nil, nil, err
+				return nil, nil, nil, nil, nil, nil, nil, nil, nil, err
 			}
 			cfg.StoreTTLSeconds = n
 		}
@@ -98,10 +185,33 @@ func parseSettingsUpdateRequest(req map[string]any) (*config.AdminConfig, *confi
 		if v, exists := raw["provider"]; exists {
 			p := strings.TrimSpace(fmt.Sprintf("%v", v))
 			if err := config.ValidateTrimmedString("embeddings.provider", p, false); err != nil {
-				return nil, nil, nil, nil, nil, nil, nil, nil, err
+				return nil, nil, nil, nil, nil, nil, nil, nil, nil, err
 			}
 			cfg.Provider = p
 		}
+		if v, exists := raw["base_url"]; exists && v != nil {
+			cfg.BaseURL = strings.TrimSpace(fmt.Sprintf("%v", v))
+		}
+		if v, exists := raw["api_key"]; exists && v != nil {
+			cfg.APIKey = strings.TrimSpace(fmt.Sprintf("%v", v))
+		}
+		if v, exists := raw["model"]; exists && v != nil {
+			cfg.Model = strings.TrimSpace(fmt.Sprintf("%v", v))
+		}
+		if v, exists := raw["max_inputs"]; exists {
+			n := intFrom(v)
+			if err := config.ValidateIntRange("embeddings.max_inputs", n, 1, 100000, true); err != nil {
+				return nil, nil, nil, nil, nil, nil, nil, nil, nil, err
+			}
+			cfg.MaxInputs = n
+		}
+		if v, exists := raw["max_tokens"]; exists {
+			n := intFrom(v)
+			if err := config.ValidateIntRange("embeddings.max_tokens", n, 1, 10000000, true); err != nil {
+				return nil, nil, nil, nil, nil, nil, nil, nil, nil, err
+			}
+			cfg.MaxTokens = n
+		}
 		embCfg = cfg
 	}
 
@@ -119,12 +229,23 @@ func parseSettingsUpdateRequest(req map[string]any) (*config.AdminConfig, *confi
 		}
 	}
 
+	if raw, ok := req["model_routing"].(map[string]any); ok {
+		cfg := &config.ModelRoutingConfig{}
+		if v, exists := raw["default_model"]; exists && v != nil {
+			cfg.DefaultModel = strings.TrimSpace(fmt.Sprintf("%v", v))
+		}
+		if v, exists := raw["response_model"]; exists && v != nil {
+			cfg.ResponseModel = strings.ToLower(strings.TrimSpace(fmt.Sprintf("%v", v)))
+		}
+		routingCfg = cfg
+	}
+
 	if raw, ok := req["auto_delete"].(map[string]any); ok {
 		cfg := &config.AutoDeleteConfig{}
 		if v, exists := raw["mode"]; exists {
 			mode := strings.ToLower(strings.TrimSpace(fmt.Sprintf("%v", v)))
 			if err := config.ValidateAutoDeleteMode(mode); err != nil {
-				return nil, nil, nil, nil, nil, nil, nil, nil, err
+				return nil, nil, nil, nil, nil, nil, nil, nil, nil, err
 			}
 			if mode == "" {
 				mode = "none"
@@ -146,12 +267,12 @@ func parseSettingsUpdateRequest(req map[string]any) (*config.AdminConfig, *confi
 		if v, exists := raw["min_chars"]; exists {
 			n := intFrom(v)
 			if err := config.ValidateIntRange("current_input_file.min_chars", n, 0, 100000000, true); err != nil {
-				return nil, nil, nil, nil, nil, nil, nil, nil, err
+				return nil, nil, nil, nil, nil, nil, nil, nil, nil, err
 			}
 			cfg.MinChars = n
 		}
 		if err := config.ValidateCurrentInputFileConfig(*cfg); err != nil {
-			return nil, nil, nil, nil, nil, nil, nil, nil, err
+			return nil, nil, nil, nil, nil, nil, nil, nil, nil, err
 		}
 		currentInputCfg = cfg
 	}
@@ -168,5 +289,5 @@ func parseSettingsUpdateRequest(req map[string]any) (*config.AdminConfig, *confi
 		thinkingInjCfg = cfg
 	}
 
-	return adminCfg, runtimeCfg, respCfg, embCfg, autoDeleteCfg, currentInputCfg, thinkingInjCfg, aliasMap, nil
+	return adminCfg, runtimeCfg, respCfg, embCfg, autoDeleteCfg, currentInputCfg, thinkingInjCfg, aliasMap, routingCfg, nil
 }
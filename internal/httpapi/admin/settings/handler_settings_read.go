@@ -12,6 +12,7 @@ import (
 func (h *Handler) getSettings(w http.ResponseWriter, _ *http.Request) {
 	snap := h.Store.Snapshot()
 	recommended := defaultRuntimeRecommended(len(snap.Accounts), h.Store.RuntimeAccountMaxInflight())
+	embeddings := h.Store.EmbeddingsSettings()
 	needsSync := config.IsVercel() && snap.VercelSyncHash != "" && snap.VercelSyncHash != h.computeSyncHash()
 	writeJSON(w, http.StatusOK, map[string]any{
 		"success": true,
@@ -26,9 +27,30 @@ func (h *Handler) getSettings(w http.ResponseWriter, _ *http.Request) {
 			"account_max_queue":            h.Store.RuntimeAccountMaxQueue(recommended),
 			"global_max_inflight":          h.Store.RuntimeGlobalMaxInflight(recommended),
 			"token_refresh_interval_hours": h.Store.RuntimeTokenRefreshIntervalHours(),
+			"upstream_retry_max_attempts":  h.Store.RuntimeUpstreamRetryMaxAttempts(),
+			"request_timeout_seconds":      h.Store.RuntimeRequestTimeoutSeconds(),
+			"max_completion_choices":       h.Store.RuntimeMaxCompletionChoices(),
+			"readiness_cache_seconds":      h.Store.RuntimeReadinessCacheSeconds(),
+			"max_request_body_mb":          h.Store.RuntimeMaxRequestBodyBytes() >> 20,
+			"shutdown_grace_seconds":       h.Store.RuntimeShutdownGraceSeconds(),
+			"upstream_max_inflight":        h.Store.RuntimeUpstreamMaxInflight(),
+			"upstream_max_queue":           h.Store.RuntimeUpstreamMaxQueue(h.Store.RuntimeUpstreamMaxInflight()),
+			"context_max_tokens":           h.Store.RuntimeContextMaxTokens(),
+			"context_trim_strategy":        h.Store.RuntimeContextTrimStrategy(),
+			"require_api_key":              h.Store.RuntimeRequireAPIKey(),
+			"strict_sampling_params":       h.Store.RuntimeStrictSamplingParams(),
+			"remote_image_fetch":           h.Store.RuntimeRemoteImageFetch(),
+			"merge_consecutive_messages":   h.Store.RuntimeMergeConsecutiveMessages(),
+		},
+		"responses": snap.Responses,
+		"embeddings": map[string]any{
+			"provider":    snap.Embeddings.Provider,
+			"base_url":    snap.Embeddings.BaseURL,
+			"model":       snap.Embeddings.Model,
+			"has_api_key": strings.TrimSpace(snap.Embeddings.APIKey) != "",
+			"max_inputs":  embeddings.MaxInputs,
+			"max_tokens":  embeddings.MaxTokens,
 		},
-		"responses":   snap.Responses,
-		"embeddings":  snap.Embeddings,
 		"auto_delete": snap.AutoDelete,
 		"current_input_file": map[string]any{
 			"enabled":   h.Store.CurrentInputFileEnabled(),
@@ -39,7 +61,11 @@ func (h *Handler) getSettings(w http.ResponseWriter, _ *http.Request) {
 			"prompt":         h.Store.ThinkingInjectionPrompt(),
 			"default_prompt": promptcompat.DefaultThinkingInjectionPrompt,
 		},
-		"model_aliases":     snap.ModelAliases,
+		"model_aliases": snap.ModelAliases,
+		"model_routing": map[string]any{
+			"default_model":  snap.ModelRouting.DefaultModel,
+			"response_model": h.Store.ModelResponseMode(),
+		},
 		"env_backed":        h.Store.IsEnvBacked(),
 		"needs_vercel_sync": needsSync,
 	})
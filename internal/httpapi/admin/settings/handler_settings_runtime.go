@@ -17,6 +17,36 @@ func validateMergedRuntimeSettings(current config.RuntimeConfig, incoming *confi
 		if incoming.TokenRefreshIntervalHours > 0 {
 			merged.TokenRefreshIntervalHours = incoming.TokenRefreshIntervalHours
 		}
+		if incoming.UpstreamRetryMaxAttempts > 0 {
+			merged.UpstreamRetryMaxAttempts = incoming.UpstreamRetryMaxAttempts
+		}
+		if incoming.RequestTimeoutSeconds > 0 {
+			merged.RequestTimeoutSeconds = incoming.RequestTimeoutSeconds
+		}
+		if incoming.MaxCompletionChoices > 0 {
+			merged.MaxCompletionChoices = incoming.MaxCompletionChoices
+		}
+		if incoming.ReadinessCacheSeconds > 0 {
+			merged.ReadinessCacheSeconds = incoming.ReadinessCacheSeconds
+		}
+		if incoming.MaxRequestBodyMB > 0 {
+			merged.MaxRequestBodyMB = incoming.MaxRequestBodyMB
+		}
+		if incoming.ShutdownGraceSeconds > 0 {
+			merged.ShutdownGraceSeconds = incoming.ShutdownGraceSeconds
+		}
+		if incoming.UpstreamMaxInflight > 0 {
+			merged.UpstreamMaxInflight = incoming.UpstreamMaxInflight
+		}
+		if incoming.UpstreamMaxQueue > 0 {
+			merged.UpstreamMaxQueue = incoming.UpstreamMaxQueue
+		}
+		if incoming.ContextMaxTokens > 0 {
+			merged.ContextMaxTokens = incoming.ContextMaxTokens
+		}
+		if incoming.ContextTrimStrategy != "" {
+			merged.ContextTrimStrategy = incoming.ContextTrimStrategy
+		}
 	}
 	return validateRuntimeSettings(merged)
 }
@@ -17,7 +17,7 @@ func (h *Handler) updateSettings(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	adminCfg, runtimeCfg, responsesCfg, embeddingsCfg, autoDeleteCfg, currentInputCfg, thinkingInjCfg, aliasMap, err := parseSettingsUpdateRequest(req)
+	adminCfg, runtimeCfg, responsesCfg, embeddingsCfg, autoDeleteCfg, currentInputCfg, thinkingInjCfg, aliasMap, routingCfg, err := parseSettingsUpdateRequest(req)
 	if err != nil {
 		writeJSON(w, http.StatusBadRequest, map[string]any{"detail": err.Error()})
 		return
@@ -28,6 +28,36 @@ func (h *Handler) updateSettings(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 	}
+	defaultModelSet := hasNestedSettingsKey(req, "model_routing", "default_model")
+	responseModelSet := hasNestedSettingsKey(req, "model_routing", "response_model")
+	if routingCfg != nil {
+		snap := h.Store.Snapshot()
+		merged := snap.ModelRouting
+		if defaultModelSet {
+			merged.DefaultModel = routingCfg.DefaultModel
+		}
+		if responseModelSet {
+			merged.ResponseModel = routingCfg.ResponseModel
+		}
+		aliases := snap.ModelAliases
+		if aliasMap != nil {
+			aliases = aliasMap
+		}
+		if err := config.ValidateModelRoutingConfig(merged, aliases); err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]any{"detail": err.Error()})
+			return
+		}
+	}
+	embeddingsBaseURLSet := hasNestedSettingsKey(req, "embeddings", "base_url")
+	embeddingsAPIKeySet := hasNestedSettingsKey(req, "embeddings", "api_key")
+	embeddingsModelSet := hasNestedSettingsKey(req, "embeddings", "model")
+	if embeddingsCfg != nil {
+		merged := mergeEmbeddingsSettings(h.Store.Snapshot().Embeddings, embeddingsCfg, embeddingsBaseURLSet, embeddingsAPIKeySet, embeddingsModelSet)
+		if err := config.ValidateEmbeddingsConfig(merged); err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]any{"detail": err.Error()})
+			return
+		}
+	}
 	currentInputEnabledSet := hasNestedSettingsKey(req, "current_input_file", "enabled")
 	currentInputMinCharsSet := hasNestedSettingsKey(req, "current_input_file", "min_chars")
 	thinkingInjectionEnabledSet := hasNestedSettingsKey(req, "thinking_injection", "enabled")
@@ -52,12 +82,54 @@ func (h *Handler) updateSettings(w http.ResponseWriter, r *http.Request) {
 			if runtimeCfg.TokenRefreshIntervalHours > 0 {
 				c.Runtime.TokenRefreshIntervalHours = runtimeCfg.TokenRefreshIntervalHours
 			}
+			if runtimeCfg.UpstreamRetryMaxAttempts > 0 {
+				c.Runtime.UpstreamRetryMaxAttempts = runtimeCfg.UpstreamRetryMaxAttempts
+			}
+			if runtimeCfg.RequestTimeoutSeconds > 0 {
+				c.Runtime.RequestTimeoutSeconds = runtimeCfg.RequestTimeoutSeconds
+			}
+			if runtimeCfg.MaxCompletionChoices > 0 {
+				c.Runtime.MaxCompletionChoices = runtimeCfg.MaxCompletionChoices
+			}
+			if runtimeCfg.ReadinessCacheSeconds > 0 {
+				c.Runtime.ReadinessCacheSeconds = runtimeCfg.ReadinessCacheSeconds
+			}
+			if runtimeCfg.MaxRequestBodyMB > 0 {
+				c.Runtime.MaxRequestBodyMB = runtimeCfg.MaxRequestBodyMB
+			}
+			if runtimeCfg.ShutdownGraceSeconds > 0 {
+				c.Runtime.ShutdownGraceSeconds = runtimeCfg.ShutdownGraceSeconds
+			}
+			if runtimeCfg.UpstreamMaxInflight > 0 {
+				c.Runtime.UpstreamMaxInflight = runtimeCfg.UpstreamMaxInflight
+			}
+			if runtimeCfg.UpstreamMaxQueue > 0 {
+				c.Runtime.UpstreamMaxQueue = runtimeCfg.UpstreamMaxQueue
+			}
+			if runtimeCfg.ContextMaxTokens > 0 {
+				c.Runtime.ContextMaxTokens = runtimeCfg.ContextMaxTokens
+			}
+			if runtimeCfg.ContextTrimStrategy != "" {
+				c.Runtime.ContextTrimStrategy = runtimeCfg.ContextTrimStrategy
+			}
+			if runtimeCfg.RequireAPIKey != nil {
+				c.Runtime.RequireAPIKey = runtimeCfg.RequireAPIKey
+			}
+			if runtimeCfg.StrictSamplingParams != nil {
+				c.Runtime.StrictSamplingParams = runtimeCfg.StrictSamplingParams
+			}
+			if runtimeCfg.RemoteImageFetch != nil {
+				c.Runtime.RemoteImageFetch = runtimeCfg.RemoteImageFetch
+			}
+			if runtimeCfg.MergeConsecutiveMessages != nil {
+				c.Runtime.MergeConsecutiveMessages = runtimeCfg.MergeConsecutiveMessages
+			}
 		}
 		if responsesCfg != nil && responsesCfg.StoreTTLSeconds > 0 {
 			c.Responses.StoreTTLSeconds = responsesCfg.StoreTTLSeconds
 		}
-		if embeddingsCfg != nil && strings.TrimSpace(embeddingsCfg.Provider) != "" {
-			c.Embeddings.Provider = strings.TrimSpace(embeddingsCfg.Provider)
+		if embeddingsCfg != nil {
+			c.Embeddings = mergeEmbeddingsSettings(c.Embeddings, embeddingsCfg, embeddingsBaseURLSet, embeddingsAPIKeySet, embeddingsModelSet)
 		}
 		if autoDeleteCfg != nil {
 			c.AutoDelete.Mode = autoDeleteCfg.Mode
@@ -82,6 +154,14 @@ func (h *Handler) updateSettings(w http.ResponseWriter, r *http.Request) {
 		if aliasMap != nil {
 			c.ModelAliases = aliasMap
 		}
+		if routingCfg != nil {
+			if defaultModelSet {
+				c.ModelRouting.DefaultModel = routingCfg.DefaultModel
+			}
+			if responseModelSet {
+				c.ModelRouting.ResponseModel = routingCfg.ResponseModel
+			}
+		}
 		return nil
 	}); err != nil {
 		writeJSON(w, http.StatusInternalServerError, map[string]any{"detail": err.Error()})
@@ -133,6 +213,31 @@ func (h *Handler) updateSettingsPassword(w http.ResponseWriter, r *http.Request)
 	})
 }
 
+// mergeEmbeddingsSettings applies an embeddings settings update. The provider
+// and limits only change when a value is given; base_url, api_key and model
+// follow key presence so they can be cleared.
+func mergeEmbeddingsSettings(current config.EmbeddingsConfig, update *config.EmbeddingsConfig, baseURLSet, apiKeySet, modelSet bool) config.EmbeddingsConfig {
+	if p := strings.TrimSpace(update.Provider); p != "" {
+		current.Provider = p
+	}
+	if baseURLSet {
+		current.BaseURL = update.BaseURL
+	}
+	if apiKeySet {
+		current.APIKey = update.APIKey
+	}
+	if modelSet {
+		current.Model = update.Model
+	}
+	if update.MaxInputs > 0 {
+		current.MaxInputs = update.MaxInputs
+	}
+	if update.MaxTokens > 0 {
+		current.MaxTokens = update.MaxTokens
+	}
+	return current
+}
+
 func hasNestedSettingsKey(req map[string]any, section, key string) bool {
 	raw, ok := req[section].(map[string]any)
 	if !ok {
@@ -19,6 +19,7 @@ type ConfigStore interface {
 	UpdateAccountTestStatus(identifier, status string) error
 	AccountTestStatus(identifier string) (string, bool)
 	Update(mutator func(*config.Config) error) error
+	Reload(check func(next *config.Store) error, apply func()) (config.Config, config.Config, error)
 	ExportJSONAndBase64() (string, string, error)
 	IsEnvBacked() bool
 	IsEnvWritebackEnabled() bool
@@ -32,6 +33,22 @@ type ConfigStore interface {
 	RuntimeAccountMaxQueue(defaultSize int) int
 	RuntimeGlobalMaxInflight(defaultSize int) int
 	RuntimeTokenRefreshIntervalHours() int
+	RuntimeUpstreamRetryMaxAttempts() int
+	RuntimeRequestTimeoutSeconds() int
+	RuntimeMaxCompletionChoices() int
+	RuntimeReadinessCacheSeconds() int
+	RuntimeMaxRequestBodyBytes() int64
+	RuntimeShutdownGraceSeconds() int
+	RuntimeUpstreamMaxInflight() int
+	RuntimeUpstreamMaxQueue(defaultSize int) int
+	RuntimeContextMaxTokens() int
+	RuntimeContextTrimStrategy() string
+	RuntimeRequireAPIKey() bool
+	RuntimeStrictSamplingParams() bool
+	RuntimeRemoteImageFetch() bool
+	RuntimeMergeConsecutiveMessages() bool
+	ModelResponseMode() string
+	EmbeddingsSettings() config.EmbeddingsConfig
 	AutoDeleteMode() string
 	CurrentInputFileEnabled() bool
 	CurrentInputFileMinChars() int
@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"slices"
 	"strconv"
 	"strings"
 
@@ -51,6 +52,9 @@ func FieldString(m map[string]any, key string) string {
 func FieldStringOptional(m map[string]any, key string) (string, bool) {
 	return fieldStringOptional(m, key)
 }
+func FieldStringList(m map[string]any, key string) []string {
+	return fieldStringList(m, key)
+}
 func StatusOr(v int, d int) int { return statusOr(v, d) }
 func AccountMatchesIdentifier(acc config.Account, identifier string) bool {
 	return accountMatchesIdentifier(acc, identifier)
@@ -193,6 +197,7 @@ func toAPIKeys(v any) ([]config.APIKey, bool) {
 				Key:    key,
 				Name:   fieldString(x, "name"),
 				Remark: fieldString(x, "remark"),
+				Models: fieldStringList(x, "models"),
 			})
 		default:
 			key := strings.TrimSpace(fmt.Sprintf("%v", item))
@@ -214,11 +219,16 @@ func normalizeAPIKeyForStorage(item config.APIKey) config.APIKey {
 		Key:    strings.TrimSpace(item.Key),
 		Name:   strings.TrimSpace(item.Name),
 		Remark: strings.TrimSpace(item.Remark),
+		Models: config.NormalizeAPIKeyModels(item.Models),
 	}
 }
 
 func apiKeyHasMetadata(item config.APIKey) bool {
-	return strings.TrimSpace(item.Name) != "" || strings.TrimSpace(item.Remark) != ""
+	return strings.TrimSpace(item.Name) != "" || strings.TrimSpace(item.Remark) != "" || len(item.Models) > 0
+}
+
+func sameAPIKeyRecord(a, b config.APIKey) bool {
+	return a.Key == b.Key && a.Name == b.Name && a.Remark == b.Remark && slices.Equal(a.Models, b.Models)
 }
 
 func mergeAPIKeysPreferStructured(existing, incoming []config.APIKey) ([]config.APIKey, int) {
@@ -249,7 +259,7 @@ func mergeAPIKeysPreferStructured(existing, incoming []config.APIKey) ([]config.
 		if idx, ok := index[item.Key]; ok {
 			keep := merged[idx]
 			next := mergeAPIKeyRecord(keep, item)
-			if next != keep {
+			if !sameAPIKeyRecord(next, keep) {
 				merged[idx] = next
 				imported++
 			}
@@ -289,6 +299,25 @@ func fieldString(m map[string]any, key string) string {
 	return strings.TrimSpace(fmt.Sprintf("%v", v))
 }
 
+// fieldStringList reads a string array, or a comma-separated string, from m.
+func fieldStringList(m map[string]any, key string) []string {
+	switch v := m[key].(type) {
+	case []any:
+		out := make([]string, 0, len(v))
+		for _, item := range v {
+			if s := strings.TrimSpace(fmt.Sprintf("%v", item)); s != "" {
+				out = append(out, s)
+			}
+		}
+		return out
+	case []string:
+		return v
+	case string:
+		return strings.Split(v, ",")
+	}
+	return nil
+}
+
 func fieldStringOptional(m map[string]any, key string) (string, bool) {
 	v, ok := m[key]
 	if !ok || v == nil {
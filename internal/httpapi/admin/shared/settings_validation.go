@@ -12,6 +12,9 @@ func normalizeSettingsConfig(c *config.Config) {
 	}
 	c.Admin.PasswordHash = strings.TrimSpace(c.Admin.PasswordHash)
 	c.Embeddings.Provider = strings.TrimSpace(c.Embeddings.Provider)
+	c.Embeddings.BaseURL = strings.TrimSpace(c.Embeddings.BaseURL)
+	c.Embeddings.APIKey = strings.TrimSpace(c.Embeddings.APIKey)
+	c.Embeddings.Model = strings.TrimSpace(c.Embeddings.Model)
 }
 
 func NormalizeSettingsConfig(c *config.Config) {
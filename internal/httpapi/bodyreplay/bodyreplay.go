@@ -0,0 +1,109 @@
+// Package bodyreplay lets middleware inspect a request body before the
+// handler reads it. The body is read and parsed once per request; every
+// middleware that asks again gets the same bytes, and the handler still
+// reads them from r.Body.
+package bodyreplay
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+type ctxKey struct{}
+
+// Body is a request body read ahead of the handler.
+type Body struct {
+	// Raw holds the bytes read before Err stopped the read, if it did.
+	Raw []byte
+	// Err is the read error; the handler sees it after Raw.
+	Err error
+
+	closer io.Closer
+	once   sync.Once
+	parsed map[string]any
+}
+
+// Capture returns the body of r, reading it on the first call for the
+// request. r.Body is reset to a replay of the bytes, and the returned request
+// carries the Body so later middleware reuse it instead of reading again.
+func Capture(r *http.Request) (*http.Request, *Body) {
+	if b, ok := r.Context().Value(ctxKey{}).(*Body); ok {
+		r.Body = b.replay()
+		return r, b
+	}
+	raw, err := io.ReadAll(r.Body)
+	b := &Body{Raw: raw, Err: err, closer: r.Body}
+	r = r.WithContext(context.WithValue(r.Context(), ctxKey{}, b))
+	r.Body = b.replay()
+	return r, b
+}
+
+// JSON returns the body decoded as a JSON object, parsed on first use. It is
+// nil for unreadable bodies and for anything but an object.
+func (b *Body) JSON() map[string]any {
+	b.once.Do(func() {
+		if b.Err == nil {
+			_ = json.Unmarshal(b.Raw, &b.parsed)
+		}
+	})
+	return b.parsed
+}
+
+func (b *Body) replay() io.ReadCloser {
+	r := io.Reader(bytes.NewReader(b.Raw))
+	if b.Err != nil {
+		r = io.MultiReader(r, errReader{err: b.Err})
+	}
+	return &replayBody{Reader: r, closer: b.closer}
+}
+
+// Eligible reports an API call that carries a request body worth
+// inspecting. Admin routes and multipart file uploads are skipped.
+func Eligible(r *http.Request) bool {
+	if r == nil || r.URL == nil || r.Body == nil || r.Method != http.MethodPost {
+		return false
+	}
+	path := r.URL.Path
+	if path == "/admin" || strings.HasPrefix(path, "/admin/") {
+		return false
+	}
+	return !strings.HasPrefix(strings.ToLower(r.Header.Get("Content-Type")), "multipart/")
+}
+
+// User returns the caller-supplied end-user identifier: OpenAI's `user`
+// field or Claude's metadata.user_id.
+func User(req map[string]any) string {
+	if user, ok := req["user"].(string); ok && strings.TrimSpace(user) != "" {
+		return strings.TrimSpace(user)
+	}
+	if meta, ok := req["metadata"].(map[string]any); ok {
+		if user, ok := meta["user_id"].(string); ok {
+			return strings.TrimSpace(user)
+		}
+	}
+	return ""
+}
+
+// replayBody hands the already-read body to the handler and closes the
+// original, surfacing the original read error after the bytes it produced.
+type replayBody struct {
+	io.Reader
+	closer io.Closer
+}
+
+func (b *replayBody) Close() error {
+	return b.closer.Close()
+}
+
+type errReader struct {
+	err error
+}
+
+func (r errReader) Read([]byte) (int, error) {
+	return 0, r.err
+}
@@ -0,0 +1,55 @@
+package bodyreplay
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type countingReader struct {
+	io.Reader
+	reads int
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	c.reads++
+	return c.Reader.Read(p)
+}
+
+func TestCaptureReadsOnceAndReplaysForEveryCaller(t *testing.T) {
+	src := &countingReader{Reader: strings.NewReader(`{"user":" alice ","model":"m"}`)}
+	req := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", io.NopCloser(src))
+	req, first := Capture(req)
+	reads := src.reads
+	req, second := Capture(req)
+	if first != second || src.reads != reads {
+		t.Fatalf("expected the second capture to reuse the first read")
+	}
+	if first.JSON()["model"] != "m" || User(first.JSON()) != "alice" {
+		t.Fatalf("unexpected parse: %#v", first.JSON())
+	}
+	got, err := io.ReadAll(req.Body)
+	if err != nil || string(got) != `{"user":" alice ","model":"m"}` {
+		t.Fatalf("expected the handler to read the full body, got %q %v", got, err)
+	}
+}
+
+func TestCaptureSurfacesReadErrorAfterBytes(t *testing.T) {
+	boom := errors.New("boom")
+	req := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", io.NopCloser(io.MultiReader(strings.NewReader("{"), &failingReader{err: boom})))
+	req, body := Capture(req)
+	if body.JSON() != nil {
+		t.Fatalf("expected no parse for an unreadable body")
+	}
+	got, err := io.ReadAll(req.Body)
+	if string(got) != "{" || !errors.Is(err, boom) {
+		t.Fatalf("expected the bytes then the read error, got %q %v", got, err)
+	}
+}
+
+type failingReader struct{ err error }
+
+func (f *failingReader) Read([]byte) (int, error) { return 0, f.err }
@@ -11,6 +11,7 @@ import (
 
 	"ds2api/internal/auth"
 	"ds2api/internal/chathistory"
+	"ds2api/internal/config"
 	dsclient "ds2api/internal/deepseek/client"
 )
 
@@ -21,8 +22,12 @@ type claudeHistoryConfig struct {
 }
 
 func (m claudeHistoryConfig) ModelAliases() map[string]string { return m.aliases }
-func (claudeHistoryConfig) CurrentInputFileEnabled() bool     { return false }
-func (claudeHistoryConfig) CurrentInputFileMinChars() int     { return 0 }
+func (claudeHistoryConfig) ModelFallbacks(string) []string    { return nil }
+func (claudeHistoryConfig) FallbackBackend(string) (config.APIBackendConfig, bool) {
+	return config.APIBackendConfig{}, false
+}
+func (claudeHistoryConfig) CurrentInputFileEnabled() bool { return false }
+func (claudeHistoryConfig) CurrentInputFileMinChars() int { return 0 }
 
 func (claudeCurrentInputAuth) Determine(*http.Request) (*auth.RequestAuth, error) {
 	return &auth.RequestAuth{
@@ -1,14 +1,22 @@
 package claude
 
-import "testing"
+import (
+	"testing"
+
+	"ds2api/internal/config"
+)
 
 type mockClaudeConfig struct {
 	aliases map[string]string
 }
 
 func (m mockClaudeConfig) ModelAliases() map[string]string { return m.aliases }
-func (mockClaudeConfig) CurrentInputFileEnabled() bool     { return true }
-func (mockClaudeConfig) CurrentInputFileMinChars() int     { return 0 }
+func (mockClaudeConfig) ModelFallbacks(string) []string    { return nil }
+func (mockClaudeConfig) FallbackBackend(string) (config.APIBackendConfig, bool) {
+	return config.APIBackendConfig{}, false
+}
+func (mockClaudeConfig) CurrentInputFileEnabled() bool { return true }
+func (mockClaudeConfig) CurrentInputFileMinChars() int { return 0 }
 
 func TestNormalizeClaudeRequestUsesGlobalAliasMapping(t *testing.T) {
 	req := map[string]any{
@@ -1,12 +1,18 @@
 package claude
 
-import "net/http"
+import (
+	"net/http"
+
+	"ds2api/internal/httpapi/requestctx"
+)
 
 func writeClaudeError(w http.ResponseWriter, status int, message string) {
 	code := "invalid_request"
 	switch status {
 	case http.StatusUnauthorized:
 		code = "authentication_failed"
+	case http.StatusForbidden:
+		code = "model_not_allowed"
 	case http.StatusTooManyRequests:
 		code = "rate_limit_exceeded"
 	case http.StatusNotFound:
@@ -14,12 +20,12 @@ func writeClaudeError(w http.ResponseWriter, status int, message string) {
 	case http.StatusInternalServerError:
 		code = "internal_error"
 	}
-	writeJSON(w, status, map[string]any{
+	writeJSON(w, status, requestctx.WithTraceID(w, map[string]any{
 		"error": map[string]any{
 			"type":    "invalid_request_error",
 			"message": message,
 			"code":    code,
 			"param":   nil,
 		},
-	})
+	}))
 }
@@ -18,6 +18,7 @@ import (
 	claudefmt "ds2api/internal/format/claude"
 	"ds2api/internal/httpapi/openai/history"
 	"ds2api/internal/httpapi/requestbody"
+	"ds2api/internal/metrics"
 	"ds2api/internal/promptcompat"
 	"ds2api/internal/responsehistory"
 	streamengine "ds2api/internal/stream"
@@ -56,20 +57,16 @@ func isClaudeVercelProxyRequest(r *http.Request) bool {
 }
 
 func (h *Handler) handleClaudeDirect(w http.ResponseWriter, r *http.Request) bool {
-	raw, err := io.ReadAll(r.Body)
-	if err != nil {
-		if errors.Is(err, requestbody.ErrInvalidUTF8Body) {
-			writeClaudeError(w, http.StatusBadRequest, "invalid json")
+	// Decode straight from the body so the raw bytes are not held twice.
+	var req map[string]any
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		if requestbody.IsTooLarge(err) {
+			writeClaudeError(w, http.StatusRequestEntityTooLarge, "request body too large")
 		} else {
-			writeClaudeError(w, http.StatusBadRequest, "invalid body")
+			writeClaudeError(w, http.StatusBadRequest, "invalid json")
 		}
 		return true
 	}
-	var req map[string]any
-	if err := json.Unmarshal(raw, &req); err != nil {
-		writeClaudeError(w, http.StatusBadRequest, "invalid json")
-		return true
-	}
 	norm, err := normalizeClaudeRequest(h.Store, req)
 	if err != nil {
 		writeClaudeError(w, http.StatusBadRequest, err.Error())
@@ -82,6 +79,10 @@ func (h *Handler) handleClaudeDirect(w http.ResponseWriter, r *http.Request) boo
 		return true
 	}
 	defer h.Auth.Release(a)
+	if !a.AllowsModel(norm.Standard.RequestedModel, norm.Standard.ResolvedModel) {
+		writeClaudeError(w, http.StatusForbidden, (&promptcompat.ModelNotAllowedError{Model: norm.Standard.RequestedModel}).Error())
+		return true
+	}
 	stdReq, err := h.applyCurrentInputFile(r.Context(), a, norm.Standard)
 	if err != nil {
 		status, message := mapCurrentInputFileError(err)
@@ -151,7 +152,9 @@ func (h *Handler) handleClaudeDirectStream(w http.ResponseWriter, r *http.Reques
 func (h *Handler) proxyViaOpenAI(w http.ResponseWriter, r *http.Request, store ConfigReader) bool {
 	raw, err := io.ReadAll(r.Body)
 	if err != nil {
-		if errors.Is(err, requestbody.ErrInvalidUTF8Body) {
+		if requestbody.IsTooLarge(err) {
+			writeClaudeError(w, http.StatusRequestEntityTooLarge, "request body too large")
+		} else if errors.Is(err, requestbody.ErrInvalidUTF8Body) {
 			writeClaudeError(w, http.StatusBadRequest, "invalid json")
 		} else {
 			writeClaudeError(w, http.StatusBadRequest, "invalid body")
@@ -321,7 +324,7 @@ func (h *Handler) handleClaudeStreamRealtime(w http.ResponseWriter, r *http.Requ
 	rc := http.NewResponseController(w)
 	_, canFlush := w.(http.Flusher)
 	if !canFlush {
-		config.Logger.Warn("[claude_stream] response writer does not support flush; streaming may be buffered")
+		config.Logger.WarnContext(r.Context(), "[claude_stream] response writer does not support flush; streaming may be buffered")
 	}
 
 	streamRuntime := newClaudeStreamRuntime(
@@ -358,6 +361,9 @@ func (h *Handler) handleClaudeStreamRealtime(w http.ResponseWriter, r *http.Requ
 		},
 		OnParsed:   streamRuntime.onParsed,
 		OnFinalize: streamRuntime.onFinalize,
+		OnContextDone: func() {
+			streamRuntime.markContextCancelled(r.Context())
+		},
 	})
 }
 
@@ -379,7 +385,7 @@ func (h *Handler) handleClaudeStreamRealtimeWithRetry(w http.ResponseWriter, r *
 	rc := http.NewResponseController(w)
 	_, canFlush := w.(http.Flusher)
 	if !canFlush {
-		config.Logger.Warn("[claude_stream] response writer does not support flush; streaming may be buffered")
+		config.Logger.WarnContext(r.Context(), "[claude_stream] response writer does not support flush; streaming may be buffered")
 	}
 
 	streamRuntime := newClaudeStreamRuntime(
@@ -396,6 +402,7 @@ func (h *Handler) handleClaudeStreamRealtimeWithRetry(w http.ResponseWriter, r *
 		promptTokenText,
 		historySession,
 	)
+	streamRuntime.sieve.SetCallFormat(stdReq.ToolPrompt.Format)
 	streamRuntime.sendMessageStart()
 
 	completionruntime.ExecuteStreamWithRetry(r.Context(), h.DS, a, resp, payload, pow, completionruntime.StreamRetryOptions{
@@ -423,6 +430,7 @@ func (h *Handler) handleClaudeStreamRealtimeWithRetry(w http.ResponseWriter, r *
 			streamRuntime.sendErrorWithCode(status, strings.TrimSpace(message), code)
 		},
 	})
+	metrics.AddGeneratedTokens(r.Context(), streamRuntime.generatedTokens)
 }
 
 func (h *Handler) consumeClaudeStreamAttempt(r *http.Request, resp *http.Response, streamRuntime *claudeStreamRuntime, thinkingEnabled bool, allowDeferEmpty bool) (bool, bool) {
@@ -451,6 +459,9 @@ func (h *Handler) consumeClaudeStreamAttempt(r *http.Request, resp *http.Respons
 			scannerErr = err
 		},
 	})
+	if streamRuntime.markContextCancelled(r.Context()) {
+		return true, false
+	}
 	if string(finalReason) == "upstream_error" {
 		if streamRuntime.history != nil {
 			streamRuntime.history.Error(500, streamRuntime.upstreamErr, "upstream_error", responsehistory.ThinkingForArchive(streamRuntime.rawThinking.String(), streamRuntime.toolDetectionThinking.String(), streamRuntime.thinking.String()), responsehistory.TextForArchive(streamRuntime.rawText.String(), streamRuntime.text.String()))
@@ -3,6 +3,8 @@ package claude
 import (
 	"strings"
 	"testing"
+
+	"ds2api/internal/toolcall"
 )
 
 // ─── normalizeClaudeMessages ─────────────────────────────────────────
@@ -318,7 +320,7 @@ func TestBuildClaudeToolPromptSingleTool(t *testing.T) {
 			},
 		},
 	}
-	prompt := buildClaudeToolPrompt(tools)
+	prompt := buildClaudeToolPrompt(tools, toolcall.ActivePrompt())
 	if prompt == "" {
 		t.Fatal("expected non-empty prompt")
 	}
@@ -342,7 +344,7 @@ func TestBuildClaudeToolPromptMultipleTools(t *testing.T) {
 		map[string]any{"name": "tool1", "description": "desc1"},
 		map[string]any{"name": "tool2", "description": "desc2"},
 	}
-	prompt := buildClaudeToolPrompt(tools)
+	prompt := buildClaudeToolPrompt(tools, toolcall.ActivePrompt())
 	if !containsStr(prompt, "tool1") || !containsStr(prompt, "tool2") {
 		t.Fatalf("expected both tools in prompt")
 	}
@@ -364,21 +366,21 @@ func TestBuildClaudeToolPromptSupportsOpenAIStyleFunctionTool(t *testing.T) {
 			},
 		},
 	}
-	prompt := buildClaudeToolPrompt(tools)
+	prompt := buildClaudeToolPrompt(tools, toolcall.ActivePrompt())
 	if !containsStr(prompt, "Tool: search") {
 		t.Fatalf("expected OpenAI-style function tool name in prompt, got: %q", prompt)
 	}
 	if !containsStr(prompt, "Search via function tool") {
 		t.Fatalf("expected OpenAI-style function tool description in prompt, got: %q", prompt)
 	}
-	if !containsStr(prompt, "\"q\"") {
-		t.Fatalf("expected parameters schema serialized in prompt, got: %q", prompt)
+	if !containsStr(prompt, "- q (string)") {
+		t.Fatalf("expected parameters schema rendered in prompt, got: %q", prompt)
 	}
 }
 
 func TestBuildClaudeToolPromptSkipsNonMap(t *testing.T) {
 	tools := []any{"not a map"}
-	prompt := buildClaudeToolPrompt(tools)
+	prompt := buildClaudeToolPrompt(tools, toolcall.ActivePrompt())
 	// No valid tools → empty prompt
 	if prompt != "" {
 		t.Fatalf("expected empty prompt for non-map tools, got: %q", prompt)
@@ -143,9 +143,10 @@ func extractClaudeThinkingBlockText(block map[string]any) string {
 	return ""
 }
 
-func buildClaudeToolPrompt(tools []any) string {
+func buildClaudeToolPrompt(tools []any, settings toolcall.PromptSettings) string {
 	toolSchemas := make([]string, 0, len(tools))
 	names := make([]string, 0, len(tools))
+	promptTools := make([]toolcall.PromptTool, 0, len(tools))
 	for _, t := range tools {
 		m, ok := t.(map[string]any)
 		if !ok {
@@ -156,15 +157,18 @@ func buildClaudeToolPrompt(tools []any) string {
 			continue
 		}
 		names = append(names, name)
-		schema, _ := json.Marshal(schemaObj)
-		toolSchemas = append(toolSchemas, fmt.Sprintf("Tool: %s\nDescription: %s\nParameters: %s", name, desc, schema))
+		toolSchemas = append(toolSchemas, fmt.Sprintf("Tool: %s\nDescription: %s\n%s", name, desc, toolcall.FormatToolParameters(schemaObj)))
+		promptTools = append(promptTools, toolcall.NewPromptTool(name, desc, schemaObj))
 	}
 	if len(toolSchemas) == 0 {
 		return ""
 	}
+	if rendered, ok := settings.Render(toolcall.PromptData{Tools: promptTools, ToolNames: names}); ok {
+		return rendered
+	}
 	return "You have access to these tools:\n\n" +
 		strings.Join(toolSchemas, "\n\n") + "\n\n" +
-		toolcall.BuildToolCallInstructions(names)
+		settings.Instructions(names)
 }
 
 //nolint:unused // retained for compatibility with pending Claude tool-result prompt flow.
@@ -0,0 +1,53 @@
+package claude
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestClaudeAssistantPrefillContinuesInsteadOfStartingOver(t *testing.T) {
+	for name, store := range map[string]ConfigReader{
+		"inline":             claudeHistoryConfig{aliases: map[string]string{"claude-sonnet-4-6": "deepseek-v4-flash"}},
+		"current input file": mockClaudeConfig{aliases: map[string]string{"claude-sonnet-4-6": "deepseek-v4-flash"}},
+	} {
+		ds := &claudeCurrentInputDS{}
+		h := &Handler{Store: store, Auth: claudeCurrentInputAuth{}, DS: ds}
+		reqBody := `{"model":"claude-sonnet-4-6","max_tokens":64,"messages":[{"role":"user","content":"Finish: the capital of France"},{"role":"assistant","content":"The capital of France is"}]}`
+		req := httptest.NewRequest(http.MethodPost, "/v1/messages", strings.NewReader(reqBody))
+		req.Header.Set("Content-Type", "application/json")
+		rec := httptest.NewRecorder()
+
+		h.Messages(rec, req)
+
+		if rec.Code != http.StatusOK {
+			t.Fatalf("%s: expected 200, got %d body=%s", name, rec.Code, rec.Body.String())
+		}
+		prompt, _ := ds.payload["prompt"].(string)
+		if !strings.HasSuffix(prompt, "<|Assistant|>The capital of France is") {
+			t.Fatalf("%s: expected the prompt to end inside the prefill, got %q", name, prompt)
+		}
+		if name == "current input file" && len(ds.uploads) == 0 {
+			t.Fatalf("%s: expected the history to be uploaded", name)
+		}
+		for _, upload := range ds.uploads {
+			if strings.Contains(string(upload.Data), "The capital of France is") {
+				t.Fatalf("%s: expected the prefill to stay out of uploaded context, got %q", name, upload.Data)
+			}
+		}
+		var body struct {
+			Role    string `json:"role"`
+			Content []struct {
+				Text string `json:"text"`
+			} `json:"content"`
+		}
+		if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+			t.Fatalf("%s: decode response: %v", name, err)
+		}
+		if body.Role != "assistant" || len(body.Content) != 1 || body.Content[0].Text != "ok" {
+			t.Fatalf("%s: expected only the continuation in an assistant message, got %s", name, rec.Body.String())
+		}
+	}
+}
@@ -6,6 +6,8 @@ import (
 	"net/http/httptest"
 	"strings"
 	"testing"
+
+	"ds2api/internal/config"
 )
 
 type claudeProxyStoreStub struct {
@@ -13,6 +15,10 @@ type claudeProxyStoreStub struct {
 }
 
 func (s claudeProxyStoreStub) ModelAliases() map[string]string { return s.aliases }
+func (claudeProxyStoreStub) ModelFallbacks(string) []string    { return nil }
+func (claudeProxyStoreStub) FallbackBackend(string) (config.APIBackendConfig, bool) {
+	return config.APIBackendConfig{}, false
+}
 
 func (claudeProxyStoreStub) CurrentInputFileEnabled() bool { return true }
 func (claudeProxyStoreStub) CurrentInputFileMinChars() int { return 0 }
@@ -7,6 +7,7 @@ import (
 	"ds2api/internal/config"
 	"ds2api/internal/prompt"
 	"ds2api/internal/promptcompat"
+	"ds2api/internal/toolcall"
 	"ds2api/internal/util"
 )
 
@@ -28,7 +29,8 @@ func normalizeClaudeRequest(store ConfigReader, req map[string]any) (claudeNorma
 	payload := cloneMap(req)
 	payload["messages"] = normalizedMessages
 	toolsRequested, _ := req["tools"].([]any)
-	payload["messages"] = injectClaudeToolPrompt(payload, normalizedMessages, toolsRequested)
+	toolPrompt := toolcall.ActivePrompt()
+	payload["messages"] = injectClaudeToolPrompt(payload, normalizedMessages, toolsRequested, toolPrompt)
 
 	dsPayload := convertClaudeToDeepSeek(payload, store)
 	dsModel, _ := dsPayload["model"].(string)
@@ -48,28 +50,31 @@ func normalizeClaudeRequest(store ConfigReader, req map[string]any) (claudeNorma
 
 	return claudeNormalizedRequest{
 		Standard: promptcompat.StandardRequest{
-			Surface:         "anthropic_messages",
-			RequestedModel:  strings.TrimSpace(model),
-			ResolvedModel:   dsModel,
-			ResponseModel:   strings.TrimSpace(model),
-			Messages:        normalizedMessages,
-			PromptTokenText: finalPrompt,
-			ToolsRaw:        toolsRequested,
-			FinalPrompt:     finalPrompt,
-			ToolNames:       toolNames,
-			Stream:          util.ToBool(req["stream"]),
-			Thinking:        thinkingEnabled,
-			Search:          searchEnabled,
+			Surface:          "anthropic_messages",
+			RequestedModel:   strings.TrimSpace(model),
+			ResolvedModel:    dsModel,
+			ResponseModel:    strings.TrimSpace(model),
+			FallbackModels:   config.ResolveModelFallbacks(store, model, dsModel),
+			FallbackBackends: config.ResolveFallbackBackends(store, model, dsModel),
+			Messages:         normalizedMessages,
+			PromptTokenText:  finalPrompt,
+			ToolsRaw:         toolsRequested,
+			FinalPrompt:      finalPrompt,
+			ToolNames:        toolNames,
+			ToolPrompt:       toolPrompt,
+			Stream:           util.ToBool(req["stream"]),
+			Thinking:         thinkingEnabled,
+			Search:           searchEnabled,
 		},
 		NormalizedMessages: normalizedMessages,
 	}, nil
 }
 
-func injectClaudeToolPrompt(payload map[string]any, normalizedMessages []any, tools []any) []any {
+func injectClaudeToolPrompt(payload map[string]any, normalizedMessages []any, tools []any, settings toolcall.PromptSettings) []any {
 	if len(tools) == 0 {
 		return normalizedMessages
 	}
-	toolPrompt := strings.TrimSpace(buildClaudeToolPrompt(tools))
+	toolPrompt := strings.TrimSpace(buildClaudeToolPrompt(tools, settings))
 	if toolPrompt == "" {
 		return normalizedMessages
 	}
@@ -60,7 +60,7 @@ func TestNormalizeClaudeRequestSupportsCamelCaseInputSchemaPromptInjection(t *te
 	if err != nil {
 		t.Fatalf("normalize failed: %v", err)
 	}
-	if !containsStr(norm.Standard.FinalPrompt, `"type":"array"`) {
+	if !containsStr(norm.Standard.FinalPrompt, "- todos (array)") {
 		t.Fatalf("expected inputSchema to be injected into prompt, got=%q", norm.Standard.FinalPrompt)
 	}
 }
@@ -1,10 +1,13 @@
 package claude
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
+	"net/http"
 	"strings"
 
+	"ds2api/internal/httpapi/requestctx"
 	"ds2api/internal/util"
 )
 
@@ -37,7 +40,7 @@ func (s *claudeStreamRuntime) sendErrorWithCode(status int, message, code string
 	if status == 429 {
 		errType = "rate_limit_error"
 	}
-	s.send("error", map[string]any{
+	s.send("error", requestctx.WithTraceID(s.w, map[string]any{
 		"type": "error",
 		"error": map[string]any{
 			"type":    errType,
@@ -45,7 +48,18 @@ func (s *claudeStreamRuntime) sendErrorWithCode(status int, message, code string
 			"code":    code,
 			"param":   nil,
 		},
-	})
+	}))
+}
+
+// markContextCancelled ends the stream with an error event when a server
+// shutdown cut it off, since the client is still listening. It reports
+// whether that happened.
+func (s *claudeStreamRuntime) markContextCancelled(ctx context.Context) bool {
+	if !requestctx.ShuttingDown(ctx) {
+		return false
+	}
+	s.sendErrorWithCode(http.StatusServiceUnavailable, requestctx.ShutdownMessage, requestctx.CodeServerShutdown)
+	return true
 }
 
 func (s *claudeStreamRuntime) sendPing() {
@@ -130,6 +130,7 @@ func (s *claudeStreamRuntime) finalize(stopReason string, deferEmptyOutput bool)
 		StripReferenceMarkers: s.stripReferenceMarkers,
 		ToolNames:             s.toolNames,
 		ToolsRaw:              s.toolsRaw,
+		ToolCallFormat:        s.sieve.CallFormat(),
 	})
 	finalText := turn.Text
 	outcome := assistantturn.FinalizeTurn(turn, assistantturn.FinalizeOptions{
@@ -190,6 +191,7 @@ func (s *claudeStreamRuntime) finalize(stopReason string, deferEmptyOutput bool)
 	if outcome.HasToolCalls {
 		stopReason = "tool_use"
 	}
+	s.generatedTokens = outcome.Usage.OutputTokens
 	if s.history != nil {
 		s.history.Success(
 			200,
@@ -8,6 +8,8 @@ import (
 
 	"github.com/go-chi/chi/v5"
 	chimw "github.com/go-chi/chi/v5/middleware"
+
+	"ds2api/internal/config"
 )
 
 type streamStatusClaudeOpenAIStub struct{}
@@ -22,6 +24,10 @@ func (streamStatusClaudeOpenAIStub) ChatCompletions(w http.ResponseWriter, _ *ht
 type streamStatusClaudeStoreStub struct{}
 
 func (streamStatusClaudeStoreStub) ModelAliases() map[string]string { return nil }
+func (streamStatusClaudeStoreStub) ModelFallbacks(string) []string  { return nil }
+func (streamStatusClaudeStoreStub) FallbackBackend(string) (config.APIBackendConfig, bool) {
+	return config.APIBackendConfig{}, false
+}
 
 func (streamStatusClaudeStoreStub) CurrentInputFileEnabled() bool { return true }
 func (streamStatusClaudeStoreStub) CurrentInputFileMinChars() int { return 0 }
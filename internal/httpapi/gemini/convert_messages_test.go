@@ -2,6 +2,7 @@ package gemini
 
 import (
 	"ds2api/internal/promptcompat"
+	"ds2api/internal/toolcall"
 	"strings"
 	"testing"
 )
@@ -85,7 +86,7 @@ func TestGeminiMessagesFromRequestPreservesThoughtOnFunctionCallHistory(t *testi
 	if len(tc) != 1 {
 		t.Fatalf("expected one tool call, got %#v", assistant["tool_calls"])
 	}
-	prompt, _ := promptcompat.BuildOpenAIPromptForAdapter(got, nil, "", true)
+	prompt, _ := promptcompat.BuildOpenAIPromptForAdapter(got, nil, "", toolcall.ActivePrompt(), true)
 	if !strings.Contains(prompt, "[reasoning_content]\nneed current state before answering\n[/reasoning_content]") {
 		t.Fatalf("expected thought in prompt history, got %q", prompt)
 	}
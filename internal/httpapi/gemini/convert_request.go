@@ -6,6 +6,7 @@ import (
 
 	"ds2api/internal/config"
 	"ds2api/internal/promptcompat"
+	"ds2api/internal/toolcall"
 	"ds2api/internal/util"
 )
 
@@ -32,25 +33,32 @@ func normalizeGeminiRequest(store ConfigReader, routeModel string, req map[strin
 	}
 
 	toolsRaw := convertGeminiTools(req["tools"])
-	finalPrompt, toolNames := promptcompat.BuildOpenAIPromptForAdapter(messagesRaw, toolsRaw, "", thinkingEnabled)
+	toolPrompt := toolcall.ActivePrompt()
+	finalPrompt, toolNames := promptcompat.BuildOpenAIPromptForAdapter(messagesRaw, toolsRaw, "", toolPrompt, thinkingEnabled)
 	if len(toolNames) == 0 && len(toolsRaw) > 0 {
 		toolNames = []string{"__any_tool__"}
 	}
 	passThrough := collectGeminiPassThrough(req)
+	if err := promptcompat.ApplySamplingParams(passThrough, store.RuntimeStrictSamplingParams(), ""); err != nil {
+		return promptcompat.StandardRequest{}, err
+	}
 
 	return promptcompat.StandardRequest{
-		Surface:         "google_gemini",
-		RequestedModel:  requestedModel,
-		ResolvedModel:   resolvedModel,
-		ResponseModel:   requestedModel,
-		Messages:        messagesRaw,
-		PromptTokenText: finalPrompt,
-		ToolsRaw:        toolsRaw,
-		FinalPrompt:     finalPrompt,
-		ToolNames:       toolNames,
-		Stream:          stream,
-		Thinking:        thinkingEnabled,
-		Search:          searchEnabled,
-		PassThrough:     passThrough,
+		Surface:          "google_gemini",
+		RequestedModel:   requestedModel,
+		ResolvedModel:    resolvedModel,
+		ResponseModel:    requestedModel,
+		FallbackModels:   config.ResolveModelFallbacks(store, requestedModel, resolvedModel),
+		FallbackBackends: config.ResolveFallbackBackends(store, requestedModel, resolvedModel),
+		Messages:         messagesRaw,
+		PromptTokenText:  finalPrompt,
+		ToolsRaw:         toolsRaw,
+		FinalPrompt:      finalPrompt,
+		ToolNames:        toolNames,
+		ToolPrompt:       toolPrompt,
+		Stream:           stream,
+		Thinking:         thinkingEnabled,
+		Search:           searchEnabled,
+		PassThrough:      passThrough,
 	}, nil
 }
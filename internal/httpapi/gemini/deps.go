@@ -23,8 +23,11 @@ type DeepSeekCaller interface {
 
 type ConfigReader interface {
 	ModelAliases() map[string]string
+	ModelFallbacks(requested string) []string
+	FallbackBackend(name string) (config.APIBackendConfig, bool)
 	CurrentInputFileEnabled() bool
 	CurrentInputFileMinChars() int
+	RuntimeStrictSamplingParams() bool
 }
 
 type OpenAIChatRunner interface {
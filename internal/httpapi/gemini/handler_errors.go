@@ -1,6 +1,10 @@
 package gemini
 
-import "net/http"
+import (
+	"net/http"
+
+	"ds2api/internal/httpapi/requestctx"
+)
 
 func writeGeminiError(w http.ResponseWriter, status int, message string) {
 	errorStatus := "INVALID_ARGUMENT"
@@ -18,11 +22,11 @@ func writeGeminiError(w http.ResponseWriter, status int, message string) {
 			errorStatus = "INTERNAL"
 		}
 	}
-	writeJSON(w, status, map[string]any{
+	writeJSON(w, status, requestctx.WithTraceID(w, map[string]any{
 		"error": map[string]any{
 			"code":    status,
 			"message": message,
 			"status":  errorStatus,
 		},
-	})
+	}))
 }
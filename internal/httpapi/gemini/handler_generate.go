@@ -53,19 +53,15 @@ func isGeminiVercelProxyRequest(r *http.Request) bool {
 }
 
 func (h *Handler) handleGeminiDirect(w http.ResponseWriter, r *http.Request, stream bool) bool {
-	raw, err := io.ReadAll(r.Body)
-	if err != nil {
-		if errors.Is(err, requestbody.ErrInvalidUTF8Body) {
-			writeGeminiError(w, http.StatusBadRequest, "invalid json")
-		} else {
-			writeGeminiError(w, http.StatusBadRequest, "invalid body")
-		}
-		return true
-	}
 	routeModel := strings.TrimSpace(chi.URLParam(r, "model"))
+	// Decode straight from the body so the raw bytes are not held twice.
 	var req map[string]any
-	if err := json.Unmarshal(raw, &req); err != nil {
-		writeGeminiError(w, http.StatusBadRequest, "invalid json")
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		if requestbody.IsTooLarge(err) {
+			writeGeminiError(w, http.StatusRequestEntityTooLarge, "request body too large")
+		} else {
+			writeGeminiError(w, http.StatusBadRequest, "invalid json")
+		}
 		return true
 	}
 	stdReq, err := normalizeGeminiRequest(h.Store, routeModel, req, stream)
@@ -79,6 +75,10 @@ func (h *Handler) handleGeminiDirect(w http.ResponseWriter, r *http.Request, str
 		return true
 	}
 	defer h.Auth.Release(a)
+	if !a.AllowsModel(stdReq.RequestedModel, stdReq.ResolvedModel) {
+		writeGeminiError(w, http.StatusForbidden, (&promptcompat.ModelNotAllowedError{Model: stdReq.RequestedModel}).Error())
+		return true
+	}
 	stdReq, err = h.applyCurrentInputFile(r.Context(), a, stdReq)
 	if err != nil {
 		status, message := mapCurrentInputFileError(err)
@@ -143,7 +143,9 @@ func (h *Handler) handleGeminiDirectStream(w http.ResponseWriter, r *http.Reques
 func (h *Handler) proxyViaOpenAI(w http.ResponseWriter, r *http.Request, stream bool) bool {
 	raw, err := io.ReadAll(r.Body)
 	if err != nil {
-		if errors.Is(err, requestbody.ErrInvalidUTF8Body) {
+		if requestbody.IsTooLarge(err) {
+			writeGeminiError(w, http.StatusRequestEntityTooLarge, "request body too large")
+		} else if errors.Is(err, requestbody.ErrInvalidUTF8Body) {
 			writeGeminiError(w, http.StatusBadRequest, "invalid json")
 		} else {
 			writeGeminiError(w, http.StatusBadRequest, "invalid body")
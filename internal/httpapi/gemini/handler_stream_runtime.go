@@ -12,6 +12,7 @@ import (
 	"ds2api/internal/auth"
 	"ds2api/internal/completionruntime"
 	dsprotocol "ds2api/internal/deepseek/protocol"
+	"ds2api/internal/metrics"
 	"ds2api/internal/promptcompat"
 	"ds2api/internal/responsehistory"
 	"ds2api/internal/sse"
@@ -78,6 +79,8 @@ type geminiStreamRuntime struct {
 	stripReferenceMarkers bool
 	toolNames             []string
 	toolsRaw              any
+	// toolCallFormat is the tool call format the request captured.
+	toolCallFormat string
 
 	accumulator       *assistantturn.Accumulator
 	contentFilter     bool
@@ -85,6 +88,7 @@ type geminiStreamRuntime struct {
 	finalErrorStatus  int
 	finalErrorMessage string
 	finalErrorCode    string
+	generatedTokens   int
 	history           *responsehistory.Session
 }
 
@@ -107,6 +111,7 @@ func (h *Handler) handleStreamGenerateContentWithRetry(w http.ResponseWriter, r
 	rc := http.NewResponseController(w)
 	_, canFlush := w.(http.Flusher)
 	runtime := newGeminiStreamRuntime(w, rc, canFlush, model, finalPrompt, thinkingEnabled, searchEnabled, stripReferenceMarkersEnabled(), toolNames, toolsRaw, historySession)
+	runtime.toolCallFormat = stdReq.ToolPrompt.Format
 
 	completionruntime.ExecuteStreamWithRetry(r.Context(), h.DS, a, resp, payload, pow, completionruntime.StreamRetryOptions{
 		Surface:          "gemini.generate_content",
@@ -133,6 +138,7 @@ func (h *Handler) handleStreamGenerateContentWithRetry(w http.ResponseWriter, r
 			runtime.sendErrorChunk(status, strings.TrimSpace(message))
 		},
 	})
+	metrics.AddGeneratedTokens(r.Context(), runtime.generatedTokens)
 }
 
 func (h *Handler) consumeGeminiStreamAttempt(ctx context.Context, resp *http.Response, runtime *geminiStreamRuntime, thinkingEnabled bool, allowDeferEmpty bool) (bool, bool) {
@@ -318,6 +324,7 @@ func (s *geminiStreamRuntime) finalize(deferEmptyOutput bool) bool {
 		StripReferenceMarkers: s.stripReferenceMarkers,
 		ToolNames:             s.toolNames,
 		ToolsRaw:              s.toolsRaw,
+		ToolCallFormat:        s.toolCallFormat,
 	})
 	outcome := assistantturn.FinalizeTurn(turn, assistantturn.FinalizeOptions{})
 	if outcome.ShouldFail {
@@ -333,6 +340,7 @@ func (s *geminiStreamRuntime) finalize(deferEmptyOutput bool) bool {
 		s.sendErrorChunk(outcome.Error.Status, outcome.Error.Message)
 		return true
 	}
+	s.generatedTokens = outcome.Usage.OutputTokens
 	if s.history != nil {
 		s.history.Success(
 			http.StatusOK,
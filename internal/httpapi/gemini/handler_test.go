@@ -15,14 +15,20 @@ import (
 
 	"ds2api/internal/auth"
 	"ds2api/internal/chathistory"
+	"ds2api/internal/config"
 	dsclient "ds2api/internal/deepseek/client"
 )
 
 type testGeminiConfig struct{}
 
 func (testGeminiConfig) ModelAliases() map[string]string { return nil }
-func (testGeminiConfig) CurrentInputFileEnabled() bool   { return true }
-func (testGeminiConfig) CurrentInputFileMinChars() int   { return 0 }
+func (testGeminiConfig) ModelFallbacks(string) []string  { return nil }
+func (testGeminiConfig) FallbackBackend(string) (config.APIBackendConfig, bool) {
+	return config.APIBackendConfig{}, false
+}
+func (testGeminiConfig) CurrentInputFileEnabled() bool     { return true }
+func (testGeminiConfig) CurrentInputFileMinChars() int     { return 0 }
+func (testGeminiConfig) RuntimeStrictSamplingParams() bool { return false }
 
 type testGeminiAuth struct {
 	a   *auth.RequestAuth
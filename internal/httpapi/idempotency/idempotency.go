@@ -0,0 +1,193 @@
+package idempotency
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5/middleware"
+
+	"ds2api/internal/config"
+	"ds2api/internal/httpapi/bodyreplay"
+	"ds2api/internal/httpapi/openai/shared"
+)
+
+const (
+	// HeaderKey is the request header clients set to mark retries of one
+	// logical request.
+	HeaderKey = "Idempotency-Key"
+	// ReplayedHeader is set on responses served from the store.
+	ReplayedHeader = "Idempotent-Replayed"
+
+	maxKeyLength = 255
+	// maxStoredBodyBytes caps a stored response. Larger responses still reach
+	// their own client but are not kept for replay.
+	maxStoredBodyBytes = 8 << 20
+)
+
+// Middleware deduplicates API requests. The first request for a key runs
+// normally while its response is recorded; duplicates arriving meanwhile
+// wait for it, and duplicates within the TTL get the recorded response
+// replayed, streamed responses included. Only 2xx responses stay stored,
+// so a retry after a failure generates again.
+func Middleware(settings func() config.IdempotencyConfig) func(http.Handler) http.Handler {
+	store := newStore()
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if settings == nil || !bodyreplay.Eligible(r) {
+				next.ServeHTTP(w, r)
+				return
+			}
+			cfg := settings()
+			if cfg.Enabled == nil || !*cfg.Enabled {
+				next.ServeHTTP(w, r)
+				return
+			}
+			clientKey := strings.TrimSpace(r.Header.Get(HeaderKey))
+			if clientKey == "" && (cfg.HashBody == nil || !*cfg.HashBody) {
+				next.ServeHTTP(w, r)
+				return
+			}
+			if len(clientKey) > maxKeyLength {
+				shared.WriteOpenAIErrorWithCode(w, http.StatusBadRequest, "Idempotency-Key must be at most 255 characters.", "invalid_request_error")
+				return
+			}
+			r, body := bodyreplay.Capture(r)
+			if body.Err != nil {
+				next.ServeHTTP(w, r)
+				return
+			}
+			bodyHash := sha256.Sum256(body.Raw)
+			e, leader, err := store.acquire(requestKey(r, clientKey, bodyHash), bodyHash, cfg.MaxEntries)
+			if errors.Is(err, errStoreFull) {
+				config.Logger.Warn("[idempotency] request rejected, store full", "trace_id", middleware.GetReqID(r.Context()), "path", r.URL.Path, "max_entries", cfg.MaxEntries)
+				w.Header().Set("Retry-After", "1")
+				shared.WriteOpenAIErrorWithCode(w, http.StatusTooManyRequests, "Too many idempotent requests are in progress. Retry later.", "idempotency_store_full")
+				return
+			}
+			if !leader {
+				if e.bodyHash != bodyHash {
+					shared.WriteOpenAIErrorWithCode(w, http.StatusUnprocessableEntity, "Idempotency-Key was already used with a different request body.", "idempotency_key_reused")
+					return
+				}
+				select {
+				case <-e.done:
+				case <-r.Context().Done():
+					return
+				}
+				if e.replayable {
+					config.Logger.Info("[idempotency] replayed response", "trace_id", middleware.GetReqID(r.Context()), "path", r.URL.Path, "status", e.status)
+					replay(w, e)
+					return
+				}
+				// The first attempt did not finish cleanly, so this one runs
+				// on its own rather than replaying a partial response.
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			capture := &cappedBuffer{limit: maxStoredBodyBytes}
+			ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+			ww.Tee(capture)
+			result := result{}
+			// Deferred so a panicking handler still releases waiting duplicates.
+			defer func() { store.complete(e, result, time.Duration(cfg.TTLSeconds)*time.Second) }()
+			next.ServeHTTP(ww, r)
+
+			result.status = ww.Status()
+			if result.status == 0 {
+				result.status = http.StatusOK
+			}
+			result.header = ww.Header().Clone()
+			result.body = capture.Bytes()
+			result.replayable = !capture.truncated && r.Context().Err() == nil
+		})
+	}
+}
+
+// requestKey scopes a key to the route and caller credentials, so two
+// clients choosing the same Idempotency-Key never see each other's output.
+func requestKey(r *http.Request, clientKey string, bodyHash [32]byte) string {
+	h := sha256.New()
+	for _, part := range []string{
+		r.URL.Path,
+		r.URL.RawQuery,
+		r.Header.Get("Authorization"),
+		r.Header.Get("X-Api-Key"),
+		r.Header.Get("X-Goog-Api-Key"),
+	} {
+		_, _ = io.WriteString(h, part)
+		_, _ = h.Write([]byte{0})
+	}
+	if clientKey != "" {
+		_, _ = io.WriteString(h, "key:"+clientKey)
+	} else {
+		_, _ = io.WriteString(h, "body:")
+		_, _ = h.Write(bodyHash[:])
+	}
+	return string(h.Sum(nil))
+}
+
+// replay writes a stored response. Headers set by outer middleware, such as
+// this request's X-Request-Id, are kept. Event streams are written one event
+// at a time with a flush after each so clients parse them as they would live.
+func replay(w http.ResponseWriter, e *entry) {
+	for k, v := range e.header {
+		if _, exists := w.Header()[k]; !exists {
+			w.Header()[k] = v
+		}
+	}
+	w.Header().Set(ReplayedHeader, "true")
+	w.WriteHeader(e.status)
+	var sep []byte
+	contentType := strings.ToLower(e.header.Get("Content-Type"))
+	switch {
+	case strings.HasPrefix(contentType, "text/event-stream"):
+		sep = []byte("\n\n")
+	case strings.HasPrefix(contentType, "application/x-ndjson"):
+		sep = []byte("\n")
+	default:
+		_, _ = w.Write(e.body)
+		return
+	}
+	rc := http.NewResponseController(w)
+	for rest := e.body; len(rest) > 0; {
+		chunk := rest
+		if i := bytes.Index(rest, sep); i >= 0 {
+			chunk = rest[:i+len(sep)]
+		}
+		if _, err := w.Write(chunk); err != nil {
+			return
+		}
+		_ = rc.Flush()
+		rest = rest[len(chunk):]
+	}
+}
+
+// cappedBuffer keeps up to limit bytes and flags anything beyond it.
+type cappedBuffer struct {
+	buf       bytes.Buffer
+	limit     int
+	truncated bool
+}
+
+func (b *cappedBuffer) Write(p []byte) (int, error) {
+	if b.truncated {
+		return len(p), nil
+	}
+	if b.buf.Len()+len(p) > b.limit {
+		b.truncated = true
+		b.buf = bytes.Buffer{}
+		return len(p), nil
+	}
+	b.buf.Write(p)
+	return len(p), nil
+}
+
+func (b *cappedBuffer) Bytes() []byte {
+	return b.buf.Bytes()
+}
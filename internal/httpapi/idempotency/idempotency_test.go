@@ -0,0 +1,195 @@
+package idempotency
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"ds2api/internal/config"
+)
+
+func settings(hashBody bool) func() config.IdempotencyConfig {
+	on := true
+	return func() config.IdempotencyConfig {
+		return config.IdempotencyConfig{Enabled: &on, HashBody: &hashBody, TTLSeconds: 60, MaxEntries: 10}
+	}
+}
+
+func post(h http.Handler, key, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer caller")
+	if key != "" {
+		req.Header.Set(HeaderKey, key)
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestMiddlewareReplaysStoredResponseForSameKey(t *testing.T) {
+	var calls atomic.Int32
+	h := Middleware(settings(false))(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		n := calls.Add(1)
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"n":` + strconv.Itoa(int(n)) + `}`))
+	}))
+
+	first := post(h, "retry-1", `{"a":1}`)
+	second := post(h, "retry-1", `{"a":1}`)
+	if calls.Load() != 1 || second.Body.String() != first.Body.String() {
+		t.Fatalf("expected replay of first response, calls=%d first=%q second=%q", calls.Load(), first.Body.String(), second.Body.String())
+	}
+	if second.Header().Get(ReplayedHeader) != "true" || first.Header().Get(ReplayedHeader) != "" {
+		t.Fatalf("expected only the replay to be marked, got %q / %q", first.Header().Get(ReplayedHeader), second.Header().Get(ReplayedHeader))
+	}
+	if rec := post(h, "retry-1", `{"a":2}`); rec.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("expected 422 for reused key with a different body, got %d", rec.Code)
+	}
+	post(h, "", `{"a":1}`)
+	post(h, "", `{"a":1}`)
+	if calls.Load() != 3 {
+		t.Fatalf("expected requests without a key to pass through, calls=%d", calls.Load())
+	}
+}
+
+func TestMiddlewareCoalescesConcurrentDuplicates(t *testing.T) {
+	var calls atomic.Int32
+	started := make(chan struct{})
+	release := make(chan struct{})
+	h := Middleware(settings(true))(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		calls.Add(1)
+		close(started)
+		<-release
+		_, _ = w.Write([]byte(`{"ok":true}`))
+	}))
+
+	var wg sync.WaitGroup
+	recs := make([]*httptest.ResponseRecorder, 3)
+	wg.Add(1)
+	go func() { defer wg.Done(); recs[0] = post(h, "", `{"same":true}`) }()
+	<-started
+	for i := 1; i < len(recs); i++ {
+		wg.Add(1)
+		go func(i int) { defer wg.Done(); recs[i] = post(h, "", `{"same":true}`) }(i)
+	}
+	time.Sleep(20 * time.Millisecond)
+	close(release)
+	wg.Wait()
+	if calls.Load() != 1 {
+		t.Fatalf("expected one upstream call for concurrent duplicates, got %d", calls.Load())
+	}
+	for i, rec := range recs {
+		if rec.Body.String() != `{"ok":true}` {
+			t.Fatalf("request %d got %q", i, rec.Body.String())
+		}
+	}
+}
+
+func TestMiddlewareReplaysStreamsAndSkipsFailures(t *testing.T) {
+	var calls atomic.Int32
+	h := Middleware(settings(false))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		if strings.Contains(r.Header.Get(HeaderKey), "fail") {
+			http.Error(w, "upstream down", http.StatusBadGateway)
+			return
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		_, _ = w.Write([]byte("data: {\"delta\":\"a\"}\n\n"))
+		w.(http.Flusher).Flush()
+		_, _ = w.Write([]byte("data: [DONE]\n\n"))
+	}))
+
+	first := post(h, "stream", `{"stream":true}`)
+	replayed := post(h, "stream", `{"stream":true}`)
+	if calls.Load() != 1 || replayed.Body.String() != first.Body.String() || replayed.Header().Get("Content-Type") != "text/event-stream" {
+		t.Fatalf("expected stream replay, calls=%d body=%q headers=%#v", calls.Load(), replayed.Body.String(), replayed.Header())
+	}
+	if !replayed.Flushed {
+		t.Fatalf("expected replayed stream to be flushed")
+	}
+
+	post(h, "fail", `{}`)
+	if rec := post(h, "fail", `{}`); rec.Code != http.StatusBadGateway || calls.Load() != 3 {
+		t.Fatalf("expected failed responses to run again, code=%d calls=%d", rec.Code, calls.Load())
+	}
+}
+
+func TestStoreEvictsOldestAndExpiredEntries(t *testing.T) {
+	s := newStore()
+	now := time.Unix(0, 0)
+	s.now = func() time.Time { return now }
+	ok := result{status: http.StatusOK, replayable: true}
+	for _, key := range []string{"a", "b"} {
+		e, leader, err := s.acquire(key, [32]byte{}, 1)
+		if err != nil || !leader {
+			t.Fatalf("expected %s to lead", key)
+		}
+		s.complete(e, ok, time.Minute)
+	}
+	if len(s.entries) != 1 || s.entries["a"] != nil {
+		t.Fatalf("expected oldest entry evicted, got %d entries", len(s.entries))
+	}
+	if _, leader, _ := s.acquire("b", [32]byte{}, 1); leader {
+		t.Fatalf("expected b to still be stored")
+	}
+	now = now.Add(2 * time.Minute)
+	if _, leader, _ := s.acquire("b", [32]byte{}, 1); !leader {
+		t.Fatalf("expected b to expire after its ttl")
+	}
+}
+
+func TestStoreNeverEvictsInFlightEntries(t *testing.T) {
+	s := newStore()
+	a, _, _ := s.acquire("a", [32]byte{}, 2)
+	b, _, _ := s.acquire("b", [32]byte{}, 2)
+	if _, _, err := s.acquire("c", [32]byte{}, 2); !errors.Is(err, errStoreFull) {
+		t.Fatalf("expected errStoreFull while every entry is in flight, got %v", err)
+	}
+	if e, leader, _ := s.acquire("a", [32]byte{}, 2); leader || e != a {
+		t.Fatalf("expected a retry of a to join the in-flight entry")
+	}
+
+	s.complete(b, result{status: http.StatusOK, replayable: true}, time.Minute)
+	if _, leader, err := s.acquire("c", [32]byte{}, 2); err != nil || !leader {
+		t.Fatalf("expected c to lead once a completed entry can be evicted, got %v", err)
+	}
+	if s.entries["a"] != a || s.entries["b"] != nil {
+		t.Fatalf("expected the completed entry evicted and the in-flight one kept")
+	}
+}
+
+func TestMiddlewareRejectsNewKeyWhenStoreIsFullOfInFlightRequests(t *testing.T) {
+	on := true
+	release := make(chan struct{})
+	started := make(chan struct{})
+	h := Middleware(func() config.IdempotencyConfig {
+		return config.IdempotencyConfig{Enabled: &on, TTLSeconds: 60, MaxEntries: 1}
+	})(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		close(started)
+		<-release
+		_, _ = w.Write([]byte(`{"ok":true}`))
+	}))
+
+	done := make(chan *httptest.ResponseRecorder)
+	go func() { done <- post(h, "first", `{"a":1}`) }()
+	<-started
+
+	rec := post(h, "second", `{"a":2}`)
+	if rec.Code != http.StatusTooManyRequests || !strings.Contains(rec.Body.String(), "idempotency_store_full") {
+		t.Fatalf("expected 429 idempotency_store_full, got %d %s", rec.Code, rec.Body.String())
+	}
+	if rec.Header().Get("Retry-After") == "" {
+		t.Fatalf("expected Retry-After on the rejection")
+	}
+	close(release)
+	if first := <-done; first.Code != http.StatusOK {
+		t.Fatalf("expected the in-flight request to finish, got %d", first.Code)
+	}
+}
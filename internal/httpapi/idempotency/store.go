@@ -0,0 +1,121 @@
+package idempotency
+
+import (
+	"container/list"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// errStoreFull is returned by acquire when every stored key is still in
+// flight, so none can be evicted without breaking its coalescing.
+var errStoreFull = errors.New("idempotency store is full of in-flight requests")
+
+type result struct {
+	status int
+	header http.Header
+	body   []byte
+	// replayable reports that the response finished and fit the store.
+	replayable bool
+}
+
+type entry struct {
+	result
+	key      string
+	bodyHash [32]byte
+	// done is closed once the leading request has finished; result fields
+	// are only read after that.
+	done    chan struct{}
+	expires time.Time
+	elem    *list.Element
+}
+
+// store holds in-flight and completed entries in insertion order so the
+// oldest completed one can be evicted once the configured size is reached.
+type store struct {
+	mu      sync.Mutex
+	entries map[string]*entry
+	order   *list.List
+	now     func() time.Time
+}
+
+func newStore() *store {
+	return &store{entries: map[string]*entry{}, order: list.New(), now: time.Now}
+}
+
+// acquire returns the live entry for key, or registers a new one when there
+// is none. leader is true when the caller registered it and must run the
+// request and call complete. In-flight entries are never evicted: when the
+// store is at maxEntries and only those remain, acquire returns errStoreFull.
+func (s *store) acquire(key string, bodyHash [32]byte, maxEntries int) (e *entry, leader bool, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.now()
+	if existing, ok := s.entries[key]; ok {
+		if !existing.finished() || now.Before(existing.expires) {
+			return existing, false, nil
+		}
+		s.removeLocked(existing)
+	}
+	for front := s.order.Front(); front != nil; front = s.order.Front() {
+		oldest := front.Value.(*entry)
+		if !oldest.finished() || now.Before(oldest.expires) {
+			break
+		}
+		s.removeLocked(oldest)
+	}
+	for maxEntries > 0 && s.order.Len() >= maxEntries {
+		oldest := s.oldestFinishedLocked()
+		if oldest == nil {
+			return nil, false, errStoreFull
+		}
+		s.removeLocked(oldest)
+	}
+	e = &entry{key: key, bodyHash: bodyHash, done: make(chan struct{})}
+	e.elem = s.order.PushBack(e)
+	s.entries[key] = e
+	return e, true, nil
+}
+
+func (s *store) oldestFinishedLocked() *entry {
+	for elem := s.order.Front(); elem != nil; elem = elem.Next() {
+		if e := elem.Value.(*entry); e.finished() {
+			return e
+		}
+	}
+	return nil
+}
+
+// complete records the leader's response and wakes waiting duplicates.
+// Only replayable 2xx responses stay in the store for ttl.
+func (s *store) complete(e *entry, res result, ttl time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e.result = res
+	e.expires = s.now().Add(ttl)
+	keep := res.replayable && res.status >= 200 && res.status < 300 && ttl > 0
+	if !keep && s.entries[e.key] == e {
+		s.removeLocked(e)
+	}
+	close(e.done)
+}
+
+func (s *store) removeLocked(e *entry) {
+	if s.entries[e.key] == e {
+		delete(s.entries, e.key)
+	}
+	if e.elem != nil {
+		s.order.Remove(e.elem)
+		e.elem = nil
+	}
+}
+
+func (e *entry) finished() bool {
+	select {
+	case <-e.done:
+		return true
+	default:
+		return false
+	}
+}
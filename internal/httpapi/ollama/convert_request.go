@@ -0,0 +1,236 @@
+package ollama
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"ds2api/internal/config"
+	"ds2api/internal/promptcompat"
+	"ds2api/internal/toolcall"
+)
+
+type ollamaMode string
+
+const (
+	ollamaModeChat     ollamaMode = "chat"
+	ollamaModeGenerate ollamaMode = "generate"
+)
+
+// normalizeOllamaChatRequest maps an /api/chat body onto the shared
+// StandardRequest. Ollama messages already use OpenAI roles; only assistant
+// tool calls and tool results need reshaping.
+func normalizeOllamaChatRequest(store ConfigReader, req map[string]any) (promptcompat.StandardRequest, error) {
+	rawMessages, ok := req["messages"].([]any)
+	if !ok {
+		return promptcompat.StandardRequest{}, fmt.Errorf("messages must be an array")
+	}
+	return normalizeOllamaRequest(store, req, ollamaChatMessages(rawMessages), "ollama_chat")
+}
+
+// normalizeOllamaGenerateRequest turns an /api/generate prompt, with its
+// optional system prompt, into a one-turn conversation.
+func normalizeOllamaGenerateRequest(store ConfigReader, req map[string]any) (promptcompat.StandardRequest, error) {
+	messages := make([]any, 0, 2)
+	if system := asString(req["system"]); strings.TrimSpace(system) != "" {
+		messages = append(messages, map[string]any{"role": "system", "content": system})
+	}
+	messages = append(messages, map[string]any{"role": "user", "content": asString(req["prompt"])})
+	return normalizeOllamaRequest(store, req, messages, "ollama_generate")
+}
+
+func normalizeOllamaRequest(store ConfigReader, req map[string]any, messages []any, surface string) (promptcompat.StandardRequest, error) {
+	requestedModel := strings.TrimSpace(asString(req["model"]))
+	if requestedModel == "" {
+		return promptcompat.StandardRequest{}, fmt.Errorf("model is required")
+	}
+	resolvedModel, ok := config.ResolveModel(store, requestedModel)
+	if !ok {
+		return promptcompat.StandardRequest{}, &promptcompat.ModelNotFoundError{Model: requestedModel}
+	}
+	thinkingEnabled, searchEnabled, _ := config.GetModelConfig(resolvedModel)
+	if enabled, ok := ollamaThinkOverride(req["think"]); ok {
+		thinkingEnabled = enabled
+	}
+	if config.IsNoThinkingModel(resolvedModel) {
+		thinkingEnabled = false
+	}
+	// Ollama streams unless the client opts out.
+	stream := true
+	if v, ok := req["stream"].(bool); ok {
+		stream = v
+	}
+
+	var toolsRaw any
+	if tools, ok := req["tools"].([]any); ok && len(tools) > 0 {
+		toolsRaw = tools
+	}
+	toolPrompt := toolcall.ActivePrompt()
+	finalPrompt, toolNames := promptcompat.BuildOpenAIPromptForAdapter(messages, toolsRaw, "", toolPrompt, thinkingEnabled)
+	if len(toolNames) == 0 && toolsRaw != nil {
+		toolNames = []string{"__any_tool__"}
+	}
+
+	stdReq := promptcompat.StandardRequest{
+		Surface:          surface,
+		RequestedModel:   requestedModel,
+		ResolvedModel:    resolvedModel,
+		ResponseModel:    requestedModel,
+		FallbackModels:   config.ResolveModelFallbacks(store, requestedModel, resolvedModel),
+		FallbackBackends: config.ResolveFallbackBackends(store, requestedModel, resolvedModel),
+		Messages:         messages,
+		PromptTokenText:  finalPrompt,
+		ToolsRaw:         toolsRaw,
+		FinalPrompt:      finalPrompt,
+		ToolNames:        toolNames,
+		ToolPrompt:       toolPrompt,
+		Stream:           stream,
+		Thinking:         thinkingEnabled,
+		Search:           searchEnabled,
+	}
+	if err := applyOllamaOptions(&stdReq, req["options"], store.RuntimeStrictSamplingParams()); err != nil {
+		return promptcompat.StandardRequest{}, err
+	}
+	return stdReq, nil
+}
+
+// applyOllamaOptions maps the Ollama `options` fields DeepSeek can honor:
+// sampling goes upstream after range checks, num_predict and stop are
+// enforced locally. Model-runtime options such as num_ctx are ignored.
+func applyOllamaOptions(stdReq *promptcompat.StandardRequest, raw any, strictSampling bool) error {
+	options, _ := raw.(map[string]any)
+	if len(options) == 0 {
+		return nil
+	}
+	passThrough := map[string]any{}
+	for _, key := range []string{"temperature", "top_p", "presence_penalty", "frequency_penalty"} {
+		if v, ok := options[key]; ok {
+			passThrough[key] = v
+		}
+	}
+	if err := promptcompat.ApplySamplingParams(passThrough, strictSampling, ""); err != nil {
+		return err
+	}
+	seed, ok, err := promptcompat.ParseSeed(options["seed"])
+	if err != nil {
+		return err
+	}
+	if ok {
+		passThrough["seed"] = seed
+	}
+	if len(passThrough) > 0 {
+		stdReq.PassThrough = passThrough
+	}
+	// num_predict -1 (infinite) and -2 (fill context) leave the budget open.
+	if n, ok := options["num_predict"].(float64); ok && n > 0 {
+		stdReq.MaxOutputTokens = int(n)
+	}
+	switch stop := options["stop"].(type) {
+	case string:
+		if stop != "" {
+			stdReq.StopSequences = []string{stop}
+		}
+	case []any:
+		for _, item := range stop {
+			if s, ok := item.(string); ok && s != "" {
+				stdReq.StopSequences = append(stdReq.StopSequences, s)
+			}
+		}
+	}
+	return nil
+}
+
+// ollamaThinkOverride reads `think`: a bool, or a level string such as
+// "high" that turns thinking on.
+func ollamaThinkOverride(raw any) (bool, bool) {
+	switch v := raw.(type) {
+	case bool:
+		return v, true
+	case string:
+		switch strings.ToLower(strings.TrimSpace(v)) {
+		case "":
+			return false, false
+		case "false", "none", "off":
+			return false, true
+		default:
+			return true, true
+		}
+	}
+	return false, false
+}
+
+// ollamaChatMessages rewrites Ollama tool calls (object arguments, no ids)
+// into OpenAI form. Calls get synthetic ids and each following tool result
+// takes the next one, so results pair with their calls in the prompt.
+func ollamaChatMessages(raw []any) []any {
+	out := make([]any, 0, len(raw))
+	var pendingIDs []string
+	for i, item := range raw {
+		msg, ok := item.(map[string]any)
+		if !ok {
+			continue
+		}
+		role := strings.ToLower(strings.TrimSpace(asString(msg["role"])))
+		converted := map[string]any{"role": role, "content": asString(msg["content"])}
+		switch role {
+		case "assistant":
+			calls, _ := msg["tool_calls"].([]any)
+			pendingIDs = pendingIDs[:0]
+			openAICalls := make([]any, 0, len(calls))
+			for j, callItem := range calls {
+				call, _ := callItem.(map[string]any)
+				fn, _ := call["function"].(map[string]any)
+				name := strings.TrimSpace(asString(fn["name"]))
+				if name == "" {
+					continue
+				}
+				id := fmt.Sprintf("call_%d_%d", i, j)
+				pendingIDs = append(pendingIDs, id)
+				openAICalls = append(openAICalls, map[string]any{
+					"id":   id,
+					"type": "function",
+					"function": map[string]any{
+						"name":      name,
+						"arguments": stringifyArguments(fn["arguments"]),
+					},
+				})
+			}
+			if len(openAICalls) > 0 {
+				converted["tool_calls"] = openAICalls
+			}
+		case "tool":
+			if name := strings.TrimSpace(asString(msg["tool_name"])); name != "" {
+				converted["name"] = name
+			}
+			if len(pendingIDs) > 0 {
+				converted["tool_call_id"] = pendingIDs[0]
+				pendingIDs = pendingIDs[1:]
+			}
+		}
+		out = append(out, converted)
+	}
+	return out
+}
+
+func stringifyArguments(v any) string {
+	switch x := v.(type) {
+	case nil:
+		return "{}"
+	case string:
+		if strings.TrimSpace(x) == "" {
+			return "{}"
+		}
+		return x
+	default:
+		b, err := json.Marshal(x)
+		if err != nil {
+			return "{}"
+		}
+		return string(b)
+	}
+}
+
+func asString(v any) string {
+	s, _ := v.(string)
+	return s
+}
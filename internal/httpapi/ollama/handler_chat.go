@@ -0,0 +1,199 @@
+package ollama
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"ds2api/internal/assistantturn"
+	"ds2api/internal/completionruntime"
+	"ds2api/internal/httpapi/requestbody"
+	"ds2api/internal/httpapi/requestctx"
+	"ds2api/internal/promptcompat"
+	"ds2api/internal/responsehistory"
+	"ds2api/internal/toolcall"
+)
+
+func (h *Handler) Chat(w http.ResponseWriter, r *http.Request) {
+	h.handleOllamaCompletion(w, r, ollamaModeChat)
+}
+
+func (h *Handler) Generate(w http.ResponseWriter, r *http.Request) {
+	h.handleOllamaCompletion(w, r, ollamaModeGenerate)
+}
+
+func (h *Handler) handleOllamaCompletion(w http.ResponseWriter, r *http.Request, mode ollamaMode) {
+	if h.Auth == nil || h.DS == nil {
+		writeOllamaError(w, http.StatusInternalServerError, "Ollama runtime backend unavailable.")
+		return
+	}
+	timings := ollamaTimings{started: time.Now()}
+	// Decode straight from the body so the raw bytes are not held twice.
+	var req map[string]any
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		if requestbody.IsTooLarge(err) {
+			writeOllamaError(w, http.StatusRequestEntityTooLarge, "request body too large")
+		} else {
+			writeOllamaError(w, http.StatusBadRequest, "invalid json")
+		}
+		return
+	}
+	if isOllamaLoadRequest(mode, req) {
+		// Clients send an empty prompt to preload a model; there is nothing
+		// to load, so answer at once like a warm Ollama.
+		WriteJSON(w, http.StatusOK, buildOllamaLoadResponse(mode, strings.TrimSpace(asString(req["model"]))))
+		return
+	}
+	var stdReq promptcompat.StandardRequest
+	var err error
+	if mode == ollamaModeChat {
+		stdReq, err = normalizeOllamaChatRequest(h.Store, req)
+	} else {
+		stdReq, err = normalizeOllamaGenerateRequest(h.Store, req)
+	}
+	if err != nil {
+		var notFound *promptcompat.ModelNotFoundError
+		if errors.As(err, &notFound) {
+			writeOllamaError(w, http.StatusNotFound, err.Error())
+			return
+		}
+		writeOllamaError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	a, err := h.Auth.Determine(r)
+	if err != nil {
+		writeOllamaError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+	defer h.Auth.Release(a)
+	if !a.AllowsModel(stdReq.RequestedModel, stdReq.ResolvedModel) {
+		writeOllamaError(w, http.StatusForbidden, (&promptcompat.ModelNotAllowedError{Model: stdReq.RequestedModel}).Error())
+		return
+	}
+	historySession := responsehistory.Start(responsehistory.StartParams{
+		Store:    h.ChatHistory,
+		Request:  r,
+		Auth:     a,
+		Surface:  "ollama." + string(mode),
+		Standard: stdReq,
+	})
+	if stdReq.Stream {
+		h.handleOllamaStream(w, r, a, stdReq, mode, timings, historySession)
+		return
+	}
+	result, outErr := completionruntime.ExecuteNonStreamWithRetry(r.Context(), h.DS, a, stdReq, completionruntime.Options{
+		RetryEnabled:     true,
+		CurrentInputFile: h.Store,
+	})
+	if outErr != nil {
+		if historySession != nil {
+			historySession.ErrorTurn(outErr.Status, outErr.Message, outErr.Code, result.Turn)
+		}
+		writeOllamaError(w, outErr.Status, outErr.Message)
+		return
+	}
+	if historySession != nil {
+		historySession.SuccessTurn(http.StatusOK, result.Turn, responsehistory.GenericUsage(result.Turn))
+	}
+	out := buildOllamaChunk(mode, stdReq.ResponseModel, result.Turn.Text, result.Turn.Thinking, result.Turn.ToolCalls)
+	applyOllamaDone(out, result.Turn, timings)
+	WriteJSON(w, http.StatusOK, out)
+}
+
+func isOllamaLoadRequest(mode ollamaMode, req map[string]any) bool {
+	if mode == ollamaModeChat {
+		messages, ok := req["messages"].([]any)
+		return ok && len(messages) == 0
+	}
+	return strings.TrimSpace(asString(req["prompt"])) == "" && strings.TrimSpace(asString(req["system"])) == ""
+}
+
+func buildOllamaLoadResponse(mode ollamaMode, model string) map[string]any {
+	out := buildOllamaChunk(mode, model, "", "", nil)
+	out["done"] = true
+	out["done_reason"] = "load"
+	return out
+}
+
+// ollamaTimings backs the nanosecond duration fields of the final object.
+// Prompt evaluation runs until the first output token; without one (the
+// non-stream path) the whole request counts as evaluation.
+type ollamaTimings struct {
+	started     time.Time
+	firstOutput time.Time
+}
+
+// buildOllamaChunk renders one NDJSON object: `message` for /api/chat,
+// `response` for /api/generate. Thinking and tool calls ride along when set.
+func buildOllamaChunk(mode ollamaMode, model, text, thinking string, calls []toolcall.ParsedToolCall) map[string]any {
+	out := map[string]any{
+		"model":      model,
+		"created_at": time.Now().UTC().Format(time.RFC3339Nano),
+		"done":       false,
+	}
+	if mode == ollamaModeGenerate {
+		out["response"] = text
+		if thinking != "" {
+			out["thinking"] = thinking
+		}
+		return out
+	}
+	message := map[string]any{"role": "assistant", "content": text}
+	if thinking != "" {
+		message["thinking"] = thinking
+	}
+	if len(calls) > 0 {
+		message["tool_calls"] = formatOllamaToolCalls(calls)
+	}
+	out["message"] = message
+	return out
+}
+
+// applyOllamaDone turns a chunk into the closing `done:true` object with
+// Ollama's token counts and durations.
+func applyOllamaDone(out map[string]any, turn assistantturn.Turn, timings ollamaTimings) {
+	now := time.Now()
+	evalStart := timings.firstOutput
+	if evalStart.IsZero() {
+		evalStart = timings.started
+	}
+	doneReason := "stop"
+	if assistantturn.FinishReason(turn) == "length" {
+		doneReason = "length"
+	}
+	out["done"] = true
+	out["done_reason"] = doneReason
+	out["total_duration"] = now.Sub(timings.started).Nanoseconds()
+	out["load_duration"] = 0
+	out["prompt_eval_count"] = turn.Usage.InputTokens
+	out["prompt_eval_duration"] = evalStart.Sub(timings.started).Nanoseconds()
+	out["eval_count"] = turn.Usage.OutputTokens
+	out["eval_duration"] = now.Sub(evalStart).Nanoseconds()
+}
+
+func formatOllamaToolCalls(calls []toolcall.ParsedToolCall) []map[string]any {
+	out := make([]map[string]any, 0, len(calls))
+	for i, call := range calls {
+		args := call.Input
+		if args == nil {
+			args = map[string]any{}
+		}
+		out = append(out, map[string]any{
+			"function": map[string]any{
+				"index":     i,
+				"name":      call.Name,
+				"arguments": args,
+			},
+		})
+	}
+	return out
+}
+
+func writeOllamaError(w http.ResponseWriter, status int, message string) {
+	if strings.TrimSpace(message) == "" {
+		message = http.StatusText(status)
+	}
+	WriteJSON(w, status, requestctx.WithTraceID(w, map[string]any{"error": message}))
+}
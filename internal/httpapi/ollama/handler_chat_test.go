@@ -0,0 +1,238 @@
+package ollama
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"ds2api/internal/auth"
+	"ds2api/internal/config"
+	dsclient "ds2api/internal/deepseek/client"
+)
+
+type testOllamaConfig struct{}
+
+func (testOllamaConfig) ModelAliases() map[string]string { return nil }
+func (testOllamaConfig) ModelFallbacks(string) []string  { return nil }
+func (testOllamaConfig) FallbackBackend(string) (config.APIBackendConfig, bool) {
+	return config.APIBackendConfig{}, false
+}
+func (testOllamaConfig) CurrentInputFileEnabled() bool     { return false }
+func (testOllamaConfig) CurrentInputFileMinChars() int     { return 0 }
+func (testOllamaConfig) RuntimeStrictSamplingParams() bool { return false }
+
+type testOllamaAuth struct{}
+
+func (testOllamaAuth) Determine(_ *http.Request) (*auth.RequestAuth, error) {
+	return &auth.RequestAuth{DeepSeekToken: "direct-token", CallerID: "caller:test", TriedAccounts: map[string]bool{}}, nil
+}
+
+func (testOllamaAuth) Release(_ *auth.RequestAuth) {}
+
+// testOllamaDS answers every completion with the same SSE lines.
+type testOllamaDS struct {
+	lines    []string
+	payloads []map[string]any
+}
+
+func (m *testOllamaDS) CreateSession(_ context.Context, _ *auth.RequestAuth, _ int) (string, error) {
+	return "session-id", nil
+}
+
+func (m *testOllamaDS) GetPow(_ context.Context, _ *auth.RequestAuth, _ int) (string, error) {
+	return "pow", nil
+}
+
+func (m *testOllamaDS) UploadFile(_ context.Context, _ *auth.RequestAuth, _ dsclient.UploadFileRequest, _ int) (*dsclient.UploadFileResult, error) {
+	return &dsclient.UploadFileResult{ID: "file-id"}, nil
+}
+
+func (m *testOllamaDS) CallCompletion(_ context.Context, _ *auth.RequestAuth, payload map[string]any, _ string, _ int) (*http.Response, error) {
+	m.payloads = append(m.payloads, payload)
+	body := strings.Join(m.lines, "\n\n") + "\n\n"
+	return &http.Response{StatusCode: http.StatusOK, Header: make(http.Header), Body: io.NopCloser(strings.NewReader(body))}, nil
+}
+
+func postOllama(h *Handler, path, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+	if path == "/api/generate" {
+		h.Generate(rec, req)
+	} else {
+		h.Chat(rec, req)
+	}
+	return rec
+}
+
+func decodeOllamaLines(t *testing.T, body string) []map[string]any {
+	t.Helper()
+	var out []map[string]any
+	scanner := bufio.NewScanner(strings.NewReader(body))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		var obj map[string]any
+		if err := json.Unmarshal([]byte(line), &obj); err != nil {
+			t.Fatalf("line is not JSON: %q", line)
+		}
+		out = append(out, obj)
+	}
+	return out
+}
+
+func TestOllamaChatNonStreamReturnsMessageAndCounts(t *testing.T) {
+	ds := &testOllamaDS{lines: []string{`data: {"p":"response/content","v":"hello there"}`, `data: [DONE]`}}
+	h := &Handler{Store: testOllamaConfig{}, Auth: testOllamaAuth{}, DS: ds}
+
+	rec := postOllama(h, "/api/chat", `{"model":"deepseek-v4-flash-nothinking","stream":false,"messages":[{"role":"system","content":"Be brief."},{"role":"user","content":"hi"}],"options":{"temperature":0.2,"num_predict":64}}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status=%d body=%s", rec.Code, rec.Body.String())
+	}
+	var out map[string]any
+	if err := json.Unmarshal(rec.Body.Bytes(), &out); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	message, _ := out["message"].(map[string]any)
+	if out["done"] != true || out["done_reason"] != "stop" || message["role"] != "assistant" || message["content"] != "hello there" {
+		t.Fatalf("unexpected response: %#v", out)
+	}
+	if n, _ := out["eval_count"].(float64); n <= 0 {
+		t.Fatalf("expected eval_count, got %#v", out)
+	}
+	if n, _ := out["prompt_eval_count"].(float64); n <= 0 {
+		t.Fatalf("expected prompt_eval_count, got %#v", out)
+	}
+	if len(ds.payloads) != 1 || ds.payloads[0]["temperature"] != 0.2 {
+		t.Fatalf("expected temperature forwarded upstream, got %#v", ds.payloads)
+	}
+	if prompt, _ := ds.payloads[0]["prompt"].(string); !strings.Contains(prompt, "Be brief.") || !strings.Contains(prompt, "hi") {
+		t.Fatalf("expected messages in prompt, got %q", prompt)
+	}
+}
+
+func TestOllamaChatStreamEmitsNDJSONEndingWithDone(t *testing.T) {
+	ds := &testOllamaDS{lines: []string{`data: {"p":"response/content","v":"hello "}`, `data: {"p":"response/content","v":"world"}`, `data: [DONE]`}}
+	h := &Handler{Store: testOllamaConfig{}, Auth: testOllamaAuth{}, DS: ds}
+
+	rec := postOllama(h, "/api/chat", `{"model":"deepseek-v4-flash-nothinking","messages":[{"role":"user","content":"hi"}]}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status=%d body=%s", rec.Code, rec.Body.String())
+	}
+	if ct := rec.Header().Get("Content-Type"); ct != "application/x-ndjson" {
+		t.Fatalf("expected NDJSON content type, got %q", ct)
+	}
+	lines := decodeOllamaLines(t, rec.Body.String())
+	if len(lines) < 2 {
+		t.Fatalf("expected delta lines and a final line, got %#v", lines)
+	}
+	var content strings.Builder
+	for _, line := range lines[:len(lines)-1] {
+		if line["done"] != false {
+			t.Fatalf("expected done=false before the last line, got %#v", line)
+		}
+		message, _ := line["message"].(map[string]any)
+		content.WriteString(asString(message["content"]))
+	}
+	if content.String() != "hello world" {
+		t.Fatalf("expected streamed content, got %q", content.String())
+	}
+	last := lines[len(lines)-1]
+	if last["done"] != true || last["done_reason"] != "stop" {
+		t.Fatalf("unexpected final line: %#v", last)
+	}
+	for _, key := range []string{"eval_count", "prompt_eval_count", "total_duration", "eval_duration"} {
+		if _, ok := last[key].(float64); !ok {
+			t.Fatalf("expected %s in final line, got %#v", key, last)
+		}
+	}
+}
+
+func TestOllamaGenerateUsesPromptAndSystem(t *testing.T) {
+	ds := &testOllamaDS{lines: []string{`data: {"p":"response/content","v":"blue"}`, `data: [DONE]`}}
+	h := &Handler{Store: testOllamaConfig{}, Auth: testOllamaAuth{}, DS: ds}
+
+	rec := postOllama(h, "/api/generate", `{"model":"deepseek-v4-flash-nothinking","system":"Answer in one word.","prompt":"Sky color?","stream":false}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status=%d body=%s", rec.Code, rec.Body.String())
+	}
+	var out map[string]any
+	if err := json.Unmarshal(rec.Body.Bytes(), &out); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if out["response"] != "blue" || out["done"] != true {
+		t.Fatalf("unexpected response: %#v", out)
+	}
+	if prompt, _ := ds.payloads[0]["prompt"].(string); !strings.Contains(prompt, "Answer in one word.") || !strings.Contains(prompt, "Sky color?") {
+		t.Fatalf("expected system and prompt in upstream prompt, got %q", prompt)
+	}
+}
+
+func TestOllamaEmptyPromptAnswersLoadWithoutUpstream(t *testing.T) {
+	ds := &testOllamaDS{}
+	h := &Handler{Store: testOllamaConfig{}, Auth: testOllamaAuth{}, DS: ds}
+
+	rec := postOllama(h, "/api/generate", `{"model":"deepseek-v4-flash"}`)
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"done_reason":"load"`) {
+		t.Fatalf("expected load response, got status=%d body=%s", rec.Code, rec.Body.String())
+	}
+	if len(ds.payloads) != 0 {
+		t.Fatalf("expected no upstream calls, got %d", len(ds.payloads))
+	}
+}
+
+func TestOllamaChatUnknownModelReturnsNotFound(t *testing.T) {
+	h := &Handler{Store: testOllamaConfig{}, Auth: testOllamaAuth{}, DS: &testOllamaDS{}}
+
+	rec := postOllama(h, "/api/chat", `{"model":"llama3","messages":[{"role":"user","content":"hi"}]}`)
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("status=%d body=%s", rec.Code, rec.Body.String())
+	}
+	var out map[string]any
+	if err := json.Unmarshal(rec.Body.Bytes(), &out); err != nil || asString(out["error"]) == "" {
+		t.Fatalf("expected Ollama error body, got %s", rec.Body.String())
+	}
+}
+
+func TestNormalizeOllamaChatRequestMapsToolCallsAndOptions(t *testing.T) {
+	req := map[string]any{
+		"model": "deepseek-v4-flash",
+		"think": false,
+		"messages": []any{
+			map[string]any{"role": "user", "content": "weather?"},
+			map[string]any{"role": "assistant", "content": "", "tool_calls": []any{
+				map[string]any{"function": map[string]any{"name": "get_weather", "arguments": map[string]any{"city": "Paris"}}},
+			}},
+			map[string]any{"role": "tool", "tool_name": "get_weather", "content": "18C"},
+		},
+		"options": map[string]any{"num_predict": float64(32), "stop": []any{"END"}, "seed": float64(7)},
+	}
+	stdReq, err := normalizeOllamaChatRequest(testOllamaConfig{}, req)
+	if err != nil {
+		t.Fatalf("normalize failed: %v", err)
+	}
+	if stdReq.Thinking || !stdReq.Stream || stdReq.MaxOutputTokens != 32 || len(stdReq.StopSequences) != 1 || stdReq.PassThrough["seed"] != int64(7) {
+		t.Fatalf("unexpected request: %#v", stdReq)
+	}
+	assistant, _ := stdReq.Messages[1].(map[string]any)
+	calls, _ := assistant["tool_calls"].([]any)
+	call, _ := calls[0].(map[string]any)
+	fn, _ := call["function"].(map[string]any)
+	if fn["arguments"] != `{"city":"Paris"}` {
+		t.Fatalf("expected JSON string arguments, got %#v", fn)
+	}
+	tool, _ := stdReq.Messages[2].(map[string]any)
+	if tool["tool_call_id"] != call["id"] || tool["name"] != "get_weather" {
+		t.Fatalf("expected tool result paired with its call, got %#v", tool)
+	}
+	if !strings.Contains(stdReq.FinalPrompt, "get_weather") || !strings.Contains(stdReq.FinalPrompt, "18C") {
+		t.Fatalf("expected tool history in prompt, got %q", stdReq.FinalPrompt)
+	}
+}
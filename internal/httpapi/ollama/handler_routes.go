@@ -1,6 +1,7 @@
 package ollama
 
 import (
+	"ds2api/internal/chathistory"
 	"ds2api/internal/config"
 	"ds2api/internal/util"
 	"encoding/json"
@@ -11,12 +12,11 @@ import (
 
 var WriteJSON = util.WriteJSON
 
-type ConfigReader interface {
-	ModelAliases() map[string]string
-}
-
 type Handler struct {
-	Store ConfigReader
+	Store       ConfigReader
+	Auth        AuthResolver
+	DS          DeepSeekCaller
+	ChatHistory *chathistory.Store
 }
 
 type OllamaModelRequest struct {
@@ -27,6 +27,8 @@ func RegisterRoutes(r chi.Router, h *Handler) {
 	r.Get("/api/version", h.GetVersion)
 	r.Get("/api/tags", h.ListOllamaModels)
 	r.Post("/api/show", h.GetOllamaModel)
+	r.Post("/api/chat", h.Chat)
+	r.Post("/api/generate", h.Generate)
 }
 
 func (h *Handler) GetVersion(w http.ResponseWriter, r *http.Request) {
@@ -0,0 +1,268 @@
+package ollama
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"ds2api/internal/assistantturn"
+	"ds2api/internal/auth"
+	"ds2api/internal/completionruntime"
+	dsprotocol "ds2api/internal/deepseek/protocol"
+	"ds2api/internal/httpapi/openai/shared"
+	"ds2api/internal/metrics"
+	"ds2api/internal/promptcompat"
+	"ds2api/internal/responsehistory"
+	"ds2api/internal/sse"
+	streamengine "ds2api/internal/stream"
+	"ds2api/internal/textclean"
+)
+
+// handleOllamaStream writes Ollama NDJSON: one JSON object per line while
+// text arrives, then a `done:true` object with token counts and durations.
+func (h *Handler) handleOllamaStream(w http.ResponseWriter, r *http.Request, a *auth.RequestAuth, stdReq promptcompat.StandardRequest, mode ollamaMode, timings ollamaTimings, historySession *responsehistory.Session) {
+	start, outErr := completionruntime.StartCompletion(r.Context(), h.DS, a, stdReq, completionruntime.Options{
+		CurrentInputFile: h.Store,
+	})
+	if outErr != nil {
+		if historySession != nil {
+			historySession.Error(outErr.Status, outErr.Message, outErr.Code, "", "")
+		}
+		writeOllamaError(w, outErr.Status, outErr.Message)
+		return
+	}
+	stdReq = start.Request
+	if start.Response.StatusCode != http.StatusOK {
+		defer func() { _ = start.Response.Body.Close() }()
+		body, _ := io.ReadAll(start.Response.Body)
+		if historySession != nil {
+			historySession.Error(start.Response.StatusCode, strings.TrimSpace(string(body)), "error", "", "")
+		}
+		writeOllamaError(w, start.Response.StatusCode, strings.TrimSpace(string(body)))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.Header().Set("Cache-Control", "no-cache, no-transform")
+	w.Header().Set("X-Accel-Buffering", "no")
+
+	rc := http.NewResponseController(w)
+	_, canFlush := w.(http.Flusher)
+	runtime := newOllamaStreamRuntime(w, rc, canFlush, mode, stdReq, timings, historySession)
+
+	completionruntime.ExecuteStreamWithRetry(r.Context(), h.DS, a, start.Response, start.Payload, start.Pow, completionruntime.StreamRetryOptions{
+		Surface:          "ollama." + string(mode),
+		Stream:           true,
+		RetryEnabled:     true,
+		MaxAttempts:      3,
+		UsagePrompt:      stdReq.PromptTokenText,
+		Request:          stdReq,
+		CurrentInputFile: h.Store,
+	}, completionruntime.StreamRetryHooks{
+		ConsumeAttempt: func(currentResp *http.Response, allowDeferEmpty bool) (bool, bool) {
+			return h.consumeOllamaStreamAttempt(r.Context(), currentResp, runtime, allowDeferEmpty)
+		},
+		Finalize: func(_ int) {
+			runtime.finalize(false)
+		},
+		ParentMessageID: func() int {
+			return runtime.responseMessageID
+		},
+		OnRetryPrompt: func(prompt string) {
+			runtime.finalPrompt = prompt
+		},
+		OnRetryFailure: func(_ int, message, _ string) {
+			runtime.sendError(message)
+		},
+	})
+	metrics.AddGeneratedTokens(r.Context(), runtime.generatedTokens)
+}
+
+func (h *Handler) consumeOllamaStreamAttempt(ctx context.Context, resp *http.Response, runtime *ollamaStreamRuntime, allowDeferEmpty bool) (bool, bool) {
+	defer func() { _ = resp.Body.Close() }()
+	initialType := "text"
+	if runtime.thinkingEnabled {
+		initialType = "thinking"
+	}
+	streamengine.ConsumeSSE(streamengine.ConsumeConfig{
+		Context:             ctx,
+		Body:                resp.Body,
+		ThinkingEnabled:     runtime.thinkingEnabled,
+		InitialType:         initialType,
+		KeepAliveInterval:   time.Duration(dsprotocol.KeepAliveTimeout) * time.Second,
+		IdleTimeout:         time.Duration(dsprotocol.StreamIdleTimeout) * time.Second,
+		MaxKeepAliveNoInput: dsprotocol.MaxKeepaliveCount,
+	}, streamengine.ConsumeHooks{
+		OnParsed: runtime.onParsed,
+	})
+	if runtime.finalize(allowDeferEmpty) {
+		return true, false
+	}
+	return false, true
+}
+
+type ollamaStreamRuntime struct {
+	w        http.ResponseWriter
+	rc       *http.ResponseController
+	canFlush bool
+
+	mode        ollamaMode
+	model       string
+	finalPrompt string
+	timings     ollamaTimings
+
+	thinkingEnabled       bool
+	searchEnabled         bool
+	stripReferenceMarkers bool
+	// bufferContent holds text back when tools are offered, so a tool call
+	// is never streamed as plain text before it is recognized.
+	bufferContent bool
+	toolNames     []string
+	toolsRaw      any
+	// toolCallFormat is the tool call format the request captured.
+	toolCallFormat string
+
+	accumulator       shared.StreamAccumulator
+	contentFilter     bool
+	responseMessageID int
+	generatedTokens   int
+	history           *responsehistory.Session
+}
+
+func newOllamaStreamRuntime(w http.ResponseWriter, rc *http.ResponseController, canFlush bool, mode ollamaMode, stdReq promptcompat.StandardRequest, timings ollamaTimings, history *responsehistory.Session) *ollamaStreamRuntime {
+	stripReferenceMarkers := textclean.StripReferenceMarkersEnabled()
+	return &ollamaStreamRuntime{
+		w:                     w,
+		rc:                    rc,
+		canFlush:              canFlush,
+		mode:                  mode,
+		model:                 stdReq.ResponseModel,
+		finalPrompt:           stdReq.PromptTokenText,
+		timings:               timings,
+		thinkingEnabled:       stdReq.Thinking,
+		searchEnabled:         stdReq.Search,
+		stripReferenceMarkers: stripReferenceMarkers,
+		bufferContent:         len(stdReq.ToolNames) > 0,
+		toolNames:             stdReq.ToolNames,
+		toolsRaw:              stdReq.ToolsRaw,
+		toolCallFormat:        stdReq.ToolPrompt.Format,
+		history:               history,
+		accumulator: shared.StreamAccumulator{
+			ThinkingEnabled:       stdReq.Thinking,
+			SearchEnabled:         stdReq.Search,
+			StripReferenceMarkers: stripReferenceMarkers,
+			Stop:                  sse.NewStopSequenceMatcher(stdReq.StopSequences),
+			Limit:                 sse.NewOutputTokenLimiter(stdReq.MaxOutputTokens, stdReq.ResponseModel),
+		},
+	}
+}
+
+func (s *ollamaStreamRuntime) sendLine(payload map[string]any) {
+	b, _ := json.Marshal(payload)
+	_, _ = s.w.Write(b)
+	_, _ = s.w.Write([]byte("\n"))
+	if s.canFlush {
+		_ = s.rc.Flush()
+	}
+}
+
+// sendError ends the stream the way Ollama reports a mid-stream failure.
+func (s *ollamaStreamRuntime) sendError(message string) {
+	msg := strings.TrimSpace(message)
+	if msg == "" {
+		msg = http.StatusText(http.StatusInternalServerError)
+	}
+	s.sendLine(map[string]any{"error": msg})
+}
+
+func (s *ollamaStreamRuntime) onParsed(parsed sse.LineResult) streamengine.ParsedDecision {
+	if !parsed.Parsed {
+		return streamengine.ParsedDecision{}
+	}
+	if parsed.ResponseMessageID > 0 {
+		s.responseMessageID = parsed.ResponseMessageID
+	}
+	if parsed.ContentFilter || parsed.ErrorMessage != "" || parsed.Stop {
+		if parsed.ContentFilter {
+			s.contentFilter = true
+		}
+		return streamengine.ParsedDecision{Stop: true}
+	}
+	accumulated := s.accumulator.Apply(parsed)
+	s.emitParts(accumulated.Parts)
+	if s.history != nil {
+		s.history.Progress(
+			responsehistory.ThinkingForArchive(s.accumulator.RawThinking.String(), s.accumulator.ToolDetectionThinking.String(), s.accumulator.Thinking.String()),
+			responsehistory.TextForArchive(s.accumulator.RawText.String(), s.accumulator.Text.String()),
+		)
+	}
+	if s.accumulator.StopSequenceMatched() || s.accumulator.OutputLimitReached() {
+		return streamengine.ParsedDecision{ContentSeen: accumulated.ContentSeen, Stop: true}
+	}
+	return streamengine.ParsedDecision{ContentSeen: accumulated.ContentSeen}
+}
+
+func (s *ollamaStreamRuntime) emitParts(parts []shared.StreamPartDelta) {
+	for _, p := range parts {
+		if p.VisibleText == "" || p.CitationOnly || s.bufferContent {
+			continue
+		}
+		if s.timings.firstOutput.IsZero() {
+			s.timings.firstOutput = time.Now()
+		}
+		if p.Type == "thinking" {
+			s.sendLine(buildOllamaChunk(s.mode, s.model, "", p.VisibleText, nil))
+			continue
+		}
+		s.sendLine(buildOllamaChunk(s.mode, s.model, p.VisibleText, "", nil))
+	}
+}
+
+// finalize flushes held-back text and writes the closing object. With
+// deferEmptyOutput it reports an empty answer as retryable instead.
+func (s *ollamaStreamRuntime) finalize(deferEmptyOutput bool) bool {
+	s.emitParts(s.accumulator.FlushStopHold().Parts)
+	turn := assistantturn.BuildTurnFromStreamSnapshot(assistantturn.StreamSnapshot{
+		RawText:            s.accumulator.RawText.String(),
+		VisibleText:        s.accumulator.Text.String(),
+		RawThinking:        s.accumulator.RawThinking.String(),
+		VisibleThinking:    s.accumulator.Thinking.String(),
+		DetectionThinking:  s.accumulator.ToolDetectionThinking.String(),
+		ContentFilter:      s.contentFilter,
+		ResponseMessageID:  s.responseMessageID,
+		OutputLimitReached: s.accumulator.OutputLimitReached(),
+	}, assistantturn.BuildOptions{
+		Model:                 s.model,
+		Prompt:                s.finalPrompt,
+		SearchEnabled:         s.searchEnabled,
+		StripReferenceMarkers: s.stripReferenceMarkers,
+		ToolNames:             s.toolNames,
+		ToolsRaw:              s.toolsRaw,
+		ToolCallFormat:        s.toolCallFormat,
+	})
+	outcome := assistantturn.FinalizeTurn(turn, assistantturn.FinalizeOptions{})
+	if outcome.ShouldFail {
+		if deferEmptyOutput {
+			return false
+		}
+		if s.history != nil {
+			s.history.ErrorTurn(outcome.Error.Status, outcome.Error.Message, outcome.Error.Code, turn)
+		}
+		s.sendError(outcome.Error.Message)
+		return true
+	}
+	s.generatedTokens = outcome.Usage.OutputTokens
+	if s.history != nil {
+		s.history.SuccessTurn(http.StatusOK, turn, responsehistory.GenericUsage(turn))
+	}
+	final := buildOllamaChunk(s.mode, s.model, "", "", nil)
+	if s.bufferContent {
+		final = buildOllamaChunk(s.mode, s.model, turn.Text, turn.Thinking, turn.ToolCalls)
+	}
+	applyOllamaDone(final, turn, s.timings)
+	s.sendLine(final)
+	return true
+}
@@ -0,0 +1,261 @@
+// Package batches serves /v1/batches: an asynchronous queue for
+// non-interactive chat completion requests. A batch is submitted as a list
+// of request bodies, each with the caller's custom_id, and runs in the
+// background on a small worker pool; the caller polls the batch and fetches
+// the results.
+package batches
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+
+	"ds2api/internal/config"
+	"ds2api/internal/httpapi/openai/shared"
+	"ds2api/internal/httpapi/requestctx"
+	"ds2api/internal/util"
+)
+
+const chatEndpoint = "/v1/chat/completions"
+
+type ConfigReader interface {
+	BatchSettings() config.BatchConfig
+}
+
+// Handler owns the batch store and the worker pool shared by all batches.
+type Handler struct {
+	Store ConfigReader
+	Auth  shared.AuthResolver
+	// Dispatch serves each batch request as POST /v1/chat/completions. The
+	// app router is used, so batch requests pass the same middleware and
+	// concurrency limits as interactive ones.
+	Dispatch http.Handler
+
+	mu      sync.Mutex
+	batches *store
+	workers *workerPool
+}
+
+func RegisterRoutes(r chi.Router, h *Handler) {
+	for _, prefix := range []string{"/v1", ""} {
+		r.Post(prefix+"/batches", h.CreateBatch)
+		r.Get(prefix+"/batches", h.ListBatches)
+		r.Get(prefix+"/batches/{batch_id}", h.GetBatch)
+		r.Get(prefix+"/batches/{batch_id}/results", h.GetBatchResults)
+		r.Post(prefix+"/batches/{batch_id}/cancel", h.CancelBatch)
+	}
+}
+
+// CreateBatch validates and enqueues a batch. The body is
+//
+//	{"requests": [{"custom_id": "...", "body": {<chat completion request>}}], "metadata": {...}}
+//
+// Every request must have a unique custom_id and a non-stream body.
+func (h *Handler) CreateBatch(w http.ResponseWriter, r *http.Request) {
+	a, err := h.Auth.DetermineCaller(r)
+	if err != nil {
+		shared.WriteOpenAIAuthError(w, err)
+		return
+	}
+	r.Body = http.MaxBytesReader(w, r.Body, shared.GeneralMaxSize)
+	var req map[string]any
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		shared.WriteOpenAIDecodeError(w, err)
+		return
+	}
+	settings := h.Store.BatchSettings()
+	requests, metadata, err := parseBatchRequest(req, settings.MaxRequests)
+	if err != nil {
+		shared.WriteOpenAIRequestError(w, err)
+		return
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	now := time.Now()
+	b := &batch{
+		id:           "batch_" + strings.ReplaceAll(uuid.NewString(), "-", ""),
+		owner:        a.CallerID,
+		metadata:     metadata,
+		header:       replayHeader(r.Header),
+		remoteAddr:   r.RemoteAddr,
+		requests:     requests,
+		ctx:          ctx,
+		cancel:       cancel,
+		status:       statusInProgress,
+		createdAt:    now,
+		inProgressAt: now,
+		results:      make([]*result, len(requests)),
+	}
+	if err := h.store().add(b, settings.MaxBatches, ttl(settings)); err != nil {
+		cancel()
+		shared.WriteOpenAIErrorWithCode(w, http.StatusTooManyRequests, fmt.Sprintf("Too many batches in progress (limit %d); retry after one finishes.", settings.MaxBatches), "batch_limit_reached")
+		return
+	}
+	config.Logger.InfoContext(r.Context(), "[batch] created", "batch_id", b.id, "total", len(requests))
+	go h.process(b)
+	shared.WriteJSON(w, http.StatusOK, b.object())
+}
+
+func (h *Handler) ListBatches(w http.ResponseWriter, r *http.Request) {
+	a, err := h.Auth.DetermineCaller(r)
+	if err != nil {
+		shared.WriteOpenAIAuthError(w, err)
+		return
+	}
+	items := h.store().list(a.CallerID, ttl(h.Store.BatchSettings()))
+	data := make([]any, 0, len(items))
+	for _, b := range items {
+		data = append(data, b.object())
+	}
+	shared.WriteJSON(w, http.StatusOK, map[string]any{"object": "list", "data": data, "has_more": false})
+}
+
+func (h *Handler) GetBatch(w http.ResponseWriter, r *http.Request) {
+	b, ok := h.lookup(w, r)
+	if !ok {
+		return
+	}
+	shared.WriteJSON(w, http.StatusOK, b.object())
+}
+
+// GetBatchResults returns the results recorded so far in request order; the
+// list is complete once the batch status is completed or cancelled.
+func (h *Handler) GetBatchResults(w http.ResponseWriter, r *http.Request) {
+	b, ok := h.lookup(w, r)
+	if !ok {
+		return
+	}
+	obj := b.object()
+	shared.WriteJSON(w, http.StatusOK, map[string]any{
+		"object":   "list",
+		"batch_id": b.id,
+		"status":   obj["status"],
+		"data":     b.finishedResults(),
+	})
+}
+
+// CancelBatch stops a running batch: requests not yet started are recorded
+// as cancelled and running ones are cut off.
+func (h *Handler) CancelBatch(w http.ResponseWriter, r *http.Request) {
+	b, ok := h.lookup(w, r)
+	if !ok {
+		return
+	}
+	if !b.requestCancel(time.Now()) {
+		shared.WriteOpenAIErrorWithCode(w, http.StatusConflict, "Batch has already finished.", "batch_finished")
+		return
+	}
+	shared.WriteJSON(w, http.StatusOK, b.object())
+}
+
+func (h *Handler) lookup(w http.ResponseWriter, r *http.Request) (*batch, bool) {
+	a, err := h.Auth.DetermineCaller(r)
+	if err != nil {
+		shared.WriteOpenAIAuthError(w, err)
+		return nil, false
+	}
+	id := strings.TrimSpace(chi.URLParam(r, "batch_id"))
+	b, ok := h.store().get(a.CallerID, id, ttl(h.Store.BatchSettings()))
+	if !ok {
+		shared.WriteOpenAIErrorWithCode(w, http.StatusNotFound, "Batch not found.", "batch_not_found")
+		return nil, false
+	}
+	return b, true
+}
+
+// parseBatchRequest checks the batch body and serialises each request body
+// for dispatch. Requests in the OpenAI JSONL line shape (with method and
+// url) are accepted as long as they target the chat completions endpoint.
+func parseBatchRequest(req map[string]any, maxRequests int) ([]request, map[string]any, error) {
+	if _, ok := req["input_file_id"]; ok {
+		return nil, nil, errors.New("input_file_id is not supported; send the requests inline in \"requests\".")
+	}
+	if endpoint, ok := req["endpoint"]; ok && endpoint != chatEndpoint {
+		return nil, nil, fmt.Errorf("endpoint must be %q.", chatEndpoint)
+	}
+	var metadata map[string]any
+	if raw, ok := req["metadata"]; ok && raw != nil {
+		if metadata, ok = raw.(map[string]any); !ok {
+			return nil, nil, errors.New("metadata must be an object.")
+		}
+	}
+	items, ok := req["requests"].([]any)
+	if !ok || len(items) == 0 {
+		return nil, nil, errors.New("requests must be a non-empty array.")
+	}
+	if maxRequests > 0 && len(items) > maxRequests {
+		return nil, nil, fmt.Errorf("A batch holds at most %d requests; got %d.", maxRequests, len(items))
+	}
+	seen := make(map[string]struct{}, len(items))
+	out := make([]request, 0, len(items))
+	for i, raw := range items {
+		item, ok := raw.(map[string]any)
+		if !ok {
+			return nil, nil, fmt.Errorf("requests[%d] must be an object.", i)
+		}
+		customID, _ := item["custom_id"].(string)
+		if strings.TrimSpace(customID) == "" {
+			return nil, nil, fmt.Errorf("requests[%d].custom_id is required.", i)
+		}
+		if _, dup := seen[customID]; dup {
+			return nil, nil, fmt.Errorf("requests[%d].custom_id %q is not unique.", i, customID)
+		}
+		seen[customID] = struct{}{}
+		if url, ok := item["url"]; ok && url != chatEndpoint {
+			return nil, nil, fmt.Errorf("requests[%d].url must be %q.", i, chatEndpoint)
+		}
+		body, ok := item["body"].(map[string]any)
+		if !ok {
+			return nil, nil, fmt.Errorf("requests[%d].body must be a chat completion request object.", i)
+		}
+		if util.ToBool(body["stream"]) {
+			return nil, nil, fmt.Errorf("requests[%d].body.stream is not supported in a batch.", i)
+		}
+		encoded, err := json.Marshal(body)
+		if err != nil {
+			return nil, nil, fmt.Errorf("requests[%d].body: %w", i, err)
+		}
+		out = append(out, request{customID: customID, body: encoded})
+	}
+	return out, metadata, nil
+}
+
+// replayHeader keeps the caller's headers for the batch requests, minus the
+// ones that describe the create call itself.
+func replayHeader(h http.Header) http.Header {
+	out := h.Clone()
+	for _, key := range []string{"Content-Length", "Content-Type", "Idempotency-Key", requestctx.TraceHeader, requestctx.TraceparentHeader, "Accept-Encoding"} {
+		out.Del(key)
+	}
+	return out
+}
+
+func ttl(settings config.BatchConfig) time.Duration {
+	return time.Duration(settings.TTLSeconds) * time.Second
+}
+
+func (h *Handler) store() *store {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.batches == nil {
+		h.batches = newStore()
+	}
+	return h.batches
+}
+
+func (h *Handler) pool() *workerPool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.workers == nil {
+		h.workers = newWorkerPool(func() int { return h.Store.BatchSettings().MaxWorkers })
+	}
+	return h.workers
+}
@@ -0,0 +1,292 @@
+package batches
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"ds2api/internal/auth"
+	"ds2api/internal/config"
+)
+
+type stubSettings struct{ cfg config.BatchConfig }
+
+func (s stubSettings) BatchSettings() config.BatchConfig { return s.cfg }
+
+type callerAuth struct{}
+
+func (callerAuth) Determine(r *http.Request) (*auth.RequestAuth, error) {
+	return callerAuth{}.DetermineCaller(r)
+}
+
+func (callerAuth) DetermineCaller(r *http.Request) (*auth.RequestAuth, error) {
+	key := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if key == "" {
+		return nil, auth.ErrUnauthorized
+	}
+	return &auth.RequestAuth{CallerID: key}, nil
+}
+
+func (callerAuth) Release(*auth.RequestAuth) {}
+
+func newTestServer(t *testing.T, cfg config.BatchConfig, dispatch http.HandlerFunc) http.Handler {
+	t.Helper()
+	if cfg.MaxWorkers == 0 {
+		cfg.MaxWorkers = 2
+	}
+	h := &Handler{Store: stubSettings{cfg: cfg}, Auth: callerAuth{}, Dispatch: dispatch}
+	r := chi.NewRouter()
+	RegisterRoutes(r, h)
+	return r
+}
+
+func doJSON(t *testing.T, srv http.Handler, method, path, key, body string) (int, map[string]any) {
+	t.Helper()
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set("Authorization", "Bearer "+key)
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+	srv.ServeHTTP(rec, req)
+	var out map[string]any
+	if err := json.Unmarshal(rec.Body.Bytes(), &out); err != nil {
+		t.Fatalf("decode %s %s: %v body=%s", method, path, err, rec.Body.String())
+	}
+	return rec.Code, out
+}
+
+func waitForStatus(t *testing.T, srv http.Handler, id, key string, want ...string) map[string]any {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		_, obj := doJSON(t, srv, http.MethodGet, "/v1/batches/"+id, key, "")
+		for _, status := range want {
+			if obj["status"] == status {
+				return obj
+			}
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("batch %s did not reach %v: %#v", id, want, obj)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+// echoDispatch answers each chat request with its last user message, or a
+// 400 envelope when the message is "fail".
+func echoDispatch(t *testing.T) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != chatEndpoint || r.Method != http.MethodPost {
+			t.Errorf("unexpected dispatch %s %s", r.Method, r.URL.Path)
+		}
+		var req struct {
+			Messages []struct {
+				Content string `json:"content"`
+			} `json:"messages"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		content := req.Messages[len(req.Messages)-1].Content
+		w.Header().Set("Content-Type", "application/json")
+		if content == "fail" {
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = io.WriteString(w, `{"error":{"message":"messages are invalid","type":"invalid_request_error","code":"invalid_request"}}`)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"object":  "chat.completion",
+			"choices": []any{map[string]any{"message": map[string]any{"role": "assistant", "content": "echo " + content}}},
+			"auth":    r.Header.Get("Authorization"),
+		})
+	}
+}
+
+func batchBody(prompts ...string) string {
+	items := make([]any, 0, len(prompts))
+	for i, p := range prompts {
+		items = append(items, map[string]any{
+			"custom_id": "req-" + string(rune('a'+i)),
+			"body": map[string]any{
+				"model":    "deepseek-v4-flash",
+				"messages": []any{map[string]any{"role": "user", "content": p}},
+			},
+		})
+	}
+	b, _ := json.Marshal(map[string]any{"requests": items, "metadata": map[string]any{"job": "nightly"}})
+	return string(b)
+}
+
+func TestBatchRunsRequestsAndReturnsResultsInOrder(t *testing.T) {
+	srv := newTestServer(t, config.BatchConfig{}, echoDispatch(t))
+
+	code, created := doJSON(t, srv, http.MethodPost, "/v1/batches", "key-1", batchBody("one", "fail", "three"))
+	if code != http.StatusOK || created["object"] != "batch" || created["endpoint"] != chatEndpoint {
+		t.Fatalf("unexpected create response %d: %#v", code, created)
+	}
+	id, _ := created["id"].(string)
+	obj := waitForStatus(t, srv, id, "key-1", statusCompleted)
+	counts, _ := obj["request_counts"].(map[string]any)
+	if counts["total"] != float64(3) || counts["completed"] != float64(2) || counts["failed"] != float64(1) {
+		t.Fatalf("unexpected request counts: %#v", counts)
+	}
+	if meta, _ := obj["metadata"].(map[string]any); meta["job"] != "nightly" {
+		t.Fatalf("expected metadata to round-trip, got %#v", obj["metadata"])
+	}
+
+	_, results := doJSON(t, srv, http.MethodGet, "/v1/batches/"+id+"/results", "key-1", "")
+	data, _ := results["data"].([]any)
+	if len(data) != 3 {
+		t.Fatalf("expected 3 results, got %#v", results)
+	}
+	first, _ := data[0].(map[string]any)
+	resp, _ := first["response"].(map[string]any)
+	body, _ := resp["body"].(map[string]any)
+	if first["custom_id"] != "req-a" || resp["status_code"] != float64(200) || first["error"] != nil {
+		t.Fatalf("unexpected first result: %#v", first)
+	}
+	if body["auth"] != "Bearer key-1" {
+		t.Fatalf("expected the request to run as the batch caller, got %#v", body["auth"])
+	}
+	second, _ := data[1].(map[string]any)
+	errObj, _ := second["error"].(map[string]any)
+	if second["custom_id"] != "req-b" || errObj["code"] != "invalid_request" || errObj["message"] != "messages are invalid" {
+		t.Fatalf("unexpected failed result: %#v", second)
+	}
+}
+
+func TestBatchIsScopedToItsCaller(t *testing.T) {
+	srv := newTestServer(t, config.BatchConfig{}, echoDispatch(t))
+	_, created := doJSON(t, srv, http.MethodPost, "/v1/batches", "key-1", batchBody("one"))
+	id, _ := created["id"].(string)
+
+	if code, _ := doJSON(t, srv, http.MethodGet, "/v1/batches/"+id, "key-2", ""); code != http.StatusNotFound {
+		t.Fatalf("expected another caller to get 404, got %d", code)
+	}
+	_, list := doJSON(t, srv, http.MethodGet, "/v1/batches", "key-2", "")
+	if data, _ := list["data"].([]any); len(data) != 0 {
+		t.Fatalf("expected an empty list for another caller, got %#v", list)
+	}
+}
+
+func TestCreateBatchRejectsInvalidRequests(t *testing.T) {
+	srv := newTestServer(t, config.BatchConfig{MaxRequests: 2}, echoDispatch(t))
+	cases := map[string]string{
+		"duplicate custom_id": `{"requests":[{"custom_id":"a","body":{}},{"custom_id":"a","body":{}}]}`,
+		"missing custom_id":   `{"requests":[{"body":{}}]}`,
+		"stream":              `{"requests":[{"custom_id":"a","body":{"stream":true}}]}`,
+		"other endpoint":      `{"endpoint":"/v1/embeddings","requests":[{"custom_id":"a","body":{}}]}`,
+		"input file":          `{"input_file_id":"file-1"}`,
+		"too many":            batchBody("1", "2", "3"),
+	}
+	for name, body := range cases {
+		t.Run(name, func(t *testing.T) {
+			code, out := doJSON(t, srv, http.MethodPost, "/v1/batches", "key-1", body)
+			if code != http.StatusBadRequest {
+				t.Fatalf("expected 400, got %d: %#v", code, out)
+			}
+		})
+	}
+}
+
+func TestBatchWorkerPoolBoundsConcurrency(t *testing.T) {
+	var active, peak atomic.Int32
+	release := make(chan struct{})
+	srv := newTestServer(t, config.BatchConfig{MaxWorkers: 2}, func(w http.ResponseWriter, r *http.Request) {
+		n := active.Add(1)
+		for {
+			p := peak.Load()
+			if n <= p || peak.CompareAndSwap(p, n) {
+				break
+			}
+		}
+		<-release
+		active.Add(-1)
+		_, _ = io.WriteString(w, `{}`)
+	})
+	_, created := doJSON(t, srv, http.MethodPost, "/v1/batches", "key-1", batchBody("1", "2", "3", "4", "5"))
+	id, _ := created["id"].(string)
+	time.Sleep(20 * time.Millisecond)
+	close(release)
+	waitForStatus(t, srv, id, "key-1", statusCompleted)
+	if peak.Load() != 2 {
+		t.Fatalf("expected at most 2 concurrent requests, peak was %d", peak.Load())
+	}
+}
+
+func TestBatchRetriesBusyRequests(t *testing.T) {
+	base := busyRetryBaseDelay
+	busyRetryBaseDelay = time.Millisecond
+	t.Cleanup(func() { busyRetryBaseDelay = base })
+	var calls atomic.Int32
+	srv := newTestServer(t, config.BatchConfig{}, func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) == 1 {
+			w.WriteHeader(http.StatusTooManyRequests)
+			_, _ = io.WriteString(w, `{"error":{"message":"busy","code":"rate_limit_exceeded"}}`)
+			return
+		}
+		_, _ = io.WriteString(w, `{"ok":true}`)
+	})
+	_, created := doJSON(t, srv, http.MethodPost, "/v1/batches", "key-1", batchBody("1"))
+	id, _ := created["id"].(string)
+	obj := waitForStatus(t, srv, id, "key-1", statusCompleted)
+	if counts, _ := obj["request_counts"].(map[string]any); counts["completed"] != float64(1) || calls.Load() != 2 {
+		t.Fatalf("expected one retried success, got %#v after %d calls", counts, calls.Load())
+	}
+}
+
+func TestCancelBatchSkipsPendingRequests(t *testing.T) {
+	started := make(chan struct{}, 1)
+	srv := newTestServer(t, config.BatchConfig{MaxWorkers: 1}, func(w http.ResponseWriter, r *http.Request) {
+		started <- struct{}{}
+		<-r.Context().Done()
+		w.WriteHeader(499)
+		_, _ = io.WriteString(w, `{"error":{"message":"request context cancelled","code":"context_cancelled"}}`)
+	})
+	_, created := doJSON(t, srv, http.MethodPost, "/v1/batches", "key-1", batchBody("1", "2", "3"))
+	id, _ := created["id"].(string)
+	<-started
+	if code, obj := doJSON(t, srv, http.MethodPost, "/v1/batches/"+id+"/cancel", "key-1", ""); code != http.StatusOK || obj["status"] != statusCancelling {
+		t.Fatalf("unexpected cancel response %d: %#v", code, obj)
+	}
+	obj := waitForStatus(t, srv, id, "key-1", statusCancelled)
+	if counts, _ := obj["request_counts"].(map[string]any); counts["failed"] != float64(3) {
+		t.Fatalf("expected every request to fail as cancelled, got %#v", counts)
+	}
+	_, results := doJSON(t, srv, http.MethodGet, "/v1/batches/"+id+"/results", "key-1", "")
+	data, _ := results["data"].([]any)
+	last, _ := data[2].(map[string]any)
+	if errObj, _ := last["error"].(map[string]any); errObj["code"] != "batch_cancelled" || last["response"] != nil {
+		t.Fatalf("expected the pending request to be skipped, got %#v", last)
+	}
+	if code, _ := doJSON(t, srv, http.MethodPost, "/v1/batches/"+id+"/cancel", "key-1", ""); code != http.StatusConflict {
+		t.Fatalf("expected 409 cancelling a finished batch, got %d", code)
+	}
+}
+
+func TestStoreEvictsOldestFinishedBatchAtCapacity(t *testing.T) {
+	s := newStore()
+	mk := func(id, status string) *batch {
+		return &batch{id: id, owner: "o", status: status, finishedAt: time.Now()}
+	}
+	if err := s.add(mk("a", statusCompleted), 2, time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.add(mk("b", statusInProgress), 2, time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.add(mk("c", statusInProgress), 2, time.Hour); err != nil {
+		t.Fatalf("expected the finished batch to be evicted, got %v", err)
+	}
+	if _, ok := s.get("o", "a", time.Hour); ok {
+		t.Fatal("expected batch a to be evicted")
+	}
+	if err := s.add(mk("d", statusInProgress), 2, time.Hour); !errors.Is(err, errStoreFull) {
+		t.Fatalf("expected errStoreFull with only running batches, got %v", err)
+	}
+}
@@ -0,0 +1,234 @@
+package batches
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"ds2api/internal/config"
+	"ds2api/internal/httpapi/requestctx"
+)
+
+const (
+	// maxBusyAttempts bounds how often one request is re-sent after a 429
+	// (busy account pool, full upstream queue or rate limit).
+	maxBusyAttempts = 6
+	maxBusyDelay    = time.Minute
+)
+
+var busyRetryBaseDelay = time.Second
+
+// workerPool bounds how many batch requests run at once across all batches.
+// The limit is read on every acquire, so a hot-reloaded setting applies to
+// the next request.
+type workerPool struct {
+	mu     sync.Mutex
+	active int
+	limit  func() int
+	wake   chan struct{}
+}
+
+func newWorkerPool(limit func() int) *workerPool {
+	return &workerPool{limit: limit, wake: make(chan struct{})}
+}
+
+// acquire waits for a free worker; it returns false when ctx ends first.
+func (p *workerPool) acquire(ctx context.Context) bool {
+	for {
+		p.mu.Lock()
+		limit := p.limit()
+		if limit < 1 {
+			limit = 1
+		}
+		if p.active < limit {
+			p.active++
+			p.mu.Unlock()
+			return true
+		}
+		wake := p.wake
+		p.mu.Unlock()
+		select {
+		case <-ctx.Done():
+			return false
+		case <-wake:
+		}
+	}
+}
+
+func (p *workerPool) release() {
+	p.mu.Lock()
+	p.active--
+	close(p.wake)
+	p.wake = make(chan struct{})
+	p.mu.Unlock()
+}
+
+// process runs every request of b through the worker pool and marks the
+// batch finished once all of them have a result. Requests still waiting when
+// the batch is cancelled are recorded as cancelled without being sent.
+func (h *Handler) process(b *batch) {
+	var wg sync.WaitGroup
+	for i := range b.requests {
+		if !h.pool().acquire(b.ctx) {
+			b.record(i, cancelledResult(b, i))
+			continue
+		}
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			defer h.pool().release()
+			b.record(i, h.runRequest(b, i))
+		}(i)
+	}
+	wg.Wait()
+	b.finish(time.Now())
+	config.Logger.Info("[batch] finished", "batch_id", b.id, "status", b.object()["status"], "total", len(b.requests))
+}
+
+// runRequest sends one batch request through Dispatch as a non-stream
+// POST /v1/chat/completions from the batch's caller, so it takes the same
+// path as an interactive request: auth and account acquisition, model
+// allowlist, rate limit, deadline, prompt building and upstream retries. A
+// 429 is retried after a backoff, honouring Retry-After, instead of failing
+// the request while interactive traffic holds the capacity. Batch requests
+// take account and upstream slots on the same terms as interactive ones; the
+// MaxWorkers cap is what keeps head room for interactive traffic.
+func (h *Handler) runRequest(b *batch, i int) *result {
+	id := itemID(b.id, i)
+	for attempt := 1; ; attempt++ {
+		if b.ctx.Err() != nil {
+			return cancelledResult(b, i)
+		}
+		req, err := http.NewRequestWithContext(b.ctx, http.MethodPost, chatEndpoint, bytes.NewReader(b.requests[i].body))
+		if err != nil {
+			return &result{
+				ID:       id,
+				CustomID: b.requests[i].customID,
+				Error:    &resultError{Code: "internal_error", Message: err.Error()},
+			}
+		}
+		req.Header = b.header.Clone()
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set(requestctx.TraceHeader, id)
+		req.RemoteAddr = b.remoteAddr
+		rec := newResponseBuffer()
+		h.Dispatch.ServeHTTP(rec, req)
+		if rec.code == http.StatusTooManyRequests && attempt < maxBusyAttempts {
+			delay := busyRetryDelay(attempt, rec.header.Get("Retry-After"))
+			config.Logger.Info("[batch] request rejected as busy; retrying", "batch_id", b.id, "custom_id", b.requests[i].customID, "attempt", attempt, "delay", delay)
+			if !sleepWithContext(b.ctx, delay) {
+				return cancelledResult(b, i)
+			}
+			continue
+		}
+		return requestResult(b, i, id, rec)
+	}
+}
+
+func requestResult(b *batch, i int, id string, rec *responseBuffer) *result {
+	body := bytes.TrimSpace(rec.body.Bytes())
+	if !json.Valid(body) {
+		body, _ = json.Marshal(string(body))
+	}
+	res := &result{
+		ID:       id,
+		CustomID: b.requests[i].customID,
+		Response: &resultResponse{StatusCode: rec.code, RequestID: id, Body: body},
+	}
+	if rec.code >= http.StatusBadRequest {
+		res.Error = envelopeError(body, rec.code)
+	}
+	return res
+}
+
+// responseBuffer captures the response of a re-dispatched batch request.
+// Like net/http, a handler that writes without calling WriteHeader gets 200.
+type responseBuffer struct {
+	header      http.Header
+	code        int
+	wroteHeader bool
+	body        bytes.Buffer
+}
+
+func newResponseBuffer() *responseBuffer {
+	return &responseBuffer{header: http.Header{}, code: http.StatusOK}
+}
+
+func (r *responseBuffer) Header() http.Header { return r.header }
+
+func (r *responseBuffer) WriteHeader(code int) {
+	if r.wroteHeader {
+		return
+	}
+	r.wroteHeader = true
+	r.code = code
+}
+
+func (r *responseBuffer) Write(p []byte) (int, error) {
+	r.WriteHeader(http.StatusOK)
+	return r.body.Write(p)
+}
+
+// Flush lets handlers that flush as they write run unchanged.
+func (r *responseBuffer) Flush() {}
+
+// envelopeError lifts code and message out of an OpenAI error envelope.
+func envelopeError(body []byte, status int) *resultError {
+	var envelope struct {
+		Error struct {
+			Code    any    `json:"code"`
+			Type    string `json:"type"`
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	_ = json.Unmarshal(body, &envelope)
+	out := &resultError{Message: envelope.Error.Message}
+	if code, ok := envelope.Error.Code.(string); ok {
+		out.Code = code
+	}
+	if out.Code == "" {
+		out.Code = envelope.Error.Type
+	}
+	if out.Code == "" {
+		out.Code = "http_" + strconv.Itoa(status)
+	}
+	if out.Message == "" {
+		out.Message = http.StatusText(status)
+	}
+	return out
+}
+
+func cancelledResult(b *batch, i int) *result {
+	return &result{
+		ID:       itemID(b.id, i),
+		CustomID: b.requests[i].customID,
+		Error:    &resultError{Code: "batch_cancelled", Message: "The batch was cancelled before this request ran."},
+	}
+}
+
+func itemID(batchID string, i int) string {
+	return strings.Replace(batchID, "batch_", "batch_req_", 1) + "_" + strconv.Itoa(i)
+}
+
+func busyRetryDelay(attempt int, retryAfter string) time.Duration {
+	if seconds, err := strconv.Atoi(strings.TrimSpace(retryAfter)); err == nil && seconds > 0 {
+		return min(time.Duration(seconds)*time.Second, maxBusyDelay)
+	}
+	return min(busyRetryBaseDelay<<(attempt-1), maxBusyDelay)
+}
+
+func sleepWithContext(ctx context.Context, d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
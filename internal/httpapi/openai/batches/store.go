@@ -0,0 +1,249 @@
+package batches
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	statusInProgress = "in_progress"
+	statusCancelling = "cancelling"
+	statusCompleted  = "completed"
+	statusCancelled  = "cancelled"
+)
+
+var errStoreFull = errors.New("too many batches in progress")
+
+type request struct {
+	customID string
+	body     []byte
+}
+
+// result is one request's outcome in the OpenAI batch output shape. Response
+// is set whenever the chat handler ran; Error is set when the request failed
+// or was never run.
+type result struct {
+	ID       string          `json:"id"`
+	CustomID string          `json:"custom_id"`
+	Response *resultResponse `json:"response"`
+	Error    *resultError    `json:"error"`
+}
+
+type resultResponse struct {
+	StatusCode int             `json:"status_code"`
+	RequestID  string          `json:"request_id"`
+	Body       json.RawMessage `json:"body"`
+}
+
+type resultError struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+type batch struct {
+	id       string
+	owner    string
+	metadata map[string]any
+	// header and remoteAddr are replayed on every request so each one is
+	// authenticated and rate limited as the caller that created the batch.
+	header     http.Header
+	remoteAddr string
+	requests   []request
+	ctx        context.Context
+	cancel     context.CancelFunc
+
+	mu           sync.Mutex
+	status       string
+	createdAt    time.Time
+	inProgressAt time.Time
+	finishedAt   time.Time
+	cancelledAt  time.Time
+	results      []*result
+	completed    int
+	failed       int
+}
+
+func (b *batch) finished() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.status == statusCompleted || b.status == statusCancelled
+}
+
+func (b *batch) record(i int, res *result) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.results[i] != nil {
+		return
+	}
+	b.results[i] = res
+	if res.Error != nil {
+		b.failed++
+	} else {
+		b.completed++
+	}
+}
+
+func (b *batch) finish(now time.Time) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.finishedAt = now
+	if b.status == statusCancelling {
+		b.status = statusCancelled
+		return
+	}
+	b.status = statusCompleted
+}
+
+// requestCancel moves a running batch to cancelling. It reports false once
+// the batch has finished.
+func (b *batch) requestCancel(now time.Time) bool {
+	b.mu.Lock()
+	if b.status != statusInProgress && b.status != statusCancelling {
+		b.mu.Unlock()
+		return false
+	}
+	if b.status == statusInProgress {
+		b.status = statusCancelling
+		b.cancelledAt = now
+	}
+	b.mu.Unlock()
+	b.cancel()
+	return true
+}
+
+// object renders the batch in the OpenAI batch object shape.
+func (b *batch) object() map[string]any {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	out := map[string]any{
+		"id":                b.id,
+		"object":            "batch",
+		"endpoint":          chatEndpoint,
+		"status":            b.status,
+		"created_at":        b.createdAt.Unix(),
+		"in_progress_at":    unixOrNil(b.inProgressAt),
+		"completed_at":      nil,
+		"cancelling_at":     unixOrNil(b.cancelledAt),
+		"cancelled_at":      nil,
+		"request_counts":    map[string]any{"total": len(b.requests), "completed": b.completed, "failed": b.failed},
+		"metadata":          b.metadata,
+		"errors":            nil,
+		"completion_window": "24h",
+	}
+	switch b.status {
+	case statusCompleted:
+		out["completed_at"] = b.finishedAt.Unix()
+	case statusCancelled:
+		out["cancelled_at"] = b.finishedAt.Unix()
+	}
+	return out
+}
+
+// finishedResults returns the results recorded so far in request order.
+func (b *batch) finishedResults() []*result {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	out := make([]*result, 0, b.completed+b.failed)
+	for _, res := range b.results {
+		if res != nil {
+			out = append(out, res)
+		}
+	}
+	return out
+}
+
+func unixOrNil(t time.Time) any {
+	if t.IsZero() {
+		return nil
+	}
+	return t.Unix()
+}
+
+// store keeps batches per caller in creation order so the oldest finished
+// ones can be dropped when the cap is reached or their retention ends.
+type store struct {
+	mu    sync.Mutex
+	items map[string]*batch
+	order []*batch
+	now   func() time.Time
+}
+
+func newStore() *store {
+	return &store{items: map[string]*batch{}, now: time.Now}
+}
+
+func storeKey(owner, id string) string {
+	return owner + "\x00" + id
+}
+
+// add stores b, evicting the oldest finished batches beyond maxBatches. It
+// fails when the store is full of batches that are still running.
+func (s *store) add(b *batch, maxBatches int, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sweepLocked(ttl)
+	if maxBatches > 0 && len(s.order) >= maxBatches {
+		for i, old := range s.order {
+			if old.finished() {
+				s.removeLocked(i)
+				break
+			}
+		}
+		if len(s.order) >= maxBatches {
+			return errStoreFull
+		}
+	}
+	s.items[storeKey(b.owner, b.id)] = b
+	s.order = append(s.order, b)
+	return nil
+}
+
+func (s *store) get(owner, id string, ttl time.Duration) (*batch, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sweepLocked(ttl)
+	b, ok := s.items[storeKey(owner, id)]
+	return b, ok
+}
+
+// list returns the caller's batches, newest first.
+func (s *store) list(owner string, ttl time.Duration) []*batch {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sweepLocked(ttl)
+	var out []*batch
+	for i := len(s.order) - 1; i >= 0; i-- {
+		if s.order[i].owner == owner {
+			out = append(out, s.order[i])
+		}
+	}
+	return out
+}
+
+func (s *store) sweepLocked(ttl time.Duration) {
+	if ttl <= 0 {
+		return
+	}
+	cutoff := s.now().Add(-ttl)
+	for i := 0; i < len(s.order); {
+		b := s.order[i]
+		b.mu.Lock()
+		expired := (b.status == statusCompleted || b.status == statusCancelled) && b.finishedAt.Before(cutoff)
+		b.mu.Unlock()
+		if expired {
+			s.removeLocked(i)
+			continue
+		}
+		i++
+	}
+}
+
+func (s *store) removeLocked(i int) {
+	b := s.order[i]
+	delete(s.items, storeKey(b.owner, b.id))
+	s.order = append(s.order[:i], s.order[i+1:]...)
+}
@@ -66,10 +66,10 @@ func startChatHistory(store *chathistory.Store, r *http.Request, a *auth.Request
 	}
 	if err != nil {
 		if entry.ID == "" {
-			config.Logger.Warn("[chat_history] start failed", "error", err)
+			config.Logger.WarnContext(r.Context(), "[chat_history] start failed", "error", err)
 			return nil
 		}
-		config.Logger.Warn("[chat_history] start persisted in memory after write failure", "error", err)
+		config.Logger.WarnContext(r.Context(), "[chat_history] start persisted in memory after write failure", "error", err)
 	}
 	return session
 }
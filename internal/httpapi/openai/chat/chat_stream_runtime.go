@@ -1,6 +1,7 @@
 package chat
 
 import (
+	"context"
 	"encoding/json"
 	"net/http"
 	"strings"
@@ -8,6 +9,7 @@ import (
 	"ds2api/internal/assistantturn"
 	openaifmt "ds2api/internal/format/openai"
 	"ds2api/internal/httpapi/openai/shared"
+	"ds2api/internal/httpapi/requestctx"
 	"ds2api/internal/promptcompat"
 	"ds2api/internal/sse"
 	streamengine "ds2api/internal/stream"
@@ -27,10 +29,21 @@ type chatStreamRuntime struct {
 	toolNames     []string
 	toolsRaw      any
 	toolChoice    promptcompat.ToolChoicePolicy
+	// responseFormat is checked against the streamed text once it ends.
+	responseFormat promptcompat.ResponseFormat
 
 	thinkingEnabled       bool
 	searchEnabled         bool
 	stripReferenceMarkers bool
+	includeUsage          bool
+
+	// choiceIndex tags every chunk for `n > 1` streams; fanout is shared by
+	// those sibling runtimes and nil for ordinary single-choice streams.
+	choiceIndex int
+	fanout      *chatChoiceFanout
+	// legacyFunctions rewrites tool call deltas into `function_call` for
+	// requests that used the deprecated `functions` field.
+	legacyFunctions bool
 
 	firstChunkSent       bool
 	bufferToolContent    bool
@@ -41,13 +54,18 @@ type chatStreamRuntime struct {
 	toolSieve         toolstream.State
 	streamToolCallIDs map[int]string
 	streamToolNames   map[int]string
+	streamToolArgs    map[int]string
 	accumulator       shared.StreamAccumulator
 	responseMessageID int
+	// contentFiltered records an upstream filter stop that arrived after
+	// visible text, so the final chunk still reports "content_filter".
+	contentFiltered bool
 
 	finalThinking     string
 	finalText         string
 	finalFinishReason string
 	finalUsage        map[string]any
+	finalTurnUsage    assistantturn.Usage
 	finalErrorStatus  int
 	finalErrorMessage string
 	finalErrorCode    string
@@ -114,6 +132,7 @@ func newChatStreamRuntime(
 		emitEarlyToolDeltas:   emitEarlyToolDeltas,
 		streamToolCallIDs:     map[int]string{},
 		streamToolNames:       map[int]string{},
+		streamToolArgs:        map[int]string{},
 		accumulator: shared.StreamAccumulator{
 			ThinkingEnabled:       thinkingEnabled,
 			SearchEnabled:         searchEnabled,
@@ -126,12 +145,17 @@ func (s *chatStreamRuntime) sendKeepAlive() {
 	if !s.canFlush {
 		return
 	}
+	defer s.fanout.lock()()
 	_, _ = s.w.Write([]byte(": keep-alive\n\n"))
 	_ = s.rc.Flush()
 }
 
 func (s *chatStreamRuntime) sendChunk(v any) {
+	if chunk, ok := v.(map[string]any); ok && s.legacyFunctions {
+		openaifmt.ConvertToLegacyFunctionCalls(chunk)
+	}
 	b, _ := json.Marshal(v)
+	defer s.fanout.lock()()
 	_, _ = s.w.Write([]byte("data: "))
 	_, _ = s.w.Write(b)
 	_, _ = s.w.Write([]byte("\n\n"))
@@ -148,16 +172,24 @@ func (s *chatStreamRuntime) sendDelta(delta map[string]any) {
 		delta["role"] = "assistant"
 		s.firstChunkSent = true
 	}
-	s.sendChunk(openaifmt.BuildChatStreamChunk(
-		s.completionID,
-		s.created,
-		s.model,
-		[]map[string]any{openaifmt.BuildChatStreamDeltaChoice(0, delta)},
-		nil,
-	))
+	s.sendChunk(s.buildChunk([]map[string]any{openaifmt.BuildChatStreamDeltaChoice(s.choiceIndex, delta)}, nil))
+}
+
+// buildChunk renders one chat.completion.chunk. With include_usage every
+// chunk but the final usage chunk carries `"usage": null`, as OpenAI does.
+func (s *chatStreamRuntime) buildChunk(choices []map[string]any, usage map[string]any) map[string]any {
+	chunk := openaifmt.BuildChatStreamChunk(s.completionID, s.created, s.model, choices, usage)
+	if s.includeUsage && len(usage) == 0 {
+		chunk["usage"] = nil
+	}
+	return chunk
 }
 
 func (s *chatStreamRuntime) sendDone() {
+	if s.fanout != nil {
+		// The fanout writes a single [DONE] after every choice finished.
+		return
+	}
 	_, _ = s.w.Write([]byte("data: [DONE]\n\n"))
 	if s.canFlush {
 		_ = s.rc.Flush()
@@ -168,7 +200,7 @@ func (s *chatStreamRuntime) sendFailedChunk(status int, message, code string) {
 	s.finalErrorStatus = status
 	s.finalErrorMessage = message
 	s.finalErrorCode = code
-	s.sendChunk(map[string]any{
+	s.sendChunk(requestctx.WithTraceID(s.w, map[string]any{
 		"status_code": status,
 		"error": map[string]any{
 			"message": message,
@@ -176,13 +208,24 @@ func (s *chatStreamRuntime) sendFailedChunk(status int, message, code string) {
 			"code":    code,
 			"param":   nil,
 		},
-	})
+	}))
 	s.sendDone()
 }
 
-func (s *chatStreamRuntime) markContextCancelled() {
-	s.finalErrorStatus = 499
-	s.finalErrorMessage = "request context cancelled"
+// markContextCancelled records why the stream stopped early. A disconnected
+// client cannot receive anything more, but when the request deadline expired
+// or a server shutdown cut the stream off the client is still listening, so
+// it gets a failed chunk first.
+func (s *chatStreamRuntime) markContextCancelled(ctx context.Context) {
+	switch requestctx.CancelReason(ctx) {
+	case requestctx.ReasonDeadlineExceeded:
+		s.sendFailedChunk(http.StatusGatewayTimeout, requestctx.TimeoutMessage, requestctx.CodeRequestTimeout)
+	case requestctx.ReasonServerShutdown:
+		s.sendFailedChunk(http.StatusServiceUnavailable, requestctx.ShutdownMessage, requestctx.CodeServerShutdown)
+	default:
+		s.finalErrorStatus = 499
+		s.finalErrorMessage = "request context cancelled"
+	}
 	s.finalErrorCode = string(streamengine.StopReasonContextCancelled)
 	s.finalThinking = s.accumulator.Thinking.String()
 	s.finalText = cleanVisibleOutput(s.accumulator.Text.String(), s.stripReferenceMarkers)
@@ -210,12 +253,14 @@ func (s *chatStreamRuntime) historyThinking() string {
 func (s *chatStreamRuntime) resetStreamToolCallState() {
 	s.streamToolCallIDs = map[int]string{}
 	s.streamToolNames = map[int]string{}
+	s.streamToolArgs = map[int]string{}
 }
 
 func (s *chatStreamRuntime) finalize(finishReason string, deferEmptyOutput bool) bool {
 	s.finalErrorStatus = 0
 	s.finalErrorMessage = ""
 	s.finalErrorCode = ""
+	s.emitAccumulatedParts(s.accumulator.FlushStopHold().Parts)
 	finalThinking := s.accumulator.Thinking.String()
 	finalToolDetectionThinking := s.accumulator.ToolDetectionThinking.String()
 	finalText := s.accumulator.Text.String()
@@ -225,10 +270,11 @@ func (s *chatStreamRuntime) finalize(finishReason string, deferEmptyOutput bool)
 		RawThinking:           s.accumulator.RawThinking.String(),
 		VisibleThinking:       finalThinking,
 		DetectionThinking:     finalToolDetectionThinking,
-		ContentFilter:         finishReason == "content_filter",
+		ContentFilter:         finishReason == "content_filter" || s.contentFiltered,
 		ResponseMessageID:     s.responseMessageID,
 		AlreadyEmittedCalls:   s.toolCallsEmitted,
 		AlreadyEmittedToolRaw: s.toolCallsDoneEmitted,
+		OutputLimitReached:    s.accumulator.OutputLimitReached(),
 	}, assistantturn.BuildOptions{
 		Model:                 s.model,
 		Prompt:                s.finalPrompt,
@@ -238,12 +284,14 @@ func (s *chatStreamRuntime) finalize(finishReason string, deferEmptyOutput bool)
 		ToolNames:             s.toolNames,
 		ToolsRaw:              s.toolsRaw,
 		ToolChoice:            s.toolChoice,
+		ToolCallFormat:        s.toolSieve.CallFormat(),
+		ResponseFormat:        s.responseFormat,
 	})
 	s.finalThinking = turn.Thinking
 	s.finalText = turn.Text
 	if len(turn.ToolCalls) > 0 && !s.toolCallsDoneEmitted {
 		s.sendDelta(map[string]any{
-			"tool_calls": formatFinalStreamToolCallsWithStableIDs(turn.ToolCalls, s.streamToolCallIDs, s.toolsRaw),
+			"tool_calls": formatFinalStreamToolCallsWithStableIDs(turn.ToolCalls, s.streamToolCallIDs, s.streamToolNames, s.streamToolArgs, s.toolsRaw),
 		})
 		s.toolCallsEmitted = true
 		s.toolCallsDoneEmitted = true
@@ -255,7 +303,7 @@ func (s *chatStreamRuntime) finalize(finishReason string, deferEmptyOutput bool)
 				s.toolCallsEmitted = true
 				s.toolCallsDoneEmitted = true
 				s.sendDelta(map[string]any{
-					"tool_calls": formatFinalStreamToolCallsWithStableIDs(evt.ToolCalls, s.streamToolCallIDs, s.toolsRaw),
+					"tool_calls": formatFinalStreamToolCallsWithStableIDs(evt.ToolCalls, s.streamToolCallIDs, s.streamToolNames, s.streamToolArgs, s.toolsRaw),
 				})
 				s.resetStreamToolCallState()
 			}
@@ -276,7 +324,9 @@ func (s *chatStreamRuntime) finalize(finishReason string, deferEmptyOutput bool)
 	})
 	if outcome.ShouldFail {
 		status, message, code := outcome.Error.Status, outcome.Error.Message, outcome.Error.Code
-		if deferEmptyOutput {
+		// JSON-mode failures follow text the client already has, so they
+		// are reported now rather than retried.
+		if deferEmptyOutput && !assistantturn.IsResponseFormatError(outcome.Error) {
 			s.finalErrorStatus = status
 			s.finalErrorMessage = message
 			s.finalErrorCode = code
@@ -288,13 +338,21 @@ func (s *chatStreamRuntime) finalize(finishReason string, deferEmptyOutput bool)
 	usage := assistantturn.OpenAIChatUsage(turn)
 	s.finalFinishReason = outcome.FinishReason
 	s.finalUsage = usage
-	s.sendChunk(openaifmt.BuildChatStreamChunk(
-		s.completionID,
-		s.created,
-		s.model,
-		[]map[string]any{openaifmt.BuildChatStreamFinishChoice(0, outcome.FinishReason)},
-		usage,
-	))
+	s.finalTurnUsage = turn.Usage
+	if s.fanout != nil {
+		// Usage for `n > 1` is reported once, summed, by the fanout.
+		s.sendChunk(s.buildChunk([]map[string]any{openaifmt.BuildChatStreamFinishChoice(s.choiceIndex, outcome.FinishReason)}, nil))
+		return true
+	}
+	finish := []map[string]any{openaifmt.BuildChatStreamFinishChoice(0, outcome.FinishReason)}
+	if s.includeUsage {
+		// stream_options.include_usage: OpenAI clients read usage only from
+		// a final chunk whose choices array is empty.
+		s.sendChunk(s.buildChunk(finish, nil))
+		s.sendChunk(s.buildChunk([]map[string]any{}, usage))
+	} else {
+		s.sendChunk(s.buildChunk(finish, usage))
+	}
 	s.sendDone()
 	return true
 }
@@ -310,6 +368,7 @@ func (s *chatStreamRuntime) onParsed(parsed sse.LineResult) streamengine.ParsedD
 		if strings.TrimSpace(s.accumulator.Text.String()) == "" {
 			return streamengine.ParsedDecision{Stop: true, StopReason: streamengine.StopReason("content_filter")}
 		}
+		s.contentFiltered = true
 		return streamengine.ParsedDecision{Stop: true, StopReason: streamengine.StopReasonHandlerRequested}
 	}
 	if parsed.ErrorMessage != "" {
@@ -319,9 +378,17 @@ func (s *chatStreamRuntime) onParsed(parsed sse.LineResult) streamengine.ParsedD
 		return streamengine.ParsedDecision{Stop: true, StopReason: streamengine.StopReasonHandlerRequested}
 	}
 
-	batch := chatDeltaBatch{runtime: s}
 	accumulated := s.accumulator.Apply(parsed)
-	for _, p := range accumulated.Parts {
+	s.emitAccumulatedParts(accumulated.Parts)
+	if s.accumulator.StopSequenceMatched() || s.accumulator.OutputLimitReached() {
+		return streamengine.ParsedDecision{ContentSeen: accumulated.ContentSeen, Stop: true, StopReason: streamengine.StopReasonHandlerRequested}
+	}
+	return streamengine.ParsedDecision{ContentSeen: accumulated.ContentSeen}
+}
+
+func (s *chatStreamRuntime) emitAccumulatedParts(parts []shared.StreamPartDelta) {
+	batch := chatDeltaBatch{runtime: s}
+	for _, p := range parts {
 		if p.Type == "thinking" {
 			batch.append("reasoning_content", p.VisibleText)
 			continue
@@ -349,6 +416,9 @@ func (s *chatStreamRuntime) onParsed(parsed sse.LineResult) streamengine.ParsedD
 					if len(formatted) == 0 {
 						continue
 					}
+					for _, d := range filtered {
+						s.streamToolArgs[d.Index] += d.Arguments
+					}
 					batch.flush()
 					tcDelta := map[string]any{
 						"tool_calls": formatted,
@@ -362,7 +432,7 @@ func (s *chatStreamRuntime) onParsed(parsed sse.LineResult) streamengine.ParsedD
 					s.toolCallsEmitted = true
 					s.toolCallsDoneEmitted = true
 					tcDelta := map[string]any{
-						"tool_calls": formatFinalStreamToolCallsWithStableIDs(evt.ToolCalls, s.streamToolCallIDs, s.toolsRaw),
+						"tool_calls": formatFinalStreamToolCallsWithStableIDs(evt.ToolCalls, s.streamToolCallIDs, s.streamToolNames, s.streamToolArgs, s.toolsRaw),
 					}
 					s.sendDelta(tcDelta)
 					s.resetStreamToolCallState()
@@ -379,5 +449,4 @@ func (s *chatStreamRuntime) onParsed(parsed sse.LineResult) streamengine.ParsedD
 		}
 	}
 	batch.flush()
-	return streamengine.ParsedDecision{ContentSeen: accumulated.ContentSeen}
 }
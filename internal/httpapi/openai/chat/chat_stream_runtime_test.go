@@ -3,11 +3,13 @@ package chat
 import (
 	"net/http"
 	"net/http/httptest"
+	"strconv"
 	"strings"
 	"testing"
 	"time"
 
 	"ds2api/internal/promptcompat"
+	"ds2api/internal/sse"
 )
 
 func TestChatStreamKeepAliveUsesCommentOnly(t *testing.T) {
@@ -75,3 +77,221 @@ func TestChatStreamFinalizeEnforcesRequiredToolChoice(t *testing.T) {
 		t.Fatalf("expected tool choice error in stream body, got %s", rec.Body.String())
 	}
 }
+
+func TestChatStreamIncludeUsageEmitsTrailingUsageChunk(t *testing.T) {
+	for _, includeUsage := range []bool{false, true} {
+		rec := httptest.NewRecorder()
+		runtime := newChatStreamRuntime(
+			rec,
+			http.NewResponseController(rec),
+			true,
+			"chatcmpl-test",
+			time.Now().Unix(),
+			"deepseek-v4-flash",
+			"prompt",
+			false,
+			false,
+			true,
+			nil,
+			nil,
+			promptcompat.DefaultToolChoicePolicy(),
+			false,
+			false,
+		)
+		runtime.includeUsage = includeUsage
+		req := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", nil)
+		resp := makeOpenAISSEHTTPResponse(`data: {"p":"response/content","v":"hello"}`, `data: [DONE]`)
+		h := &Handler{}
+		if terminal, _ := h.consumeChatStreamAttempt(req, resp, runtime, "text", false, nil, false); !terminal {
+			t.Fatalf("expected terminal stream write")
+		}
+
+		frames, done := parseSSEDataFrames(t, rec.Body.String())
+		if !done || len(frames) == 0 {
+			t.Fatalf("expected frames and [DONE], body=%s", rec.Body.String())
+		}
+		last := frames[len(frames)-1]
+		choices, _ := last["choices"].([]any)
+		usage, _ := last["usage"].(map[string]any)
+		if includeUsage {
+			if len(choices) != 0 || usage == nil || usage["total_tokens"] == nil {
+				t.Fatalf("expected usage-only final chunk, got %#v", last)
+			}
+			for _, frame := range frames[:len(frames)-1] {
+				if v, ok := frame["usage"]; !ok || v != nil {
+					t.Fatalf("expected usage null before the final chunk, got %#v", frame)
+				}
+			}
+			continue
+		}
+		if len(choices) == 0 {
+			t.Fatalf("expected no usage-only chunk without include_usage, got %#v", last)
+		}
+	}
+}
+
+func TestChatStreamStopSequenceTruncatesAcrossChunks(t *testing.T) {
+	rec := httptest.NewRecorder()
+	runtime := newChatStreamRuntime(
+		rec,
+		http.NewResponseController(rec),
+		true,
+		"chatcmpl-test",
+		time.Now().Unix(),
+		"deepseek-v4-flash",
+		"prompt",
+		false,
+		false,
+		true,
+		nil,
+		nil,
+		promptcompat.DefaultToolChoicePolicy(),
+		false,
+		false,
+	)
+	runtime.accumulator.Stop = sse.NewStopSequenceMatcher([]string{"</end>"})
+	req := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", nil)
+	resp := makeOpenAISSEHTTPResponse(
+		`data: {"p":"response/content","v":"hello </e"}`,
+		`data: {"p":"response/content","v":"nd> ignored"}`,
+		`data: {"p":"response/content","v":" more"}`,
+		`data: [DONE]`,
+	)
+	h := &Handler{}
+	if terminal, _ := h.consumeChatStreamAttempt(req, resp, runtime, "text", false, nil, false); !terminal {
+		t.Fatalf("expected terminal stream write")
+	}
+
+	frames, done := parseSSEDataFrames(t, rec.Body.String())
+	if !done {
+		t.Fatalf("expected [DONE], body=%s", rec.Body.String())
+	}
+	var content strings.Builder
+	finishReason := ""
+	for _, frame := range frames {
+		choices, _ := frame["choices"].([]any)
+		for _, c := range choices {
+			choice, _ := c.(map[string]any)
+			delta, _ := choice["delta"].(map[string]any)
+			if text, ok := delta["content"].(string); ok {
+				content.WriteString(text)
+			}
+			if reason, ok := choice["finish_reason"].(string); ok {
+				finishReason = reason
+			}
+		}
+	}
+	if content.String() != "hello " {
+		t.Fatalf("expected content truncated before stop sequence, got %q", content.String())
+	}
+	if strings.Contains(rec.Body.String(), "</e") || strings.Contains(rec.Body.String(), "ignored") {
+		t.Fatalf("stop sequence prefix or trailing text leaked: %s", rec.Body.String())
+	}
+	if finishReason != "stop" {
+		t.Fatalf("expected finish_reason stop, got %q", finishReason)
+	}
+}
+
+func TestChatStreamMaxOutputTokensFinishesWithLength(t *testing.T) {
+	rec := httptest.NewRecorder()
+	runtime := newChatStreamRuntime(
+		rec,
+		http.NewResponseController(rec),
+		true,
+		"chatcmpl-test",
+		time.Now().Unix(),
+		"deepseek-v4-flash",
+		"prompt",
+		false,
+		false,
+		true,
+		nil,
+		nil,
+		promptcompat.DefaultToolChoicePolicy(),
+		false,
+		false,
+	)
+	runtime.accumulator.Limit = sse.NewOutputTokenLimiter(2, "deepseek-v4-flash")
+	req := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", nil)
+	resp := makeOpenAISSEHTTPResponse(
+		`data: {"p":"response/content","v":"one"}`,
+		`data: {"p":"response/content","v":" two three four five six seven eight nine ten"}`,
+		`data: {"p":"response/content","v":" ignored"}`,
+		`data: [DONE]`,
+	)
+	h := &Handler{}
+	if terminal, _ := h.consumeChatStreamAttempt(req, resp, runtime, "text", false, nil, false); !terminal {
+		t.Fatalf("expected terminal stream write")
+	}
+
+	frames, done := parseSSEDataFrames(t, rec.Body.String())
+	if !done {
+		t.Fatalf("expected [DONE], body=%s", rec.Body.String())
+	}
+	var content strings.Builder
+	finishReason := ""
+	for _, frame := range frames {
+		choices, _ := frame["choices"].([]any)
+		for _, c := range choices {
+			choice, _ := c.(map[string]any)
+			delta, _ := choice["delta"].(map[string]any)
+			if text, ok := delta["content"].(string); ok {
+				content.WriteString(text)
+			}
+			if reason, ok := choice["finish_reason"].(string); ok {
+				finishReason = reason
+			}
+		}
+	}
+	if !strings.HasPrefix(content.String(), "one") || strings.Contains(rec.Body.String(), "ignored") || strings.Contains(content.String(), "ten") {
+		t.Fatalf("expected content cut at the token budget, got %q", content.String())
+	}
+	if finishReason != "length" {
+		t.Fatalf("expected finish_reason length, got %q", finishReason)
+	}
+}
+
+func TestChatStreamValidatesResponseFormatAtStreamEnd(t *testing.T) {
+	cases := []struct {
+		text     string
+		wantCode string
+	}{
+		{text: `{"city":"Paris"}`},
+		{text: "Sure: {\"city\":\"Paris\"}", wantCode: "invalid_json_output"},
+	}
+	for _, tc := range cases {
+		rec := httptest.NewRecorder()
+		runtime := newChatStreamRuntime(
+			rec,
+			http.NewResponseController(rec),
+			true,
+			"chatcmpl-test",
+			time.Now().Unix(),
+			"deepseek-v4-flash",
+			"prompt",
+			false,
+			false,
+			true,
+			nil,
+			nil,
+			promptcompat.DefaultToolChoicePolicy(),
+			false,
+			false,
+		)
+		runtime.responseFormat = promptcompat.ResponseFormat{Type: promptcompat.ResponseFormatJSONObject}
+		req := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", nil)
+		resp := makeOpenAISSEHTTPResponse(`data: {"p":"response/content","v":`+strconv.Quote(tc.text)+`}`, `data: [DONE]`)
+		h := &Handler{}
+		// The empty-output retry must not swallow a JSON-mode failure: the
+		// text has already been streamed.
+		if terminal, _ := h.consumeChatStreamAttempt(req, resp, runtime, "text", false, nil, true); !terminal {
+			t.Fatalf("expected terminal stream write for %q, body=%s", tc.text, rec.Body.String())
+		}
+		if runtime.finalErrorCode != tc.wantCode {
+			t.Fatalf("expected error code %q for %q, got %q body=%s", tc.wantCode, tc.text, runtime.finalErrorCode, rec.Body.String())
+		}
+		if tc.wantCode != "" && !strings.Contains(rec.Body.String(), `"code":"`+tc.wantCode+`"`) {
+			t.Fatalf("expected error chunk in stream body, got %s", rec.Body.String())
+		}
+	}
+}
@@ -0,0 +1,161 @@
+package chat
+
+import (
+	"net/http"
+	"sync"
+
+	"ds2api/internal/assistantturn"
+	"ds2api/internal/auth"
+	"ds2api/internal/completionruntime"
+	openaifmt "ds2api/internal/format/openai"
+	"ds2api/internal/metrics"
+	"ds2api/internal/promptcompat"
+	"ds2api/internal/sse"
+)
+
+// chatChoiceFanout serializes writes from the sibling runtimes of an `n > 1`
+// stream so their chunks interleave whole, each tagged with its choice index.
+type chatChoiceFanout struct {
+	mu sync.Mutex
+}
+
+// lock returns the matching unlock; a nil fanout is a no-op so single-choice
+// streams pay nothing.
+func (f *chatChoiceFanout) lock() func() {
+	if f == nil {
+		return func() {}
+	}
+	f.mu.Lock()
+	return f.mu.Unlock
+}
+
+func buildChatChoicesResponse(stdReq promptcompat.StandardRequest, results []completionruntime.NonStreamResult) map[string]any {
+	respBody := buildChatChoicesBody(stdReq, results)
+	if stdReq.LegacyFunctions {
+		openaifmt.ConvertToLegacyFunctionCalls(respBody)
+	}
+	return respBody
+}
+
+func buildChatChoicesBody(stdReq promptcompat.StandardRequest, results []completionruntime.NonStreamResult) map[string]any {
+	first := results[0]
+	respBody := openaifmt.BuildChatCompletionWithToolCalls(first.SessionID, stdReq.ResponseModel, first.Turn.Prompt, first.Turn.Thinking, first.Turn.Text, first.Turn.ToolCalls, stdReq.ToolsRaw)
+	if len(results) == 1 {
+		if choices, _ := respBody["choices"].([]map[string]any); len(choices) == 1 {
+			applyTurnFinishReason(choices[0], first.Turn)
+		}
+		respBody["usage"] = assistantturn.OpenAIChatUsage(first.Turn)
+		return respBody
+	}
+	choices := make([]map[string]any, 0, len(results))
+	usages := make([]assistantturn.Usage, 0, len(results))
+	for i, result := range results {
+		choice := openaifmt.BuildChatCompletionChoice(i, result.Turn.Thinking, result.Turn.Text, result.Turn.ToolCalls, stdReq.ToolsRaw)
+		applyTurnFinishReason(choice, result.Turn)
+		choices = append(choices, choice)
+		usages = append(usages, result.Turn.Usage)
+	}
+	respBody["choices"] = choices
+	respBody["usage"] = assistantturn.OpenAIChatUsage(assistantturn.Turn{Usage: assistantturn.CombineChoiceUsage(usages)})
+	return respBody
+}
+
+// applyTurnFinishReason replaces the renderer's stop/tool_calls guess with
+// the turn's own finish reason, which also knows about length and
+// content_filter terminations.
+func applyTurnFinishReason(choice map[string]any, turn assistantturn.Turn) {
+	choice["finish_reason"] = assistantturn.FinishReason(turn)
+}
+
+// handleMultiChoiceStream streams every started choice concurrently over one
+// SSE response. Each choice keeps its own empty-output retry loop; only the
+// first one feeds chat history. With include_usage, usage is summed into one
+// final chunk. The shared [DONE] is always written, even when the client went
+// away mid-stream.
+func (h *Handler) handleMultiChoiceStream(w http.ResponseWriter, r *http.Request, a *auth.RequestAuth, starts []completionruntime.StartResult, historySession *chatHistorySession) {
+	fanout := &chatChoiceFanout{}
+	runtimes := make([]*chatStreamRuntime, len(starts))
+	initialTypes := make([]string, len(starts))
+	histories := make([]*chatHistorySession, len(starts))
+	histories[0] = historySession
+	completionID := starts[0].SessionID
+	for i, start := range starts {
+		req := start.Request
+		streamRuntime, initialType, _ := h.prepareChatStreamRuntime(w, start.Response, completionID, req.ResponseModel, req.PromptTokenText, req.RefFileTokens, req.Thinking, req.Search, req.ToolNames, req.ToolsRaw, req.ToolChoice, histories[i])
+		streamRuntime.choiceIndex = i
+		streamRuntime.includeUsage = req.IncludeUsage
+		streamRuntime.legacyFunctions = req.LegacyFunctions
+		streamRuntime.responseFormat = req.ResponseFormat
+		streamRuntime.toolSieve.SetCallFormat(req.ToolPrompt.Format)
+		streamRuntime.fanout = fanout
+		streamRuntime.accumulator.Stop = sse.NewStopSequenceMatcher(req.StopSequences)
+		streamRuntime.accumulator.Limit = sse.NewOutputTokenLimiter(req.MaxOutputTokens, req.ResponseModel)
+		streamRuntime.accumulator.Banned = sse.NewBannedWordFilter(req.BannedWords)
+		if i > 0 {
+			streamRuntime.created = runtimes[0].created
+		}
+		runtimes[i] = streamRuntime
+		initialTypes[i] = initialType
+	}
+
+	var wg sync.WaitGroup
+	for i, start := range starts {
+		wg.Add(1)
+		go func(i int, start completionruntime.StartResult) {
+			defer wg.Done()
+			h.runChatStreamChoice(r, a, start, runtimes[i], initialTypes[i], histories[i])
+		}(i, start)
+	}
+	wg.Wait()
+
+	// Every choice has finished, so the first runtime can write the summary
+	// directly instead of going through the fanout.
+	first := runtimes[0]
+	first.fanout = nil
+	if first.includeUsage {
+		usages := make([]assistantturn.Usage, 0, len(runtimes))
+		for _, streamRuntime := range runtimes {
+			usages = append(usages, streamRuntime.finalTurnUsage)
+		}
+		first.sendChunk(first.buildChunk([]map[string]any{}, assistantturn.OpenAIChatUsage(assistantturn.Turn{Usage: assistantturn.CombineChoiceUsage(usages)})))
+	}
+	first.sendDone()
+}
+
+func (h *Handler) runChatStreamChoice(r *http.Request, a *auth.RequestAuth, start completionruntime.StartResult, streamRuntime *chatStreamRuntime, initialType string, historySession *chatHistorySession) {
+	stdReq := start.Request
+	completionruntime.ExecuteStreamWithRetry(r.Context(), h.DS, a, start.Response, start.Payload, start.Pow, completionruntime.StreamRetryOptions{
+		Surface:                  "chat.completions",
+		Stream:                   true,
+		RetryEnabled:             emptyOutputRetryEnabled(),
+		RetryMaxAttempts:         emptyOutputRetryMaxAttempts(),
+		MaxAttempts:              3,
+		UsagePrompt:              stdReq.PromptTokenText,
+		Request:                  stdReq,
+		CurrentInputFile:         h.Store,
+		UpstreamRetryMaxAttempts: h.upstreamRetryMaxAttempts(),
+		TraceID:                  requestTraceID(r),
+		DisableAccountSwitch:     true,
+	}, completionruntime.StreamRetryHooks{
+		ConsumeAttempt: func(currentResp *http.Response, allowDeferEmpty bool) (bool, bool) {
+			return h.consumeChatStreamAttempt(r, currentResp, streamRuntime, initialType, stdReq.Thinking, historySession, allowDeferEmpty)
+		},
+		Finalize: func(int) {
+			streamRuntime.finalize("stop", false)
+			recordChatStreamHistory(streamRuntime, historySession)
+		},
+		ParentMessageID: func() int {
+			return streamRuntime.responseMessageID
+		},
+		OnRetryPrompt: func(prompt string) {
+			streamRuntime.finalPrompt = prompt
+		},
+		OnRetryFailure: func(status int, message, code string) {
+			failChatStreamRetry(streamRuntime, historySession, status, message, code)
+		},
+		OnTerminal: func(attempts int) {
+			logChatStreamTerminal(streamRuntime, attempts)
+		},
+	})
+	metrics.AddGeneratedTokens(r.Context(), streamRuntime.finalTurnUsage.OutputTokens)
+}
@@ -0,0 +1,223 @@
+package chat
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"ds2api/internal/assistantturn"
+	"ds2api/internal/auth"
+	"ds2api/internal/completionruntime"
+	dsclient "ds2api/internal/deepseek/client"
+	"ds2api/internal/promptcompat"
+	"ds2api/internal/toolcall"
+)
+
+// multiChoiceDSStub hands every completion call its own SSE body so parallel
+// `n > 1` choices never share a reader.
+type multiChoiceDSStub struct {
+	mu       sync.Mutex
+	sessions int
+	calls    int
+	deleted  []string
+}
+
+func (m *multiChoiceDSStub) CreateSession(_ context.Context, _ *auth.RequestAuth, _ int) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.sessions++
+	return fmt.Sprintf("session-%d", m.sessions), nil
+}
+
+func (m *multiChoiceDSStub) GetPow(_ context.Context, _ *auth.RequestAuth, _ int) (string, error) {
+	return "pow", nil
+}
+
+func (m *multiChoiceDSStub) UploadFile(_ context.Context, _ *auth.RequestAuth, _ dsclient.UploadFileRequest, _ int) (*dsclient.UploadFileResult, error) {
+	return &dsclient.UploadFileResult{ID: "file-id"}, nil
+}
+
+func (m *multiChoiceDSStub) CallCompletion(_ context.Context, _ *auth.RequestAuth, _ map[string]any, _ string, _ int) (*http.Response, error) {
+	m.mu.Lock()
+	m.calls++
+	call := m.calls
+	m.mu.Unlock()
+	return makeOpenAISSEHTTPResponse(
+		fmt.Sprintf(`data: {"p":"response/content","v":"answer %d"}`, call),
+		`data: [DONE]`,
+	), nil
+}
+
+func (m *multiChoiceDSStub) DeleteSessionForToken(_ context.Context, _ string, sessionID string) (*dsclient.DeleteSessionResult, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.deleted = append(m.deleted, sessionID)
+	return &dsclient.DeleteSessionResult{SessionID: sessionID, Success: true}, nil
+}
+
+func (m *multiChoiceDSStub) DeleteAllSessionsForToken(_ context.Context, _ string) error {
+	return nil
+}
+
+func postChatCompletion(h *Handler, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", strings.NewReader(body))
+	req.Header.Set("Authorization", "Bearer direct-token")
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+	h.ChatCompletions(rec, req)
+	return rec
+}
+
+func TestChatCompletionsNonStreamReturnsOneChoicePerN(t *testing.T) {
+	ds := &multiChoiceDSStub{}
+	h := &Handler{Store: mockOpenAIConfig{autoDeleteMode: "single"}, Auth: streamStatusAuthStub{}, DS: ds}
+
+	rec := postChatCompletion(h, `{"model":"deepseek-v4-flash","messages":[{"role":"user","content":"hi"}],"n":3}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status=%d body=%s", rec.Code, rec.Body.String())
+	}
+	var out struct {
+		Choices []struct {
+			Index   int `json:"index"`
+			Message struct {
+				Content string `json:"content"`
+			} `json:"message"`
+		} `json:"choices"`
+		Usage map[string]any `json:"usage"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &out); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if len(out.Choices) != 3 {
+		t.Fatalf("expected three choices, got %#v", out.Choices)
+	}
+	for i, choice := range out.Choices {
+		if choice.Index != i || !strings.HasPrefix(choice.Message.Content, "answer ") {
+			t.Fatalf("unexpected choice %d: %#v", i, choice)
+		}
+	}
+	prompt, _ := out.Usage["prompt_tokens"].(float64)
+	completion, _ := out.Usage["completion_tokens"].(float64)
+	total, _ := out.Usage["total_tokens"].(float64)
+	if prompt <= 0 || completion <= 0 || total != prompt+completion {
+		t.Fatalf("unexpected combined usage: %#v", out.Usage)
+	}
+	if len(ds.deleted) != 3 {
+		t.Fatalf("expected every choice session to be auto-deleted, got %#v", ds.deleted)
+	}
+}
+
+func TestChatCompletionsStreamInterleavesChoicesWithIndex(t *testing.T) {
+	for _, includeUsage := range []bool{false, true} {
+		t.Run(fmt.Sprintf("include_usage=%v", includeUsage), func(t *testing.T) {
+			testChatCompletionsStreamInterleavesChoices(t, includeUsage)
+		})
+	}
+}
+
+func testChatCompletionsStreamInterleavesChoices(t *testing.T, includeUsage bool) {
+	ds := &multiChoiceDSStub{}
+	h := &Handler{Store: mockOpenAIConfig{}, Auth: streamStatusAuthStub{}, DS: ds}
+
+	rec := postChatCompletion(h, fmt.Sprintf(`{"model":"deepseek-v4-flash","messages":[{"role":"user","content":"hi"}],"n":2,"stream":true,"stream_options":{"include_usage":%v}}`, includeUsage))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status=%d body=%s", rec.Code, rec.Body.String())
+	}
+	frames, done := parseSSEDataFrames(t, rec.Body.String())
+	if !done || strings.Count(rec.Body.String(), "data: [DONE]") != 1 {
+		t.Fatalf("expected exactly one [DONE], body=%s", rec.Body.String())
+	}
+	content := map[int]string{}
+	finished := map[int]bool{}
+	usageChunks := 0
+	for _, frame := range frames {
+		choices, _ := frame["choices"].([]any)
+		if usage, ok := frame["usage"].(map[string]any); ok && usage != nil {
+			usageChunks++
+			if len(choices) != 0 {
+				t.Fatalf("usage chunk should carry no choices: %#v", frame)
+			}
+		}
+		for _, raw := range choices {
+			choice, _ := raw.(map[string]any)
+			index := int(choice["index"].(float64))
+			if delta, ok := choice["delta"].(map[string]any); ok {
+				text, _ := delta["content"].(string)
+				content[index] += text
+			}
+			if reason, _ := choice["finish_reason"].(string); reason != "" {
+				finished[index] = true
+			}
+		}
+	}
+	if len(content) != 2 || !strings.HasPrefix(content[0], "answer ") || !strings.HasPrefix(content[1], "answer ") {
+		t.Fatalf("expected content for choices 0 and 1, got %#v", content)
+	}
+	if !finished[0] || !finished[1] {
+		t.Fatalf("expected a finish chunk per choice, got %#v", finished)
+	}
+	if want := map[bool]int{false: 0, true: 1}[includeUsage]; usageChunks != want {
+		t.Fatalf("expected %d combined usage chunks, got %d", want, usageChunks)
+	}
+}
+
+func TestChatCompletionsRejectsNAboveConfiguredMax(t *testing.T) {
+	ds := &multiChoiceDSStub{}
+	h := &Handler{Store: mockOpenAIConfig{}, Auth: streamStatusAuthStub{}, DS: ds}
+
+	rec := postChatCompletion(h, `{"model":"deepseek-v4-flash","messages":[{"role":"user","content":"hi"}],"n":5}`)
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("status=%d body=%s", rec.Code, rec.Body.String())
+	}
+	if !strings.Contains(rec.Body.String(), "n must be at most 4") {
+		t.Fatalf("unexpected error body: %s", rec.Body.String())
+	}
+	if ds.calls != 0 {
+		t.Fatalf("expected no upstream calls, got %d", ds.calls)
+	}
+}
+
+func TestBuildChatChoicesResponseReportsTurnFinishReason(t *testing.T) {
+	stdReq := promptcompat.StandardRequest{ResponseModel: "deepseek-v4-flash"}
+	results := []completionruntime.NonStreamResult{
+		{Turn: assistantturn.Turn{Text: "cut", StopReason: assistantturn.StopReasonLength}},
+		{Turn: assistantturn.Turn{Text: "partial", ContentFilter: true, StopReason: assistantturn.StopReasonContentFilter}},
+		{Turn: assistantturn.Turn{StopReason: assistantturn.StopReasonLength, ToolCalls: []toolcall.ParsedToolCall{{Name: "search", Input: map[string]any{"q": "x"}}}}},
+		{Turn: assistantturn.Turn{Text: "done", StopReason: assistantturn.StopReasonStop}},
+	}
+	want := []string{"length", "content_filter", "tool_calls", "stop"}
+	choices, _ := buildChatChoicesResponse(stdReq, results)["choices"].([]map[string]any)
+	if len(choices) != len(want) {
+		t.Fatalf("expected %d choices, got %#v", len(want), choices)
+	}
+	for i, choice := range choices {
+		if choice["finish_reason"] != want[i] {
+			t.Fatalf("choice %d: finish_reason=%#v want %q", i, choice["finish_reason"], want[i])
+		}
+	}
+
+	single, _ := buildChatChoicesResponse(stdReq, results[2:3])["choices"].([]map[string]any)
+	if len(single) != 1 || single[0]["finish_reason"] != "tool_calls" {
+		t.Fatalf("expected a truncated tool-call response to report tool_calls, got %#v", single)
+	}
+}
+
+func TestChatCompletionsStreamWritesDoneAfterClientCancel(t *testing.T) {
+	ds := &multiChoiceDSStub{}
+	h := &Handler{Store: mockOpenAIConfig{}, Auth: streamStatusAuthStub{}, DS: ds}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	req := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", strings.NewReader(`{"model":"deepseek-v4-flash","messages":[{"role":"user","content":"hi"}],"n":2,"stream":true}`)).WithContext(ctx)
+	req.Header.Set("Authorization", "Bearer direct-token")
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+	h.ChatCompletions(rec, req)
+	if rec.Code != http.StatusOK || !strings.HasSuffix(rec.Body.String(), "data: [DONE]\n\n") {
+		t.Fatalf("expected the stream to end with [DONE], body=%s", rec.Body.String())
+	}
+}
@@ -12,6 +12,7 @@ import (
 	"ds2api/internal/config"
 	dsprotocol "ds2api/internal/deepseek/protocol"
 	openaifmt "ds2api/internal/format/openai"
+	"ds2api/internal/metrics"
 	"ds2api/internal/promptcompat"
 	"ds2api/internal/sse"
 	streamengine "ds2api/internal/stream"
@@ -71,15 +72,24 @@ func (h *Handler) handleStreamWithRetry(w http.ResponseWriter, r *http.Request,
 	if !ok {
 		return
 	}
+	streamRuntime.includeUsage = stdReq.IncludeUsage
+	streamRuntime.legacyFunctions = stdReq.LegacyFunctions
+	streamRuntime.responseFormat = stdReq.ResponseFormat
+	streamRuntime.toolSieve.SetCallFormat(stdReq.ToolPrompt.Format)
+	streamRuntime.accumulator.Stop = sse.NewStopSequenceMatcher(stdReq.StopSequences)
+	streamRuntime.accumulator.Limit = sse.NewOutputTokenLimiter(stdReq.MaxOutputTokens, stdReq.ResponseModel)
+	streamRuntime.accumulator.Banned = sse.NewBannedWordFilter(stdReq.BannedWords)
 	completionruntime.ExecuteStreamWithRetry(r.Context(), h.DS, a, resp, payload, pow, completionruntime.StreamRetryOptions{
-		Surface:          "chat.completions",
-		Stream:           true,
-		RetryEnabled:     emptyOutputRetryEnabled(),
-		RetryMaxAttempts: emptyOutputRetryMaxAttempts(),
-		MaxAttempts:      3,
-		UsagePrompt:      finalPrompt,
-		Request:          stdReq,
-		CurrentInputFile: h.Store,
+		Surface:                  "chat.completions",
+		Stream:                   true,
+		RetryEnabled:             emptyOutputRetryEnabled(),
+		RetryMaxAttempts:         emptyOutputRetryMaxAttempts(),
+		MaxAttempts:              3,
+		UsagePrompt:              finalPrompt,
+		Request:                  stdReq,
+		CurrentInputFile:         h.Store,
+		UpstreamRetryMaxAttempts: h.upstreamRetryMaxAttempts(),
+		TraceID:                  requestTraceID(r),
 	}, completionruntime.StreamRetryHooks{
 		ConsumeAttempt: func(currentResp *http.Response, allowDeferEmpty bool) (bool, bool) {
 			return h.consumeChatStreamAttempt(r, currentResp, streamRuntime, initialType, thinkingEnabled, historySession, allowDeferEmpty)
@@ -87,7 +97,7 @@ func (h *Handler) handleStreamWithRetry(w http.ResponseWriter, r *http.Request,
 		Finalize: func(attempts int) {
 			streamRuntime.finalize("stop", false)
 			recordChatStreamHistory(streamRuntime, historySession)
-			config.Logger.Info("[openai_empty_retry] terminal empty output", "surface", "chat.completions", "stream", true, "retry_attempts", attempts, "success_source", "none")
+			config.Logger.InfoContext(r.Context(), "[openai_empty_retry] terminal empty output", "surface", "chat.completions", "stream", true, "retry_attempts", attempts, "success_source", "none")
 		},
 		ParentMessageID: func() int {
 			return streamRuntime.responseMessageID
@@ -107,6 +117,7 @@ func (h *Handler) handleStreamWithRetry(w http.ResponseWriter, r *http.Request,
 			logChatStreamTerminal(streamRuntime, attempts)
 		},
 	})
+	metrics.AddGeneratedTokens(r.Context(), streamRuntime.finalTurnUsage.OutputTokens)
 }
 
 func (h *Handler) prepareChatStreamRuntime(w http.ResponseWriter, resp *http.Response, completionID, model, finalPrompt string, refFileTokens int, thinkingEnabled, searchEnabled bool, toolNames []string, toolsRaw any, toolChoice promptcompat.ToolChoicePolicy, historySession *chatHistorySession) (*chatStreamRuntime, string, bool) {
@@ -127,6 +138,12 @@ func (h *Handler) prepareChatStreamRuntime(w http.ResponseWriter, resp *http.Res
 	_, canFlush := w.(http.Flusher)
 	if !canFlush {
 		config.Logger.Warn("[stream] response writer does not support flush; streaming may be buffered")
+	} else {
+		// Commit the headers now so the client sees the stream open before
+		// the first token, which can take a while with thinking enabled.
+		// WriteHeader first so wrapping middlewares record the 200.
+		w.WriteHeader(http.StatusOK)
+		_ = rc.Flush()
 	}
 	initialType := "text"
 	if thinkingEnabled {
@@ -168,7 +185,7 @@ func (h *Handler) consumeChatStreamAttempt(r *http.Request, resp *http.Response,
 			}
 		},
 		OnContextDone: func() {
-			streamRuntime.markContextCancelled()
+			streamRuntime.markContextCancelled(r.Context())
 			if historySession != nil {
 				historySession.stopped(streamRuntime.historyThinking(), streamRuntime.historyText(), string(streamengine.StopReasonContextCancelled))
 			}
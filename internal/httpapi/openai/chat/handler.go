@@ -42,6 +42,13 @@ func stripReferenceMarkersEnabled() bool {
 	return textclean.StripReferenceMarkersEnabled()
 }
 
+func (h *Handler) applyContextWindow(w http.ResponseWriter, stdReq promptcompat.StandardRequest, traceID string) (promptcompat.StandardRequest, error) {
+	if h == nil {
+		return stdReq, nil
+	}
+	return shared.ApplyContextWindow(w, h.Store, stdReq, traceID)
+}
+
 func (h *Handler) applyCurrentInputFile(ctx context.Context, a *auth.RequestAuth, stdReq promptcompat.StandardRequest) (promptcompat.StandardRequest, error) {
 	if h == nil {
 		return stdReq, nil
@@ -84,6 +91,24 @@ func writeOpenAIErrorWithCode(w http.ResponseWriter, status int, message, code s
 	shared.WriteOpenAIErrorWithCode(w, status, message, code)
 }
 
+func writeOpenAIRequestError(w http.ResponseWriter, err error) {
+	shared.WriteOpenAIRequestError(w, err)
+}
+
+func writeOpenAIAuthError(w http.ResponseWriter, err error) {
+	shared.WriteOpenAIAuthError(w, err)
+}
+
+func writeOpenAIDecodeError(w http.ResponseWriter, err error) {
+	shared.WriteOpenAIDecodeError(w, err)
+}
+
+// checkModelAllowed enforces the caller key's model allowlist before any
+// prompt work happens.
+func (h *Handler) checkModelAllowed(a *auth.RequestAuth, req map[string]any) error {
+	return shared.CheckRequestModelAllowed(h.Store, a, req)
+}
+
 func openAIErrorType(status int) string {
 	return shared.OpenAIErrorType(status)
 }
@@ -116,6 +141,20 @@ func emptyOutputRetryMaxAttempts() int {
 	return shared.EmptyOutputRetryMaxAttempts()
 }
 
+func (h *Handler) upstreamRetryMaxAttempts() int {
+	if h == nil {
+		return 0
+	}
+	return shared.UpstreamRetryMaxAttempts(h.Store)
+}
+
+func (h *Handler) maxCompletionChoices() int {
+	if h == nil {
+		return 1
+	}
+	return shared.MaxCompletionChoices(h.Store)
+}
+
 func formatIncrementalStreamToolCallDeltas(deltas []toolstream.ToolCallDelta, ids map[int]string) []map[string]any {
 	return shared.FormatIncrementalStreamToolCallDeltas(deltas, ids)
 }
@@ -124,6 +163,6 @@ func filterIncrementalToolCallDeltasByAllowed(deltas []toolstream.ToolCallDelta,
 	return shared.FilterIncrementalToolCallDeltasByAllowed(deltas, seenNames)
 }
 
-func formatFinalStreamToolCallsWithStableIDs(calls []toolcall.ParsedToolCall, ids map[int]string, toolsRaw any) []map[string]any {
-	return shared.FormatFinalStreamToolCallsWithStableIDs(calls, ids, toolsRaw)
+func formatFinalStreamToolCallsWithStableIDs(calls []toolcall.ParsedToolCall, ids map[int]string, announced map[int]string, sentArgs map[int]string, toolsRaw any) []map[string]any {
+	return shared.FormatFinalStreamToolCallsAfterDeltas(calls, ids, announced, sentArgs, toolsRaw)
 }
@@ -3,9 +3,9 @@ package chat
 import (
 	"context"
 	"encoding/json"
+	"fmt"
 	"io"
 	"net/http"
-	"strings"
 	"time"
 
 	"ds2api/internal/assistantturn"
@@ -39,17 +39,14 @@ func (h *Handler) ChatCompletions(w http.ResponseWriter, r *http.Request) {
 
 	a, err := h.Auth.Determine(r)
 	if err != nil {
-		status := http.StatusUnauthorized
-		detail := err.Error()
-		if err == auth.ErrNoAccount {
-			status = http.StatusTooManyRequests
-		}
-		writeOpenAIError(w, status, detail)
+		writeOpenAIAuthError(w, err)
 		return
 	}
 	var sessionID string
+	// extraSessionIDs holds the sessions of choices 1..n-1 for `n > 1`.
+	var extraSessionIDs []string
 	defer func() {
-		h.autoDeleteRemoteSession(r.Context(), a, sessionID)
+		h.autoDeleteRemoteSession(r.Context(), a, sessionID, extraSessionIDs...)
 		h.Auth.Release(a)
 	}()
 
@@ -58,11 +55,11 @@ func (h *Handler) ChatCompletions(w http.ResponseWriter, r *http.Request) {
 	r.Body = http.MaxBytesReader(w, r.Body, openAIGeneralMaxSize)
 	var req map[string]any
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		if strings.Contains(strings.ToLower(err.Error()), "too large") {
-			writeOpenAIError(w, http.StatusRequestEntityTooLarge, "request body too large")
-			return
-		}
-		writeOpenAIError(w, http.StatusBadRequest, "invalid json")
+		writeOpenAIDecodeError(w, err)
+		return
+	}
+	if err := h.checkModelAllowed(a, req); err != nil {
+		writeOpenAIRequestError(w, err)
 		return
 	}
 	if err := h.preprocessInlineFileInputs(r.Context(), a, req); err != nil {
@@ -71,7 +68,16 @@ func (h *Handler) ChatCompletions(w http.ResponseWriter, r *http.Request) {
 	}
 	stdReq, err := promptcompat.NormalizeOpenAIChatRequest(h.Store, req, requestTraceID(r))
 	if err != nil {
-		writeOpenAIError(w, http.StatusBadRequest, err.Error())
+		writeOpenAIRequestError(w, err)
+		return
+	}
+	if maxChoices := h.maxCompletionChoices(); stdReq.Choices > maxChoices {
+		writeOpenAIError(w, http.StatusBadRequest, fmt.Sprintf("n must be at most %d", maxChoices))
+		return
+	}
+	stdReq, err = h.applyContextWindow(w, stdReq, requestTraceID(r))
+	if err != nil {
+		writeOpenAIRequestError(w, err)
 		return
 	}
 	stdReq, err = h.applyCurrentInputFile(r.Context(), a, stdReq)
@@ -83,11 +89,17 @@ func (h *Handler) ChatCompletions(w http.ResponseWriter, r *http.Request) {
 	historySession := startChatHistory(h.ChatHistory, r, a, stdReq)
 
 	if !stdReq.Stream {
-		result, outErr := completionruntime.ExecuteNonStreamWithRetry(r.Context(), h.DS, a, stdReq, completionruntime.Options{
-			RetryEnabled:     true,
-			CurrentInputFile: h.Store,
+		results, outErr := completionruntime.ExecuteNonStreamChoices(r.Context(), h.DS, a, stdReq, completionruntime.Options{
+			RetryEnabled:             true,
+			UpstreamRetryMaxAttempts: h.upstreamRetryMaxAttempts(),
+			TraceID:                  requestTraceID(r),
+			CurrentInputFile:         h.Store,
 		})
+		result := results[0]
 		sessionID = result.SessionID
+		for _, extra := range results[1:] {
+			extraSessionIDs = append(extraSessionIDs, extra.SessionID)
+		}
 		if outErr != nil {
 			if historySession != nil {
 				historySession.error(outErr.Status, outErr.Message, outErr.Code, historyThinkingForArchive(result.Turn.RawThinking, result.Turn.DetectionThinking, result.Turn.Thinking), historyTextForArchive(result.Turn.RawText, result.Turn.Text))
@@ -95,18 +107,40 @@ func (h *Handler) ChatCompletions(w http.ResponseWriter, r *http.Request) {
 			writeOpenAIErrorWithCode(w, outErr.Status, outErr.Message, outErr.Code)
 			return
 		}
-		respBody := openaifmt.BuildChatCompletionWithToolCalls(result.SessionID, stdReq.ResponseModel, result.Turn.Prompt, result.Turn.Thinking, result.Turn.Text, result.Turn.ToolCalls, stdReq.ToolsRaw)
-		respBody["usage"] = assistantturn.OpenAIChatUsage(result.Turn)
+		respBody := buildChatChoicesResponse(stdReq, results)
 		finishReason := assistantturn.FinalizeTurn(result.Turn, assistantturn.FinalizeOptions{}).FinishReason
 		if historySession != nil {
-			historySession.success(http.StatusOK, historyThinkingForArchive(result.Turn.RawThinking, result.Turn.DetectionThinking, result.Turn.Thinking), historyTextForArchive(result.Turn.RawText, result.Turn.Text), finishReason, assistantturn.OpenAIChatUsage(result.Turn))
+			historySession.success(http.StatusOK, historyThinkingForArchive(result.Turn.RawThinking, result.Turn.DetectionThinking, result.Turn.Thinking), historyTextForArchive(result.Turn.RawText, result.Turn.Text), finishReason, respBody["usage"].(map[string]any))
 		}
 		writeJSON(w, http.StatusOK, respBody)
 		return
 	}
 
+	if stdReq.Choices > 1 {
+		starts, outErr := completionruntime.StartCompletionChoices(r.Context(), h.DS, a, stdReq, completionruntime.Options{
+			UpstreamRetryMaxAttempts: h.upstreamRetryMaxAttempts(),
+			TraceID:                  requestTraceID(r),
+			CurrentInputFile:         h.Store,
+		})
+		sessionID = starts[0].SessionID
+		for _, extra := range starts[1:] {
+			extraSessionIDs = append(extraSessionIDs, extra.SessionID)
+		}
+		if outErr != nil {
+			if historySession != nil {
+				historySession.error(outErr.Status, outErr.Message, outErr.Code, "", "")
+			}
+			writeOpenAIErrorWithCode(w, outErr.Status, outErr.Message, outErr.Code)
+			return
+		}
+		h.handleMultiChoiceStream(w, r, a, starts, historySession)
+		return
+	}
+
 	start, outErr := completionruntime.StartCompletion(r.Context(), h.DS, a, stdReq, completionruntime.Options{
-		CurrentInputFile: h.Store,
+		UpstreamRetryMaxAttempts: h.upstreamRetryMaxAttempts(),
+		TraceID:                  requestTraceID(r),
+		CurrentInputFile:         h.Store,
 	})
 	sessionID = start.SessionID
 	if outErr != nil {
@@ -121,7 +155,7 @@ func (h *Handler) ChatCompletions(w http.ResponseWriter, r *http.Request) {
 	h.handleStreamWithRetry(w, r, a, start.Response, start.Payload, start.Pow, sessionID, &sessionID, streamReq, streamReq.ResponseModel, streamReq.PromptTokenText, refFileTokens, streamReq.Thinking, streamReq.Search, streamReq.ToolNames, streamReq.ToolsRaw, streamReq.ToolChoice, historySession)
 }
 
-func (h *Handler) autoDeleteRemoteSession(ctx context.Context, a *auth.RequestAuth, sessionID string) {
+func (h *Handler) autoDeleteRemoteSession(ctx context.Context, a *auth.RequestAuth, sessionID string, extraSessionIDs ...string) {
 	mode := h.Store.AutoDeleteMode()
 	if mode == "none" || a.DeepSeekToken == "" {
 		return
@@ -134,23 +168,27 @@ func (h *Handler) autoDeleteRemoteSession(ctx context.Context, a *auth.RequestAu
 	switch mode {
 	case "single":
 		if sessionID == "" {
-			config.Logger.Warn("[auto_delete_sessions] skipped single-session delete because session_id is empty", "account", a.AccountID)
+			config.Logger.WarnContext(ctx, "[auto_delete_sessions] skipped single-session delete because session_id is empty", "account", a.AccountID)
 			return
 		}
-		_, err := h.DS.DeleteSessionForToken(deleteCtx, a.DeepSeekToken, sessionID)
-		if err != nil {
-			config.Logger.Warn("[auto_delete_sessions] failed", "account", a.AccountID, "mode", mode, "session_id", sessionID, "error", err)
-			return
+		for _, id := range append([]string{sessionID}, extraSessionIDs...) {
+			if id == "" {
+				continue
+			}
+			if _, err := h.DS.DeleteSessionForToken(deleteCtx, a.DeepSeekToken, id); err != nil {
+				config.Logger.WarnContext(ctx, "[auto_delete_sessions] failed", "account", a.AccountID, "mode", mode, "session_id", id, "error", err)
+				continue
+			}
+			config.Logger.DebugContext(ctx, "[auto_delete_sessions] success", "account", a.AccountID, "mode", mode, "session_id", id)
 		}
-		config.Logger.Debug("[auto_delete_sessions] success", "account", a.AccountID, "mode", mode, "session_id", sessionID)
 	case "all":
 		if err := h.DS.DeleteAllSessionsForToken(deleteCtx, a.DeepSeekToken); err != nil {
-			config.Logger.Warn("[auto_delete_sessions] failed", "account", a.AccountID, "mode", mode, "error", err)
+			config.Logger.WarnContext(ctx, "[auto_delete_sessions] failed", "account", a.AccountID, "mode", mode, "error", err)
 			return
 		}
-		config.Logger.Debug("[auto_delete_sessions] success", "account", a.AccountID, "mode", mode)
+		config.Logger.DebugContext(ctx, "[auto_delete_sessions] success", "account", a.AccountID, "mode", mode)
 	default:
-		config.Logger.Warn("[auto_delete_sessions] unknown mode", "account", a.AccountID, "mode", mode)
+		config.Logger.WarnContext(ctx, "[auto_delete_sessions] unknown mode", "account", a.AccountID, "mode", mode)
 	}
 }
 
@@ -209,7 +247,7 @@ func (h *Handler) handleStream(w http.ResponseWriter, r *http.Request, resp *htt
 	rc := http.NewResponseController(w)
 	_, canFlush := w.(http.Flusher)
 	if !canFlush {
-		config.Logger.Warn("[stream] response writer does not support flush; streaming may be buffered")
+		config.Logger.WarnContext(r.Context(), "[stream] response writer does not support flush; streaming may be buffered")
 	}
 
 	created := time.Now().Unix()
@@ -275,7 +313,7 @@ func (h *Handler) handleStream(w http.ResponseWriter, r *http.Request, resp *htt
 			historySession.success(http.StatusOK, streamRuntime.historyThinking(), streamRuntime.historyText(), streamRuntime.finalFinishReason, streamRuntime.finalUsage)
 		},
 		OnContextDone: func() {
-			streamRuntime.markContextCancelled()
+			streamRuntime.markContextCancelled(r.Context())
 			if historySession != nil {
 				historySession.stopped(streamRuntime.historyThinking(), streamRuntime.historyText(), string(streamengine.StopReasonContextCancelled))
 			}
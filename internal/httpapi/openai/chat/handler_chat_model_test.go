@@ -0,0 +1,153 @@
+package chat
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"ds2api/internal/httpapi/openai/shared"
+)
+
+func TestChatCompletionsUnknownModelReturnsModelNotFound(t *testing.T) {
+	ds := &multiChoiceDSStub{}
+	h := &Handler{Store: mockOpenAIConfig{}, Auth: streamStatusAuthStub{}, DS: ds}
+
+	rec := postChatCompletion(h, `{"model":"my-proprietary-model","messages":[{"role":"user","content":"hi"}]}`)
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("status=%d body=%s", rec.Code, rec.Body.String())
+	}
+	var out struct {
+		Error struct {
+			Type string `json:"type"`
+			Code string `json:"code"`
+		} `json:"error"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &out); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if out.Error.Type != "invalid_request_error" || out.Error.Code != "model_not_found" {
+		t.Fatalf("unexpected error body: %s", rec.Body.String())
+	}
+	if ds.calls != 0 {
+		t.Fatalf("expected no upstream calls, got %d", ds.calls)
+	}
+}
+
+func TestChatCompletionsEchoesResolvedModelWhenConfigured(t *testing.T) {
+	h := &Handler{Store: mockOpenAIConfig{defaultModel: "deepseek-v4-pro", responseModelMode: "resolved"}, Auth: streamStatusAuthStub{}, DS: &multiChoiceDSStub{}}
+
+	rec := postChatCompletion(h, `{"model":"my-proprietary-model","messages":[{"role":"user","content":"hi"}]}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status=%d body=%s", rec.Code, rec.Body.String())
+	}
+	var out struct {
+		Model string `json:"model"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &out); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if out.Model != "deepseek-v4-pro" {
+		t.Fatalf("expected resolved model echoed, got %q", out.Model)
+	}
+}
+
+func TestChatCompletionsRejectsEmptyMessagesBeforeUpstream(t *testing.T) {
+	for _, body := range []string{
+		`{"model":"deepseek-v4-flash","messages":[]}`,
+		`{"model":"deepseek-v4-flash","messages":[{"role":"system","content":"  "},{"role":"user","content":""}]}`,
+	} {
+		ds := &multiChoiceDSStub{}
+		h := &Handler{Store: mockOpenAIConfig{}, Auth: streamStatusAuthStub{}, DS: ds}
+		rec := postChatCompletion(h, body)
+		if rec.Code != http.StatusBadRequest {
+			t.Fatalf("status=%d body=%s", rec.Code, rec.Body.String())
+		}
+		var out struct {
+			Error struct {
+				Type    string `json:"type"`
+				Message string `json:"message"`
+			} `json:"error"`
+		}
+		if err := json.Unmarshal(rec.Body.Bytes(), &out); err != nil {
+			t.Fatalf("decode response: %v", err)
+		}
+		if out.Error.Type != "invalid_request_error" || out.Error.Message != "messages must contain at least one non-empty message" {
+			t.Fatalf("unexpected error body: %s", rec.Body.String())
+		}
+		if ds.calls != 0 {
+			t.Fatalf("expected no upstream calls, got %d", ds.calls)
+		}
+	}
+}
+
+func TestChatCompletionsRejectsUnknownToolCallIDBeforeUpstream(t *testing.T) {
+	body := `{"model":"deepseek-v4-flash","messages":[` +
+		`{"role":"user","content":"weather?"},` +
+		`{"role":"assistant","content":null,"tool_calls":[{"id":"call_1","type":"function","function":{"name":"get_weather","arguments":"{}"}}]},` +
+		`{"role":"tool","tool_call_id":"call_9","content":{"temp":18}}]}`
+	ds := &multiChoiceDSStub{}
+	h := &Handler{Store: mockOpenAIConfig{}, Auth: streamStatusAuthStub{}, DS: ds}
+	rec := postChatCompletion(h, body)
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("status=%d body=%s", rec.Code, rec.Body.String())
+	}
+	if !strings.Contains(rec.Body.String(), `call_9`) {
+		t.Fatalf("expected error to name the unknown tool_call_id, got %s", rec.Body.String())
+	}
+	if ds.calls != 0 {
+		t.Fatalf("expected no upstream calls, got %d", ds.calls)
+	}
+}
+
+func TestChatCompletionsTrimsOldestMessagesToContextWindow(t *testing.T) {
+	filler := strings.Repeat("older context ", 400)
+	body := `{"model":"deepseek-v4-flash","messages":[` +
+		`{"role":"system","content":"Be brief."},` +
+		`{"role":"user","content":"` + filler + `"},` +
+		`{"role":"assistant","content":"` + filler + `"},` +
+		`{"role":"user","content":"latest"}]}`
+	ds := &multiChoiceDSStub{}
+	h := &Handler{Store: mockOpenAIConfig{contextMaxTokens: 300}, Auth: streamStatusAuthStub{}, DS: ds}
+	rec := postChatCompletion(h, body)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status=%d body=%s", rec.Code, rec.Body.String())
+	}
+	if got := rec.Header().Get(shared.ContextTrimmedHeader); got != "2" {
+		t.Fatalf("expected two trimmed messages in header, got %q", got)
+	}
+
+	ds = &multiChoiceDSStub{}
+	h = &Handler{Store: mockOpenAIConfig{contextMaxTokens: 300}, Auth: streamStatusAuthStub{}, DS: ds}
+	rec = postChatCompletion(h, `{"model":"deepseek-v4-flash","messages":[{"role":"user","content":"`+filler+`"}]}`)
+	if rec.Code != http.StatusBadRequest || !strings.Contains(rec.Body.String(), "context_length_exceeded") {
+		t.Fatalf("expected context_length_exceeded 400, got status=%d body=%s", rec.Code, rec.Body.String())
+	}
+	if ds.calls != 0 {
+		t.Fatalf("expected no upstream calls, got %d", ds.calls)
+	}
+}
+
+func TestChatCompletionsRejectsLogprobsBeforeUpstream(t *testing.T) {
+	ds := &multiChoiceDSStub{}
+	h := &Handler{Store: mockOpenAIConfig{}, Auth: streamStatusAuthStub{}, DS: ds}
+	rec := postChatCompletion(h, `{"model":"deepseek-v4-flash","messages":[{"role":"user","content":"hi"}],"logprobs":true,"top_logprobs":3}`)
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("status=%d body=%s", rec.Code, rec.Body.String())
+	}
+	var out struct {
+		Error struct {
+			Code  string `json:"code"`
+			Param string `json:"param"`
+		} `json:"error"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &out); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if out.Error.Code != "unsupported_parameter" || out.Error.Param != "top_logprobs" {
+		t.Fatalf("unexpected error body: %s", rec.Body.String())
+	}
+	if ds.calls != 0 {
+		t.Fatalf("expected no upstream calls, got %d", ds.calls)
+	}
+}
@@ -601,3 +601,147 @@ func TestHandleNonStreamWithRetryIncludesRefFileTokensInUsage(t *testing.T) {
 		t.Fatalf("expected total_tokens delta 7, got %d", got)
 	}
 }
+
+func TestHandleStreamAnnouncesToolNameBeforeArgumentsWithoutRepeatingIt(t *testing.T) {
+	h := &Handler{}
+	resp := makeSSEHTTPResponse(
+		`data: {"p":"response/content","v":"<tool_calls>\n  <invoke name=\"read_file\">\n"}`,
+		`data: {"p":"response/content","v":"    <parameter name=\"path\">README.MD</parameter>\n  </invoke>\n</tool_calls>"}`,
+		`data: [DONE]`,
+	)
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", nil)
+
+	h.handleStream(rec, req, resp, "cid-name-delta", "deepseek-v4-flash", "prompt", 0, false, false, []string{"read_file"}, nil, nil)
+
+	frames, done := parseSSEDataFrames(t, rec.Body.String())
+	if !done {
+		t.Fatalf("expected [DONE], body=%s", rec.Body.String())
+	}
+	var toolDeltas []map[string]any
+	for _, frame := range frames {
+		choices, _ := frame["choices"].([]any)
+		for _, item := range choices {
+			choice, _ := item.(map[string]any)
+			delta, _ := choice["delta"].(map[string]any)
+			toolCalls, _ := delta["tool_calls"].([]any)
+			for _, rawCall := range toolCalls {
+				call, _ := rawCall.(map[string]any)
+				toolDeltas = append(toolDeltas, call)
+			}
+		}
+	}
+	if len(toolDeltas) != 2 {
+		t.Fatalf("expected name delta followed by arguments delta, got %#v body=%s", toolDeltas, rec.Body.String())
+	}
+	first, _ := toolDeltas[0]["function"].(map[string]any)
+	if asString(first["name"]) != "read_file" || asString(toolDeltas[0]["id"]) == "" {
+		t.Fatalf("expected first delta to carry id and name, got %#v", toolDeltas[0])
+	}
+	if _, ok := first["arguments"]; ok {
+		t.Fatalf("expected name delta without arguments, got %#v", toolDeltas[0])
+	}
+	second, _ := toolDeltas[1]["function"].(map[string]any)
+	if _, ok := second["name"]; ok {
+		t.Fatalf("expected arguments delta not to repeat the name, got %#v", toolDeltas[1])
+	}
+	if _, ok := toolDeltas[1]["id"]; ok {
+		t.Fatalf("expected arguments delta not to repeat the id, got %#v", toolDeltas[1])
+	}
+	if !strings.Contains(asString(second["arguments"]), "README.MD") {
+		t.Fatalf("expected arguments in closing delta, got %#v", toolDeltas[1])
+	}
+}
+
+func collectStreamToolCallDeltas(t *testing.T, body string) []map[string]any {
+	t.Helper()
+	frames, done := parseSSEDataFrames(t, body)
+	if !done {
+		t.Fatalf("expected [DONE], body=%s", body)
+	}
+	var toolDeltas []map[string]any
+	for _, frame := range frames {
+		choices, _ := frame["choices"].([]any)
+		for _, item := range choices {
+			choice, _ := item.(map[string]any)
+			delta, _ := choice["delta"].(map[string]any)
+			toolCalls, _ := delta["tool_calls"].([]any)
+			for _, rawCall := range toolCalls {
+				call, _ := rawCall.(map[string]any)
+				toolDeltas = append(toolDeltas, call)
+			}
+		}
+	}
+	return toolDeltas
+}
+
+func TestHandleStreamStreamsToolArgumentsBeforeTheCallCloses(t *testing.T) {
+	h := &Handler{}
+	resp := makeSSEHTTPResponse(
+		`data: {"p":"response/content","v":"<tool_calls>\n  <invoke name=\"read_file\">\n"}`,
+		`data: {"p":"response/content","v":"    <parameter name=\"path\">README.MD</parameter>\n"}`,
+		`data: {"p":"response/content","v":"    <parameter name=\"limit\">10</parameter>\n"}`,
+		`data: {"p":"response/content","v":"  </invoke>\n</tool_calls>"}`,
+		`data: [DONE]`,
+	)
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", nil)
+
+	h.handleStream(rec, req, resp, "cid-args-delta", "deepseek-v4-flash", "prompt", 0, false, false, []string{"read_file"}, nil, nil)
+
+	toolDeltas := collectStreamToolCallDeltas(t, rec.Body.String())
+	if len(toolDeltas) != 4 {
+		t.Fatalf("expected name, two argument fragments and the closing delta, got %#v body=%s", toolDeltas, rec.Body.String())
+	}
+	var args strings.Builder
+	for i, d := range toolDeltas {
+		if idx, _ := d["index"].(float64); idx != 0 {
+			t.Fatalf("expected every delta on index 0, got %#v", d)
+		}
+		if _, ok := d["id"]; ok != (i == 0) {
+			t.Fatalf("expected only the first delta to carry the id, got %#v", d)
+		}
+		fn, _ := d["function"].(map[string]any)
+		args.WriteString(asString(fn["arguments"]))
+	}
+	var decoded map[string]any
+	if err := json.Unmarshal([]byte(args.String()), &decoded); err != nil {
+		t.Fatalf("expected concatenated arguments to be JSON, got %q: %v", args.String(), err)
+	}
+	if decoded["path"] != "README.MD" || decoded["limit"] != float64(10) {
+		t.Fatalf("unexpected arguments %#v", decoded)
+	}
+}
+
+func TestHandleStreamKeepsAnnouncedIndexWhenFinalParseDropsACall(t *testing.T) {
+	h := &Handler{}
+	resp := makeSSEHTTPResponse(
+		`data: {"p":"response/content","v":"<tool_calls>\n  <invoke name=\"broken\">\n"}`,
+		`data: {"p":"response/content","v":"not a parameter</invoke>\n  <invoke name=\"read_file\">\n"}`,
+		`data: {"p":"response/content","v":"    <parameter name=\"path\">README.MD</parameter>\n  </invoke>\n</tool_calls>"}`,
+		`data: [DONE]`,
+	)
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", nil)
+
+	h.handleStream(rec, req, resp, "cid-index-reconcile", "deepseek-v4-flash", "prompt", 0, false, false, []string{"read_file"}, nil, nil)
+
+	toolDeltas := collectStreamToolCallDeltas(t, rec.Body.String())
+	names := map[int]string{}
+	args := map[int]string{}
+	for _, d := range toolDeltas {
+		idx, _ := d["index"].(float64)
+		fn, _ := d["function"].(map[string]any)
+		names[int(idx)] += asString(fn["name"])
+		args[int(idx)] += asString(fn["arguments"])
+	}
+	if names[0] != "broken" || names[1] != "read_file" {
+		t.Fatalf("expected names announced at their stream indexes, got %#v", names)
+	}
+	if args[0] != "" {
+		t.Fatalf("expected the dropped call to receive no arguments, got %q", args[0])
+	}
+	if args[1] != `{"path":"README.MD"}` {
+		t.Fatalf("expected read_file arguments to stay on index 1, got %#v body=%s", args, rec.Body.String())
+	}
+}
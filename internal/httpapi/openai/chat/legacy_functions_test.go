@@ -0,0 +1,52 @@
+package chat
+
+import (
+	"strings"
+	"testing"
+)
+
+const legacyFunctionsToolCallLine = `data: {"p":"response/content","v":"<tool_calls><invoke name=\"search\"><parameter name=\"q\">golang</parameter></invoke></tool_calls>"}`
+
+func legacyFunctionsHandler() *Handler {
+	return &Handler{
+		Store: mockOpenAIConfig{},
+		Auth:  streamStatusAuthStub{},
+		DS:    streamStatusDSStub{resp: makeOpenAISSEHTTPResponse(legacyFunctionsToolCallLine, `data: [DONE]`)},
+	}
+}
+
+func TestChatCompletionsLegacyFunctionsReturnFunctionCall(t *testing.T) {
+	rec := postChatCompletion(legacyFunctionsHandler(), `{"model":"deepseek-v4-flash","messages":[{"role":"user","content":"find golang"}],"functions":[{"name":"search","parameters":{"type":"object","properties":{"q":{"type":"string"}}}}],"function_call":"auto"}`)
+	body := decodeJSONBody(t, rec.Body.String())
+	choice := body["choices"].([]any)[0].(map[string]any)
+	msg := choice["message"].(map[string]any)
+	if choice["finish_reason"] != "function_call" || msg["tool_calls"] != nil {
+		t.Fatalf("expected legacy finish reason without tool_calls, got %#v", choice)
+	}
+	fc, _ := msg["function_call"].(map[string]any)
+	if fc["name"] != "search" || !strings.Contains(fc["arguments"].(string), "golang") {
+		t.Fatalf("expected function_call for search, got %#v", msg)
+	}
+}
+
+func TestChatCompletionsLegacyFunctionsStreamFunctionCallDeltas(t *testing.T) {
+	rec := postChatCompletion(legacyFunctionsHandler(), `{"model":"deepseek-v4-flash","stream":true,"messages":[{"role":"user","content":"find golang"}],"functions":[{"name":"search","parameters":{"type":"object","properties":{"q":{"type":"string"}}}}]}`)
+	frames, done := parseSSEDataFrames(t, rec.Body.String())
+	if !done || streamHasToolCallsDelta(frames) {
+		t.Fatalf("expected a finished stream without tool_calls deltas, got %s", rec.Body.String())
+	}
+	if got := streamFinishReason(frames); got != "function_call" {
+		t.Fatalf("expected function_call finish reason, got %q", got)
+	}
+	if !strings.Contains(rec.Body.String(), `"function_call":{`) || !strings.Contains(rec.Body.String(), `"name":"search"`) {
+		t.Fatalf("expected function_call deltas, got %s", rec.Body.String())
+	}
+}
+
+func TestChatCompletionsPrefersToolsWhenLegacyFieldsAlsoSent(t *testing.T) {
+	rec := postChatCompletion(legacyFunctionsHandler(), `{"model":"deepseek-v4-flash","messages":[{"role":"user","content":"find golang"}],"tools":[{"type":"function","function":{"name":"search","parameters":{"type":"object"}}}],"functions":[{"name":"other"}]}`)
+	choice := decodeJSONBody(t, rec.Body.String())["choices"].([]any)[0].(map[string]any)
+	if choice["finish_reason"] != "tool_calls" || choice["message"].(map[string]any)["tool_calls"] == nil {
+		t.Fatalf("expected modern tool_calls response, got %#v", choice)
+	}
+}
@@ -14,6 +14,7 @@ import (
 	"ds2api/internal/config"
 	dsclient "ds2api/internal/deepseek/client"
 	"ds2api/internal/promptcompat"
+	"ds2api/internal/upstreamhook"
 )
 
 func TestIsVercelStreamPrepareRequest(t *testing.T) {
@@ -147,6 +148,55 @@ func TestHandleVercelStreamPrepareAppliesCurrentInputFile(t *testing.T) {
 	}
 }
 
+type suffixPromptHook struct {
+	upstreamhook.Nop
+}
+
+func (suffixPromptHook) BeforeUpstream(_ context.Context, req *upstreamhook.Request) error {
+	req.Prompt += "\n\nDeployment note."
+	return nil
+}
+
+func TestHandleVercelStreamPrepareAppliesUpstreamHooksToPayload(t *testing.T) {
+	t.Setenv("VERCEL", "1")
+	t.Setenv("DS2API_VERCEL_INTERNAL_SECRET", "stream-secret")
+	t.Cleanup(upstreamhook.Register(suffixPromptHook{}))
+
+	h := &Handler{
+		Store: mockOpenAIConfig{},
+		Auth:  streamStatusAuthStub{},
+		DS:    &inlineUploadDSStub{},
+	}
+	reqBody, _ := json.Marshal(map[string]any{
+		"model":    "deepseek-v4-flash",
+		"messages": []any{map[string]any{"role": "user", "content": "hello"}},
+		"stream":   true,
+	})
+	req := httptest.NewRequest(http.MethodPost, "/v1/chat/completions?__stream_prepare=1", strings.NewReader(string(reqBody)))
+	req.Header.Set("Authorization", "Bearer direct-token")
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Ds2-Internal-Token", "stream-secret")
+	rec := httptest.NewRecorder()
+
+	h.handleVercelStreamPrepare(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d body=%s", rec.Code, rec.Body.String())
+	}
+	var body map[string]any
+	if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+		t.Fatalf("decode failed: %v", err)
+	}
+	payload, _ := body["payload"].(map[string]any)
+	promptText, _ := payload["prompt"].(string)
+	if !strings.HasSuffix(promptText, "\n\nDeployment note.") {
+		t.Fatalf("expected the hook suffix in payload.prompt, got %q", promptText)
+	}
+	if payload["chat_session_id"] == nil || payload["model_type"] == nil {
+		t.Fatalf("expected the other payload fields kept, got %#v", payload)
+	}
+}
+
 func TestHandleVercelStreamPrepareUsesHalfwidthDSMLToolPrompt(t *testing.T) {
 	t.Setenv("VERCEL", "1")
 	t.Setenv("DS2API_VERCEL_INTERNAL_SECRET", "stream-secret")
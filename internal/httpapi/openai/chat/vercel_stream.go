@@ -10,9 +10,14 @@ import (
 	"time"
 
 	"ds2api/internal/auth"
+	"ds2api/internal/completionruntime"
 	"ds2api/internal/config"
+	dsclient "ds2api/internal/deepseek/client"
+	openaifmt "ds2api/internal/format/openai"
 	"ds2api/internal/httpapi/openai/history"
 	"ds2api/internal/promptcompat"
+	"ds2api/internal/toolcall"
+	"ds2api/internal/upstreamhook"
 	"ds2api/internal/util"
 
 	"github.com/google/uuid"
@@ -33,11 +38,7 @@ func (h *Handler) handleVercelStreamPrepare(w http.ResponseWriter, r *http.Reque
 
 	a, err := h.Auth.Determine(r)
 	if err != nil {
-		status := http.StatusUnauthorized
-		if err == auth.ErrNoAccount {
-			status = http.StatusTooManyRequests
-		}
-		writeOpenAIError(w, status, err.Error())
+		writeOpenAIAuthError(w, err)
 		return
 	}
 	leased := false
@@ -53,6 +54,10 @@ func (h *Handler) handleVercelStreamPrepare(w http.ResponseWriter, r *http.Reque
 		writeOpenAIError(w, http.StatusBadRequest, "invalid json")
 		return
 	}
+	if err := h.checkModelAllowed(a, req); err != nil {
+		writeOpenAIRequestError(w, err)
+		return
+	}
 	if err := h.preprocessInlineFileInputs(r.Context(), a, req); err != nil {
 		writeOpenAIInlineFileError(w, err)
 		return
@@ -63,13 +68,18 @@ func (h *Handler) handleVercelStreamPrepare(w http.ResponseWriter, r *http.Reque
 	}
 	stdReq, err := promptcompat.NormalizeOpenAIChatRequest(h.Store, req, requestTraceID(r))
 	if err != nil {
-		writeOpenAIError(w, http.StatusBadRequest, err.Error())
+		writeOpenAIRequestError(w, err)
 		return
 	}
 	if !stdReq.Stream {
 		writeOpenAIError(w, http.StatusBadRequest, "stream must be true")
 		return
 	}
+	stdReq, err = h.applyContextWindow(w, stdReq, requestTraceID(r))
+	if err != nil {
+		writeOpenAIRequestError(w, err)
+		return
+	}
 	stdReq, err = h.applyCurrentInputFile(r.Context(), a, stdReq)
 	if err != nil {
 		status, message := mapCurrentInputFileError(err)
@@ -88,7 +98,7 @@ func (h *Handler) handleVercelStreamPrepare(w http.ResponseWriter, r *http.Reque
 	}
 	powHeader, err := h.DS.GetPow(r.Context(), a, 3)
 	if err != nil {
-		writeOpenAIError(w, http.StatusUnauthorized, "Failed to get PoW (invalid token or unknown error).")
+		writeVercelPowError(w, err, http.StatusUnauthorized, "Failed to get PoW (invalid token or unknown error).")
 		return
 	}
 	if strings.TrimSpace(a.DeepSeekToken) == "" {
@@ -96,7 +106,11 @@ func (h *Handler) handleVercelStreamPrepare(w http.ResponseWriter, r *http.Reque
 		return
 	}
 
-	payload := stdReq.CompletionPayload(sessionID)
+	payload, err := vercelCompletionPayload(r, stdReq, sessionID)
+	if err != nil {
+		writeUpstreamHookError(w, err)
+		return
+	}
 	leaseID := h.holdStreamLease(a, stdReq, sessionID)
 	if leaseID == "" {
 		writeOpenAIError(w, http.StatusInternalServerError, "failed to create stream lease")
@@ -104,16 +118,20 @@ func (h *Handler) handleVercelStreamPrepare(w http.ResponseWriter, r *http.Reque
 	}
 	leased = true
 	writeJSON(w, http.StatusOK, map[string]any{
-		"session_id":       sessionID,
-		"lease_id":         leaseID,
-		"model":            stdReq.ResponseModel,
-		"final_prompt":     stdReq.FinalPrompt,
-		"thinking_enabled": stdReq.Thinking,
-		"search_enabled":   stdReq.Search,
-		"tool_names":       stdReq.ToolNames,
-		"deepseek_token":   a.DeepSeekToken,
-		"pow_header":       powHeader,
-		"payload":          payload,
+		"session_id":         sessionID,
+		"lease_id":           leaseID,
+		"model":              stdReq.ResponseModel,
+		"system_fingerprint": openaifmt.SystemFingerprint(stdReq.ResponseModel),
+		"final_prompt":       stdReq.FinalPrompt,
+		"thinking_enabled":   stdReq.Thinking,
+		"search_enabled":     stdReq.Search,
+		"tool_names":         stdReq.ToolNames,
+		"tool_call_format":   toolcall.NormalizeCallFormat(stdReq.ToolPrompt.Format),
+		"banned_words":       stdReq.BannedWords,
+		"context_trimmed":    stdReq.ContextTrimmedMessages,
+		"deepseek_token":     a.DeepSeekToken,
+		"pow_header":         powHeader,
+		"payload":            payload,
 	})
 }
 
@@ -185,7 +203,7 @@ func (h *Handler) handleVercelStreamPow(w http.ResponseWriter, r *http.Request)
 	}
 	powHeader, err := h.DS.GetPow(r.Context(), leaseAuth, 3)
 	if err != nil {
-		writeOpenAIError(w, http.StatusInternalServerError, "Failed to get PoW.")
+		writeVercelPowError(w, err, http.StatusInternalServerError, "Failed to get PoW.")
 		return
 	}
 	writeJSON(w, http.StatusOK, map[string]any{
@@ -245,25 +263,32 @@ func (h *Handler) handleVercelStreamSwitch(w http.ResponseWriter, r *http.Reques
 	}
 	powHeader, err := h.DS.GetPow(r.Context(), a, 3)
 	if err != nil {
-		writeOpenAIError(w, http.StatusUnauthorized, "Failed to get PoW (invalid token or unknown error).")
+		writeVercelPowError(w, err, http.StatusUnauthorized, "Failed to get PoW (invalid token or unknown error).")
 		return
 	}
 	if strings.TrimSpace(a.DeepSeekToken) == "" {
 		writeOpenAIError(w, http.StatusUnauthorized, "Account token is invalid. Please re-login the account in admin.")
 		return
 	}
+	payload, err := vercelCompletionPayload(r, stdReq, sessionID)
+	if err != nil {
+		writeUpstreamHookError(w, err)
+		return
+	}
 	h.updateStreamLeaseState(leaseID, stdReq, sessionID)
 	writeJSON(w, http.StatusOK, map[string]any{
-		"session_id":       sessionID,
-		"lease_id":         leaseID,
-		"model":            stdReq.ResponseModel,
-		"final_prompt":     stdReq.FinalPrompt,
-		"thinking_enabled": stdReq.Thinking,
-		"search_enabled":   stdReq.Search,
-		"tool_names":       stdReq.ToolNames,
-		"deepseek_token":   a.DeepSeekToken,
-		"pow_header":       powHeader,
-		"payload":          stdReq.CompletionPayload(sessionID),
+		"session_id":         sessionID,
+		"lease_id":           leaseID,
+		"model":              stdReq.ResponseModel,
+		"system_fingerprint": openaifmt.SystemFingerprint(stdReq.ResponseModel),
+		"final_prompt":       stdReq.FinalPrompt,
+		"thinking_enabled":   stdReq.Thinking,
+		"search_enabled":     stdReq.Search,
+		"tool_names":         stdReq.ToolNames,
+		"banned_words":       stdReq.BannedWords,
+		"deepseek_token":     a.DeepSeekToken,
+		"pow_header":         powHeader,
+		"payload":            payload,
 	})
 }
 
@@ -436,3 +461,27 @@ func streamLeaseTTL() time.Duration {
 func newLeaseID() string {
 	return strings.ReplaceAll(uuid.NewString(), "-", "")
 }
+
+// vercelCompletionPayload builds the completion payload the Node stream sends
+// upstream itself, after the BeforeUpstream hooks had their say. Only the
+// prompt and params reach Node; header changes and AfterUpstream hooks apply
+// to calls the Go server sends.
+func vercelCompletionPayload(r *http.Request, stdReq promptcompat.StandardRequest, sessionID string) (map[string]any, error) {
+	return upstreamhook.ApplyToPayload(r.Context(), stdReq.CompletionPayload(sessionID), nil)
+}
+
+func writeUpstreamHookError(w http.ResponseWriter, err error) {
+	hookErr := upstreamhook.AsError(err)
+	writeOpenAIErrorWithCode(w, hookErr.Status, hookErr.Message, hookErr.Code)
+}
+
+// writeVercelPowError reports a failed PoW fetch, using the same retryable
+// pow_solve_failed 503 as the Go completion path when the challenge itself
+// could not be solved.
+func writeVercelPowError(w http.ResponseWriter, err error, status int, message string) {
+	if dsclient.IsPowSolveError(err) {
+		writeOpenAIErrorWithCode(w, http.StatusServiceUnavailable, "Failed to solve the DeepSeek PoW challenge; retry the request.", completionruntime.CodePowSolveFailed)
+		return
+	}
+	writeOpenAIError(w, status, message)
+}
@@ -0,0 +1,124 @@
+package completions
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/google/uuid"
+
+	"ds2api/internal/assistantturn"
+	"ds2api/internal/auth"
+	"ds2api/internal/completionruntime"
+	openaifmt "ds2api/internal/format/openai"
+	"ds2api/internal/httpapi/openai/shared"
+	"ds2api/internal/promptcompat"
+)
+
+// Handler serves the legacy POST /v1/completions text endpoint on top of the
+// chat generation path.
+type Handler struct {
+	Store shared.ConfigReader
+	Auth  shared.AuthResolver
+	DS    shared.DeepSeekCaller
+}
+
+func (h *Handler) Completions(w http.ResponseWriter, r *http.Request) {
+	a, err := h.Auth.Determine(r)
+	if err != nil {
+		shared.WriteOpenAIAuthError(w, err)
+		return
+	}
+	defer h.Auth.Release(a)
+	r = r.WithContext(auth.WithAuth(r.Context(), a))
+
+	r.Body = http.MaxBytesReader(w, r.Body, shared.GeneralMaxSize)
+	var req map[string]any
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		shared.WriteOpenAIDecodeError(w, err)
+		return
+	}
+	if err := shared.CheckRequestModelAllowed(h.Store, a, req); err != nil {
+		shared.WriteOpenAIRequestError(w, err)
+		return
+	}
+	traceID := shared.RequestTraceID(r)
+	completionReq, err := promptcompat.NormalizeOpenAICompletionsRequest(h.Store, req, traceID, shared.MaxCompletionChoices(h.Store))
+	if err != nil {
+		shared.WriteOpenAIRequestError(w, err)
+		return
+	}
+	for i, stdReq := range completionReq.Requests {
+		stdReq, err = shared.ApplyContextWindow(w, h.Store, stdReq, traceID)
+		if err != nil {
+			shared.WriteOpenAIRequestError(w, err)
+			return
+		}
+		completionReq.Requests[i] = stdReq
+	}
+
+	completionID := "cmpl-" + strings.ReplaceAll(uuid.NewString(), "-", "")
+	opts := completionruntime.Options{
+		RetryEnabled:             true,
+		UpstreamRetryMaxAttempts: shared.UpstreamRetryMaxAttempts(h.Store),
+		TraceID:                  traceID,
+		CurrentInputFile:         h.Store,
+	}
+	if completionReq.Requests[0].Stream {
+		h.handleStream(w, r, a, completionReq, completionID, opts)
+		return
+	}
+	h.handleNonStream(w, r, a, completionReq, completionID, opts)
+}
+
+// handleNonStream generates every prompt in order. Choice indexes run
+// prompt by prompt, so prompt p's choice i is index p*n+i.
+func (h *Handler) handleNonStream(w http.ResponseWriter, r *http.Request, a *auth.RequestAuth, completionReq promptcompat.CompletionRequest, completionID string, opts completionruntime.Options) {
+	n := choiceCount(completionReq)
+	choices := make([]map[string]any, 0, len(completionReq.Requests)*n)
+	var usage assistantturn.Usage
+	for p, stdReq := range completionReq.Requests {
+		results, outErr := completionruntime.ExecuteNonStreamChoices(r.Context(), h.DS, a, stdReq, opts)
+		if outErr != nil {
+			shared.WriteOpenAIErrorWithCode(w, outErr.Status, outErr.Message, outErr.Code)
+			return
+		}
+		usages := make([]assistantturn.Usage, 0, len(results))
+		for i, result := range results {
+			text := result.Turn.Text
+			if completionReq.Echo {
+				text = completionReq.Prompts[p] + text
+			}
+			choices = append(choices, openaifmt.BuildTextCompletionChoice(p*n+i, text, assistantturn.FinishReason(result.Turn)))
+			usages = append(usages, result.Turn.Usage)
+		}
+		usage = addUsage(usage, assistantturn.CombineChoiceUsage(usages))
+	}
+	model := completionReq.Requests[0].ResponseModel
+	shared.WriteJSON(w, http.StatusOK, openaifmt.BuildTextCompletion(completionID, model, choices, textCompletionUsage(usage)))
+}
+
+func choiceCount(completionReq promptcompat.CompletionRequest) int {
+	if n := completionReq.Requests[0].Choices; n > 1 {
+		return n
+	}
+	return 1
+}
+
+// addUsage totals usage across different prompts, whose prompt tokens are
+// all billed.
+func addUsage(total, u assistantturn.Usage) assistantturn.Usage {
+	total.InputTokens += u.InputTokens
+	total.OutputTokens += u.OutputTokens
+	total.ReasoningTokens += u.ReasoningTokens
+	total.TotalTokens = total.InputTokens + total.OutputTokens
+	return total
+}
+
+func textCompletionUsage(u assistantturn.Usage) map[string]any {
+	return map[string]any{
+		"prompt_tokens":     u.InputTokens,
+		"completion_tokens": u.OutputTokens,
+		"total_tokens":      u.TotalTokens,
+	}
+}
@@ -0,0 +1,329 @@
+package completions
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"ds2api/internal/assistantturn"
+	"ds2api/internal/auth"
+	"ds2api/internal/completionruntime"
+	"ds2api/internal/config"
+	dsprotocol "ds2api/internal/deepseek/protocol"
+	openaifmt "ds2api/internal/format/openai"
+	"ds2api/internal/httpapi/openai/shared"
+	"ds2api/internal/httpapi/requestctx"
+	"ds2api/internal/metrics"
+	"ds2api/internal/promptcompat"
+	"ds2api/internal/sse"
+	streamengine "ds2api/internal/stream"
+	"ds2api/internal/textclean"
+)
+
+// handleStream streams every prompt and choice one after another over a
+// single SSE response, each chunk tagged with its choice index. A failure
+// before the first byte is an ordinary JSON error; after that it ends the
+// stream with a failed chunk.
+func (h *Handler) handleStream(w http.ResponseWriter, r *http.Request, a *auth.RequestAuth, completionReq promptcompat.CompletionRequest, completionID string, opts completionruntime.Options) {
+	first := completionReq.Requests[0]
+	stream := &completionStream{w: w, rc: http.NewResponseController(w), completionID: completionID, created: time.Now().Unix(), model: first.ResponseModel}
+	_, stream.canFlush = w.(http.Flusher)
+	n := choiceCount(completionReq)
+	var usage assistantturn.Usage
+	for p, stdReq := range completionReq.Requests {
+		usages := make([]assistantturn.Usage, 0, n)
+		for i := 0; i < n; i++ {
+			choice, ok := h.streamChoice(r, a, stream, stdReq, p*n+i, completionReq, p, opts)
+			if !ok {
+				return
+			}
+			usages = append(usages, choice.usage)
+		}
+		usage = addUsage(usage, assistantturn.CombineChoiceUsage(usages))
+	}
+	if first.IncludeUsage {
+		stream.sendChunk(openaifmt.BuildTextCompletionChunk(stream.completionID, stream.created, stream.model, []map[string]any{}, textCompletionUsage(usage)))
+	}
+	stream.sendDone()
+}
+
+// streamChoice starts and streams one generation. ok is false once the
+// response has been ended, by an error or a cancelled request.
+func (h *Handler) streamChoice(r *http.Request, a *auth.RequestAuth, stream *completionStream, stdReq promptcompat.StandardRequest, index int, completionReq promptcompat.CompletionRequest, promptIndex int, opts completionruntime.Options) (*completionChoiceRuntime, bool) {
+	start, outErr := completionruntime.StartCompletion(r.Context(), h.DS, a, stdReq, opts)
+	if outErr != nil {
+		stream.fail(outErr.Status, outErr.Message, outErr.Code)
+		return nil, false
+	}
+	if start.Response.StatusCode != http.StatusOK {
+		defer func() { _ = start.Response.Body.Close() }()
+		body, _ := io.ReadAll(start.Response.Body)
+		stream.fail(start.Response.StatusCode, strings.TrimSpace(string(body)), "error")
+		return nil, false
+	}
+	stream.begin()
+	stdReq = start.Request
+	choice := newCompletionChoiceRuntime(stream, index, stdReq)
+	if completionReq.Echo && completionReq.Prompts[promptIndex] != "" {
+		choice.sendText(completionReq.Prompts[promptIndex])
+	}
+	completionruntime.ExecuteStreamWithRetry(r.Context(), h.DS, a, start.Response, start.Payload, start.Pow, completionruntime.StreamRetryOptions{
+		Surface:                  "completions",
+		Stream:                   true,
+		RetryEnabled:             shared.EmptyOutputRetryEnabled(),
+		RetryMaxAttempts:         shared.EmptyOutputRetryMaxAttempts(),
+		MaxAttempts:              3,
+		UsagePrompt:              stdReq.PromptTokenText,
+		Request:                  stdReq,
+		CurrentInputFile:         h.Store,
+		UpstreamRetryMaxAttempts: opts.UpstreamRetryMaxAttempts,
+		TraceID:                  opts.TraceID,
+	}, completionruntime.StreamRetryHooks{
+		ConsumeAttempt: func(currentResp *http.Response, allowDeferEmpty bool) (bool, bool) {
+			return choice.consumeAttempt(r.Context(), currentResp, allowDeferEmpty)
+		},
+		Finalize: func(_ int) {
+			choice.finalize(false)
+		},
+		ParentMessageID: func() int {
+			return choice.responseMessageID
+		},
+		OnRetryPrompt: func(prompt string) {
+			choice.finalPrompt = prompt
+		},
+		OnRetryFailure: func(status int, message, code string) {
+			stream.fail(status, message, code)
+		},
+	})
+	metrics.AddGeneratedTokens(r.Context(), choice.usage.OutputTokens)
+	if stream.ended || r.Context().Err() != nil {
+		return nil, false
+	}
+	return choice, true
+}
+
+// completionStream owns the SSE response shared by every choice.
+type completionStream struct {
+	w        http.ResponseWriter
+	rc       *http.ResponseController
+	canFlush bool
+
+	completionID string
+	created      int64
+	model        string
+
+	started bool
+	ended   bool
+}
+
+func (s *completionStream) begin() {
+	if s.started {
+		return
+	}
+	s.started = true
+	s.w.Header().Set("Content-Type", "text/event-stream")
+	s.w.Header().Set("Cache-Control", "no-cache, no-transform")
+	s.w.Header().Set("Connection", "keep-alive")
+	s.w.Header().Set("X-Accel-Buffering", "no")
+	if !s.canFlush {
+		config.Logger.Warn("[completions_stream] response writer does not support flush; streaming may be buffered")
+	}
+}
+
+func (s *completionStream) sendKeepAlive() {
+	if !s.canFlush {
+		return
+	}
+	_, _ = s.w.Write([]byte(": keep-alive\n\n"))
+	_ = s.rc.Flush()
+}
+
+func (s *completionStream) sendChunk(v any) {
+	b, _ := json.Marshal(v)
+	_, _ = s.w.Write([]byte("data: "))
+	_, _ = s.w.Write(b)
+	_, _ = s.w.Write([]byte("\n\n"))
+	if s.canFlush {
+		_ = s.rc.Flush()
+	}
+}
+
+func (s *completionStream) sendDone() {
+	s.ended = true
+	_, _ = s.w.Write([]byte("data: [DONE]\n\n"))
+	if s.canFlush {
+		_ = s.rc.Flush()
+	}
+}
+
+// fail reports an error as JSON before the stream started and as a failed
+// chunk followed by [DONE] afterwards.
+func (s *completionStream) fail(status int, message, code string) {
+	if s.ended {
+		return
+	}
+	if !s.started {
+		s.ended = true
+		shared.WriteOpenAIErrorWithCode(s.w, status, message, code)
+		return
+	}
+	s.sendChunk(requestctx.WithTraceID(s.w, map[string]any{
+		"status_code": status,
+		"error": map[string]any{
+			"message": message,
+			"type":    shared.OpenAIErrorType(status),
+			"code":    code,
+			"param":   nil,
+		},
+	}))
+	s.sendDone()
+}
+
+// markContextCancelled tells a still-listening client why the stream stopped;
+// a disconnected client gets nothing.
+func (s *completionStream) markContextCancelled(ctx context.Context) {
+	switch requestctx.CancelReason(ctx) {
+	case requestctx.ReasonDeadlineExceeded:
+		s.fail(http.StatusGatewayTimeout, requestctx.TimeoutMessage, requestctx.CodeRequestTimeout)
+	case requestctx.ReasonServerShutdown:
+		s.fail(http.StatusServiceUnavailable, requestctx.ShutdownMessage, requestctx.CodeServerShutdown)
+	}
+}
+
+// completionChoiceRuntime streams the text of one generation.
+type completionChoiceRuntime struct {
+	stream *completionStream
+	index  int
+
+	model                 string
+	finalPrompt           string
+	searchEnabled         bool
+	stripReferenceMarkers bool
+
+	accumulator       shared.StreamAccumulator
+	contentFilter     bool
+	responseMessageID int
+	usage             assistantturn.Usage
+}
+
+func newCompletionChoiceRuntime(stream *completionStream, index int, stdReq promptcompat.StandardRequest) *completionChoiceRuntime {
+	stripReferenceMarkers := textclean.StripReferenceMarkersEnabled()
+	return &completionChoiceRuntime{
+		stream:                stream,
+		index:                 index,
+		model:                 stdReq.ResponseModel,
+		finalPrompt:           stdReq.PromptTokenText,
+		searchEnabled:         stdReq.Search,
+		stripReferenceMarkers: stripReferenceMarkers,
+		accumulator: shared.StreamAccumulator{
+			ThinkingEnabled:       stdReq.Thinking,
+			SearchEnabled:         stdReq.Search,
+			StripReferenceMarkers: stripReferenceMarkers,
+			Stop:                  sse.NewStopSequenceMatcher(stdReq.StopSequences),
+			Limit:                 sse.NewOutputTokenLimiter(stdReq.MaxOutputTokens, stdReq.ResponseModel),
+			Banned:                sse.NewBannedWordFilter(stdReq.BannedWords),
+		},
+	}
+}
+
+func (c *completionChoiceRuntime) sendText(text string) {
+	c.stream.sendChunk(openaifmt.BuildTextCompletionChunk(c.stream.completionID, c.stream.created, c.stream.model, []map[string]any{openaifmt.BuildTextCompletionChoice(c.index, text, nil)}, nil))
+}
+
+func (c *completionChoiceRuntime) consumeAttempt(ctx context.Context, resp *http.Response, allowDeferEmpty bool) (bool, bool) {
+	defer func() { _ = resp.Body.Close() }()
+	initialType := "text"
+	if c.accumulator.ThinkingEnabled {
+		initialType = "thinking"
+	}
+	cancelled := false
+	streamengine.ConsumeSSE(streamengine.ConsumeConfig{
+		Context:             ctx,
+		Body:                resp.Body,
+		ThinkingEnabled:     c.accumulator.ThinkingEnabled,
+		InitialType:         initialType,
+		KeepAliveInterval:   time.Duration(dsprotocol.KeepAliveTimeout) * time.Second,
+		IdleTimeout:         time.Duration(dsprotocol.StreamIdleTimeout) * time.Second,
+		MaxKeepAliveNoInput: dsprotocol.MaxKeepaliveCount,
+	}, streamengine.ConsumeHooks{
+		OnKeepAlive: c.stream.sendKeepAlive,
+		OnParsed:    c.onParsed,
+		OnContextDone: func() {
+			cancelled = true
+			c.stream.markContextCancelled(ctx)
+		},
+	})
+	if cancelled {
+		return true, false
+	}
+	if c.finalize(allowDeferEmpty) {
+		return true, false
+	}
+	return false, true
+}
+
+func (c *completionChoiceRuntime) onParsed(parsed sse.LineResult) streamengine.ParsedDecision {
+	if !parsed.Parsed {
+		return streamengine.ParsedDecision{}
+	}
+	if parsed.ResponseMessageID > 0 {
+		c.responseMessageID = parsed.ResponseMessageID
+	}
+	if parsed.ContentFilter || parsed.ErrorMessage != "" || parsed.Stop {
+		if parsed.ContentFilter {
+			c.contentFilter = true
+		}
+		return streamengine.ParsedDecision{Stop: true}
+	}
+	accumulated := c.accumulator.Apply(parsed)
+	c.emitParts(accumulated.Parts)
+	if c.accumulator.StopSequenceMatched() || c.accumulator.OutputLimitReached() {
+		return streamengine.ParsedDecision{ContentSeen: accumulated.ContentSeen, Stop: true}
+	}
+	return streamengine.ParsedDecision{ContentSeen: accumulated.ContentSeen}
+}
+
+// emitParts streams answer text; the legacy shape has no place for thinking.
+func (c *completionChoiceRuntime) emitParts(parts []shared.StreamPartDelta) {
+	for _, p := range parts {
+		if p.Type != "text" || p.VisibleText == "" || p.CitationOnly {
+			continue
+		}
+		c.sendText(p.VisibleText)
+	}
+}
+
+// finalize flushes held-back text and writes the choice's finish chunk. With
+// deferEmptyOutput it reports an empty answer as retryable instead.
+func (c *completionChoiceRuntime) finalize(deferEmptyOutput bool) bool {
+	c.emitParts(c.accumulator.FlushStopHold().Parts)
+	turn := assistantturn.BuildTurnFromStreamSnapshot(assistantturn.StreamSnapshot{
+		RawText:            c.accumulator.RawText.String(),
+		VisibleText:        c.accumulator.Text.String(),
+		RawThinking:        c.accumulator.RawThinking.String(),
+		VisibleThinking:    c.accumulator.Thinking.String(),
+		DetectionThinking:  c.accumulator.ToolDetectionThinking.String(),
+		ContentFilter:      c.contentFilter,
+		ResponseMessageID:  c.responseMessageID,
+		OutputLimitReached: c.accumulator.OutputLimitReached(),
+	}, assistantturn.BuildOptions{
+		Model:                 c.model,
+		Prompt:                c.finalPrompt,
+		SearchEnabled:         c.searchEnabled,
+		StripReferenceMarkers: c.stripReferenceMarkers,
+	})
+	outcome := assistantturn.FinalizeTurn(turn, assistantturn.FinalizeOptions{})
+	if outcome.ShouldFail {
+		if deferEmptyOutput {
+			return false
+		}
+		c.stream.fail(outcome.Error.Status, outcome.Error.Message, outcome.Error.Code)
+		return true
+	}
+	c.usage = turn.Usage
+	c.stream.sendChunk(openaifmt.BuildTextCompletionChunk(c.stream.completionID, c.stream.created, c.stream.model, []map[string]any{openaifmt.BuildTextCompletionChoice(c.index, "", outcome.FinishReason)}, nil))
+	return true
+}
@@ -0,0 +1,103 @@
+package openai
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func postCompletions(t *testing.T, h *openAITestSurface, body string) *httptest.ResponseRecorder {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodPost, "/v1/completions", strings.NewReader(body))
+	req.Header.Set("Authorization", "Bearer direct-token")
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+	newOpenAITestRouter(h).ServeHTTP(rec, req)
+	return rec
+}
+
+func TestCompletionsNonStreamPromptArrayAndEcho(t *testing.T) {
+	ds := &streamStatusDSSeqStub{resps: []*http.Response{
+		makeOpenAISSEHTTPResponse(`data: {"p":"response/content","v":" world"}`, "data: [DONE]"),
+		makeOpenAISSEHTTPResponse(`data: {"p":"response/content","v":" there"}`, "data: [DONE]"),
+	}}
+	h := &openAITestSurface{Store: mockOpenAIConfig{}, Auth: streamStatusAuthStub{}, DS: ds}
+	rec := postCompletions(t, h, `{"model":"deepseek-v4-flash","prompt":["hello","hi"],"echo":true}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d body=%s", rec.Code, rec.Body.String())
+	}
+	var out map[string]any
+	if err := json.Unmarshal(rec.Body.Bytes(), &out); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if out["object"] != "text_completion" || !strings.HasPrefix(asString(out["id"]), "cmpl-") {
+		t.Fatalf("unexpected envelope: %#v", out)
+	}
+	choices, _ := out["choices"].([]any)
+	if len(choices) != 2 {
+		t.Fatalf("expected one choice per prompt, got %#v", out["choices"])
+	}
+	for i, want := range []string{"hello world", "hi there"} {
+		choice, _ := choices[i].(map[string]any)
+		if choice["text"] != want || choice["index"] != float64(i) || choice["finish_reason"] != "stop" {
+			t.Fatalf("unexpected choice %d: %#v", i, choice)
+		}
+		if _, ok := choice["logprobs"]; !ok || choice["logprobs"] != nil {
+			t.Fatalf("expected null logprobs on choice %d: %#v", i, choice)
+		}
+	}
+	if len(ds.payloads) != 2 || !strings.Contains(asString(ds.payloads[1]["prompt"]), "hi") {
+		t.Fatalf("expected one upstream call per prompt, got %#v", ds.payloads)
+	}
+	usage, _ := out["usage"].(map[string]any)
+	if usage["completion_tokens"] == nil || usage["prompt_tokens"] == nil {
+		t.Fatalf("expected text completion usage, got %#v", out["usage"])
+	}
+}
+
+func TestCompletionsStreamEmitsTextChunks(t *testing.T) {
+	ds := &streamStatusDSSeqStub{resps: []*http.Response{
+		makeOpenAISSEHTTPResponse(`data: {"p":"response/content","v":" world"}`, "data: [DONE]"),
+	}}
+	h := &openAITestSurface{Store: mockOpenAIConfig{}, Auth: streamStatusAuthStub{}, DS: ds}
+	rec := postCompletions(t, h, `{"model":"deepseek-v4-flash","prompt":"hello","stream":true,"stream_options":{"include_usage":true}}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d body=%s", rec.Code, rec.Body.String())
+	}
+	frames, done := parseSSEDataFrames(t, rec.Body.String())
+	if !done {
+		t.Fatalf("expected [DONE], body=%s", rec.Body.String())
+	}
+	var text strings.Builder
+	finish := any(nil)
+	for _, frame := range frames {
+		if frame["object"] != "text_completion" {
+			t.Fatalf("unexpected chunk object: %#v", frame)
+		}
+		choices, _ := frame["choices"].([]any)
+		for _, item := range choices {
+			choice, _ := item.(map[string]any)
+			text.WriteString(asString(choice["text"]))
+			if choice["finish_reason"] != nil {
+				finish = choice["finish_reason"]
+			}
+		}
+	}
+	if text.String() != " world" || finish != "stop" {
+		t.Fatalf("unexpected streamed text %q finish=%v body=%s", text.String(), finish, rec.Body.String())
+	}
+	last := frames[len(frames)-1]
+	if choices, _ := last["choices"].([]any); len(choices) != 0 || last["usage"] == nil {
+		t.Fatalf("expected a trailing usage chunk, got %#v", last)
+	}
+}
+
+func TestCompletionsRejectsTokenPrompts(t *testing.T) {
+	h := &openAITestSurface{Store: mockOpenAIConfig{}, Auth: streamStatusAuthStub{}, DS: &streamStatusDSSeqStub{}}
+	rec := postCompletions(t, h, `{"model":"deepseek-v4-flash","prompt":[[1,2,3]]}`)
+	if rec.Code != http.StatusBadRequest || !strings.Contains(rec.Body.String(), "unsupported_parameter") {
+		t.Fatalf("expected an unsupported_parameter error, got %d body=%s", rec.Code, rec.Body.String())
+	}
+}
@@ -4,11 +4,14 @@ import (
 	"strings"
 	"testing"
 
+	"ds2api/internal/config"
 	"ds2api/internal/promptcompat"
 )
 
 type mockOpenAIConfig struct {
 	aliases             map[string]string
+	defaultModel        string
+	responseModelMode   string
 	autoDeleteMode      string
 	toolMode            string
 	earlyEmit           string
@@ -18,13 +21,26 @@ type mockOpenAIConfig struct {
 	currentInputMin     int
 	thinkingInjection   *bool
 	thinkingPrompt      string
+	contextMaxTokens    int
+	contextTrimStrategy string
+	strictSampling      bool
+	remoteImageFetch    bool
 }
 
-func (m mockOpenAIConfig) ModelAliases() map[string]string     { return m.aliases }
+func (m mockOpenAIConfig) ModelAliases() map[string]string { return m.aliases }
+func (mockOpenAIConfig) ModelFallbacks(string) []string    { return nil }
+func (mockOpenAIConfig) FallbackBackend(string) (config.APIBackendConfig, bool) {
+	return config.APIBackendConfig{}, false
+}
+func (m mockOpenAIConfig) ModelDefaultTarget() string          { return m.defaultModel }
+func (m mockOpenAIConfig) ModelResponseMode() string           { return m.responseModelMode }
 func (m mockOpenAIConfig) ToolcallMode() string                { return m.toolMode }
 func (m mockOpenAIConfig) ToolcallEarlyEmitConfidence() string { return m.earlyEmit }
 func (m mockOpenAIConfig) ResponsesStoreTTLSeconds() int       { return m.responsesTTL }
 func (m mockOpenAIConfig) EmbeddingsProvider() string          { return m.embedProv }
+func (m mockOpenAIConfig) EmbeddingsSettings() config.EmbeddingsConfig {
+	return config.EmbeddingsConfig{Provider: m.embedProv, MaxInputs: config.DefaultEmbeddingsMaxInputs, MaxTokens: config.DefaultEmbeddingsMaxTokens}
+}
 func (m mockOpenAIConfig) AutoDeleteMode() string {
 	if m.autoDeleteMode == "" {
 		return "none"
@@ -42,7 +58,13 @@ func (m mockOpenAIConfig) ThinkingInjectionEnabled() bool {
 	}
 	return *m.thinkingInjection
 }
-func (m mockOpenAIConfig) ThinkingInjectionPrompt() string { return m.thinkingPrompt }
+func (m mockOpenAIConfig) ThinkingInjectionPrompt() string      { return m.thinkingPrompt }
+func (m mockOpenAIConfig) RuntimeUpstreamRetryMaxAttempts() int { return 1 }
+func (m mockOpenAIConfig) RuntimeMaxCompletionChoices() int     { return 4 }
+func (m mockOpenAIConfig) RuntimeContextMaxTokens() int         { return m.contextMaxTokens }
+func (m mockOpenAIConfig) RuntimeContextTrimStrategy() string   { return m.contextTrimStrategy }
+func (m mockOpenAIConfig) RuntimeStrictSamplingParams() bool    { return m.strictSampling }
+func (m mockOpenAIConfig) RuntimeRemoteImageFetch() bool        { return m.remoteImageFetch }
 
 func TestNormalizeOpenAIChatRequestWithConfigInterface(t *testing.T) {
 	cfg := mockOpenAIConfig{
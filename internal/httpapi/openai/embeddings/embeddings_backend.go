@@ -0,0 +1,205 @@
+package embeddings
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"ds2api/internal/config"
+	"ds2api/internal/httpapi/requestctx"
+	"ds2api/internal/metrics"
+	"ds2api/internal/util"
+)
+
+// backendHTTPClient is shared by every OpenAI-compatible backend call; the
+// request deadline still bounds each call through its context.
+var backendHTTPClient = &http.Client{Timeout: 120 * time.Second}
+
+type embeddingInput struct {
+	// Text is the input as a string, used for token estimates and the
+	// deterministic provider.
+	Text string
+	// Raw is the original JSON item (a string or a token array) forwarded
+	// unchanged to HTTP backends.
+	Raw any
+}
+
+type embeddingOptions struct {
+	Model          string
+	EncodingFormat string
+	Dimensions     any
+	User           string
+}
+
+type embeddingResult struct {
+	// Vectors are in input order; each is the JSON value of one embedding
+	// so base64 output from a backend passes through untouched.
+	Vectors     []any
+	InputTokens int
+}
+
+// backendError carries the OpenAI-style status and code for a failed
+// backend call.
+type backendError struct {
+	Status  int
+	Message string
+	Code    string
+}
+
+func (e *backendError) Error() string { return e.Message }
+
+type embeddingBackend interface {
+	Embed(ctx context.Context, inputs []embeddingInput, opts embeddingOptions) (embeddingResult, error)
+}
+
+type deterministicBackend struct{}
+
+func (deterministicBackend) Embed(_ context.Context, inputs []embeddingInput, _ embeddingOptions) (embeddingResult, error) {
+	out := embeddingResult{Vectors: make([]any, len(inputs))}
+	for i, input := range inputs {
+		out.Vectors[i] = DeterministicEmbedding(input.Text)
+	}
+	return out, nil
+}
+
+// openAICompatibleBackend calls POST {BaseURL}/embeddings on any server that
+// speaks the OpenAI embeddings API.
+type openAICompatibleBackend struct {
+	BaseURL string
+	APIKey  string
+	Model   string
+}
+
+func (b openAICompatibleBackend) Embed(ctx context.Context, inputs []embeddingInput, opts embeddingOptions) (embeddingResult, error) {
+	raw := make([]any, len(inputs))
+	for i, input := range inputs {
+		raw[i] = input.Raw
+	}
+	model := b.Model
+	if model == "" {
+		model = opts.Model
+	}
+	reqBody := map[string]any{"model": model, "input": raw}
+	if opts.EncodingFormat != "" {
+		reqBody["encoding_format"] = opts.EncodingFormat
+	}
+	if opts.Dimensions != nil {
+		reqBody["dimensions"] = opts.Dimensions
+	}
+	if opts.User != "" {
+		reqBody["user"] = opts.User
+	}
+	body, err := json.Marshal(reqBody)
+	if err != nil {
+		return embeddingResult{}, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, strings.TrimRight(b.BaseURL, "/")+"/embeddings", bytes.NewReader(body))
+	if err != nil {
+		return embeddingResult{}, &backendError{Status: http.StatusNotImplemented, Message: "Embeddings backend base_url is invalid.", Code: "embeddings_backend_misconfigured"}
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if b.APIKey != "" {
+		req.Header.Set("Authorization", "Bearer "+b.APIKey)
+	}
+
+	started := time.Now()
+	resp, err := backendHTTPClient.Do(req)
+	if err != nil {
+		if requestctx.DeadlineExceeded(ctx) {
+			return embeddingResult{}, &backendError{Status: http.StatusGatewayTimeout, Message: requestctx.TimeoutMessage, Code: requestctx.CodeRequestTimeout}
+		}
+		metrics.RecordError(ctx, metrics.ErrorUpstreamUnavailable)
+		config.Logger.WarnContext(ctx, "[embeddings] backend request failed", "error", err, "elapsed", time.Since(started))
+		return embeddingResult{}, &backendError{Status: http.StatusBadGateway, Message: "Embeddings backend request failed.", Code: "upstream_error"}
+	}
+	defer func() {
+		if err := resp.Body.Close(); err != nil {
+			config.Logger.WarnContext(ctx, "[embeddings] backend response close failed", "error", err)
+		}
+	}()
+	payload, err := io.ReadAll(io.LimitReader(resp.Body, 256<<20))
+	if err != nil {
+		return embeddingResult{}, &backendError{Status: http.StatusBadGateway, Message: "Embeddings backend response could not be read.", Code: "upstream_error"}
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		if resp.StatusCode >= http.StatusInternalServerError {
+			metrics.RecordError(ctx, metrics.ErrorUpstream5xx)
+		}
+		config.Logger.WarnContext(ctx, "[embeddings] backend returned error status", "status", resp.StatusCode, "body", truncateForLog(string(payload)))
+		return embeddingResult{}, &backendError{Status: http.StatusBadGateway, Message: fmt.Sprintf("Embeddings backend returned status %d.", resp.StatusCode), Code: "upstream_error"}
+	}
+	return decodeOpenAIEmbeddings(ctx, payload, len(inputs))
+}
+
+func decodeOpenAIEmbeddings(ctx context.Context, payload []byte, want int) (embeddingResult, error) {
+	var parsed struct {
+		Data []struct {
+			Index     int             `json:"index"`
+			Embedding json.RawMessage `json:"embedding"`
+		} `json:"data"`
+		Usage struct {
+			PromptTokens int `json:"prompt_tokens"`
+		} `json:"usage"`
+	}
+	invalid := &backendError{Status: http.StatusBadGateway, Message: "Embeddings backend returned an invalid response.", Code: "upstream_error"}
+	if err := json.Unmarshal(payload, &parsed); err != nil {
+		metrics.RecordError(ctx, metrics.ErrorParseFailure)
+		return embeddingResult{}, invalid
+	}
+	if len(parsed.Data) != want {
+		metrics.RecordError(ctx, metrics.ErrorParseFailure)
+		return embeddingResult{}, invalid
+	}
+	// Backends may answer out of order; the index field is authoritative.
+	sort.SliceStable(parsed.Data, func(i, j int) bool { return parsed.Data[i].Index < parsed.Data[j].Index })
+	out := embeddingResult{Vectors: make([]any, want), InputTokens: parsed.Usage.PromptTokens}
+	for i, item := range parsed.Data {
+		if item.Index != i || len(item.Embedding) == 0 {
+			metrics.RecordError(ctx, metrics.ErrorParseFailure)
+			return embeddingResult{}, invalid
+		}
+		out.Vectors[i] = item.Embedding
+	}
+	return out, nil
+}
+
+// selectBackend maps embeddings.provider to a backend. An empty or unknown
+// provider is reported as 501 so clients see a clear configuration error.
+func selectBackend(settings config.EmbeddingsConfig) (embeddingBackend, error) {
+	provider := strings.ToLower(strings.TrimSpace(settings.Provider))
+	switch provider {
+	case "":
+		return nil, &backendError{Status: http.StatusNotImplemented, Message: "Embeddings provider is not configured. Set embeddings.provider in config.", Code: "embeddings_not_configured"}
+	case "mock", "deterministic", "builtin":
+		return deterministicBackend{}, nil
+	case config.EmbeddingsProviderOpenAI:
+		if settings.BaseURL == "" {
+			return nil, &backendError{Status: http.StatusNotImplemented, Message: "Embeddings provider 'openai' requires embeddings.base_url.", Code: "embeddings_not_configured"}
+		}
+		return openAICompatibleBackend{BaseURL: settings.BaseURL, APIKey: settings.APIKey, Model: settings.Model}, nil
+	default:
+		return nil, &backendError{Status: http.StatusNotImplemented, Message: fmt.Sprintf("Embeddings provider '%s' is not supported.", settings.Provider), Code: "embeddings_not_configured"}
+	}
+}
+
+func asBackendError(err error) *backendError {
+	var be *backendError
+	if errors.As(err, &be) {
+		return be
+	}
+	return &backendError{Status: http.StatusBadGateway, Message: "Embeddings backend request failed.", Code: "upstream_error"}
+}
+
+func truncateForLog(s string) string {
+	if cut, ok := util.TruncateUTF8Bytes(s, 512); ok {
+		return cut + "..."
+	}
+	return s
+}
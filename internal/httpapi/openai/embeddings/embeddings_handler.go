@@ -8,10 +8,11 @@ import (
 	"net/http"
 	"strings"
 
-	"ds2api/internal/auth"
 	"ds2api/internal/chathistory"
 	"ds2api/internal/config"
 	"ds2api/internal/httpapi/openai/shared"
+	"ds2api/internal/metrics"
+	"ds2api/internal/promptcompat"
 	"ds2api/internal/util"
 )
 
@@ -25,12 +26,7 @@ type Handler struct {
 func (h *Handler) Embeddings(w http.ResponseWriter, r *http.Request) {
 	a, err := h.Auth.Determine(r)
 	if err != nil {
-		status := http.StatusUnauthorized
-		detail := err.Error()
-		if err == auth.ErrNoAccount {
-			status = http.StatusTooManyRequests
-		}
-		shared.WriteOpenAIError(w, status, detail)
+		shared.WriteOpenAIAuthError(w, err)
 		return
 	}
 	defer h.Auth.Release(a)
@@ -38,11 +34,7 @@ func (h *Handler) Embeddings(w http.ResponseWriter, r *http.Request) {
 	r.Body = http.MaxBytesReader(w, r.Body, shared.GeneralMaxSize)
 	var req map[string]any
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		if strings.Contains(strings.ToLower(err.Error()), "too large") {
-			shared.WriteOpenAIError(w, http.StatusRequestEntityTooLarge, "request body too large")
-			return
-		}
-		shared.WriteOpenAIError(w, http.StatusBadRequest, "invalid json")
+		shared.WriteOpenAIDecodeError(w, err)
 		return
 	}
 	model, _ := req["model"].(string)
@@ -51,41 +43,65 @@ func (h *Handler) Embeddings(w http.ResponseWriter, r *http.Request) {
 		shared.WriteOpenAIError(w, http.StatusBadRequest, "Request must include 'model'.")
 		return
 	}
-	if _, ok := config.ResolveModel(h.Store, model); !ok {
-		shared.WriteOpenAIError(w, http.StatusBadRequest, fmt.Sprintf("Model '%s' is not available.", model))
+	resolvedModel, ok := config.ResolveRequestModel(h.Store, model)
+	if !ok {
+		shared.WriteOpenAIErrorWithCode(w, http.StatusNotFound, fmt.Sprintf("Model '%s' is not available.", model), "model_not_found")
 		return
 	}
+	if !a.AllowsModel(model, resolvedModel) {
+		shared.WriteOpenAIRequestError(w, &promptcompat.ModelNotAllowedError{Model: model})
+		return
+	}
+	metrics.SetModel(r.Context(), resolvedModel)
 
-	inputs := ExtractEmbeddingInputs(req["input"])
-	if len(inputs) == 0 {
-		shared.WriteOpenAIError(w, http.StatusBadRequest, "Request must include non-empty 'input'.")
+	inputs, err := parseEmbeddingInputs(req["input"])
+	if err != nil {
+		shared.WriteOpenAIRequestError(w, err)
 		return
 	}
 
-	provider := ""
+	settings := config.EmbeddingsConfig{}
 	if h.Store != nil {
-		provider = strings.ToLower(strings.TrimSpace(h.Store.EmbeddingsProvider()))
+		settings = h.Store.EmbeddingsSettings()
 	}
-	if provider == "" {
-		shared.WriteOpenAIError(w, http.StatusNotImplemented, "Embeddings provider is not configured. Set embeddings.provider in config.")
+	backend, err := selectBackend(settings)
+	if err != nil {
+		be := asBackendError(err)
+		shared.WriteOpenAIErrorWithCode(w, be.Status, be.Message, be.Code)
 		return
 	}
-	switch provider {
-	case "mock", "deterministic", "builtin":
-		// supported local deterministic provider
-	default:
-		shared.WriteOpenAIError(w, http.StatusNotImplemented, fmt.Sprintf("Embeddings provider '%s' is not supported.", provider))
+	if len(inputs) > settings.MaxInputs {
+		shared.WriteOpenAIError(w, http.StatusBadRequest, fmt.Sprintf("Too many inputs: %d exceeds the limit of %d per request.", len(inputs), settings.MaxInputs))
+		return
+	}
+	totalTokens := 0
+	for _, input := range inputs {
+		totalTokens += util.EstimateTokens(input.Text)
+	}
+	if totalTokens > settings.MaxTokens {
+		shared.WriteOpenAIError(w, http.StatusBadRequest, fmt.Sprintf("Input is too large: about %d tokens exceeds the limit of %d per request.", totalTokens, settings.MaxTokens))
 		return
 	}
 
+	opts := embeddingOptions{Model: model, Dimensions: req["dimensions"]}
+	opts.EncodingFormat, _ = req["encoding_format"].(string)
+	opts.User, _ = req["user"].(string)
+	result, err := backend.Embed(r.Context(), inputs, opts)
+	if err != nil {
+		be := asBackendError(err)
+		shared.WriteOpenAIErrorWithCode(w, be.Status, be.Message, be.Code)
+		return
+	}
+	if result.InputTokens > 0 {
+		totalTokens = result.InputTokens
+	}
+
 	data := make([]map[string]any, 0, len(inputs))
-	totalTokens := 0
-	for i, input := range inputs {
-		totalTokens += util.EstimateTokens(input)
+	for i, vector := range result.Vectors {
 		data = append(data, map[string]any{
 			"object":    "embedding",
 			"index":     i,
-			"embedding": DeterministicEmbedding(input),
+			"embedding": vector,
 		})
 	}
 	shared.WriteJSON(w, http.StatusOK, map[string]any{
@@ -99,37 +115,67 @@ func (h *Handler) Embeddings(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
+// ExtractEmbeddingInputs returns the text of each input item, or nil when the
+// input is missing or contains an empty item.
 func ExtractEmbeddingInputs(raw any) []string {
+	inputs, err := parseEmbeddingInputs(raw)
+	if err != nil {
+		return nil
+	}
+	out := make([]string, len(inputs))
+	for i, input := range inputs {
+		out[i] = input.Text
+	}
+	return out
+}
+
+// parseEmbeddingInputs accepts a string, an array of strings, a token array
+// or an array of token arrays. Empty items are rejected instead of dropped so
+// response indexes always line up with the request.
+func parseEmbeddingInputs(raw any) ([]embeddingInput, error) {
 	switch v := raw.(type) {
 	case string:
-		s := strings.TrimSpace(v)
-		if s == "" {
-			return nil
+		if strings.TrimSpace(v) == "" {
+			return nil, fmt.Errorf("Request must include non-empty 'input'.")
 		}
-		return []string{s}
+		return []embeddingInput{{Text: v, Raw: v}}, nil
 	case []any:
-		out := make([]string, 0, len(v))
-		for _, item := range v {
+		if len(v) == 0 {
+			return nil, fmt.Errorf("Request must include non-empty 'input'.")
+		}
+		if isTokenArray(v) {
+			return []embeddingInput{{Text: fmt.Sprintf("%v", v), Raw: v}}, nil
+		}
+		out := make([]embeddingInput, 0, len(v))
+		for i, item := range v {
 			switch iv := item.(type) {
 			case string:
-				s := strings.TrimSpace(iv)
-				if s != "" {
-					out = append(out, s)
+				if strings.TrimSpace(iv) == "" {
+					return nil, fmt.Errorf("'input[%d]' must not be empty.", i)
 				}
+				out = append(out, embeddingInput{Text: iv, Raw: iv})
 			case []any:
-				// Token array input support: convert to stable string form.
-				out = append(out, fmt.Sprintf("%v", iv))
-			default:
-				s := strings.TrimSpace(fmt.Sprintf("%v", iv))
-				if s != "" {
-					out = append(out, s)
+				if len(iv) == 0 || !isTokenArray(iv) {
+					return nil, fmt.Errorf("'input[%d]' must be a string or a non-empty token array.", i)
 				}
+				out = append(out, embeddingInput{Text: fmt.Sprintf("%v", iv), Raw: iv})
+			default:
+				return nil, fmt.Errorf("'input[%d]' must be a string or a token array.", i)
 			}
 		}
-		return out
+		return out, nil
 	default:
-		return nil
+		return nil, fmt.Errorf("Request must include non-empty 'input'.")
+	}
+}
+
+func isTokenArray(items []any) bool {
+	for _, item := range items {
+		if _, ok := item.(float64); !ok {
+			return false
+		}
 	}
+	return len(items) > 0
 }
 
 func DeterministicEmbedding(input string) []float64 {
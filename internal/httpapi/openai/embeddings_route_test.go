@@ -6,6 +6,7 @@ import (
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 
 	"github.com/go-chi/chi/v5"
@@ -94,3 +95,115 @@ func TestEmbeddingsRouteProviderMissing(t *testing.T) {
 		t.Fatalf("expected error.param in response: %#v", out)
 	}
 }
+
+func postEmbeddings(t *testing.T, r http.Handler, body string) (*httptest.ResponseRecorder, map[string]any) {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodPost, "/v1/embeddings", bytes.NewBufferString(body))
+	req.Header.Set("Authorization", "Bearer test-token")
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, req)
+	var out map[string]any
+	if err := json.Unmarshal(rec.Body.Bytes(), &out); err != nil {
+		t.Fatalf("decode response failed: %v body=%s", err, rec.Body.String())
+	}
+	return rec, out
+}
+
+func TestEmbeddingsRouteOpenAIBackendPreservesBatchOrder(t *testing.T) {
+	var got map[string]any
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/embeddings" || r.Header.Get("Authorization") != "Bearer sk-embed" {
+			t.Errorf("unexpected backend request %s auth=%q", r.URL.Path, r.Header.Get("Authorization"))
+		}
+		if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+			t.Errorf("decode backend request: %v", err)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"object":"list","data":[{"index":2,"embedding":[0.3]},{"index":0,"embedding":[0.1]},{"index":1,"embedding":[0.2]}],"usage":{"prompt_tokens":9,"total_tokens":9}}`))
+	}))
+	defer backend.Close()
+	store, resolver := newResolverWithConfigJSON(t, `{"embeddings":{"provider":"openai","base_url":"`+backend.URL+`/v1/","api_key":"sk-embed","model":"text-embedding-3-small"}}`)
+	r := chi.NewRouter()
+	registerOpenAITestRoutes(r, &openAITestSurface{Store: store, Auth: resolver})
+
+	rec, out := postEmbeddings(t, r, `{"model":"gpt-4o","input":["a","b","c"],"encoding_format":"float"}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d body=%s", rec.Code, rec.Body.String())
+	}
+	if got["model"] != "text-embedding-3-small" || got["encoding_format"] != "float" {
+		t.Fatalf("unexpected backend request body: %#v", got)
+	}
+	if inputs, _ := got["input"].([]any); len(inputs) != 3 || inputs[0] != "a" || inputs[2] != "c" {
+		t.Fatalf("expected inputs forwarded in order, got %#v", got["input"])
+	}
+	data, _ := out["data"].([]any)
+	if len(data) != 3 {
+		t.Fatalf("expected 3 embeddings, got %#v", out["data"])
+	}
+	for i, want := range []float64{0.1, 0.2, 0.3} {
+		item, _ := data[i].(map[string]any)
+		vec, _ := item["embedding"].([]any)
+		if item["index"] != float64(i) || len(vec) != 1 || vec[0] != want {
+			t.Fatalf("expected index %d to carry %v, got %#v", i, want, item)
+		}
+	}
+	usage, _ := out["usage"].(map[string]any)
+	if usage["prompt_tokens"] != float64(9) {
+		t.Fatalf("expected backend usage to be reported, got %#v", usage)
+	}
+}
+
+func TestEmbeddingsRouteOpenAIBackendFailureMapsTo502(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		http.Error(w, "boom", http.StatusInternalServerError)
+	}))
+	defer backend.Close()
+	store, resolver := newResolverWithConfigJSON(t, `{"embeddings":{"provider":"openai","base_url":"`+backend.URL+`"}}`)
+	r := chi.NewRouter()
+	registerOpenAITestRoutes(r, &openAITestSurface{Store: store, Auth: resolver})
+
+	rec, out := postEmbeddings(t, r, `{"model":"gpt-4o","input":"hello"}`)
+	if rec.Code != http.StatusBadGateway {
+		t.Fatalf("expected 502, got %d body=%s", rec.Code, rec.Body.String())
+	}
+	errObj, _ := out["error"].(map[string]any)
+	if errObj["code"] != "upstream_error" {
+		t.Fatalf("expected upstream_error code, got %#v", out)
+	}
+}
+
+func TestEmbeddingsRouteRejectsInvalidAndOversizedInput(t *testing.T) {
+	store, resolver := newResolverWithConfigJSON(t, `{"embeddings":{"provider":"deterministic","max_inputs":2,"max_tokens":5}}`)
+	r := chi.NewRouter()
+	registerOpenAITestRoutes(r, &openAITestSurface{Store: store, Auth: resolver})
+
+	cases := map[string]string{
+		"empty item":      `{"model":"gpt-4o","input":["a",""]}`,
+		"too many items":  `{"model":"gpt-4o","input":["a","b","c"]}`,
+		"too many tokens": `{"model":"gpt-4o","input":"` + strings.Repeat("word ", 50) + `"}`,
+	}
+	for name, body := range cases {
+		t.Run(name, func(t *testing.T) {
+			rec, _ := postEmbeddings(t, r, body)
+			if rec.Code != http.StatusBadRequest {
+				t.Fatalf("expected 400, got %d body=%s", rec.Code, rec.Body.String())
+			}
+		})
+	}
+}
+
+func TestEmbeddingsRouteUnsupportedProviderIs501(t *testing.T) {
+	store, resolver := newResolverWithConfigJSON(t, `{"embeddings":{"provider":"cohere"}}`)
+	r := chi.NewRouter()
+	registerOpenAITestRoutes(r, &openAITestSurface{Store: store, Auth: resolver})
+
+	rec, out := postEmbeddings(t, r, `{"model":"gpt-4o","input":"hello"}`)
+	if rec.Code != http.StatusNotImplemented {
+		t.Fatalf("expected 501, got %d body=%s", rec.Code, rec.Body.String())
+	}
+	errObj, _ := out["error"].(map[string]any)
+	if errObj["code"] != "embeddings_not_configured" {
+		t.Fatalf("expected embeddings_not_configured code, got %#v", out)
+	}
+}
@@ -14,6 +14,7 @@ import (
 
 	"ds2api/internal/auth"
 	dsclient "ds2api/internal/deepseek/client"
+	"ds2api/internal/httpapi/openai/files"
 )
 
 type inlineUploadDSStub struct {
@@ -325,3 +326,65 @@ func TestVercelPrepareUploadsInlineFilesBeforeLeasePayload(t *testing.T) {
 		t.Fatalf("unexpected payload ref_file_ids: %#v", payload["ref_file_ids"])
 	}
 }
+
+func TestPreprocessInlineFileInputsRejectsRemoteImageURLWhenFetchDisabled(t *testing.T) {
+	hits := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		hits++
+		w.Header().Set("Content-Type", "image/png")
+		_, _ = w.Write([]byte("\x89PNG\r\n\x1a\n0000"))
+	}))
+	defer srv.Close()
+
+	ds := &inlineUploadDSStub{}
+	h := &openAITestSurface{Store: mockOpenAIConfig{}, DS: ds}
+	block := map[string]any{"type": "image_url", "image_url": map[string]any{"url": srv.URL + "/cat.png"}}
+	req := map[string]any{
+		"messages": []any{map[string]any{"role": "user", "content": []any{block}}},
+	}
+	err := h.preprocessInlineFileInputs(context.Background(), &auth.RequestAuth{DeepSeekToken: "token"}, req)
+	if err == nil || !strings.Contains(err.Error(), "runtime.remote_image_fetch") {
+		t.Fatalf("expected an error naming runtime.remote_image_fetch, got %v", err)
+	}
+	rec := httptest.NewRecorder()
+	files.WriteInlineFileError(rec, err)
+	if rec.Code != http.StatusBadRequest || !strings.Contains(rec.Body.String(), "invalid_request_error") {
+		t.Fatalf("expected a 400 invalid_request_error, got %d %s", rec.Code, rec.Body.String())
+	}
+	if hits != 0 || len(ds.uploadCalls) != 0 {
+		t.Fatalf("expected no fetch or upload while remote_image_fetch is off, got hits=%d uploads=%d", hits, len(ds.uploadCalls))
+	}
+}
+
+func TestPreprocessInlineFileInputsRefusesPrivateRemoteImageAddresses(t *testing.T) {
+	hits := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		hits++
+		w.Header().Set("Content-Type", "image/png")
+		_, _ = w.Write([]byte("\x89PNG\r\n\x1a\n0000"))
+	}))
+	defer srv.Close()
+
+	for _, rawURL := range []string{srv.URL + "/cat.png", "http://169.254.169.254/latest/meta-data", "http://[::1]:9/x.png", "http://10.0.0.1/x.png"} {
+		ds := &inlineUploadDSStub{}
+		h := &openAITestSurface{Store: mockOpenAIConfig{remoteImageFetch: true}, DS: ds}
+		req := map[string]any{
+			"messages": []any{
+				map[string]any{
+					"role":    "user",
+					"content": []any{map[string]any{"type": "image_url", "image_url": rawURL}},
+				},
+			},
+		}
+		err := h.preprocessInlineFileInputs(context.Background(), &auth.RequestAuth{DeepSeekToken: "token"}, req)
+		if err == nil || !strings.Contains(err.Error(), "disallowed address") {
+			t.Fatalf("expected %s to be refused, got %v", rawURL, err)
+		}
+		if len(ds.uploadCalls) != 0 {
+			t.Fatalf("expected no upload for %s", rawURL)
+		}
+	}
+	if hits != 0 {
+		t.Fatalf("expected the loopback server never to be reached, got %d hits", hits)
+	}
+}
@@ -47,6 +47,9 @@ type inlineUploadState struct {
 	uploadedByID    map[string]string
 	uploadCount     int
 	inlineFileBytes int
+	// remoteImageFetch mirrors runtime.remote_image_fetch; remote image URLs
+	// are rejected when it is off.
+	remoteImageFetch bool
 }
 
 type inlineDecodedFile struct {
@@ -75,6 +78,9 @@ func (h *Handler) PreprocessInlineFileInputs(ctx context.Context, a *auth.Reques
 		modelType:    modelType,
 		uploadedByID: map[string]string{},
 	}
+	if h.Store != nil {
+		state.remoteImageFetch = h.Store.RuntimeRemoteImageFetch()
+	}
 	for _, key := range []string{"messages", "input", "attachments"} {
 		if raw, ok := req[key]; ok {
 			updated, err := state.walk(raw)
@@ -147,7 +153,22 @@ func (s *inlineUploadState) tryUploadBlock(block map[string]any) (map[string]any
 		return nil, true, &inlineFileUploadError{status: http.StatusBadRequest, message: err.Error(), err: err}
 	}
 	if !ok {
-		return nil, false, nil
+		rawURL, remote := extractRemoteImageURL(block)
+		if !remote {
+			return nil, false, nil
+		}
+		if !s.remoteImageFetch {
+			err := errRemoteImageFetchDisabled
+			return nil, true, &inlineFileUploadError{status: http.StatusBadRequest, message: err.Error(), err: err}
+		}
+		if s.uploadCount >= maxInlineFilesPerRequest {
+			err := fmt.Errorf("exceeded maximum of %d inline files per request", maxInlineFilesPerRequest)
+			return nil, true, &inlineFileUploadError{status: http.StatusBadRequest, message: err.Error(), err: err}
+		}
+		decoded, err = s.fetchRemoteImage(block, rawURL)
+		if err != nil {
+			return nil, true, &inlineFileUploadError{status: http.StatusBadRequest, message: err.Error(), err: err}
+		}
 	}
 	if s.uploadCount >= maxInlineFilesPerRequest {
 		err := fmt.Errorf("exceeded maximum of %d inline files per request", maxInlineFilesPerRequest)
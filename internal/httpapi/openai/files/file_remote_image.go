@@ -0,0 +1,201 @@
+package files
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"path"
+	"strings"
+	"syscall"
+	"time"
+
+	"ds2api/internal/config"
+	"ds2api/internal/httpapi/openai/shared"
+)
+
+const (
+	maxRemoteImageBytes     = 20 << 20
+	maxRemoteImageRedirects = 3
+)
+
+// remoteImageHTTPClient only connects to public addresses: the check runs on
+// the resolved IP of every connection, so DNS names and redirects cannot
+// reach loopback, private or metadata endpoints. Environment proxies are
+// ignored because the check would only see the proxy's address.
+var remoteImageHTTPClient = &http.Client{
+	Timeout: 30 * time.Second,
+	Transport: &http.Transport{
+		Proxy: nil,
+		DialContext: (&net.Dialer{
+			Timeout: 10 * time.Second,
+			Control: guardRemoteImageDial,
+		}).DialContext,
+		ForceAttemptHTTP2:   true,
+		MaxIdleConns:        10,
+		IdleConnTimeout:     90 * time.Second,
+		TLSHandshakeTimeout: 10 * time.Second,
+	},
+	CheckRedirect: checkRemoteImageRedirect,
+}
+
+var errRemoteImageAddress = errors.New("image url resolves to a disallowed address")
+
+// errRemoteImageFetchDisabled is returned for a remote image_url while
+// runtime.remote_image_fetch is off; DeepSeek cannot read the URL itself.
+var errRemoteImageFetchDisabled = errors.New("remote image urls are not fetched while runtime.remote_image_fetch is disabled; send the image as a data URL or enable runtime.remote_image_fetch")
+
+// remoteImageAddrAllowed is swapped in tests so a local server can stand in
+// for a public host.
+var remoteImageAddrAllowed = isPublicRemoteImageAddr
+
+var remoteImageBlockedPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),
+	netip.MustParsePrefix("100.64.0.0/10"),
+	netip.MustParsePrefix("192.0.0.0/24"),
+	netip.MustParsePrefix("198.18.0.0/15"),
+}
+
+func guardRemoteImageDial(_, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return errRemoteImageAddress
+	}
+	addr, err := netip.ParseAddr(host)
+	if err != nil || !remoteImageAddrAllowed(addr) {
+		return errRemoteImageAddress
+	}
+	return nil
+}
+
+func isPublicRemoteImageAddr(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	if !addr.IsValid() || addr.IsLoopback() || addr.IsPrivate() || addr.IsUnspecified() ||
+		addr.IsLinkLocalUnicast() || addr.IsLinkLocalMulticast() || addr.IsInterfaceLocalMulticast() || addr.IsMulticast() {
+		return false
+	}
+	for _, prefix := range remoteImageBlockedPrefixes {
+		if prefix.Contains(addr) {
+			return false
+		}
+	}
+	return true
+}
+
+func checkRemoteImageRedirect(req *http.Request, via []*http.Request) error {
+	if len(via) >= maxRemoteImageRedirects {
+		return fmt.Errorf("image url redirected more than %d times", maxRemoteImageRedirects)
+	}
+	if !isRemoteHTTPURL(req.URL.String()) {
+		return fmt.Errorf("image url redirected to a non-http(s) location")
+	}
+	return nil
+}
+
+// extractRemoteImageURL returns the http(s) URL of an image_url block so it can
+// be downloaded and uploaded like an inline data URL.
+func extractRemoteImageURL(block map[string]any) (string, bool) {
+	var raw string
+	switch x := block["image_url"].(type) {
+	case string:
+		raw = x
+	case map[string]any:
+		raw = shared.AsString(x["url"])
+	}
+	if strings.TrimSpace(raw) == "" && strings.Contains(strings.ToLower(shared.AsString(block["type"])), "image") {
+		raw = shared.AsString(block["url"])
+	}
+	raw = strings.TrimSpace(raw)
+	if !isRemoteHTTPURL(raw) {
+		return "", false
+	}
+	return raw, true
+}
+
+func isRemoteHTTPURL(raw string) bool {
+	u, err := url.Parse(strings.TrimSpace(raw))
+	if err != nil || u.Host == "" {
+		return false
+	}
+	scheme := strings.ToLower(u.Scheme)
+	return scheme == "http" || scheme == "https"
+}
+
+func (s *inlineUploadState) fetchRemoteImage(block map[string]any, rawURL string) (inlineDecodedFile, error) {
+	req, err := http.NewRequestWithContext(s.ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return inlineDecodedFile{}, fmt.Errorf("invalid image url")
+	}
+	resp, err := remoteImageHTTPClient.Do(req)
+	if err != nil {
+		if errors.Is(err, errRemoteImageAddress) {
+			return inlineDecodedFile{}, errRemoteImageAddress
+		}
+		return inlineDecodedFile{}, fmt.Errorf("failed to fetch image url")
+	}
+	defer func() {
+		if err := resp.Body.Close(); err != nil {
+			config.Logger.Warn("[inline_file] remote image body close failed", "error", err)
+		}
+	}()
+	if resp.StatusCode != http.StatusOK {
+		return inlineDecodedFile{}, fmt.Errorf("failed to fetch image url: upstream returned status %d", resp.StatusCode)
+	}
+	if resp.ContentLength > maxRemoteImageBytes {
+		return inlineDecodedFile{}, fmt.Errorf("image url exceeds maximum size of %d bytes", maxRemoteImageBytes)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxRemoteImageBytes+1))
+	if err != nil {
+		return inlineDecodedFile{}, fmt.Errorf("failed to fetch image url")
+	}
+	if len(data) > maxRemoteImageBytes {
+		return inlineDecodedFile{}, fmt.Errorf("image url exceeds maximum size of %d bytes", maxRemoteImageBytes)
+	}
+	if len(data) == 0 {
+		return inlineDecodedFile{}, fmt.Errorf("image url returned an empty body")
+	}
+	contentType := contentTypeFromMap(block)
+	if contentType == "" {
+		contentType = strings.TrimSpace(resp.Header.Get("Content-Type"))
+	}
+	if !strings.HasPrefix(strings.ToLower(contentType), "image/") {
+		contentType = http.DetectContentType(data)
+	}
+	if !strings.HasPrefix(strings.ToLower(contentType), "image/") {
+		return inlineDecodedFile{}, fmt.Errorf("image url did not return an image")
+	}
+	filename := remoteURLFilename(rawURL)
+	if filename == "" || hasInlineFilename(block) {
+		filename = pickInlineFilename(block, contentType, "image")
+	}
+	return inlineDecodedFile{
+		Data:            data,
+		ContentType:     contentType,
+		Filename:        filename,
+		ReplacementType: "input_image",
+	}, nil
+}
+
+func remoteURLFilename(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return ""
+	}
+	base := path.Base(u.Path)
+	if base == "." || base == "/" || !strings.Contains(base, ".") {
+		return ""
+	}
+	return base
+}
+
+func hasInlineFilename(block map[string]any) bool {
+	for _, value := range []any{block["filename"], block["file_name"], block["name"]} {
+		if strings.TrimSpace(shared.AsString(value)) != "" {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,110 @@
+package files
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"strings"
+	"testing"
+)
+
+func allowOnlyLocalTestServer(t *testing.T) {
+	t.Helper()
+	prev := remoteImageAddrAllowed
+	remoteImageAddrAllowed = func(addr netip.Addr) bool { return addr == netip.MustParseAddr("127.0.0.1") }
+	t.Cleanup(func() { remoteImageAddrAllowed = prev })
+}
+
+func TestFetchRemoteImageDownloadsImage(t *testing.T) {
+	allowOnlyLocalTestServer(t)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "image/png")
+		_, _ = w.Write([]byte("\x89PNG\r\n\x1a\n0000"))
+	}))
+	defer srv.Close()
+
+	s := &inlineUploadState{ctx: context.Background()}
+	got, err := s.fetchRemoteImage(map[string]any{}, srv.URL+"/cat.png")
+	if err != nil {
+		t.Fatalf("fetch failed: %v", err)
+	}
+	if got.ContentType != "image/png" || got.Filename != "cat.png" || got.ReplacementType != "input_image" {
+		t.Fatalf("unexpected decoded file: %#v", got)
+	}
+}
+
+func TestFetchRemoteImageRejectsOversizedOrNonImage(t *testing.T) {
+	allowOnlyLocalTestServer(t)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/big.png" {
+			w.Header().Set("Content-Type", "image/png")
+			_, _ = w.Write(make([]byte, maxRemoteImageBytes+1))
+			return
+		}
+		w.Header().Set("Content-Type", "text/html")
+		_, _ = w.Write([]byte("<html>not an image</html>"))
+	}))
+	defer srv.Close()
+
+	s := &inlineUploadState{ctx: context.Background()}
+	for path, want := range map[string]string{"/big.png": "exceeds maximum size", "/page": "did not return an image"} {
+		if _, err := s.fetchRemoteImage(map[string]any{}, srv.URL+path); err == nil || !strings.Contains(err.Error(), want) {
+			t.Fatalf("expected %q error for %s, got %v", want, path, err)
+		}
+	}
+}
+
+func TestFetchRemoteImageChecksEveryRedirectHop(t *testing.T) {
+	allowOnlyLocalTestServer(t)
+	hits := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits++
+		if r.URL.Path == "/private" {
+			// 127.0.0.2 is loopback too, but outside what the test allows.
+			http.Redirect(w, r, strings.Replace("http://"+r.Host, "127.0.0.1", "127.0.0.2", 1)+"/cat.png", http.StatusFound)
+			return
+		}
+		http.Redirect(w, r, "/loop", http.StatusFound)
+	}))
+	defer srv.Close()
+
+	s := &inlineUploadState{ctx: context.Background()}
+	if _, err := s.fetchRemoteImage(map[string]any{}, srv.URL+"/private"); err != errRemoteImageAddress {
+		t.Fatalf("expected the redirect target to be refused, got %v", err)
+	}
+	hits = 0
+	if _, err := s.fetchRemoteImage(map[string]any{}, srv.URL+"/loop"); err == nil {
+		t.Fatal("expected an endless redirect to fail")
+	}
+	if hits > maxRemoteImageRedirects {
+		t.Fatalf("expected at most %d requests, got %d", maxRemoteImageRedirects, hits)
+	}
+}
+
+func TestIsPublicRemoteImageAddr(t *testing.T) {
+	for raw, want := range map[string]bool{
+		"93.184.216.34":          true,
+		"2606:4700::1111":        true,
+		"127.0.0.1":              false,
+		"10.1.2.3":               false,
+		"172.16.0.1":             false,
+		"192.168.1.1":            false,
+		"169.254.169.254":        false,
+		"100.64.0.1":             false,
+		"198.18.0.1":             false,
+		"198.19.255.254":         false,
+		"192.0.0.8":              false,
+		"0.0.0.0":                false,
+		"224.0.0.1":              false,
+		"::1":                    false,
+		"fe80::1":                false,
+		"fd00::1":                false,
+		"::ffff:127.0.0.1":       false,
+		"::ffff:169.254.169.254": false,
+	} {
+		if got := isPublicRemoteImageAddr(netip.MustParseAddr(raw)); got != want {
+			t.Fatalf("isPublicRemoteImageAddr(%s)=%v want %v", raw, got, want)
+		}
+	}
+}
@@ -33,12 +33,7 @@ type fileFetcher interface {
 func (h *Handler) UploadFile(w http.ResponseWriter, r *http.Request) {
 	a, err := h.Auth.Determine(r)
 	if err != nil {
-		status := http.StatusUnauthorized
-		detail := err.Error()
-		if err == auth.ErrNoAccount {
-			status = http.StatusTooManyRequests
-		}
-		shared.WriteOpenAIError(w, status, detail)
+		shared.WriteOpenAIAuthError(w, err)
 		return
 	}
 	defer h.Auth.Release(a)
@@ -96,12 +91,7 @@ func (h *Handler) UploadFile(w http.ResponseWriter, r *http.Request) {
 func (h *Handler) RetrieveFile(w http.ResponseWriter, r *http.Request) {
 	a, err := h.Auth.Determine(r)
 	if err != nil {
-		status := http.StatusUnauthorized
-		detail := err.Error()
-		if err == auth.ErrNoAccount {
-			status = http.StatusTooManyRequests
-		}
-		shared.WriteOpenAIError(w, status, detail)
+		shared.WriteOpenAIAuthError(w, err)
 		return
 	}
 	defer h.Auth.Release(a)
@@ -3,6 +3,7 @@ package history
 import (
 	"net/http"
 
+	"ds2api/internal/config"
 	dsclient "ds2api/internal/deepseek/client"
 )
 
@@ -13,6 +14,7 @@ func MapError(err error) (int, string) {
 	case dsclient.IsDirectUnauthorizedError(err):
 		return http.StatusUnauthorized, "Invalid token. If this should be a DS2API key, add it to config.keys first."
 	default:
-		return http.StatusInternalServerError, err.Error()
+		config.Logger.Warn("[current_input_file] history upload failed", "error", err)
+		return http.StatusInternalServerError, "Failed to prepare conversation history."
 	}
 }
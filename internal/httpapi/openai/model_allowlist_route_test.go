@@ -0,0 +1,119 @@
+package openai
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"ds2api/internal/auth"
+	dsclient "ds2api/internal/deepseek/client"
+)
+
+type allowlistAuthStub struct {
+	models []string
+	err    error
+}
+
+func (a allowlistAuthStub) Determine(_ *http.Request) (*auth.RequestAuth, error) {
+	if a.err != nil {
+		return nil, a.err
+	}
+	return &auth.RequestAuth{
+		DeepSeekToken: "direct-token",
+		CallerID:      "caller:test",
+		AllowedModels: a.models,
+		TriedAccounts: map[string]bool{},
+	}, nil
+}
+
+func (a allowlistAuthStub) DetermineCaller(r *http.Request) (*auth.RequestAuth, error) {
+	return a.Determine(r)
+}
+
+func (allowlistAuthStub) Release(_ *auth.RequestAuth) {}
+
+// allowlistDSStub fails the test if the handler reaches the upstream.
+type allowlistDSStub struct {
+	t *testing.T
+}
+
+func (m allowlistDSStub) CreateSession(_ context.Context, _ *auth.RequestAuth, _ int) (string, error) {
+	m.t.Fatal("upstream session must not be created for a rejected request")
+	return "", nil
+}
+
+func (m allowlistDSStub) GetPow(_ context.Context, _ *auth.RequestAuth, _ int) (string, error) {
+	return "pow", nil
+}
+
+func (m allowlistDSStub) UploadFile(_ context.Context, _ *auth.RequestAuth, _ dsclient.UploadFileRequest, _ int) (*dsclient.UploadFileResult, error) {
+	return &dsclient.UploadFileResult{ID: "file-id"}, nil
+}
+
+func (m allowlistDSStub) CallCompletion(_ context.Context, _ *auth.RequestAuth, _ map[string]any, _ string, _ int) (*http.Response, error) {
+	m.t.Fatal("upstream completion must not be called for a rejected request")
+	return nil, nil
+}
+
+func (m allowlistDSStub) DeleteSessionForToken(_ context.Context, _ string, _ string) (*dsclient.DeleteSessionResult, error) {
+	return &dsclient.DeleteSessionResult{Success: true}, nil
+}
+
+func (m allowlistDSStub) DeleteAllSessionsForToken(_ context.Context, _ string) error {
+	return nil
+}
+
+func decodeOpenAIErrorBody(t *testing.T, rec *httptest.ResponseRecorder) map[string]any {
+	t.Helper()
+	var body map[string]any
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatalf("decode body: %v body=%s", err, rec.Body.String())
+	}
+	errObj, _ := body["error"].(map[string]any)
+	return errObj
+}
+
+func TestChatCompletionsRejectsModelOutsideKeyAllowlist(t *testing.T) {
+	h := &openAITestSurface{
+		Store: mockOpenAIConfig{},
+		Auth:  allowlistAuthStub{models: []string{"gpt-4o"}},
+		DS:    allowlistDSStub{t: t},
+	}
+	router := newOpenAITestRouter(h)
+
+	for _, path := range []string{"/v1/chat/completions", "/v1/responses"} {
+		body := `{"model":"deepseek-v4-pro","messages":[{"role":"user","content":"hi"}]}`
+		req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		if rec.Code != http.StatusForbidden {
+			t.Fatalf("%s: expected 403, got %d body=%s", path, rec.Code, rec.Body.String())
+		}
+		errObj := decodeOpenAIErrorBody(t, rec)
+		if errObj["code"] != "model_not_allowed" || errObj["type"] != "permission_error" {
+			t.Fatalf("%s: unexpected error: %#v", path, errObj)
+		}
+	}
+}
+
+func TestChatCompletionsMissingKeyReturnsInvalidAPIKey(t *testing.T) {
+	for _, authErr := range []error{auth.ErrUnauthorized, auth.ErrInvalidAPIKey} {
+		h := &openAITestSurface{
+			Store: mockOpenAIConfig{},
+			Auth:  allowlistAuthStub{err: authErr},
+			DS:    allowlistDSStub{t: t},
+		}
+		req := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", strings.NewReader(`{"model":"gpt-4o","messages":[{"role":"user","content":"hi"}]}`))
+		rec := httptest.NewRecorder()
+		newOpenAITestRouter(h).ServeHTTP(rec, req)
+		if rec.Code != http.StatusUnauthorized {
+			t.Fatalf("expected 401, got %d body=%s", rec.Code, rec.Body.String())
+		}
+		if errObj := decodeOpenAIErrorBody(t, rec); errObj["code"] != "invalid_api_key" {
+			t.Fatalf("unexpected error: %#v", errObj)
+		}
+	}
+}
@@ -1,6 +1,7 @@
 package openai
 
 import (
+	"encoding/json"
 	"net/http"
 	"net/http/httptest"
 	"testing"
@@ -80,3 +81,42 @@ func TestGetModelRouteNotFound(t *testing.T) {
 		t.Fatalf("expected 404, got %d body=%s", rec.Code, rec.Body.String())
 	}
 }
+
+func TestListModelsRouteEntriesResolveThroughGetModel(t *testing.T) {
+	h := &openAITestSurface{}
+	r := chi.NewRouter()
+	registerOpenAITestRoutes(r, h)
+
+	req := httptest.NewRequest(http.MethodGet, "/v1/models", nil)
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d body=%s", rec.Code, rec.Body.String())
+	}
+	var body struct {
+		Object string `json:"object"`
+		Data   []struct {
+			ID      string `json:"id"`
+			Object  string `json:"object"`
+			Created int64  `json:"created"`
+			OwnedBy string `json:"owned_by"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatalf("decode models list failed: %v body=%s", err, rec.Body.String())
+	}
+	if body.Object != "list" || len(body.Data) == 0 {
+		t.Fatalf("expected non-empty OpenAI list, got %s", rec.Body.String())
+	}
+	for _, model := range body.Data {
+		if model.Object != "model" || model.Created == 0 || model.OwnedBy == "" {
+			t.Fatalf("incomplete model entry %#v", model)
+		}
+		getReq := httptest.NewRequest(http.MethodGet, "/v1/models/"+model.ID, nil)
+		getRec := httptest.NewRecorder()
+		r.ServeHTTP(getRec, getReq)
+		if getRec.Code != http.StatusOK {
+			t.Fatalf("listed model %q is not routable, got %d body=%s", model.ID, getRec.Code, getRec.Body.String())
+		}
+	}
+}
@@ -10,8 +10,10 @@ import (
 	"ds2api/internal/completionruntime"
 	"ds2api/internal/config"
 	dsprotocol "ds2api/internal/deepseek/protocol"
+	"ds2api/internal/metrics"
 	"ds2api/internal/promptcompat"
 	"ds2api/internal/responsehistory"
+	"ds2api/internal/sse"
 	streamengine "ds2api/internal/stream"
 )
 
@@ -20,22 +22,29 @@ func (h *Handler) handleResponsesStreamWithRetry(w http.ResponseWriter, r *http.
 	if !ok {
 		return
 	}
+	streamRuntime.responseFormat = stdReq.ResponseFormat
+	streamRuntime.sieve.SetCallFormat(stdReq.ToolPrompt.Format)
+	streamRuntime.accumulator.Stop = sse.NewStopSequenceMatcher(stdReq.StopSequences)
+	streamRuntime.accumulator.Limit = sse.NewOutputTokenLimiter(stdReq.MaxOutputTokens, stdReq.ResponseModel)
+	streamRuntime.accumulator.Banned = sse.NewBannedWordFilter(stdReq.BannedWords)
 	completionruntime.ExecuteStreamWithRetry(r.Context(), h.DS, a, resp, payload, pow, completionruntime.StreamRetryOptions{
-		Surface:          "responses",
-		Stream:           true,
-		RetryEnabled:     emptyOutputRetryEnabled(),
-		RetryMaxAttempts: emptyOutputRetryMaxAttempts(),
-		MaxAttempts:      3,
-		UsagePrompt:      finalPrompt,
-		Request:          stdReq,
-		CurrentInputFile: h.Store,
+		Surface:                  "responses",
+		Stream:                   true,
+		RetryEnabled:             emptyOutputRetryEnabled(),
+		RetryMaxAttempts:         emptyOutputRetryMaxAttempts(),
+		MaxAttempts:              3,
+		UsagePrompt:              finalPrompt,
+		Request:                  stdReq,
+		CurrentInputFile:         h.Store,
+		UpstreamRetryMaxAttempts: h.upstreamRetryMaxAttempts(),
+		TraceID:                  requestTraceID(r),
 	}, completionruntime.StreamRetryHooks{
 		ConsumeAttempt: func(currentResp *http.Response, allowDeferEmpty bool) (bool, bool) {
 			return h.consumeResponsesStreamAttempt(r, currentResp, streamRuntime, initialType, thinkingEnabled, allowDeferEmpty)
 		},
 		Finalize: func(attempts int) {
 			streamRuntime.finalize("stop", false)
-			config.Logger.Info("[openai_empty_retry] terminal empty output", "surface", "responses", "stream", true, "retry_attempts", attempts, "success_source", "none", "error_code", streamRuntime.finalErrorCode)
+			config.Logger.InfoContext(r.Context(), "[openai_empty_retry] terminal empty output", "surface", "responses", "stream", true, "retry_attempts", attempts, "success_source", "none", "error_code", streamRuntime.finalErrorCode)
 		},
 		ParentMessageID: func() int {
 			return streamRuntime.responseMessageID
@@ -50,6 +59,7 @@ func (h *Handler) handleResponsesStreamWithRetry(w http.ResponseWriter, r *http.
 			logResponsesStreamTerminal(streamRuntime, attempts)
 		},
 	})
+	metrics.AddGeneratedTokens(r.Context(), streamRuntime.generatedTokens)
 }
 
 func (h *Handler) prepareResponsesStreamRuntime(w http.ResponseWriter, resp *http.Response, owner, responseID, model, finalPrompt string, refFileTokens int, thinkingEnabled, searchEnabled bool, toolNames []string, toolsRaw any, toolChoice promptcompat.ToolChoicePolicy, traceID string, historySession *responsehistory.Session) (*responsesStreamRuntime, string, bool) {
@@ -104,7 +114,7 @@ func (h *Handler) consumeResponsesStreamAttempt(r *http.Request, resp *http.Resp
 			}
 		},
 		OnContextDone: func() {
-			streamRuntime.markContextCancelled()
+			streamRuntime.markContextCancelled(r.Context())
 		},
 	})
 	if streamRuntime.finalErrorCode == string(streamengine.StopReasonContextCancelled) {
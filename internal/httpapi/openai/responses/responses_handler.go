@@ -26,7 +26,7 @@ import (
 func (h *Handler) GetResponseByID(w http.ResponseWriter, r *http.Request) {
 	a, err := h.Auth.DetermineCaller(r)
 	if err != nil {
-		writeOpenAIError(w, http.StatusUnauthorized, err.Error())
+		writeOpenAIAuthError(w, err)
 		return
 	}
 
@@ -52,12 +52,7 @@ func (h *Handler) GetResponseByID(w http.ResponseWriter, r *http.Request) {
 func (h *Handler) Responses(w http.ResponseWriter, r *http.Request) {
 	a, err := h.Auth.Determine(r)
 	if err != nil {
-		status := http.StatusUnauthorized
-		detail := err.Error()
-		if err == auth.ErrNoAccount {
-			status = http.StatusTooManyRequests
-		}
-		writeOpenAIError(w, status, detail)
+		writeOpenAIAuthError(w, err)
 		return
 	}
 	defer h.Auth.Release(a)
@@ -71,11 +66,11 @@ func (h *Handler) Responses(w http.ResponseWriter, r *http.Request) {
 	r.Body = http.MaxBytesReader(w, r.Body, openAIGeneralMaxSize)
 	var req map[string]any
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		if strings.Contains(strings.ToLower(err.Error()), "too large") {
-			writeOpenAIError(w, http.StatusRequestEntityTooLarge, "request body too large")
-			return
-		}
-		writeOpenAIError(w, http.StatusBadRequest, "invalid json")
+		writeOpenAIDecodeError(w, err)
+		return
+	}
+	if err := h.checkModelAllowed(a, req); err != nil {
+		writeOpenAIRequestError(w, err)
 		return
 	}
 	if err := h.preprocessInlineFileInputs(r.Context(), a, req); err != nil {
@@ -85,7 +80,12 @@ func (h *Handler) Responses(w http.ResponseWriter, r *http.Request) {
 	traceID := requestTraceID(r)
 	stdReq, err := promptcompat.NormalizeOpenAIResponsesRequest(h.Store, req, traceID)
 	if err != nil {
-		writeOpenAIError(w, http.StatusBadRequest, err.Error())
+		writeOpenAIRequestError(w, err)
+		return
+	}
+	stdReq, err = h.applyContextWindow(w, stdReq, traceID)
+	if err != nil {
+		writeOpenAIRequestError(w, err)
 		return
 	}
 	stdReq, err = h.applyCurrentInputFile(r.Context(), a, stdReq)
@@ -105,8 +105,10 @@ func (h *Handler) Responses(w http.ResponseWriter, r *http.Request) {
 	})
 	if !stdReq.Stream {
 		result, outErr := completionruntime.ExecuteNonStreamWithRetry(r.Context(), h.DS, a, stdReq, completionruntime.Options{
-			RetryEnabled:     true,
-			CurrentInputFile: h.Store,
+			RetryEnabled:             true,
+			UpstreamRetryMaxAttempts: h.upstreamRetryMaxAttempts(),
+			TraceID:                  requestTraceID(r),
+			CurrentInputFile:         h.Store,
 		})
 		if outErr != nil {
 			if historySession != nil {
@@ -120,13 +122,18 @@ func (h *Handler) Responses(w http.ResponseWriter, r *http.Request) {
 		}
 		responseObj := openaifmt.BuildResponseObjectWithToolCalls(responseID, stdReq.ResponseModel, result.Turn.Prompt, result.Turn.Thinking, result.Turn.Text, result.Turn.ToolCalls, stdReq.ToolsRaw)
 		responseObj["usage"] = assistantturn.OpenAIResponsesUsage(result.Turn)
+		if result.Turn.StopReason == assistantturn.StopReasonLength {
+			openaifmt.MarkResponseIncomplete(responseObj, "max_output_tokens")
+		}
 		h.getResponseStore().put(owner, responseID, responseObj)
 		writeJSON(w, http.StatusOK, responseObj)
 		return
 	}
 
 	start, outErr := completionruntime.StartCompletion(r.Context(), h.DS, a, stdReq, completionruntime.Options{
-		CurrentInputFile: h.Store,
+		UpstreamRetryMaxAttempts: h.upstreamRetryMaxAttempts(),
+		TraceID:                  requestTraceID(r),
+		CurrentInputFile:         h.Store,
 	})
 	if outErr != nil {
 		if historySession != nil {
@@ -1,6 +1,7 @@
 package responses
 
 import (
+	"context"
 	"ds2api/internal/assistantturn"
 	"ds2api/internal/toolcall"
 	"net/http"
@@ -9,6 +10,7 @@ import (
 	"ds2api/internal/config"
 	openaifmt "ds2api/internal/format/openai"
 	"ds2api/internal/httpapi/openai/shared"
+	"ds2api/internal/httpapi/requestctx"
 	"ds2api/internal/promptcompat"
 	"ds2api/internal/responsehistory"
 	"ds2api/internal/sse"
@@ -29,6 +31,8 @@ type responsesStreamRuntime struct {
 	toolsRaw      any
 	traceID       string
 	toolChoice    promptcompat.ToolChoicePolicy
+	// responseFormat is checked against the streamed text once it ends.
+	responseFormat promptcompat.ResponseFormat
 
 	thinkingEnabled       bool
 	searchEnabled         bool
@@ -60,6 +64,7 @@ type responsesStreamRuntime struct {
 	finalErrorStatus  int
 	finalErrorMessage string
 	finalErrorCode    string
+	generatedTokens   int
 
 	persistResponse func(obj map[string]any)
 	history         *responsehistory.Session
@@ -145,14 +150,24 @@ func (s *responsesStreamRuntime) failResponse(status int, message, code string)
 	if s.history != nil {
 		s.history.Error(status, message, code, responsehistory.ThinkingForArchive(s.accumulator.RawThinking.String(), s.accumulator.ToolDetectionThinking.String(), s.accumulator.Thinking.String()), responsehistory.TextForArchive(s.accumulator.RawText.String(), s.accumulator.Text.String()))
 	}
-	s.sendEvent("response.failed", openaifmt.BuildResponsesFailedPayload(s.responseID, s.model, status, message, code))
+	s.sendEvent("response.failed", requestctx.WithTraceID(s.w, openaifmt.BuildResponsesFailedPayload(s.responseID, s.model, status, message, code)))
 	s.sendDone()
 }
 
-func (s *responsesStreamRuntime) markContextCancelled() {
+// markContextCancelled records why the stream stopped early; see the chat
+// runtime for why only an expired deadline or a shutdown still writes a
+// terminal event.
+func (s *responsesStreamRuntime) markContextCancelled(ctx context.Context) {
+	switch requestctx.CancelReason(ctx) {
+	case requestctx.ReasonDeadlineExceeded:
+		s.failResponse(http.StatusGatewayTimeout, requestctx.TimeoutMessage, requestctx.CodeRequestTimeout)
+	case requestctx.ReasonServerShutdown:
+		s.failResponse(http.StatusServiceUnavailable, requestctx.ShutdownMessage, requestctx.CodeServerShutdown)
+	default:
+		s.finalErrorStatus = 499
+		s.finalErrorMessage = "request context cancelled"
+	}
 	s.failed = true
-	s.finalErrorStatus = 499
-	s.finalErrorMessage = "request context cancelled"
 	s.finalErrorCode = string(streamengine.StopReasonContextCancelled)
 }
 
@@ -161,6 +176,7 @@ func (s *responsesStreamRuntime) finalize(finishReason string, deferEmptyOutput
 	s.finalErrorStatus = 0
 	s.finalErrorMessage = ""
 	s.finalErrorCode = ""
+	s.emitAccumulatedParts(s.accumulator.FlushStopHold().Parts)
 	if s.bufferToolContent {
 		s.processToolStreamEvents(toolstream.Flush(&s.sieve, s.toolNames), true, true)
 	}
@@ -178,6 +194,7 @@ func (s *responsesStreamRuntime) finalize(finishReason string, deferEmptyOutput
 		ResponseMessageID:     s.responseMessageID,
 		AlreadyEmittedCalls:   s.toolCallsEmitted,
 		AlreadyEmittedToolRaw: s.toolCallsDoneEmitted,
+		OutputLimitReached:    s.accumulator.OutputLimitReached(),
 	}, assistantturn.BuildOptions{
 		Model:                 s.model,
 		Prompt:                s.finalPrompt,
@@ -187,6 +204,8 @@ func (s *responsesStreamRuntime) finalize(finishReason string, deferEmptyOutput
 		ToolNames:             s.toolNames,
 		ToolsRaw:              s.toolsRaw,
 		ToolChoice:            s.toolChoice,
+		ToolCallFormat:        s.sieve.CallFormat(),
+		ResponseFormat:        s.responseFormat,
 	})
 	textParsed := turn.ParsedToolCalls
 	detected := turn.ToolCalls
@@ -206,7 +225,9 @@ func (s *responsesStreamRuntime) finalize(finishReason string, deferEmptyOutput
 	})
 	if outcome.ShouldFail {
 		status, message, code := outcome.Error.Status, outcome.Error.Message, outcome.Error.Code
-		if deferEmptyOutput {
+		// JSON-mode failures follow text the client already has, so they
+		// are reported now rather than retried.
+		if deferEmptyOutput && !assistantturn.IsResponseFormatError(outcome.Error) {
 			s.finalErrorStatus = status
 			s.finalErrorMessage = message
 			s.finalErrorCode = code
@@ -218,9 +239,14 @@ func (s *responsesStreamRuntime) finalize(finishReason string, deferEmptyOutput
 	s.closeIncompleteFunctionItems()
 
 	obj := s.buildCompletedResponseObject(turn.Thinking, turn.Text, detected)
+	incomplete := outcome.FinishReason == "length"
+	if incomplete {
+		openaifmt.MarkResponseIncomplete(obj, "max_output_tokens")
+	}
 	if s.persistResponse != nil {
 		s.persistResponse(obj)
 	}
+	s.generatedTokens = turn.Usage.OutputTokens
 	if s.history != nil {
 		s.history.Success(
 			http.StatusOK,
@@ -230,7 +256,11 @@ func (s *responsesStreamRuntime) finalize(finishReason string, deferEmptyOutput
 			assistantturn.OpenAIResponsesUsage(turn),
 		)
 	}
-	s.sendEvent("response.completed", openaifmt.BuildResponsesCompletedPayload(obj))
+	if incomplete {
+		s.sendEvent("response.incomplete", openaifmt.BuildResponsesIncompletePayload(obj))
+	} else {
+		s.sendEvent("response.completed", openaifmt.BuildResponsesCompletedPayload(obj))
+	}
 	s.sendDone()
 	return true
 }
@@ -266,9 +296,23 @@ func (s *responsesStreamRuntime) onParsed(parsed sse.LineResult) streamengine.Pa
 		return streamengine.ParsedDecision{Stop: true}
 	}
 
-	batch := responsesDeltaBatch{runtime: s}
 	accumulated := s.accumulator.Apply(parsed)
-	for _, p := range accumulated.Parts {
+	s.emitAccumulatedParts(accumulated.Parts)
+	if s.history != nil {
+		s.history.Progress(
+			responsehistory.ThinkingForArchive(s.accumulator.RawThinking.String(), s.accumulator.ToolDetectionThinking.String(), s.accumulator.Thinking.String()),
+			responsehistory.TextForArchive(s.accumulator.RawText.String(), s.accumulator.Text.String()),
+		)
+	}
+	if s.accumulator.StopSequenceMatched() || s.accumulator.OutputLimitReached() {
+		return streamengine.ParsedDecision{ContentSeen: accumulated.ContentSeen, Stop: true}
+	}
+	return streamengine.ParsedDecision{ContentSeen: accumulated.ContentSeen}
+}
+
+func (s *responsesStreamRuntime) emitAccumulatedParts(parts []shared.StreamPartDelta) {
+	batch := responsesDeltaBatch{runtime: s}
+	for _, p := range parts {
 		if p.Type == "thinking" {
 			batch.append("reasoning", p.VisibleText)
 			continue
@@ -288,11 +332,4 @@ func (s *responsesStreamRuntime) onParsed(parsed sse.LineResult) streamengine.Pa
 	}
 
 	batch.flush()
-	if s.history != nil {
-		s.history.Progress(
-			responsehistory.ThinkingForArchive(s.accumulator.RawThinking.String(), s.accumulator.ToolDetectionThinking.String(), s.accumulator.Thinking.String()),
-			responsehistory.TextForArchive(s.accumulator.RawText.String(), s.accumulator.Text.String()),
-		)
-	}
-	return streamengine.ParsedDecision{ContentSeen: accumulated.ContentSeen}
 }
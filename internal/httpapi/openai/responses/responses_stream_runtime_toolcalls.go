@@ -3,10 +3,10 @@ package responses
 import (
 	"ds2api/internal/toolcall"
 	"ds2api/internal/toolstream"
-	"encoding/json"
 	"strings"
 
 	openaifmt "ds2api/internal/format/openai"
+	"ds2api/internal/httpapi/openai/shared"
 
 	"github.com/google/uuid"
 )
@@ -219,12 +219,17 @@ func (s *responsesStreamRuntime) emitFunctionCallDeltaEvents(deltas []toolstream
 	}
 }
 
+// emitFunctionCallDoneEvents closes each call under the output item its name
+// was announced with. Arguments already streamed as deltas are completed
+// with one more delta, so the deltas and the done event agree.
 func (s *responsesStreamRuntime) emitFunctionCallDoneEvents(calls []toolcall.ParsedToolCall) {
 	normalizedCalls := toolcall.NormalizeParsedToolCallsForSchemas(calls, s.toolsRaw)
-	for idx, tc := range normalizedCalls {
+	indexes := shared.StreamToolCallIndexes(normalizedCalls, s.functionNames)
+	for i, tc := range normalizedCalls {
 		if strings.TrimSpace(tc.Name) == "" {
 			continue
 		}
+		idx := indexes[i]
 		s.ensureFunctionItemAdded(idx, tc.Name)
 		if s.functionDone[idx] {
 			continue
@@ -232,8 +237,15 @@ func (s *responsesStreamRuntime) emitFunctionCallDoneEvents(calls []toolcall.Par
 		outputIndex := s.ensureFunctionOutputIndex(idx)
 		itemID := s.ensureFunctionItemID(idx)
 		callID := s.ensureToolCallID(idx)
-		argsBytes, _ := json.Marshal(tc.Input)
-		args := string(argsBytes)
+		args := s.functionArgs[idx]
+		rest := shared.RemainingStreamToolCallArguments(args, tc.Input)
+		if args != "" {
+			s.sendEvent(
+				"response.function_call_arguments.delta",
+				openaifmt.BuildResponsesFunctionCallArgumentsDeltaPayload(s.responseID, itemID, outputIndex, callID, rest),
+			)
+		}
+		args += rest
 		s.functionArgs[idx] = args
 		s.sendEvent(
 			"response.function_call_arguments.done",
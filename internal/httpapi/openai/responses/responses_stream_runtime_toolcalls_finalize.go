@@ -7,6 +7,7 @@ import (
 	"strings"
 
 	openaifmt "ds2api/internal/format/openai"
+	"ds2api/internal/httpapi/openai/shared"
 )
 
 func (s *responsesStreamRuntime) closeIncompleteFunctionItems() {
@@ -30,7 +31,11 @@ func (s *responsesStreamRuntime) closeIncompleteFunctionItems() {
 			continue
 		}
 		args := strings.TrimSpace(s.functionArgs[idx])
-		if args == "" {
+		if args != "" && !json.Valid([]byte(args)) {
+			// Streamed fragments leave the object open.
+			args += "}"
+		}
+		if !json.Valid([]byte(args)) {
 			args = "{}"
 		}
 		outputIndex := s.ensureFunctionOutputIndex(idx)
@@ -126,11 +131,17 @@ func (s *responsesStreamRuntime) buildCompletedResponseObject(finalThinking, fin
 	}
 
 	normalizedCalls := toolcall.NormalizeParsedToolCallsForSchemas(calls, s.toolsRaw)
-	for idx, tc := range normalizedCalls {
+	indexes := shared.StreamToolCallIndexes(normalizedCalls, s.functionNames)
+	for i, tc := range normalizedCalls {
 		if strings.TrimSpace(tc.Name) == "" {
 			continue
 		}
-		argsBytes, _ := json.Marshal(tc.Input)
+		idx := indexes[i]
+		args := s.functionArgs[idx]
+		if !json.Valid([]byte(args)) {
+			argsBytes, _ := json.Marshal(tc.Input)
+			args = string(argsBytes)
+		}
 		indexed = append(indexed, indexedItem{
 			index: s.ensureFunctionOutputIndex(idx),
 			item: map[string]any{
@@ -138,7 +149,7 @@ func (s *responsesStreamRuntime) buildCompletedResponseObject(finalThinking, fin
 				"type":      "function_call",
 				"call_id":   s.ensureToolCallID(idx),
 				"name":      tc.Name,
-				"arguments": string(argsBytes),
+				"arguments": args,
 				"status":    "completed",
 			},
 		})
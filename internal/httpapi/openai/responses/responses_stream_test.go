@@ -621,3 +621,48 @@ func extractSSEEventPayloads(body, targetEvent string) []map[string]any {
 	}
 	return out
 }
+
+func TestHandleResponsesStreamArgumentDeltasMatchDoneArguments(t *testing.T) {
+	h := &Handler{}
+	req := httptest.NewRequest(http.MethodPost, "/v1/responses", nil)
+	rec := httptest.NewRecorder()
+
+	sseLine := func(v string) string {
+		b, _ := json.Marshal(map[string]any{
+			"p": "response/content",
+			"v": v,
+		})
+		return "data: " + string(b) + "\n"
+	}
+
+	streamBody := sseLine("<tool_calls>\n  <invoke name=\"read_file\">\n") +
+		sseLine("    <parameter name=\"path\">README.MD</parameter>\n") +
+		sseLine("  </invoke>\n</tool_calls>") +
+		"data: [DONE]\n"
+	resp := &http.Response{
+		StatusCode: http.StatusOK,
+		Body:       io.NopCloser(strings.NewReader(streamBody)),
+	}
+
+	h.handleResponsesStream(rec, req, resp, "owner-a", "resp_test", "deepseek-v4-flash", "prompt", 0, false, false, []string{"read_file"}, nil, promptcompat.DefaultToolChoicePolicy(), "")
+
+	body := rec.Body.String()
+	deltas := extractSSEEventPayloads(body, "response.function_call_arguments.delta")
+	if len(deltas) < 2 {
+		t.Fatalf("expected streamed fragment plus closing delta, got %d body=%s", len(deltas), body)
+	}
+	var args strings.Builder
+	for _, payload := range deltas {
+		args.WriteString(asString(payload["delta"]))
+	}
+	done, ok := extractSSEEventPayload(body, "response.function_call_arguments.done")
+	if !ok {
+		t.Fatalf("expected done event, body=%s", body)
+	}
+	if asString(done["arguments"]) != args.String() {
+		t.Fatalf("expected deltas %q to add up to done arguments %q", args.String(), asString(done["arguments"]))
+	}
+	if args.String() != `{"path":"README.MD"}` {
+		t.Fatalf("unexpected arguments %q", args.String())
+	}
+}
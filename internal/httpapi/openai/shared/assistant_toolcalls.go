@@ -6,8 +6,10 @@ import (
 	"ds2api/internal/toolcall"
 )
 
-func DetectAssistantToolCalls(rawText, visibleText, exposedThinking, detectionThinking string, toolNames []string) toolcall.ToolCallParseResult {
-	textParsed := toolcall.ParseStandaloneToolCallsDetailed(rawText, toolNames)
+// DetectAssistantToolCalls parses the turn's tool calls with format, the call
+// format the request captured when it started.
+func DetectAssistantToolCalls(rawText, visibleText, exposedThinking, detectionThinking string, toolNames []string, format string) toolcall.ToolCallParseResult {
+	textParsed := toolcall.ParseStandaloneToolCallsDetailedForFormat(rawText, toolNames, format)
 	if len(textParsed.Calls) > 0 {
 		return textParsed
 	}
@@ -18,7 +20,7 @@ func DetectAssistantToolCalls(rawText, visibleText, exposedThinking, detectionTh
 	if strings.TrimSpace(thinking) == "" {
 		thinking = exposedThinking
 	}
-	thinkingParsed := toolcall.ParseStandaloneToolCallsDetailed(thinking, toolNames)
+	thinkingParsed := toolcall.ParseStandaloneToolCallsDetailedForFormat(thinking, toolNames, format)
 	if len(thinkingParsed.Calls) > 0 {
 		return thinkingParsed
 	}
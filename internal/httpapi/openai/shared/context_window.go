@@ -0,0 +1,31 @@
+package shared
+
+import (
+	"net/http"
+	"strconv"
+
+	"ds2api/internal/promptcompat"
+)
+
+// ContextTrimmedHeader tells clients how many of the oldest messages were
+// removed to fit runtime.context_max_tokens.
+const ContextTrimmedHeader = "X-Ds2api-Context-Trimmed"
+
+// ApplyContextWindow trims the oldest messages of an over-budget conversation
+// and reports the count in ContextTrimmedHeader. It returns a
+// *promptcompat.ContextWindowExceededError when the kept messages alone do
+// not fit.
+func ApplyContextWindow(w http.ResponseWriter, store ConfigReader, stdReq promptcompat.StandardRequest, traceID string) (promptcompat.StandardRequest, error) {
+	if store == nil {
+		return stdReq, nil
+	}
+	trimmer := promptcompat.ContextTrimmerFor(store.RuntimeContextTrimStrategy())
+	out, err := promptcompat.ApplyContextWindow(stdReq, store.RuntimeContextMaxTokens(), trimmer, traceID)
+	if err != nil {
+		return stdReq, err
+	}
+	if out.ContextTrimmedMessages > 0 && w != nil {
+		w.Header().Set(ContextTrimmedHeader, strconv.Itoa(out.ContextTrimmedMessages))
+	}
+	return out, nil
+}
@@ -35,16 +35,27 @@ type DeepSeekCaller interface {
 
 type ConfigReader interface {
 	ModelAliases() map[string]string
+	ModelDefaultTarget() string
+	ModelResponseMode() string
+	ModelFallbacks(requested string) []string
+	FallbackBackend(name string) (config.APIBackendConfig, bool)
 	ToolcallMode() string
 	ToolcallEarlyEmitConfidence() string
 	ResponsesStoreTTLSeconds() int
 	EmbeddingsProvider() string
+	EmbeddingsSettings() config.EmbeddingsConfig
 	AutoDeleteMode() string
 	AutoDeleteSessions() bool
 	CurrentInputFileEnabled() bool
 	CurrentInputFileMinChars() int
 	ThinkingInjectionEnabled() bool
 	ThinkingInjectionPrompt() string
+	RuntimeUpstreamRetryMaxAttempts() int
+	RuntimeMaxCompletionChoices() int
+	RuntimeContextMaxTokens() int
+	RuntimeContextTrimStrategy() string
+	RuntimeStrictSamplingParams() bool
+	RuntimeRemoteImageFetch() bool
 }
 
 type Deps struct {
@@ -21,12 +21,19 @@ func ClonePayloadWithEmptyOutputRetryPrompt(payload map[string]any) map[string]a
 // retry is submitted as a proper follow-up turn in the same DeepSeek
 // session rather than a disconnected root message.
 func ClonePayloadForEmptyOutputRetry(payload map[string]any, parentMessageID int) map[string]any {
+	return ClonePayloadWithRetrySuffix(payload, parentMessageID, EmptyOutputRetrySuffix)
+}
+
+// ClonePayloadWithRetrySuffix is the generic form of
+// ClonePayloadForEmptyOutputRetry for retries that need a different
+// follow-up instruction.
+func ClonePayloadWithRetrySuffix(payload map[string]any, parentMessageID int, suffix string) map[string]any {
 	clone := make(map[string]any, len(payload))
 	for k, v := range payload {
 		clone[k] = v
 	}
 	original, _ := payload["prompt"].(string)
-	clone["prompt"] = AppendEmptyOutputRetrySuffix(original)
+	clone["prompt"] = AppendRetrySuffix(original, suffix)
 	if parentMessageID > 0 {
 		clone["parent_message_id"] = parentMessageID
 	}
@@ -34,11 +41,15 @@ func ClonePayloadForEmptyOutputRetry(payload map[string]any, parentMessageID int
 }
 
 func AppendEmptyOutputRetrySuffix(prompt string) string {
+	return AppendRetrySuffix(prompt, EmptyOutputRetrySuffix)
+}
+
+func AppendRetrySuffix(prompt, suffix string) string {
 	prompt = strings.TrimRight(prompt, "\r\n\t ")
 	if prompt == "" {
-		return EmptyOutputRetrySuffix
+		return suffix
 	}
-	return prompt + "\n\n" + EmptyOutputRetrySuffix
+	return prompt + "\n\n" + suffix
 }
 
 func UsagePromptWithEmptyOutputRetry(originalPrompt string, retryAttempts int) string {
@@ -54,3 +65,21 @@ func UsagePromptWithEmptyOutputRetry(originalPrompt string, retryAttempts int) s
 	}
 	return strings.Join(parts, "\n")
 }
+
+// UpstreamRetryMaxAttempts reads the configured transient-upstream retry cap,
+// returning 0 (runtime default) when no config is wired.
+func UpstreamRetryMaxAttempts(store ConfigReader) int {
+	if store == nil {
+		return 0
+	}
+	return store.RuntimeUpstreamRetryMaxAttempts()
+}
+
+// MaxCompletionChoices reads the configured cap on the chat `n` parameter,
+// allowing a single choice when no config is wired.
+func MaxCompletionChoices(store ConfigReader) int {
+	if store == nil {
+		return 1
+	}
+	return store.RuntimeMaxCompletionChoices()
+}
@@ -0,0 +1,188 @@
+package shared
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"ds2api/internal/auth"
+	"ds2api/internal/config"
+	"ds2api/internal/httpapi/requestbody"
+	"ds2api/internal/httpapi/requestctx"
+	"ds2api/internal/promptcompat"
+)
+
+// ErrorCategory is the adapter-level class of a failure. MapOpenAIError turns
+// each category into the HTTP status, `type` and default `code` of the OpenAI
+// error envelope.
+type ErrorCategory string
+
+const (
+	ErrorCategoryBadRequest ErrorCategory = "bad_request"
+	ErrorCategoryAuth       ErrorCategory = "auth"
+	ErrorCategoryPermission ErrorCategory = "permission"
+	ErrorCategoryNotFound   ErrorCategory = "not_found"
+	ErrorCategoryRateLimit  ErrorCategory = "rate_limit"
+	ErrorCategoryUpstream   ErrorCategory = "upstream"
+	ErrorCategoryTimeout    ErrorCategory = "timeout"
+	ErrorCategoryParse      ErrorCategory = "parse"
+	ErrorCategoryInternal   ErrorCategory = "internal"
+)
+
+// internalErrorMessage replaces unclassified error text so raw Go errors are
+// logged instead of returned to clients.
+const internalErrorMessage = "Internal server error."
+
+const (
+	authFailedMessage  = "Authentication failed."
+	invalidJSONMessage = "invalid json"
+)
+
+var categoryStatus = map[ErrorCategory]int{
+	ErrorCategoryBadRequest: http.StatusBadRequest,
+	ErrorCategoryAuth:       http.StatusUnauthorized,
+	ErrorCategoryPermission: http.StatusForbidden,
+	ErrorCategoryNotFound:   http.StatusNotFound,
+	ErrorCategoryRateLimit:  http.StatusTooManyRequests,
+	ErrorCategoryUpstream:   http.StatusBadGateway,
+	ErrorCategoryTimeout:    http.StatusGatewayTimeout,
+	ErrorCategoryParse:      http.StatusBadGateway,
+	ErrorCategoryInternal:   http.StatusInternalServerError,
+}
+
+var categoryCode = map[ErrorCategory]string{
+	ErrorCategoryUpstream: "upstream_error",
+	ErrorCategoryTimeout:  requestctx.CodeRequestTimeout,
+	ErrorCategoryParse:    "upstream_parse_error",
+}
+
+// CategorizedError tags an error with its category and the client-facing
+// message. Code is optional; the category default is used when empty.
+type CategorizedError struct {
+	Category ErrorCategory
+	Message  string
+	Code     string
+	Err      error
+}
+
+func (e *CategorizedError) Error() string {
+	if e.Err != nil {
+		return e.Message + ": " + e.Err.Error()
+	}
+	return e.Message
+}
+
+func (e *CategorizedError) Unwrap() error { return e.Err }
+
+// NewCategorizedError wraps err under category with a client-facing message.
+func NewCategorizedError(category ErrorCategory, message string, err error) *CategorizedError {
+	return &CategorizedError{Category: category, Message: message, Err: err}
+}
+
+// OpenAIErrorDetail is one mapped error: the HTTP status plus the fields of
+// `{"error":{"message","type","param","code"}}`.
+type OpenAIErrorDetail struct {
+	Status  int
+	Type    string
+	Message string
+	Code    string
+	// Param names the offending request field, when there is one.
+	Param string
+}
+
+// MapOpenAIError classifies err into the OpenAI error envelope. Errors that
+// match no category become a 500 api_error with a generic message; the raw
+// error is logged rather than returned.
+func MapOpenAIError(err error) OpenAIErrorDetail {
+	if detail, ok := mapKnownOpenAIError(err); ok {
+		return detail
+	}
+	config.Logger.Error("[openai_error] unclassified error", "error", err)
+	return newOpenAIErrorDetail(http.StatusInternalServerError, internalErrorMessage, "")
+}
+
+// WriteOpenAIMappedError writes err through MapOpenAIError.
+func WriteOpenAIMappedError(w http.ResponseWriter, err error) {
+	writeOpenAIErrorDetail(w, MapOpenAIError(err))
+}
+
+func mapKnownOpenAIError(err error) (OpenAIErrorDetail, bool) {
+	if err == nil {
+		return OpenAIErrorDetail{}, false
+	}
+	var categorized *CategorizedError
+	if errors.As(err, &categorized) {
+		status, ok := categoryStatus[categorized.Category]
+		if !ok {
+			status = http.StatusInternalServerError
+		}
+		code := categorized.Code
+		if code == "" {
+			code = categoryCode[categorized.Category]
+		}
+		message := categorized.Message
+		if message == "" {
+			message = internalErrorMessage
+		}
+		return newOpenAIErrorDetail(status, message, code), true
+	}
+	var notFound *promptcompat.ModelNotFoundError
+	if errors.As(err, &notFound) {
+		return newOpenAIErrorDetail(http.StatusNotFound, err.Error(), "model_not_found"), true
+	}
+	var overWindow *promptcompat.ContextWindowExceededError
+	if errors.As(err, &overWindow) {
+		return newOpenAIErrorDetail(http.StatusBadRequest, err.Error(), "context_length_exceeded"), true
+	}
+	var unsupported *promptcompat.UnsupportedParameterError
+	if errors.As(err, &unsupported) {
+		detail := newOpenAIErrorDetail(http.StatusBadRequest, err.Error(), "unsupported_parameter")
+		detail.Param = unsupported.Param
+		return detail, true
+	}
+	var notAllowed *promptcompat.ModelNotAllowedError
+	if errors.As(err, &notAllowed) {
+		return newOpenAIErrorDetail(http.StatusForbidden, err.Error(), "model_not_allowed"), true
+	}
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	switch {
+	case errors.Is(err, auth.ErrNoAccount):
+		return newOpenAIErrorDetail(http.StatusTooManyRequests, err.Error(), ""), true
+	case errors.Is(err, auth.ErrUnauthorized), errors.Is(err, auth.ErrInvalidAPIKey):
+		return newOpenAIErrorDetail(http.StatusUnauthorized, err.Error(), "invalid_api_key"), true
+	case errors.Is(err, context.DeadlineExceeded):
+		return newOpenAIErrorDetail(http.StatusGatewayTimeout, requestctx.TimeoutMessage, requestctx.CodeRequestTimeout), true
+	case requestbody.IsTooLarge(err):
+		return newOpenAIErrorDetail(http.StatusRequestEntityTooLarge, "request body too large", ""), true
+	case errors.As(err, &syntaxErr), errors.As(err, &typeErr), errors.Is(err, requestbody.ErrInvalidUTF8Body):
+		return newOpenAIErrorDetail(http.StatusBadRequest, invalidJSONMessage, "invalid_json"), true
+	}
+	return OpenAIErrorDetail{}, false
+}
+
+func newOpenAIErrorDetail(status int, message, code string) OpenAIErrorDetail {
+	if code == "" {
+		code = OpenAIErrorCode(status)
+	}
+	return OpenAIErrorDetail{Status: status, Type: OpenAIErrorType(status), Message: message, Code: code}
+}
+
+func writeOpenAIErrorDetail(w http.ResponseWriter, detail OpenAIErrorDetail) {
+	WriteJSON(w, detail.Status, requestctx.WithTraceID(w, map[string]any{
+		"error": map[string]any{
+			"message": detail.Message,
+			"type":    detail.Type,
+			"code":    detail.Code,
+			"param":   errorParam(detail.Param),
+		},
+	}))
+}
+
+func errorParam(param string) any {
+	if param == "" {
+		return nil
+	}
+	return param
+}
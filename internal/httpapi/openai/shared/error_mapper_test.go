@@ -0,0 +1,120 @@
+package shared
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"ds2api/internal/auth"
+	"ds2api/internal/promptcompat"
+)
+
+func TestMapOpenAIErrorCategories(t *testing.T) {
+	cases := []struct {
+		err    error
+		status int
+		typ    string
+		code   string
+	}{
+		{NewCategorizedError(ErrorCategoryBadRequest, "bad", nil), http.StatusBadRequest, "invalid_request_error", "invalid_request"},
+		{auth.ErrInvalidAPIKey, http.StatusUnauthorized, "authentication_error", "invalid_api_key"},
+		{fmt.Errorf("wrapped: %w", auth.ErrNoAccount), http.StatusTooManyRequests, "rate_limit_error", "rate_limit_exceeded"},
+		{&promptcompat.ModelNotFoundError{Model: "x"}, http.StatusNotFound, "invalid_request_error", "model_not_found"},
+		{NewCategorizedError(ErrorCategoryUpstream, "upstream down", errors.New("dial tcp")), http.StatusBadGateway, "api_error", "upstream_error"},
+		{context.DeadlineExceeded, http.StatusGatewayTimeout, "api_error", "request_timeout"},
+		{NewCategorizedError(ErrorCategoryParse, "bad upstream payload", nil), http.StatusBadGateway, "api_error", "upstream_parse_error"},
+		{&json.SyntaxError{}, http.StatusBadRequest, "invalid_request_error", "invalid_json"},
+		{&promptcompat.UnsupportedParameterError{Param: "logprobs", Reason: "x"}, http.StatusBadRequest, "invalid_request_error", "unsupported_parameter"},
+		{errors.New("open /secret/path: permission denied"), http.StatusInternalServerError, "api_error", "internal_error"},
+	}
+	for _, tc := range cases {
+		got := MapOpenAIError(tc.err)
+		if got.Status != tc.status || got.Type != tc.typ || got.Code != tc.code {
+			t.Fatalf("MapOpenAIError(%v) = %#v, want status=%d type=%s code=%s", tc.err, got, tc.status, tc.typ, tc.code)
+		}
+	}
+	if got := MapOpenAIError(errors.New("open /secret/path: permission denied")); strings.Contains(got.Message, "secret") {
+		t.Fatalf("expected unclassified error text to stay out of the response, got %q", got.Message)
+	}
+}
+
+func TestWriteOpenAIErrorWithCodeReplacesGenericCode(t *testing.T) {
+	rec := httptest.NewRecorder()
+	WriteOpenAIErrorWithCode(rec, http.StatusUnauthorized, "Invalid token.", "error")
+	var body map[string]map[string]any
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatalf("decode body: %v", err)
+	}
+	errObj := body["error"]
+	if errObj["code"] != "authentication_failed" || errObj["type"] != "authentication_error" {
+		t.Fatalf("unexpected error envelope: %#v", errObj)
+	}
+	if _, ok := errObj["param"]; !ok {
+		t.Fatalf("expected param key in envelope: %#v", errObj)
+	}
+}
+
+func TestOpenAIErrorWriters(t *testing.T) {
+	cases := []struct {
+		name    string
+		write   func(http.ResponseWriter, error)
+		err     error
+		status  int
+		code    string
+		message string
+	}{
+		{"auth key", WriteOpenAIAuthError, auth.ErrInvalidAPIKey, http.StatusUnauthorized, "invalid_api_key", auth.ErrInvalidAPIKey.Error()},
+		{"auth login", WriteOpenAIAuthError, errors.New("login acct@example.com: bad password"), http.StatusUnauthorized, "authentication_failed", "Authentication failed."},
+		{"request", WriteOpenAIRequestError, errors.New("messages is required"), http.StatusBadRequest, "invalid_request", "messages is required"},
+		{"decode empty", WriteOpenAIDecodeError, io.EOF, http.StatusBadRequest, "invalid_json", "invalid json"},
+		{"decode truncated", WriteOpenAIDecodeError, io.ErrUnexpectedEOF, http.StatusBadRequest, "invalid_json", "invalid json"},
+	}
+	for _, tc := range cases {
+		rec := httptest.NewRecorder()
+		tc.write(rec, tc.err)
+		var body map[string]map[string]any
+		if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+			t.Fatalf("%s: decode body: %v", tc.name, err)
+		}
+		if rec.Code != tc.status || body["error"]["code"] != tc.code || body["error"]["message"] != tc.message {
+			t.Fatalf("%s: got %d %s", tc.name, rec.Code, rec.Body.String())
+		}
+	}
+}
+
+func TestRecoverPanicsWritesAPIError(t *testing.T) {
+	h := RecoverPanics(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {
+		panic("boom")
+	}))
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/v1/chat/completions", nil))
+	if rec.Code != http.StatusInternalServerError {
+		t.Fatalf("expected 500, got %d", rec.Code)
+	}
+	var body map[string]map[string]any
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatalf("decode body: %v body=%s", err, rec.Body.String())
+	}
+	if body["error"]["type"] != "api_error" || strings.Contains(rec.Body.String(), "boom") {
+		t.Fatalf("unexpected panic response: %s", rec.Body.String())
+	}
+}
+
+func TestRecoverPanicsLeavesStartedResponseAlone(t *testing.T) {
+	h := RecoverPanics(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		_, _ = w.Write([]byte("data: {}\n\n"))
+		panic("boom")
+	}))
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/v1/chat/completions", nil))
+	if rec.Code != http.StatusOK || rec.Body.String() != "data: {}\n\n" {
+		t.Fatalf("expected the started stream to be left untouched, got %d %q", rec.Code, rec.Body.String())
+	}
+}
@@ -1,23 +1,82 @@
 package shared
 
-import "net/http"
+import (
+	"net/http"
+	"strings"
+
+	"ds2api/internal/auth"
+	"ds2api/internal/config"
+	"ds2api/internal/httpapi/requestctx"
+	"ds2api/internal/promptcompat"
+)
 
 func WriteOpenAIError(w http.ResponseWriter, status int, message string) {
 	WriteOpenAIErrorWithCode(w, status, message, "")
 }
 
+// WriteOpenAIErrorWithCode writes the OpenAI error envelope. An empty code,
+// or the generic "error" used by internal runtime errors, is replaced with the
+// default code for status.
 func WriteOpenAIErrorWithCode(w http.ResponseWriter, status int, message, code string) {
-	if code == "" {
-		code = OpenAIErrorCode(status)
+	if code == "error" {
+		code = ""
+	}
+	writeOpenAIErrorDetail(w, newOpenAIErrorDetail(status, message, code))
+}
+
+// WriteOpenAIAuthError writes a caller authentication failure. Missing or
+// rejected keys get OpenAI's 401 invalid_api_key; an exhausted account pool
+// is a 429. Any other failure, such as a managed account that cannot log in,
+// is a 401 with a generic message and the cause is logged.
+func WriteOpenAIAuthError(w http.ResponseWriter, err error) {
+	if _, ok := mapKnownOpenAIError(err); !ok {
+		config.Logger.Warn("[openai_error] authentication failed", "error", err)
+		err = NewCategorizedError(ErrorCategoryAuth, authFailedMessage, err)
+	}
+	WriteOpenAIMappedError(w, err)
+}
+
+// WriteOpenAIRequestError writes a request normalization failure. Unknown
+// models get OpenAI's 404 model_not_found, models outside the key allowlist
+// a 403 model_not_allowed; everything else is a 400 carrying the
+// normalizer's message, which is written for the caller.
+func WriteOpenAIRequestError(w http.ResponseWriter, err error) {
+	if _, ok := mapKnownOpenAIError(err); !ok {
+		err = NewCategorizedError(ErrorCategoryBadRequest, err.Error(), err)
+	}
+	WriteOpenAIMappedError(w, err)
+}
+
+// WriteOpenAIDecodeError writes a failure to decode the JSON request body:
+// a 413 for an oversized body and a 400 invalid_json for everything else,
+// including an empty or truncated body.
+func WriteOpenAIDecodeError(w http.ResponseWriter, err error) {
+	if _, ok := mapKnownOpenAIError(err); !ok {
+		err = &CategorizedError{Category: ErrorCategoryBadRequest, Message: invalidJSONMessage, Code: "invalid_json", Err: err}
+	}
+	WriteOpenAIMappedError(w, err)
+}
+
+// CheckRequestModelAllowed enforces the caller key's model allowlist on the
+// raw request `model` before normalization builds the prompt. Unresolvable
+// models pass through so normalization reports model_not_found.
+func CheckRequestModelAllowed(store ConfigReader, a *auth.RequestAuth, req map[string]any) error {
+	if a == nil || len(a.AllowedModels) == 0 {
+		return nil
+	}
+	model, _ := req["model"].(string)
+	model = strings.TrimSpace(model)
+	if model == "" {
+		return nil
+	}
+	resolved, ok := config.ResolveRequestModel(store, model)
+	if !ok {
+		return nil
+	}
+	if !a.AllowsModel(model, resolved) {
+		return &promptcompat.ModelNotAllowedError{Model: model}
 	}
-	WriteJSON(w, status, map[string]any{
-		"error": map[string]any{
-			"message": message,
-			"type":    OpenAIErrorType(status),
-			"code":    code,
-			"param":   nil,
-		},
-	})
+	return nil
 }
 
 func OpenAIErrorType(status int) string {
@@ -54,6 +113,10 @@ func OpenAIErrorCode(status int) string {
 		return "not_found"
 	case http.StatusServiceUnavailable:
 		return "service_unavailable"
+	case http.StatusBadGateway:
+		return "upstream_error"
+	case http.StatusGatewayTimeout:
+		return requestctx.CodeRequestTimeout
 	default:
 		if status >= 500 {
 			return "internal_error"
@@ -1,8 +1,10 @@
 package shared
 
 import (
+	"bytes"
 	"ds2api/internal/toolcall"
 	"encoding/json"
+	"sort"
 	"strings"
 
 	"github.com/google/uuid"
@@ -10,6 +12,9 @@ import (
 	"ds2api/internal/toolstream"
 )
 
+// FormatIncrementalStreamToolCallDeltas renders early tool_calls deltas. The
+// first delta of an index carries id/type; later ones only carry what is new,
+// because OpenAI stream accumulators concatenate every string field per index.
 func FormatIncrementalStreamToolCallDeltas(deltas []toolstream.ToolCallDelta, ids map[int]string) []map[string]any {
 	if len(deltas) == 0 {
 		return nil
@@ -19,15 +24,12 @@ func FormatIncrementalStreamToolCallDeltas(deltas []toolstream.ToolCallDelta, id
 		if d.Name == "" && d.Arguments == "" {
 			continue
 		}
-		callID, ok := ids[d.Index]
-		if !ok || callID == "" {
+		item := map[string]any{"index": d.Index}
+		if callID := ids[d.Index]; callID == "" {
 			callID = "call_" + strings.ReplaceAll(uuid.NewString(), "-", "")
 			ids[d.Index] = callID
-		}
-		item := map[string]any{
-			"index": d.Index,
-			"id":    callID,
-			"type":  "function",
+			item["id"] = callID
+			item["type"] = "function"
 		}
 		fn := map[string]any{}
 		if d.Name != "" {
@@ -71,25 +73,44 @@ func FilterIncrementalToolCallDeltasByAllowed(deltas []toolstream.ToolCallDelta,
 }
 
 func FormatFinalStreamToolCallsWithStableIDs(calls []toolcall.ParsedToolCall, ids map[int]string, toolsRaw any) []map[string]any {
+	return FormatFinalStreamToolCallsAfterDeltas(calls, ids, nil, nil, toolsRaw)
+}
+
+// FormatFinalStreamToolCallsAfterDeltas renders the closing tool_calls frame.
+// announced and sentArgs hold the names and argument fragments incremental
+// deltas already sent per index. Calls keep the index their name was
+// announced under (see StreamToolCallIndexes); those indexes only carry the
+// rest of their arguments, because OpenAI stream accumulators concatenate
+// every string field per index and would otherwise duplicate the name.
+func FormatFinalStreamToolCallsAfterDeltas(calls []toolcall.ParsedToolCall, ids map[int]string, announced map[int]string, sentArgs map[int]string, toolsRaw any) []map[string]any {
 	if len(calls) == 0 {
 		return nil
 	}
 	normalizedCalls := toolcall.NormalizeParsedToolCallsForSchemas(calls, toolsRaw)
+	indexes := StreamToolCallIndexes(normalizedCalls, announced)
 	out := make([]map[string]any, 0, len(calls))
 	for i, c := range normalizedCalls {
+		idx := indexes[i]
 		callID := ""
 		if ids != nil {
-			callID = strings.TrimSpace(ids[i])
+			callID = strings.TrimSpace(ids[idx])
 		}
 		if callID == "" {
 			callID = "call_" + strings.ReplaceAll(uuid.NewString(), "-", "")
 			if ids != nil {
-				ids[i] = callID
+				ids[idx] = callID
 			}
 		}
+		if strings.TrimSpace(announced[idx]) != "" {
+			out = append(out, map[string]any{
+				"index":    idx,
+				"function": map[string]any{"arguments": RemainingStreamToolCallArguments(sentArgs[idx], c.Input)},
+			})
+			continue
+		}
 		args, _ := json.Marshal(c.Input)
 		out = append(out, map[string]any{
-			"index": i,
+			"index": idx,
 			"id":    callID,
 			"type":  "function",
 			"function": map[string]any{
@@ -100,3 +121,85 @@ func FormatFinalStreamToolCallsWithStableIDs(calls []toolcall.ParsedToolCall, id
 	}
 	return out
 }
+
+// StreamToolCallIndexes maps final calls onto the stream indexes their names
+// were announced under, so a call the final parse dropped or filtered never
+// shifts the ones after it. Each call takes the lowest unused index announced
+// with its name; calls that were never announced get fresh indexes after the
+// announced ones. Announced indexes left unmatched receive no further deltas.
+func StreamToolCallIndexes(calls []toolcall.ParsedToolCall, announced map[int]string) []int {
+	slots := make([]int, 0, len(announced))
+	next := 0
+	for idx, name := range announced {
+		if strings.TrimSpace(name) == "" {
+			continue
+		}
+		slots = append(slots, idx)
+		if idx >= next {
+			next = idx + 1
+		}
+	}
+	sort.Ints(slots)
+	used := map[int]bool{}
+	out := make([]int, len(calls))
+	for i, c := range calls {
+		out[i] = -1
+		for _, idx := range slots {
+			if !used[idx] && strings.TrimSpace(announced[idx]) == strings.TrimSpace(c.Name) {
+				out[i] = idx
+				used[idx] = true
+				break
+			}
+		}
+		if out[i] < 0 {
+			out[i] = next
+			next++
+		}
+	}
+	return out
+}
+
+// RemainingStreamToolCallArguments returns what has to follow the argument
+// fragments already streamed for a call so the concatenation is a JSON object
+// equal to input. Keys streamed with the final value are not repeated; keys
+// whose final value differs (for example after schema normalization) are sent
+// again, and JSON decoders keep the last occurrence. With nothing streamed it
+// returns the whole object.
+func RemainingStreamToolCallArguments(sent string, input map[string]any) string {
+	if strings.TrimSpace(sent) == "" {
+		args, _ := json.Marshal(input)
+		return string(args)
+	}
+	streamed := map[string]json.RawMessage{}
+	_ = json.Unmarshal([]byte(sent+"}"), &streamed)
+	keys := make([]string, 0, len(input))
+	for key := range input {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	var b strings.Builder
+	for _, key := range keys {
+		value, err := json.Marshal(input[key])
+		if err != nil {
+			continue
+		}
+		if prev, ok := streamed[key]; ok && bytes.Equal(compactJSON(prev), value) {
+			continue
+		}
+		name, _ := json.Marshal(key)
+		b.WriteByte(',')
+		b.Write(name)
+		b.WriteByte(':')
+		b.Write(value)
+	}
+	b.WriteByte('}')
+	return b.String()
+}
+
+func compactJSON(raw json.RawMessage) []byte {
+	var b bytes.Buffer
+	if err := json.Compact(&b, raw); err != nil {
+		return raw
+	}
+	return b.Bytes()
+}
@@ -0,0 +1,42 @@
+package shared
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"ds2api/internal/toolcall"
+)
+
+func TestRemainingStreamToolCallArgumentsResendsChangedKeys(t *testing.T) {
+	input := map[string]any{"path": "README.MD", "limit": "10", "mode": "fast"}
+	sent := `{"path":"README.MD","limit":10`
+
+	rest := RemainingStreamToolCallArguments(sent, input)
+	if rest != `,"limit":"10","mode":"fast"}` {
+		t.Fatalf("unexpected remainder %q", rest)
+	}
+	var decoded map[string]any
+	if err := json.Unmarshal([]byte(sent+rest), &decoded); err != nil {
+		t.Fatalf("expected valid JSON, got %q: %v", sent+rest, err)
+	}
+	if !reflect.DeepEqual(decoded, input) {
+		t.Fatalf("expected the last occurrence to win, got %#v", decoded)
+	}
+	if got := RemainingStreamToolCallArguments("", input); got != `{"limit":"10","mode":"fast","path":"README.MD"}` {
+		t.Fatalf("expected the whole object when nothing was streamed, got %q", got)
+	}
+}
+
+func TestStreamToolCallIndexesFollowsAnnouncedNames(t *testing.T) {
+	calls := []toolcall.ParsedToolCall{{Name: "search"}, {Name: "write_file"}, {Name: "read_file"}}
+	announced := map[int]string{0: "read_file", 1: "broken", 2: "search"}
+
+	got := StreamToolCallIndexes(calls, announced)
+	if want := []int{2, 3, 0}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("expected %v, got %v", want, got)
+	}
+	if got := StreamToolCallIndexes(calls, nil); !reflect.DeepEqual(got, []int{0, 1, 2}) {
+		t.Fatalf("expected positional indexes without announcements, got %v", got)
+	}
+}
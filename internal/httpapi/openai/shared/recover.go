@@ -0,0 +1,35 @@
+package shared
+
+import (
+	"net/http"
+	"runtime/debug"
+
+	"github.com/go-chi/chi/v5/middleware"
+
+	"ds2api/internal/config"
+)
+
+// RecoverPanics turns a handler panic into a 500 api_error in the OpenAI
+// envelope. The panic and stack are logged with the trace ID. When the
+// handler had already started the response, e.g. mid-stream, nothing more is
+// written and the connection is left to close.
+func RecoverPanics(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+		defer func() {
+			rec := recover()
+			if rec == nil {
+				return
+			}
+			if rec == http.ErrAbortHandler {
+				panic(rec)
+			}
+			config.Logger.Error("[panic] handler panicked", "trace_id", middleware.GetReqID(r.Context()), "method", r.Method, "path", r.URL.Path, "panic", rec, "stack", string(debug.Stack()))
+			if ww.Status() != 0 {
+				return
+			}
+			WriteOpenAIErrorWithCode(ww, http.StatusInternalServerError, internalErrorMessage, "")
+		}()
+		next.ServeHTTP(ww, r)
+	})
+}
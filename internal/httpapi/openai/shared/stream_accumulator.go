@@ -10,6 +10,13 @@ type StreamAccumulator struct {
 	ThinkingEnabled       bool
 	SearchEnabled         bool
 	StripReferenceMarkers bool
+	// Stop, when set, truncates text parts at the first OpenAI `stop`
+	// string and withholds possible partial matches between chunks.
+	Stop *sse.StopSequenceMatcher
+	// Limit, when set, cuts text parts once the max_tokens budget is spent.
+	Limit *sse.OutputTokenLimiter
+	// Banned, when set, drops `logit_bias` banned words from text parts.
+	Banned *sse.BannedWordFilter
 
 	RawThinking           strings.Builder
 	Thinking              strings.Builder
@@ -82,8 +89,49 @@ func (a *StreamAccumulator) applyThinkingPart(text string) StreamPartDelta {
 	return delta
 }
 
+// StopSequenceMatched reports whether the text stream hit a `stop` string;
+// stream runtimes stop reading upstream once it returns true.
+func (a *StreamAccumulator) StopSequenceMatched() bool {
+	return a.Stop.Matched()
+}
+
+// OutputLimitReached reports whether text hit the max_tokens budget; stream
+// runtimes stop reading upstream and finish with "length" once it is true.
+func (a *StreamAccumulator) OutputLimitReached() bool {
+	return a.Limit.Reached()
+}
+
+// FlushStopHold releases text withheld as a possible stop-string prefix or
+// banned-word fragment. Call it once at end of stream before building the
+// final turn.
+func (a *StreamAccumulator) FlushStopHold() StreamAccumulatorResult {
+	out := StreamAccumulatorResult{}
+	tail, _ := a.Limit.Push(a.Banned.Push(a.Stop.Flush()) + a.Banned.Flush())
+	delta := a.writeTextPart(tail)
+	if delta.RawText != "" {
+		out.ContentSeen = true
+		out.Parts = append(out.Parts, delta)
+	}
+	return out
+}
+
 func (a *StreamAccumulator) applyTextPart(text string) StreamPartDelta {
-	rawTrimmed := sse.TrimContinuationOverlapFromBuilder(&a.RawText, text)
+	if a.Stop.Matched() || a.Limit.Reached() {
+		return StreamPartDelta{Type: "text"}
+	}
+	var rawTrimmed string
+	if held := a.Banned.Held() + a.Stop.Held(); held != "" {
+		rawTrimmed = sse.TrimContinuationOverlap(a.RawText.String()+held, text)
+	} else {
+		rawTrimmed = sse.TrimContinuationOverlapFromBuilder(&a.RawText, text)
+	}
+	rawTrimmed, _ = a.Stop.Push(rawTrimmed)
+	rawTrimmed = a.Banned.Push(rawTrimmed)
+	rawTrimmed, _ = a.Limit.Push(rawTrimmed)
+	return a.writeTextPart(rawTrimmed)
+}
+
+func (a *StreamAccumulator) writeTextPart(rawTrimmed string) StreamPartDelta {
 	if rawTrimmed == "" {
 		return StreamPartDelta{Type: "text"}
 	}
@@ -0,0 +1,109 @@
+package openai
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"ds2api/internal/auth"
+	dsclient "ds2api/internal/deepseek/client"
+)
+
+// slowUpstreamDSStub streams its SSE lines through a pipe, holding each
+// line back until the test releases it, so a buffered server would stall.
+type slowUpstreamDSStub struct {
+	body *io.PipeReader
+}
+
+func (m slowUpstreamDSStub) CreateSession(_ context.Context, _ *auth.RequestAuth, _ int) (string, error) {
+	return "session-id", nil
+}
+
+func (m slowUpstreamDSStub) GetPow(_ context.Context, _ *auth.RequestAuth, _ int) (string, error) {
+	return "pow", nil
+}
+
+func (m slowUpstreamDSStub) UploadFile(_ context.Context, _ *auth.RequestAuth, _ dsclient.UploadFileRequest, _ int) (*dsclient.UploadFileResult, error) {
+	return &dsclient.UploadFileResult{ID: "file-id"}, nil
+}
+
+func (m slowUpstreamDSStub) CallCompletion(_ context.Context, _ *auth.RequestAuth, _ map[string]any, _ string, _ int) (*http.Response, error) {
+	return &http.Response{StatusCode: http.StatusOK, Header: make(http.Header), Body: m.body}, nil
+}
+
+func (m slowUpstreamDSStub) DeleteSessionForToken(_ context.Context, _ string, _ string) (*dsclient.DeleteSessionResult, error) {
+	return &dsclient.DeleteSessionResult{Success: true}, nil
+}
+
+func (m slowUpstreamDSStub) DeleteAllSessionsForToken(_ context.Context, _ string) error {
+	return nil
+}
+
+func TestStreamingDeliversChunksIncrementally(t *testing.T) {
+	cases := []struct {
+		name, path, body string
+	}{
+		{"chat", "/v1/chat/completions", `{"model":"deepseek-v4-flash","messages":[{"role":"user","content":"hi"}],"stream":true,"stream_options":{"include_usage":true}}`},
+		{"responses", "/v1/responses", `{"model":"deepseek-v4-flash","input":"hi","stream":true}`},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			upstreamR, upstreamW := io.Pipe()
+			h := &openAITestSurface{Store: mockOpenAIConfig{}, Auth: streamStatusAuthStub{}, DS: slowUpstreamDSStub{body: upstreamR}}
+			srv := httptest.NewServer(newOpenAITestRouter(h))
+			defer srv.Close()
+			defer func() { _ = upstreamW.Close() }()
+
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			req, _ := http.NewRequestWithContext(ctx, http.MethodPost, srv.URL+tc.path, strings.NewReader(tc.body))
+			req.Header.Set("Authorization", "Bearer direct-token")
+			req.Header.Set("Content-Type", "application/json")
+			// Headers must arrive before the upstream has produced anything.
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatalf("request failed before any upstream output: %v", err)
+			}
+			defer func() { _ = resp.Body.Close() }()
+			if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+				t.Fatalf("content-type=%q", ct)
+			}
+			if resp.Header.Get("Cache-Control") != "no-cache, no-transform" || resp.Header.Get("X-Accel-Buffering") != "no" {
+				t.Fatalf("missing no-buffering headers: %#v", resp.Header)
+			}
+
+			lines := bufio.NewReader(resp.Body)
+			readUntil := func(want string) string {
+				t.Helper()
+				var seen strings.Builder
+				for {
+					line, err := lines.ReadString('\n')
+					seen.WriteString(line)
+					if strings.Contains(line, want) {
+						return seen.String()
+					}
+					if err != nil {
+						t.Fatalf("stream ended before %q arrived (%v), got %q", want, err, seen.String())
+					}
+				}
+			}
+
+			// The first delta must reach the client while the upstream still
+			// holds back the rest of the answer.
+			_, _ = io.WriteString(upstreamW, "data: {\"p\":\"response/content\",\"v\":\"first-part\"}\n\n")
+			if got := readUntil("first-part"); strings.Contains(got, "second-part") {
+				t.Fatalf("unexpected later content: %q", got)
+			}
+			_, _ = io.WriteString(upstreamW, "data: {\"p\":\"response/content\",\"v\":\"second-part\"}\n\n")
+			readUntil("second-part")
+			_, _ = io.WriteString(upstreamW, "data: [DONE]\n\n")
+			_ = upstreamW.Close()
+			readUntil("data: [DONE]")
+		})
+	}
+}
@@ -190,7 +190,7 @@ func TestResponsesStreamStatusCapturedAs200(t *testing.T) {
 	}
 }
 
-func TestChatCompletionsStreamContentFilterStopsNormallyWithoutLeak(t *testing.T) {
+func TestChatCompletionsStreamContentFilterReportsFilterWithoutLeak(t *testing.T) {
 	statuses := make([]int, 0, 1)
 	h := &openAITestSurface{
 		Store: mockOpenAIConfig{},
@@ -235,8 +235,8 @@ func TestChatCompletionsStreamContentFilterStopsNormallyWithoutLeak(t *testing.T
 		t.Fatalf("expected one choice in final frame, got %#v", last)
 	}
 	choice, _ := choices[0].(map[string]any)
-	if choice["finish_reason"] != "stop" {
-		t.Fatalf("expected finish_reason=stop for content-filter upstream stop, got %#v", choice["finish_reason"])
+	if choice["finish_reason"] != "content_filter" {
+		t.Fatalf("expected finish_reason=content_filter for content-filter upstream stop, got %#v", choice["finish_reason"])
 	}
 }
 
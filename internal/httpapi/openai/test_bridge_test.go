@@ -12,6 +12,7 @@ import (
 	"ds2api/internal/auth"
 	"ds2api/internal/chathistory"
 	"ds2api/internal/httpapi/openai/chat"
+	"ds2api/internal/httpapi/openai/completions"
 	"ds2api/internal/httpapi/openai/embeddings"
 	"ds2api/internal/httpapi/openai/files"
 	"ds2api/internal/httpapi/openai/history"
@@ -26,11 +27,12 @@ type openAITestSurface struct {
 	DS          shared.DeepSeekCaller
 	ChatHistory *chathistory.Store
 
-	chat       *chat.Handler
-	responses  *responses.Handler
-	files      *files.Handler
-	embeddings *embeddings.Handler
-	models     *shared.ModelsHandler
+	chat        *chat.Handler
+	completions *completions.Handler
+	responses   *responses.Handler
+	files       *files.Handler
+	embeddings  *embeddings.Handler
+	models      *shared.ModelsHandler
 }
 
 func (h *openAITestSurface) deps() shared.Deps {
@@ -48,6 +50,14 @@ func (h *openAITestSurface) chatHandler() *chat.Handler {
 	return h.chat
 }
 
+func (h *openAITestSurface) completionsHandler() *completions.Handler {
+	if h.completions == nil {
+		deps := h.deps()
+		h.completions = &completions.Handler{Store: deps.Store, Auth: deps.Auth, DS: deps.DS}
+	}
+	return h.completions
+}
+
 func (h *openAITestSurface) responsesHandler() *responses.Handler {
 	if h.responses == nil {
 		deps := h.deps()
@@ -101,6 +111,7 @@ func registerOpenAITestRoutes(r chi.Router, h *openAITestSurface) {
 	r.Get("/v1/models", h.modelsHandler().ListModels)
 	r.Get("/v1/models/{model_id}", h.modelsHandler().GetModel)
 	r.Post("/v1/chat/completions", h.chatHandler().ChatCompletions)
+	r.Post("/v1/completions", h.completionsHandler().Completions)
 	r.Post("/v1/responses", h.responsesHandler().Responses)
 	r.Get("/v1/responses/{response_id}", h.responsesHandler().GetResponseByID)
 	r.Post("/v1/files", h.filesHandler().UploadFile)
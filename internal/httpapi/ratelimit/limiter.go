@@ -0,0 +1,169 @@
+package ratelimit
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// Limits exhausted by a rejected request, used in logs and metrics.
+const (
+	LimitRequests = "requests"
+	LimitTokens   = "tokens"
+)
+
+// sweepInterval is how often idle buckets are dropped. A bucket idle for a
+// full minute has refilled completely, so forgetting it changes nothing.
+const sweepInterval = time.Minute
+
+type rule struct {
+	requestsPerMinute int
+	tokensPerMinute   int
+}
+
+func (r rule) limited() bool {
+	return r.requestsPerMinute > 0 || r.tokensPerMinute > 0
+}
+
+// bucket holds one caller's remaining allowance. Both dimensions refill
+// continuously at their per-minute rate up to the limit.
+type bucket struct {
+	requests float64
+	tokens   float64
+	rule     rule
+	updated  time.Time
+	// inflight counts admitted requests whose generated tokens are not yet
+	// debited; such buckets are never swept.
+	inflight int
+}
+
+type limiter struct {
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	now       func() time.Time
+	lastSweep time.Time
+}
+
+func newLimiter() *limiter {
+	return &limiter{buckets: map[string]*bucket{}, now: time.Now}
+}
+
+// charge names one bucket a request is counted against and its rule.
+type charge struct {
+	kind string
+	key  string
+	rule rule
+}
+
+// admit charges one request and the prompt estimate against every bucket in
+// charges. When any bucket cannot cover them, nothing is charged and the
+// longest wait is returned with the bucket kind and limit that caused it.
+func (l *limiter) admit(charges []charge, promptTokens int) (ok bool, kind, limit string, retryAfter time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := l.now()
+	l.sweepLocked(now)
+	buckets := make([]*bucket, len(charges))
+	for i, c := range charges {
+		b, exists := l.buckets[c.key]
+		if !exists {
+			b = &bucket{requests: float64(c.rule.requestsPerMinute), tokens: float64(c.rule.tokensPerMinute), updated: now}
+			l.buckets[c.key] = b
+		}
+		b.refill(c.rule, now)
+		buckets[i] = b
+		if short, wait := b.shortfall(promptTokens); short != "" && (limit == "" || wait > retryAfter) {
+			kind, limit, retryAfter = c.kind, short, wait
+		}
+	}
+	if limit != "" {
+		return false, kind, limit, retryAfter
+	}
+	for _, b := range buckets {
+		if b.rule.requestsPerMinute > 0 {
+			b.requests--
+		}
+		b.debitTokens(promptTokens)
+		b.inflight++
+	}
+	return true, "", "", 0
+}
+
+// shortfall reports the limit that keeps the bucket from covering one more
+// request with promptTokens, and the wait until it can.
+func (b *bucket) shortfall(promptTokens int) (limit string, retryAfter time.Duration) {
+	r := b.rule
+	if r.requestsPerMinute > 0 && b.requests < 1 {
+		limit, retryAfter = LimitRequests, waitFor(1-b.requests, r.requestsPerMinute)
+	}
+	if r.tokensPerMinute > 0 {
+		// A prompt larger than the whole budget is admitted once the bucket is
+		// full, rather than never.
+		need := math.Min(float64(promptTokens), float64(r.tokensPerMinute))
+		if b.tokens < need {
+			if wait := waitFor(need-b.tokens, r.tokensPerMinute); wait > retryAfter {
+				limit, retryAfter = LimitTokens, wait
+			}
+		}
+	}
+	return limit, retryAfter
+}
+
+// finish debits the tokens generated by an admitted request from each of
+// its buckets.
+func (l *limiter) finish(keys []string, generatedTokens int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := l.now()
+	for _, key := range keys {
+		b, ok := l.buckets[key]
+		if !ok {
+			continue
+		}
+		b.refill(b.rule, now)
+		b.debitTokens(generatedTokens)
+		if b.inflight > 0 {
+			b.inflight--
+		}
+	}
+}
+
+func (l *limiter) sweepLocked(now time.Time) {
+	if now.Sub(l.lastSweep) < sweepInterval {
+		return
+	}
+	l.lastSweep = now
+	for key, b := range l.buckets {
+		if b.inflight == 0 && now.Sub(b.updated) >= sweepInterval {
+			delete(l.buckets, key)
+		}
+	}
+}
+
+// refill tops the bucket up for the time elapsed since its last update and
+// adopts r, so limits changed by a config reload apply at once.
+func (b *bucket) refill(r rule, now time.Time) {
+	elapsed := now.Sub(b.updated).Minutes()
+	if elapsed > 0 {
+		b.requests += elapsed * float64(r.requestsPerMinute)
+		b.tokens += elapsed * float64(r.tokensPerMinute)
+		b.updated = now
+	}
+	b.requests = math.Min(b.requests, float64(r.requestsPerMinute))
+	b.tokens = math.Min(b.tokens, float64(r.tokensPerMinute))
+	b.rule = r
+}
+
+// debitTokens charges n tokens. The balance may go negative, by at most one
+// minute's budget, so an oversized exchange delays the caller's next request
+// instead of being forgotten.
+func (b *bucket) debitTokens(n int) {
+	if b.rule.tokensPerMinute <= 0 || n <= 0 {
+		return
+	}
+	b.tokens = math.Max(b.tokens-float64(n), -float64(b.rule.tokensPerMinute))
+}
+
+func waitFor(deficit float64, perMinute int) time.Duration {
+	return time.Duration(deficit / float64(perMinute) * float64(time.Minute))
+}
@@ -0,0 +1,161 @@
+package ratelimit
+
+import (
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5/middleware"
+
+	"ds2api/internal/auth"
+	"ds2api/internal/config"
+	"ds2api/internal/httpapi/bodyreplay"
+	"ds2api/internal/httpapi/openai/shared"
+	"ds2api/internal/metrics"
+	"ds2api/internal/util"
+)
+
+// Bucket kinds, from the most to the least specific caller identity.
+const (
+	BucketUser = "user"
+	BucketKey  = "key"
+	BucketIP   = "ip"
+)
+
+// promptFields hold the request content counted against the token limit.
+var promptFields = []string{"messages", "input", "prompt", "contents", "system", "systemInstruction", "instructions"}
+
+// Middleware enforces per-caller requests-per-minute and tokens-per-minute
+// limits with in-memory token buckets. Every request counts against its API
+// key, else its client IP; a body `user` field (or Claude's
+// metadata.user_id) adds that user's bucket under the key.
+// Admission charges one request plus an estimate of the prompt tokens; the
+// completion tokens are charged once the handler returns. Rejected requests
+// get 429 rate_limit_error with Retry-After. Settings are read per request so
+// hot-reloaded limits apply at once.
+func Middleware(settings func() config.RateLimitConfig) func(http.Handler) http.Handler {
+	l := newLimiter()
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if settings == nil || !bodyreplay.Eligible(r) {
+				next.ServeHTTP(w, r)
+				return
+			}
+			cfg := settings()
+			if cfg.Enabled == nil || !*cfg.Enabled {
+				next.ServeHTTP(w, r)
+				return
+			}
+			r, body := bodyreplay.Capture(r)
+			if body.Err != nil {
+				next.ServeHTTP(w, r)
+				return
+			}
+			req := body.JSON()
+			user := bodyreplay.User(req)
+			charges := bucketCharges(r, cfg, user)
+			if len(charges) == 0 {
+				next.ServeHTTP(w, r)
+				return
+			}
+			ok, kind, limit, wait := l.admit(charges, promptTokens(req))
+			if !ok {
+				retryAfter := int(math.Ceil(wait.Seconds()))
+				if retryAfter < 1 {
+					retryAfter = 1
+				}
+				metrics.ObserveRateLimited(limit, kind)
+				config.Logger.Warn("[rate_limit] request rejected", "trace_id", middleware.GetReqID(r.Context()), "path", r.URL.Path, "bucket", kind, "user", user, "limit", limit, "retry_after", retryAfter)
+				w.Header().Set("Retry-After", strconv.Itoa(retryAfter))
+				shared.WriteOpenAIErrorWithCode(w, http.StatusTooManyRequests, fmt.Sprintf("Rate limit exceeded: too many %s per minute. Retry after %d seconds.", limit, retryAfter), "rate_limit_exceeded")
+				return
+			}
+			keys := make([]string, len(charges))
+			for i, c := range charges {
+				keys[i] = c.key
+			}
+			// Deferred so a panicking handler still releases the buckets.
+			defer func() { l.finish(keys, metrics.GeneratedTokens(r.Context())) }()
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// bucketCharges lists the buckets a request is counted against: its API key,
+// or its client IP without one, under the default limits; and, when the
+// body names a user, that user's bucket nested under the key. A request must
+// pass both, so varying `user` never lifts a caller above its key's limits.
+// Buckets with no limit configured are left out.
+func bucketCharges(r *http.Request, cfg config.RateLimitConfig, user string) []charge {
+	var out []charge
+	parent := charge{kind: BucketKey, key: auth.CallerID(r), rule: ruleFor(cfg, "")}
+	if parent.key == "" {
+		host, _, err := net.SplitHostPort(r.RemoteAddr)
+		if err != nil {
+			host = r.RemoteAddr
+		}
+		parent.kind, parent.key = BucketIP, "ip:"+host
+	}
+	if parent.rule.limited() {
+		out = append(out, parent)
+	}
+	if user != "" {
+		if userRule := ruleFor(cfg, user); userRule.limited() {
+			out = append(out, charge{kind: BucketUser, key: parent.key + "\x00user:" + user, rule: userRule})
+		}
+	}
+	return out
+}
+
+// ruleFor applies the per-user override for user on top of the defaults; a
+// zero override field keeps the default.
+func ruleFor(cfg config.RateLimitConfig, user string) rule {
+	out := rule{requestsPerMinute: cfg.RequestsPerMinute, tokensPerMinute: cfg.TokensPerMinute}
+	if user == "" {
+		return out
+	}
+	if override, ok := cfg.Users[user]; ok {
+		if override.RequestsPerMinute > 0 {
+			out.requestsPerMinute = override.RequestsPerMinute
+		}
+		if override.TokensPerMinute > 0 {
+			out.tokensPerMinute = override.TokensPerMinute
+		}
+	}
+	return out
+}
+
+// promptTokens estimates the prompt size from every string in the content
+// fields of the request.
+func promptTokens(req map[string]any) int {
+	total := 0
+	for _, field := range promptFields {
+		if v, ok := req[field]; ok {
+			total += estimateValue(v)
+		}
+	}
+	return total
+}
+
+func estimateValue(v any) int {
+	switch x := v.(type) {
+	case string:
+		return util.EstimateTokens(x)
+	case []any:
+		n := 0
+		for _, item := range x {
+			n += estimateValue(item)
+		}
+		return n
+	case map[string]any:
+		n := 0
+		for _, item := range x {
+			n += estimateValue(item)
+		}
+		return n
+	default:
+		return 0
+	}
+}
@@ -0,0 +1,179 @@
+package ratelimit
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"ds2api/internal/config"
+	"ds2api/internal/metrics"
+)
+
+func settings(cfg config.RateLimitConfig) func() config.RateLimitConfig {
+	on := true
+	cfg.Enabled = &on
+	return func() config.RateLimitConfig { return cfg }
+}
+
+func post(h http.Handler, apiKey, remoteAddr, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	if apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+apiKey)
+	}
+	if remoteAddr != "" {
+		req.RemoteAddr = remoteAddr
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec
+}
+
+func okHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+}
+
+func TestMiddlewareRejectsOverRequestLimitWithRetryAfter(t *testing.T) {
+	h := Middleware(settings(config.RateLimitConfig{RequestsPerMinute: 2}))(okHandler())
+	body := `{"user":"alice","messages":[{"role":"user","content":"hi"}]}`
+	for i := 0; i < 2; i++ {
+		if rec := post(h, "k1", "", body); rec.Code != http.StatusOK {
+			t.Fatalf("request %d: expected 200, got %d", i, rec.Code)
+		}
+	}
+	rec := post(h, "k1", "", body)
+	if rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("expected 429, got %d", rec.Code)
+	}
+	if got := rec.Header().Get("Retry-After"); got != "30" {
+		t.Fatalf("expected Retry-After 30, got %q", got)
+	}
+	var payload struct {
+		Error struct {
+			Type string `json:"type"`
+			Code string `json:"code"`
+		} `json:"error"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &payload); err != nil {
+		t.Fatalf("decode error body: %v", err)
+	}
+	if payload.Error.Type != "rate_limit_error" || payload.Error.Code != "rate_limit_exceeded" {
+		t.Fatalf("unexpected error body: %s", rec.Body.String())
+	}
+	if rec := post(h, "k1", "", `{"user":"bob","messages":[]}`); rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("expected another user to stay within the key's limit, got %d", rec.Code)
+	}
+	if rec := post(h, "k2", "", body); rec.Code != http.StatusOK {
+		t.Fatalf("expected the same user under another key to keep its own bucket, got %d", rec.Code)
+	}
+}
+
+func TestMiddlewareAppliesPerUserOverrides(t *testing.T) {
+	h := Middleware(settings(config.RateLimitConfig{
+		RequestsPerMinute: 3,
+		Users:             map[string]config.RateLimitRule{"capped": {RequestsPerMinute: 1}},
+	}))(okHandler())
+	if rec := post(h, "k", "", `{"metadata":{"user_id":"capped"}}`); rec.Code != http.StatusOK {
+		t.Fatalf("expected the first capped request through, got %d", rec.Code)
+	}
+	if rec := post(h, "k", "", `{"metadata":{"user_id":"capped"}}`); rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("expected the override limit to apply, got %d", rec.Code)
+	}
+	for i := 0; i < 2; i++ {
+		if rec := post(h, "k", "", `{"user":"other"}`); rec.Code != http.StatusOK {
+			t.Fatalf("other request %d: expected 200, got %d", i, rec.Code)
+		}
+	}
+	if rec := post(h, "k", "", `{"user":"other"}`); rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("expected the key bucket to cap every user together, got %d", rec.Code)
+	}
+}
+
+func TestMiddlewareUserCannotEscapeKeyLimit(t *testing.T) {
+	h := Middleware(settings(config.RateLimitConfig{RequestsPerMinute: 2}))(okHandler())
+	for i, user := range []string{"a", "b"} {
+		if rec := post(h, "k", "", `{"user":"`+user+`"}`); rec.Code != http.StatusOK {
+			t.Fatalf("request %d: expected 200, got %d", i, rec.Code)
+		}
+	}
+	if rec := post(h, "k", "", `{"user":"c"}`); rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("expected a fresh user to hit the key limit, got %d", rec.Code)
+	}
+}
+
+func TestMiddlewareFallsBackToKeyThenIP(t *testing.T) {
+	h := Middleware(settings(config.RateLimitConfig{RequestsPerMinute: 1}))(okHandler())
+	post(h, "k1", "10.0.0.1:1000", `{}`)
+	if rec := post(h, "k1", "10.0.0.2:1000", `{}`); rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("expected requests without a user to share the key bucket, got %d", rec.Code)
+	}
+	post(h, "", "10.0.0.1:1000", `{}`)
+	if rec := post(h, "", "10.0.0.1:2000", `{}`); rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("expected anonymous requests to share the IP bucket, got %d", rec.Code)
+	}
+	if rec := post(h, "", "10.0.0.3:1000", `{}`); rec.Code != http.StatusOK {
+		t.Fatalf("expected another IP to keep its own bucket, got %d", rec.Code)
+	}
+}
+
+func TestMiddlewareChargesGeneratedTokens(t *testing.T) {
+	h := Middleware(settings(config.RateLimitConfig{TokensPerMinute: 100}))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		metrics.AddGeneratedTokens(r.Context(), 150)
+		w.WriteHeader(http.StatusOK)
+	}))
+	wrapped := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		h.ServeHTTP(w, r.WithContext(metrics.WithRequest(r.Context(), &metrics.Request{})))
+	})
+	if rec := post(wrapped, "k", "", `{"messages":[{"role":"user","content":"hi"}]}`); rec.Code != http.StatusOK {
+		t.Fatalf("expected the first request through, got %d", rec.Code)
+	}
+	rec := post(wrapped, "k", "", `{"messages":[{"role":"user","content":"hi"}]}`)
+	if rec.Code != http.StatusTooManyRequests || !strings.Contains(rec.Body.String(), "tokens per minute") {
+		t.Fatalf("expected the token limit to reject, got %d %s", rec.Code, rec.Body.String())
+	}
+}
+
+func TestLimiterRefillsOverTime(t *testing.T) {
+	now := time.Unix(1000, 0)
+	l := newLimiter()
+	l.now = func() time.Time { return now }
+	r := rule{requestsPerMinute: 60, tokensPerMinute: 600}
+	for i := 0; i < 60; i++ {
+		if ok, _, _, _ := l.admit([]charge{{kind: BucketKey, key: "k", rule: r}}, 0); !ok {
+			t.Fatalf("request %d rejected", i)
+		}
+		l.finish([]string{"k"}, 0)
+	}
+	ok, _, limit, wait := l.admit([]charge{{kind: BucketKey, key: "k", rule: r}}, 0)
+	if ok || limit != LimitRequests || wait != time.Second {
+		t.Fatalf("expected a one second wait on requests, got %v %q %v", ok, limit, wait)
+	}
+	now = now.Add(time.Second)
+	if ok, _, _, _ := l.admit([]charge{{kind: BucketKey, key: "k", rule: r}}, 0); !ok {
+		t.Fatal("expected a request to be admitted after refilling")
+	}
+	l.finish([]string{"k"}, 0)
+
+	if ok, _, _, _ := l.admit([]charge{{kind: BucketKey, key: "t", rule: r}}, 1000); !ok {
+		t.Fatal("expected an oversized prompt to be admitted on a full bucket")
+	}
+	l.finish([]string{"t"}, 0)
+	ok, _, limit, wait = l.admit([]charge{{kind: BucketKey, key: "t", rule: r}}, 10)
+	if ok || limit != LimitTokens || wait != 41*time.Second {
+		t.Fatalf("expected a token wait after the debt, got %v %q %v", ok, limit, wait)
+	}
+
+	now = now.Add(2 * sweepInterval)
+	l.admit([]charge{{kind: BucketKey, key: "fresh", rule: r}}, 0)
+	l.mu.Lock()
+	_, kept := l.buckets["k"]
+	l.mu.Unlock()
+	if kept {
+		t.Fatal("expected idle buckets to be swept")
+	}
+}
@@ -25,7 +25,7 @@ func ValidateJSONUTF8(next http.Handler) http.Handler {
 	}
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		if shouldValidateJSONBody(r) {
-			r.Body = validateAndReplayBody(r.Body)
+			r.Body = validateAndReplayBody(r.Body, r.ContentLength)
 		}
 		next.ServeHTTP(w, r)
 	})
@@ -68,6 +68,8 @@ func isKnownJSONRequestPath(method, path string) bool {
 	switch {
 	case path == "/v1/chat/completions" || path == "/chat/completions":
 		return true
+	case path == "/v1/completions" || path == "/completions":
+		return true
 	case path == "/v1/responses" || path == "/responses":
 		return true
 	case path == "/v1/embeddings" || path == "/embeddings":
@@ -85,14 +87,20 @@ func isKnownJSONRequestPath(method, path string) bool {
 	}
 }
 
-func validateAndReplayBody(body io.ReadCloser) io.ReadCloser {
+// validateAndReplayBody buffers the body once. A known Content-Length sizes
+// the buffer up front so large bodies are not copied while it grows.
+func validateAndReplayBody(body io.ReadCloser, contentLength int64) io.ReadCloser {
 	if body == nil {
 		return body
 	}
-	raw, err := io.ReadAll(io.LimitReader(body, maxJSONUTF8ValidationSize+1))
-	if err != nil {
+	var buf bytes.Buffer
+	if contentLength > 0 && contentLength <= maxJSONUTF8ValidationSize {
+		buf.Grow(int(contentLength) + bytes.MinRead)
+	}
+	if _, err := buf.ReadFrom(io.LimitReader(body, maxJSONUTF8ValidationSize+1)); err != nil {
 		return &errorReadCloser{err: err, closer: body}
 	}
+	raw := buf.Bytes()
 	if len(raw) > maxJSONUTF8ValidationSize {
 		return &errorReadCloser{err: errRequestBodyTooLarge, closer: body}
 	}
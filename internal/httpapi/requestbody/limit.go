@@ -0,0 +1,52 @@
+package requestbody
+
+import (
+	"errors"
+	"mime"
+	"net/http"
+	"strings"
+)
+
+// ErrRequestBodyTooLarge is returned by body reads once a request exceeds the
+// configured size limit. Handlers answer it with 413.
+var ErrRequestBodyTooLarge = errRequestBodyTooLarge
+
+// LimitSize caps request bodies at limit() bytes. A declared Content-Length
+// over the limit fails on the first read without touching the connection;
+// chunked bodies are cut by http.MaxBytesReader once they cross it. Multipart
+// uploads keep their own limit in the files handler. The limit is read per
+// request so hot-reloaded config applies at once.
+func LimitSize(limit func() int64) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if limit == nil || r.Body == nil || r.Body == http.NoBody || isMultipart(r.Header.Get("Content-Type")) {
+				next.ServeHTTP(w, r)
+				return
+			}
+			n := limit()
+			switch {
+			case n <= 0:
+			case r.ContentLength > n:
+				r.Body = &errorReadCloser{err: ErrRequestBodyTooLarge, closer: r.Body}
+			default:
+				r.Body = http.MaxBytesReader(w, r.Body, n)
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// IsTooLarge reports whether err comes from a body that exceeded a size
+// limit, including http.MaxBytesReader limits set by individual handlers.
+func IsTooLarge(err error) bool {
+	var maxErr *http.MaxBytesError
+	return errors.Is(err, ErrRequestBodyTooLarge) || errors.As(err, &maxErr)
+}
+
+func isMultipart(raw string) bool {
+	mediaType, _, err := mime.ParseMediaType(strings.TrimSpace(raw))
+	if err != nil {
+		return false
+	}
+	return strings.HasPrefix(strings.ToLower(mediaType), "multipart/")
+}
@@ -0,0 +1,49 @@
+package requestbody
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func serveLimited(t *testing.T, req *http.Request, limit int64) (int, error) {
+	t.Helper()
+	var n int
+	var readErr error
+	handler := LimitSize(func() int64 { return limit })(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, err := io.ReadAll(r.Body)
+		n, readErr = len(b), err
+	}))
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+	return n, readErr
+}
+
+func TestLimitSizeRejectsDeclaredLengthWithoutReading(t *testing.T) {
+	req := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", strings.NewReader(strings.Repeat("a", 64)))
+	n, err := serveLimited(t, req, 16)
+	if n != 0 || !IsTooLarge(err) {
+		t.Fatalf("expected too-large error before any bytes, got n=%d err=%v", n, err)
+	}
+}
+
+func TestLimitSizeCutsChunkedBodies(t *testing.T) {
+	req := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", io.NopCloser(strings.NewReader(strings.Repeat("a", 64))))
+	req.ContentLength = -1
+	if n, err := serveLimited(t, req, 16); n > 16 || !IsTooLarge(err) {
+		t.Fatalf("expected chunked body cut at the limit, got n=%d err=%v", n, err)
+	}
+}
+
+func TestLimitSizePassesSmallAndMultipartBodies(t *testing.T) {
+	req := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", strings.NewReader("{}"))
+	if n, err := serveLimited(t, req, 16); n != 2 || err != nil {
+		t.Fatalf("expected small body untouched, got n=%d err=%v", n, err)
+	}
+	req = httptest.NewRequest(http.MethodPost, "/v1/files", strings.NewReader(strings.Repeat("a", 64)))
+	req.Header.Set("Content-Type", "multipart/form-data; boundary=x")
+	if n, err := serveLimited(t, req, 16); n != 64 || err != nil {
+		t.Fatalf("expected multipart body left to the files handler, got n=%d err=%v", n, err)
+	}
+}
@@ -0,0 +1,88 @@
+package requestctx
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5/middleware"
+
+	"ds2api/internal/config"
+)
+
+const (
+	// CodeRequestTimeout is the error code reported when the overall request
+	// deadline expires before the upstream completion finished.
+	CodeRequestTimeout = "request_timeout"
+	// TimeoutMessage is the client-facing message for CodeRequestTimeout.
+	TimeoutMessage = "Request timed out before the upstream completion finished."
+
+	ReasonDeadlineExceeded   = "deadline_exceeded"
+	ReasonClientDisconnected = "client_disconnected"
+)
+
+// Deadline bounds every API request with the duration returned by timeout,
+// read per request so hot-reloaded settings apply immediately. The deadline
+// rides on r.Context(), which the handlers already thread into the upstream
+// DeepSeek call and the SSE pump, so expiry and client disconnects both
+// cancel the upstream request. Admin and WebUI routes are left unbounded.
+func Deadline(timeout func() time.Duration) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !isAPIRequest(r) {
+				next.ServeHTTP(w, r)
+				return
+			}
+			ctx := r.Context()
+			if timeout != nil {
+				if d := timeout(); d > 0 {
+					var cancel context.CancelFunc
+					ctx, cancel = context.WithTimeout(ctx, d)
+					defer cancel()
+					r = r.WithContext(ctx)
+				}
+			}
+			started := time.Now()
+			next.ServeHTTP(w, r)
+			if reason := CancelReason(ctx); reason != "" {
+				config.Logger.Warn("[request_cancel] request context ended before the handler returned", "trace_id", middleware.GetReqID(ctx), "method", r.Method, "path", r.URL.Path, "reason", reason, "elapsed", time.Since(started))
+			}
+		})
+	}
+}
+
+// CancelReason reports why ctx ended: ReasonDeadlineExceeded when the request
+// deadline expired, ReasonServerShutdown when a shutdown cut it off,
+// ReasonClientDisconnected for any other cancellation, or "" while ctx is
+// still live.
+func CancelReason(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	switch err := ctx.Err(); {
+	case err == nil:
+		return ""
+	case errors.Is(context.Cause(ctx), ErrServerShutdown):
+		return ReasonServerShutdown
+	case errors.Is(err, context.DeadlineExceeded):
+		return ReasonDeadlineExceeded
+	default:
+		return ReasonClientDisconnected
+	}
+}
+
+// DeadlineExceeded reports whether ctx ended because the request deadline
+// expired rather than because the client went away.
+func DeadlineExceeded(ctx context.Context) bool {
+	return CancelReason(ctx) == ReasonDeadlineExceeded
+}
+
+func isAPIRequest(r *http.Request) bool {
+	if r == nil || r.URL == nil {
+		return false
+	}
+	path := r.URL.Path
+	return path != "/admin" && !strings.HasPrefix(path, "/admin/")
+}
@@ -0,0 +1,55 @@
+package requestctx
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestDeadlineBoundsAPIRequestsAndSkipsAdmin(t *testing.T) {
+	gotDeadline := map[string]bool{}
+	handler := Deadline(func() time.Duration { return time.Minute })(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+		_, ok := r.Context().Deadline()
+		gotDeadline[r.URL.Path] = ok
+	}))
+	for _, path := range []string{"/v1/chat/completions", "/admin/settings"} {
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, path, nil))
+	}
+	if !gotDeadline["/v1/chat/completions"] {
+		t.Fatal("expected API request context to carry a deadline")
+	}
+	if gotDeadline["/admin/settings"] {
+		t.Fatal("expected admin request context to stay unbounded")
+	}
+}
+
+func TestDeadlineExpiryCancelsHandlerContext(t *testing.T) {
+	var reason string
+	handler := Deadline(func() time.Duration { return 10 * time.Millisecond })(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+			reason = CancelReason(r.Context())
+		case <-time.After(2 * time.Second):
+		}
+	}))
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/v1/responses", nil))
+	if reason != ReasonDeadlineExceeded {
+		t.Fatalf("expected deadline cancellation, got %q", reason)
+	}
+}
+
+func TestCancelReasonDistinguishesClientDisconnect(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	if got := CancelReason(ctx); got != "" {
+		t.Fatalf("expected live context to have no reason, got %q", got)
+	}
+	cancel()
+	if got := CancelReason(ctx); got != ReasonClientDisconnected {
+		t.Fatalf("expected client disconnect reason, got %q", got)
+	}
+	if DeadlineExceeded(ctx) {
+		t.Fatal("expected plain cancellation not to count as a deadline")
+	}
+}
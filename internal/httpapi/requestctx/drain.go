@@ -0,0 +1,127 @@
+package requestctx
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	// CodeServerShutdown is the error code written to streams that were still
+	// running when the shutdown grace period ran out.
+	CodeServerShutdown = "server_shutdown"
+	// ShutdownMessage is the client-facing message for CodeServerShutdown.
+	ShutdownMessage = "Server is shutting down; the response was cut short."
+
+	ReasonServerShutdown = "server_shutdown"
+)
+
+// ErrServerShutdown is the cancellation cause of requests cut off by
+// Drainer.Drain.
+var ErrServerShutdown = errors.New("server shutting down")
+
+// Drainer tracks in-flight API requests so a shutdown can wait for them and
+// then cancel whatever outlives the grace period, letting each handler write
+// its own terminal event before the connection closes.
+type Drainer struct {
+	mu       sync.Mutex
+	inflight map[*drainEntry]struct{}
+	idle     chan struct{}
+}
+
+type drainEntry struct {
+	cancel context.CancelCauseFunc
+	done   chan struct{}
+}
+
+func NewDrainer() *Drainer {
+	return &Drainer{inflight: map[*drainEntry]struct{}{}}
+}
+
+// Track registers every API request with the drainer. Admin and WebUI routes
+// are left alone, like in Deadline.
+func (d *Drainer) Track(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !isAPIRequest(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		ctx, cancel := context.WithCancelCause(r.Context())
+		e := &drainEntry{cancel: cancel, done: make(chan struct{})}
+		d.mu.Lock()
+		d.inflight[e] = struct{}{}
+		d.mu.Unlock()
+		defer func() {
+			cancel(nil)
+			d.mu.Lock()
+			delete(d.inflight, e)
+			if len(d.inflight) == 0 && d.idle != nil {
+				close(d.idle)
+				d.idle = nil
+			}
+			d.mu.Unlock()
+			close(e.done)
+		}()
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// Inflight returns the number of API requests currently running.
+func (d *Drainer) Inflight() int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return len(d.inflight)
+}
+
+// Drain waits up to grace for in-flight requests to finish, then cancels the
+// rest with ErrServerShutdown and waits up to forceWait for their handlers to
+// return. drained counts requests that finished within the grace period;
+// forced counts those that were cancelled.
+func (d *Drainer) Drain(grace, forceWait time.Duration) (drained, forced int) {
+	d.mu.Lock()
+	started := len(d.inflight)
+	if started == 0 {
+		d.mu.Unlock()
+		return 0, 0
+	}
+	idle := make(chan struct{})
+	d.idle = idle
+	d.mu.Unlock()
+
+	timer := time.NewTimer(grace)
+	defer timer.Stop()
+	select {
+	case <-idle:
+		return started, 0
+	case <-timer.C:
+	}
+
+	d.mu.Lock()
+	remaining := make([]*drainEntry, 0, len(d.inflight))
+	for e := range d.inflight {
+		remaining = append(remaining, e)
+	}
+	d.mu.Unlock()
+	for _, e := range remaining {
+		e.cancel(ErrServerShutdown)
+	}
+	deadline := time.NewTimer(forceWait)
+	defer deadline.Stop()
+	for _, e := range remaining {
+		select {
+		case <-e.done:
+		case <-deadline.C:
+			return started - len(remaining), len(remaining)
+		}
+	}
+	return started - len(remaining), len(remaining)
+}
+
+// ShuttingDown reports whether ctx was cancelled by a server shutdown. The
+// client is still connected then, so streams should end with a terminal
+// event rather than just stopping.
+func ShuttingDown(ctx context.Context) bool {
+	return CancelReason(ctx) == ReasonServerShutdown
+}
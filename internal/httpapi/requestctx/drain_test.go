@@ -0,0 +1,55 @@
+package requestctx
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestDrainWaitsForRequestsWithinGrace(t *testing.T) {
+	d := NewDrainer()
+	started := make(chan struct{})
+	handler := d.Track(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {
+		close(started)
+		time.Sleep(20 * time.Millisecond)
+	}))
+	go handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/v1/chat/completions", nil))
+	<-started
+	drained, forced := d.Drain(time.Second, time.Second)
+	if drained != 1 || forced != 0 || d.Inflight() != 0 {
+		t.Fatalf("expected one drained request, got drained=%d forced=%d inflight=%d", drained, forced, d.Inflight())
+	}
+}
+
+func TestDrainCancelsRequestsPastGraceWithShutdownReason(t *testing.T) {
+	d := NewDrainer()
+	started := make(chan struct{})
+	reason := make(chan string, 1)
+	handler := d.Track(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-r.Context().Done()
+		reason <- CancelReason(r.Context())
+	}))
+	go handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/v1/responses", nil))
+	<-started
+	drained, forced := d.Drain(10*time.Millisecond, time.Second)
+	if drained != 0 || forced != 1 {
+		t.Fatalf("expected one force-closed request, got drained=%d forced=%d", drained, forced)
+	}
+	if got := <-reason; got != ReasonServerShutdown {
+		t.Fatalf("expected shutdown cancel reason, got %q", got)
+	}
+}
+
+func TestDrainSkipsAdminRequests(t *testing.T) {
+	d := NewDrainer()
+	var inflight int
+	handler := d.Track(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {
+		inflight = d.Inflight()
+	}))
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/admin/settings", nil))
+	if inflight != 0 {
+		t.Fatalf("expected admin requests to stay untracked, got %d", inflight)
+	}
+}
@@ -0,0 +1,106 @@
+package requestctx
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/google/uuid"
+)
+
+const (
+	// TraceHeader carries the request's trace ID in both directions.
+	TraceHeader = "X-Request-Id"
+	// TraceparentHeader is the W3C Trace Context header; its trace-id field
+	// is adopted when no X-Request-ID is sent.
+	TraceparentHeader = "traceparent"
+
+	maxTraceIDLength = 128
+)
+
+// TraceID assigns every request a trace ID: the caller's X-Request-ID when
+// it is a usable token, else the trace-id of a valid traceparent, else a
+// fresh random one. The ID is stored where middleware.GetReqID finds it, so
+// logs and handlers keep reading it the same way, and is set as the
+// X-Request-Id response header before the handler runs, so streamed and
+// failed responses carry it as well.
+func TraceID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := InboundTraceID(r.Header)
+		if id == "" {
+			id = strings.ReplaceAll(uuid.NewString(), "-", "")
+		}
+		w.Header().Set(TraceHeader, id)
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), middleware.RequestIDKey, id)))
+	})
+}
+
+// InboundTraceID returns the trace ID a caller supplied, or "" when neither
+// header holds a usable one. Over-long IDs and IDs with characters outside
+// [A-Za-z0-9._:/+=@-] are ignored rather than trusted into logs.
+func InboundTraceID(h http.Header) string {
+	if id := strings.TrimSpace(h.Get(TraceHeader)); validTraceID(id) {
+		return id
+	}
+	return traceparentTraceID(h.Get(TraceparentHeader))
+}
+
+// ResponseTraceID returns the trace ID already set on w by TraceID, for error
+// writers that only have the response at hand.
+func ResponseTraceID(w http.ResponseWriter) string {
+	if w == nil {
+		return ""
+	}
+	return w.Header().Get(TraceHeader)
+}
+
+func validTraceID(id string) bool {
+	if id == "" || len(id) > maxTraceIDLength {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		c := id[i]
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9':
+		case strings.IndexByte("._:/+=@-", c) >= 0:
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// traceparentTraceID extracts the 32-hex trace-id from a
+// "version-traceid-parentid-flags" traceparent value. The all-zero trace-id
+// is invalid by spec.
+func traceparentTraceID(value string) string {
+	parts := strings.Split(strings.TrimSpace(value), "-")
+	if len(parts) < 4 || len(parts[0]) != 2 || parts[0] == "ff" || len(parts[1]) != 32 || len(parts[2]) != 16 {
+		return ""
+	}
+	traceID := strings.ToLower(parts[1])
+	if !isHex(parts[0]) || !isHex(traceID) || !isHex(parts[2]) || strings.Trim(traceID, "0") == "" {
+		return ""
+	}
+	return traceID
+}
+
+func isHex(s string) bool {
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if (c < '0' || c > '9') && (c < 'a' || c > 'f') && (c < 'A' || c > 'F') {
+			return false
+		}
+	}
+	return s != ""
+}
+
+// WithTraceID adds w's trace ID to an error body as request_id, so clients
+// that only record response bodies can still quote it.
+func WithTraceID(w http.ResponseWriter, body map[string]any) map[string]any {
+	if id := ResponseTraceID(w); id != "" {
+		body["request_id"] = id
+	}
+	return body
+}
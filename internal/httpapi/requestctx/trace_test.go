@@ -0,0 +1,58 @@
+package requestctx
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+func TestInboundTraceID(t *testing.T) {
+	cases := []struct {
+		name   string
+		header http.Header
+		want   string
+	}{
+		{"request id", http.Header{"X-Request-Id": {" caller-1 "}}, "caller-1"},
+		{"request id wins", http.Header{"X-Request-Id": {"caller-1"}, "Traceparent": {"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"}}, "caller-1"},
+		{"traceparent", http.Header{"Traceparent": {"00-4BF92F3577B34DA6A3CE929D0E0E4736-00f067aa0ba902b7-01"}}, "4bf92f3577b34da6a3ce929d0e0e4736"},
+		{"bad request id falls back", http.Header{"X-Request-Id": {"a b\n"}, "Traceparent": {"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"}}, "4bf92f3577b34da6a3ce929d0e0e4736"},
+		{"too long", http.Header{"X-Request-Id": {strings.Repeat("a", 129)}}, ""},
+		{"zero trace id", http.Header{"Traceparent": {"00-00000000000000000000000000000000-00f067aa0ba902b7-01"}}, ""},
+		{"malformed traceparent", http.Header{"Traceparent": {"00-xyz-00f067aa0ba902b7-01"}}, ""},
+		{"none", http.Header{}, ""},
+	}
+	for _, tc := range cases {
+		if got := InboundTraceID(tc.header); got != tc.want {
+			t.Fatalf("%s: want %q, got %q", tc.name, tc.want, got)
+		}
+	}
+}
+
+func TestTraceIDSetsHeaderBeforeHandlerAndContext(t *testing.T) {
+	var seenHeader, seenCtx string
+	h := TraceID(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seenHeader = w.Header().Get(TraceHeader)
+		seenCtx = middleware.GetReqID(r.Context())
+		_, _ = w.Write([]byte("data: x\n\n"))
+	}))
+
+	req := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", nil)
+	req.Header.Set("X-Request-ID", "caller-7")
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if seenHeader != "caller-7" || seenCtx != "caller-7" || rec.Header().Get(TraceHeader) != "caller-7" {
+		t.Fatalf("expected caller-7 everywhere, got header=%q ctx=%q response=%q", seenHeader, seenCtx, rec.Header().Get(TraceHeader))
+	}
+
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/v1/chat/completions", nil))
+	if got := rec.Header().Get(TraceHeader); len(got) != 32 || got != seenCtx {
+		t.Fatalf("expected a generated 32-char trace ID shared with the context, got %q ctx=%q", got, seenCtx)
+	}
+	if body := WithTraceID(rec, map[string]any{}); body["request_id"] != seenCtx {
+		t.Fatalf("expected request_id in the error body, got %#v", body)
+	}
+}
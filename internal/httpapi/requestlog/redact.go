@@ -0,0 +1,143 @@
+package requestlog
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"ds2api/internal/config"
+)
+
+// structuralKeys hold identifiers and enums rather than user content, so
+// their string values are logged verbatim under every redaction mode.
+var structuralKeys = map[string]struct{}{
+	"model":         {},
+	"user":          {},
+	"user_id":       {},
+	"role":          {},
+	"type":          {},
+	"object":        {},
+	"id":            {},
+	"name":          {},
+	"tool_call_id":  {},
+	"call_id":       {},
+	"finish_reason": {},
+	"stop_reason":   {},
+	"status":        {},
+	"code":          {},
+	"event":         {},
+	"mime_type":     {},
+	"mimeType":      {},
+	"tool_choice":   {},
+}
+
+// redactValue replaces every non-structural string in v. Numbers, booleans,
+// keys and nesting survive, so the logged shape still shows message counts,
+// roles and parameters.
+func redactValue(v any, key, mode string) any {
+	switch x := v.(type) {
+	case map[string]any:
+		out := make(map[string]any, len(x))
+		for k, item := range x {
+			out[k] = redactValue(item, k, mode)
+		}
+		return out
+	case []any:
+		out := make([]any, len(x))
+		for i, item := range x {
+			out[i] = redactValue(item, key, mode)
+		}
+		return out
+	case string:
+		if _, ok := structuralKeys[key]; ok {
+			return x
+		}
+		return redactString(x, mode)
+	default:
+		return v
+	}
+}
+
+func redactString(s, mode string) string {
+	if s == "" {
+		return s
+	}
+	if mode == config.RequestLogRedactionOmit {
+		return fmt.Sprintf("[omitted %d bytes]", len(s))
+	}
+	sum := sha256.Sum256([]byte(s))
+	return "sha256:" + hex.EncodeToString(sum[:8])
+}
+
+func redactRequestBody(raw []byte, parsed bool, req map[string]any, mode string) string {
+	if mode == config.RequestLogRedactionNone {
+		return string(raw)
+	}
+	if !parsed {
+		return redactString(string(raw), mode)
+	}
+	return marshalRedacted(redactValue(req, "", mode))
+}
+
+// redactResponseBody handles JSON bodies as well as SSE and NDJSON streams,
+// redacting each event payload while keeping event names and [DONE] markers.
+func redactResponseBody(raw []byte, contentType, mode string) string {
+	if mode == config.RequestLogRedactionNone || len(raw) == 0 {
+		return string(raw)
+	}
+	contentType = strings.ToLower(contentType)
+	switch {
+	case strings.HasPrefix(contentType, "text/event-stream"):
+		return redactLines(raw, mode, "data:")
+	case strings.HasPrefix(contentType, "application/x-ndjson"):
+		return redactLines(raw, mode, "")
+	}
+	var v any
+	if err := json.Unmarshal(raw, &v); err != nil {
+		return redactString(string(raw), mode)
+	}
+	return marshalRedacted(redactValue(v, "", mode))
+}
+
+func redactLines(raw []byte, mode, prefix string) string {
+	var out strings.Builder
+	scanner := bufio.NewScanner(bytes.NewReader(raw))
+	scanner.Buffer(make([]byte, 0, 64*1024), maxLoggedBodyBytes)
+	head := prefix
+	if prefix != "" {
+		head += " "
+	}
+	for scanner.Scan() {
+		line := scanner.Text()
+		if !strings.HasPrefix(line, prefix) {
+			out.WriteString(line)
+			out.WriteByte('\n')
+			continue
+		}
+		payload := strings.TrimSpace(strings.TrimPrefix(line, prefix))
+		var v any
+		switch {
+		case payload == "" || payload == "[DONE]":
+			out.WriteString(line)
+		case json.Unmarshal([]byte(payload), &v) == nil:
+			out.WriteString(head + marshalRedacted(redactValue(v, "", mode)))
+		default:
+			// A payload cut by the capture limit is no longer valid JSON.
+			out.WriteString(head + redactString(payload, mode))
+		}
+		out.WriteByte('\n')
+	}
+	return out.String()
+}
+
+func marshalRedacted(v any) string {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return ""
+	}
+	return string(b)
+}
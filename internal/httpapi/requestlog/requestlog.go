@@ -0,0 +1,180 @@
+package requestlog
+
+import (
+	"bytes"
+	"math/rand/v2"
+	"net/http"
+	"time"
+	"unicode/utf8"
+
+	"github.com/go-chi/chi/v5/middleware"
+
+	"ds2api/internal/config"
+	"ds2api/internal/httpapi/bodyreplay"
+	"ds2api/internal/util"
+)
+
+// maxLoggedBodyBytes caps how much of each request and response body a log
+// line carries; longer bodies are cut and flagged as truncated.
+const maxLoggedBodyBytes = 64 << 10
+
+// sampleFloat draws the body sampling decision; tests replace it.
+var sampleFloat = rand.Float64
+
+// samplingParams are the request fields copied into the request log line.
+var samplingParams = []string{
+	"temperature",
+	"top_p",
+	"max_tokens",
+	"max_completion_tokens",
+	"max_output_tokens",
+	"presence_penalty",
+	"frequency_penalty",
+	"n",
+	"seed",
+	"stop",
+	"tool_choice",
+}
+
+// Middleware writes two log lines per API request, both keyed by trace_id:
+// request metadata when it arrives and the response status once the handler
+// returns. Full bodies ride on the response line for failed requests and for
+// the sampled fraction of successful ones, redacted per settings.Redaction.
+// Settings are read per request so hot-reloaded config applies at once.
+func Middleware(settings func() config.RequestLogConfig) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if settings == nil || !bodyreplay.Eligible(r) {
+				next.ServeHTTP(w, r)
+				return
+			}
+			cfg := settings()
+			if cfg.Enabled == nil || !*cfg.Enabled {
+				next.ServeHTTP(w, r)
+				return
+			}
+			traceID := middleware.GetReqID(r.Context())
+			r, body := bodyreplay.Capture(r)
+			raw, readErr, req := body.Raw, body.Err, body.JSON()
+			config.Logger.Info("[request_log] request", requestAttrs(traceID, r, req)...)
+
+			capture := &cappedBuffer{limit: maxLoggedBodyBytes}
+			ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+			ww.Tee(capture)
+			started := time.Now()
+			next.ServeHTTP(ww, r)
+
+			status := ww.Status()
+			if status == 0 {
+				status = http.StatusOK
+			}
+			attrs := []any{
+				"trace_id", traceID,
+				"status", status,
+				"duration", time.Since(started),
+				"bytes", ww.BytesWritten(),
+				"content_type", ww.Header().Get("Content-Type"),
+			}
+			failed := status >= http.StatusBadRequest
+			if failed || (cfg.BodySampleRate > 0 && sampleFloat() < cfg.BodySampleRate) {
+				requestBody := "[unreadable]"
+				if readErr == nil {
+					requestBody = redactRequestBody(raw, req != nil, req, cfg.Redaction)
+				}
+				attrs = append(attrs,
+					"redaction", cfg.Redaction,
+					"request_body", truncate(requestBody),
+					"response_body", truncate(redactResponseBody(capture.Bytes(), ww.Header().Get("Content-Type"), cfg.Redaction)),
+					"response_truncated", capture.truncated,
+				)
+			}
+			if failed {
+				config.Logger.Warn("[request_log] response", attrs...)
+				return
+			}
+			config.Logger.Info("[request_log] response", attrs...)
+		})
+	}
+}
+
+func requestAttrs(traceID string, r *http.Request, req map[string]any) []any {
+	attrs := []any{"trace_id", traceID, "method", r.Method, "path", r.URL.Path}
+	if req == nil {
+		return attrs
+	}
+	if model, ok := req["model"].(string); ok {
+		attrs = append(attrs, "model", model)
+	}
+	if stream, ok := req["stream"].(bool); ok {
+		attrs = append(attrs, "stream", stream)
+	}
+	if user := bodyreplay.User(req); user != "" {
+		attrs = append(attrs, "user", user)
+	}
+	for _, key := range []string{"messages", "input", "contents"} {
+		if items, ok := req[key].([]any); ok {
+			attrs = append(attrs, "message_count", len(items))
+			break
+		}
+	}
+	if tools, ok := req["tools"].([]any); ok {
+		attrs = append(attrs, "tool_count", len(tools))
+	}
+	params := map[string]any{}
+	for _, key := range samplingParams {
+		if v, ok := req[key]; ok {
+			params[key] = v
+		}
+	}
+	if len(params) > 0 {
+		attrs = append(attrs, "params", params)
+	}
+	return attrs
+}
+
+func truncate(s string) string {
+	if cut, ok := util.TruncateUTF8Bytes(s, maxLoggedBodyBytes); ok {
+		return cut + "...[truncated]"
+	}
+	return s
+}
+
+// cappedBuffer keeps the first limit bytes written to it and drops the rest,
+// so streaming responses cannot grow the capture without bound.
+type cappedBuffer struct {
+	buf       bytes.Buffer
+	limit     int
+	truncated bool
+}
+
+func (b *cappedBuffer) Write(p []byte) (int, error) {
+	if room := b.limit - b.buf.Len(); room > 0 {
+		if len(p) > room {
+			b.buf.Write(p[:room])
+			b.truncated = true
+		} else {
+			b.buf.Write(p)
+		}
+	} else if len(p) > 0 {
+		b.truncated = true
+	}
+	return len(p), nil
+}
+
+// Bytes returns the capture; when the cap cut through a multi-byte character
+// its leading bytes are dropped so the logged body stays valid UTF-8.
+func (b *cappedBuffer) Bytes() []byte {
+	raw := b.buf.Bytes()
+	if !b.truncated {
+		return raw
+	}
+	for i := len(raw) - 1; i >= 0 && i >= len(raw)-utf8.UTFMax; i-- {
+		if utf8.RuneStart(raw[i]) {
+			if !utf8.FullRune(raw[i:]) {
+				raw = raw[:i]
+			}
+			break
+		}
+	}
+	return raw
+}
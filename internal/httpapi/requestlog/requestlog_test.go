@@ -0,0 +1,170 @@
+package requestlog
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/go-chi/chi/v5/middleware"
+
+	"ds2api/internal/config"
+)
+
+func captureLogs(t *testing.T) *bytes.Buffer {
+	t.Helper()
+	var buf bytes.Buffer
+	prev := config.Logger
+	config.Logger = slog.New(slog.NewJSONHandler(&buf, nil))
+	t.Cleanup(func() { config.Logger = prev })
+	return &buf
+}
+
+func logLines(t *testing.T, buf *bytes.Buffer) []map[string]any {
+	t.Helper()
+	var out []map[string]any
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		if line == "" {
+			continue
+		}
+		var obj map[string]any
+		if err := json.Unmarshal([]byte(line), &obj); err != nil {
+			t.Fatalf("log line is not JSON: %q", line)
+		}
+		out = append(out, obj)
+	}
+	return out
+}
+
+func serveLogged(cfg config.RequestLogConfig, status int, respBody string, reqBody string) *httptest.ResponseRecorder {
+	var seenBody string
+	handler := middleware.RequestID(Middleware(func() config.RequestLogConfig { return cfg })(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		seenBody = string(b)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		_, _ = w.Write([]byte(respBody))
+	})))
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/v1/chat/completions", strings.NewReader(reqBody)))
+	rec.Header().Set("X-Seen-Body", seenBody)
+	return rec
+}
+
+func enabled(redaction string, rate float64) config.RequestLogConfig {
+	on := true
+	return config.RequestLogConfig{Enabled: &on, Redaction: redaction, BodySampleRate: rate}
+}
+
+const chatBody = `{"model":"deepseek-v4-flash","stream":false,"temperature":0.3,"messages":[{"role":"system","content":"secret system"},{"role":"user","content":"secret question"}]}`
+
+func TestMiddlewareLogsMetadataAndHashesSampledBodies(t *testing.T) {
+	buf := captureLogs(t)
+	rec := serveLogged(enabled(config.RequestLogRedactionHash, 1), http.StatusOK, `{"choices":[{"message":{"role":"assistant","content":"secret answer"},"finish_reason":"stop"}]}`, chatBody)
+	if rec.Header().Get("X-Seen-Body") != chatBody {
+		t.Fatalf("handler did not receive the original body: %q", rec.Header().Get("X-Seen-Body"))
+	}
+	lines := logLines(t, buf)
+	if len(lines) != 2 {
+		t.Fatalf("expected a request and a response line, got %#v", lines)
+	}
+	req, resp := lines[0], lines[1]
+	if req["msg"] != "[request_log] request" || req["model"] != "deepseek-v4-flash" || req["message_count"] != float64(2) {
+		t.Fatalf("unexpected request line: %#v", req)
+	}
+	if params, _ := req["params"].(map[string]any); params["temperature"] != 0.3 {
+		t.Fatalf("expected sampling params in request line, got %#v", req)
+	}
+	if req["trace_id"] == "" || req["trace_id"] != resp["trace_id"] {
+		t.Fatalf("expected a shared trace_id, got %v and %v", req["trace_id"], resp["trace_id"])
+	}
+	if strings.Contains(buf.String(), "secret") {
+		t.Fatalf("expected content redacted, got %s", buf.String())
+	}
+	requestBody, _ := resp["request_body"].(string)
+	if !strings.Contains(requestBody, `"role":"user"`) || !strings.Contains(requestBody, "sha256:") {
+		t.Fatalf("expected structure kept and content hashed, got %q", requestBody)
+	}
+	if responseBody, _ := resp["response_body"].(string); !strings.Contains(responseBody, `"finish_reason":"stop"`) {
+		t.Fatalf("expected response structure kept, got %q", responseBody)
+	}
+}
+
+func TestMiddlewareSkipsSuccessBodiesOutsideSampleButAlwaysLogsErrors(t *testing.T) {
+	buf := captureLogs(t)
+	serveLogged(enabled(config.RequestLogRedactionOmit, 0), http.StatusOK, `{"ok":true}`, chatBody)
+	if resp := logLines(t, buf)[1]; resp["request_body"] != nil || resp["response_body"] != nil {
+		t.Fatalf("expected unsampled success without bodies, got %#v", resp)
+	}
+
+	buf.Reset()
+	serveLogged(enabled(config.RequestLogRedactionOmit, 0), http.StatusBadRequest, `{"error":{"message":"bad","code":"invalid_request"}}`, chatBody)
+	resp := logLines(t, buf)[1]
+	if resp["level"] != "WARN" || resp["status"] != float64(http.StatusBadRequest) {
+		t.Fatalf("expected warn line for failed request, got %#v", resp)
+	}
+	if body, _ := resp["request_body"].(string); !strings.Contains(body, "[omitted 15 bytes]") || strings.Contains(body, "secret") {
+		t.Fatalf("expected omitted content in error body, got %q", body)
+	}
+	if body, _ := resp["response_body"].(string); !strings.Contains(body, `"code":"invalid_request"`) {
+		t.Fatalf("expected error response body, got %q", body)
+	}
+}
+
+func TestMiddlewareLogsEndUserVerbatim(t *testing.T) {
+	buf := captureLogs(t)
+	body := `{"model":"deepseek-v4-flash","user":"user-42","messages":[{"role":"user","content":"secret question"}]}`
+	serveLogged(enabled(config.RequestLogRedactionOmit, 0), http.StatusBadRequest, `{}`, body)
+	lines := logLines(t, buf)
+	if lines[0]["user"] != "user-42" {
+		t.Fatalf("expected the user field on the request line, got %#v", lines[0])
+	}
+	if reqBody, _ := lines[1]["request_body"].(string); !strings.Contains(reqBody, `"user":"user-42"`) || strings.Contains(reqBody, "secret") {
+		t.Fatalf("expected the user kept and content omitted, got %q", reqBody)
+	}
+
+	buf.Reset()
+	serveLogged(enabled(config.RequestLogRedactionHash, 0), http.StatusOK, `{}`, `{"model":"claude-sonnet-4-5","metadata":{"user_id":"abc"},"messages":[]}`)
+	if got := logLines(t, buf)[0]["user"]; got != "abc" {
+		t.Fatalf("expected metadata.user_id as the user, got %#v", got)
+	}
+}
+
+func TestMiddlewareDisabledPassesThrough(t *testing.T) {
+	buf := captureLogs(t)
+	off := false
+	rec := serveLogged(config.RequestLogConfig{Enabled: &off}, http.StatusOK, `{}`, chatBody)
+	if buf.Len() != 0 || rec.Header().Get("X-Seen-Body") != chatBody {
+		t.Fatalf("expected no log lines and untouched body, got %q", buf.String())
+	}
+}
+
+func TestRedactResponseBodyHandlesEventStreams(t *testing.T) {
+	raw := "event: message\ndata: {\"choices\":[{\"delta\":{\"content\":\"hi\"}}]}\n\ndata: [DONE]\n"
+	got := redactResponseBody([]byte(raw), "text/event-stream", config.RequestLogRedactionHash)
+	if strings.Contains(got, `"hi"`) || !strings.Contains(got, "event: message") || !strings.Contains(got, "data: [DONE]") || !strings.Contains(got, `"delta":{"content":"sha256:`) {
+		t.Fatalf("unexpected redacted stream: %q", got)
+	}
+	if plain := redactResponseBody([]byte(raw), "text/event-stream", config.RequestLogRedactionNone); plain != raw {
+		t.Fatalf("expected verbatim body with redaction none, got %q", plain)
+	}
+}
+
+func TestCapturedBodiesStayValidUTF8AtTheCap(t *testing.T) {
+	for pad := 0; pad < 4; pad++ {
+		body := strings.Repeat("a", maxLoggedBodyBytes-4+pad) + strings.Repeat("😀中", 8)
+		capture := &cappedBuffer{limit: maxLoggedBodyBytes}
+		_, _ = capture.Write([]byte(body))
+		if got := capture.Bytes(); !utf8.Valid(got) || !strings.HasPrefix(body, string(got)) || len(got) < maxLoggedBodyBytes-utf8.UTFMax {
+			t.Fatalf("pad %d: capture is not a valid prefix (len %d)", pad, len(got))
+		}
+		if got := truncate(body); !utf8.ValidString(got) || !strings.HasSuffix(got, "...[truncated]") {
+			t.Fatalf("pad %d: truncated body is not valid UTF-8", pad)
+		}
+	}
+}
@@ -0,0 +1,135 @@
+'use strict';
+
+// Mirrors sse.BannedWordFilter. The Go prepare step decodes strongly negative
+// `logit_bias` entries into banned_words; Latin words match whole words
+// case-insensitively and any other entry matches as an exact substring.
+const WORD_RUNE = /[\p{Script=Latin}\p{Nd}_]/u;
+
+function isWordChar(ch) {
+  return WORD_RUNE.test(ch);
+}
+
+function normalizeBannedWords(raw) {
+  const words = [];
+  const phrases = [];
+  for (const item of Array.isArray(raw) ? raw : []) {
+    const entry = typeof item === 'string' ? item.trim() : '';
+    if (!entry) {
+      continue;
+    }
+    if (Array.from(entry).every(isWordChar)) {
+      words.push(entry.toLowerCase());
+    } else {
+      phrases.push(entry);
+    }
+  }
+  return { words, phrases };
+}
+
+function trailingWordRunLen(text) {
+  const chars = Array.from(text);
+  let n = 0;
+  for (let i = chars.length - 1; i >= 0 && isWordChar(chars[i]); i -= 1) {
+    n += chars[i].length;
+  }
+  return n;
+}
+
+function filterText(text, banned) {
+  let removed = false;
+  for (const p of banned.phrases) {
+    if (text.includes(p)) {
+      text = text.split(p).join('');
+      removed = true;
+    }
+  }
+  if (banned.words.length === 0) {
+    return { text, removed };
+  }
+  let out = '';
+  const chars = Array.from(text);
+  for (let i = 0; i < chars.length;) {
+    if (!isWordChar(chars[i])) {
+      out += chars[i];
+      i += 1;
+      continue;
+    }
+    let word = '';
+    while (i < chars.length && isWordChar(chars[i])) {
+      word += chars[i];
+      i += 1;
+    }
+    if (banned.words.includes(word.toLowerCase())) {
+      removed = true;
+      if (out.endsWith(' ')) {
+        out = out.slice(0, -1);
+      }
+    } else {
+      out += word;
+    }
+  }
+  return { text: out, removed };
+}
+
+function holdLen(text, banned) {
+  let keep = banned.words.length > 0 ? trailingWordRunLen(text) : 0;
+  for (const p of banned.phrases) {
+    for (let n = Math.min(p.length - 1, text.length); n > keep; n -= 1) {
+      if (text.endsWith(p.slice(0, n))) {
+        keep = n;
+        break;
+      }
+    }
+  }
+  let cut = text.length - keep;
+  if (cut > 0 && cut < text.length && isWordChar(text[cut - 1]) && isWordChar(text[cut])) {
+    cut -= trailingWordRunLen(text.slice(0, cut));
+  }
+  if (banned.words.length > 0 && cut > 0 && text[cut - 1] === ' ') {
+    cut -= 1;
+  }
+  return text.length - cut;
+}
+
+function createBannedWordFilter(raw) {
+  const banned = normalizeBannedWords(raw);
+  const enabled = banned.words.length > 0 || banned.phrases.length > 0;
+  const state = { held: '', removed: false };
+  const apply = (text) => {
+    const result = filterText(text, banned);
+    state.removed = state.removed || result.removed;
+    return result.text;
+  };
+  return {
+    get held() {
+      return state.held;
+    },
+    get removed() {
+      return state.removed;
+    },
+    push(text) {
+      if (!enabled) {
+        return text;
+      }
+      if (!text) {
+        return '';
+      }
+      const combined = state.held + text;
+      const keep = holdLen(combined, banned);
+      state.held = combined.slice(combined.length - keep);
+      return apply(combined.slice(0, combined.length - keep));
+    },
+    flush() {
+      if (!enabled || !state.held) {
+        return '';
+      }
+      const out = apply(state.held);
+      state.held = '';
+      return out;
+    },
+  };
+}
+
+module.exports = {
+  createBannedWordFilter,
+};
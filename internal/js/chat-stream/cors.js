@@ -1,79 +1,42 @@
 'use strict';
 
-const DEFAULT_CORS_ALLOW_HEADERS = [
-  'Content-Type',
-  'Authorization',
-  'X-API-Key',
-  'X-Ds2-Target-Account',
-  'X-Ds2-Source',
-  'X-Vercel-Protection-Bypass',
-  'X-Goog-Api-Key',
-  'Anthropic-Version',
-  'Anthropic-Beta',
+// CORS is decided by the Go router from the `cors` config. The Node stream
+// function forwards the browser's CORS request headers on its internal calls
+// to Go and copies the Access-Control-* headers Go answers with.
+const CORS_REQUEST_HEADERS = [
+  'origin',
+  'access-control-request-headers',
+  'access-control-request-method',
+  'access-control-request-private-network',
 ];
 
-const BLOCKED_CORS_REQUEST_HEADERS = new Set([
-  'x-ds2-internal-token',
-]);
-
-function setCorsHeaders(res, req) {
-  const origin = asString(readHeader(req, 'origin'));
-  res.setHeader('Access-Control-Allow-Origin', origin || '*');
-  if (origin) {
-    addVaryHeader(res, 'Origin');
-  }
-  res.setHeader('Access-Control-Allow-Methods', 'GET, POST, OPTIONS, PUT, DELETE');
-  res.setHeader('Access-Control-Max-Age', '600');
-  res.setHeader(
-    'Access-Control-Allow-Headers',
-    buildCORSAllowHeaders(req),
-  );
-  addVaryHeader(res, 'Access-Control-Request-Headers');
-  if (asString(readHeader(req, 'access-control-request-private-network')).toLowerCase() === 'true') {
-    res.setHeader('Access-Control-Allow-Private-Network', 'true');
-    addVaryHeader(res, 'Access-Control-Request-Private-Network');
-  }
-}
-
-function buildCORSAllowHeaders(req) {
-  const seen = new Set();
-  const headers = [];
-  for (const name of DEFAULT_CORS_ALLOW_HEADERS) {
-    appendCORSHeaderName(headers, seen, name);
-  }
-  for (const name of splitCORSRequestHeaders(readHeader(req, 'access-control-request-headers'))) {
-    appendCORSHeaderName(headers, seen, name);
-  }
-  return headers.join(', ');
-}
-
-function splitCORSRequestHeaders(raw) {
-  const text = asString(raw);
-  if (!text) {
-    return [];
+function corsRequestHeaders(req) {
+  const out = {};
+  for (const key of CORS_REQUEST_HEADERS) {
+    const value = asString(readHeader(req, key));
+    if (value) {
+      out[key] = value;
+    }
   }
-  return text
-    .split(',')
-    .map((part) => asString(part))
-    .filter((name) => isValidCORSHeaderToken(name))
-    .filter((name) => !BLOCKED_CORS_REQUEST_HEADERS.has(name.toLowerCase()));
+  return out;
 }
 
-function appendCORSHeaderName(headers, seen, name) {
-  const text = asString(name);
-  if (!isValidCORSHeaderToken(text)) {
-    return;
-  }
-  const lower = text.toLowerCase();
-  if (BLOCKED_CORS_REQUEST_HEADERS.has(lower) || seen.has(lower)) {
+function copyCorsHeaders(res, headers) {
+  if (!headers || typeof headers.forEach !== 'function' || typeof res.setHeader !== 'function') {
     return;
   }
-  seen.add(lower);
-  headers.push(text);
-}
-
-function isValidCORSHeaderToken(name) {
-  return /^[A-Za-z0-9!#$%&'*+.^_`|~-]+$/.test(asString(name));
+  headers.forEach((value, key) => {
+    const lower = String(key).toLowerCase();
+    if (lower.startsWith('access-control-')) {
+      res.setHeader(lower, value);
+      return;
+    }
+    if (lower === 'vary') {
+      for (const part of String(value).split(',')) {
+        addVaryHeader(res, part);
+      }
+    }
+  });
 }
 
 function addVaryHeader(res, token) {
@@ -132,5 +95,6 @@ function asString(v) {
 }
 
 module.exports = {
-  setCorsHeaders,
+  copyCorsHeaders,
+  corsRequestHeaders,
 };
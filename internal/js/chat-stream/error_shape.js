@@ -4,15 +4,25 @@ function writeOpenAIError(res, status, message) {
   res.statusCode = status;
   res.setHeader('Content-Type', 'application/json');
   res.end(
-    JSON.stringify({
+    JSON.stringify(withTraceID(res, {
       error: {
         message,
         type: openAIErrorType(status),
       },
-    }),
+    })),
   );
 }
 
+// withTraceID mirrors requestctx.WithTraceID: error bodies carry the
+// response's X-Request-Id as request_id.
+function withTraceID(res, body) {
+  const id = res && typeof res.getHeader === 'function' ? res.getHeader('X-Request-Id') : '';
+  if (id) {
+    body.request_id = String(id);
+  }
+  return body;
+}
+
 function openAIErrorType(status) {
   switch (status) {
     case 400:
@@ -33,4 +43,5 @@ function openAIErrorType(status) {
 module.exports = {
   writeOpenAIError,
   openAIErrorType,
+  withTraceID,
 };
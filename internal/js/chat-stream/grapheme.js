@@ -0,0 +1,58 @@
+'use strict';
+
+// splitClusters mirrors util.ClusterStart: the text is split into
+// user-perceived characters so a cut between two entries never separates
+// combining marks, variation selectors, skin-tone modifiers or tag characters
+// from their base, emoji joined by ZWJ, regional-indicator flag pairs or CRLF.
+function splitClusters(text) {
+  const chars = Array.from(typeof text === 'string' ? text : '');
+  const out = [];
+  let riRun = 0;
+  for (let i = 0; i < chars.length; i += 1) {
+    const ch = chars[i];
+    const prev = i > 0 ? chars[i - 1] : '';
+    riRun = isRegionalIndicator(ch) ? riRun + 1 : 0;
+    if (out.length > 0 && !clusterStart(prev, ch, riRun)) {
+      out[out.length - 1] += ch;
+    } else {
+      out.push(ch);
+    }
+  }
+  return out;
+}
+
+function clusterStart(prev, next, riRun) {
+  if (prev === '\r' && next === '\n') {
+    return false;
+  }
+  if (prev === '\r' || prev === '\n' || next === '\r' || next === '\n') {
+    return true;
+  }
+  if (isClusterExtender(next) || prev === '\u200d') {
+    return false;
+  }
+  if (isRegionalIndicator(prev) && isRegionalIndicator(next)) {
+    // riRun counts next as well, so an odd run means next starts a new flag.
+    return riRun % 2 === 1;
+  }
+  return true;
+}
+
+function isClusterExtender(ch) {
+  const cp = ch.codePointAt(0);
+  return cp === 0x200d
+    || (cp >= 0xfe00 && cp <= 0xfe0f)
+    || (cp >= 0xe0100 && cp <= 0xe01ef)
+    || (cp >= 0x1f3fb && cp <= 0x1f3ff)
+    || (cp >= 0xe0020 && cp <= 0xe007f)
+    || /\p{M}/u.test(ch);
+}
+
+function isRegionalIndicator(ch) {
+  const cp = ch ? ch.codePointAt(0) : 0;
+  return cp >= 0x1f1e6 && cp <= 0x1f1ff;
+}
+
+module.exports = {
+  splitClusters,
+};
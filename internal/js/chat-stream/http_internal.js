@@ -4,7 +4,8 @@ const {
   writeOpenAIError,
 } = require('./error_shape');
 const {
-  setCorsHeaders,
+  copyCorsHeaders,
+  corsRequestHeaders,
 } = require('./cors');
 
 function header(req, key) {
@@ -53,6 +54,7 @@ async function fetchStreamPrepare(req, rawBody) {
     ok: upstream.ok,
     status: upstream.status,
     contentType: upstream.headers.get('content-type') || 'application/json',
+    headers: upstream.headers,
     text,
     body,
   };
@@ -121,6 +123,8 @@ function relayPreparedFailure(res, prep) {
     );
     return;
   }
+  copyCorsHeaders(res, prep.headers);
+  adoptTraceID(null, res, prep.headers);
   res.statusCode = prep.status || 500;
   res.setHeader('Content-Type', prep.contentType || 'application/json');
   if (prep.text) {
@@ -164,6 +168,8 @@ function buildInternalGoHeaders(req, opts = {}) {
     'x-api-key': asString(header(req, 'x-api-key')),
     'x-ds2-target-account': asString(header(req, 'x-ds2-target-account')),
     'x-vercel-protection-bypass': resolveProtectionBypass(req),
+    ...corsRequestHeaders(req),
+    ...traceRequestHeaders(req),
   };
   if (opts.withInternalToken) {
     headers['x-ds2-internal-token'] = internalSecret();
@@ -174,6 +180,35 @@ function buildInternalGoHeaders(req, opts = {}) {
   return headers;
 }
 
+// Go adopts an inbound X-Request-ID or traceparent as the trace ID, so both
+// are forwarded on internal calls.
+function traceRequestHeaders(req) {
+  const out = {};
+  for (const key of ['x-request-id', 'traceparent']) {
+    const value = asString(header(req, key));
+    if (value) {
+      out[key] = value;
+    }
+  }
+  return out;
+}
+
+// adoptTraceID keeps the trace ID Go assigned on the prepare call: it is
+// echoed to the client and sent on the later internal calls, so every Go log
+// line for this stream shares it.
+function adoptTraceID(req, res, headers) {
+  const id = headers && typeof headers.get === 'function' ? asString(headers.get('x-request-id')) : '';
+  if (!id) {
+    return;
+  }
+  if (req && req.headers) {
+    req.headers['x-request-id'] = id;
+  }
+  if (typeof res.setHeader === 'function') {
+    res.setHeader('X-Request-Id', id);
+  }
+}
+
 function createLeaseReleaser(req, leaseID) {
   let released = false;
   return async () => {
@@ -245,7 +280,7 @@ function isAbortError(err) {
 }
 
 module.exports = {
-  setCorsHeaders,
+  copyCorsHeaders,
   header,
   readRawBody,
   fetchStreamPrepare,
@@ -255,6 +290,7 @@ module.exports = {
   safeReadText,
   buildInternalGoURL,
   buildInternalGoHeaders,
+  adoptTraceID,
   createLeaseReleaser,
   releaseStreamLease,
   resolveProtectionBypass,
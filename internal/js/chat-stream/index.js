@@ -25,7 +25,6 @@ const {
   buildUsage,
 } = require('./token_usage');
 const {
-  setCorsHeaders,
   readRawBody,
   asString,
 } = require('./http_internal');
@@ -40,10 +39,9 @@ const {
 } = require('./dedupe');
 
 async function handler(req, res) {
-  setCorsHeaders(res, req);
+  // Preflights go to Go, which owns the CORS policy.
   if (req.method === 'OPTIONS') {
-    res.statusCode = 204;
-    res.end();
+    await proxyToGo(req, res, null);
     return;
   }
   if (req.method !== 'POST') {
@@ -70,7 +68,11 @@ async function handler(req, res) {
 
   // Keep all non-stream behavior and non-OpenAI-chat paths on Go side to avoid
   // protocol-shape regressions (e.g. Gemini/Claude clients expecting their own formats).
-  if (!toBool(payload.stream) || !isNodeStreamSupportedPath(req.url || '')) {
+  // `n > 1` fans out several upstream generations, and legacy `functions` /
+  // `function_call` requests answer in the legacy message shape, and JSON-mode
+  // `response_format` is validated when the stream ends; only the Go path does
+  // any of these.
+  if (!toBool(payload.stream) || !isNodeStreamSupportedPath(req.url || '') || Number(payload.n) > 1 || usesLegacyFunctions(payload) || usesJSONResponseFormat(payload)) {
     await proxyToGo(req, res, rawBody);
     return;
   }
@@ -82,6 +84,22 @@ function toBool(v) {
   return v === true;
 }
 
+function usesLegacyFunctions(payload) {
+  if (payload.tools != null || payload.tool_choice != null) {
+    return false;
+  }
+  return payload.functions != null || payload.function_call != null;
+}
+
+function usesJSONResponseFormat(payload) {
+  const format = payload.response_format;
+  if (!format || typeof format !== 'object') {
+    return false;
+  }
+  const type = asString(format.type).toLowerCase();
+  return type === 'json_object' || type === 'json_schema';
+}
+
 function isVercelRuntime() {
   return asString(process.env.VERCEL) !== '' || asString(process.env.NOW_REGION) !== '';
 }
@@ -123,6 +141,8 @@ module.exports.__test = {
   hasContentFilterStatus,
   extractAccumulatedTokenUsage,
   isNodeStreamSupportedPath,
+  usesLegacyFunctions,
+  usesJSONResponseFormat,
   extractPathname,
   trimContinuationOverlap,
 };
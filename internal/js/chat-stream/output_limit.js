@@ -0,0 +1,67 @@
+'use strict';
+
+const { splitClusters } = require('./grapheme');
+const { estimateTokens } = require('./token_usage');
+
+// resolveMaxOutputTokens mirrors promptcompat.ParseMaxOutputTokens:
+// max_completion_tokens wins over max_tokens. Invalid values were already
+// rejected by the Go prepare step.
+function resolveMaxOutputTokens(payload) {
+  const body = payload && typeof payload === 'object' ? payload : {};
+  for (const key of ['max_completion_tokens', 'max_tokens']) {
+    const n = Number(body[key]);
+    if (body[key] != null && Number.isInteger(n) && n > 0) {
+      return n;
+    }
+  }
+  return 0;
+}
+
+// createOutputTokenLimiter mirrors sse.OutputTokenLimiter: each chunk is
+// counted as it arrives and the chunk that crosses the budget is cut at the
+// last character that still fits, never inside an emoji or combining
+// sequence.
+function createOutputTokenLimiter(limit) {
+  const state = { used: 0, reached: false };
+  return {
+    get reached() {
+      return state.reached;
+    },
+    push(text) {
+      if (!(limit > 0)) {
+        return text;
+      }
+      if (state.reached || !text) {
+        return '';
+      }
+      const n = estimateTokens(text);
+      if (state.used + n <= limit) {
+        state.used += n;
+        return text;
+      }
+      state.reached = true;
+      const remaining = limit - state.used;
+      state.used = limit;
+      if (remaining <= 0) {
+        return '';
+      }
+      const chars = splitClusters(text);
+      let lo = 0;
+      let hi = chars.length - 1;
+      while (lo < hi) {
+        const mid = Math.floor((lo + hi + 1) / 2);
+        if (estimateTokens(chars.slice(0, mid).join('')) <= remaining) {
+          lo = mid;
+        } else {
+          hi = mid - 1;
+        }
+      }
+      return chars.slice(0, lo).join('');
+    },
+  };
+}
+
+module.exports = {
+  resolveMaxOutputTokens,
+  createOutputTokenLimiter,
+};
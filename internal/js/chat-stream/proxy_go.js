@@ -29,10 +29,11 @@ async function proxyToGo(req, res, rawBody) {
   try {
     let upstream;
     try {
+      const preflight = req.method === 'OPTIONS';
       upstream = await fetch(url.toString(), {
-        method: 'POST',
-        headers: buildInternalGoHeaders(req, { withContentType: true }),
-        body: rawBody,
+        method: preflight ? 'OPTIONS' : 'POST',
+        headers: buildInternalGoHeaders(req, { withContentType: !preflight }),
+        body: preflight ? undefined : rawBody,
         signal: controller.signal,
       });
     } catch (err) {
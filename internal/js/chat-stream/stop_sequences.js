@@ -0,0 +1,79 @@
+'use strict';
+
+const MAX_STOP_SEQUENCES = 4;
+
+// normalizeStopSequences mirrors promptcompat.ParseStopSequences. Invalid
+// values were already rejected by the Go prepare step, so this only filters.
+function normalizeStopSequences(raw) {
+  const items = typeof raw === 'string' ? [raw] : Array.isArray(raw) ? raw : [];
+  return items.filter((s) => typeof s === 'string' && s !== '').slice(0, MAX_STOP_SEQUENCES);
+}
+
+function indexStopSequence(text, stops) {
+  let best = -1;
+  for (const stop of stops) {
+    const idx = text.indexOf(stop);
+    if (idx >= 0 && (best < 0 || idx < best)) {
+      best = idx;
+    }
+  }
+  return best;
+}
+
+// createStopSequenceMatcher mirrors sse.StopSequenceMatcher: text that could
+// still grow into a stop string is held back until the next chunk.
+function createStopSequenceMatcher(raw) {
+  const stops = normalizeStopSequences(raw);
+  const state = { held: '', matched: false };
+  const partialSuffixLen = (text) => {
+    let longest = 0;
+    for (const stop of stops) {
+      for (let n = Math.min(stop.length - 1, text.length); n > longest; n -= 1) {
+        if (text.endsWith(stop.slice(0, n))) {
+          longest = n;
+          break;
+        }
+      }
+    }
+    return longest;
+  };
+  return {
+    get held() {
+      return state.held;
+    },
+    get matched() {
+      return state.matched;
+    },
+    push(text) {
+      if (stops.length === 0) {
+        return text;
+      }
+      if (state.matched || !text) {
+        return '';
+      }
+      const combined = state.held + text;
+      const idx = indexStopSequence(combined, stops);
+      if (idx >= 0) {
+        state.held = '';
+        state.matched = true;
+        return combined.slice(0, idx);
+      }
+      const keep = partialSuffixLen(combined);
+      state.held = combined.slice(combined.length - keep);
+      return combined.slice(0, combined.length - keep);
+    },
+    flush() {
+      if (state.matched) {
+        return '';
+      }
+      const out = state.held;
+      state.held = '';
+      return out;
+    },
+  };
+}
+
+module.exports = {
+  normalizeStopSequences,
+  createStopSequenceMatcher,
+};
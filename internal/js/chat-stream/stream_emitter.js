@@ -3,7 +3,7 @@
 const MIN_DELTA_FLUSH_CHARS = 16;
 const MAX_DELTA_FLUSH_WAIT_MS = 20;
 
-function createChatCompletionEmitter({ res, sessionID, created, model, isClosed }) {
+function createChatCompletionEmitter({ res, sessionID, created, model, systemFingerprint, includeUsage, isClosed }) {
   let firstChunkSent = false;
 
   const sendFrame = (obj) => {
@@ -27,7 +27,10 @@ function createChatCompletionEmitter({ res, sessionID, created, model, isClosed
       object: 'chat.completion.chunk',
       created,
       model,
+      ...(systemFingerprint ? { system_fingerprint: systemFingerprint } : {}),
       choices: [{ delta: payloadDelta, index: 0 }],
+      // With include_usage only the final chunk carries usage; the rest send null.
+      ...(includeUsage ? { usage: null } : {}),
     });
   };
 
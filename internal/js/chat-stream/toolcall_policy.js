@@ -12,10 +12,13 @@ function resolveToolcallPolicy(prepBody, payloadTools) {
   if (toolNames.length === 0 && Array.isArray(payloadTools) && payloadTools.length > 0) {
     toolNames = ['__any_tool__'];
   }
+  const toolCallFormat = asString(prepBody && prepBody.tool_call_format) || 'dsml';
   return {
     toolNames,
     toolSieveEnabled: toolNames.length > 0,
     emitEarlyToolDeltas: true,
+    // The Node sieve only reads DSML markup; other formats stream from Go.
+    nodeSieveSupported: toolCallFormat === 'dsml',
   };
 }
 
@@ -10,7 +10,7 @@ const {
   formatOpenAIStreamToolCalls,
 } = require('../helpers/stream-tool-sieve');
 const { BASE_HEADERS } = require('../shared/deepseek-constants');
-const { writeOpenAIError, openAIErrorType } = require('./error_shape');
+const { writeOpenAIError, openAIErrorType, withTraceID } = require('./error_shape');
 const { parseChunkForContent, isCitation } = require('./sse_parse');
 const { buildUsage } = require('./token_usage');
 const {
@@ -27,11 +27,17 @@ const {
   fetchStreamPow,
   fetchStreamSwitch,
   relayPreparedFailure,
+  adoptTraceID,
   createLeaseReleaser,
+  copyCorsHeaders,
 } = require('./http_internal');
 const {
   trimContinuationOverlap,
 } = require('./dedupe');
+const { createStopSequenceMatcher } = require('./stop_sequences');
+const { resolveMaxOutputTokens, createOutputTokenLimiter } = require('./output_limit');
+const { createBannedWordFilter } = require('./banned_words');
+const { proxyToGo } = require('./proxy_go');
 
 const DEEPSEEK_COMPLETION_URL = 'https://chat.deepseek.com/api/v0/chat/completion';
 const DEEPSEEK_CONTINUE_URL = 'https://chat.deepseek.com/api/v0/chat/continue';
@@ -45,8 +51,12 @@ async function handleVercelStream(req, res, rawBody, payload) {
     relayPreparedFailure(res, prep);
     return;
   }
+  copyCorsHeaders(res, prep.headers);
+  adoptTraceID(req, res, prep.headers);
 
   const model = asString(prep.body.model) || asString(payload.model);
+  const systemFingerprint = asString(prep.body.system_fingerprint);
+  const fingerprintField = systemFingerprint ? { system_fingerprint: systemFingerprint } : {};
   const responseID = asString(prep.body.session_id) || `chatcmpl-${Date.now()}`;
   const leaseID = asString(prep.body.lease_id);
   let deepseekToken = asString(prep.body.deepseek_token);
@@ -55,6 +65,7 @@ async function handleVercelStream(req, res, rawBody, payload) {
   const finalPrompt = asString(prep.body.final_prompt);
   const thinkingEnabled = toBool(prep.body.thinking_enabled);
   const searchEnabled = toBool(prep.body.search_enabled);
+  const includeUsage = toBool(payload.stream_options && payload.stream_options.include_usage);
   const toolPolicy = resolveToolcallPolicy(prep.body, payload.tools);
   const toolNames = toolPolicy.toolNames;
   const emitEarlyToolDeltas = toolPolicy.emitEarlyToolDeltas;
@@ -66,6 +77,11 @@ async function handleVercelStream(req, res, rawBody, payload) {
   }
 
   const releaseLease = createLeaseReleaser(req, leaseID);
+  if (toolPolicy.toolSieveEnabled && !toolPolicy.nodeSieveSupported) {
+    await releaseLease();
+    await proxyToGo(req, res, rawBody);
+    return;
+  }
   const upstreamController = new AbortController();
   let clientClosed = false;
   let reader = null;
@@ -167,6 +183,10 @@ async function handleVercelStream(req, res, rawBody, payload) {
     res.setHeader('Cache-Control', 'no-cache, no-transform');
     res.setHeader('Connection', 'keep-alive');
     res.setHeader('X-Accel-Buffering', 'no');
+    const contextTrimmed = Number(prep.body.context_trimmed) || 0;
+    if (contextTrimmed > 0) {
+      res.setHeader('X-Ds2api-Context-Trimmed', String(contextTrimmed));
+    }
     if (typeof res.flushHeaders === 'function') {
       res.flushHeaders();
     }
@@ -190,9 +210,52 @@ async function handleVercelStream(req, res, rawBody, payload) {
       sessionID: responseID,
       created,
       model,
+      systemFingerprint,
+      includeUsage,
       isClosed: () => clientClosed,
     });
     const deltaCoalescer = createDeltaCoalescer({ sendDeltaFrame });
+    const stopMatcher = createStopSequenceMatcher(payload.stop);
+    const outputLimiter = createOutputTokenLimiter(resolveMaxOutputTokens(payload));
+    const bannedFilter = createBannedWordFilter(prep.body.banned_words);
+
+    const emitOutputText = (text) => {
+      if (!text) {
+        return;
+      }
+      outputText += text;
+      if (!toolSieveEnabled) {
+        deltaCoalescer.append('content', text);
+        return;
+      }
+      const events = processToolSieveChunk(toolSieveState, text, toolNames);
+      for (const evt of events) {
+        if (evt.type === 'tool_call_deltas') {
+          if (!emitEarlyToolDeltas) {
+            continue;
+          }
+          const filtered = filterIncrementalToolCallDeltasByAllowed(evt.deltas, toolNames, streamToolNames);
+          const formatted = formatIncrementalToolCallDeltas(filtered, streamToolCallIDs);
+          if (formatted.length > 0) {
+            toolCallsEmitted = true;
+            deltaCoalescer.flush();
+            sendDeltaFrame({ tool_calls: formatted });
+          }
+          continue;
+        }
+        if (evt.type === 'tool_calls') {
+          toolCallsEmitted = true;
+          toolCallsDoneEmitted = true;
+          deltaCoalescer.flush();
+          sendDeltaFrame({ tool_calls: formatOpenAIStreamToolCalls(evt.calls, streamToolCallIDs, payload.tools) });
+          resetStreamToolCallState(streamToolCallIDs, streamToolNames);
+          continue;
+        }
+        if (evt.text) {
+          deltaCoalescer.append('content', evt.text);
+        }
+      }
+    };
 
     const finish = async (reason, options = {}) => {
       if (ended) {
@@ -203,6 +266,7 @@ async function handleVercelStream(req, res, rawBody, payload) {
         await releaseLease();
         return true;
       }
+      emitOutputText(outputLimiter.push(bannedFilter.push(stopMatcher.flush()) + bannedFilter.flush()));
       deltaCoalescer.flush();
       const detected = parseStandaloneToolCalls(outputText, toolNames);
       if (detected.length > 0 && !toolCallsDoneEmitted) {
@@ -226,6 +290,9 @@ async function handleVercelStream(req, res, rawBody, payload) {
         }
         deltaCoalescer.flush();
       }
+      if (outputLimiter.reached && reason === 'stop') {
+        reason = 'length';
+      }
       if (detected.length > 0 || toolCallsEmitted) {
         reason = 'tool_calls';
       }
@@ -243,14 +310,20 @@ async function handleVercelStream(req, res, rawBody, payload) {
         return true;
       }
       ended = true;
+      const usage = buildUsage(usagePrompt, thinkingText, outputText);
       sendFrame({
         id: responseID,
         object: 'chat.completion.chunk',
         created,
         model,
+        ...fingerprintField,
         choices: [{ delta: {}, index: 0, finish_reason: reason }],
-        usage: buildUsage(usagePrompt, thinkingText, outputText),
+        // include_usage reports usage only on the trailing empty-choices chunk.
+        usage: includeUsage ? null : usage,
       });
+      if (includeUsage) {
+        sendFrame({ id: responseID, object: 'chat.completion.chunk', created, model, ...fingerprintField, choices: [], usage });
+      }
       if (!res.writableEnded && !res.destroyed) {
         res.write('data: [DONE]\n\n');
       }
@@ -314,7 +387,7 @@ async function handleVercelStream(req, res, rawBody, payload) {
                 return { terminal: await finish('content_filter'), retryable: false };
               }
               if (parsed.contentFilter) {
-                return { terminal: await finish(outputText.trim() === '' ? 'content_filter' : 'stop'), retryable: false };
+                return { terminal: await finish('content_filter'), retryable: false };
               }
               if (parsed.finished) {
                 streamEnded = true;
@@ -335,44 +408,17 @@ async function handleVercelStream(req, res, rawBody, payload) {
                     deltaCoalescer.append('reasoning_content', trimmed);
                   }
                 } else {
-                  const trimmed = trimContinuationOverlap(outputText, p.text);
+                  const trimmed = trimContinuationOverlap(outputText + bannedFilter.held + stopMatcher.held, p.text);
                   if (!trimmed) {
                     continue;
                   }
                   if (searchEnabled && isCitation(trimmed)) {
                     continue;
                   }
-                  outputText += trimmed;
-                  if (!toolSieveEnabled) {
-                    deltaCoalescer.append('content', trimmed);
-                    continue;
-                  }
-                  const events = processToolSieveChunk(toolSieveState, trimmed, toolNames);
-                  for (const evt of events) {
-                    if (evt.type === 'tool_call_deltas') {
-                      if (!emitEarlyToolDeltas) {
-                        continue;
-                      }
-                      const filtered = filterIncrementalToolCallDeltasByAllowed(evt.deltas, toolNames, streamToolNames);
-                      const formatted = formatIncrementalToolCallDeltas(filtered, streamToolCallIDs);
-                      if (formatted.length > 0) {
-                        toolCallsEmitted = true;
-                        deltaCoalescer.flush();
-                        sendDeltaFrame({ tool_calls: formatted });
-                      }
-                      continue;
-                    }
-                    if (evt.type === 'tool_calls') {
-                      toolCallsEmitted = true;
-                      toolCallsDoneEmitted = true;
-                      deltaCoalescer.flush();
-                      sendDeltaFrame({ tool_calls: formatOpenAIStreamToolCalls(evt.calls, streamToolCallIDs, payload.tools) });
-                      resetStreamToolCallState(streamToolCallIDs, streamToolNames);
-                      continue;
-                    }
-                    if (evt.text) {
-                      deltaCoalescer.append('content', evt.text);
-                    }
+                  emitOutputText(outputLimiter.push(bannedFilter.push(stopMatcher.push(trimmed))));
+                  if (stopMatcher.matched || outputLimiter.reached) {
+                    streamEnded = true;
+                    break;
                   }
                 }
               }
@@ -393,6 +439,12 @@ async function handleVercelStream(req, res, rawBody, payload) {
           return { terminal: true, retryable: false };
         }
 
+        if (stopMatcher.matched || outputLimiter.reached) {
+          // A stop sequence or max_tokens ended the answer; drop the rest of
+          // the upstream body.
+          Promise.resolve(reader.cancel()).catch(() => {});
+          break;
+        }
         if (shouldAutoContinue(continueState) && continueRounds < AUTO_CONTINUE_MAX_ROUNDS) {
           continueRounds += 1;
           const nextRes = await fetchContinue(continueState.responseMessageID);
@@ -676,7 +728,7 @@ function upstreamEmptyOutputDetail(contentFilter, _text, thinking) {
 }
 
 function sendFailedChunk(res, status, message, code) {
-  res.write(`data: ${JSON.stringify({
+  res.write(`data: ${JSON.stringify(withTraceID(res, {
     status_code: status,
     error: {
       message,
@@ -684,7 +736,7 @@ function sendFailedChunk(res, status, message, code) {
       code,
       param: null,
     },
-  })}\n\n`);
+  }))}\n\n`);
   if (!res.writableEnded && !res.destroyed) {
     res.write('data: [DONE]\n\n');
   }
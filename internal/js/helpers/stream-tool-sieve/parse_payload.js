@@ -1911,7 +1911,11 @@ function parseMarkupValue(raw, paramName = '') {
       return structured.value;
     }
     const looseArray = parseLooseJSONArrayValue(cdata.value, paramName);
-    return looseArray.ok ? looseArray.value : cdata.value;
+    if (looseArray.ok) {
+      return looseArray.value;
+    }
+    const repaired = parseRepairedJSONValue(cdata.value, paramName);
+    return repaired.ok ? repaired.value : cdata.value;
   }
   const s = toStringSafe(extractRawTagValue(raw)).trim();
   if (!s) {
@@ -1949,6 +1953,10 @@ function parseMarkupValue(raw, paramName = '') {
   if (looseArray.ok) {
     return looseArray.value;
   }
+  const repaired = parseRepairedJSONValue(s, paramName);
+  if (repaired.ok) {
+    return repaired.value;
+  }
   return s;
 }
 
@@ -2322,11 +2330,139 @@ function repairLooseJSON(s) {
   if (!raw) {
     return raw;
   }
-  let out = raw.replace(/([{,]\s*)([a-zA-Z_][a-zA-Z0-9_]*)\s*:/g, '$1"$2":');
+  let out = raw.replace(/([{,]\s*)([\p{L}_][\p{L}\p{M}\p{N}_]*)\s*:/gu, '$1"$2":');
   out = out.replace(/(:\s*)(\{(?:[^{}]|\{[^{}]*\})*\}(?:\s*,\s*\{(?:[^{}]|\{[^{}]*\})*\})+)/g, '$1[$2]');
   return out;
 }
 
+// repairJSONSyntax mirrors the Go tool-argument repair: single-quoted strings,
+// unquoted keys, trailing commas and Python literals are normalized while
+// double-quoted strings are copied verbatim.
+function repairJSONSyntax(s) {
+  const raw = toStringSafe(s);
+  let out = '';
+  let lastSignificant = '';
+  let i = 0;
+  while (i < raw.length) {
+    const c = raw[i];
+    if (c === '"' || c === "'") {
+      const end = scanQuotedEnd(raw, i);
+      if (c === '"') {
+        out += raw.slice(i, end);
+      } else {
+        const body = end > i + 1 && raw[end - 1] === "'" ? raw.slice(i + 1, end - 1) : raw.slice(i + 1, end);
+        out += requoteSingleQuoted(body);
+      }
+      i = end;
+      lastSignificant = '"';
+      continue;
+    }
+    if (c === ',') {
+      let j = i + 1;
+      while (j < raw.length && /\s/.test(raw[j])) {
+        j += 1;
+      }
+      if (raw[j] === '}' || raw[j] === ']') {
+        i += 1;
+        continue;
+      }
+      out += c;
+      i += 1;
+      lastSignificant = c;
+      continue;
+    }
+    const startLen = identCharLen(raw, i, true);
+    if (startLen > 0) {
+      let j = i + startLen;
+      for (let n = identCharLen(raw, j, false); n > 0; n = identCharLen(raw, j, false)) {
+        j += n;
+      }
+      const word = raw.slice(i, j);
+      let k = j;
+      while (k < raw.length && /\s/.test(raw[k])) {
+        k += 1;
+      }
+      if (raw[k] === ':' && (lastSignificant === '{' || lastSignificant === ',')) {
+        out += `"${word}"`;
+      } else {
+        out += { True: 'true', False: 'false', None: 'null' }[word] || word;
+      }
+      i = j;
+      lastSignificant = 'a';
+      continue;
+    }
+    out += c;
+    if (!/\s/.test(c)) {
+      lastSignificant = c;
+    }
+    i += 1;
+  }
+  return out;
+}
+
+// identCharLen mirrors the Go identRuneLen: letters of any script start an
+// identifier, digits and combining marks may continue it. It returns the
+// UTF-16 length of the character at s[i], or 0.
+function identCharLen(s, i, first) {
+  if (i >= s.length) {
+    return 0;
+  }
+  const ch = String.fromCodePoint(s.codePointAt(i));
+  if (/[\p{L}_$]/u.test(ch) || (!first && /[\p{M}\p{N}]/u.test(ch))) {
+    return ch.length;
+  }
+  return 0;
+}
+
+function scanQuotedEnd(s, start) {
+  const quote = s[start];
+  for (let i = start + 1; i < s.length; i += 1) {
+    if (s[i] === '\\') {
+      i += 1;
+    } else if (s[i] === quote) {
+      return i + 1;
+    }
+  }
+  return s.length;
+}
+
+function requoteSingleQuoted(body) {
+  let out = '"';
+  for (let i = 0; i < body.length; i += 1) {
+    const c = body[i];
+    if (c === '\\' && i + 1 < body.length) {
+      out += body[i + 1] === "'" ? "'" : c + body[i + 1];
+      i += 1;
+    } else if (c === '"') {
+      out += '\\"';
+    } else {
+      out += c;
+    }
+  }
+  return `${out}"`;
+}
+
+function parseRepairedJSONValue(raw, paramName = '') {
+  if (preservesCDATAStringParameter(paramName)) {
+    return { ok: false, value: null };
+  }
+  const s = toStringSafe(raw).trim();
+  const container = (s.startsWith('{') && s.endsWith('}')) || (s.startsWith('[') && s.endsWith(']'));
+  if (s.length < 2 || !container) {
+    return { ok: false, value: null };
+  }
+  try {
+    return { ok: true, value: JSON.parse(repairJSONSyntax(s)) };
+  } catch (err) {
+    console.warn('[tool_call] argument looks like JSON but could not be repaired; keeping raw string', {
+      parameter: paramName,
+      length: s.length,
+      error: err && err.message,
+    });
+    return { ok: false, value: null };
+  }
+}
+
 function sanitizeLooseCDATA(text) {
   const raw = toStringSafe(text);
   if (!raw) {
@@ -15,6 +15,7 @@ const {
   shouldKeepBareInvokeCapture,
   findPartialXMLToolTagStart,
 } = require('./sieve-xml');
+const { stripToolMarkup } = require('./strip');
 function processToolSieveChunk(state, chunk, toolNames) {
   if (!state) {
     return [];
@@ -45,10 +46,7 @@ function processToolSieveChunk(state, chunk, toolNames) {
       resetIncrementalToolState(state);
 
       if (Array.isArray(consumed.calls) && consumed.calls.length > 0) {
-        if (consumed.prefix) {
-          noteText(state, consumed.prefix);
-          events.push({ type: 'text', text: consumed.prefix });
-        }
+        releaseCapturedText(state, events, consumed.prefix);
         state.pendingToolRaw = captured;
         state.pendingToolCalls = consumed.calls;
         if (consumed.suffix) {
@@ -56,10 +54,7 @@ function processToolSieveChunk(state, chunk, toolNames) {
         }
         continue;
       }
-      if (consumed.prefix) {
-        noteText(state, consumed.prefix);
-        events.push({ type: 'text', text: consumed.prefix });
-      }
+      releaseCapturedText(state, events, consumed.prefix);
       if (consumed.suffix) {
         state.pending += consumed.suffix;
       }
@@ -117,39 +112,25 @@ function flushToolSieve(state, toolNames) {
   if (state.capturing) {
     const consumed = consumeToolCapture(state, toolNames);
     if (consumed.ready) {
-      if (consumed.prefix) {
-        noteText(state, consumed.prefix);
-        events.push({ type: 'text', text: consumed.prefix });
-      }
+      releaseCapturedText(state, events, consumed.prefix);
       if (Array.isArray(consumed.calls) && consumed.calls.length > 0) {
         events.push({ type: 'tool_calls', calls: consumed.calls });
       }
-      if (consumed.suffix) {
-        noteText(state, consumed.suffix);
-        events.push({ type: 'text', text: consumed.suffix });
-      }
+      releaseCapturedText(state, events, consumed.suffix);
     } else if (state.capture) {
       const content = state.capture;
       const recovered = sanitizeLooseCDATA(content);
       if (recovered !== content) {
         const recoveredResult = consumeXMLToolCaptureImpl(recovered, toolNames, trimWrappingJSONFence);
         if (recoveredResult.ready && Array.isArray(recoveredResult.calls) && recoveredResult.calls.length > 0) {
-          if (recoveredResult.prefix) {
-            noteText(state, recoveredResult.prefix);
-            events.push({ type: 'text', text: recoveredResult.prefix });
-          }
+          releaseCapturedText(state, events, recoveredResult.prefix);
           events.push({ type: 'tool_calls', calls: recoveredResult.calls });
-          if (recoveredResult.suffix) {
-            noteText(state, recoveredResult.suffix);
-            events.push({ type: 'text', text: recoveredResult.suffix });
-          }
+          releaseCapturedText(state, events, recoveredResult.suffix);
         } else {
-          noteText(state, content);
-          events.push({ type: 'text', text: content });
+          releaseCapturedText(state, events, content);
         }
       } else {
-        noteText(state, content);
-        events.push({ type: 'text', text: content });
+        releaseCapturedText(state, events, content);
       }
     }
     state.capture = '';
@@ -164,6 +145,17 @@ function flushToolSieve(state, toolNames) {
   return events;
 }
 
+// Captured text starts at a tool tag; leftover markup from a malformed or
+// cut-off call is stripped before the text reaches the client (same as Go).
+function releaseCapturedText(state, events, text) {
+  const cleaned = stripToolMarkup(text);
+  if (!cleaned) {
+    return;
+  }
+  noteText(state, cleaned);
+  events.push({ type: 'text', text: cleaned });
+}
+
 function splitSafeContentForToolDetection(state, s) {
   const text = s || '';
   if (!text) {
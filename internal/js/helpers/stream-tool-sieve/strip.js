@@ -0,0 +1,112 @@
+'use strict';
+
+const {
+  findToolMarkupTagOutsideIgnored,
+  findMatchingToolMarkupClose,
+} = require('./parse_payload');
+
+// Mirrors Go toolcall.StripToolMarkup: removes leftover call attempts (complete
+// wrappers, bare invokes closed by </tool_calls>, calls cut off before they
+// close, orphan </tool_calls>) while keeping prose mentions, complete bare
+// <invoke> examples and anything inside Markdown code or CDATA.
+function stripToolMarkup(text) {
+  const raw = typeof text === 'string' ? text : '';
+  if (!raw) {
+    return raw;
+  }
+  let out = '';
+  let changed = false;
+  let pos = 0;
+  while (pos < raw.length) {
+    const tag = findToolMarkupTagOutsideIgnored(raw, pos);
+    if (!tag) {
+      break;
+    }
+    const end = leftoverToolMarkupEnd(raw, tag);
+    if (end < 0) {
+      out += raw.slice(pos, tag.end + 1);
+      pos = tag.end + 1;
+      continue;
+    }
+    out += raw.slice(pos, tag.start);
+    changed = true;
+    pos = end;
+  }
+  if (!changed) {
+    return raw;
+  }
+  return out + raw.slice(pos);
+}
+
+function leftoverToolMarkupEnd(text, tag) {
+  if (tag.name === 'tool_calls' && tag.closing) {
+    return tag.end + 1;
+  }
+  if (tag.closing || tag.selfClosing) {
+    return -1;
+  }
+  if (tag.name === 'tool_calls') {
+    const closeTag = findMatchingToolMarkupClose(text, tag);
+    if (closeTag) {
+      return closeTag.end + 1;
+    }
+    if (!text.slice(tag.end + 1).trim()) {
+      return text.length;
+    }
+    const next = findToolMarkupTagOutsideIgnored(text, tag.end + 1);
+    if (next && next.name === 'invoke' && !next.closing && !text.slice(tag.end + 1, next.start).trim()) {
+      return next.start;
+    }
+    return -1;
+  }
+  if (tag.name === 'invoke') {
+    const closeTag = findMatchingToolMarkupClose(text, tag);
+    if (!closeTag) {
+      if (text.slice(tag.start, tag.end + 1).includes('name') && !hasLaterToolBlockOpening(text, tag.end + 1)) {
+        return text.length;
+      }
+      return -1;
+    }
+    const wrapperClose = nextToolMarkupTag(text, closeTag.end + 1);
+    if (wrapperClose && wrapperClose.name === 'tool_calls' && wrapperClose.closing) {
+      return wrapperClose.end + 1;
+    }
+  }
+  return -1;
+}
+
+function nextToolMarkupTag(text, from) {
+  for (let pos = from; pos < text.length;) {
+    const tag = findToolMarkupTagOutsideIgnored(text, pos);
+    if (!tag) {
+      return null;
+    }
+    if (tag.name === 'invoke' && !tag.closing) {
+      const closeTag = findMatchingToolMarkupClose(text, tag);
+      if (closeTag) {
+        pos = closeTag.end + 1;
+        continue;
+      }
+    }
+    return tag;
+  }
+  return null;
+}
+
+function hasLaterToolBlockOpening(text, from) {
+  for (let pos = from; pos < text.length;) {
+    const tag = findToolMarkupTagOutsideIgnored(text, pos);
+    if (!tag) {
+      return false;
+    }
+    if (!tag.closing && (tag.name === 'tool_calls' || tag.name === 'invoke')) {
+      return true;
+    }
+    pos = tag.end + 1;
+  }
+  return false;
+}
+
+module.exports = {
+  stripToolMarkup,
+};
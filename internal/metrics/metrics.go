@@ -0,0 +1,241 @@
+package metrics
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+
+	"ds2api/internal/config"
+	"ds2api/internal/httpapi/requestctx"
+)
+
+// Error kinds reported in ds2api_errors_total.
+const (
+	ErrorUpstream5xx         = "upstream_5xx"
+	ErrorUpstreamUnavailable = "upstream_unavailable"
+	ErrorParseFailure        = "parse_failure"
+	ErrorTimeout             = "timeout"
+	ErrorClientDisconnected  = "client_disconnected"
+)
+
+var (
+	requestDurationBuckets  = []float64{0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60, 120, 300}
+	upstreamDurationBuckets = []float64{0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30}
+)
+
+// Default is the registry served by Handler.
+var Default = NewRegistry()
+
+var (
+	requestsTotal = Default.NewCounterVec("ds2api_requests_total",
+		"API requests by route, resolved model, streaming mode and HTTP status.",
+		"endpoint", "model", "stream", "status")
+	requestDuration = Default.NewHistogramVec("ds2api_request_duration_seconds",
+		"End-to-end API request duration, including the full stream.",
+		requestDurationBuckets, "endpoint", "model", "stream")
+	upstreamDuration = Default.NewHistogramVec("ds2api_upstream_request_duration_seconds",
+		"Time until DeepSeek answered a completion request with response headers.",
+		upstreamDurationBuckets, "model", "status")
+	generatedTokensTotal = Default.NewCounterVec("ds2api_generated_tokens_total",
+		"Completion tokens generated for API requests.",
+		"endpoint", "model", "stream")
+	errorsTotal = Default.NewCounterVec("ds2api_errors_total",
+		"API request errors by kind: upstream_5xx, upstream_unavailable, parse_failure, timeout, client_disconnected.",
+		"endpoint", "model", "type")
+	upstreamInflight = Default.NewGauge("ds2api_upstream_inflight",
+		"DeepSeek completion streams currently open through the upstream concurrency limiter.")
+	upstreamQueued = Default.NewGauge("ds2api_upstream_queued",
+		"Requests waiting for an upstream concurrency slot.")
+	rateLimitedTotal = Default.NewCounterVec("ds2api_rate_limited_total",
+		"Requests rejected by the per-caller rate limiter, by exhausted limit (requests or tokens) and bucket kind (user, key or ip).",
+		"limit", "bucket")
+)
+
+// Request collects what the handler and completion runtime learn about one
+// API request: the resolved model, generated tokens and error kinds.
+// Middleware emits them with consistent labels once the handler returns; trace
+// IDs stay in the logs. A nil *Request ignores every call, so code paths
+// outside Middleware (tests, admin account checks) need no guards.
+type Request struct {
+	mu              sync.Mutex
+	model           string
+	generatedTokens int
+	errors          map[string]int
+}
+
+type requestKey struct{}
+
+// FromContext returns the record attached by Middleware, or nil.
+func FromContext(ctx context.Context) *Request {
+	if ctx == nil {
+		return nil
+	}
+	rec, _ := ctx.Value(requestKey{}).(*Request)
+	return rec
+}
+
+// WithRequest attaches rec to ctx.
+func WithRequest(ctx context.Context, rec *Request) context.Context {
+	return context.WithValue(ctx, requestKey{}, rec)
+}
+
+// SetModel records the resolved model used as the `model` label.
+func SetModel(ctx context.Context, model string) {
+	if rec := FromContext(ctx); rec != nil {
+		rec.mu.Lock()
+		rec.model = strings.TrimSpace(model)
+		rec.mu.Unlock()
+	}
+}
+
+// AddGeneratedTokens adds completion tokens produced for the request; `n > 1`
+// choices each add their own share.
+func AddGeneratedTokens(ctx context.Context, n int) {
+	if rec := FromContext(ctx); rec != nil && n > 0 {
+		rec.mu.Lock()
+		rec.generatedTokens += n
+		rec.mu.Unlock()
+	}
+}
+
+// GeneratedTokens returns the completion tokens recorded so far for the
+// request in ctx.
+func GeneratedTokens(ctx context.Context) int {
+	rec := FromContext(ctx)
+	if rec == nil {
+		return 0
+	}
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	return rec.generatedTokens
+}
+
+// RecordError counts one error of the given kind against the request.
+func RecordError(ctx context.Context, kind string) {
+	if rec := FromContext(ctx); rec != nil && kind != "" {
+		rec.mu.Lock()
+		if rec.errors == nil {
+			rec.errors = map[string]int{}
+		}
+		rec.errors[kind]++
+		rec.mu.Unlock()
+	}
+}
+
+func (r *Request) modelLabel() string {
+	if r == nil {
+		return ""
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.model
+}
+
+// ObserveUpstream records the latency of one DeepSeek completion call. status
+// is the upstream HTTP status, ignored when err is set. 5xx responses and
+// transport failures are also counted as errors of the current request.
+func ObserveUpstream(ctx context.Context, elapsed time.Duration, status int, err error) {
+	statusLabel := strconv.Itoa(status)
+	switch {
+	case err != nil:
+		statusLabel = "error"
+		RecordError(ctx, ErrorUpstreamUnavailable)
+	case status >= http.StatusInternalServerError:
+		RecordError(ctx, ErrorUpstream5xx)
+	}
+	upstreamDuration.Observe(elapsed.Seconds(), FromContext(ctx).modelLabel(), statusLabel)
+}
+
+// SetUpstreamConcurrency publishes the upstream limiter's open streams and
+// queued waiters.
+func SetUpstreamConcurrency(inflight, queued int) {
+	upstreamInflight.Set(float64(inflight))
+	upstreamQueued.Set(float64(queued))
+}
+
+// ObserveRateLimited counts one request rejected by the rate limiter. The
+// caller's identity is logged alongside the trace ID rather than used as a
+// label, which would grow without bound.
+func ObserveRateLimited(limit, bucket string) {
+	rateLimitedTotal.Inc(limit, bucket)
+}
+
+// Middleware records every routed API request. It must run inside the request
+// deadline middleware so timeouts can be told apart from client disconnects.
+// Health checks, /metrics itself, the WebUI and admin routes are skipped.
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !isTrackedPath(r.URL.Path) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		rec := &Request{}
+		ctx := WithRequest(r.Context(), rec)
+		ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+		started := time.Now()
+		next.ServeHTTP(ww, r.WithContext(ctx))
+
+		endpoint := ""
+		if rctx := chi.RouteContext(ctx); rctx != nil {
+			endpoint = rctx.RoutePattern()
+		}
+		if endpoint == "" {
+			return
+		}
+		switch requestctx.CancelReason(ctx) {
+		case requestctx.ReasonDeadlineExceeded:
+			RecordError(ctx, ErrorTimeout)
+		case requestctx.ReasonClientDisconnected:
+			RecordError(ctx, ErrorClientDisconnected)
+		}
+		status := ww.Status()
+		if status == 0 {
+			status = http.StatusOK
+		}
+		stream := strconv.FormatBool(isStreamingContentType(ww.Header().Get("Content-Type")))
+
+		rec.mu.Lock()
+		model, tokens := rec.model, rec.generatedTokens
+		errs := make(map[string]int, len(rec.errors))
+		for kind, n := range rec.errors {
+			errs[kind] = n
+		}
+		rec.mu.Unlock()
+
+		requestsTotal.Inc(endpoint, model, stream, strconv.Itoa(status))
+		requestDuration.Observe(time.Since(started).Seconds(), endpoint, model, stream)
+		if tokens > 0 {
+			generatedTokensTotal.Add(float64(tokens), endpoint, model, stream)
+		}
+		for kind, n := range errs {
+			errorsTotal.Add(float64(n), endpoint, model, kind)
+		}
+	})
+}
+
+// Handler serves the Default registry.
+func Handler(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	if err := Default.WriteText(w); err != nil {
+		config.Logger.Warn("[metrics] write failed", "error", err)
+	}
+}
+
+func isTrackedPath(path string) bool {
+	switch path {
+	case "/", "/metrics", "/healthz", "/readyz", "/admin":
+		return false
+	}
+	return !strings.HasPrefix(path, "/admin/")
+}
+
+func isStreamingContentType(contentType string) bool {
+	contentType = strings.ToLower(contentType)
+	return strings.HasPrefix(contentType, "text/event-stream") || strings.HasPrefix(contentType, "application/x-ndjson")
+}
@@ -0,0 +1,141 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+func TestRegistryWritesPrometheusText(t *testing.T) {
+	reg := NewRegistry()
+	counter := reg.NewCounterVec("test_requests_total", "Requests.", "endpoint", "model")
+	hist := reg.NewHistogramVec("test_duration_seconds", "Duration.", []float64{1, 0.5}, "endpoint")
+	gauge := reg.NewGauge("test_inflight", "In flight.")
+	counter.Add(2, "/v1/chat/completions", `deepseek "v4"`)
+	gauge.Set(3)
+	hist.Observe(0.2, "/v1/chat/completions")
+	hist.Observe(0.7, "/v1/chat/completions")
+
+	var b strings.Builder
+	if err := reg.WriteText(&b); err != nil {
+		t.Fatalf("WriteText error: %v", err)
+	}
+	out := b.String()
+	for _, want := range []string{
+		"# TYPE test_requests_total counter\n",
+		`test_requests_total{endpoint="/v1/chat/completions",model="deepseek \"v4\""} 2` + "\n",
+		"# TYPE test_duration_seconds histogram\n",
+		`test_duration_seconds_bucket{endpoint="/v1/chat/completions",le="0.5"} 1` + "\n",
+		`test_duration_seconds_bucket{endpoint="/v1/chat/completions",le="1"} 2` + "\n",
+		`test_duration_seconds_bucket{endpoint="/v1/chat/completions",le="+Inf"} 2` + "\n",
+		`test_duration_seconds_count{endpoint="/v1/chat/completions"} 2` + "\n",
+		"# TYPE test_inflight gauge\ntest_inflight 3\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Fatalf("expected output to contain %q, got:\n%s", want, out)
+		}
+	}
+	if strings.Index(out, "test_duration_seconds") > strings.Index(out, "test_requests_total") {
+		t.Fatalf("expected families sorted by name, got:\n%s", out)
+	}
+}
+
+func TestCounterVecConcurrentIncrements(t *testing.T) {
+	counter := NewRegistry().NewCounterVec("test_concurrent_total", "Concurrent.", "model")
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				counter.Inc("m")
+			}
+		}()
+	}
+	wg.Wait()
+	if got := counter.Value("m"); got != 5000 {
+		t.Fatalf("expected 5000 increments, got %v", got)
+	}
+}
+
+func TestMiddlewareRecordsRequestWithResolvedModel(t *testing.T) {
+	r := chi.NewRouter()
+	r.Use(Middleware)
+	r.Post("/test/metrics/stream", func(w http.ResponseWriter, r *http.Request) {
+		SetModel(r.Context(), "deepseek-v4-flash")
+		ObserveUpstream(r.Context(), 20*time.Millisecond, http.StatusBadGateway, nil)
+		AddGeneratedTokens(r.Context(), 7)
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.WriteHeader(http.StatusOK)
+	})
+	r.Get("/healthz", func(w http.ResponseWriter, _ *http.Request) { w.WriteHeader(http.StatusOK) })
+
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/test/metrics/stream", nil))
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/healthz", nil))
+
+	endpoint, model := "/test/metrics/stream", "deepseek-v4-flash"
+	if got := requestsTotal.Value(endpoint, model, "true", "200"); got != 1 {
+		t.Fatalf("expected one streamed request, got %v", got)
+	}
+	if got := requestDuration.Count(endpoint, model, "true"); got != 1 {
+		t.Fatalf("expected one duration observation, got %d", got)
+	}
+	if got := generatedTokensTotal.Value(endpoint, model, "true"); got != 7 {
+		t.Fatalf("expected 7 generated tokens, got %v", got)
+	}
+	if got := errorsTotal.Value(endpoint, model, ErrorUpstream5xx); got != 1 {
+		t.Fatalf("expected one upstream 5xx error, got %v", got)
+	}
+	if got := upstreamDuration.Count(model, "502"); got != 1 {
+		t.Fatalf("expected one upstream latency observation, got %d", got)
+	}
+	if got := requestsTotal.Value("/healthz", "", "false", "200"); got != 0 {
+		t.Fatalf("expected health checks to be skipped, got %v", got)
+	}
+}
+
+func TestMiddlewareClassifiesDeadlineAsTimeout(t *testing.T) {
+	r := chi.NewRouter()
+	r.Use(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx, cancel := context.WithTimeout(r.Context(), time.Nanosecond)
+			defer cancel()
+			<-ctx.Done()
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	})
+	r.Use(Middleware)
+	r.Post("/test/metrics/timeout", func(w http.ResponseWriter, r *http.Request) {
+		ObserveUpstream(r.Context(), time.Millisecond, 0, errors.New("dial failed"))
+		w.WriteHeader(http.StatusGatewayTimeout)
+	})
+
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/test/metrics/timeout", nil))
+
+	if got := errorsTotal.Value("/test/metrics/timeout", "", ErrorTimeout); got != 1 {
+		t.Fatalf("expected one timeout error, got %v", got)
+	}
+	if got := errorsTotal.Value("/test/metrics/timeout", "", ErrorUpstreamUnavailable); got != 1 {
+		t.Fatalf("expected one upstream transport error, got %v", got)
+	}
+	if got := requestsTotal.Value("/test/metrics/timeout", "", "false", "504"); got != 1 {
+		t.Fatalf("expected the 504 request to be counted, got %v", got)
+	}
+}
+
+func TestHelpersIgnoreContextsWithoutRecord(t *testing.T) {
+	ctx := context.Background()
+	SetModel(ctx, "m")
+	AddGeneratedTokens(ctx, 3)
+	RecordError(ctx, ErrorParseFailure)
+	if FromContext(ctx) != nil {
+		t.Fatal("expected no record outside the middleware")
+	}
+}
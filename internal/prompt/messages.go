@@ -5,6 +5,8 @@ import (
 	"fmt"
 	"regexp"
 	"strings"
+	"sync/atomic"
+	"unicode"
 )
 
 var markdownImagePattern = regexp.MustCompile(`!\[(.*?)\]\((.*?)\)`)
@@ -24,6 +26,28 @@ const (
 		"do not imitate or echo them; output only the correct content for the user."
 )
 
+var mergeConsecutiveMessages atomic.Pointer[func() bool]
+
+// ConfigureMessageMerge installs the setting that decides whether adjacent
+// user or assistant messages are joined into one block. A nil enabled
+// restores the default, which merges.
+func ConfigureMessageMerge(enabled func() bool) {
+	if enabled == nil {
+		mergeConsecutiveMessages.Store(nil)
+		return
+	}
+	mergeConsecutiveMessages.Store(&enabled)
+}
+
+// MergeConsecutiveMessages reports whether adjacent user or assistant
+// messages are joined when a prompt is built.
+func MergeConsecutiveMessages() bool {
+	if enabled := mergeConsecutiveMessages.Load(); enabled != nil {
+		return (*enabled)()
+	}
+	return true
+}
+
 func MessagesPrepare(messages []map[string]any) string {
 	return MessagesPrepareWithThinking(messages, false)
 }
@@ -44,9 +68,14 @@ func MessagesPrepareWithThinking(messages []map[string]any, _ bool) string {
 	if len(processed) == 0 {
 		return ""
 	}
+	// System blocks always merge so the output integrity guard joins the
+	// caller's system prompt; tool results never do, so each keeps its own
+	// tool block.
+	mergeTurns := MergeConsecutiveMessages()
 	merged := make([]block, 0, len(processed))
 	for _, msg := range processed {
-		if len(merged) > 0 && merged[len(merged)-1].Role == msg.Role {
+		if len(merged) > 0 && merged[len(merged)-1].Role == msg.Role && msg.Role != "tool" &&
+			(mergeTurns || msg.Role == "system") {
 			merged[len(merged)-1].Text += "\n\n" + msg.Text
 			continue
 		}
@@ -55,10 +84,16 @@ func MessagesPrepareWithThinking(messages []map[string]any, _ bool) string {
 	parts := make([]string, 0, len(merged)+2)
 	parts = append(parts, beginSentenceMarker)
 	lastRole := ""
-	for _, m := range merged {
+	for i, m := range merged {
 		lastRole = m.Role
 		switch m.Role {
 		case "assistant":
+			// A trailing assistant message is a prefill: its turn stays open so
+			// the model continues the text instead of starting a new reply.
+			if i == len(merged)-1 {
+				parts = append(parts, assistantMarker+strings.TrimRightFunc(m.Text, unicode.IsSpace))
+				continue
+			}
 			parts = append(parts, formatRoleBlock(assistantMarker, m.Text, endSentenceMarker))
 		case "tool":
 			if strings.TrimSpace(m.Text) != "" {
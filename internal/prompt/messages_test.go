@@ -30,6 +30,7 @@ func TestMessagesPrepareUsesTurnSuffixes(t *testing.T) {
 		{"role": "system", "content": "System rule"},
 		{"role": "user", "content": "Question"},
 		{"role": "assistant", "content": "Answer"},
+		{"role": "user", "content": "Follow-up"},
 	}
 	got := MessagesPrepare(messages)
 	if !strings.HasPrefix(got, "<|begin▁of▁sentence|>") {
@@ -86,3 +87,52 @@ func TestMessagesPrepareWithThinkingPreservesPromptShape(t *testing.T) {
 		t.Fatalf("expected assistant suffix, got %q", gotThinking)
 	}
 }
+
+func TestMessagesPrepareLeavesTrailingAssistantPrefillOpen(t *testing.T) {
+	got := MessagesPrepare([]map[string]any{
+		{"role": "user", "content": "Finish the sentence about Paris."},
+		{"role": "assistant", "content": "Paris is the capital of "},
+	})
+	if !strings.HasSuffix(got, "<|User|>Finish the sentence about Paris.<|Assistant|>Paris is the capital of") {
+		t.Fatalf("expected the prefill to end the prompt mid-sentence, got %q", got)
+	}
+	if strings.Contains(got, endSentenceMarker) {
+		t.Fatalf("did not expect the prefill turn to be closed, got %q", got)
+	}
+}
+
+func TestMessagesPrepareKeepsConsecutiveToolResultsSeparate(t *testing.T) {
+	got := MessagesPrepare([]map[string]any{
+		{"role": "user", "content": "Check both"},
+		{"role": "tool", "content": "result A"},
+		{"role": "tool", "content": "result B"},
+		{"role": "user", "content": "Summarize"},
+	})
+	want := "<|Tool|>result A<|end▁of▁toolresults|><|Tool|>result B<|end▁of▁toolresults|>"
+	if !strings.Contains(got, want) {
+		t.Fatalf("expected one tool block per result, got %q", got)
+	}
+}
+
+func TestMessagesPrepareMergeToggle(t *testing.T) {
+	t.Cleanup(func() { ConfigureMessageMerge(nil) })
+	messages := []map[string]any{
+		{"role": "system", "content": "System rule"},
+		{"role": "user", "content": "first"},
+		{"role": "user", "content": "second"},
+	}
+
+	got := MessagesPrepare(messages)
+	if !strings.Contains(got, "<|User|>first\n\nsecond") {
+		t.Fatalf("expected consecutive user messages to merge by default, got %q", got)
+	}
+
+	ConfigureMessageMerge(func() bool { return false })
+	got = MessagesPrepare(messages)
+	if !strings.Contains(got, "<|User|>first<|User|>second") {
+		t.Fatalf("expected consecutive user messages to stay separate, got %q", got)
+	}
+	if strings.Count(got, systemMarker) != 1 || !strings.Contains(got, outputIntegrityGuardMarker) {
+		t.Fatalf("expected the guard to stay in the single system block, got %q", got)
+	}
+}
@@ -0,0 +1,34 @@
+package promptcompat
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+)
+
+// ParseChoiceCount reads the OpenAI chat `n` parameter. A missing or null
+// value means one choice; anything other than a positive integer is an error.
+// The configured upper bound is enforced by the handler.
+func ParseChoiceCount(raw any) (int, error) {
+	var f float64
+	switch x := raw.(type) {
+	case nil:
+		return 1, nil
+	case int:
+		f = float64(x)
+	case float64:
+		f = x
+	case json.Number:
+		parsed, err := x.Float64()
+		if err != nil {
+			return 0, fmt.Errorf("n must be a positive integer")
+		}
+		f = parsed
+	default:
+		return 0, fmt.Errorf("n must be a positive integer")
+	}
+	if f < 1 || f != math.Trunc(f) || f > math.MaxInt32 {
+		return 0, fmt.Errorf("n must be a positive integer")
+	}
+	return int(f), nil
+}
@@ -0,0 +1,150 @@
+package promptcompat
+
+import (
+	"fmt"
+	"strings"
+
+	"ds2api/internal/util"
+)
+
+// Legacy completions have no conversation: the prompt becomes an assistant
+// prefill that the model continues, steered by one of these user turns.
+const (
+	CompletionContinueInstruction = "Continue the text of your reply from exactly where it stops. Write only the continuation: do not repeat the existing text, add a preamble or comment on it."
+	CompletionInsertInstruction   = "Continue the text of your reply from exactly where it stops so that it joins up with the suffix below. Write only the missing text: do not repeat the existing text or the suffix, add a preamble or comment on it.\n\nSuffix:\n"
+)
+
+// CompletionRequest is a normalized legacy /v1/completions request. Requests
+// holds one StandardRequest per prompt, in prompt order.
+type CompletionRequest struct {
+	Prompts  []string
+	Requests []StandardRequest
+	// Echo prepends each prompt to its completion text.
+	Echo bool
+}
+
+// NormalizeOpenAICompletionsRequest turns a legacy completions request into
+// chat requests that reuse the chat normalization: `suffix`, `echo`,
+// `logprobs` and `best_of` are handled here, everything else (`max_tokens`,
+// `stop`, `n`, sampling, `seed`, `stream`) means what it means for chat.
+// Thinking stays off unless the request asks for it, because the legacy
+// response has no field to carry it. Every prompt generates `n` choices, so
+// maxChoices caps prompts × n rather than `n` alone.
+func NormalizeOpenAICompletionsRequest(store ConfigReader, req map[string]any, traceID string, maxChoices int) (CompletionRequest, error) {
+	model, _ := req["model"].(string)
+	if strings.TrimSpace(model) == "" {
+		return CompletionRequest{}, fmt.Errorf("request must include 'model'")
+	}
+	n, err := ParseChoiceCount(req["n"])
+	if err != nil {
+		return CompletionRequest{}, err
+	}
+	prompts, err := parseCompletionPrompts(req["prompt"], n, maxChoices)
+	if err != nil {
+		return CompletionRequest{}, err
+	}
+	suffix, err := optionalString(req["suffix"], "suffix")
+	if err != nil {
+		return CompletionRequest{}, err
+	}
+	if err := validateCompletionsLogprobs(req); err != nil {
+		return CompletionRequest{}, err
+	}
+	if raw, ok := req["best_of"]; ok && raw != nil {
+		v, ok := samplingNumber(raw)
+		if !ok || v != float64(int(v)) || v < 1 {
+			return CompletionRequest{}, fmt.Errorf("best_of must be a positive integer")
+		}
+		if v > 1 {
+			return CompletionRequest{}, &UnsupportedParameterError{Param: "best_of", Reason: "the DeepSeek backend cannot score and rank candidate completions"}
+		}
+	}
+
+	chatReq := make(map[string]any, len(req)+1)
+	for k, v := range req {
+		switch k {
+		case "prompt", "suffix", "echo", "logprobs", "best_of":
+			continue
+		}
+		chatReq[k] = v
+	}
+	if _, ok := util.ResolveThinkingOverride(req); !ok {
+		chatReq["thinking"] = false
+	}
+	out := CompletionRequest{Prompts: prompts, Requests: make([]StandardRequest, 0, len(prompts)), Echo: util.ToBool(req["echo"])}
+	for _, p := range prompts {
+		chatReq["messages"] = completionMessages(p, suffix)
+		stdReq, err := NormalizeOpenAIChatRequest(store, chatReq, traceID)
+		if err != nil {
+			return CompletionRequest{}, err
+		}
+		stdReq.Surface = "openai_completions"
+		out.Requests = append(out.Requests, stdReq)
+	}
+	return out, nil
+}
+
+// parseCompletionPrompts accepts a string or an array of strings; a missing
+// prompt is one empty prompt. Token-id prompts need the client's tokenizer
+// and are rejected, as is an array whose prompts × n would exceed
+// maxChoices upstream generations.
+func parseCompletionPrompts(raw any, n, maxChoices int) ([]string, error) {
+	prompts, err := completionPromptList(raw)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case n > maxChoices:
+		return nil, fmt.Errorf("n must be at most %d", maxChoices)
+	case len(prompts) > maxChoices/n:
+		return nil, fmt.Errorf("prompt count × n must be at most %d (got %d prompts with n=%d)", maxChoices, len(prompts), n)
+	}
+	return prompts, nil
+}
+
+func completionPromptList(raw any) ([]string, error) {
+	switch v := raw.(type) {
+	case nil:
+		return []string{""}, nil
+	case string:
+		return []string{v}, nil
+	case []any:
+		if len(v) == 0 {
+			return nil, fmt.Errorf("prompt must not be an empty array")
+		}
+		prompts := make([]string, 0, len(v))
+		for _, item := range v {
+			s, ok := item.(string)
+			if !ok {
+				return nil, &UnsupportedParameterError{Param: "prompt", Reason: "token-id prompts are not supported; send the prompt as text"}
+			}
+			prompts = append(prompts, s)
+		}
+		return prompts, nil
+	default:
+		return nil, fmt.Errorf("prompt must be a string or an array of strings")
+	}
+}
+
+func optionalString(raw any, name string) (string, error) {
+	switch v := raw.(type) {
+	case nil:
+		return "", nil
+	case string:
+		return v, nil
+	default:
+		return "", fmt.Errorf("%s must be a string", name)
+	}
+}
+
+func completionMessages(prompt, suffix string) []any {
+	instruction := CompletionContinueInstruction
+	if suffix != "" {
+		instruction = CompletionInsertInstruction + suffix
+	}
+	messages := []any{map[string]any{"role": "user", "content": instruction}}
+	if strings.TrimSpace(prompt) != "" {
+		messages = append(messages, map[string]any{"role": "assistant", "content": prompt})
+	}
+	return messages
+}
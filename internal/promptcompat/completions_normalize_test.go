@@ -0,0 +1,94 @@
+package promptcompat
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestNormalizeOpenAICompletionsRequestBuildsPrefillPerPrompt(t *testing.T) {
+	req := map[string]any{"model": "deepseek-v4-pro", "prompt": []any{"Once upon", "Dear sir"}, "max_tokens": 16.0, "echo": true}
+	out, err := NormalizeOpenAICompletionsRequest(nil, req, "", 4)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(out.Requests) != 2 || !out.Echo || out.Prompts[1] != "Dear sir" {
+		t.Fatalf("unexpected completion request: %#v", out)
+	}
+	for i, stdReq := range out.Requests {
+		if stdReq.Thinking {
+			t.Fatalf("request %d: expected thinking off by default", i)
+		}
+		if stdReq.Surface != "openai_completions" || stdReq.MaxOutputTokens != 16 {
+			t.Fatalf("request %d: unexpected request %#v", i, stdReq)
+		}
+		if len(stdReq.Messages) != 2 || !strings.Contains(stdReq.FinalPrompt, CompletionContinueInstruction) || !strings.Contains(stdReq.FinalPrompt, out.Prompts[i]) {
+			t.Fatalf("request %d: expected instruction plus prefill, got %q", i, stdReq.FinalPrompt)
+		}
+	}
+
+	req = map[string]any{"model": "deepseek-v4-pro", "prompt": "def add(a, b):\n", "suffix": "\nprint(add(1, 2))", "thinking": true}
+	out, err = NormalizeOpenAICompletionsRequest(nil, req, "", 4)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !out.Requests[0].Thinking || !strings.Contains(out.Requests[0].FinalPrompt, "Suffix:\n\nprint(add(1, 2))") {
+		t.Fatalf("expected the suffix instruction and the thinking override, got %#v", out.Requests[0])
+	}
+}
+
+func TestNormalizeOpenAICompletionsRequestRejectsUnsupportedParams(t *testing.T) {
+	var unsupported *UnsupportedParameterError
+	for _, tc := range []struct {
+		extra map[string]any
+		param string
+	}{
+		{map[string]any{"logprobs": 2.0}, "logprobs"},
+		{map[string]any{"best_of": 3.0}, "best_of"},
+		{map[string]any{"prompt": []any{[]any{1.0, 2.0}}}, "prompt"},
+	} {
+		req := map[string]any{"model": "deepseek-v4-flash", "prompt": "hi"}
+		for k, v := range tc.extra {
+			req[k] = v
+		}
+		_, err := NormalizeOpenAICompletionsRequest(nil, req, "", 4)
+		if !errors.As(err, &unsupported) || unsupported.Param != tc.param {
+			t.Fatalf("%v: expected unsupported %s, got %v", tc.extra, tc.param, err)
+		}
+	}
+	for _, extra := range []map[string]any{{"logprobs": 0.0, "best_of": 1.0}, {"logprobs": nil}} {
+		req := map[string]any{"model": "deepseek-v4-flash", "prompt": "hi"}
+		for k, v := range extra {
+			req[k] = v
+		}
+		if _, err := NormalizeOpenAICompletionsRequest(nil, req, "", 4); err != nil {
+			t.Fatalf("%v: unexpected error: %v", extra, err)
+		}
+	}
+	for _, extra := range []map[string]any{{"logprobs": 6.0}, {"suffix": 1.0}, {"prompt": []any{}}, {"best_of": 0.0}} {
+		req := map[string]any{"model": "deepseek-v4-flash", "prompt": "hi"}
+		for k, v := range extra {
+			req[k] = v
+		}
+		if _, err := NormalizeOpenAICompletionsRequest(nil, req, "", 4); err == nil || errors.As(err, &unsupported) {
+			t.Fatalf("%v: expected a validation error, got %v", extra, err)
+		}
+	}
+}
+
+func TestNormalizeOpenAICompletionsRequestCapsPromptsTimesN(t *testing.T) {
+	req := map[string]any{"model": "deepseek-v4-flash", "prompt": []any{"a", "b"}, "n": 2.0}
+	if _, err := NormalizeOpenAICompletionsRequest(nil, req, "", 4); err != nil {
+		t.Fatalf("expected 2 prompts × n=2 to fit a limit of 4, got %v", err)
+	}
+	for _, extra := range []map[string]any{{"prompt": []any{"a", "b", "c"}}, {"n": 5.0, "prompt": "a"}} {
+		req := map[string]any{"model": "deepseek-v4-flash", "n": 2.0}
+		for k, v := range extra {
+			req[k] = v
+		}
+		_, err := NormalizeOpenAICompletionsRequest(nil, req, "", 4)
+		if err == nil || !strings.Contains(err.Error(), "at most 4") {
+			t.Fatalf("%v: expected the choice limit to be enforced, got %v", extra, err)
+		}
+	}
+}
@@ -0,0 +1,209 @@
+package promptcompat
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"ds2api/internal/config"
+	"ds2api/internal/util"
+)
+
+// ContextWindowExceededError reports a prompt that stays over the context
+// budget after every trimmable message is gone: the system messages and the
+// latest user turn alone are too long.
+type ContextWindowExceededError struct {
+	Tokens int
+	Limit  int
+}
+
+func (e *ContextWindowExceededError) Error() string {
+	return fmt.Sprintf("prompt is %d tokens after trimming older messages, over the %d-token context window; shorten the system prompt or the latest message", e.Tokens, e.Limit)
+}
+
+// ContextTrimmer decides what stands in for the oldest messages removed to fit
+// the context window. It returns replacement messages, or none.
+type ContextTrimmer interface {
+	Replace(dropped []any) []any
+}
+
+// ContextTrimmerFor returns the trimmer for a runtime.context_trim_strategy
+// value; unknown values fall back to dropping.
+func ContextTrimmerFor(strategy string) ContextTrimmer {
+	if strings.EqualFold(strings.TrimSpace(strategy), config.ContextTrimSummarizeOldest) {
+		return summarizeOldestTrimmer{}
+	}
+	return dropOldestTrimmer{}
+}
+
+type dropOldestTrimmer struct{}
+
+func (dropOldestTrimmer) Replace([]any) []any { return nil }
+
+const (
+	summaryMaxLines       = 20
+	summaryLineMaxRunes   = 160
+	summaryPreviewEllipse = "…"
+)
+
+// summarizeOldestTrimmer replaces the dropped messages with one system note
+// holding the start of each message. It is an extract, not a model-written
+// summary, so it costs no extra upstream call.
+type summarizeOldestTrimmer struct{}
+
+func (summarizeOldestTrimmer) Replace(dropped []any) []any {
+	lines := make([]string, 0, len(dropped))
+	for _, item := range dropped {
+		msg, ok := item.(map[string]any)
+		if !ok {
+			continue
+		}
+		role := strings.ToLower(strings.TrimSpace(asString(msg["role"])))
+		text := strings.Join(strings.Fields(NormalizeOpenAIContentForPrompt(msg["content"])), " ")
+		if text == "" && role == "assistant" && msg["tool_calls"] != nil {
+			text = "(called tools)"
+		}
+		if text == "" {
+			continue
+		}
+		if truncated, ok := util.TruncateRunes(text, summaryLineMaxRunes); ok {
+			text = truncated + summaryPreviewEllipse
+		}
+		lines = append(lines, "- "+role+": "+text)
+	}
+	omitted := 0
+	if len(lines) > summaryMaxLines {
+		omitted = len(lines) - summaryMaxLines
+		lines = lines[omitted:]
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "%d earlier messages were removed to fit the context window. Their opening lines:", len(dropped))
+	if omitted > 0 {
+		fmt.Fprintf(&b, "\n- (%d older messages not shown)", omitted)
+	}
+	for _, line := range lines {
+		b.WriteString("\n")
+		b.WriteString(line)
+	}
+	return []any{map[string]any{"role": "system", "content": b.String()}}
+}
+
+// ApplyContextWindow trims the oldest messages until the built prompt fits in
+// maxTokens. System and developer messages and the latest user turn (the
+// last user message and everything after it) are never removed; an assistant
+// message is removed together with the tool results that follow it. The
+// fewest messages that make the prompt fit are removed. A zero maxTokens
+// leaves the request unchanged.
+func ApplyContextWindow(stdReq StandardRequest, maxTokens int, trimmer ContextTrimmer, traceID string) (StandardRequest, error) {
+	if maxTokens <= 0 {
+		return stdReq, nil
+	}
+	count := func(finalPrompt string) int {
+		return util.CountPromptTokens(finalPrompt, stdReq.ResolvedModel)
+	}
+	if count(stdReq.FinalPrompt) <= maxTokens {
+		return stdReq, nil
+	}
+	if trimmer == nil {
+		trimmer = dropOldestTrimmer{}
+	}
+	groups := trimmableGroups(stdReq.Messages)
+	build := func(n int, replace bool) ([]any, string, int) {
+		var dropped []any
+		drop := map[int]struct{}{}
+		for _, g := range groups[:n] {
+			dropped = append(dropped, g.messages...)
+			for i := range g.messages {
+				drop[g.start+i] = struct{}{}
+			}
+		}
+		var replacement []any
+		if replace && len(dropped) > 0 {
+			replacement = trimmer.Replace(dropped)
+		}
+		messages := make([]any, 0, len(stdReq.Messages)-len(dropped)+len(replacement))
+		for i, msg := range stdReq.Messages {
+			if n > 0 && i == groups[0].start {
+				// The stand-in takes the place of the oldest removed message.
+				messages = append(messages, replacement...)
+			}
+			if _, ok := drop[i]; ok {
+				continue
+			}
+			messages = append(messages, msg)
+		}
+		finalPrompt, _ := BuildOpenAIPrompt(messages, stdReq.ToolsRaw, traceID, stdReq.ToolChoice, stdReq.ToolPrompt, stdReq.Thinking)
+		return messages, finalPrompt, len(dropped)
+	}
+	fits := func(n int, replace bool) bool {
+		_, finalPrompt, _ := build(n, replace)
+		return count(finalPrompt) <= maxTokens
+	}
+	replace := true
+	n := sort.Search(len(groups)+1, func(n int) bool { return n > 0 && fits(n, true) })
+	if n > len(groups) {
+		// Even the stand-in for every trimmable message is too long; try
+		// dropping them outright before giving up.
+		replace = false
+		if len(groups) == 0 || !fits(len(groups), false) {
+			_, finalPrompt, _ := build(len(groups), false)
+			return stdReq, &ContextWindowExceededError{Tokens: count(finalPrompt), Limit: maxTokens}
+		}
+		n = len(groups)
+	}
+	messages, finalPrompt, removed := build(n, replace)
+	config.Logger.Info("[context_window] trimmed older messages to fit the context window", "trace_id", traceID, "model", stdReq.ResolvedModel, "removed", removed, "max_tokens", maxTokens, "prompt_tokens", count(finalPrompt))
+	stdReq.Messages = messages
+	stdReq.FinalPrompt = finalPrompt
+	stdReq.PromptTokenText = finalPrompt
+	stdReq.ContextTrimmedMessages = removed
+	return stdReq, nil
+}
+
+// trimGroup is a run of messages removed together: an assistant turn keeps
+// its tool results so no result is left without its call.
+type trimGroup struct {
+	start    int
+	messages []any
+}
+
+// trimmableGroups splits the messages before the latest user turn into
+// removable groups, oldest first, skipping system and developer messages.
+// Without any user message the last message is kept instead.
+func trimmableGroups(messages []any) []trimGroup {
+	latestUser := len(messages) - 1
+	for i := len(messages) - 1; i >= 0; i-- {
+		if msg, ok := messages[i].(map[string]any); ok && strings.EqualFold(strings.TrimSpace(asString(msg["role"])), "user") {
+			latestUser = i
+			break
+		}
+	}
+	var groups []trimGroup
+	for i := 0; i < latestUser; i++ {
+		if isSystemRole(messages[i]) {
+			continue
+		}
+		msg, _ := messages[i].(map[string]any)
+		if len(groups) > 0 && isToolResultRole(asString(msg["role"])) {
+			last := &groups[len(groups)-1]
+			if last.start+len(last.messages) == i {
+				last.messages = append(last.messages, messages[i])
+				continue
+			}
+		}
+		groups = append(groups, trimGroup{start: i, messages: []any{messages[i]}})
+	}
+	return groups
+}
+
+func isSystemRole(item any) bool {
+	msg, ok := item.(map[string]any)
+	if !ok {
+		return false
+	}
+	switch strings.ToLower(strings.TrimSpace(asString(msg["role"]))) {
+	case "system", "developer":
+		return true
+	}
+	return false
+}
@@ -0,0 +1,91 @@
+package promptcompat
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"ds2api/internal/config"
+	"ds2api/internal/util"
+)
+
+func longConversation() []any {
+	filler := strings.Repeat("history filler words ", 150)
+	return []any{
+		map[string]any{"role": "system", "content": "You are terse."},
+		map[string]any{"role": "user", "content": "old question one " + filler},
+		map[string]any{"role": "assistant", "tool_calls": []any{map[string]any{"id": "call_1", "type": "function", "function": map[string]any{"name": "lookup", "arguments": "{}"}}}},
+		map[string]any{"role": "tool", "tool_call_id": "call_1", "content": "tool output " + filler},
+		map[string]any{"role": "assistant", "content": "old answer " + filler},
+		map[string]any{"role": "user", "content": "recent question " + filler},
+		map[string]any{"role": "assistant", "content": "recent answer"},
+		map[string]any{"role": "user", "content": "latest question"},
+	}
+}
+
+func contextWindowRequest(t *testing.T) StandardRequest {
+	t.Helper()
+	stdReq, err := NormalizeOpenAIChatRequest(nil, map[string]any{"model": "deepseek-v4-flash", "messages": longConversation()}, "")
+	if err != nil {
+		t.Fatalf("normalize: %v", err)
+	}
+	return stdReq
+}
+
+func TestApplyContextWindowDropsOldestAndKeepsSystemAndLatestTurn(t *testing.T) {
+	stdReq := contextWindowRequest(t)
+	limit := util.CountPromptTokens(stdReq.FinalPrompt, stdReq.ResolvedModel) / 2
+	out, err := ApplyContextWindow(stdReq, limit, ContextTrimmerFor(config.ContextTrimDropOldest), "")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := util.CountPromptTokens(out.FinalPrompt, out.ResolvedModel); got > limit {
+		t.Fatalf("expected trimmed prompt within %d tokens, got %d", limit, got)
+	}
+	if out.ContextTrimmedMessages != 4 {
+		t.Fatalf("expected the first user turn, the tool call with its result and the old answer removed, got %d", out.ContextTrimmedMessages)
+	}
+	for _, want := range []string{"You are terse.", "recent question", "latest question"} {
+		if !strings.Contains(out.FinalPrompt, want) {
+			t.Fatalf("expected %q kept in prompt: %q", want, out.FinalPrompt)
+		}
+	}
+	if strings.Contains(out.FinalPrompt, "old question one") || strings.Contains(out.FinalPrompt, "tool output history") {
+		t.Fatalf("expected oldest messages dropped: %q", out.FinalPrompt)
+	}
+	if out.PromptTokenText != out.FinalPrompt {
+		t.Fatal("expected token accounting to follow the trimmed prompt")
+	}
+}
+
+func TestApplyContextWindowLeavesFittingRequestAlone(t *testing.T) {
+	stdReq := contextWindowRequest(t)
+	out, err := ApplyContextWindow(stdReq, 1_000_000, ContextTrimmerFor(""), "")
+	if err != nil || out.ContextTrimmedMessages != 0 || out.FinalPrompt != stdReq.FinalPrompt {
+		t.Fatalf("expected untouched request, got trimmed=%d err=%v", out.ContextTrimmedMessages, err)
+	}
+}
+
+func TestApplyContextWindowSummarizeOldestInsertsExtract(t *testing.T) {
+	stdReq := contextWindowRequest(t)
+	limit := util.CountPromptTokens(stdReq.FinalPrompt, stdReq.ResolvedModel) / 2
+	out, err := ApplyContextWindow(stdReq, limit, ContextTrimmerFor(config.ContextTrimSummarizeOldest), "")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if out.ContextTrimmedMessages == 0 || !strings.Contains(out.FinalPrompt, "earlier messages were removed to fit the context window") {
+		t.Fatalf("expected summary stand-in, got %q", out.FinalPrompt)
+	}
+	if !strings.Contains(out.FinalPrompt, "- user: old question one") {
+		t.Fatalf("expected the dropped user turn to be previewed: %q", out.FinalPrompt)
+	}
+}
+
+func TestApplyContextWindowRejectsWhenKeptMessagesDoNotFit(t *testing.T) {
+	stdReq := contextWindowRequest(t)
+	_, err := ApplyContextWindow(stdReq, 5, ContextTrimmerFor(config.ContextTrimDropOldest), "")
+	var exceeded *ContextWindowExceededError
+	if !errors.As(err, &exceeded) || exceeded.Limit != 5 || exceeded.Tokens <= 5 {
+		t.Fatalf("expected ContextWindowExceededError, got %v", err)
+	}
+}
@@ -74,17 +74,7 @@ func buildOpenAIHistoryEntry(role string, msg map[string]any) string {
 
 func buildToolHistoryContent(msg map[string]any) string {
 	content := strings.TrimSpace(NormalizeOpenAIContentForPrompt(msg["content"]))
-	parts := make([]string, 0, 2)
-	if name := strings.TrimSpace(asString(msg["name"])); name != "" {
-		parts = append(parts, "name="+name)
-	}
-	if callID := strings.TrimSpace(asString(msg["tool_call_id"])); callID != "" {
-		parts = append(parts, "tool_call_id="+callID)
-	}
-	header := ""
-	if len(parts) > 0 {
-		header = "[" + strings.Join(parts, " ") + "]"
-	}
+	header := toolResultHeader(asString(msg["name"]), asString(msg["tool_call_id"]))
 	switch {
 	case header != "" && content != "":
 		return header + "\n" + content
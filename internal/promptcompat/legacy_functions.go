@@ -0,0 +1,72 @@
+package promptcompat
+
+import (
+	"strings"
+
+	"ds2api/internal/config"
+)
+
+// resolveLegacyFunctions returns the tools and tool_choice of a chat request,
+// translating the deprecated `functions` / `function_call` fields when the
+// modern ones are absent. legacy reports that the response should use the
+// legacy `function_call` message shape too. When both styles are present the
+// modern fields win.
+func resolveLegacyFunctions(req map[string]any, traceID string) (toolsRaw, toolChoiceRaw any, legacy bool) {
+	toolsRaw, toolChoiceRaw = req["tools"], req["tool_choice"]
+	functions, functionCall := req["functions"], req["function_call"]
+	if functions == nil && functionCall == nil {
+		return toolsRaw, toolChoiceRaw, false
+	}
+	if toolsRaw != nil || toolChoiceRaw != nil {
+		config.Logger.Warn("[openai_chat] request mixes tools/tool_choice with legacy functions/function_call; ignoring the legacy fields", "trace_id", traceID)
+		return toolsRaw, toolChoiceRaw, false
+	}
+	if functions != nil {
+		toolsRaw = legacyFunctionsAsTools(functions)
+	}
+	if functionCall != nil {
+		toolChoiceRaw = legacyFunctionCallAsToolChoice(functionCall)
+	}
+	return toolsRaw, toolChoiceRaw, true
+}
+
+// legacyFunctionsAsTools wraps each legacy function definition in the
+// `{"type":"function","function":{...}}` tool shape. Anything that is not a
+// list is passed through so the usual tool validation reports it.
+func legacyFunctionsAsTools(raw any) any {
+	functions, ok := raw.([]any)
+	if !ok {
+		return raw
+	}
+	tools := make([]any, 0, len(functions))
+	for _, fn := range functions {
+		tools = append(tools, map[string]any{"type": "function", "function": fn})
+	}
+	return tools
+}
+
+// legacyFunctionCallAsToolChoice maps "none" / "auto" as-is and
+// `{"name": "x"}` to a forced function tool_choice.
+func legacyFunctionCallAsToolChoice(raw any) any {
+	obj, ok := raw.(map[string]any)
+	if !ok {
+		return raw
+	}
+	if _, hasType := obj["type"]; hasType {
+		return raw
+	}
+	return map[string]any{"type": "function", "function": map[string]any{"name": obj["name"]}}
+}
+
+// assistantToolCallsRaw returns an assistant message's tool calls, reading a
+// legacy `function_call` as a single call when `tool_calls` is empty.
+func assistantToolCallsRaw(msg map[string]any) any {
+	if calls, ok := msg["tool_calls"].([]any); ok && len(calls) > 0 {
+		return msg["tool_calls"]
+	}
+	fc, ok := msg["function_call"].(map[string]any)
+	if !ok || strings.TrimSpace(asString(fc["name"])) == "" {
+		return msg["tool_calls"]
+	}
+	return []any{map[string]any{"type": "function", "function": fc}}
+}
@@ -0,0 +1,47 @@
+package promptcompat
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestNormalizeOpenAIChatRequestTranslatesLegacyFunctions(t *testing.T) {
+	stdReq, err := NormalizeOpenAIChatRequest(nil, map[string]any{
+		"model": "deepseek-v4-flash",
+		"messages": []any{
+			map[string]any{"role": "user", "content": "weather?"},
+			map[string]any{"role": "assistant", "content": nil, "function_call": map[string]any{"name": "get_weather", "arguments": `{"city":"Paris"}`}},
+			map[string]any{"role": "function", "name": "get_weather", "content": "sunny"},
+			map[string]any{"role": "user", "content": "and tomorrow?"},
+		},
+		"functions":     []any{map[string]any{"name": "get_weather", "parameters": map[string]any{"type": "object"}}},
+		"function_call": map[string]any{"name": "get_weather"},
+	}, "")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !stdReq.LegacyFunctions {
+		t.Fatalf("expected legacy response style")
+	}
+	if stdReq.ToolChoice.Mode != ToolChoiceForced || stdReq.ToolChoice.ForcedName != "get_weather" {
+		t.Fatalf("expected function_call mapped to a forced tool_choice, got %#v", stdReq.ToolChoice)
+	}
+	if len(stdReq.ToolNames) != 1 || stdReq.ToolNames[0] != "get_weather" {
+		t.Fatalf("expected functions translated to tools, got %#v", stdReq.ToolNames)
+	}
+	if !strings.Contains(stdReq.FinalPrompt, "Paris") || !strings.Contains(stdReq.FinalPrompt, "sunny") {
+		t.Fatalf("expected legacy call history in prompt: %q", stdReq.FinalPrompt)
+	}
+}
+
+func TestNormalizeOpenAIChatRequestPrefersToolsOverLegacyFunctions(t *testing.T) {
+	req := chatRequestWithToolChoice(nil)
+	req["functions"] = []any{map[string]any{"name": "legacy_only"}}
+	stdReq, err := NormalizeOpenAIChatRequest(nil, req, "")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if stdReq.LegacyFunctions || strings.Contains(strings.Join(stdReq.ToolNames, ","), "legacy_only") {
+		t.Fatalf("expected modern tools to win, got legacy=%v tools=%#v", stdReq.LegacyFunctions, stdReq.ToolNames)
+	}
+}
@@ -0,0 +1,121 @@
+package promptcompat
+
+import (
+	"encoding/json"
+	"sort"
+	"strconv"
+	"strings"
+
+	"ds2api/internal/config"
+	"ds2api/internal/util"
+)
+
+// bannedTokenBias is the bias at or below which a `logit_bias` entry is read
+// as "never emit this token". OpenAI documents -100 as a ban; anything from
+// -50 down is treated the same way.
+const bannedTokenBias = -50
+
+// ParseLogitBias turns OpenAI `logit_bias` into the words the model should
+// avoid. DeepSeek exposes no logit control, so a bias cannot be applied
+// exactly: strongly negative entries are decoded with the model's tokenizer
+// and approximated by a prompt instruction plus output post-filtering, and
+// every other entry is dropped with a warning. A missing, empty or malformed
+// map is a no-op, never an error.
+func ParseLogitBias(raw any, model string) []string {
+	m, ok := raw.(map[string]any)
+	if !ok || len(m) == 0 {
+		if raw != nil && !ok {
+			config.Logger.Warn("[logit_bias] ignored: expected an object of token id to bias", "model", model)
+		}
+		return nil
+	}
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var words []string
+	seen := map[string]struct{}{}
+	var ignored, undecodable []string
+	for _, k := range keys {
+		id, err := strconv.Atoi(strings.TrimSpace(k))
+		bias, ok := logitBiasValue(m[k])
+		if err != nil || !ok {
+			ignored = append(ignored, k)
+			continue
+		}
+		if bias > bannedTokenBias {
+			ignored = append(ignored, k)
+			continue
+		}
+		word, ok := util.DecodeTokenID(id, model)
+		word = strings.TrimSpace(word)
+		if !ok || word == "" {
+			undecodable = append(undecodable, k)
+			continue
+		}
+		key := strings.ToLower(word)
+		if _, dup := seen[key]; dup {
+			continue
+		}
+		seen[key] = struct{}{}
+		words = append(words, word)
+	}
+	if len(ignored) > 0 {
+		config.Logger.Warn("[logit_bias] biases above -50 cannot be applied upstream and were ignored", "model", model, "token_ids", ignored)
+	}
+	if len(undecodable) > 0 {
+		config.Logger.Warn("[logit_bias] token ids could not be decoded and were ignored", "model", model, "token_ids", undecodable)
+	}
+	if len(words) > 0 {
+		config.Logger.Info("[logit_bias] banned tokens approximated by prompt steering and output filtering", "model", model, "words", words)
+	}
+	return words
+}
+
+func logitBiasValue(raw any) (float64, bool) {
+	switch x := raw.(type) {
+	case float64:
+		return x, true
+	case int:
+		return float64(x), true
+	case json.Number:
+		f, err := x.Float64()
+		return f, err == nil
+	default:
+		return 0, false
+	}
+}
+
+// BannedWordsRetryInstruction is appended to the prompt when a reply still
+// contained a banned word and the request is retried once.
+func BannedWordsRetryInstruction(words []string) string {
+	return "Your previous reply used words you were told to avoid. Reply again without using any of these words: " + quoteWordList(words) + "."
+}
+
+func bannedWordsInstruction(words []string) string {
+	if len(words) == 0 {
+		return ""
+	}
+	return "Never use the following words or tokens anywhere in your reply, in any letter case: " + quoteWordList(words) + ". Choose other wording instead."
+}
+
+func quoteWordList(words []string) string {
+	quoted := make([]string, 0, len(words))
+	for _, w := range words {
+		quoted = append(quoted, strconv.Quote(w))
+	}
+	return strings.Join(quoted, ", ")
+}
+
+// applyBannedWordsInstruction prepends the banned-word instruction as a
+// system message, like applyResponseFormatInstruction.
+func applyBannedWordsInstruction(messages []any, words []string) []any {
+	instruction := bannedWordsInstruction(words)
+	if instruction == "" {
+		return messages
+	}
+	out := make([]any, 0, len(messages)+1)
+	out = append(out, map[string]any{"role": "system", "content": instruction})
+	return append(out, messages...)
+}
@@ -0,0 +1,110 @@
+package promptcompat
+
+import (
+	"fmt"
+	"strings"
+)
+
+// maxTopLogprobs is the OpenAI upper bound for top_logprobs.
+const maxTopLogprobs = 20
+
+// responsesLogprobsInclude is the Responses `include` entry asking for
+// output token logprobs.
+const responsesLogprobsInclude = "message.output_text.logprobs"
+
+// UnsupportedParameterError reports a request parameter DS2API understands
+// but cannot honour, because the DeepSeek backend has no equivalent.
+type UnsupportedParameterError struct {
+	Param  string
+	Reason string
+}
+
+func (e *UnsupportedParameterError) Error() string {
+	return fmt.Sprintf("%s is not supported: %s", e.Param, e.Reason)
+}
+
+// errLogprobsUnavailable explains why no logprobs can be returned. Requests
+// that ask for them fail instead of answering without the field.
+func errLogprobsUnavailable(param string) error {
+	return &UnsupportedParameterError{Param: param, Reason: "the DeepSeek backend does not expose token log probabilities"}
+}
+
+// validateChatLogprobs checks Chat `logprobs` / `top_logprobs`. logprobs=false
+// or null is accepted; logprobs=true is rejected because no per-token data is
+// available upstream.
+func validateChatLogprobs(req map[string]any) error {
+	enabled := false
+	switch v := req["logprobs"].(type) {
+	case nil:
+	case bool:
+		enabled = v
+	default:
+		return fmt.Errorf("logprobs must be a boolean")
+	}
+	top, hasTop, err := parseTopLogprobs(req["top_logprobs"])
+	if err != nil {
+		return err
+	}
+	if hasTop && !enabled {
+		return fmt.Errorf("top_logprobs requires logprobs to be true")
+	}
+	if enabled {
+		if hasTop && top > 0 {
+			return errLogprobsUnavailable("top_logprobs")
+		}
+		return errLogprobsUnavailable("logprobs")
+	}
+	return nil
+}
+
+// validateResponsesLogprobs rejects the Responses forms of the request:
+// a positive `top_logprobs` or `include` naming output text logprobs.
+func validateResponsesLogprobs(req map[string]any) error {
+	top, _, err := parseTopLogprobs(req["top_logprobs"])
+	if err != nil {
+		return err
+	}
+	if top > 0 {
+		return errLogprobsUnavailable("top_logprobs")
+	}
+	if include, ok := req["include"].([]any); ok {
+		for _, item := range include {
+			if s, _ := item.(string); strings.TrimSpace(s) == responsesLogprobsInclude {
+				return errLogprobsUnavailable("include")
+			}
+		}
+	}
+	return nil
+}
+
+func parseTopLogprobs(raw any) (int, bool, error) {
+	if raw == nil {
+		return 0, false, nil
+	}
+	v, ok := samplingNumber(raw)
+	if !ok || v != float64(int(v)) || v < 0 || v > maxTopLogprobs {
+		return 0, false, fmt.Errorf("top_logprobs must be an integer between 0 and %d", maxTopLogprobs)
+	}
+	return int(v), true, nil
+}
+
+// maxCompletionLogprobs is the legacy /v1/completions upper bound for the
+// integer `logprobs` parameter.
+const maxCompletionLogprobs = 5
+
+// validateCompletionsLogprobs checks the legacy integer `logprobs`. Zero or
+// null is accepted; a positive count is rejected like Chat logprobs=true.
+func validateCompletionsLogprobs(req map[string]any) error {
+	raw, ok := req["logprobs"]
+	if !ok || raw == nil {
+		return nil
+	}
+	v, ok := samplingNumber(raw)
+	if !ok || v != float64(int(v)) || v < 0 || v > maxCompletionLogprobs {
+		return fmt.Errorf("logprobs must be an integer between 0 and %d", maxCompletionLogprobs)
+	}
+	if v > 0 {
+		return errLogprobsUnavailable("logprobs")
+	}
+	return nil
+}
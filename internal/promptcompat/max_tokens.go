@@ -0,0 +1,55 @@
+package promptcompat
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+
+	"ds2api/internal/config"
+)
+
+// ParseMaxOutputTokens reads the completion budget of an OpenAI request.
+// Chat accepts `max_completion_tokens` and the legacy `max_tokens`; when both
+// are present the newer field wins and a conflict is logged. The Responses API
+// uses `max_output_tokens`. Zero means no limit.
+func ParseMaxOutputTokens(req map[string]any) (int, error) {
+	var limit int
+	var source string
+	for _, key := range []string{"max_output_tokens", "max_tokens", "max_completion_tokens"} {
+		raw, ok := req[key]
+		if !ok || raw == nil {
+			continue
+		}
+		n, err := parsePositiveTokenCount(key, raw)
+		if err != nil {
+			return 0, err
+		}
+		if source != "" && n != limit {
+			config.Logger.Warn("[max_tokens] conflicting completion token limits", "ignored", source, "ignored_value", limit, "used", key, "used_value", n)
+		}
+		limit, source = n, key
+	}
+	return limit, nil
+}
+
+func parsePositiveTokenCount(key string, raw any) (int, error) {
+	var f float64
+	switch x := raw.(type) {
+	case int:
+		f = float64(x)
+	case float64:
+		f = x
+	case json.Number:
+		parsed, err := x.Float64()
+		if err != nil {
+			return 0, fmt.Errorf("%s must be a positive integer", key)
+		}
+		f = parsed
+	default:
+		return 0, fmt.Errorf("%s must be a positive integer", key)
+	}
+	if f < 1 || f != math.Trunc(f) || f > math.MaxInt32 {
+		return 0, fmt.Errorf("%s must be a positive integer", key)
+	}
+	return int(f), nil
+}
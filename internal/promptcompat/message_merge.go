@@ -0,0 +1,94 @@
+package promptcompat
+
+import (
+	"strings"
+
+	"ds2api/internal/prompt"
+)
+
+// MessageMergeOptions controls how repeated roles are folded before the
+// prompt is rendered. DeepSeek's role markers expect alternation, so split
+// user turns and stacked system prompts read better as single blocks.
+type MessageMergeOptions struct {
+	// MergeConsecutiveRoles joins adjacent user, assistant or system
+	// messages. Tool results are never merged, so an assistant → tool →
+	// assistant sequence keeps its tool boundary.
+	MergeConsecutiveRoles bool
+	// CollapseSystem moves every system message into one leading system
+	// block, keeping their original order.
+	CollapseSystem bool
+}
+
+// DefaultMessageMergeOptions is what chat, responses and adapter prompts use.
+// MergeConsecutiveRoles follows runtime.merge_consecutive_messages.
+func DefaultMessageMergeOptions() MessageMergeOptions {
+	return MessageMergeOptions{MergeConsecutiveRoles: prompt.MergeConsecutiveMessages(), CollapseSystem: true}
+}
+
+// NormalizeOpenAIMessagesForPromptWithOptions normalizes messages like
+// NormalizeOpenAIMessagesForPrompt and then applies MergePromptMessages.
+func NormalizeOpenAIMessagesForPromptWithOptions(raw []any, traceID string, opts MessageMergeOptions) []map[string]any {
+	return MergePromptMessages(NormalizeOpenAIMessagesForPrompt(raw, traceID), opts)
+}
+
+// MergePromptMessages folds normalized prompt messages according to opts.
+// Content is joined with a blank line, matching how the prompt renderer
+// separates blocks. Only plain messages (role and content alone) are folded;
+// one carrying name, tool_call_id, tool_calls or any other key stays as is.
+func MergePromptMessages(messages []map[string]any, opts MessageMergeOptions) []map[string]any {
+	if len(messages) == 0 || (!opts.MergeConsecutiveRoles && !opts.CollapseSystem) {
+		return messages
+	}
+	out := make([]map[string]any, 0, len(messages))
+	if opts.CollapseSystem {
+		systemParts := make([]string, 0, 1)
+		rest := make([]map[string]any, 0, len(messages))
+		for _, msg := range messages {
+			if asString(msg["role"]) != "system" || !isPlainPromptMessage(msg) {
+				rest = append(rest, msg)
+				continue
+			}
+			if text := strings.TrimSpace(asString(msg["content"])); text != "" {
+				systemParts = append(systemParts, text)
+			}
+		}
+		if len(systemParts) > 0 {
+			out = append(out, map[string]any{"role": "system", "content": strings.Join(systemParts, "\n\n")})
+		}
+		messages = rest
+	}
+	for _, msg := range messages {
+		role := asString(msg["role"])
+		if opts.MergeConsecutiveRoles && role != "tool" && len(out) > 0 && asString(out[len(out)-1]["role"]) == role &&
+			isPlainPromptMessage(msg) && isPlainPromptMessage(out[len(out)-1]) {
+			prev := out[len(out)-1]
+			out[len(out)-1] = map[string]any{
+				"role":    role,
+				"content": joinPromptContent(asString(prev["content"]), asString(msg["content"])),
+			}
+			continue
+		}
+		out = append(out, msg)
+	}
+	return out
+}
+
+func isPlainPromptMessage(msg map[string]any) bool {
+	for key := range msg {
+		if key != "role" && key != "content" {
+			return false
+		}
+	}
+	return true
+}
+
+func joinPromptContent(a, b string) string {
+	switch {
+	case strings.TrimSpace(a) == "":
+		return b
+	case strings.TrimSpace(b) == "":
+		return a
+	default:
+		return a + "\n\n" + b
+	}
+}
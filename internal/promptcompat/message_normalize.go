@@ -1,7 +1,13 @@
 package promptcompat
 
 import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
 	"strings"
+	"unicode"
 
 	"ds2api/internal/prompt"
 	"ds2api/internal/toolcall"
@@ -12,32 +18,47 @@ const assistantReasoningLabel = "reasoning_content"
 func NormalizeOpenAIMessagesForPrompt(raw []any, traceID string) []map[string]any {
 	_ = traceID
 	out := make([]map[string]any, 0, len(raw))
-	for _, item := range raw {
-		msg, ok := item.(map[string]any)
+	// pendingCalls holds the tool calls of the latest assistant turn so the
+	// tool results that answer them can be put back in call order.
+	var pendingCalls []toolCallRef
+	for i := 0; i < len(raw); i++ {
+		msg, ok := raw[i].(map[string]any)
 		if !ok {
 			continue
 		}
 		role := strings.ToLower(strings.TrimSpace(asString(msg["role"])))
 		switch role {
 		case "assistant":
+			pendingCalls = assistantToolCallRefs(assistantToolCallsRaw(msg))
 			content := buildAssistantContentForPrompt(msg)
 			if content == "" {
 				continue
 			}
 			out = append(out, map[string]any{
 				"role":    "assistant",
-				"content": content,
+				"content": withSpeakerName(msg, content),
 			})
 		case "tool", "function":
-			content := buildToolContentForPrompt(msg)
-			out = append(out, map[string]any{
-				"role":    "tool",
-				"content": content,
-			})
+			results := []map[string]any{msg}
+			for i+1 < len(raw) {
+				next, ok := raw[i+1].(map[string]any)
+				if !ok || !isToolResultRole(asString(next["role"])) {
+					break
+				}
+				results = append(results, next)
+				i++
+			}
+			for _, content := range buildToolResultsForPrompt(results, pendingCalls) {
+				out = append(out, map[string]any{
+					"role":    "tool",
+					"content": content,
+				})
+			}
+			pendingCalls = nil
 		case "user", "system", "developer":
 			out = append(out, map[string]any{
 				"role":    normalizeOpenAIRoleForPrompt(role),
-				"content": NormalizeOpenAIContentForPrompt(msg["content"]),
+				"content": withSpeakerName(msg, NormalizeOpenAIContentForPrompt(msg["content"])),
 			})
 		default:
 			content := NormalizeOpenAIContentForPrompt(msg["content"])
@@ -49,20 +70,73 @@ func NormalizeOpenAIMessagesForPrompt(raw []any, traceID string) []map[string]an
 			}
 			out = append(out, map[string]any{
 				"role":    normalizeOpenAIRoleForPrompt(role),
-				"content": content,
+				"content": withSpeakerName(msg, content),
 			})
 		}
 	}
 	return out
 }
 
+// maxSpeakerNameRunes bounds the speaker label taken from a message `name`.
+const maxSpeakerNameRunes = 64
+
+// withSpeakerName prefixes content with the message's optional `name` so the
+// model can tell participants of a multi-agent conversation apart, e.g.
+// "alice: hello". Blank content stays blank.
+func withSpeakerName(msg map[string]any, content string) string {
+	name := sanitizeSpeakerName(asString(msg["name"]))
+	if name == "" || strings.TrimSpace(content) == "" {
+		return content
+	}
+	return name + ": " + content
+}
+
+// sanitizeSpeakerName keeps letters, digits, spaces and `_-.@` so a name
+// cannot open a role marker such as <|User|>, end the label early with ':'
+// or break onto a new line. Anything else becomes '_'.
+func sanitizeSpeakerName(raw string) string {
+	var b strings.Builder
+	n := 0
+	for _, r := range strings.TrimSpace(raw) {
+		if n >= maxSpeakerNameRunes {
+			break
+		}
+		switch {
+		case unicode.IsLetter(r), unicode.IsDigit(r), r == '_', r == '-', r == '.', r == '@':
+			b.WriteRune(r)
+		case unicode.IsSpace(r):
+			b.WriteRune(' ')
+		default:
+			b.WriteRune('_')
+		}
+		n++
+	}
+	return strings.Join(strings.Fields(b.String()), " ")
+}
+
+// ErrEmptyMessages rejects a conversation with nothing for the model to
+// answer: an empty messages array, or messages whose content is all blank.
+var ErrEmptyMessages = errors.New("messages must contain at least one non-empty message")
+
+// validatePromptMessages runs the prompt normalization and requires at least
+// one message with visible content. A trailing assistant message counts, since
+// the prompt builder leaves it open as a prefill for the model to continue.
+func validatePromptMessages(raw []any) error {
+	for _, msg := range NormalizeOpenAIMessagesForPrompt(raw, "") {
+		if strings.TrimSpace(asString(msg["content"])) != "" {
+			return nil
+		}
+	}
+	return ErrEmptyMessages
+}
+
 func buildAssistantContentForPrompt(msg map[string]any) string {
 	content := strings.TrimSpace(NormalizeOpenAIContentForPrompt(msg["content"]))
 	reasoning := strings.TrimSpace(normalizeOpenAIReasoningContentForPrompt(msg["reasoning_content"]))
 	if reasoning == "" {
 		reasoning = strings.TrimSpace(extractOpenAIReasoningContentFromMessage(msg["content"]))
 	}
-	toolHistory := prompt.FormatToolCallsForPrompt(msg["tool_calls"])
+	toolHistory := prompt.FormatToolCallsForPrompt(assistantToolCallsRaw(msg))
 	if toolHistory == "" {
 		content = normalizeAssistantToolMarkupContentForPrompt(content)
 	}
@@ -187,15 +261,257 @@ func formatPromptLabeledBlock(label, text string) string {
 }
 
 func buildToolContentForPrompt(msg map[string]any) string {
-	content := NormalizeOpenAIContentForPrompt(msg["content"])
+	content := normalizeToolResultContent(msg["content"])
 	if strings.TrimSpace(content) == "" {
 		return "null"
 	}
 	return content
 }
 
+// normalizeToolResultContent renders a tool result's content. Text parts stay
+// plain text; objects, bare JSON values and non-text parts are serialized as
+// compact JSON with sorted keys, so the same structured result always renders
+// the same prompt. An array with no text part is serialized as a whole.
+func normalizeToolResultContent(v any) string {
+	switch x := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return x
+	case []any:
+		if len(x) == 0 {
+			return ""
+		}
+		if hasUploadedAttachmentPart(x) {
+			return NormalizeOpenAIContentForPrompt(x)
+		}
+		hasText := false
+		for _, item := range x {
+			if _, ok := textContentPart(item); ok {
+				hasText = true
+				break
+			}
+		}
+		if !hasText {
+			return compactJSON(x)
+		}
+		parts := make([]string, 0, len(x))
+		for _, item := range x {
+			if text, ok := textContentPart(item); ok {
+				if text != "" {
+					parts = append(parts, text)
+				}
+				continue
+			}
+			parts = append(parts, compactJSON(item))
+		}
+		return strings.Join(parts, "\n")
+	default:
+		return compactJSON(x)
+	}
+}
+
+// textContentPart reports whether item is a text content part and returns
+// its text.
+func textContentPart(item any) (string, bool) {
+	m, ok := item.(map[string]any)
+	if !ok {
+		return "", false
+	}
+	switch strings.ToLower(strings.TrimSpace(asString(m["type"]))) {
+	case "text", "input_text", "output_text":
+	default:
+		return "", false
+	}
+	if text := asString(m["text"]); text != "" {
+		return text, true
+	}
+	return asString(m["content"]), true
+}
+
+// compactJSON marshals v without HTML escaping so `<`, `>` and `&` in tool
+// output reach the prompt as written.
+func compactJSON(v any) string {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(v); err != nil {
+		return fmt.Sprintf("%v", v)
+	}
+	return strings.TrimSuffix(buf.String(), "\n")
+}
+
+// validateToolResultReferences rejects a tool result whose tool_call_id
+// matches no earlier assistant tool call; rendering it would attach the
+// output to the wrong call. Results without an id, and conversations whose
+// assistant calls carry no ids, are left to positional matching.
+func validateToolResultReferences(raw []any) error {
+	known := map[string]struct{}{}
+	anonymous := false
+	for _, item := range raw {
+		msg, ok := item.(map[string]any)
+		if !ok {
+			continue
+		}
+		role := strings.ToLower(strings.TrimSpace(asString(msg["role"])))
+		switch {
+		case role == "assistant":
+			for _, ref := range assistantToolCallRefs(msg["tool_calls"]) {
+				if ref.ID == "" {
+					anonymous = true
+					continue
+				}
+				known[ref.ID] = struct{}{}
+			}
+		case isToolResultRole(role):
+			id := strings.TrimSpace(asString(msg["tool_call_id"]))
+			if id == "" || anonymous {
+				continue
+			}
+			if _, ok := known[id]; !ok {
+				return fmt.Errorf("tool message references unknown tool_call_id %q: no earlier assistant message has a tool call with that id", id)
+			}
+		}
+	}
+	return nil
+}
+
+// toolCallRef is the part of an assistant tool call that tool results refer
+// back to.
+type toolCallRef struct {
+	ID   string
+	Name string
+}
+
+func assistantToolCallRefs(raw any) []toolCallRef {
+	calls, _ := raw.([]any)
+	refs := make([]toolCallRef, 0, len(calls))
+	for _, item := range calls {
+		call, ok := item.(map[string]any)
+		if !ok {
+			continue
+		}
+		name := strings.TrimSpace(asString(call["name"]))
+		if fn, ok := call["function"].(map[string]any); ok && name == "" {
+			name = strings.TrimSpace(asString(fn["name"]))
+		}
+		refs = append(refs, toolCallRef{ID: strings.TrimSpace(asString(call["id"])), Name: name})
+	}
+	return refs
+}
+
+func isToolResultRole(role string) bool {
+	role = strings.ToLower(strings.TrimSpace(role))
+	return role == "tool" || role == "function"
+}
+
+// buildToolResultsForPrompt renders a run of consecutive tool results. When
+// they answer a parallel assistant turn, clients may send them back in any
+// order, so results are sorted into the order of the matching tool_call_id
+// and each one is labeled with its tool name and id; the model only sees the
+// calls by position and name. Results whose id matches no call keep their
+// arrival order after the matched ones. A lone result is left unlabeled.
+func buildToolResultsForPrompt(results []map[string]any, calls []toolCallRef) []string {
+	if len(results) == 1 && len(calls) <= 1 {
+		return []string{buildToolContentForPrompt(results[0])}
+	}
+	position := make(map[string]int, len(calls))
+	for i, call := range calls {
+		if call.ID != "" {
+			position[call.ID] = i
+		}
+	}
+	type rankedResult struct {
+		rank int
+		msg  map[string]any
+		name string
+	}
+	ranked := make([]rankedResult, 0, len(results))
+	for i, msg := range results {
+		entry := rankedResult{rank: len(calls) + i, msg: msg, name: strings.TrimSpace(asString(msg["name"]))}
+		if pos, ok := position[strings.TrimSpace(asString(msg["tool_call_id"]))]; ok {
+			entry.rank = pos
+			if entry.name == "" {
+				entry.name = calls[pos].Name
+			}
+		}
+		ranked = append(ranked, entry)
+	}
+	sort.SliceStable(ranked, func(a, b int) bool { return ranked[a].rank < ranked[b].rank })
+	out := make([]string, 0, len(ranked))
+	for _, entry := range ranked {
+		content := buildToolContentForPrompt(entry.msg)
+		if header := toolResultHeader(entry.name, asString(entry.msg["tool_call_id"])); header != "" {
+			content = header + "\n" + content
+		}
+		out = append(out, content)
+	}
+	return out
+}
+
+// toolResultHeader is the `[name=... tool_call_id=...]` label shared by
+// prompt tool results and the DS2API_HISTORY.txt transcript.
+func toolResultHeader(name, callID string) string {
+	parts := make([]string, 0, 2)
+	if name = strings.TrimSpace(name); name != "" {
+		parts = append(parts, "name="+name)
+	}
+	if callID = strings.TrimSpace(callID); callID != "" {
+		parts = append(parts, "tool_call_id="+callID)
+	}
+	if len(parts) == 0 {
+		return ""
+	}
+	return "[" + strings.Join(parts, " ") + "]"
+}
+
 func NormalizeOpenAIContentForPrompt(v any) string {
-	return prompt.NormalizeContent(v)
+	items, ok := v.([]any)
+	if !ok || !hasUploadedAttachmentPart(items) {
+		return prompt.NormalizeContent(v)
+	}
+	// Uploaded images/files travel as ref_file_ids; keep a marker at their
+	// original position so interleaved text still reads in order.
+	parts := make([]string, 0, len(items))
+	for _, item := range items {
+		if marker := uploadedAttachmentMarker(item); marker != "" {
+			parts = append(parts, marker)
+			continue
+		}
+		if text := prompt.NormalizeContent([]any{item}); text != "" {
+			parts = append(parts, text)
+		}
+	}
+	return strings.Join(parts, "\n")
+}
+
+func hasUploadedAttachmentPart(items []any) bool {
+	for _, item := range items {
+		if uploadedAttachmentMarker(item) != "" {
+			return true
+		}
+	}
+	return false
+}
+
+func uploadedAttachmentMarker(item any) string {
+	m, ok := item.(map[string]any)
+	if !ok || strings.TrimSpace(asString(m["file_id"])) == "" {
+		return ""
+	}
+	label := "file"
+	switch strings.ToLower(strings.TrimSpace(asString(m["type"]))) {
+	case "input_image", "image_url", "image":
+		label = "image"
+	case "input_file", "file":
+	default:
+		return ""
+	}
+	name := strings.TrimSpace(asString(m["filename"]))
+	if name == "" {
+		name = strings.TrimSpace(asString(m["file_id"]))
+	}
+	return "[Attached " + label + ": " + name + "]"
 }
 
 func normalizeOpenAIRoleForPrompt(role string) string {
@@ -0,0 +1,24 @@
+package promptcompat
+
+import "strings"
+
+// SplitAssistantPrefill separates a trailing assistant prefill from the rest
+// of the conversation. A prefill is a last assistant message with visible
+// text and no tool calls; the model continues it rather than starting a new
+// reply. rest is messages unchanged when there is no prefill.
+func SplitAssistantPrefill(messages []any) (rest []any, prefill map[string]any) {
+	if len(messages) == 0 {
+		return messages, nil
+	}
+	msg, ok := messages[len(messages)-1].(map[string]any)
+	if !ok || strings.ToLower(strings.TrimSpace(asString(msg["role"]))) != "assistant" {
+		return messages, nil
+	}
+	if calls, _ := assistantToolCallsRaw(msg).([]any); len(calls) > 0 {
+		return messages, nil
+	}
+	if strings.TrimSpace(NormalizeOpenAIContentForPrompt(msg["content"])) == "" {
+		return messages, nil
+	}
+	return messages[:len(messages)-1], msg
+}
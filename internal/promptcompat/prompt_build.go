@@ -2,28 +2,32 @@ package promptcompat
 
 import (
 	"ds2api/internal/prompt"
+	"ds2api/internal/toolcall"
 )
 
-func buildOpenAIFinalPrompt(messagesRaw []any, toolsRaw any, traceID string, thinkingEnabled bool) (string, []string) {
-	return BuildOpenAIPrompt(messagesRaw, toolsRaw, traceID, DefaultToolChoicePolicy(), thinkingEnabled)
+func buildOpenAIFinalPrompt(messagesRaw []any, toolsRaw any, traceID string, toolPrompt toolcall.PromptSettings, thinkingEnabled bool) (string, []string) {
+	return BuildOpenAIPrompt(messagesRaw, toolsRaw, traceID, DefaultToolChoicePolicy(), toolPrompt, thinkingEnabled)
 }
 
-func BuildOpenAIPrompt(messagesRaw []any, toolsRaw any, traceID string, toolPolicy ToolChoicePolicy, thinkingEnabled bool) (string, []string) {
-	return buildOpenAIPrompt(messagesRaw, toolsRaw, traceID, toolPolicy, thinkingEnabled, true)
+// BuildOpenAIPrompt renders the prompt with toolPrompt, the tool call
+// settings the request captured (StandardRequest.ToolPrompt), so rebuilding a
+// prompt later in the request keeps the same tool call format.
+func BuildOpenAIPrompt(messagesRaw []any, toolsRaw any, traceID string, toolPolicy ToolChoicePolicy, toolPrompt toolcall.PromptSettings, thinkingEnabled bool) (string, []string) {
+	return buildOpenAIPrompt(messagesRaw, toolsRaw, traceID, toolPolicy, toolPrompt, thinkingEnabled, true)
 }
 
-func BuildOpenAIPromptWithToolInstructionsOnly(messagesRaw []any, toolsRaw any, traceID string, toolPolicy ToolChoicePolicy, thinkingEnabled bool) (string, []string) {
-	return buildOpenAIPrompt(messagesRaw, toolsRaw, traceID, toolPolicy, thinkingEnabled, false)
+func BuildOpenAIPromptWithToolInstructionsOnly(messagesRaw []any, toolsRaw any, traceID string, toolPolicy ToolChoicePolicy, toolPrompt toolcall.PromptSettings, thinkingEnabled bool) (string, []string) {
+	return buildOpenAIPrompt(messagesRaw, toolsRaw, traceID, toolPolicy, toolPrompt, thinkingEnabled, false)
 }
 
-func buildOpenAIPrompt(messagesRaw []any, toolsRaw any, traceID string, toolPolicy ToolChoicePolicy, thinkingEnabled bool, includeToolDescriptions bool) (string, []string) {
-	messages := NormalizeOpenAIMessagesForPrompt(messagesRaw, traceID)
+func buildOpenAIPrompt(messagesRaw []any, toolsRaw any, traceID string, toolPolicy ToolChoicePolicy, toolPrompt toolcall.PromptSettings, thinkingEnabled bool, includeToolDescriptions bool) (string, []string) {
+	messages := NormalizeOpenAIMessagesForPromptWithOptions(messagesRaw, traceID, DefaultMessageMergeOptions())
 	toolNames := []string{}
 	if tools, ok := toolsRaw.([]any); ok && len(tools) > 0 {
 		if includeToolDescriptions {
-			messages, toolNames = injectToolPrompt(messages, tools, toolPolicy)
+			messages, toolNames = injectToolPrompt(messages, tools, toolPolicy, toolPrompt)
 		} else {
-			messages, toolNames = injectToolPromptInstructionsOnly(messages, tools, toolPolicy)
+			messages, toolNames = injectToolPromptInstructionsOnly(messages, tools, toolPolicy, toolPrompt)
 		}
 	}
 	return prompt.MessagesPrepareWithThinking(messages, thinkingEnabled), toolNames
@@ -32,6 +36,6 @@ func buildOpenAIPrompt(messagesRaw []any, toolsRaw any, traceID string, toolPoli
 // BuildOpenAIPromptForAdapter exposes the OpenAI-compatible prompt building flow so
 // other protocol adapters (for example Gemini) can reuse the same tool/history
 // normalization logic and remain behavior-compatible with chat/completions.
-func BuildOpenAIPromptForAdapter(messagesRaw []any, toolsRaw any, traceID string, thinkingEnabled bool) (string, []string) {
-	return buildOpenAIFinalPrompt(messagesRaw, toolsRaw, traceID, thinkingEnabled)
+func BuildOpenAIPromptForAdapter(messagesRaw []any, toolsRaw any, traceID string, toolPrompt toolcall.PromptSettings, thinkingEnabled bool) (string, []string) {
+	return buildOpenAIFinalPrompt(messagesRaw, toolsRaw, traceID, toolPrompt, thinkingEnabled)
 }
@@ -3,6 +3,8 @@ package promptcompat
 import (
 	"strings"
 	"testing"
+
+	"ds2api/internal/toolcall"
 )
 
 func TestBuildOpenAIFinalPrompt_HandlerPathIncludesToolRoundtripSemantics(t *testing.T) {
@@ -40,7 +42,7 @@ func TestBuildOpenAIFinalPrompt_HandlerPathIncludesToolRoundtripSemantics(t *tes
 		},
 	}
 
-	finalPrompt, toolNames := buildOpenAIFinalPrompt(messages, tools, "", false)
+	finalPrompt, toolNames := buildOpenAIFinalPrompt(messages, tools, "", toolcall.ActivePrompt(), false)
 	if len(toolNames) != 1 || toolNames[0] != "get_weather" {
 		t.Fatalf("unexpected tool names: %#v", toolNames)
 	}
@@ -73,7 +75,7 @@ func TestBuildOpenAIFinalPrompt_VercelPreparePathKeepsFinalAnswerInstruction(t *
 		},
 	}
 
-	finalPrompt, _ := buildOpenAIFinalPrompt(messages, tools, "", false)
+	finalPrompt, _ := buildOpenAIFinalPrompt(messages, tools, "", toolcall.ActivePrompt(), false)
 	if !strings.Contains(finalPrompt, "Remember: The ONLY valid way to use tools is the <|DSML|tool_calls>...</|DSML|tool_calls> block at the end of your response.") {
 		t.Fatalf("vercel prepare finalPrompt missing final tool-call anchor instruction: %q", finalPrompt)
 	}
@@ -106,7 +108,7 @@ func TestBuildOpenAIPromptWithToolInstructionsOnlyOmitsSchemas(t *testing.T) {
 		},
 	}
 
-	finalPrompt, toolNames := BuildOpenAIPromptWithToolInstructionsOnly(messages, tools, "", DefaultToolChoicePolicy(), false)
+	finalPrompt, toolNames := BuildOpenAIPromptWithToolInstructionsOnly(messages, tools, "", DefaultToolChoicePolicy(), toolcall.ActivePrompt(), false)
 	if len(toolNames) != 1 || toolNames[0] != "search" {
 		t.Fatalf("unexpected tool names: %#v", toolNames)
 	}
@@ -135,7 +137,7 @@ func TestBuildOpenAIToolsContextTranscriptContainsOnlyDescriptions(t *testing.T)
 		},
 	}
 
-	transcript, toolNames := BuildOpenAIToolsContextTranscript(tools, DefaultToolChoicePolicy())
+	transcript, toolNames := BuildOpenAIToolsContextTranscript(tools, DefaultToolChoicePolicy(), toolcall.ActivePrompt())
 	if len(toolNames) != 1 || toolNames[0] != "search" {
 		t.Fatalf("unexpected tool names: %#v", toolNames)
 	}
@@ -149,6 +151,33 @@ func TestBuildOpenAIToolsContextTranscriptContainsOnlyDescriptions(t *testing.T)
 	}
 }
 
+func TestBuildOpenAIFinalPromptRendersParameterOutline(t *testing.T) {
+	messages := []any{map[string]any{"role": "user", "content": "write the file"}}
+	tools := []any{
+		map[string]any{
+			"type": "function",
+			"function": map[string]any{
+				"name":        "write_file",
+				"description": "Write a file",
+				"parameters": map[string]any{
+					"type":     "object",
+					"required": []any{"path"},
+					"properties": map[string]any{
+						"path": map[string]any{"type": "string", "description": "Absolute path"},
+						"mode": map[string]any{"type": "string", "enum": []any{"overwrite", "append"}},
+					},
+				},
+			},
+		},
+	}
+
+	finalPrompt, _ := buildOpenAIFinalPrompt(messages, tools, "", toolcall.ActivePrompt(), false)
+	want := "Tool: write_file\nDescription: Write a file\nParameters:\n- path (string, required): Absolute path\n- mode (string, one of \"overwrite\" | \"append\")"
+	if !strings.Contains(finalPrompt, want) {
+		t.Fatalf("expected parameter outline in final prompt, got: %q", finalPrompt)
+	}
+}
+
 func TestBuildOpenAIFinalPromptPrependsOutputIntegrityGuard(t *testing.T) {
 	messages := []any{
 		map[string]any{"role": "system", "content": "You are helpful"},
@@ -167,7 +196,7 @@ func TestBuildOpenAIFinalPromptPrependsOutputIntegrityGuard(t *testing.T) {
 		},
 	}
 
-	finalPrompt, _ := buildOpenAIFinalPrompt(messages, tools, "", false)
+	finalPrompt, _ := buildOpenAIFinalPrompt(messages, tools, "", toolcall.ActivePrompt(), false)
 	guardIdx := strings.Index(finalPrompt, "Output integrity guard")
 	toolIdx := strings.Index(finalPrompt, "TOOL CALL FORMAT")
 	if guardIdx < 0 {
@@ -198,7 +227,7 @@ func TestBuildOpenAIFinalPromptReadLikeToolIncludesCacheGuard(t *testing.T) {
 		},
 	}
 
-	finalPrompt, _ := buildOpenAIFinalPrompt(messages, tools, "", false)
+	finalPrompt, _ := buildOpenAIFinalPrompt(messages, tools, "", toolcall.ActivePrompt(), false)
 	if !strings.Contains(finalPrompt, "Read-tool cache guard") {
 		t.Fatalf("read-like tool prompt missing cache guard: %q", finalPrompt)
 	}
@@ -227,7 +256,7 @@ func TestBuildOpenAIFinalPromptNonReadToolOmitsCacheGuard(t *testing.T) {
 		},
 	}
 
-	finalPrompt, _ := buildOpenAIFinalPrompt(messages, tools, "", false)
+	finalPrompt, _ := buildOpenAIFinalPrompt(messages, tools, "", toolcall.ActivePrompt(), false)
 	if strings.Contains(finalPrompt, "Read-tool cache guard") {
 		t.Fatalf("non-read tool prompt should not include read cache guard: %q", finalPrompt)
 	}
@@ -238,9 +267,62 @@ func TestBuildOpenAIFinalPromptWithThinkingKeepsPromptUnchanged(t *testing.T) {
 		map[string]any{"role": "user", "content": "继续回答上一个问题"},
 	}
 
-	finalPromptThinking, _ := buildOpenAIFinalPrompt(messages, nil, "", true)
-	finalPromptPlain, _ := buildOpenAIFinalPrompt(messages, nil, "", false)
+	finalPromptThinking, _ := buildOpenAIFinalPrompt(messages, nil, "", toolcall.ActivePrompt(), true)
+	finalPromptPlain, _ := buildOpenAIFinalPrompt(messages, nil, "", toolcall.ActivePrompt(), false)
 	if finalPromptThinking != finalPromptPlain {
 		t.Fatalf("expected thinking flag not to prepend continuation contract, thinking=%q plain=%q", finalPromptThinking, finalPromptPlain)
 	}
 }
+
+func TestBuildOpenAIPromptUsesConfiguredToolTemplate(t *testing.T) {
+	tmpl, err := toolcall.ParsePromptTemplate(`Tools ({{.Format}}):{{range .Tools}} {{.Name}}={{.ParametersJSON}}{{end}}{{if .ForcedName}} must call {{.ForcedName}}{{end}}{{if .ToolsAttached}} see file{{end}}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	toolcall.ConfigurePrompt("json", tmpl)
+	t.Cleanup(func() { toolcall.ConfigurePrompt("", nil) })
+
+	messages := []any{map[string]any{"role": "user", "content": "hi"}}
+	tools := []any{map[string]any{"type": "function", "function": map[string]any{"name": "search", "parameters": map[string]any{"type": "object"}}}}
+	policy := ToolChoicePolicy{Mode: ToolChoiceForced, ForcedName: "search"}
+
+	finalPrompt, _ := BuildOpenAIPrompt(messages, tools, "", policy, toolcall.ActivePrompt(), false)
+	if !strings.Contains(finalPrompt, `Tools (json): search={"type":"object"} must call search`) {
+		t.Fatalf("expected the template output, got %q", finalPrompt)
+	}
+	if strings.Contains(finalPrompt, "You have access to these tools") || strings.Contains(finalPrompt, "TOOL CALL FORMAT") {
+		t.Fatalf("expected the built-in tool prompt to be replaced, got %q", finalPrompt)
+	}
+
+	attachedPrompt, _ := BuildOpenAIPromptWithToolInstructionsOnly(messages, tools, "", policy, toolcall.ActivePrompt(), false)
+	if !strings.Contains(attachedPrompt, "see file") || !strings.Contains(attachedPrompt, "DS2API_TOOLS.txt") {
+		t.Fatalf("expected the attached-tools variant, got %q", attachedPrompt)
+	}
+}
+
+func TestNormalizeOpenAIChatRequestCapturesToolPrompt(t *testing.T) {
+	toolcall.ConfigurePrompt("json", nil)
+	t.Cleanup(func() { toolcall.ConfigurePrompt("", nil) })
+
+	stdReq, err := NormalizeOpenAIChatRequest(nil, map[string]any{
+		"model":    "deepseek-v4-flash",
+		"messages": []any{map[string]any{"role": "user", "content": "hi"}},
+		"tools":    []any{map[string]any{"type": "function", "function": map[string]any{"name": "search", "parameters": map[string]any{"type": "object"}}}},
+	}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stdReq.ToolPrompt.Format != toolcall.CallFormatJSON {
+		t.Fatalf("expected the json format to be captured, got %q", stdReq.ToolPrompt.Format)
+	}
+
+	// A prompt rebuilt after a reload keeps the format the request started with.
+	toolcall.ConfigurePrompt("dsml", nil)
+	rebuilt, _ := BuildOpenAIPrompt(stdReq.Messages, stdReq.ToolsRaw, "", stdReq.ToolChoice, stdReq.ToolPrompt, false)
+	if rebuilt != stdReq.FinalPrompt {
+		t.Fatalf("expected the rebuilt prompt to match the original\nrebuilt=%q\noriginal=%q", rebuilt, stdReq.FinalPrompt)
+	}
+	if strings.Contains(rebuilt, "<|DSML|tool_calls>") {
+		t.Fatalf("expected json tool instructions, got %q", rebuilt)
+	}
+}
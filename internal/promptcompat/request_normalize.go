@@ -5,55 +5,131 @@ import (
 	"strings"
 
 	"ds2api/internal/config"
+	"ds2api/internal/toolcall"
 	"ds2api/internal/util"
 )
 
 type ConfigReader interface {
 	ModelAliases() map[string]string
+	ModelDefaultTarget() string
+	ModelResponseMode() string
+	ModelFallbacks(requested string) []string
+	FallbackBackend(name string) (config.APIBackendConfig, bool)
+	RuntimeStrictSamplingParams() bool
+}
+
+// ModelNotFoundError reports a requested model that matches no DeepSeek
+// model, alias or configured default target.
+type ModelNotFoundError struct {
+	Model string
+}
+
+func (e *ModelNotFoundError) Error() string {
+	return fmt.Sprintf("model %q is not available", e.Model)
+}
+
+// ModelNotAllowedError reports a model outside the caller key's allowlist.
+type ModelNotAllowedError struct {
+	Model string
+}
+
+func (e *ModelNotAllowedError) Error() string {
+	return fmt.Sprintf("this API key is not allowed to use model %q", e.Model)
 }
 
 func NormalizeOpenAIChatRequest(store ConfigReader, req map[string]any, traceID string) (StandardRequest, error) {
 	model, _ := req["model"].(string)
-	messagesRaw, _ := req["messages"].([]any)
-	if strings.TrimSpace(model) == "" || len(messagesRaw) == 0 {
+	messagesRaw, hasMessages := req["messages"].([]any)
+	if strings.TrimSpace(model) == "" || !hasMessages {
 		return StandardRequest{}, fmt.Errorf("request must include 'model' and 'messages'")
 	}
-	resolvedModel, ok := config.ResolveModel(store, model)
+	if len(CollectOpenAIRefFileIDs(req)) == 0 {
+		if err := validatePromptMessages(messagesRaw); err != nil {
+			return StandardRequest{}, err
+		}
+	}
+	if err := validateToolResultReferences(messagesRaw); err != nil {
+		return StandardRequest{}, err
+	}
+	resolvedModel, ok := config.ResolveRequestModel(store, model)
 	if !ok {
-		return StandardRequest{}, fmt.Errorf("model %q is not available", model)
+		return StandardRequest{}, &ModelNotFoundError{Model: strings.TrimSpace(model)}
 	}
 	defaultThinkingEnabled, searchEnabled, _ := config.GetModelConfig(resolvedModel)
 	thinkingEnabled := util.ResolveThinkingEnabled(req, defaultThinkingEnabled)
 	if config.IsNoThinkingModel(resolvedModel) {
 		thinkingEnabled = false
 	}
-	responseModel := strings.TrimSpace(model)
-	if responseModel == "" {
-		responseModel = resolvedModel
+	responseModel := config.ResponseModelName(store, model, resolvedModel)
+	toolsRaw, toolChoiceRaw, legacyFunctions := resolveLegacyFunctions(req, traceID)
+	toolPolicy, err := parseToolChoicePolicy(toolChoiceRaw, toolsRaw)
+	if err != nil {
+		return StandardRequest{}, err
+	}
+	responseFormat, err := parseResponseFormat(req["response_format"])
+	if err != nil {
+		return StandardRequest{}, err
+	}
+	stopSequences, err := ParseStopSequences(req["stop"])
+	if err != nil {
+		return StandardRequest{}, err
+	}
+	maxOutputTokens, err := ParseMaxOutputTokens(req)
+	if err != nil {
+		return StandardRequest{}, err
+	}
+	choices, err := ParseChoiceCount(req["n"])
+	if err != nil {
+		return StandardRequest{}, err
+	}
+	if err := validateChatLogprobs(req); err != nil {
+		return StandardRequest{}, err
+	}
+	bannedWords := ParseLogitBias(req["logit_bias"], resolvedModel)
+	messagesRaw = applyResponseFormatInstruction(messagesRaw, responseFormat)
+	messagesRaw = applyBannedWordsInstruction(messagesRaw, bannedWords)
+	toolPrompt := toolcall.ActivePrompt()
+	finalPrompt, toolNames := BuildOpenAIPrompt(messagesRaw, toolsRaw, traceID, toolPolicy, toolPrompt, thinkingEnabled)
+	toolNames = ensureToolDetectionEnabled(toolNames, toolsRaw, toolPolicy)
+	if !toolPolicy.IsNone() {
+		toolPolicy.Allowed = namesToSet(toolNames)
 	}
-	toolPolicy := DefaultToolChoicePolicy()
-	finalPrompt, toolNames := BuildOpenAIPrompt(messagesRaw, req["tools"], traceID, toolPolicy, thinkingEnabled)
-	toolNames = ensureToolDetectionEnabled(toolNames, req["tools"])
 	passThrough := collectOpenAIChatPassThrough(req)
+	if err := ApplySamplingParams(passThrough, strictSamplingParams(store), traceID); err != nil {
+		return StandardRequest{}, err
+	}
+	if err := applySeed(passThrough, req, resolvedModel, traceID); err != nil {
+		return StandardRequest{}, err
+	}
 	refFileIDs := CollectOpenAIRefFileIDs(req)
 
 	return StandardRequest{
-		Surface:         "openai_chat",
-		RequestedModel:  strings.TrimSpace(model),
-		ResolvedModel:   resolvedModel,
-		ResponseModel:   responseModel,
-		Messages:        messagesRaw,
-		PromptTokenText: finalPrompt,
-		ToolsRaw:        req["tools"],
-		FinalPrompt:     finalPrompt,
-		ToolNames:       toolNames,
-		ToolChoice:      toolPolicy,
-		Stream:          util.ToBool(req["stream"]),
-		Thinking:        thinkingEnabled,
-		Search:          searchEnabled,
-		RefFileIDs:      refFileIDs,
-		RefFileTokens:   estimateInlineFileTokens(req),
-		PassThrough:     passThrough,
+		Surface:          "openai_chat",
+		RequestedModel:   strings.TrimSpace(model),
+		ResolvedModel:    resolvedModel,
+		ResponseModel:    responseModel,
+		FallbackModels:   config.ResolveModelFallbacks(store, model, resolvedModel),
+		FallbackBackends: config.ResolveFallbackBackends(store, model, resolvedModel),
+		Messages:         messagesRaw,
+		PromptTokenText:  finalPrompt,
+		ToolsRaw:         toolsRaw,
+		FinalPrompt:      finalPrompt,
+		ToolNames:        toolNames,
+		ToolChoice:       toolPolicy,
+		ToolPrompt:       toolPrompt,
+		ResponseFormat:   responseFormat,
+		StopSequences:    stopSequences,
+		MaxOutputTokens:  maxOutputTokens,
+		BannedWords:      bannedWords,
+		Choices:          choices,
+		Stream:           util.ToBool(req["stream"]),
+		IncludeUsage:     streamIncludeUsage(req),
+		Thinking:         thinkingEnabled,
+		Search:           searchEnabled,
+		RefFileIDs:       refFileIDs,
+		RefFileTokens:    estimateInlineFileTokens(req),
+		PassThrough:      passThrough,
+		LegacyFunctions:  legacyFunctions,
 	}, nil
 }
 
@@ -63,9 +139,9 @@ func NormalizeOpenAIResponsesRequest(store ConfigReader, req map[string]any, tra
 	if model == "" {
 		return StandardRequest{}, fmt.Errorf("request must include 'model'")
 	}
-	resolvedModel, ok := config.ResolveModel(store, model)
+	resolvedModel, ok := config.ResolveRequestModel(store, model)
 	if !ok {
-		return StandardRequest{}, fmt.Errorf("model %q is not available", model)
+		return StandardRequest{}, &ModelNotFoundError{Model: strings.TrimSpace(model)}
 	}
 	defaultThinkingEnabled, searchEnabled, _ := config.GetModelConfig(resolvedModel)
 	thinkingEnabled := util.ResolveThinkingEnabled(req, defaultThinkingEnabled)
@@ -77,39 +153,91 @@ func NormalizeOpenAIResponsesRequest(store ConfigReader, req map[string]any, tra
 	if len(messagesRaw) == 0 {
 		return StandardRequest{}, fmt.Errorf("request must include 'input' or 'messages'")
 	}
+	if len(CollectOpenAIRefFileIDs(req)) == 0 {
+		if err := validatePromptMessages(messagesRaw); err != nil {
+			return StandardRequest{}, err
+		}
+	}
+	if err := validateToolResultReferences(messagesRaw); err != nil {
+		return StandardRequest{}, err
+	}
 	toolPolicy, err := parseToolChoicePolicy(req["tool_choice"], req["tools"])
 	if err != nil {
 		return StandardRequest{}, err
 	}
-	finalPrompt, toolNames := BuildOpenAIPrompt(messagesRaw, req["tools"], traceID, toolPolicy, thinkingEnabled)
-	toolNames = ensureToolDetectionEnabled(toolNames, req["tools"])
+	responseFormat, err := parseResponsesTextFormat(req)
+	if err != nil {
+		return StandardRequest{}, err
+	}
+	stopSequences, err := ParseStopSequences(req["stop"])
+	if err != nil {
+		return StandardRequest{}, err
+	}
+	maxOutputTokens, err := ParseMaxOutputTokens(req)
+	if err != nil {
+		return StandardRequest{}, err
+	}
+	if err := validateResponsesLogprobs(req); err != nil {
+		return StandardRequest{}, err
+	}
+	bannedWords := ParseLogitBias(req["logit_bias"], resolvedModel)
+	messagesRaw = applyResponseFormatInstruction(messagesRaw, responseFormat)
+	messagesRaw = applyBannedWordsInstruction(messagesRaw, bannedWords)
+	toolPrompt := toolcall.ActivePrompt()
+	finalPrompt, toolNames := BuildOpenAIPrompt(messagesRaw, req["tools"], traceID, toolPolicy, toolPrompt, thinkingEnabled)
+	toolNames = ensureToolDetectionEnabled(toolNames, req["tools"], toolPolicy)
 	if !toolPolicy.IsNone() {
 		toolPolicy.Allowed = namesToSet(toolNames)
 	}
 	passThrough := collectOpenAIChatPassThrough(req)
+	if err := ApplySamplingParams(passThrough, strictSamplingParams(store), traceID); err != nil {
+		return StandardRequest{}, err
+	}
+	if err := applySeed(passThrough, req, resolvedModel, traceID); err != nil {
+		return StandardRequest{}, err
+	}
 	refFileIDs := CollectOpenAIRefFileIDs(req)
 
 	return StandardRequest{
-		Surface:         "openai_responses",
-		RequestedModel:  model,
-		ResolvedModel:   resolvedModel,
-		ResponseModel:   model,
-		Messages:        messagesRaw,
-		PromptTokenText: finalPrompt,
-		ToolsRaw:        req["tools"],
-		FinalPrompt:     finalPrompt,
-		ToolNames:       toolNames,
-		ToolChoice:      toolPolicy,
-		Stream:          util.ToBool(req["stream"]),
-		Thinking:        thinkingEnabled,
-		Search:          searchEnabled,
-		RefFileIDs:      refFileIDs,
-		RefFileTokens:   estimateInlineFileTokens(req),
-		PassThrough:     passThrough,
+		Surface:          "openai_responses",
+		RequestedModel:   model,
+		ResolvedModel:    resolvedModel,
+		ResponseModel:    config.ResponseModelName(store, model, resolvedModel),
+		FallbackModels:   config.ResolveModelFallbacks(store, model, resolvedModel),
+		FallbackBackends: config.ResolveFallbackBackends(store, model, resolvedModel),
+		Messages:         messagesRaw,
+		PromptTokenText:  finalPrompt,
+		ToolsRaw:         req["tools"],
+		FinalPrompt:      finalPrompt,
+		ToolNames:        toolNames,
+		ToolChoice:       toolPolicy,
+		ToolPrompt:       toolPrompt,
+		ResponseFormat:   responseFormat,
+		StopSequences:    stopSequences,
+		MaxOutputTokens:  maxOutputTokens,
+		BannedWords:      bannedWords,
+		Stream:           util.ToBool(req["stream"]),
+		Thinking:         thinkingEnabled,
+		Search:           searchEnabled,
+		RefFileIDs:       refFileIDs,
+		RefFileTokens:    estimateInlineFileTokens(req),
+		PassThrough:      passThrough,
 	}, nil
 }
 
-func ensureToolDetectionEnabled(toolNames []string, toolsRaw any) []string {
+// streamIncludeUsage reports stream_options.include_usage, which asks for a
+// trailing usage-only chunk before [DONE].
+func streamIncludeUsage(req map[string]any) bool {
+	opts, _ := req["stream_options"].(map[string]any)
+	return util.ToBool(opts["include_usage"])
+}
+
+func ensureToolDetectionEnabled(toolNames []string, toolsRaw any, policy ToolChoicePolicy) []string {
+	if policy.IsNone() {
+		// tool_choice=none keeps tool-shaped output as plain text, so the
+		// stream sieve must not buffer it as a pending call either.
+		return nil
+	}
 	if len(toolNames) > 0 {
 		return toolNames
 	}
@@ -123,6 +251,10 @@ func ensureToolDetectionEnabled(toolNames []string, toolsRaw any) []string {
 	return []string{"__any_tool__"}
 }
 
+func strictSamplingParams(store ConfigReader) bool {
+	return store != nil && store.RuntimeStrictSamplingParams()
+}
+
 func collectOpenAIChatPassThrough(req map[string]any) map[string]any {
 	out := map[string]any{}
 	for _, k := range []string{
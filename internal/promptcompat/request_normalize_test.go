@@ -0,0 +1,528 @@
+package promptcompat
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"ds2api/internal/config"
+	"ds2api/internal/util"
+)
+
+func chatRequestWithToolChoice(toolChoice any) map[string]any {
+	req := map[string]any{
+		"model":    "deepseek-v4-flash",
+		"messages": []any{map[string]any{"role": "user", "content": "hi"}},
+		"tools": []any{
+			map[string]any{"type": "function", "function": map[string]any{"name": "read_file", "parameters": map[string]any{"type": "object"}}},
+			map[string]any{"type": "function", "function": map[string]any{"name": "search", "parameters": map[string]any{"type": "object"}}},
+		},
+	}
+	if toolChoice != nil {
+		req["tool_choice"] = toolChoice
+	}
+	return req
+}
+
+func TestNormalizeOpenAIChatRequestToolChoiceNoneOmitsToolPrompt(t *testing.T) {
+	stdReq, err := NormalizeOpenAIChatRequest(nil, chatRequestWithToolChoice("none"), "")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !stdReq.ToolChoice.IsNone() {
+		t.Fatalf("expected none policy, got %#v", stdReq.ToolChoice)
+	}
+	if len(stdReq.ToolNames) != 0 {
+		t.Fatalf("expected tool detection disabled, got %#v", stdReq.ToolNames)
+	}
+	if strings.Contains(stdReq.FinalPrompt, "Tool: read_file") {
+		t.Fatalf("expected no tool descriptions for tool_choice=none: %q", stdReq.FinalPrompt)
+	}
+}
+
+func TestNormalizeOpenAIChatRequestToolChoiceRequiredAddsDirective(t *testing.T) {
+	stdReq, err := NormalizeOpenAIChatRequest(nil, chatRequestWithToolChoice("required"), "")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !stdReq.ToolChoice.IsRequired() {
+		t.Fatalf("expected required policy, got %#v", stdReq.ToolChoice)
+	}
+	if !strings.Contains(stdReq.FinalPrompt, "MUST call at least one tool") {
+		t.Fatalf("expected required-call directive: %q", stdReq.FinalPrompt)
+	}
+}
+
+func TestNormalizeOpenAIChatRequestToolChoiceForcedFiltersTools(t *testing.T) {
+	stdReq, err := NormalizeOpenAIChatRequest(nil, chatRequestWithToolChoice(map[string]any{
+		"type":     "function",
+		"function": map[string]any{"name": "search"},
+	}), "")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if stdReq.ToolChoice.Mode != ToolChoiceForced || stdReq.ToolChoice.ForcedName != "search" {
+		t.Fatalf("expected forced search policy, got %#v", stdReq.ToolChoice)
+	}
+	if len(stdReq.ToolNames) != 1 || stdReq.ToolNames[0] != "search" {
+		t.Fatalf("expected only the forced tool, got %#v", stdReq.ToolNames)
+	}
+	if strings.Contains(stdReq.FinalPrompt, "Tool: read_file") || !strings.Contains(stdReq.FinalPrompt, "Tool: search") {
+		t.Fatalf("expected prompt filtered to the forced tool: %q", stdReq.FinalPrompt)
+	}
+	if !strings.Contains(stdReq.FinalPrompt, "MUST call exactly this tool name: search") {
+		t.Fatalf("expected forced-call directive: %q", stdReq.FinalPrompt)
+	}
+}
+
+func TestNormalizeOpenAIChatRequestRejectsUndeclaredForcedTool(t *testing.T) {
+	_, err := NormalizeOpenAIChatRequest(nil, chatRequestWithToolChoice(map[string]any{
+		"type":     "function",
+		"function": map[string]any{"name": "missing"},
+	}), "")
+	if err == nil {
+		t.Fatal("expected error for undeclared forced tool")
+	}
+}
+
+func TestNormalizeOpenAIChatRequestJSONObjectInjectsInstruction(t *testing.T) {
+	req := map[string]any{
+		"model":           "deepseek-v4-flash",
+		"messages":        []any{map[string]any{"role": "user", "content": "list three colors"}},
+		"response_format": map[string]any{"type": "json_object"},
+	}
+	stdReq, err := NormalizeOpenAIChatRequest(nil, req, "")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if stdReq.ResponseFormat.Type != ResponseFormatJSONObject {
+		t.Fatalf("expected json_object format, got %#v", stdReq.ResponseFormat)
+	}
+	if !strings.Contains(stdReq.FinalPrompt, "single valid JSON value only") {
+		t.Fatalf("expected JSON-mode instruction in prompt: %q", stdReq.FinalPrompt)
+	}
+}
+
+func TestNormalizeOpenAIChatRequestJSONSchemaEmbedsSchema(t *testing.T) {
+	req := map[string]any{
+		"model":    "deepseek-v4-flash",
+		"messages": []any{map[string]any{"role": "user", "content": "hi"}},
+		"response_format": map[string]any{
+			"type": "json_schema",
+			"json_schema": map[string]any{
+				"name":   "answer",
+				"strict": true,
+				"schema": map[string]any{"type": "object", "required": []any{"city"}},
+			},
+		},
+	}
+	stdReq, err := NormalizeOpenAIChatRequest(nil, req, "")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if stdReq.ResponseFormat.Type != ResponseFormatJSONSchema || stdReq.ResponseFormat.Name != "answer" || !stdReq.ResponseFormat.Strict {
+		t.Fatalf("unexpected format: %#v", stdReq.ResponseFormat)
+	}
+	if !strings.Contains(stdReq.FinalPrompt, `"required":["city"]`) || !strings.Contains(stdReq.FinalPrompt, `JSON Schema "answer"`) {
+		t.Fatalf("expected schema embedded in prompt: %q", stdReq.FinalPrompt)
+	}
+}
+
+func TestNormalizeOpenAIChatRequestRejectsUnknownResponseFormat(t *testing.T) {
+	req := map[string]any{
+		"model":           "deepseek-v4-flash",
+		"messages":        []any{map[string]any{"role": "user", "content": "hi"}},
+		"response_format": map[string]any{"type": "yaml"},
+	}
+	if _, err := NormalizeOpenAIChatRequest(nil, req, ""); err == nil || !strings.Contains(err.Error(), "unsupported response_format") {
+		t.Fatalf("expected unsupported response_format error, got %v", err)
+	}
+	req["response_format"] = map[string]any{"type": "json_schema", "json_schema": map[string]any{"name": "x"}}
+	if _, err := NormalizeOpenAIChatRequest(nil, req, ""); err == nil {
+		t.Fatal("expected json_schema without schema to be rejected")
+	}
+}
+
+func TestNormalizeOpenAIResponsesRequestReadsTextFormat(t *testing.T) {
+	req := map[string]any{
+		"model": "deepseek-v4-flash",
+		"input": "hi",
+		"text": map[string]any{"format": map[string]any{
+			"type":   "json_schema",
+			"name":   "reply",
+			"schema": map[string]any{"type": "object"},
+		}},
+	}
+	stdReq, err := NormalizeOpenAIResponsesRequest(nil, req, "")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if stdReq.ResponseFormat.Type != ResponseFormatJSONSchema || stdReq.ResponseFormat.Name != "reply" {
+		t.Fatalf("unexpected format: %#v", stdReq.ResponseFormat)
+	}
+}
+
+func TestNormalizeOpenAIChatRequestReadsStreamIncludeUsage(t *testing.T) {
+	req := map[string]any{
+		"model":          "deepseek-v4-flash",
+		"messages":       []any{map[string]any{"role": "user", "content": "hi"}},
+		"stream":         true,
+		"stream_options": map[string]any{"include_usage": true},
+	}
+	stdReq, err := NormalizeOpenAIChatRequest(nil, req, "")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !stdReq.IncludeUsage {
+		t.Fatal("expected stream_options.include_usage to be honored")
+	}
+}
+
+func TestNormalizeOpenAIChatRequestParsesStopSequences(t *testing.T) {
+	base := func(stop any) map[string]any {
+		return map[string]any{
+			"model":    "deepseek-v4-flash",
+			"messages": []any{map[string]any{"role": "user", "content": "hi"}},
+			"stop":     stop,
+		}
+	}
+	stdReq, err := NormalizeOpenAIChatRequest(nil, base("END"), "")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(stdReq.StopSequences) != 1 || stdReq.StopSequences[0] != "END" {
+		t.Fatalf("unexpected stop sequences: %#v", stdReq.StopSequences)
+	}
+	stdReq, err = NormalizeOpenAIChatRequest(nil, base([]any{"a", "", "b"}), "")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(stdReq.StopSequences) != 2 {
+		t.Fatalf("expected empty stop strings to be dropped, got %#v", stdReq.StopSequences)
+	}
+	if _, err := NormalizeOpenAIChatRequest(nil, base([]any{"1", "2", "3", "4", "5"}), ""); err == nil {
+		t.Fatal("expected error for more than four stop sequences")
+	}
+	if _, err := NormalizeOpenAIChatRequest(nil, base([]any{1}), ""); err == nil {
+		t.Fatal("expected error for non-string stop entry")
+	}
+}
+
+func TestNormalizeOpenAIChatRequestParsesMaxOutputTokens(t *testing.T) {
+	base := func(extra map[string]any) map[string]any {
+		req := map[string]any{
+			"model":    "deepseek-v4-flash",
+			"messages": []any{map[string]any{"role": "user", "content": "hi"}},
+		}
+		for k, v := range extra {
+			req[k] = v
+		}
+		return req
+	}
+	stdReq, err := NormalizeOpenAIChatRequest(nil, base(map[string]any{"max_tokens": float64(64)}), "")
+	if err != nil || stdReq.MaxOutputTokens != 64 {
+		t.Fatalf("expected max_tokens 64, got %d err=%v", stdReq.MaxOutputTokens, err)
+	}
+	stdReq, err = NormalizeOpenAIChatRequest(nil, base(map[string]any{"max_tokens": float64(64), "max_completion_tokens": float64(16)}), "")
+	if err != nil || stdReq.MaxOutputTokens != 16 {
+		t.Fatalf("expected max_completion_tokens to win, got %d err=%v", stdReq.MaxOutputTokens, err)
+	}
+	stdReq, err = NormalizeOpenAIChatRequest(nil, base(nil), "")
+	if err != nil || stdReq.MaxOutputTokens != 0 {
+		t.Fatalf("expected no limit by default, got %d err=%v", stdReq.MaxOutputTokens, err)
+	}
+	for _, bad := range []any{float64(0), float64(-1), 1.5, "10"} {
+		if _, err := NormalizeOpenAIChatRequest(nil, base(map[string]any{"max_tokens": bad}), ""); err == nil {
+			t.Fatalf("expected error for max_tokens=%#v", bad)
+		}
+	}
+}
+
+func TestNormalizeOpenAIResponsesRequestParsesMaxOutputTokens(t *testing.T) {
+	stdReq, err := NormalizeOpenAIResponsesRequest(nil, map[string]any{
+		"model":             "deepseek-v4-flash",
+		"input":             "hi",
+		"max_output_tokens": float64(32),
+	}, "")
+	if err != nil || stdReq.MaxOutputTokens != 32 {
+		t.Fatalf("expected max_output_tokens 32, got %d err=%v", stdReq.MaxOutputTokens, err)
+	}
+}
+
+func TestNormalizeOpenAIChatRequestParsesChoiceCount(t *testing.T) {
+	base := func(n any) map[string]any {
+		req := map[string]any{
+			"model":    "deepseek-v4-flash",
+			"messages": []any{map[string]any{"role": "user", "content": "hi"}},
+		}
+		if n != nil {
+			req["n"] = n
+		}
+		return req
+	}
+	stdReq, err := NormalizeOpenAIChatRequest(nil, base(nil), "")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if stdReq.Choices != 1 {
+		t.Fatalf("expected default of one choice, got %d", stdReq.Choices)
+	}
+	stdReq, err = NormalizeOpenAIChatRequest(nil, base(float64(3)), "")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if stdReq.Choices != 3 {
+		t.Fatalf("expected three choices, got %d", stdReq.Choices)
+	}
+	for _, bad := range []any{float64(0), float64(-1), 1.5, "2"} {
+		if _, err := NormalizeOpenAIChatRequest(nil, base(bad), ""); err == nil {
+			t.Fatalf("expected error for n=%#v", bad)
+		}
+	}
+}
+
+func TestNormalizeOpenAIChatRequestForwardsSeed(t *testing.T) {
+	base := func(seed any) map[string]any {
+		return map[string]any{
+			"model":    "deepseek-v4-flash",
+			"messages": []any{map[string]any{"role": "user", "content": "hi"}},
+			"seed":     seed,
+		}
+	}
+	stdReq, err := NormalizeOpenAIChatRequest(nil, base(float64(42)), "")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := stdReq.CompletionPayload("session")["seed"]; got != int64(42) {
+		t.Fatalf("expected seed forwarded upstream, got %#v", got)
+	}
+	stdReq, err = NormalizeOpenAIChatRequest(nil, base(nil), "")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, ok := stdReq.CompletionPayload("session")["seed"]; ok {
+		t.Fatal("expected no seed in payload when omitted")
+	}
+	for _, bad := range []any{1.5, "7", true} {
+		if _, err := NormalizeOpenAIChatRequest(nil, base(bad), ""); err == nil {
+			t.Fatalf("expected error for seed=%#v", bad)
+		}
+	}
+}
+
+func TestNormalizeOpenAIChatRequestClampsSamplingParams(t *testing.T) {
+	base := func(extra map[string]any) map[string]any {
+		req := map[string]any{
+			"model":    "deepseek-v4-flash",
+			"messages": []any{map[string]any{"role": "user", "content": "hi"}},
+		}
+		for k, v := range extra {
+			req[k] = v
+		}
+		return req
+	}
+	stdReq, err := NormalizeOpenAIChatRequest(nil, base(map[string]any{"temperature": float64(3), "top_p": float64(-1), "presence_penalty": float64(1.5)}), "")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	payload := stdReq.CompletionPayload("session")
+	if payload["temperature"] != float64(2) || payload["top_p"] != float64(0) || payload["presence_penalty"] != 1.5 {
+		t.Fatalf("expected clamped sampling params in payload, got %#v", payload)
+	}
+
+	strict := modelRoutingStore{strictSampling: true}
+	if _, err := NormalizeOpenAIChatRequest(strict, base(map[string]any{"temperature": float64(3)}), ""); err == nil || !strings.Contains(err.Error(), "temperature must be between 0 and 2") {
+		t.Fatalf("expected strict range error, got %v", err)
+	}
+	if _, err := NormalizeOpenAIResponsesRequest(strict, map[string]any{"model": "deepseek-v4-flash", "input": "hi", "top_p": float64(1.2)}, ""); err == nil || !strings.Contains(err.Error(), "top_p must be between 0 and 1") {
+		t.Fatalf("expected strict range error for responses top_p, got %v", err)
+	}
+	if _, err := NormalizeOpenAIChatRequest(strict, base(map[string]any{"temperature": float64(0.7)}), ""); err != nil {
+		t.Fatalf("expected in-range value to pass strict mode, got %v", err)
+	}
+	for _, bad := range []any{"0.5", true, map[string]any{}} {
+		if _, err := NormalizeOpenAIChatRequest(nil, base(map[string]any{"presence_penalty": bad}), ""); err == nil || !strings.Contains(err.Error(), "presence_penalty must be a number") {
+			t.Fatalf("expected type error for presence_penalty=%#v, got %v", bad, err)
+		}
+	}
+}
+
+func TestNormalizeOpenAIRequestsRejectUnknownToolCallID(t *testing.T) {
+	chat := map[string]any{
+		"model": "deepseek-v4-flash",
+		"messages": []any{
+			map[string]any{"role": "user", "content": "weather?"},
+			map[string]any{"role": "assistant", "tool_calls": []any{map[string]any{"id": "call_1", "type": "function", "function": map[string]any{"name": "get_weather", "arguments": "{}"}}}},
+			map[string]any{"role": "tool", "tool_call_id": "call_1", "content": map[string]any{"temp": 18}},
+		},
+	}
+	if _, err := NormalizeOpenAIChatRequest(nil, chat, ""); err != nil {
+		t.Fatalf("expected matching tool_call_id to be accepted, got %v", err)
+	}
+	chat["messages"] = append(chat["messages"].([]any), map[string]any{"role": "tool", "tool_call_id": "call_missing", "content": "late"})
+	if _, err := NormalizeOpenAIChatRequest(nil, chat, ""); err == nil || !strings.Contains(err.Error(), "call_missing") {
+		t.Fatalf("expected unknown tool_call_id error, got %v", err)
+	}
+	responses := map[string]any{
+		"model": "deepseek-v4-flash",
+		"input": []any{
+			map[string]any{"role": "user", "content": "weather?"},
+			map[string]any{"type": "function_call_output", "call_id": "call_orphan", "output": "sunny"},
+		},
+	}
+	if _, err := NormalizeOpenAIResponsesRequest(nil, responses, ""); err == nil || !strings.Contains(err.Error(), "call_orphan") {
+		t.Fatalf("expected unknown call_id error for responses input, got %v", err)
+	}
+}
+
+type modelRoutingStore struct {
+	defaultModel   string
+	responseMode   string
+	strictSampling bool
+}
+
+func (modelRoutingStore) ModelAliases() map[string]string { return nil }
+func (modelRoutingStore) ModelFallbacks(string) []string  { return nil }
+func (modelRoutingStore) FallbackBackend(string) (config.APIBackendConfig, bool) {
+	return config.APIBackendConfig{}, false
+}
+func (s modelRoutingStore) ModelDefaultTarget() string { return s.defaultModel }
+func (s modelRoutingStore) ModelResponseMode() string  { return s.responseMode }
+func (s modelRoutingStore) RuntimeStrictSamplingParams() bool {
+	return s.strictSampling
+}
+
+func TestNormalizeOpenAIChatRequestRoutesUnknownModelToDefault(t *testing.T) {
+	req := map[string]any{
+		"model":    "my-proprietary-model",
+		"messages": []any{map[string]any{"role": "user", "content": "hi"}},
+	}
+	stdReq, err := NormalizeOpenAIChatRequest(modelRoutingStore{defaultModel: "deepseek-v4-pro"}, req, "")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if stdReq.ResolvedModel != "deepseek-v4-pro" || stdReq.ResponseModel != "my-proprietary-model" {
+		t.Fatalf("unexpected models: resolved=%q response=%q", stdReq.ResolvedModel, stdReq.ResponseModel)
+	}
+	stdReq, err = NormalizeOpenAIChatRequest(modelRoutingStore{defaultModel: "deepseek-v4-pro", responseMode: "resolved"}, req, "")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if stdReq.ResponseModel != "deepseek-v4-pro" {
+		t.Fatalf("expected resolved model echoed, got %q", stdReq.ResponseModel)
+	}
+}
+
+func TestNormalizeOpenAIChatRequestReportsModelNotFound(t *testing.T) {
+	req := map[string]any{
+		"model":    "my-proprietary-model",
+		"messages": []any{map[string]any{"role": "user", "content": "hi"}},
+	}
+	_, err := NormalizeOpenAIChatRequest(modelRoutingStore{}, req, "")
+	var notFound *ModelNotFoundError
+	if !errors.As(err, &notFound) || notFound.Model != "my-proprietary-model" {
+		t.Fatalf("expected ModelNotFoundError, got %v", err)
+	}
+}
+
+func TestNormalizeOpenAIChatRequestMapsBannedLogitBias(t *testing.T) {
+	if _, ok := util.DecodeTokenID(60252, "deepseek-v4-flash"); !ok {
+		t.Skip("no tokenizer on this target")
+	}
+	req := map[string]any{
+		"model":      "deepseek-v4-flash",
+		"messages":   []any{map[string]any{"role": "user", "content": "hi"}},
+		"logit_bias": map[string]any{"60252": float64(-100), "15339": float64(5)},
+	}
+	stdReq, err := NormalizeOpenAIChatRequest(nil, req, "")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(stdReq.BannedWords) != 1 || stdReq.BannedWords[0] != "banana" {
+		t.Fatalf("expected only the -100 bias to ban a word, got %#v", stdReq.BannedWords)
+	}
+	if !strings.Contains(stdReq.FinalPrompt, `"banana"`) {
+		t.Fatalf("expected banned-word instruction in prompt, got %q", stdReq.FinalPrompt)
+	}
+}
+
+func TestNormalizeOpenAIChatRequestIgnoresEmptyOrMalformedLogitBias(t *testing.T) {
+	for _, raw := range []any{map[string]any{}, "nope", map[string]any{"x": float64(-100), "1": "y"}} {
+		stdReq, err := NormalizeOpenAIChatRequest(nil, map[string]any{
+			"model":      "deepseek-v4-flash",
+			"messages":   []any{map[string]any{"role": "user", "content": "hi"}},
+			"logit_bias": raw,
+		}, "")
+		if err != nil || len(stdReq.BannedWords) != 0 {
+			t.Fatalf("expected logit_bias %#v to be a no-op, got %#v err=%v", raw, stdReq.BannedWords, err)
+		}
+	}
+}
+
+func TestNormalizeOpenAIRequestsRejectBlankMessages(t *testing.T) {
+	blank := []any{map[string]any{"role": "user", "content": " \n"}, map[string]any{"role": "assistant", "content": ""}}
+	if _, err := NormalizeOpenAIChatRequest(nil, map[string]any{"model": "deepseek-v4-flash", "messages": []any{}}, ""); !errors.Is(err, ErrEmptyMessages) {
+		t.Fatalf("expected ErrEmptyMessages for empty array, got %v", err)
+	}
+	if _, err := NormalizeOpenAIChatRequest(nil, map[string]any{"model": "deepseek-v4-flash", "messages": blank}, ""); !errors.Is(err, ErrEmptyMessages) {
+		t.Fatalf("expected ErrEmptyMessages for blank content, got %v", err)
+	}
+	if _, err := NormalizeOpenAIResponsesRequest(nil, map[string]any{"model": "deepseek-v4-flash", "input": []any{map[string]any{"role": "user", "content": "   "}}}, ""); !errors.Is(err, ErrEmptyMessages) {
+		t.Fatalf("expected ErrEmptyMessages for blank responses input, got %v", err)
+	}
+}
+
+func TestNormalizeOpenAIChatRequestAcceptsTrailingAssistantMessage(t *testing.T) {
+	stdReq, err := NormalizeOpenAIChatRequest(nil, map[string]any{
+		"model": "deepseek-v4-flash",
+		"messages": []any{
+			map[string]any{"role": "user", "content": "Write a haiku"},
+			map[string]any{"role": "assistant", "content": "Autumn moonlight"},
+		},
+	}, "")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !strings.HasSuffix(stdReq.FinalPrompt, "<|User|>Write a haiku<|Assistant|>Autumn moonlight") {
+		t.Fatalf("expected the trailing assistant turn to stay open for continuation, got %q", stdReq.FinalPrompt)
+	}
+}
+
+func TestNormalizeOpenAIRequestsRejectLogprobs(t *testing.T) {
+	chat := func(extra map[string]any) map[string]any {
+		req := map[string]any{"model": "deepseek-v4-flash", "messages": []any{map[string]any{"role": "user", "content": "hi"}}}
+		for k, v := range extra {
+			req[k] = v
+		}
+		return req
+	}
+	for _, extra := range []map[string]any{nil, {"logprobs": false}, {"logprobs": nil, "top_logprobs": nil}} {
+		if _, err := NormalizeOpenAIChatRequest(nil, chat(extra), ""); err != nil {
+			t.Fatalf("%v: unexpected error: %v", extra, err)
+		}
+	}
+	var unsupported *UnsupportedParameterError
+	for _, tc := range []struct {
+		extra map[string]any
+		param string
+	}{
+		{map[string]any{"logprobs": true}, "logprobs"},
+		{map[string]any{"logprobs": true, "top_logprobs": 0.0}, "logprobs"},
+		{map[string]any{"logprobs": true, "top_logprobs": 5.0}, "top_logprobs"},
+	} {
+		_, err := NormalizeOpenAIChatRequest(nil, chat(tc.extra), "")
+		if !errors.As(err, &unsupported) || unsupported.Param != tc.param {
+			t.Fatalf("%v: expected unsupported %s, got %v", tc.extra, tc.param, err)
+		}
+	}
+	for _, extra := range []map[string]any{{"logprobs": "yes"}, {"top_logprobs": 3.0}, {"logprobs": true, "top_logprobs": 21.0}} {
+		_, err := NormalizeOpenAIChatRequest(nil, chat(extra), "")
+		if err == nil || errors.As(err, &unsupported) {
+			t.Fatalf("%v: expected a validation error, got %v", extra, err)
+		}
+	}
+	responses := map[string]any{"model": "deepseek-v4-flash", "input": "hi", "include": []any{"message.output_text.logprobs"}}
+	if _, err := NormalizeOpenAIResponsesRequest(nil, responses, ""); !errors.As(err, &unsupported) || unsupported.Param != "include" {
+		t.Fatalf("expected responses include to be rejected, got %v", err)
+	}
+}
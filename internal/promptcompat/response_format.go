@@ -0,0 +1,107 @@
+package promptcompat
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+type ResponseFormatType string
+
+const (
+	ResponseFormatText       ResponseFormatType = ""
+	ResponseFormatJSONObject ResponseFormatType = "json_object"
+	ResponseFormatJSONSchema ResponseFormatType = "json_schema"
+)
+
+// ResponseFormat is the normalized form of OpenAI chat `response_format` and
+// Responses `text.format`. Only JSON modes change prompt building and output
+// validation; plain text keeps the zero value.
+type ResponseFormat struct {
+	Type   ResponseFormatType
+	Name   string
+	Schema map[string]any
+	Strict bool
+}
+
+func (f ResponseFormat) IsJSON() bool {
+	return f.Type == ResponseFormatJSONObject || f.Type == ResponseFormatJSONSchema
+}
+
+// JSONFormatRetryInstruction is appended to the prompt when a JSON-mode reply
+// could not be parsed or validated and the request is retried once.
+const JSONFormatRetryInstruction = "Your previous reply was not valid JSON for the required format. Reply again with ONLY the JSON value: no markdown code fences, no comments, no text before or after it."
+
+func parseResponseFormat(raw any) (ResponseFormat, error) {
+	if raw == nil {
+		return ResponseFormat{}, nil
+	}
+	m, ok := raw.(map[string]any)
+	if !ok {
+		return ResponseFormat{}, fmt.Errorf("response_format must be an object")
+	}
+	typ, _ := m["type"].(string)
+	switch strings.ToLower(strings.TrimSpace(typ)) {
+	case "", "text":
+		return ResponseFormat{}, nil
+	case string(ResponseFormatJSONObject):
+		return ResponseFormat{Type: ResponseFormatJSONObject}, nil
+	case string(ResponseFormatJSONSchema):
+		// Chat nests the schema under json_schema; Responses text.format
+		// carries name/schema/strict on the format object itself.
+		spec := m
+		if nested, ok := m["json_schema"].(map[string]any); ok {
+			spec = nested
+		}
+		schema, ok := spec["schema"].(map[string]any)
+		if !ok {
+			return ResponseFormat{}, fmt.Errorf("response_format json_schema requires a 'schema' object")
+		}
+		name, _ := spec["name"].(string)
+		strict, _ := spec["strict"].(bool)
+		return ResponseFormat{Type: ResponseFormatJSONSchema, Name: strings.TrimSpace(name), Schema: schema, Strict: strict}, nil
+	default:
+		return ResponseFormat{}, fmt.Errorf("unsupported response_format type: %q", typ)
+	}
+}
+
+// parseResponsesTextFormat reads the Responses API `text.format` object and
+// falls back to a chat-style `response_format` when present.
+func parseResponsesTextFormat(req map[string]any) (ResponseFormat, error) {
+	if text, ok := req["text"].(map[string]any); ok && text["format"] != nil {
+		return parseResponseFormat(text["format"])
+	}
+	return parseResponseFormat(req["response_format"])
+}
+
+func responseFormatInstruction(f ResponseFormat) string {
+	switch f.Type {
+	case ResponseFormatJSONObject:
+		return "Response format: reply with a single valid JSON value only. Do not wrap it in markdown code fences and do not add any text before or after the JSON."
+	case ResponseFormatJSONSchema:
+		schema, err := json.Marshal(f.Schema)
+		if err != nil {
+			schema = []byte("{}")
+		}
+		label := "the following JSON Schema"
+		if f.Name != "" {
+			label = "the JSON Schema \"" + f.Name + "\""
+		}
+		return "Response format: reply with a single valid JSON value that conforms to " + label + ". Do not wrap it in markdown code fences and do not add any text before or after the JSON.\nJSON Schema:\n" + string(schema)
+	default:
+		return ""
+	}
+}
+
+// applyResponseFormatInstruction prepends the JSON-mode instruction as a
+// system message so rebuilding the prompt from StandardRequest.Messages keeps
+// it in place.
+func applyResponseFormatInstruction(messages []any, f ResponseFormat) []any {
+	instruction := responseFormatInstruction(f)
+	if instruction == "" {
+		return messages
+	}
+	out := make([]any, 0, len(messages)+1)
+	out = append(out, map[string]any{"role": "system", "content": instruction})
+	return append(out, messages...)
+}
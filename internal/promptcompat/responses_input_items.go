@@ -42,9 +42,9 @@ func normalizeResponsesInputItemWithState(m map[string]any, callNameByID map[str
 			if callID := strings.TrimSpace(asString(m["call_id"])); callID != "" {
 				out["tool_call_id"] = callID
 			}
-			if name := strings.TrimSpace(asString(m["name"])); name != "" {
-				out["name"] = name
-			}
+		}
+		if name := strings.TrimSpace(asString(m["name"])); name != "" {
+			out["name"] = name
 		}
 		return out
 	}
@@ -0,0 +1,76 @@
+package promptcompat
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+
+	"ds2api/internal/config"
+)
+
+type samplingRange struct {
+	key      string
+	min, max float64
+}
+
+// samplingRanges are the bounds DeepSeek accepts for each sampling field.
+var samplingRanges = []samplingRange{
+	{key: "temperature", min: 0, max: 2},
+	{key: "top_p", min: 0, max: 1},
+	{key: "presence_penalty", min: -2, max: 2},
+	{key: "frequency_penalty", min: -2, max: 2},
+}
+
+// ApplySamplingParams validates the sampling fields already copied into
+// passThrough. A non-number is always an error. An out-of-range number is
+// clamped and logged, or rejected when strict is set, so only values the
+// backend accepts are forwarded. An explicit null drops the field.
+func ApplySamplingParams(passThrough map[string]any, strict bool, traceID string) error {
+	for _, r := range samplingRanges {
+		raw, ok := passThrough[r.key]
+		if !ok {
+			continue
+		}
+		if raw == nil {
+			delete(passThrough, r.key)
+			continue
+		}
+		v, ok := samplingNumber(raw)
+		if !ok {
+			return fmt.Errorf("%s must be a number", r.key)
+		}
+		clamped := math.Min(math.Max(v, r.min), r.max)
+		if clamped != v {
+			if strict {
+				return fmt.Errorf("%s must be between %g and %g, got %g", r.key, r.min, r.max, v)
+			}
+			config.Logger.Info("[sampling] clamped out-of-range parameter", "trace_id", traceID, "param", r.key, "requested", v, "forwarded", clamped)
+		}
+		passThrough[r.key] = clamped
+	}
+	return nil
+}
+
+func samplingNumber(raw any) (float64, bool) {
+	var f float64
+	switch x := raw.(type) {
+	case float64:
+		f = x
+	case int:
+		f = float64(x)
+	case int64:
+		f = float64(x)
+	case json.Number:
+		parsed, err := x.Float64()
+		if err != nil {
+			return 0, false
+		}
+		f = parsed
+	default:
+		return 0, false
+	}
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return 0, false
+	}
+	return f, true
+}
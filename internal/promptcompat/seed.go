@@ -0,0 +1,53 @@
+package promptcompat
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+
+	"ds2api/internal/config"
+)
+
+// maxSeed keeps seeds inside the range a JSON number carries exactly.
+const maxSeed = 1<<53 - 1
+
+// ParseSeed validates OpenAI `seed`. A missing seed reports ok=false; any
+// integer is forwarded upstream unchanged.
+func ParseSeed(raw any) (seed int64, ok bool, err error) {
+	var f float64
+	switch x := raw.(type) {
+	case nil:
+		return 0, false, nil
+	case int:
+		f = float64(x)
+	case int64:
+		f = float64(x)
+	case float64:
+		f = x
+	case json.Number:
+		parsed, perr := x.Float64()
+		if perr != nil {
+			return 0, false, fmt.Errorf("seed must be an integer")
+		}
+		f = parsed
+	default:
+		return 0, false, fmt.Errorf("seed must be an integer")
+	}
+	if f != math.Trunc(f) || math.Abs(f) > maxSeed {
+		return 0, false, fmt.Errorf("seed must be an integer")
+	}
+	return int64(f), true, nil
+}
+
+// applySeed forwards a validated seed with the other sampling parameters.
+// DeepSeek does not document seed support, so outputs stay best-effort:
+// only `system_fingerprint` is guaranteed to repeat for identical requests.
+func applySeed(passThrough map[string]any, req map[string]any, model, traceID string) error {
+	seed, ok, err := ParseSeed(req["seed"])
+	if err != nil || !ok {
+		return err
+	}
+	passThrough["seed"] = seed
+	config.Logger.Info("[seed] forwarded upstream; DeepSeek may ignore it, so determinism is not guaranteed", "trace_id", traceID, "model", model, "seed", seed)
+	return nil
+}
@@ -1,12 +1,24 @@
 package promptcompat
 
-import "ds2api/internal/config"
+import (
+	"ds2api/internal/config"
+	"ds2api/internal/toolcall"
+)
 
 type StandardRequest struct {
-	Surface                 string
-	RequestedModel          string
-	ResolvedModel           string
-	ResponseModel           string
+	Surface        string
+	RequestedModel string
+	ResolvedModel  string
+	ResponseModel  string
+	// FallbackModels are the DeepSeek models tried in order when the
+	// upstream call for ResolvedModel fails before any output. A
+	// "backend:<name>" entry switches to the API backend of that name.
+	FallbackModels []string
+	// FallbackBackends holds the API backends named by FallbackModels.
+	FallbackBackends map[string]config.APIBackendConfig
+	// Backend names the API backend serving the request; empty means the
+	// DeepSeek web client.
+	Backend                 string
 	Messages                []any
 	HistoryText             string
 	PromptTokenText         string
@@ -17,12 +29,33 @@ type StandardRequest struct {
 	FinalPrompt             string
 	ToolNames               []string
 	ToolChoice              ToolChoicePolicy
-	Stream                  bool
-	Thinking                bool
-	Search                  bool
-	RefFileIDs              []string
-	RefFileTokens           int
-	PassThrough             map[string]any
+	// ToolPrompt is the tool call format and prompt template captured when
+	// the request was normalized. The prompt, the stream sieve and the final
+	// parse all use it, so a config reload never affects a running request.
+	ToolPrompt     toolcall.PromptSettings
+	ResponseFormat ResponseFormat
+	StopSequences  []string
+	// MaxOutputTokens caps the visible answer text; zero means no limit.
+	MaxOutputTokens int
+	// BannedWords come from strongly negative `logit_bias` entries and are
+	// filtered out of the answer text.
+	BannedWords []string
+	// Choices is the chat `n` parameter: how many independent generations
+	// of the same prompt to return. Zero is treated as one.
+	Choices int
+	// ContextTrimmedMessages counts the oldest messages removed to fit the
+	// configured context window.
+	ContextTrimmedMessages int
+	Stream                 bool
+	IncludeUsage           bool
+	Thinking               bool
+	Search                 bool
+	RefFileIDs             []string
+	RefFileTokens          int
+	PassThrough            map[string]any
+	// LegacyFunctions marks a chat request that declared its tools with the
+	// deprecated `functions` field; its response uses `function_call`.
+	LegacyFunctions bool
 }
 
 type ToolChoiceMode string
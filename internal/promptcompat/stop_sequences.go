@@ -0,0 +1,43 @@
+package promptcompat
+
+import "fmt"
+
+// maxStopSequences mirrors the OpenAI limit on the `stop` parameter.
+const maxStopSequences = 4
+
+// ParseStopSequences normalizes an OpenAI `stop` value: a single string or an
+// array of up to four strings. Empty strings are ignored.
+func ParseStopSequences(raw any) ([]string, error) {
+	var items []any
+	switch x := raw.(type) {
+	case nil:
+		return nil, nil
+	case string:
+		items = []any{x}
+	case []string:
+		for _, s := range x {
+			items = append(items, s)
+		}
+	case []any:
+		items = x
+	default:
+		return nil, fmt.Errorf("stop must be a string or an array of strings")
+	}
+	if len(items) > maxStopSequences {
+		return nil, fmt.Errorf("stop supports at most %d sequences", maxStopSequences)
+	}
+	out := make([]string, 0, len(items))
+	for _, item := range items {
+		s, ok := item.(string)
+		if !ok {
+			return nil, fmt.Errorf("stop must be a string or an array of strings")
+		}
+		if s != "" {
+			out = append(out, s)
+		}
+	}
+	if len(out) == 0 {
+		return nil, nil
+	}
+	return out, nil
+}
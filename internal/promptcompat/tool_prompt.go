@@ -1,7 +1,6 @@
 package promptcompat
 
 import (
-	"encoding/json"
 	"fmt"
 	"strings"
 	"unicode"
@@ -18,28 +17,34 @@ type toolPromptParts struct {
 	Descriptions string
 	Instructions string
 	Names        []string
+	// Templated marks Instructions as rendered by the configured tool prompt
+	// template, which presents the tools itself.
+	Templated bool
 }
 
-func injectToolPrompt(messages []map[string]any, tools []any, policy ToolChoicePolicy) ([]map[string]any, []string) {
-	return injectToolPromptWithDescriptions(messages, tools, policy, true)
+func injectToolPrompt(messages []map[string]any, tools []any, policy ToolChoicePolicy, settings toolcall.PromptSettings) ([]map[string]any, []string) {
+	return injectToolPromptWithDescriptions(messages, tools, policy, settings, true)
 }
 
-func injectToolPromptInstructionsOnly(messages []map[string]any, tools []any, policy ToolChoicePolicy) ([]map[string]any, []string) {
-	return injectToolPromptWithDescriptions(messages, tools, policy, false)
+func injectToolPromptInstructionsOnly(messages []map[string]any, tools []any, policy ToolChoicePolicy, settings toolcall.PromptSettings) ([]map[string]any, []string) {
+	return injectToolPromptWithDescriptions(messages, tools, policy, settings, false)
 }
 
-func injectToolPromptWithDescriptions(messages []map[string]any, tools []any, policy ToolChoicePolicy, includeDescriptions bool) ([]map[string]any, []string) {
+func injectToolPromptWithDescriptions(messages []map[string]any, tools []any, policy ToolChoicePolicy, settings toolcall.PromptSettings, includeDescriptions bool) ([]map[string]any, []string) {
 	if policy.IsNone() {
 		return messages, nil
 	}
-	parts := buildToolPromptParts(tools, policy)
+	parts := buildToolPromptParts(tools, policy, settings, !includeDescriptions)
 	if parts.Instructions == "" {
 		return messages, parts.Names
 	}
 	toolPrompt := parts.Instructions
-	if includeDescriptions && parts.Descriptions != "" {
+	switch {
+	case parts.Templated && includeDescriptions:
+		// The template lists the tools itself.
+	case includeDescriptions && parts.Descriptions != "":
 		toolPrompt = parts.Descriptions + "\n\n" + toolPrompt
-	} else if !includeDescriptions && parts.Descriptions != "" {
+	case !includeDescriptions && parts.Descriptions != "":
 		toolPrompt = "Available tool descriptions and parameter schemas are attached in DS2API_TOOLS.txt. Treat DS2API_TOOLS.txt as the authoritative list of callable tools and schemas; use only tools and parameters listed there.\n\n" + toolPrompt
 	}
 
@@ -54,9 +59,10 @@ func injectToolPromptWithDescriptions(messages []map[string]any, tools []any, po
 	return messages, parts.Names
 }
 
-func buildToolPromptParts(tools []any, policy ToolChoicePolicy) toolPromptParts {
+func buildToolPromptParts(tools []any, policy ToolChoicePolicy, settings toolcall.PromptSettings, toolsAttached bool) toolPromptParts {
 	toolSchemas := make([]string, 0, len(tools))
 	names := make([]string, 0, len(tools))
+	promptTools := make([]toolcall.PromptTool, 0, len(tools))
 	isAllowed := func(name string) bool {
 		if strings.TrimSpace(name) == "" {
 			return false
@@ -82,14 +88,23 @@ func buildToolPromptParts(tools []any, policy ToolChoicePolicy) toolPromptParts
 		if desc == "" {
 			desc = "No description available"
 		}
-		b, _ := json.Marshal(schema)
-		toolSchemas = append(toolSchemas, fmt.Sprintf("Tool: %s\nDescription: %s\nParameters: %s", name, desc, string(b)))
+		toolSchemas = append(toolSchemas, fmt.Sprintf("Tool: %s\nDescription: %s\n%s", name, desc, toolcall.FormatToolParameters(schema)))
+		promptTools = append(promptTools, toolcall.NewPromptTool(name, desc, schema))
 	}
 	if len(toolSchemas) == 0 {
 		return toolPromptParts{Names: names}
 	}
 	descriptions := "You have access to these tools:\n\n" + strings.Join(toolSchemas, "\n\n")
-	instructions := toolcall.BuildToolCallInstructions(names)
+	if rendered, ok := settings.Render(toolcall.PromptData{
+		Tools:         promptTools,
+		ToolNames:     names,
+		Required:      policy.Mode == ToolChoiceRequired,
+		ForcedName:    forcedToolName(policy),
+		ToolsAttached: toolsAttached,
+	}); ok {
+		return toolPromptParts{Descriptions: descriptions, Instructions: rendered, Names: names, Templated: true}
+	}
+	instructions := settings.Instructions(names)
 	if hasReadLikeTool(names) {
 		instructions += "\n\nRead-tool cache guard: If a Read/read_file-style tool result says the file is unchanged, already available in history, should be referenced from previous context, or otherwise provides no file body, treat that result as missing content. Do not repeatedly call the same read request for that missing body. Request a full-content read if the tool supports it, or tell the user that the file contents need to be provided again."
 	}
@@ -107,7 +122,7 @@ func buildToolPromptParts(tools []any, policy ToolChoicePolicy) toolPromptParts
 	}
 }
 
-func BuildOpenAIToolsContextTranscript(toolsRaw any, policy ToolChoicePolicy) (string, []string) {
+func BuildOpenAIToolsContextTranscript(toolsRaw any, policy ToolChoicePolicy, settings toolcall.PromptSettings) (string, []string) {
 	if policy.IsNone() {
 		return "", nil
 	}
@@ -115,7 +130,7 @@ func BuildOpenAIToolsContextTranscript(toolsRaw any, policy ToolChoicePolicy) (s
 	if !ok || len(tools) == 0 {
 		return "", nil
 	}
-	parts := buildToolPromptParts(tools, policy)
+	parts := buildToolPromptParts(tools, policy, settings, true)
 	if strings.TrimSpace(parts.Descriptions) == "" {
 		return "", parts.Names
 	}
@@ -129,6 +144,13 @@ func BuildOpenAIToolsContextTranscript(toolsRaw any, policy ToolChoicePolicy) (s
 	return b.String(), parts.Names
 }
 
+func forcedToolName(policy ToolChoicePolicy) string {
+	if policy.Mode != ToolChoiceForced {
+		return ""
+	}
+	return strings.TrimSpace(policy.ForcedName)
+}
+
 func hasReadLikeTool(names []string) bool {
 	for _, name := range names {
 		switch normalizeToolNameForGuard(name) {
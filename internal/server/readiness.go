@@ -0,0 +1,60 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// readinessProbeTimeout bounds one upstream probe, so a hung DeepSeek
+// connection fails readiness instead of stalling the kubelet.
+const readinessProbeTimeout = 10 * time.Second
+
+// readiness caches the outcome of the upstream probe for the configured
+// interval. Concurrent /readyz calls during a probe wait for its result, so
+// a flood of probes reaches DeepSeek at most once per interval.
+type readiness struct {
+	probe    func(context.Context) error
+	interval func() time.Duration
+	now      func() time.Time
+
+	mu        sync.Mutex
+	checkedAt time.Time
+	lastErr   error
+}
+
+func newReadiness(probe func(context.Context) error, interval func() time.Duration) *readiness {
+	return &readiness{probe: probe, interval: interval, now: time.Now}
+}
+
+func (p *readiness) check() (time.Time, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if !p.checkedAt.IsZero() && p.now().Sub(p.checkedAt) < p.interval() {
+		return p.checkedAt, p.lastErr
+	}
+	// Detached from the caller so a probe client hanging up early does not
+	// cache a cancellation as an upstream failure.
+	ctx, cancel := context.WithTimeout(context.Background(), readinessProbeTimeout)
+	defer cancel()
+	p.lastErr = p.probe(ctx)
+	p.checkedAt = p.now()
+	return p.checkedAt, p.lastErr
+}
+
+func (p *readiness) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	checkedAt, err := p.check()
+	body := map[string]any{"status": "ready", "checked_at": checkedAt.UTC().Format(time.RFC3339)}
+	status := http.StatusOK
+	if err != nil {
+		body["status"] = "unavailable"
+		body["error"] = err.Error()
+		status = http.StatusServiceUnavailable
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(body)
+}
@@ -23,13 +23,22 @@ import (
 	"ds2api/internal/httpapi/admin"
 	"ds2api/internal/httpapi/claude"
 	"ds2api/internal/httpapi/gemini"
+	"ds2api/internal/httpapi/idempotency"
 	"ds2api/internal/httpapi/ollama"
+	"ds2api/internal/httpapi/openai/batches"
 	"ds2api/internal/httpapi/openai/chat"
+	"ds2api/internal/httpapi/openai/completions"
 	"ds2api/internal/httpapi/openai/embeddings"
 	"ds2api/internal/httpapi/openai/files"
 	"ds2api/internal/httpapi/openai/responses"
 	"ds2api/internal/httpapi/openai/shared"
+	"ds2api/internal/httpapi/ratelimit"
 	"ds2api/internal/httpapi/requestbody"
+	"ds2api/internal/httpapi/requestctx"
+	"ds2api/internal/httpapi/requestlog"
+	"ds2api/internal/metrics"
+	"ds2api/internal/prompt"
+	"ds2api/internal/toolcall"
 	"ds2api/internal/webui"
 )
 
@@ -39,6 +48,10 @@ type App struct {
 	Resolver *auth.Resolver
 	DS       *dsclient.Client
 	Router   http.Handler
+	// Drainer tracks in-flight API requests for graceful shutdown.
+	Drainer *requestctx.Drainer
+
+	readiness *readiness
 }
 
 func NewApp() (*App, error) {
@@ -46,6 +59,13 @@ func NewApp() (*App, error) {
 	if err != nil {
 		return nil, fmt.Errorf("load config: %w", err)
 	}
+	toolPrompt := store.ToolPromptSettings()
+	toolPromptTemplate, err := toolcall.LoadPromptTemplate(toolPrompt.TemplateFile)
+	if err != nil {
+		return nil, fmt.Errorf("load tool prompt template: %w", err)
+	}
+	toolcall.ConfigurePrompt(toolPrompt.Format, toolPromptTemplate)
+	prompt.ConfigureMessageMerge(store.RuntimeMergeConsecutiveMessages)
 	pool := account.NewPool(store)
 	var dsClient *dsclient.Client
 	resolver := auth.NewResolver(store, pool, func(ctx context.Context, acc config.Account) (string, error) {
@@ -64,41 +84,56 @@ func NewApp() (*App, error) {
 
 	modelsHandler := &shared.ModelsHandler{Store: store}
 	chatHandler := &chat.Handler{Store: store, Auth: resolver, DS: dsClient, ChatHistory: chatHistoryStore}
+	completionsHandler := &completions.Handler{Store: store, Auth: resolver, DS: dsClient}
 	responsesHandler := &responses.Handler{Store: store, Auth: resolver, DS: dsClient, ChatHistory: chatHistoryStore}
 	filesHandler := &files.Handler{Store: store, Auth: resolver, DS: dsClient, ChatHistory: chatHistoryStore}
 	embeddingsHandler := &embeddings.Handler{Store: store, Auth: resolver, DS: dsClient, ChatHistory: chatHistoryStore}
 	claudeHandler := &claude.Handler{Store: store, Auth: resolver, DS: dsClient, OpenAI: chatHandler, ChatHistory: chatHistoryStore}
 	geminiHandler := &gemini.Handler{Store: store, Auth: resolver, DS: dsClient, OpenAI: chatHandler, ChatHistory: chatHistoryStore}
 	adminHandler := &admin.Handler{Store: store, Pool: pool, DS: dsClient, OpenAI: chatHandler, ChatHistory: chatHistoryStore}
-	ollamaHandler := &ollama.Handler{Store: store}
+	batchesHandler := &batches.Handler{Store: store, Auth: resolver}
+	ollamaHandler := &ollama.Handler{Store: store, Auth: resolver, DS: dsClient, ChatHistory: chatHistoryStore}
 	webuiHandler := webui.NewHandler()
 
 	r := chi.NewRouter()
-	r.Use(middleware.RequestID)
+	// Batch requests re-enter the router so they get the same middleware as
+	// interactive ones.
+	batchesHandler.Dispatch = r
+	r.Use(requestctx.TraceID)
 	r.Use(middleware.RealIP)
 	r.Use(filteredLogger())
-	r.Use(middleware.Recoverer)
-	r.Use(cors)
+	r.Use(shared.RecoverPanics)
+	r.Use(corsMiddleware(store.CORSSettings))
+	r.Use(requestbody.LimitSize(store.RuntimeMaxRequestBodyBytes))
 	r.Use(requestbody.ValidateJSONUTF8)
-	r.Use(timeout(0))
+	drainer := requestctx.NewDrainer()
+	r.Use(drainer.Track)
+	r.Use(requestctx.Deadline(func() time.Duration {
+		return time.Duration(store.RuntimeRequestTimeoutSeconds()) * time.Second
+	}))
+	r.Use(requestlog.Middleware(store.RequestLogSettings))
+	r.Use(metrics.Middleware)
+	r.Use(idempotency.Middleware(store.IdempotencySettings))
+	r.Use(ratelimit.Middleware(store.RateLimitSettings))
 
 	healthzHandler := func(w http.ResponseWriter, _ *http.Request) {
 		w.Header().Set("Content-Type", "application/json")
 		w.WriteHeader(http.StatusOK)
 		_, _ = w.Write([]byte(`{"status":"ok"}`))
 	}
-	readyzHandler := func(w http.ResponseWriter, _ *http.Request) {
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusOK)
-		_, _ = w.Write([]byte(`{"status":"ready"}`))
-	}
+	// Liveness only needs the process; readiness also probes DeepSeek.
+	readyz := newReadiness(dsClient.Probe, func() time.Duration {
+		return time.Duration(store.RuntimeReadinessCacheSeconds()) * time.Second
+	})
 	r.Get("/healthz", healthzHandler)
 	r.Head("/healthz", healthzHandler)
-	r.Get("/readyz", readyzHandler)
-	r.Head("/readyz", readyzHandler)
+	r.Method(http.MethodGet, "/readyz", readyz)
+	r.Method(http.MethodHead, "/readyz", readyz)
+	r.Get("/metrics", metrics.Handler)
 	r.Get("/v1/models", modelsHandler.ListModels)
 	r.Get("/v1/models/{model_id}", modelsHandler.GetModel)
 	r.Post("/v1/chat/completions", chatHandler.ChatCompletions)
+	r.Post("/v1/completions", completionsHandler.Completions)
 	r.Post("/v1/responses", responsesHandler.Responses)
 	r.Get("/v1/responses/{response_id}", responsesHandler.GetResponseByID)
 	r.Post("/v1/files", filesHandler.UploadFile)
@@ -108,11 +143,13 @@ func NewApp() (*App, error) {
 	r.Get("/models", modelsHandler.ListModels)
 	r.Get("/models/{model_id}", modelsHandler.GetModel)
 	r.Post("/chat/completions", chatHandler.ChatCompletions)
+	r.Post("/completions", completionsHandler.Completions)
 	r.Post("/responses", responsesHandler.Responses)
 	r.Get("/responses/{response_id}", responsesHandler.GetResponseByID)
 	r.Post("/files", filesHandler.UploadFile)
 	r.Get("/files/{file_id}", filesHandler.RetrieveFile)
 	r.Post("/embeddings", embeddingsHandler.Embeddings)
+	batches.RegisterRoutes(r, batchesHandler)
 	claude.RegisterRoutes(r, claudeHandler)
 	gemini.RegisterRoutes(r, geminiHandler)
 	ollama.RegisterRoutes(r, ollamaHandler)
@@ -124,17 +161,21 @@ func NewApp() (*App, error) {
 		if strings.HasPrefix(req.URL.Path, "/admin/") && webuiHandler.HandleAdminFallback(w, req) {
 			return
 		}
+		if strings.HasPrefix(req.URL.Path, "/v1/") {
+			shared.WriteOpenAIErrorWithCode(w, http.StatusNotFound, "Unknown API route: "+req.Method+" "+req.URL.Path, "")
+			return
+		}
 		http.NotFound(w, req)
 	})
+	r.MethodNotAllowed(func(w http.ResponseWriter, req *http.Request) {
+		if !strings.HasPrefix(req.URL.Path, "/v1/") {
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+		shared.WriteOpenAIErrorWithCode(w, http.StatusMethodNotAllowed, "Method "+req.Method+" is not allowed for "+req.URL.Path, "method_not_allowed")
+	})
 
-	return &App{Store: store, Pool: pool, Resolver: resolver, DS: dsClient, Router: r}, nil
-}
-
-func timeout(d time.Duration) func(http.Handler) http.Handler {
-	if d <= 0 {
-		return func(next http.Handler) http.Handler { return next }
-	}
-	return middleware.Timeout(d)
+	return &App{Store: store, Pool: pool, Resolver: resolver, DS: dsClient, Router: r, Drainer: drainer, readiness: readyz}, nil
 }
 
 func filteredLogger() func(http.Handler) http.Handler {
@@ -260,152 +301,6 @@ func isSensitiveQueryParam(name string) bool {
 	return strings.EqualFold(name, "key") || strings.EqualFold(name, "api_key")
 }
 
-var defaultCORSAllowHeaders = []string{
-	"Content-Type",
-	"Authorization",
-	"X-API-Key",
-	"X-Ds2-Target-Account",
-	"X-Ds2-Source",
-	"X-Vercel-Protection-Bypass",
-	"X-Goog-Api-Key",
-	"Anthropic-Version",
-	"Anthropic-Beta",
-}
-
-var blockedCORSRequestHeaders = map[string]struct{}{
-	"x-ds2-internal-token": {},
-}
-
-func cors(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		setCORSHeaders(w, r)
-		if r.Method == http.MethodOptions {
-			w.WriteHeader(http.StatusNoContent)
-			return
-		}
-		next.ServeHTTP(w, r)
-	})
-}
-
-func setCORSHeaders(w http.ResponseWriter, r *http.Request) {
-	origin := strings.TrimSpace(r.Header.Get("Origin"))
-	if origin == "" {
-		w.Header().Set("Access-Control-Allow-Origin", "*")
-	} else {
-		w.Header().Set("Access-Control-Allow-Origin", origin)
-		addVaryHeaderToken(w.Header(), "Origin")
-	}
-	w.Header().Set("Access-Control-Allow-Methods", "GET, POST, OPTIONS, PUT, DELETE")
-	w.Header().Set("Access-Control-Allow-Headers", buildCORSAllowHeaders(r))
-	w.Header().Set("Access-Control-Max-Age", "600")
-	addVaryHeaderToken(w.Header(), "Access-Control-Request-Headers")
-	if strings.EqualFold(strings.TrimSpace(r.Header.Get("Access-Control-Request-Private-Network")), "true") {
-		w.Header().Set("Access-Control-Allow-Private-Network", "true")
-		addVaryHeaderToken(w.Header(), "Access-Control-Request-Private-Network")
-	}
-}
-
-func buildCORSAllowHeaders(r *http.Request) string {
-	names := make([]string, 0, len(defaultCORSAllowHeaders)+4)
-	seen := make(map[string]struct{}, len(defaultCORSAllowHeaders)+4)
-	for _, name := range defaultCORSAllowHeaders {
-		appendCORSHeaderName(&names, seen, name)
-	}
-	if r == nil {
-		return strings.Join(names, ", ")
-	}
-	for _, name := range splitCORSRequestHeaders(r.Header.Get("Access-Control-Request-Headers")) {
-		appendCORSHeaderName(&names, seen, name)
-	}
-	return strings.Join(names, ", ")
-}
-
-func splitCORSRequestHeaders(raw string) []string {
-	if strings.TrimSpace(raw) == "" {
-		return nil
-	}
-	parts := strings.Split(raw, ",")
-	out := make([]string, 0, len(parts))
-	for _, part := range parts {
-		name := strings.TrimSpace(part)
-		if !isValidCORSHeaderToken(name) {
-			continue
-		}
-		if _, blocked := blockedCORSRequestHeaders[strings.ToLower(name)]; blocked {
-			continue
-		}
-		out = append(out, name)
-	}
-	return out
-}
-
-func appendCORSHeaderName(dst *[]string, seen map[string]struct{}, name string) {
-	name = strings.TrimSpace(name)
-	if !isValidCORSHeaderToken(name) {
-		return
-	}
-	key := strings.ToLower(name)
-	if _, blocked := blockedCORSRequestHeaders[key]; blocked {
-		return
-	}
-	if _, ok := seen[key]; ok {
-		return
-	}
-	seen[key] = struct{}{}
-	*dst = append(*dst, name)
-}
-
-func isValidCORSHeaderToken(v string) bool {
-	if v == "" {
-		return false
-	}
-	for i := 0; i < len(v); i++ {
-		c := v[i]
-		if (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9') {
-			continue
-		}
-		switch c {
-		case '!', '#', '$', '%', '&', '\'', '*', '+', '-', '.', '^', '_', '`', '|', '~':
-			continue
-		default:
-			return false
-		}
-	}
-	return true
-}
-
-func addVaryHeaderToken(h http.Header, token string) {
-	if h == nil {
-		return
-	}
-	token = strings.TrimSpace(token)
-	if token == "" {
-		return
-	}
-	current := h.Values("Vary")
-	seen := map[string]struct{}{}
-	merged := make([]string, 0, len(current)+1)
-	for _, value := range current {
-		for _, part := range strings.Split(value, ",") {
-			name := strings.TrimSpace(part)
-			if name == "" {
-				continue
-			}
-			key := strings.ToLower(name)
-			if _, ok := seen[key]; ok {
-				continue
-			}
-			seen[key] = struct{}{}
-			merged = append(merged, name)
-		}
-	}
-	key := strings.ToLower(token)
-	if _, ok := seen[key]; !ok {
-		merged = append(merged, token)
-	}
-	h.Set("Vary", strings.Join(merged, ", "))
-}
-
 func WriteUnhandledError(w http.ResponseWriter, err error) {
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusInternalServerError)
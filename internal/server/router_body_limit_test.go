@@ -0,0 +1,46 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestOversizedBodiesReturn413OnEveryProtocol(t *testing.T) {
+	t.Setenv("DS2API_CONFIG_JSON", `{"keys":["managed-key"],"accounts":[{"email":"u@example.com","password":"p"}],"runtime":{"max_request_body_mb":1}}`)
+	t.Setenv("DS2API_ENV_WRITEBACK", "0")
+
+	app, err := NewApp()
+	if err != nil {
+		t.Fatalf("NewApp() error: %v", err)
+	}
+	body := `{"model":"deepseek-v4-flash","messages":[{"role":"user","content":"` + strings.Repeat("a", 2<<20) + `"}]}`
+
+	for _, path := range []string{"/v1/chat/completions", "/v1/responses", "/anthropic/v1/messages", "/v1beta/models/gemini-2.5-pro:generateContent", "/api/chat"} {
+		req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("x-api-key", "direct-token")
+		rec := httptest.NewRecorder()
+		app.Router.ServeHTTP(rec, req)
+		if rec.Code != http.StatusRequestEntityTooLarge {
+			t.Fatalf("%s: expected 413, got %d body=%q", path, rec.Code, rec.Body.String())
+		}
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("x-api-key", "direct-token")
+	rec := httptest.NewRecorder()
+	app.Router.ServeHTTP(rec, req)
+	var out struct {
+		Error struct {
+			Type    string `json:"type"`
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &out); err != nil || out.Error.Type != "invalid_request_error" || out.Error.Message != "request body too large" {
+		t.Fatalf("expected OpenAI invalid_request_error, got %q", rec.Body.String())
+	}
+}
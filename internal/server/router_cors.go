@@ -0,0 +1,225 @@
+package server
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/go-chi/chi/v5/middleware"
+
+	"ds2api/internal/config"
+	"ds2api/internal/httpapi/openai/shared"
+)
+
+var defaultCORSAllowHeaders = []string{
+	"Content-Type",
+	"Authorization",
+	"X-API-Key",
+	"X-Ds2-Target-Account",
+	"X-Ds2-Source",
+	"X-Vercel-Protection-Bypass",
+	"X-Goog-Api-Key",
+	"Anthropic-Version",
+	"Anthropic-Beta",
+}
+
+// requiredCORSAllowHeaders stay allowed when cors.allowed_headers replaces
+// the defaults, since no API call works without them.
+var requiredCORSAllowHeaders = []string{"Content-Type", "Authorization"}
+
+const defaultCORSAllowMethods = "GET, POST, OPTIONS, PUT, DELETE"
+
+var blockedCORSRequestHeaders = map[string]struct{}{
+	"x-ds2-internal-token": {},
+}
+
+// corsMiddleware applies the cors config to every route and answers OPTIONS
+// preflights with 204. Headers are set before the handler runs, so streamed
+// responses carry them too.
+func corsMiddleware(settings func() config.CORSConfig) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			setCORSHeaders(w, r, settings())
+			if r.Method == http.MethodOptions {
+				w.WriteHeader(http.StatusNoContent)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// setCORSHeaders echoes an allowed Origin. Without configured origins, or for
+// an origin that matches none, no CORS headers are sent and browsers block the
+// cross-origin call.
+func setCORSHeaders(w http.ResponseWriter, r *http.Request, cfg config.CORSConfig) {
+	if len(cfg.AllowedOrigins) == 0 {
+		return
+	}
+	addVaryHeaderToken(w.Header(), "Origin")
+	origin := strings.TrimSpace(r.Header.Get("Origin"))
+	if origin == "" || !corsOriginAllowed(cfg.AllowedOrigins, origin) {
+		return
+	}
+	w.Header().Set("Access-Control-Allow-Origin", origin)
+	w.Header().Set("Access-Control-Allow-Methods", buildCORSAllowMethods(cfg.AllowedMethods))
+	w.Header().Set("Access-Control-Allow-Headers", buildCORSAllowHeaders(r, cfg.AllowedHeaders))
+	w.Header().Set("Access-Control-Max-Age", "600")
+	w.Header().Set("Access-Control-Expose-Headers", middleware.RequestIDHeader+", "+shared.ContextTrimmedHeader)
+	addVaryHeaderToken(w.Header(), "Access-Control-Request-Headers")
+	if strings.EqualFold(strings.TrimSpace(r.Header.Get("Access-Control-Request-Private-Network")), "true") {
+		w.Header().Set("Access-Control-Allow-Private-Network", "true")
+		addVaryHeaderToken(w.Header(), "Access-Control-Request-Private-Network")
+	}
+}
+
+// corsOriginAllowed matches origin against exact entries, "*", and patterns
+// with one "*" standing for a run of host characters, such as
+// "https://*.example.com" or "http://localhost:*".
+func corsOriginAllowed(patterns []string, origin string) bool {
+	origin = strings.ToLower(origin)
+	for _, pattern := range patterns {
+		pattern = strings.ToLower(strings.TrimRight(strings.TrimSpace(pattern), "/"))
+		if pattern == "*" || pattern == origin {
+			return true
+		}
+		prefix, suffix, ok := strings.Cut(pattern, "*")
+		if !ok || len(origin) <= len(prefix)+len(suffix) {
+			continue
+		}
+		if !strings.HasPrefix(origin, prefix) || !strings.HasSuffix(origin, suffix) {
+			continue
+		}
+		if middle := origin[len(prefix) : len(origin)-len(suffix)]; !strings.ContainsAny(middle, "/:") {
+			return true
+		}
+	}
+	return false
+}
+
+func buildCORSAllowMethods(configured []string) string {
+	if len(configured) == 0 {
+		return defaultCORSAllowMethods
+	}
+	names := make([]string, 0, len(configured)+1)
+	seen := make(map[string]struct{}, len(configured)+1)
+	for _, name := range append(configured, http.MethodOptions) {
+		appendCORSHeaderName(&names, seen, strings.ToUpper(name))
+	}
+	return strings.Join(names, ", ")
+}
+
+// buildCORSAllowHeaders lists the allowed request headers. With no
+// configured list the defaults are sent plus whatever the preflight asks for,
+// so SDKs with their own headers keep working; a configured list replaces the
+// defaults, and a "*" entry in it reflects the requested headers again.
+func buildCORSAllowHeaders(r *http.Request, configured []string) string {
+	base, reflect := defaultCORSAllowHeaders, true
+	if len(configured) > 0 {
+		base, reflect = requiredCORSAllowHeaders, false
+	}
+	names := make([]string, 0, len(base)+len(configured)+4)
+	seen := make(map[string]struct{}, len(base)+len(configured)+4)
+	for _, name := range base {
+		appendCORSHeaderName(&names, seen, name)
+	}
+	for _, name := range configured {
+		if strings.TrimSpace(name) == "*" {
+			reflect = true
+			continue
+		}
+		appendCORSHeaderName(&names, seen, name)
+	}
+	if r == nil || !reflect {
+		return strings.Join(names, ", ")
+	}
+	for _, name := range splitCORSRequestHeaders(r.Header.Get("Access-Control-Request-Headers")) {
+		appendCORSHeaderName(&names, seen, name)
+	}
+	return strings.Join(names, ", ")
+}
+
+func splitCORSRequestHeaders(raw string) []string {
+	if strings.TrimSpace(raw) == "" {
+		return nil
+	}
+	parts := strings.Split(raw, ",")
+	out := make([]string, 0, len(parts))
+	for _, part := range parts {
+		name := strings.TrimSpace(part)
+		if !isValidCORSHeaderToken(name) {
+			continue
+		}
+		if _, blocked := blockedCORSRequestHeaders[strings.ToLower(name)]; blocked {
+			continue
+		}
+		out = append(out, name)
+	}
+	return out
+}
+
+func appendCORSHeaderName(dst *[]string, seen map[string]struct{}, name string) {
+	name = strings.TrimSpace(name)
+	if !isValidCORSHeaderToken(name) {
+		return
+	}
+	key := strings.ToLower(name)
+	if _, blocked := blockedCORSRequestHeaders[key]; blocked {
+		return
+	}
+	if _, ok := seen[key]; ok {
+		return
+	}
+	seen[key] = struct{}{}
+	*dst = append(*dst, name)
+}
+
+func isValidCORSHeaderToken(v string) bool {
+	if v == "" {
+		return false
+	}
+	for i := 0; i < len(v); i++ {
+		c := v[i]
+		if (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9') {
+			continue
+		}
+		switch c {
+		case '!', '#', '$', '%', '&', '\'', '*', '+', '-', '.', '^', '_', '`', '|', '~':
+			continue
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+func addVaryHeaderToken(h http.Header, token string) {
+	if h == nil {
+		return
+	}
+	token = strings.TrimSpace(token)
+	if token == "" {
+		return
+	}
+	current := h.Values("Vary")
+	seen := map[string]struct{}{}
+	merged := make([]string, 0, len(current)+1)
+	for _, value := range current {
+		for _, part := range strings.Split(value, ",") {
+			name := strings.TrimSpace(part)
+			if name == "" {
+				continue
+			}
+			key := strings.ToLower(name)
+			if _, ok := seen[key]; ok {
+				continue
+			}
+			seen[key] = struct{}{}
+			merged = append(merged, name)
+		}
+	}
+	key := strings.ToLower(token)
+	if _, ok := seen[key]; !ok {
+		merged = append(merged, token)
+	}
+	h.Set("Vary", strings.Join(merged, ", "))
+}
@@ -5,10 +5,14 @@ import (
 	"net/http/httptest"
 	"strings"
 	"testing"
+
+	"ds2api/internal/config"
 )
 
 func TestCORSPreflightAllowsThirdPartyRequestedHeaders(t *testing.T) {
-	handler := cors(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+	handler := corsMiddleware(func() config.CORSConfig {
+		return config.CORSConfig{AllowedOrigins: []string{"app://obsidian.md"}}
+	})(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
 		w.WriteHeader(http.StatusTeapot)
 	}))
 
@@ -49,7 +53,7 @@ func TestCORSPreflightAllowsThirdPartyRequestedHeaders(t *testing.T) {
 }
 
 func TestBuildCORSAllowHeadersKeepsDefaultsWithoutRequest(t *testing.T) {
-	got := strings.ToLower(buildCORSAllowHeaders(nil))
+	got := strings.ToLower(buildCORSAllowHeaders(nil, nil))
 	for _, want := range []string{"content-type", "x-goog-api-key", "anthropic-version", "x-ds2-source"} {
 		if !strings.Contains(got, want) {
 			t.Fatalf("expected default allow headers to include %q, got %q", want, got)
@@ -58,7 +62,7 @@ func TestBuildCORSAllowHeadersKeepsDefaultsWithoutRequest(t *testing.T) {
 }
 
 func TestAppCORSPreflightIsUnifiedAcrossInterfaces(t *testing.T) {
-	t.Setenv("DS2API_CONFIG_JSON", `{"keys":["k1"],"accounts":[{"email":"u@example.com","password":"p"}]}`)
+	t.Setenv("DS2API_CONFIG_JSON", `{"keys":["k1"],"accounts":[{"email":"u@example.com","password":"p"}],"cors":{"allowed_origins":["app://obsidian.md"]}}`)
 	t.Setenv("DS2API_ENV_WRITEBACK", "0")
 
 	app, err := NewApp()
@@ -117,3 +121,80 @@ func TestAppCORSPreflightIsUnifiedAcrossInterfaces(t *testing.T) {
 		})
 	}
 }
+
+func TestCORSDisabledWithoutAllowedOrigins(t *testing.T) {
+	handler := corsMiddleware(func() config.CORSConfig { return config.CORSConfig{} })(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	for _, method := range []string{http.MethodOptions, http.MethodPost} {
+		req := httptest.NewRequest(method, "/v1/chat/completions", nil)
+		req.Header.Set("Origin", "https://app.example.com")
+		req.Header.Set("Access-Control-Request-Headers", "authorization")
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		for key := range rec.Header() {
+			if strings.HasPrefix(strings.ToLower(key), "access-control-") {
+				t.Fatalf("%s: expected no CORS headers without allowed origins, got %v", method, rec.Header())
+			}
+		}
+	}
+}
+
+func TestCORSOriginMatching(t *testing.T) {
+	patterns := []string{"https://app.example.com", "https://*.example.org", "http://localhost:*"}
+	cases := map[string]bool{
+		"https://app.example.com":        true,
+		"https://APP.example.com":        true,
+		"https://other.example.com":      false,
+		"https://a.b.example.org":        true,
+		"https://example.org":            false,
+		"http://x.example.org":           false,
+		"https://evil.com/.example.org":  false,
+		"http://localhost:5173":          true,
+		"http://localhost.evil.com:5173": false,
+	}
+	for origin, want := range cases {
+		if got := corsOriginAllowed(patterns, origin); got != want {
+			t.Fatalf("%s: want %v, got %v", origin, want, got)
+		}
+	}
+	if !corsOriginAllowed([]string{"*"}, "null") {
+		t.Fatal("expected * to allow any origin")
+	}
+}
+
+func TestCORSConfiguredHeadersAndMethodsOnStreamedResponse(t *testing.T) {
+	cfg := config.CORSConfig{
+		AllowedOrigins: []string{"https://*.example.com"},
+		AllowedHeaders: []string{"X-Custom"},
+		AllowedMethods: []string{"post"},
+	}
+	handler := corsMiddleware(func() config.CORSConfig { return cfg })(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		_, _ = w.Write([]byte("data: {}\n\n"))
+		w.(http.Flusher).Flush()
+	}))
+
+	req := httptest.NewRequest(http.MethodOptions, "/v1/chat/completions", nil)
+	req.Header.Set("Origin", "https://app.example.com")
+	req.Header.Set("Access-Control-Request-Headers", "x-stainless-os")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusNoContent {
+		t.Fatalf("expected 204 preflight, got %d", rec.Code)
+	}
+	if got := rec.Header().Get("Access-Control-Allow-Headers"); got != "Content-Type, Authorization, X-Custom" {
+		t.Fatalf("expected only the configured and required headers, got %q", got)
+	}
+	if got := rec.Header().Get("Access-Control-Allow-Methods"); got != "POST, OPTIONS" {
+		t.Fatalf("expected configured methods plus OPTIONS, got %q", got)
+	}
+
+	req = httptest.NewRequest(http.MethodPost, "/v1/chat/completions", nil)
+	req.Header.Set("Origin", "https://app.example.com")
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if got := rec.Header().Get("Access-Control-Allow-Origin"); got != "https://app.example.com" || !strings.Contains(rec.Body.String(), "data:") {
+		t.Fatalf("expected the streamed response to carry the allowed origin, got %q body=%q", got, rec.Body.String())
+	}
+}
@@ -1,9 +1,14 @@
 package server
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestHealthEndpointsSupportHEAD(t *testing.T) {
@@ -14,6 +19,7 @@ func TestHealthEndpointsSupportHEAD(t *testing.T) {
 	if err != nil {
 		t.Fatalf("NewApp() error: %v", err)
 	}
+	app.readiness.probe = func(context.Context) error { return nil }
 
 	for _, path := range []string{"/healthz", "/readyz"} {
 		req := httptest.NewRequest(http.MethodHead, path, nil)
@@ -24,3 +30,111 @@ func TestHealthEndpointsSupportHEAD(t *testing.T) {
 		}
 	}
 }
+
+func TestReadyzCachesProbeAndReportsUpstreamError(t *testing.T) {
+	calls := 0
+	probeErr := errors.New("account u@example.com: request failed: status=200, code=40003, msg=INVALID_TOKEN")
+	now := time.Unix(1000, 0)
+	p := newReadiness(func(context.Context) error {
+		calls++
+		return probeErr
+	}, func() time.Duration { return 5 * time.Second })
+	p.now = func() time.Time { return now }
+
+	for i := 0; i < 3; i++ {
+		rec := httptest.NewRecorder()
+		p.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+		if rec.Code != http.StatusServiceUnavailable {
+			t.Fatalf("expected 503, got %d", rec.Code)
+		}
+		var body map[string]any
+		_ = json.Unmarshal(rec.Body.Bytes(), &body)
+		if body["status"] != "unavailable" || body["error"] != probeErr.Error() {
+			t.Fatalf("expected upstream error in body, got %s", rec.Body.String())
+		}
+	}
+	if calls != 1 {
+		t.Fatalf("expected cached probe result, got %d probes", calls)
+	}
+
+	probeErr = nil
+	now = now.Add(6 * time.Second)
+	rec := httptest.NewRecorder()
+	p.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	if rec.Code != http.StatusOK || calls != 2 || !strings.Contains(rec.Body.String(), `"status":"ready"`) {
+		t.Fatalf("expected fresh successful probe after the interval, code=%d calls=%d body=%s", rec.Code, calls, rec.Body.String())
+	}
+}
+
+func TestMetricsEndpointServesPrometheusText(t *testing.T) {
+	t.Setenv("DS2API_CONFIG_JSON", `{"keys":["k1"],"accounts":[{"email":"u@example.com","password":"p"}]}`)
+	t.Setenv("DS2API_ENV_WRITEBACK", "0")
+
+	app, err := NewApp()
+	if err != nil {
+		t.Fatalf("NewApp() error: %v", err)
+	}
+
+	app.Router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/v1/models", nil))
+	rec := httptest.NewRecorder()
+	app.Router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected /metrics status 200, got %d", rec.Code)
+	}
+	if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/plain") {
+		t.Fatalf("expected text exposition content type, got %q", ct)
+	}
+	if !strings.Contains(rec.Body.String(), `ds2api_requests_total{endpoint="/v1/models",model="",stream="false",status="200"}`) {
+		t.Fatalf("expected /v1/models request in metrics, got:\n%s", rec.Body.String())
+	}
+}
+
+func TestUnknownV1RouteReturnsOpenAIErrorWithRequestID(t *testing.T) {
+	t.Setenv("DS2API_CONFIG_JSON", `{"keys":["k1"],"accounts":[{"email":"u@example.com","password":"p"}]}`)
+	t.Setenv("DS2API_ENV_WRITEBACK", "0")
+
+	app, err := NewApp()
+	if err != nil {
+		t.Fatalf("NewApp() error: %v", err)
+	}
+	req := httptest.NewRequest(http.MethodGet, "/v1/does-not-exist", nil)
+	rec := httptest.NewRecorder()
+	app.Router.ServeHTTP(rec, req)
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("expected 404, got %d", rec.Code)
+	}
+	if !strings.Contains(rec.Body.String(), `"type":"invalid_request_error"`) {
+		t.Fatalf("expected OpenAI error envelope, got %s", rec.Body.String())
+	}
+	if rec.Header().Get("X-Request-Id") == "" {
+		t.Fatal("expected X-Request-Id response header")
+	}
+}
+
+func TestInboundTraceIDIsEchoedInHeaderAndErrorBody(t *testing.T) {
+	t.Setenv("DS2API_CONFIG_JSON", `{"keys":["k1"],"accounts":[{"email":"u@example.com","password":"p"}]}`)
+	t.Setenv("DS2API_ENV_WRITEBACK", "0")
+
+	app, err := NewApp()
+	if err != nil {
+		t.Fatalf("NewApp() error: %v", err)
+	}
+	for header, want := range map[string]string{
+		"X-Request-ID": "upstream-req-42",
+		"traceparent":  "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+	} {
+		req := httptest.NewRequest(http.MethodGet, "/v1/does-not-exist", nil)
+		req.Header.Set(header, want)
+		rec := httptest.NewRecorder()
+		app.Router.ServeHTTP(rec, req)
+		if header == "traceparent" {
+			want = "4bf92f3577b34da6a3ce929d0e0e4736"
+		}
+		if got := rec.Header().Get("X-Request-Id"); got != want {
+			t.Fatalf("%s: expected X-Request-Id %q, got %q", header, want, got)
+		}
+		if !strings.Contains(rec.Body.String(), `"request_id":"`+want+`"`) {
+			t.Fatalf("%s: expected request_id in the error body, got %s", header, rec.Body.String())
+		}
+	}
+}
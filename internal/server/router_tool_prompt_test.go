@@ -0,0 +1,37 @@
+package server
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"ds2api/internal/toolcall"
+)
+
+func TestNewAppFailsFastOnBadToolPromptTemplate(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tools.tmpl")
+	if err := os.WriteFile(path, []byte(`{{range .Tools}}{{.Name}}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("DS2API_CONFIG_JSON", `{"keys":["k"],"tool_prompt":{"template_file":`+strconv.Quote(path)+`}}`)
+	t.Setenv("DS2API_ENV_WRITEBACK", "0")
+
+	if _, err := NewApp(); err == nil || !strings.Contains(err.Error(), "tool prompt template") {
+		t.Fatalf("expected a tool prompt template error, got %v", err)
+	}
+}
+
+func TestNewAppInstallsToolCallFormat(t *testing.T) {
+	t.Setenv("DS2API_CONFIG_JSON", `{"keys":["k"],"tool_prompt":{"format":"json"}}`)
+	t.Setenv("DS2API_ENV_WRITEBACK", "0")
+	t.Cleanup(func() { toolcall.ConfigurePrompt("", nil) })
+
+	if _, err := NewApp(); err != nil {
+		t.Fatalf("NewApp() error: %v", err)
+	}
+	if got := toolcall.ActiveCallFormat(); got != toolcall.CallFormatJSON {
+		t.Fatalf("expected the json call format, got %q", got)
+	}
+}
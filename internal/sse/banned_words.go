@@ -0,0 +1,220 @@
+package sse
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// bannedWords matches the words a request asked the model to avoid (from
+// OpenAI `logit_bias`). Latin words match whole words case-insensitively so
+// banning "cat" leaves "category" alone; any other entry, e.g. CJK text with
+// no word boundaries, matches as an exact substring.
+type bannedWords struct {
+	words   []string
+	phrases []string
+}
+
+func newBannedWords(entries []string) bannedWords {
+	out := bannedWords{}
+	for _, entry := range entries {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		if isWordEntry(entry) {
+			out.words = append(out.words, entry)
+		} else {
+			out.phrases = append(out.phrases, entry)
+		}
+	}
+	return out
+}
+
+func (b bannedWords) empty() bool {
+	return len(b.words) == 0 && len(b.phrases) == 0
+}
+
+// filter removes every banned word from text. A space in front of a removed
+// word is dropped with it so "a bad word" reads "a word".
+func (b bannedWords) filter(text string) (string, bool) {
+	removed := false
+	for _, p := range b.phrases {
+		if strings.Contains(text, p) {
+			text = strings.ReplaceAll(text, p, "")
+			removed = true
+		}
+	}
+	if len(b.words) == 0 {
+		return text, removed
+	}
+	out := make([]byte, 0, len(text))
+	for i := 0; i < len(text); {
+		r, size := utf8.DecodeRuneInString(text[i:])
+		if !isWordRune(r) {
+			out = append(out, text[i:i+size]...)
+			i += size
+			continue
+		}
+		j := i + wordRunLen(text[i:])
+		if b.isBannedWord(text[i:j]) {
+			removed = true
+			if n := len(out); n > 0 && out[n-1] == ' ' {
+				out = out[:n-1]
+			}
+		} else {
+			out = append(out, text[i:j]...)
+		}
+		i = j
+	}
+	return string(out), removed
+}
+
+func (b bannedWords) isBannedWord(word string) bool {
+	for _, w := range b.words {
+		if strings.EqualFold(w, word) {
+			return true
+		}
+	}
+	return false
+}
+
+// holdLen returns how many trailing bytes of text must wait for the next
+// chunk: an unfinished word, or a suffix that could still grow into a banned
+// phrase. The cut never splits a word.
+func (b bannedWords) holdLen(text string) int {
+	keep := 0
+	if len(b.words) > 0 {
+		keep = trailingWordRunLen(text)
+	}
+	for _, p := range b.phrases {
+		n := min(len(p)-1, len(text))
+		for ; n > keep; n-- {
+			if strings.HasSuffix(text, p[:n]) {
+				keep = n
+				break
+			}
+		}
+	}
+	cut := len(text) - keep
+	if cut > 0 && cut < len(text) {
+		before, _ := utf8.DecodeLastRuneInString(text[:cut])
+		after, _ := utf8.DecodeRuneInString(text[cut:])
+		if isWordRune(before) && isWordRune(after) {
+			cut -= trailingWordRunLen(text[:cut])
+		}
+	}
+	// Keep the space in front of a held word so filter can drop it with
+	// the word.
+	if len(b.words) > 0 && cut > 0 && text[cut-1] == ' ' {
+		cut--
+	}
+	return len(text) - cut
+}
+
+// FilterBannedWords removes banned words from a complete text and reports
+// whether anything was removed.
+func FilterBannedWords(text string, entries []string) (string, bool) {
+	b := newBannedWords(entries)
+	if b.empty() || text == "" {
+		return text, false
+	}
+	return b.filter(text)
+}
+
+// BannedWordFilter is the streaming form of FilterBannedWords. Like
+// StopSequenceMatcher it withholds a trailing fragment until the next chunk
+// shows whether it completes a banned word. A nil filter passes text through.
+type BannedWordFilter struct {
+	banned  bannedWords
+	held    string
+	removed bool
+}
+
+// NewBannedWordFilter returns nil when there is nothing to filter.
+func NewBannedWordFilter(entries []string) *BannedWordFilter {
+	b := newBannedWords(entries)
+	if b.empty() {
+		return nil
+	}
+	return &BannedWordFilter{banned: b}
+}
+
+// Held returns the text currently withheld.
+func (f *BannedWordFilter) Held() string {
+	if f == nil {
+		return ""
+	}
+	return f.held
+}
+
+// Removed reports whether any banned word has been dropped so far.
+func (f *BannedWordFilter) Removed() bool {
+	return f != nil && f.removed
+}
+
+// Push feeds the next chunk and returns the filtered text that is safe to
+// emit.
+func (f *BannedWordFilter) Push(text string) string {
+	if f == nil {
+		return text
+	}
+	if text == "" {
+		return ""
+	}
+	combined := f.held + text
+	keep := f.banned.holdLen(combined)
+	f.held = combined[len(combined)-keep:]
+	out, removed := f.banned.filter(combined[:len(combined)-keep])
+	f.removed = f.removed || removed
+	return out
+}
+
+// Flush filters and releases the withheld tail at end of stream.
+func (f *BannedWordFilter) Flush() string {
+	if f == nil || f.held == "" {
+		return ""
+	}
+	out, removed := f.banned.filter(f.held)
+	f.held = ""
+	f.removed = f.removed || removed
+	return out
+}
+
+func isWordEntry(entry string) bool {
+	for _, r := range entry {
+		if !isWordRune(r) {
+			return false
+		}
+	}
+	return true
+}
+
+// isWordRune reports runes of space-delimited Latin-script words.
+func isWordRune(r rune) bool {
+	return r == '_' || unicode.IsDigit(r) || unicode.Is(unicode.Latin, r)
+}
+
+func wordRunLen(text string) int {
+	n := 0
+	for n < len(text) {
+		r, size := utf8.DecodeRuneInString(text[n:])
+		if !isWordRune(r) {
+			break
+		}
+		n += size
+	}
+	return n
+}
+
+func trailingWordRunLen(text string) int {
+	n := 0
+	for n < len(text) {
+		r, size := utf8.DecodeLastRuneInString(text[:len(text)-n])
+		if !isWordRune(r) {
+			break
+		}
+		n += size
+	}
+	return n
+}
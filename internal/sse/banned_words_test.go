@@ -0,0 +1,42 @@
+package sse
+
+import "testing"
+
+func TestFilterBannedWordsMatchesWholeWordsCaseInsensitively(t *testing.T) {
+	got, removed := FilterBannedWords("Cats like the cat category. CAT!", []string{"cat"})
+	if !removed || got != "Cats like the category.!" {
+		t.Fatalf("unexpected filter result: removed=%v text=%q", removed, got)
+	}
+}
+
+func TestFilterBannedWordsMatchesCJKAsSubstring(t *testing.T) {
+	got, removed := FilterBannedWords("你好世界", []string{"世界"})
+	if !removed || got != "你好" {
+		t.Fatalf("unexpected filter result: removed=%v text=%q", removed, got)
+	}
+}
+
+func TestFilterBannedWordsNoopWithoutWords(t *testing.T) {
+	got, removed := FilterBannedWords("keep me", nil)
+	if removed || got != "keep me" {
+		t.Fatalf("expected passthrough, got removed=%v text=%q", removed, got)
+	}
+	if NewBannedWordFilter([]string{" "}) != nil {
+		t.Fatal("expected nil filter for blank entries")
+	}
+}
+
+func TestBannedWordFilterHoldsWordsSplitAcrossChunks(t *testing.T) {
+	f := NewBannedWordFilter([]string{"secret", "机密"})
+	var out string
+	for _, chunk := range []string{"the sec", "ret is", " here 机", "密文件 secretive"} {
+		out += f.Push(chunk)
+	}
+	out += f.Flush()
+	if out != "the is here 文件 secretive" {
+		t.Fatalf("unexpected streamed output: %q", out)
+	}
+	if !f.Removed() {
+		t.Fatal("expected Removed after dropping banned words")
+	}
+}
@@ -17,6 +17,9 @@ type CollectResult struct {
 	ContentFilter         bool
 	CitationLinks         map[int]string
 	ResponseMessageID     int
+	StopSequenceMatched   bool
+	// OutputLimitReached is set when text was cut at the max_tokens budget.
+	OutputLimitReached bool
 }
 
 // CollectStream fully consumes a DeepSeek SSE response and separates
@@ -26,6 +29,20 @@ type CollectResult struct {
 //
 // The caller is responsible for closing resp.Body unless closeBody is true.
 func CollectStream(resp *http.Response, thinkingEnabled bool, closeBody bool) CollectResult {
+	return CollectStreamWithStop(resp, thinkingEnabled, closeBody, nil)
+}
+
+// CollectStreamWithStop is CollectStream with OpenAI `stop` handling: text is
+// cut before the first stop string and the upstream body is no longer read
+// once one is matched.
+func CollectStreamWithStop(resp *http.Response, thinkingEnabled bool, closeBody bool, stops []string) CollectResult {
+	return CollectStreamWithLimits(resp, thinkingEnabled, closeBody, stops, nil)
+}
+
+// CollectStreamWithLimits is CollectStreamWithStop with an optional
+// max_tokens budget: text past the limit is dropped and the upstream body is
+// no longer read once the limiter is exhausted.
+func CollectStreamWithLimits(resp *http.Response, thinkingEnabled bool, closeBody bool, stops []string, limiter *OutputTokenLimiter) CollectResult {
 	if closeBody {
 		defer func() { _ = resp.Body.Close() }()
 	}
@@ -36,6 +53,7 @@ func CollectStream(resp *http.Response, thinkingEnabled bool, closeBody bool) Co
 	stopped := false
 	collector := newCitationLinkCollector()
 	responseMessageID := 0
+	stopMatcher := NewStopSequenceMatcher(stops)
 	currentType := "text"
 	if thinkingEnabled {
 		currentType = "thinking"
@@ -72,8 +90,13 @@ func CollectStream(resp *http.Response, thinkingEnabled bool, closeBody bool) Co
 				trimmed := TrimContinuationOverlap(thinking.String(), p.Text)
 				thinking.WriteString(trimmed)
 			} else {
-				trimmed := TrimContinuationOverlap(text.String(), p.Text)
-				text.WriteString(trimmed)
+				trimmed := TrimContinuationOverlap(text.String()+stopMatcher.Held(), p.Text)
+				emit, matched := stopMatcher.Push(trimmed)
+				emit, reached := limiter.Push(emit)
+				text.WriteString(emit)
+				if matched || reached {
+					return false
+				}
 			}
 		}
 		for _, p := range result.ToolDetectionThinkingParts {
@@ -82,6 +105,8 @@ func CollectStream(resp *http.Response, thinkingEnabled bool, closeBody bool) Co
 		}
 		return true
 	})
+	tail, _ := limiter.Push(stopMatcher.Flush())
+	text.WriteString(tail)
 	return CollectResult{
 		Text:                  text.String(),
 		Thinking:              thinking.String(),
@@ -89,6 +114,8 @@ func CollectStream(resp *http.Response, thinkingEnabled bool, closeBody bool) Co
 		ContentFilter:         contentFilter,
 		CitationLinks:         collector.build(),
 		ResponseMessageID:     responseMessageID,
+		StopSequenceMatched:   stopMatcher.Matched(),
+		OutputLimitReached:    limiter.Reached(),
 	}
 }
 
@@ -0,0 +1,81 @@
+package sse
+
+import (
+	"unicode/utf8"
+
+	"ds2api/internal/util"
+)
+
+// OutputTokenLimiter enforces an OpenAI `max_tokens` budget on streamed
+// answer text. Each chunk is counted with util.CountOutputTokens as it
+// arrives; the chunk that crosses the budget is cut at the last character
+// that still fits and every later Push returns "". A nil limiter passes text
+// through unchanged.
+type OutputTokenLimiter struct {
+	model   string
+	limit   int
+	used    int
+	reached bool
+}
+
+// NewOutputTokenLimiter returns nil when limit is not positive so callers can
+// keep the zero-cost path.
+func NewOutputTokenLimiter(limit int, model string) *OutputTokenLimiter {
+	if limit <= 0 {
+		return nil
+	}
+	return &OutputTokenLimiter{model: model, limit: limit}
+}
+
+// Reached reports whether the budget has been exhausted.
+func (l *OutputTokenLimiter) Reached() bool {
+	return l != nil && l.reached
+}
+
+// Push feeds the next chunk and returns the part of it that fits in the
+// remaining budget. reached is true once the budget is exhausted.
+func (l *OutputTokenLimiter) Push(text string) (string, bool) {
+	if l == nil {
+		return text, false
+	}
+	if l.reached || text == "" {
+		return "", l.reached
+	}
+	n := util.CountOutputTokens(text, l.model)
+	if l.used+n <= l.limit {
+		l.used += n
+		return text, false
+	}
+	l.reached = true
+	remaining := l.limit - l.used
+	l.used = l.limit
+	if remaining <= 0 {
+		return "", true
+	}
+	return l.fittingPrefix(text, remaining), true
+}
+
+// fittingPrefix binary-searches the longest prefix of text whose token count
+// is at most budget. Only util.ClusterStart offsets are tried, so the cut never
+// lands inside a code point, an emoji sequence or a combining-mark cluster.
+func (l *OutputTokenLimiter) fittingPrefix(text string, budget int) string {
+	bounds := make([]int, 0, utf8.RuneCountInString(text))
+	for i := range text {
+		if i > 0 && util.ClusterStart(text, i) {
+			bounds = append(bounds, i)
+		}
+	}
+	lo, hi := -1, len(bounds)-1
+	for lo < hi {
+		mid := (lo + hi + 1) / 2
+		if util.CountOutputTokens(text[:bounds[mid]], l.model) <= budget {
+			lo = mid
+		} else {
+			hi = mid - 1
+		}
+	}
+	if lo < 0 {
+		return ""
+	}
+	return text[:bounds[lo]]
+}
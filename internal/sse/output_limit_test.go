@@ -0,0 +1,71 @@
+package sse
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"ds2api/internal/util"
+)
+
+func TestOutputTokenLimiterCutsChunkThatCrossesBudget(t *testing.T) {
+	l := NewOutputTokenLimiter(3, "deepseek-v4-flash")
+	var out strings.Builder
+	for _, chunk := range []string{"hi", " there, this chunk is far too long to fit", " more"} {
+		emit, _ := l.Push(chunk)
+		out.WriteString(emit)
+	}
+	if !l.Reached() {
+		t.Fatal("expected the budget to be reached")
+	}
+	got := out.String()
+	if !strings.HasPrefix(got, "hi") || len(got) >= len("hi there, this chunk is far too long to fit") {
+		t.Fatalf("unexpected limited text: %q", got)
+	}
+	if n := util.CountOutputTokens(got, "deepseek-v4-flash"); n > 3 {
+		t.Fatalf("expected at most 3 tokens, got %d for %q", n, got)
+	}
+	if rest, reached := l.Push("again"); rest != "" || !reached {
+		t.Fatalf("expected no output after the budget is spent, got %q", rest)
+	}
+}
+
+func TestOutputTokenLimiterKeepsRunesWhole(t *testing.T) {
+	l := NewOutputTokenLimiter(2, "deepseek-v4-flash")
+	emit, reached := l.Push("你好世界你好世界你好世界")
+	if !reached {
+		t.Fatal("expected the budget to be reached")
+	}
+	if !strings.HasPrefix("你好世界你好世界你好世界", emit) || !utf8.ValidString(emit) {
+		t.Fatalf("expected a rune-aligned prefix, got %q", emit)
+	}
+}
+
+func TestOutputTokenLimiterKeepsEmojiSequencesWhole(t *testing.T) {
+	family := "\U0001F468\u200d\U0001F469\u200d\U0001F467"
+	thumbsUp := "\U0001F44D\U0001F3FD"
+	text := strings.Repeat("ok "+family+" "+thumbsUp+" e\u0301 ", 8)
+	for budget := 1; budget <= 12; budget++ {
+		l := NewOutputTokenLimiter(budget, "deepseek-v4-flash")
+		emit, reached := l.Push(text)
+		if !reached {
+			t.Fatalf("budget %d: expected the budget to be reached", budget)
+		}
+		if !strings.HasPrefix(text, emit) || !utf8.ValidString(emit) {
+			t.Fatalf("budget %d: expected a prefix of the chunk, got %q", budget, emit)
+		}
+		if !util.ClusterStart(text, len(emit)) {
+			t.Fatalf("budget %d: cut %q inside an emoji or combining sequence", budget, emit)
+		}
+	}
+}
+
+func TestOutputTokenLimiterNilPassesThrough(t *testing.T) {
+	l := NewOutputTokenLimiter(0, "")
+	if l != nil {
+		t.Fatal("expected nil limiter for a zero budget")
+	}
+	if emit, reached := l.Push("text"); emit != "text" || reached || l.Reached() {
+		t.Fatalf("expected nil limiter to pass text through, got %q", emit)
+	}
+}
@@ -0,0 +1,115 @@
+package sse
+
+import "strings"
+
+// StopSequenceMatcher scans streamed text for OpenAI-style `stop` strings.
+// Push returns only the text that can safely reach the client: a suffix that
+// could still grow into a stop string is held back until the next chunk
+// proves otherwise, so a match split across chunks never leaks a partial
+// prefix. A nil matcher passes text through unchanged.
+type StopSequenceMatcher struct {
+	stops   []string
+	held    string
+	matched bool
+}
+
+// NewStopSequenceMatcher returns nil when there are no stop strings so callers
+// can keep the zero-cost path.
+func NewStopSequenceMatcher(stops []string) *StopSequenceMatcher {
+	filtered := make([]string, 0, len(stops))
+	for _, s := range stops {
+		if s != "" {
+			filtered = append(filtered, s)
+		}
+	}
+	if len(filtered) == 0 {
+		return nil
+	}
+	return &StopSequenceMatcher{stops: filtered}
+}
+
+// Matched reports whether a stop string has been seen. Once matched, every
+// later Push returns "".
+func (m *StopSequenceMatcher) Matched() bool {
+	return m != nil && m.matched
+}
+
+// Held returns the text currently withheld as a possible stop prefix.
+func (m *StopSequenceMatcher) Held() string {
+	if m == nil {
+		return ""
+	}
+	return m.held
+}
+
+// Push feeds the next chunk and returns the text to emit. When the chunk
+// completes a stop string, the text before it is returned and matched is true;
+// the stop string and everything after it are dropped.
+func (m *StopSequenceMatcher) Push(text string) (string, bool) {
+	if m == nil {
+		return text, false
+	}
+	if m.matched || text == "" {
+		return "", m.matched
+	}
+	combined := m.held + text
+	if idx := IndexStopSequence(combined, m.stops); idx >= 0 {
+		m.held = ""
+		m.matched = true
+		return combined[:idx], true
+	}
+	keep := m.partialSuffixLen(combined)
+	m.held = combined[len(combined)-keep:]
+	return combined[:len(combined)-keep], false
+}
+
+// Flush releases any withheld text at end of stream, when it can no longer
+// become a stop string.
+func (m *StopSequenceMatcher) Flush() string {
+	if m == nil || m.matched {
+		return ""
+	}
+	out := m.held
+	m.held = ""
+	return out
+}
+
+// partialSuffixLen returns the length of the longest suffix of text that is a
+// proper prefix of some stop string.
+func (m *StopSequenceMatcher) partialSuffixLen(text string) int {
+	longest := 0
+	for _, stop := range m.stops {
+		n := min(len(stop)-1, len(text))
+		for ; n > longest; n-- {
+			if strings.HasSuffix(text, stop[:n]) {
+				longest = n
+				break
+			}
+		}
+	}
+	return longest
+}
+
+// IndexStopSequence returns the byte offset of the earliest stop string in
+// text, or -1 when none occurs.
+func IndexStopSequence(text string, stops []string) int {
+	best := -1
+	for _, stop := range stops {
+		if stop == "" {
+			continue
+		}
+		if idx := strings.Index(text, stop); idx >= 0 && (best < 0 || idx < best) {
+			best = idx
+		}
+	}
+	return best
+}
+
+// TruncateAtStopSequence cuts text before the earliest stop string and
+// reports whether one was found.
+func TruncateAtStopSequence(text string, stops []string) (string, bool) {
+	if idx := IndexStopSequence(text, stops); idx >= 0 {
+		return text[:idx], true
+	}
+	return text, false
+}
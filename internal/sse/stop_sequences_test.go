@@ -0,0 +1,67 @@
+package sse
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestStopSequenceMatcherHandlesMatchSpanningChunks(t *testing.T) {
+	m := NewStopSequenceMatcher([]string{"END"})
+	var out strings.Builder
+	for _, chunk := range []string{"hello E", "N", "D trailing"} {
+		emit, _ := m.Push(chunk)
+		out.WriteString(emit)
+	}
+	if !m.Matched() {
+		t.Fatal("expected stop sequence to match across chunks")
+	}
+	if got := out.String(); got != "hello " {
+		t.Fatalf("unexpected truncated text: %q", got)
+	}
+	if rest, _ := m.Push("more"); rest != "" || m.Flush() != "" {
+		t.Fatal("expected no output after a match")
+	}
+}
+
+func TestStopSequenceMatcherWithholdsPartialMatchUntilResolved(t *testing.T) {
+	m := NewStopSequenceMatcher([]string{"###", "STOP"})
+	if emit, _ := m.Push("abc ST"); emit != "abc " {
+		t.Fatalf("expected partial stop prefix to be withheld, got %q", emit)
+	}
+	if emit, _ := m.Push("ART #"); emit != "START " {
+		t.Fatalf("expected resolved prefix to be released, got %q", emit)
+	}
+	if got := m.Flush(); got != "#" {
+		t.Fatalf("expected held tail on flush, got %q", got)
+	}
+}
+
+func TestStopSequenceMatcherPicksEarliestStop(t *testing.T) {
+	m := NewStopSequenceMatcher([]string{"world", "o w"})
+	emit, matched := m.Push("hello world")
+	if !matched || emit != "hell" {
+		t.Fatalf("expected earliest match, got %q matched=%v", emit, matched)
+	}
+}
+
+func TestNewStopSequenceMatcherIgnoresEmptyStops(t *testing.T) {
+	if m := NewStopSequenceMatcher([]string{""}); m != nil {
+		t.Fatal("expected nil matcher without stop strings")
+	}
+	var m *StopSequenceMatcher
+	if emit, matched := m.Push("text"); emit != "text" || matched {
+		t.Fatalf("nil matcher should pass text through, got %q", emit)
+	}
+}
+
+func TestCollectStreamWithStopTruncatesAndStopsReading(t *testing.T) {
+	resp := makeHTTPResponse(strings.Join([]string{
+		`data: {"p":"response/content","v":"answer: 42\n"}`,
+		`data: {"p":"response/content","v":"\nUser: next"}`,
+		`data: {"p":"response/content","v":" question"}`,
+	}, "\n") + "\n")
+	result := CollectStreamWithStop(resp, false, true, []string{"\n\nUser:"})
+	if result.Text != "answer: 42" || !result.StopSequenceMatched {
+		t.Fatalf("unexpected collect result: %#v", result)
+	}
+}
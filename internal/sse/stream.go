@@ -7,6 +7,8 @@ import (
 	"strings"
 	"time"
 	"unicode/utf8"
+
+	"ds2api/internal/config"
 )
 
 const (
@@ -59,6 +61,19 @@ func startParsedLinePumpWithConfig(ctx context.Context, body io.Reader, thinking
 		scanCh := make(chan []byte, parsedLineBufferSize)
 		scanDone := make(chan error, 1)
 
+		// A blocked ReadBytes never observes ctx, so closing the body on
+		// cancellation is what lets the reader goroutine below exit when the
+		// client disconnects mid-stream.
+		stopBodyClose := func() bool { return false }
+		if closer, ok := body.(io.Closer); ok {
+			stopBodyClose = context.AfterFunc(ctx, func() {
+				if err := closer.Close(); err != nil {
+					config.Logger.WarnContext(ctx, "[sse] upstream body close on cancel failed", "error", err)
+				}
+			})
+		}
+		defer stopBodyClose()
+
 		go func() {
 			for {
 				line, err := reader.ReadBytes('\n')
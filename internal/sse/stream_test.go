@@ -3,8 +3,10 @@ package sse
 import (
 	"context"
 	"encoding/json"
+	"io"
 	"strings"
 	"testing"
+	"testing/iotest"
 )
 
 func makeLargeContentSSEBody(t *testing.T, payload string) string {
@@ -66,3 +68,39 @@ func TestStartParsedLinePumpHandlesLongSingleSSELine(t *testing.T) {
 		t.Fatal("expected DONE after long SSE line")
 	}
 }
+
+func TestStartParsedLinePumpKeepsMultiByteTextSplitAcrossReads(t *testing.T) {
+	chunks := []string{
+		"😀",
+		"混合 scripts: русский, العربية, हिन्दी",
+		"\U0001F468\u200d\U0001F469\u200d\U0001F467 e\u0301 \U0001F1E8\U0001F1F3",
+		`{"城市": "東京", "emoji": "🎉"}`,
+	}
+	var body strings.Builder
+	var want strings.Builder
+	for _, chunk := range chunks {
+		line, err := json.Marshal(map[string]any{"p": "response/content", "v": chunk})
+		if err != nil {
+			t.Fatalf("marshal SSE line failed: %v", err)
+		}
+		body.WriteString("data: " + string(line) + "\n\n")
+		want.WriteString(chunk)
+	}
+	body.WriteString("data: [DONE]\n")
+
+	// OneByteReader hands the pump every 4-byte emoji split over four reads.
+	var reader io.Reader = iotest.OneByteReader(strings.NewReader(body.String()))
+	results, done := StartParsedLinePump(context.Background(), reader, false, "text")
+	var got strings.Builder
+	for r := range results {
+		for _, p := range r.Parts {
+			got.WriteString(p.Text)
+		}
+	}
+	if err := <-done; err != nil {
+		t.Fatalf("unexpected scanner error: %v", err)
+	}
+	if got.String() != want.String() {
+		t.Fatalf("multi-byte text mismatch:\n got %q\nwant %q", got.String(), want.String())
+	}
+}
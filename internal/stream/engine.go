@@ -54,7 +54,11 @@ func ConsumeSSE(cfg ConsumeConfig, hooks ConsumeHooks) {
 			initialType = "text"
 		}
 	}
-	parsedLines, done := sse.StartParsedLinePump(cfg.Context, cfg.Body, cfg.ThinkingEnabled, initialType)
+	// The pump must not outlive this call: when a hook stops early nobody
+	// drains parsedLines any more, so cancel it on every return path.
+	pumpCtx, stopPump := context.WithCancel(cfg.Context)
+	defer stopPump()
+	parsedLines, done := sse.StartParsedLinePump(pumpCtx, cfg.Body, cfg.ThinkingEnabled, initialType)
 
 	var ticker *time.Ticker
 	if cfg.KeepAliveInterval > 0 {
@@ -2,8 +2,11 @@ package stream
 
 import (
 	"context"
+	"errors"
+	"io"
 	"strings"
 	"testing"
+	"time"
 
 	"ds2api/internal/sse"
 )
@@ -45,3 +48,43 @@ func TestConsumeSSEPrefersContextCancellationOverReadyParsedLines(t *testing.T)
 		t.Fatal("expected parsed lines not to be processed after context cancellation wins")
 	}
 }
+
+func TestConsumeSSEReleasesUpstreamReaderWhenHandlerStopsEarly(t *testing.T) {
+	body, upstream := io.Pipe()
+	go func() {
+		_, _ = upstream.Write([]byte("data: {\"p\":\"response/content\",\"v\":\"hello\\n\"}\n"))
+	}()
+
+	returned := make(chan struct{})
+	go func() {
+		defer close(returned)
+		ConsumeSSE(ConsumeConfig{
+			Context:     context.Background(),
+			Body:        body,
+			InitialType: "text",
+		}, ConsumeHooks{
+			OnParsed: func(_ sse.LineResult) ParsedDecision {
+				return ParsedDecision{Stop: true}
+			},
+		})
+	}()
+	select {
+	case <-returned:
+	case <-time.After(2 * time.Second):
+		t.Fatal("expected ConsumeSSE to return after the handler requested a stop")
+	}
+
+	// The upstream body stays open and silent; the pump must have closed it
+	// so its blocked reader goroutine can exit.
+	deadline := time.After(2 * time.Second)
+	for {
+		if _, err := upstream.Write([]byte("data: {}\n")); errors.Is(err, io.ErrClosedPipe) {
+			return
+		}
+		select {
+		case <-deadline:
+			t.Fatal("expected upstream body to be closed once ConsumeSSE returned")
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+}
@@ -27,11 +27,12 @@ func (r *Runner) caseInvalidModel(ctx context.Context, cc *caseContext) error {
 	if err != nil {
 		return err
 	}
-	cc.assert("status_503", resp.StatusCode == http.StatusServiceUnavailable, fmt.Sprintf("status=%d", resp.StatusCode))
+	cc.assert("status_404", resp.StatusCode == http.StatusNotFound, fmt.Sprintf("status=%d", resp.StatusCode))
 	var m map[string]any
 	_ = json.Unmarshal(resp.Body, &m)
 	e, _ := m["error"].(map[string]any)
-	cc.assert("error_type_service_unavailable", asString(e["type"]) == "service_unavailable_error", fmt.Sprintf("body=%s", string(resp.Body)))
+	cc.assert("error_type_invalid_request", asString(e["type"]) == "invalid_request_error", fmt.Sprintf("body=%s", string(resp.Body)))
+	cc.assert("error_code_model_not_found", asString(e["code"]) == "model_not_found", fmt.Sprintf("body=%s", string(resp.Body)))
 	return nil
 }
 
@@ -7,8 +7,16 @@ import "strings"
 // structure: rules → negative examples → positive examples → anchor.
 //
 // The toolNames slice should contain the actual tool names available in the
-// current request; the function picks real names for examples.
+// current request; the function picks real names for examples. With the
+// "json" call format it returns the JSON instructions instead.
 func BuildToolCallInstructions(toolNames []string) string {
+	return buildToolCallInstructionsForFormat(toolNames, ActiveCallFormat())
+}
+
+func buildToolCallInstructionsForFormat(toolNames []string, format string) string {
+	if NormalizeCallFormat(format) == CallFormatJSON {
+		return buildJSONToolCallInstructions(toolNames)
+	}
 	return `TOOL CALL FORMAT — FOLLOW EXACTLY:
 
 <|DSML|tool_calls>
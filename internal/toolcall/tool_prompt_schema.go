@@ -0,0 +1,277 @@
+package toolcall
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+)
+
+const maxSchemaOutlineDepth = 8
+
+// FormatToolParameters renders a tool's parameter schema as the
+// "Parameters:" block of the tool prompt. An object schema with properties
+// becomes an outline with one line per property: its type, whether it is
+// required, enum values, defaults and bounds, then its description. Nested
+// objects and the fields of array items are indented under their parent, so
+// the model sees every constraint it has to satisfy. Schemas the outline
+// cannot state faithfully keep the compact JSON form.
+func FormatToolParameters(schema any) string {
+	root, ok := schema.(map[string]any)
+	if !ok || hasSchemaCombinator(root) {
+		return "Parameters: " + compactSchemaJSON(schema)
+	}
+	props, ok := root["properties"].(map[string]any)
+	if !ok {
+		return "Parameters: " + compactSchemaJSON(schema)
+	}
+	if len(props) == 0 {
+		return "Parameters: none"
+	}
+	var b strings.Builder
+	b.WriteString("Parameters:")
+	if additionalPropertiesClosed(root) {
+		b.WriteString(" (no other parameters)")
+	}
+	writeSchemaProperties(&b, root, props, 0)
+	return b.String()
+}
+
+func writeSchemaProperties(b *strings.Builder, parent, props map[string]any, depth int) {
+	required := schemaRequired(parent)
+	for _, name := range orderedPropertyNames(props, required) {
+		prop, _ := props[name].(map[string]any)
+		b.WriteString("\n")
+		b.WriteString(strings.Repeat("  ", depth))
+		b.WriteString("- ")
+		b.WriteString(name)
+		b.WriteString(" (")
+		b.WriteString(strings.Join(propertyTraits(prop, slices.Contains(required, name), depth), ", "))
+		b.WriteString(")")
+		if desc := collapseWhitespace(schemaString(prop["description"])); desc != "" {
+			b.WriteString(": ")
+			b.WriteString(desc)
+		}
+		if depth+1 >= maxSchemaOutlineDepth || hasSchemaCombinator(prop) {
+			continue
+		}
+		if children, ok := prop["properties"].(map[string]any); ok && len(children) > 0 {
+			writeSchemaProperties(b, prop, children, depth+1)
+			continue
+		}
+		if items, ok := prop["items"].(map[string]any); ok && !hasSchemaCombinator(items) {
+			if children, ok := items["properties"].(map[string]any); ok && len(children) > 0 {
+				writeSchemaProperties(b, items, children, depth+1)
+			}
+		}
+	}
+}
+
+// propertyTraits lists what the model must know about one property; the type
+// always comes first.
+func propertyTraits(prop map[string]any, required bool, depth int) []string {
+	traits := []string{schemaTypeLabel(prop)}
+	if required {
+		traits = append(traits, "required")
+	}
+	if hasSchemaCombinator(prop) || (depth+1 >= maxSchemaOutlineDepth && hasNestedFields(prop)) {
+		return append(traits, "schema "+compactSchemaJSON(withoutDescription(prop)))
+	}
+	traits = append(traits, valueConstraints(prop)...)
+	if items, ok := prop["items"].(map[string]any); ok {
+		if hasSchemaCombinator(items) {
+			traits = append(traits, "items schema "+compactSchemaJSON(withoutDescription(items)))
+		} else {
+			for _, c := range valueConstraints(items) {
+				traits = append(traits, "items "+c)
+			}
+		}
+	}
+	if n, ok := schemaNumber(prop["minItems"]); ok {
+		traits = append(traits, "at least "+n+" items")
+	}
+	if n, ok := schemaNumber(prop["maxItems"]); ok {
+		traits = append(traits, "at most "+n+" items")
+	}
+	if unique, _ := prop["uniqueItems"].(bool); unique {
+		traits = append(traits, "unique items")
+	}
+	if additionalPropertiesClosed(prop) {
+		traits = append(traits, "no other fields")
+	}
+	return traits
+}
+
+// valueConstraints covers the constraints that apply to a single value:
+// enum, const, format, pattern, length and numeric bounds, default and
+// nullable.
+func valueConstraints(s map[string]any) []string {
+	var out []string
+	if enum, ok := s["enum"].([]any); ok && len(enum) > 0 {
+		values := make([]string, 0, len(enum))
+		for _, v := range enum {
+			values = append(values, compactSchemaJSON(v))
+		}
+		out = append(out, "one of "+strings.Join(values, " | "))
+	}
+	if c, ok := s["const"]; ok {
+		out = append(out, "must be "+compactSchemaJSON(c))
+	}
+	if format := schemaString(s["format"]); format != "" {
+		out = append(out, "format "+format)
+	}
+	if pattern := schemaString(s["pattern"]); pattern != "" {
+		out = append(out, "pattern "+pattern)
+	}
+	if n, ok := schemaNumber(s["minLength"]); ok {
+		out = append(out, "min length "+n)
+	}
+	if n, ok := schemaNumber(s["maxLength"]); ok {
+		out = append(out, "max length "+n)
+	}
+	for _, bound := range []struct{ key, op string }{
+		{"minimum", ">="}, {"exclusiveMinimum", ">"}, {"maximum", "<="}, {"exclusiveMaximum", "<"},
+	} {
+		if n, ok := schemaNumber(s[bound.key]); ok {
+			out = append(out, bound.op+" "+n)
+		}
+	}
+	if d, ok := s["default"]; ok {
+		out = append(out, "default "+compactSchemaJSON(d))
+	}
+	if nullable, _ := s["nullable"].(bool); nullable {
+		out = append(out, "nullable")
+	}
+	return out
+}
+
+func schemaTypeLabel(s map[string]any) string {
+	label := ""
+	switch t := s["type"].(type) {
+	case string:
+		label = t
+	case []any:
+		types := make([]string, 0, len(t))
+		for _, item := range t {
+			if name, ok := item.(string); ok {
+				types = append(types, name)
+			}
+		}
+		label = strings.Join(types, " or ")
+	}
+	if label == "" {
+		switch {
+		case s["properties"] != nil:
+			label = "object"
+		case s["items"] != nil:
+			label = "array"
+		default:
+			label = "any"
+		}
+	}
+	if label == "array" {
+		if items, ok := s["items"].(map[string]any); ok {
+			if itemLabel := schemaTypeLabel(items); itemLabel != "any" {
+				label = "array of " + itemLabel
+			}
+		}
+	}
+	return label
+}
+
+// orderedPropertyNames lists required properties in their declared order,
+// then the rest alphabetically, so the outline is stable across requests.
+func orderedPropertyNames(props map[string]any, required []string) []string {
+	names := make([]string, 0, len(props))
+	for _, name := range required {
+		if _, ok := props[name]; ok && !slices.Contains(names, name) {
+			names = append(names, name)
+		}
+	}
+	rest := make([]string, 0, len(props))
+	for name := range props {
+		if !slices.Contains(names, name) {
+			rest = append(rest, name)
+		}
+	}
+	sort.Strings(rest)
+	return append(names, rest...)
+}
+
+func schemaRequired(s map[string]any) []string {
+	raw, _ := s["required"].([]any)
+	out := make([]string, 0, len(raw))
+	for _, item := range raw {
+		if name, ok := item.(string); ok && name != "" {
+			out = append(out, name)
+		}
+	}
+	return out
+}
+
+func hasSchemaCombinator(s map[string]any) bool {
+	for _, key := range []string{"anyOf", "oneOf", "allOf", "not", "$ref", "patternProperties", "if"} {
+		if _, ok := s[key]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+func hasNestedFields(s map[string]any) bool {
+	if props, ok := s["properties"].(map[string]any); ok && len(props) > 0 {
+		return true
+	}
+	items, ok := s["items"].(map[string]any)
+	if !ok {
+		return false
+	}
+	props, ok := items["properties"].(map[string]any)
+	return ok && len(props) > 0
+}
+
+func additionalPropertiesClosed(s map[string]any) bool {
+	allowed, ok := s["additionalProperties"].(bool)
+	return ok && !allowed
+}
+
+func withoutDescription(s map[string]any) map[string]any {
+	if _, ok := s["description"]; !ok {
+		return s
+	}
+	out := make(map[string]any, len(s))
+	for k, v := range s {
+		if k != "description" {
+			out[k] = v
+		}
+	}
+	return out
+}
+
+func schemaString(v any) string {
+	s, _ := v.(string)
+	return strings.TrimSpace(s)
+}
+
+func schemaNumber(v any) (string, bool) {
+	switch n := v.(type) {
+	case float64:
+		return fmt.Sprint(n), true
+	case int:
+		return fmt.Sprint(n), true
+	case json.Number:
+		return n.String(), true
+	default:
+		return "", false
+	}
+}
+
+func collapseWhitespace(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
+
+func compactSchemaJSON(v any) string {
+	b, _ := json.Marshal(v)
+	return string(b)
+}
@@ -0,0 +1,71 @@
+package toolcall
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestFormatToolParametersRendersOutline(t *testing.T) {
+	schema := map[string]any{
+		"type":                 "object",
+		"additionalProperties": false,
+		"required":             []any{"path", "mode"},
+		"properties": map[string]any{
+			"encoding": map[string]any{"type": "string", "default": "utf-8"},
+			"mode":     map[string]any{"type": "string", "enum": []any{"read", "write"}, "description": "Access\n  mode."},
+			"path":     map[string]any{"type": "string", "minLength": 1.0},
+			"options": map[string]any{
+				"type":       "object",
+				"required":   []any{"recursive"},
+				"properties": map[string]any{"recursive": map[string]any{"type": "boolean"}},
+			},
+			"edits": map[string]any{
+				"type":     "array",
+				"minItems": 1.0,
+				"items": map[string]any{
+					"type":       "object",
+					"properties": map[string]any{"line": map[string]any{"type": "integer", "minimum": 1.0}},
+				},
+			},
+		},
+	}
+	want := strings.Join([]string{
+		"Parameters: (no other parameters)",
+		"- path (string, required, min length 1)",
+		`- mode (string, required, one of "read" | "write"): Access mode.`,
+		"- edits (array of object, at least 1 items)",
+		"  - line (integer, >= 1)",
+		`- encoding (string, default "utf-8")`,
+		"- options (object)",
+		"  - recursive (boolean, required)",
+	}, "\n")
+	if got := FormatToolParameters(schema); got != want {
+		t.Fatalf("unexpected outline:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestFormatToolParametersFallsBackToJSON(t *testing.T) {
+	for _, tc := range []struct {
+		schema any
+		want   string
+	}{
+		{map[string]any{"type": "object"}, `Parameters: {"type":"object"}`},
+		{map[string]any{"type": "object", "properties": map[string]any{}}, "Parameters: none"},
+		{map[string]any{"oneOf": []any{map[string]any{"type": "string"}}}, `Parameters: {"oneOf":[{"type":"string"}]}`},
+		{nil, "Parameters: null"},
+	} {
+		if got := FormatToolParameters(tc.schema); got != tc.want {
+			t.Fatalf("%v: got %q want %q", tc.schema, got, tc.want)
+		}
+	}
+	nested := map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"value": map[string]any{"description": "Either form", "anyOf": []any{map[string]any{"type": "string"}, map[string]any{"type": "number"}}},
+		},
+	}
+	if got := FormatToolParameters(nested); got != `Parameters:
+- value (any, schema {"anyOf":[{"type":"string"},{"type":"number"}]}): Either form` {
+		t.Fatalf("unexpected combinator rendering: %q", got)
+	}
+}
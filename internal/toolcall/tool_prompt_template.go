@@ -0,0 +1,185 @@
+package toolcall
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync/atomic"
+	"text/template"
+)
+
+// Tool call formats selectable through tool_prompt.format. The format decides
+// both the built-in instructions and which parser reads the model output.
+const (
+	CallFormatDSML = "dsml"
+	CallFormatJSON = "json"
+)
+
+// PromptTool is one tool as seen by a tool prompt template.
+type PromptTool struct {
+	Name        string
+	Description string
+	// Parameters is the decoded JSON schema; ParametersJSON is the same
+	// schema as compact JSON and ParametersText the outline the built-in
+	// prompt uses (see FormatToolParameters).
+	Parameters     any
+	ParametersJSON string
+	ParametersText string
+}
+
+// PromptData is the value a tool prompt template is executed with.
+type PromptData struct {
+	Tools     []PromptTool
+	ToolNames []string
+	// Format is the active tool call format, "dsml" or "json".
+	Format string
+	// Required and ForcedName carry tool_choice: any tool must be called, or
+	// exactly the named one.
+	Required   bool
+	ForcedName string
+	// ToolsAttached reports that the tool list is delivered separately (as
+	// DS2API_TOOLS.txt), so the template may skip the descriptions.
+	ToolsAttached bool
+}
+
+// PromptSettings is one snapshot of the tool call format and the custom tool
+// prompt template. A request captures it once (see ActivePrompt) and uses it
+// for its prompt, its stream sieve and its final parse, so a config reload
+// never switches the format under a request that is already running.
+type PromptSettings struct {
+	// Format is CallFormatDSML or CallFormatJSON.
+	Format string
+	// Template is the custom tool prompt template, nil for the built-in one.
+	Template *template.Template
+}
+
+var activePrompt atomic.Pointer[PromptSettings]
+
+// ConfigurePrompt installs the tool call format and, when tmpl is non-nil, a
+// custom tool prompt template for requests that start afterwards. An unknown
+// format selects DSML.
+func ConfigurePrompt(format string, tmpl *template.Template) {
+	activePrompt.Store(&PromptSettings{Format: NormalizeCallFormat(format), Template: tmpl})
+}
+
+// ActivePrompt returns the settings new requests should capture.
+func ActivePrompt() PromptSettings {
+	if s := activePrompt.Load(); s != nil {
+		return *s
+	}
+	return PromptSettings{Format: CallFormatDSML}
+}
+
+// ActiveCallFormat returns the configured tool call format.
+func ActiveCallFormat() string {
+	return ActivePrompt().Format
+}
+
+// NormalizeCallFormat maps format to CallFormatJSON or CallFormatDSML.
+func NormalizeCallFormat(format string) string {
+	if strings.ToLower(strings.TrimSpace(format)) == CallFormatJSON {
+		return CallFormatJSON
+	}
+	return CallFormatDSML
+}
+
+// HasPromptTemplate reports whether a custom tool prompt template is set.
+func HasPromptTemplate() bool {
+	return ActivePrompt().Template != nil
+}
+
+// RenderPromptTemplate executes the active custom tool prompt template.
+func RenderPromptTemplate(data PromptData) (string, bool) {
+	return ActivePrompt().Render(data)
+}
+
+// Render executes the custom tool prompt template. ok is false when no
+// template is set or it fails on this request's tools, in which case callers
+// use the built-in prompt.
+func (p PromptSettings) Render(data PromptData) (string, bool) {
+	if p.Template == nil {
+		return "", false
+	}
+	data.Format = NormalizeCallFormat(p.Format)
+	var b strings.Builder
+	if err := p.Template.Execute(&b, data); err != nil {
+		return "", false
+	}
+	out := strings.TrimSpace(b.String())
+	return out, out != ""
+}
+
+// Instructions returns the built-in tool calling instructions for the format.
+func (p PromptSettings) Instructions(toolNames []string) string {
+	return buildToolCallInstructionsForFormat(toolNames, p.Format)
+}
+
+// NewPromptTool builds the template view of a tool from its name,
+// description and parameter schema.
+func NewPromptTool(name, description string, schema any) PromptTool {
+	b, _ := json.Marshal(schema)
+	return PromptTool{Name: name, Description: description, Parameters: schema, ParametersJSON: string(b), ParametersText: FormatToolParameters(schema)}
+}
+
+var promptTemplateFuncs = template.FuncMap{
+	"json": func(v any) (string, error) {
+		b, err := json.Marshal(v)
+		return string(b), err
+	},
+	"join": strings.Join,
+}
+
+// ParsePromptTemplate parses a tool prompt template and executes it once
+// against sample tools, so field typos and runtime errors surface at startup
+// rather than on the first tool request.
+func ParsePromptTemplate(text string) (*template.Template, error) {
+	tmpl, err := template.New("tool_prompt").Funcs(promptTemplateFuncs).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, err
+	}
+	sample := PromptData{
+		Tools: []PromptTool{
+			NewPromptTool("read_file", "Read a file", map[string]any{
+				"type":       "object",
+				"properties": map[string]any{"path": map[string]any{"type": "string"}},
+				"required":   []any{"path"},
+			}),
+			NewPromptTool("list_files", "", map[string]any{"type": "object"}),
+		},
+		ToolNames: []string{"read_file", "list_files"},
+		Format:    CallFormatDSML,
+	}
+	var b strings.Builder
+	if err := tmpl.Execute(&b, sample); err != nil {
+		return nil, err
+	}
+	if strings.TrimSpace(b.String()) == "" {
+		return nil, fmt.Errorf("template renders an empty prompt")
+	}
+	forced := sample
+	forced.Format, forced.Required, forced.ForcedName, forced.ToolsAttached = CallFormatJSON, true, "read_file", true
+	if err := tmpl.Execute(io.Discard, forced); err != nil {
+		return nil, err
+	}
+	return tmpl, nil
+}
+
+// LoadPromptTemplate reads and validates the tool prompt template at path. An
+// empty path means no custom template.
+func LoadPromptTemplate(path string) (*template.Template, error) {
+	path = strings.TrimSpace(path)
+	if path == "" {
+		return nil, nil
+	}
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	tmpl, err := ParsePromptTemplate(string(raw))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return tmpl, nil
+}
@@ -0,0 +1,76 @@
+package toolcall
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func configurePromptForTest(t *testing.T, format, text string) {
+	t.Helper()
+	if text == "" {
+		ConfigurePrompt(format, nil)
+	} else {
+		tmpl, err := ParsePromptTemplate(text)
+		if err != nil {
+			t.Fatalf("parse template: %v", err)
+		}
+		ConfigurePrompt(format, tmpl)
+	}
+	t.Cleanup(func() { ConfigurePrompt("", nil) })
+}
+
+func TestParsePromptTemplateRejectsBadTemplates(t *testing.T) {
+	for name, text := range map[string]string{
+		"syntax":        `{{range .Tools}}{{.Name}}`,
+		"unknown field": `{{range .Tools}}{{.Schema}}{{end}}`,
+		"empty output":  `{{if false}}tools{{end}}`,
+		"forced path":   `{{if .ForcedName}}{{index .ToolNames 5}}{{end}}tools`,
+	} {
+		if _, err := ParsePromptTemplate(text); err == nil {
+			t.Fatalf("%s: expected the template to be rejected", name)
+		}
+	}
+}
+
+func TestLoadPromptTemplateReportsPath(t *testing.T) {
+	if tmpl, err := LoadPromptTemplate(""); tmpl != nil || err != nil {
+		t.Fatalf("expected no template for an empty path, got %v %v", tmpl, err)
+	}
+	path := filepath.Join(t.TempDir(), "tools.tmpl")
+	if err := os.WriteFile(path, []byte(`{{.Missing}}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadPromptTemplate(path); err == nil || !strings.Contains(err.Error(), path) {
+		t.Fatalf("expected an error naming %s, got %v", path, err)
+	}
+}
+
+func TestRenderPromptTemplateExposesToolsAndFormat(t *testing.T) {
+	configurePromptForTest(t, CallFormatJSON, `Format: {{.Format}}
+{{range .Tools}}- {{.Name}}: {{.Description}} {{.ParametersJSON}} {{json .Parameters.type}}
+{{end}}Names: {{join .ToolNames ", "}}`)
+	got, ok := RenderPromptTemplate(PromptData{
+		Tools:     []PromptTool{NewPromptTool("search", "Search the web", map[string]any{"type": "object"})},
+		ToolNames: []string{"search"},
+	})
+	if !ok {
+		t.Fatal("expected the template to render")
+	}
+	want := "Format: json\n- search: Search the web {\"type\":\"object\"} \"object\"\nNames: search"
+	if got != want {
+		t.Fatalf("unexpected render:\nwant %q\ngot  %q", want, got)
+	}
+}
+
+func TestBuildToolCallInstructionsFollowsCallFormat(t *testing.T) {
+	if !strings.Contains(BuildToolCallInstructions([]string{"search"}), "<|DSML|tool_calls>") {
+		t.Fatal("expected DSML instructions by default")
+	}
+	configurePromptForTest(t, CallFormatJSON, "")
+	got := BuildToolCallInstructions([]string{"search"})
+	if strings.Contains(got, "DSML") || !strings.Contains(got, `{"tool_calls":[{"name":"search","arguments":{}}]}`) {
+		t.Fatalf("expected JSON instructions, got %q", got)
+	}
+}
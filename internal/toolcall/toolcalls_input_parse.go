@@ -39,6 +39,12 @@ func parseToolCallInput(v any) map[string]any {
 				return parsed
 			}
 		}
+		if value, ok := parseRepairedJSONValue(raw, ""); ok {
+			if obj, ok := value.(map[string]any); ok && obj != nil {
+				repairPathLikeControlChars(obj)
+				return obj
+			}
+		}
 		return map[string]any{"_raw": raw}
 	default:
 		b, err := json.Marshal(x)
@@ -0,0 +1,140 @@
+package toolcall
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"regexp"
+	"strings"
+)
+
+const jsonToolCallsKey = `{"tool_calls":`
+
+var jsonToolCallsOpenPattern = regexp.MustCompile(`\{\s*"tool_calls"\s*:`)
+
+// buildJSONToolCallInstructions is the built-in prompt for the "json" tool
+// call format. It keeps to six rules so the tool_choice lines appended by the
+// adapters continue the numbering.
+func buildJSONToolCallInstructions(toolNames []string) string {
+	example := "TOOL_NAME_HERE"
+	if names := uniqueToolNames(toolNames); len(names) > 0 {
+		example = names[0]
+	}
+	return `TOOL CALL FORMAT — FOLLOW EXACTLY:
+
+{"tool_calls":[{"name":"TOOL_NAME_HERE","arguments":{"PARAMETER_NAME":"PARAMETER_VALUE"}}]}
+
+RULES:
+1) To call tools, output exactly one JSON object with a "tool_calls" array, on its own line at the end of your response.
+2) Each entry has "name" (a tool from the list) and "arguments" (a JSON object matching that tool's parameter schema). Put several entries in the array to call tools in parallel.
+3) Use only the parameter names in the tool schema. Do not invent fields.
+4) Fill parameters with the actual values required for this call. If a required value is unknown, ask the user or answer normally instead.
+5) The JSON must be valid: double-quoted keys and strings, escaped newlines and quotes inside strings.
+6) Do NOT wrap the JSON in markdown fences and do NOT write anything after it.
+
+Example:
+{"tool_calls":[{"name":"` + example + `","arguments":{}}]}
+`
+}
+
+// FindJSONToolCallsStart returns the index of the first `{"tool_calls":`
+// opener at or after from, or -1.
+func FindJSONToolCallsStart(s string, from int) int {
+	if from < 0 || from >= len(s) {
+		return -1
+	}
+	loc := jsonToolCallsOpenPattern.FindStringIndex(s[from:])
+	if loc == nil {
+		return -1
+	}
+	return from + loc[0]
+}
+
+// PartialJSONToolCallsStart returns the index of a trailing fragment of s
+// that could still grow into a `{"tool_calls":` opener, or -1.
+func PartialJSONToolCallsStart(s string) int {
+	for i := strings.LastIndexByte(s, '{'); i >= 0; i = strings.LastIndexByte(s[:i], '{') {
+		compact := strings.Join(strings.Fields(s[i:]), "")
+		if len(compact) > len(jsonToolCallsKey) {
+			return -1
+		}
+		if strings.HasPrefix(jsonToolCallsKey, compact) {
+			if compact == jsonToolCallsKey {
+				return -1
+			}
+			return i
+		}
+	}
+	return -1
+}
+
+// DecodeJSONToolCalls decodes the `{"tool_calls":[...]}` object at the start
+// of s. complete is false when s ends before the object does; end is the
+// offset just past the object.
+func DecodeJSONToolCalls(s string) (calls []ParsedToolCall, end int, complete bool) {
+	dec := json.NewDecoder(strings.NewReader(s))
+	var payload map[string]any
+	if err := dec.Decode(&payload); err != nil {
+		if errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, io.EOF) {
+			return nil, 0, false
+		}
+		return nil, 0, true
+	}
+	entries, _ := payload["tool_calls"].([]any)
+	for _, item := range entries {
+		entry, ok := item.(map[string]any)
+		if !ok {
+			continue
+		}
+		name := strings.TrimSpace(asStringValue(entry["name"]))
+		args := firstNonNil(entry["arguments"], entry["input"], entry["parameters"])
+		if fn, ok := entry["function"].(map[string]any); ok {
+			if name == "" {
+				name = strings.TrimSpace(asStringValue(fn["name"]))
+			}
+			args = firstNonNil(args, fn["arguments"])
+		}
+		calls = append(calls, ParsedToolCall{Name: name, Input: parseToolCallInput(args)})
+	}
+	return calls, int(dec.InputOffset()), true
+}
+
+// parseToolCallsDetailedJSON reads tool calls written in the "json" format.
+// Fenced code blocks are skipped like in the markup parser.
+func parseToolCallsDetailedJSON(text string) ToolCallParseResult {
+	result := ToolCallParseResult{}
+	trimmed := strings.TrimSpace(stripFencedCodeBlocks(strings.TrimSpace(text)))
+	for from := 0; ; {
+		start := FindJSONToolCallsStart(trimmed, from)
+		if start < 0 {
+			return result
+		}
+		result.SawToolCallSyntax = true
+		parsed, _, complete := DecodeJSONToolCalls(trimmed[start:])
+		if complete && len(parsed) > 0 {
+			calls, rejectedNames := filterToolCallsDetailed(parsed)
+			if len(calls) > 0 {
+				result.Calls = calls
+				result.RejectedToolNames = rejectedNames
+				return result
+			}
+		}
+		from = start + 1
+	}
+}
+
+// parseToolCallsDetailedForFormat dispatches on the call format. The json
+// format still accepts markup blocks because conversation history renders
+// earlier tool calls as DSML.
+func parseToolCallsDetailedForFormat(text, format string) ToolCallParseResult {
+	if NormalizeCallFormat(format) != CallFormatJSON {
+		return parseToolCallsDetailedXMLOnly(text)
+	}
+	jsonResult := parseToolCallsDetailedJSON(text)
+	if len(jsonResult.Calls) > 0 {
+		return jsonResult
+	}
+	xmlResult := parseToolCallsDetailedXMLOnly(text)
+	xmlResult.SawToolCallSyntax = xmlResult.SawToolCallSyntax || jsonResult.SawToolCallSyntax
+	return xmlResult
+}
@@ -0,0 +1,53 @@
+package toolcall
+
+import "testing"
+
+func TestParseToolCallsJSONFormat(t *testing.T) {
+	configurePromptForTest(t, CallFormatJSON, "")
+	text := "Let me check.\n" + `{"tool_calls":[{"name":"read_file","arguments":{"path":"a.go"}},{"name":"search","arguments":"{\"q\":\"x\"}"}]}`
+	calls := ParseToolCalls(text, []string{"read_file", "search"})
+	if len(calls) != 2 {
+		t.Fatalf("expected two calls, got %#v", calls)
+	}
+	if calls[0].Name != "read_file" || calls[0].Input["path"] != "a.go" {
+		t.Fatalf("unexpected first call: %#v", calls[0])
+	}
+	if calls[1].Name != "search" || calls[1].Input["q"] != "x" {
+		t.Fatalf("expected string arguments to be decoded, got %#v", calls[1])
+	}
+}
+
+func TestParseToolCallsJSONFormatIgnoresFencesAndFallsBackToMarkup(t *testing.T) {
+	configurePromptForTest(t, CallFormatJSON, "")
+	fenced := "Example:\n```json\n{\"tool_calls\":[{\"name\":\"read_file\",\"arguments\":{}}]}\n```"
+	if calls := ParseToolCalls(fenced, []string{"read_file"}); len(calls) != 0 {
+		t.Fatalf("expected fenced JSON to be ignored, got %#v", calls)
+	}
+	markup := `<|DSML|tool_calls><|DSML|invoke name="read_file"><|DSML|parameter name="path"><![CDATA[b.go]]></|DSML|parameter></|DSML|invoke></|DSML|tool_calls>`
+	calls := ParseToolCalls(markup, []string{"read_file"})
+	if len(calls) != 1 || calls[0].Input["path"] != "b.go" {
+		t.Fatalf("expected the markup fallback to parse, got %#v", calls)
+	}
+}
+
+func TestParseToolCallsDSMLFormatIgnoresJSON(t *testing.T) {
+	text := `{"tool_calls":[{"name":"read_file","arguments":{"path":"a.go"}}]}`
+	if calls := ParseToolCalls(text, []string{"read_file"}); len(calls) != 0 {
+		t.Fatalf("expected JSON to stay text under the dsml format, got %#v", calls)
+	}
+}
+
+func TestPartialJSONToolCallsStart(t *testing.T) {
+	cases := map[string]int{
+		"answer {":             7,
+		`answer { "tool_ca`:    7,
+		`answer {"tool_calls"`: 7,
+		`answer {"other"`:      -1,
+		"no brace":             -1,
+	}
+	for input, want := range cases {
+		if got := PartialJSONToolCallsStart(input); got != want {
+			t.Fatalf("%q: want %d, got %d", input, want, got)
+		}
+	}
+}
@@ -1,8 +1,13 @@
 package toolcall
 
 import (
+	"encoding/json"
 	"regexp"
 	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"ds2api/internal/config"
 )
 
 func repairInvalidJSONBackslashes(s string) string {
@@ -53,7 +58,7 @@ func repairInvalidJSONBackslashes(s string) string {
 	return out.String()
 }
 
-var unquotedKeyPattern = regexp.MustCompile(`([{,]\s*)([a-zA-Z_][a-zA-Z0-9_]*)\s*:`)
+var unquotedKeyPattern = regexp.MustCompile(`([{,]\s*)([\p{L}_][\p{L}\p{M}\p{N}_]*)\s*:`)
 
 // missingArrayBracketsPattern identifies a sequence of two or more JSON objects separated by commas
 // that immediately follow a colon, which indicates a missing array bracket `[` `]`.
@@ -77,3 +82,173 @@ func RepairLooseJSON(s string) string {
 
 	return s
 }
+
+// repairJSONSyntax rewrites the near-JSON drift DeepSeek produces in tool
+// arguments: single-quoted strings, unquoted object keys, trailing commas and
+// Python literals (True/False/None). It scans token by token instead of using
+// regexes, so double-quoted strings are copied byte for byte and a nested
+// JSON document carried as a string value survives unchanged.
+func repairJSONSyntax(s string) string {
+	var out strings.Builder
+	out.Grow(len(s) + 16)
+	var lastSignificant byte
+	for i := 0; i < len(s); {
+		c := s[i]
+		switch {
+		case c == '"':
+			end := scanQuotedEnd(s, i)
+			out.WriteString(s[i:end])
+			i = end
+			lastSignificant = '"'
+		case c == '\'':
+			end := scanQuotedEnd(s, i)
+			body := s[i+1 : end]
+			if end > i+1 && s[end-1] == '\'' {
+				body = s[i+1 : end-1]
+			}
+			out.WriteString(requoteSingleQuoted(body))
+			i = end
+			lastSignificant = '"'
+		case c == ',':
+			j := i + 1
+			for j < len(s) && isJSONSpace(s[j]) {
+				j++
+			}
+			if j < len(s) && (s[j] == '}' || s[j] == ']') {
+				i++
+				continue
+			}
+			out.WriteByte(c)
+			i++
+			lastSignificant = c
+		case identRuneLen(s, i, true) > 0:
+			j := i + identRuneLen(s, i, true)
+			for j < len(s) {
+				n := identRuneLen(s, j, false)
+				if n == 0 {
+					break
+				}
+				j += n
+			}
+			word := s[i:j]
+			k := j
+			for k < len(s) && isJSONSpace(s[k]) {
+				k++
+			}
+			switch {
+			case k < len(s) && s[k] == ':' && (lastSignificant == '{' || lastSignificant == ','):
+				out.WriteString(`"` + word + `"`)
+			case word == "True":
+				out.WriteString("true")
+			case word == "False":
+				out.WriteString("false")
+			case word == "None":
+				out.WriteString("null")
+			default:
+				out.WriteString(word)
+			}
+			i = j
+			lastSignificant = 'a'
+		default:
+			out.WriteByte(c)
+			if !isJSONSpace(c) {
+				lastSignificant = c
+			}
+			i++
+		}
+	}
+	return out.String()
+}
+
+// scanQuotedEnd returns the index just past the string literal opening at
+// start, honouring backslash escapes, or len(s) when it is unterminated.
+func scanQuotedEnd(s string, start int) int {
+	quote := s[start]
+	for i := start + 1; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case quote:
+			return i + 1
+		}
+	}
+	return len(s)
+}
+
+func requoteSingleQuoted(body string) string {
+	var out strings.Builder
+	out.Grow(len(body) + 2)
+	out.WriteByte('"')
+	for i := 0; i < len(body); i++ {
+		switch c := body[i]; {
+		case c == '\\' && i+1 < len(body) && body[i+1] == '\'':
+			out.WriteByte('\'')
+			i++
+		case c == '\\' && i+1 < len(body):
+			out.WriteByte(c)
+			out.WriteByte(body[i+1])
+			i++
+		case c == '"':
+			out.WriteString(`\"`)
+		default:
+			out.WriteByte(c)
+		}
+	}
+	out.WriteByte('"')
+	return out.String()
+}
+
+func isJSONSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r'
+}
+
+func isIdentStart(c byte) bool {
+	return c == '_' || c == '$' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+// identRuneLen returns the byte length of the identifier character at s[i],
+// or 0 when there is none. Letters of any script count, so a bare key such as
+// {名前: "x"} or {clé: 1} is quoted like an ASCII one; digits and combining
+// marks may continue an identifier but not start it.
+func identRuneLen(s string, i int, first bool) int {
+	if c := s[i]; c < utf8.RuneSelf {
+		if isIdentStart(c) || (!first && c >= '0' && c <= '9') {
+			return 1
+		}
+		return 0
+	}
+	r, size := utf8.DecodeRuneInString(s[i:])
+	if unicode.IsLetter(r) || (!first && (unicode.IsNumber(r) || unicode.Is(unicode.M, r))) {
+		return size
+	}
+	return 0
+}
+
+// parseRepairedJSONValue decodes a parameter body that looks like a JSON
+// object or array but fails strict parsing. Free-text parameters such as
+// content or command are left alone, since code there often looks like loose
+// JSON. A body that still does not parse is logged and kept as a string.
+func parseRepairedJSONValue(raw, paramName string) (any, bool) {
+	if preservesCDATAStringParameter(paramName) {
+		return nil, false
+	}
+	trimmed := strings.TrimSpace(raw)
+	if !looksLikeJSONContainer(trimmed) {
+		return nil, false
+	}
+	var parsed any
+	err := json.Unmarshal([]byte(repairJSONSyntax(trimmed)), &parsed)
+	if err == nil {
+		return parsed, true
+	}
+	config.Logger.Warn("[tool_call] argument looks like JSON but could not be repaired; keeping raw string", "parameter", paramName, "bytes", len(trimmed), "error", err)
+	return nil, false
+}
+
+func looksLikeJSONContainer(s string) bool {
+	if len(s) < 2 {
+		return false
+	}
+	first, last := s[0], s[len(s)-1]
+	return (first == '{' && last == '}') || (first == '[' && last == ']')
+}
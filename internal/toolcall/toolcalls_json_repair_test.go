@@ -0,0 +1,96 @@
+package toolcall
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func dsmlCall(param, body string) string {
+	return `<|DSML|tool_calls><|DSML|invoke name="configure"><|DSML|parameter name="` + param + `">` + body + `</|DSML|parameter></|DSML|invoke></|DSML|tool_calls>`
+}
+
+func TestParseToolCallsRepairsMalformedJSONArguments(t *testing.T) {
+	want := map[string]any{"name": "demo", "tags": []any{"a", "b"}, "enabled": true}
+	tests := []struct {
+		name string
+		body string
+	}{
+		{"trailing commas", `{"name": "demo", "tags": ["a", "b",], "enabled": true,}`},
+		{"unquoted keys", `{name: "demo", tags: ["a", "b"], enabled: true}`},
+		{"single quotes", `{'name': 'demo', 'tags': ['a', 'b'], 'enabled': true}`},
+		{"python literals", `{"name": "demo", "tags": ["a", "b"], "enabled": True}`},
+		{"all at once in CDATA", `<![CDATA[{name: 'demo', tags: ['a', 'b',], enabled: True,}]]>`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls := ParseToolCalls(dsmlCall("options", tt.body), []string{"configure"})
+			if len(calls) != 1 {
+				t.Fatalf("expected one call, got %#v", calls)
+			}
+			if got := calls[0].Input["options"]; !reflect.DeepEqual(got, want) {
+				t.Fatalf("options=%#v want %#v", got, want)
+			}
+		})
+	}
+}
+
+func TestRepairJSONSyntaxPreservesNestedJSONStrings(t *testing.T) {
+	nested := `{\"a\": [1, 2,], 'b': {c: True}}`
+	in := `{payload: "` + nested + `", 'note': 'it\'s "quoted"',}`
+	var parsed map[string]any
+	if err := json.Unmarshal([]byte(repairJSONSyntax(in)), &parsed); err != nil {
+		t.Fatalf("repaired JSON does not parse: %v (%s)", err, repairJSONSyntax(in))
+	}
+	if parsed["payload"] != `{"a": [1, 2,], 'b': {c: True}}` {
+		t.Fatalf("nested JSON string was altered: %q", parsed["payload"])
+	}
+	if parsed["note"] != `it's "quoted"` {
+		t.Fatalf("single-quoted string not converted: %q", parsed["note"])
+	}
+}
+
+func TestParseToolCallsRepairsJSONArgumentsWithMultiByteText(t *testing.T) {
+	want := map[string]any{"城市": "東京 🗼", "clé": "naïve e\u0301", "emoji": "👨\u200d👩\u200d👧", "note_1": "混合 scripts"}
+	tests := []struct {
+		name string
+		body string
+	}{
+		{"unquoted keys in any script", `{城市: "東京 🗼", clé: "naïve e\u0301", emoji: "👨\u200d👩\u200d👧", note_1: "混合 scripts"}`},
+		{"single quotes and trailing comma", `{'城市': '東京 🗼', 'clé': 'naïve e\u0301', 'emoji': '👨\u200d👩\u200d👧', 'note_1': '混合 scripts',}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls := ParseToolCalls(dsmlCall("options", tt.body), []string{"configure"})
+			if len(calls) != 1 {
+				t.Fatalf("expected one call, got %#v", calls)
+			}
+			if got := calls[0].Input["options"]; !reflect.DeepEqual(got, want) {
+				t.Fatalf("options=%#v want %#v", got, want)
+			}
+		})
+	}
+}
+
+func TestParseToolCallsKeepsUnrepairableArgumentsRaw(t *testing.T) {
+	body := `{"name": "demo", "tags": [unclosed}`
+	calls := ParseToolCalls(dsmlCall("options", body), []string{"configure"})
+	if len(calls) != 1 || calls[0].Input["options"] != body {
+		t.Fatalf("expected raw string fallback, got %#v", calls)
+	}
+}
+
+func TestParseToolCallsDoesNotRepairFreeTextParameters(t *testing.T) {
+	code := `{ greeting: 'hi', }`
+	calls := ParseToolCalls(dsmlCall("code", `<![CDATA[`+code+`]]>`), []string{"configure"})
+	if len(calls) != 1 || calls[0].Input["code"] != code {
+		t.Fatalf("expected code parameter kept verbatim, got %#v", calls)
+	}
+}
+
+func TestParseToolCallInputRepairsJSONStringArguments(t *testing.T) {
+	parsed := parseToolCallInput(`{'query': 'golang', limit: 5,}`)
+	if parsed["query"] != "golang" || parsed["limit"] != float64(5) {
+		t.Fatalf("unexpected repaired input: %#v", parsed)
+	}
+}
@@ -21,7 +21,7 @@ func ParseToolCalls(text string, availableToolNames []string) []ParsedToolCall {
 }
 
 func ParseToolCallsDetailed(text string, availableToolNames []string) ToolCallParseResult {
-	return parseToolCallsDetailedXMLOnly(text)
+	return parseToolCallsDetailedForFormat(text, ActiveCallFormat())
 }
 
 func ParseStandaloneToolCalls(text string, availableToolNames []string) []ParsedToolCall {
@@ -29,7 +29,13 @@ func ParseStandaloneToolCalls(text string, availableToolNames []string) []Parsed
 }
 
 func ParseStandaloneToolCallsDetailed(text string, availableToolNames []string) ToolCallParseResult {
-	return parseToolCallsDetailedXMLOnly(text)
+	return parseToolCallsDetailedForFormat(text, ActiveCallFormat())
+}
+
+// ParseStandaloneToolCallsDetailedForFormat parses with the call format a
+// request captured, instead of the one configured now.
+func ParseStandaloneToolCallsDetailedForFormat(text string, availableToolNames []string, format string) ToolCallParseResult {
+	return parseToolCallsDetailedForFormat(text, format)
 }
 
 func ParseAssistantToolCallsDetailed(text, thinking string, availableToolNames []string) ToolCallParseResult {
@@ -517,6 +517,9 @@ func parseInvokeParameterValue(paramName, raw string) any {
 		if parsed, ok := parseLooseJSONArrayValue(value, paramName); ok {
 			return parsed
 		}
+		if parsed, ok := parseRepairedJSONValue(value, paramName); ok {
+			return parsed
+		}
 		return value
 	}
 	decoded := html.UnescapeString(extractRawTagValue(trimmed))
@@ -575,6 +578,9 @@ func parseInvokeParameterValue(paramName, raw string) any {
 	if parsed, ok := parseLooseJSONArrayValue(decoded, paramName); ok {
 		return parsed
 	}
+	if parsed, ok := parseRepairedJSONValue(decoded, paramName); ok {
+		return parsed
+	}
 	return decoded
 }
 
@@ -0,0 +1,85 @@
+package toolcall
+
+import (
+	"html"
+	"strings"
+)
+
+// StreamingInvoke is an invoke seen in a possibly unfinished tool block.
+// Params lists its parameters whose closing tag has already arrived, in
+// arrival order. Each Value is the value the parameter's key holds once that
+// parameter is applied, so a repeated key carries the merged value.
+type StreamingInvoke struct {
+	Name   string
+	Params []StreamingParam
+}
+
+type StreamingParam struct {
+	Name  string
+	Value any
+}
+
+// StreamingInvokes returns the invokes whose opening tag is already complete
+// inside the first tool_calls wrapper of a possibly unfinished tool block,
+// with the parameters that have closed so far. Streaming callers use it to
+// announce tool names and arguments before the whole block closes. Scanning
+// stops at the first invoke whose closing tag has not arrived yet, so
+// parameter bodies are never mistaken for sibling invokes. Parameter values
+// are decoded the same way the final parse decodes them.
+func StreamingInvokes(text string) []StreamingInvoke {
+	if strings.TrimSpace(text) == "" {
+		return nil
+	}
+	normalized, _ := normalizeDSMLToolCallMarkup(text)
+	_, from, _, ok := findXMLStartTagOutsideCDATA(normalized, "tool_calls", 0)
+	if !ok {
+		return nil
+	}
+	var invokes []StreamingInvoke
+	for {
+		_, bodyStart, attrs, ok := findXMLStartTagOutsideCDATA(normalized, "invoke", from)
+		if !ok {
+			return invokes
+		}
+		name := strings.TrimSpace(html.UnescapeString(parseXMLTagAttributes(attrs)["name"]))
+		if name == "" {
+			return invokes
+		}
+		closeStart, closeEnd, closed := findMatchingXMLEndTagOutsideCDATA(normalized, "invoke", bodyStart)
+		body := normalized[bodyStart:]
+		if closed {
+			body = normalized[bodyStart:closeStart]
+		}
+		invokes = append(invokes, StreamingInvoke{Name: name, Params: streamingInvokeParams(body)})
+		if !closed {
+			return invokes
+		}
+		from = closeEnd
+	}
+}
+
+// streamingInvokeParams decodes the parameters of a possibly unfinished
+// invoke body, stopping at the first one still open so text inside it is
+// never read as a sibling parameter.
+func streamingInvokeParams(body string) []StreamingParam {
+	var params []StreamingParam
+	input := map[string]any{}
+	for pos := 0; pos < len(body); {
+		_, valueStart, attrs, ok := findXMLStartTagOutsideCDATA(body, "parameter", pos)
+		if !ok {
+			break
+		}
+		closeStart, closeEnd, ok := findMatchingXMLEndTagOutsideCDATA(body, "parameter", valueStart)
+		if !ok {
+			break
+		}
+		pos = closeEnd
+		paramName := strings.TrimSpace(html.UnescapeString(parseXMLTagAttributes(attrs)["name"]))
+		if paramName == "" {
+			continue
+		}
+		appendMarkupValue(input, paramName, parseInvokeParameterValue(paramName, body[valueStart:closeStart]))
+		params = append(params, StreamingParam{Name: paramName, Value: input[paramName]})
+	}
+	return params
+}
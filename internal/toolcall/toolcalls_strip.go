@@ -0,0 +1,116 @@
+package toolcall
+
+import (
+	"strings"
+)
+
+// StripToolMarkup removes leftover tool-call markup from assistant text shown
+// to the user of a request that declared tools. What counts as leftover is a
+// call attempt, not a mention:
+//
+//   - a complete <tool_calls> block, and a bare <invoke> block closed by
+//     </tool_calls> (the opening wrapper was omitted), are removed whole, so a
+//     call that was parsed does not show up as content too;
+//   - a call cut off before it closes (an unclosed <invoke name="..."> or a
+//     dangling <tool_calls> with nothing after it) is removed to the end;
+//   - an orphan </tool_calls> is removed.
+//
+// A wrapper tag mentioned in prose, a complete bare <invoke> example and every
+// tag inside Markdown code or CDATA are kept as they are.
+func StripToolMarkup(text string) string {
+	if text == "" {
+		return text
+	}
+	var b strings.Builder
+	changed := false
+	pos := 0
+	for pos < len(text) {
+		tag, ok := FindToolMarkupTagOutsideIgnored(text, pos)
+		if !ok {
+			break
+		}
+		end, drop := leftoverToolMarkupEnd(text, tag)
+		if !drop {
+			b.WriteString(text[pos : tag.End+1])
+			pos = tag.End + 1
+			continue
+		}
+		b.WriteString(text[pos:tag.Start])
+		changed = true
+		pos = end
+	}
+	if !changed {
+		return text
+	}
+	b.WriteString(text[pos:])
+	return b.String()
+}
+
+// leftoverToolMarkupEnd reports whether the markup starting at tag is a call
+// attempt and, if so, the offset just past it.
+func leftoverToolMarkupEnd(text string, tag ToolMarkupTag) (int, bool) {
+	switch {
+	case tag.Name == "tool_calls" && tag.Closing:
+		return tag.End + 1, true
+	case tag.Closing || tag.SelfClosing:
+		return 0, false
+	case tag.Name == "tool_calls":
+		if closeTag, ok := FindMatchingToolMarkupClose(text, tag); ok {
+			return closeTag.End + 1, true
+		}
+		if strings.TrimSpace(text[tag.End+1:]) == "" {
+			return len(text), true
+		}
+		if next, ok := FindToolMarkupTagOutsideIgnored(text, tag.End+1); ok && next.Name == "invoke" && !next.Closing && strings.TrimSpace(text[tag.End+1:next.Start]) == "" {
+			// The wrapper opens a call that was cut off; the invoke is
+			// judged on its own next.
+			return next.Start, true
+		}
+		return 0, false
+	case tag.Name == "invoke":
+		closeTag, ok := FindMatchingToolMarkupClose(text, tag)
+		if !ok {
+			if strings.Contains(text[tag.Start:tag.End+1], "name") && !hasLaterToolBlockOpening(text, tag.End+1) {
+				return len(text), true
+			}
+			return 0, false
+		}
+		if wrapperClose, ok := nextToolMarkupTag(text, closeTag.End+1); ok && wrapperClose.Name == "tool_calls" && wrapperClose.Closing {
+			return wrapperClose.End + 1, true
+		}
+		return 0, false
+	}
+	return 0, false
+}
+
+func nextToolMarkupTag(text string, from int) (ToolMarkupTag, bool) {
+	for pos := from; pos < len(text); {
+		tag, ok := FindToolMarkupTagOutsideIgnored(text, pos)
+		if !ok {
+			return ToolMarkupTag{}, false
+		}
+		if tag.Name == "invoke" && !tag.Closing {
+			// Another call follows; the wrapper close belongs to the last one.
+			if closeTag, ok := FindMatchingToolMarkupClose(text, tag); ok {
+				pos = closeTag.End + 1
+				continue
+			}
+		}
+		return tag, true
+	}
+	return ToolMarkupTag{}, false
+}
+
+func hasLaterToolBlockOpening(text string, from int) bool {
+	for pos := from; pos < len(text); {
+		tag, ok := FindToolMarkupTagOutsideIgnored(text, pos)
+		if !ok {
+			return false
+		}
+		if !tag.Closing && (tag.Name == "tool_calls" || tag.Name == "invoke") {
+			return true
+		}
+		pos = tag.End + 1
+	}
+	return false
+}
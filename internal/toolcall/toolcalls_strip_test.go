@@ -0,0 +1,22 @@
+package toolcall
+
+import "testing"
+
+func TestStripToolMarkupRemovesCallAttempts(t *testing.T) {
+	call := "<|DSML|invoke name=\"Bash\">\n<|DSML|parameter name=\"command\"><![CDATA[ls]]></|DSML|parameter>\n</|DSML|invoke>\n"
+	for name, tc := range map[string]struct{ in, want string }{
+		"complete wrapper":      {"before <|DSML|tool_calls>\n" + call + "</|DSML|tool_calls> after", "before  after"},
+		"missing open wrapper":  {"before\n" + call + "</|DSML|tool_calls>\nafter", "before\n\nafter"},
+		"two bare invokes":      {"x " + call + call + "</|DSML|tool_calls>", "x "},
+		"cut off in CDATA":      {"before <|DSML|tool_calls>\n<|DSML|invoke name=\"Bash\">\n<|DSML|parameter name=\"command\"><![CDATA[ls", "before "},
+		"dangling wrapper":      {"answer\n<tool_calls>\n", "answer\n"},
+		"orphan closing":        {"answer</|DSML|tool_calls>", "answer"},
+		"prose mention":         {"Use <|DSML|tool_calls> to call tools.", "Use <|DSML|tool_calls> to call tools."},
+		"inline code":           {"see `<|DSML|tool_calls>` and `</|DSML|tool_calls>`", "see `<|DSML|tool_calls>` and `</|DSML|tool_calls>`"},
+		"complete bare example": {"Example: <invoke name=\"read_file\"><parameter name=\"path\">README.md</parameter></invoke> then continue.", "Example: <invoke name=\"read_file\"><parameter name=\"path\">README.md</parameter></invoke> then continue."},
+	} {
+		if got := StripToolMarkup(tc.in); got != tc.want {
+			t.Fatalf("%s: got %q want %q", name, got, tc.want)
+		}
+	}
+}
@@ -16,6 +16,23 @@ func TestFormatOpenAIToolCalls(t *testing.T) {
 	}
 }
 
+func TestParseAndFormatParallelToolCallsAssignsDistinctIDs(t *testing.T) {
+	text := `<|DSML|tool_calls><|DSML|invoke name="search"><|DSML|parameter name="q"><![CDATA[go]]></|DSML|parameter></|DSML|invoke><|DSML|invoke name="read_file"><|DSML|parameter name="path"><![CDATA[a.go]]></|DSML|parameter></|DSML|invoke></|DSML|tool_calls>`
+	calls := ParseToolCalls(text, []string{"search", "read_file"})
+	if len(calls) != 2 || calls[0].Name != "search" || calls[1].Name != "read_file" {
+		t.Fatalf("expected two calls in model order, got %#v", calls)
+	}
+	formatted := FormatOpenAIToolCalls(calls, nil)
+	if len(formatted) != 2 {
+		t.Fatalf("expected 2 formatted calls, got %#v", formatted)
+	}
+	first, _ := formatted[0]["id"].(string)
+	second, _ := formatted[1]["id"].(string)
+	if !strings.HasPrefix(first, "call_") || !strings.HasPrefix(second, "call_") || first == second {
+		t.Fatalf("expected distinct call_ ids, got %q and %q", first, second)
+	}
+}
+
 func TestParseToolCallsSupportsToolCallsWrapper(t *testing.T) {
 	text := `<tool_calls><invoke name="Bash"><parameter name="command">pwd</parameter><parameter name="description">show cwd</parameter></invoke></tool_calls>`
 	calls := ParseToolCalls(text, []string{"bash"})
@@ -816,6 +833,7 @@ func TestRepairLooseJSON(t *testing.T) {
 	}{
 		{`{tool_calls: [{"name": "search", "input": {"q": "go"}}]}`, `{"tool_calls": [{"name": "search", "input": {"q": "go"}}]}`},
 		{`{name: "search", input: {q: "go"}}`, `{"name": "search", "input": {"q": "go"}}`},
+		{`{查询: "天气", ville: "Zürich"}`, `{"查询": "天气", "ville": "Zürich"}`},
 	}
 
 	for _, tt := range tests {
@@ -342,11 +342,12 @@ func TestSieve_FullwidthPipeWrapperDSMLInvoke(t *testing.T) {
 	}
 }
 
-// ---- 未闭合工具块应回退为文本 ----
+// ---- 未闭合工具块只丢弃截断的调用，保留正文 ----
 
-func TestSieve_UnclosedToolCallBlockFallsBack(t *testing.T) {
+func TestSieve_UnclosedToolCallBlockKeepsProse(t *testing.T) {
 	var state State
 	chunks := []string{
+		"先看一下文件。\n",
 		"<tool_calls>\n",
 		`<invoke name="read_file">` + "\n",
 		`<parameter name="path">README.md</parameter>` + "\n",
@@ -364,9 +365,9 @@ func TestSieve_UnclosedToolCallBlockFallsBack(t *testing.T) {
 		text.WriteString(e.Content)
 		tc += len(e.ToolCalls)
 	}
-	// 未闭合的应回退为文本，不应丢失
-	if text.String() == "" {
-		t.Fatalf("未闭合工具块不应丢失所有内容")
+	// 正文保留，截断的工具标记不应泄漏
+	if text.String() != "先看一下文件。\n" {
+		t.Fatalf("未闭合工具块应只保留正文, got %q", text.String())
 	}
 	if tc != 0 {
 		t.Fatalf("未闭合工具块不应解析出工具调用，got %d", tc)
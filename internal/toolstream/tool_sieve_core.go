@@ -24,6 +24,9 @@ func ProcessChunk(state *State, chunk string, toolNames []string) []Event {
 			}
 			prefix, calls, suffix, ready := consumeToolCapture(state, toolNames)
 			if !ready {
+				if deltas := collectInvokeDeltas(state); len(deltas) > 0 {
+					events = append(events, Event{ToolCallDeltas: deltas})
+				}
 				break
 			}
 			captured := state.capture.String()
@@ -31,10 +34,7 @@ func ProcessChunk(state *State, chunk string, toolNames []string) []Event {
 			state.capturing = false
 			state.resetIncrementalToolState()
 			if len(calls) > 0 {
-				if prefix != "" {
-					state.noteText(prefix)
-					events = append(events, Event{Content: prefix})
-				}
+				events = state.releaseCapturedText(events, prefix)
 				if suffix != "" {
 					state.pending.WriteString(suffix)
 				}
@@ -42,10 +42,7 @@ func ProcessChunk(state *State, chunk string, toolNames []string) []Event {
 				state.pendingToolCalls = calls
 				continue
 			}
-			if prefix != "" {
-				state.noteText(prefix)
-				events = append(events, Event{Content: prefix})
-			}
+			events = state.releaseCapturedText(events, prefix)
 			if suffix != "" {
 				state.pending.WriteString(suffix)
 			}
@@ -110,43 +107,29 @@ func Flush(state *State, toolNames []string) []Event {
 	if state.capturing {
 		consumedPrefix, consumedCalls, consumedSuffix, ready := consumeToolCapture(state, toolNames)
 		if ready {
-			if consumedPrefix != "" {
-				state.noteText(consumedPrefix)
-				events = append(events, Event{Content: consumedPrefix})
-			}
+			events = state.releaseCapturedText(events, consumedPrefix)
 			if len(consumedCalls) > 0 {
 				events = append(events, Event{ToolCalls: consumedCalls})
 			}
-			if consumedSuffix != "" {
-				state.noteText(consumedSuffix)
-				events = append(events, Event{Content: consumedSuffix})
-			}
+			events = state.releaseCapturedText(events, consumedSuffix)
 		} else {
 			content := state.capture.String()
 			if content != "" {
 				recovered := toolcall.SanitizeLooseCDATA(content)
 				if recovered != content {
-					if prefix, calls, suffix, recoveredReady := consumeXMLToolCapture(recovered, toolNames); recoveredReady && len(calls) > 0 {
-						if prefix != "" {
-							state.noteText(prefix)
-							events = append(events, Event{Content: prefix})
-						}
+					if prefix, calls, suffix, recoveredReady := consumeXMLToolCapture(recovered, toolNames, state.CallFormat()); recoveredReady && len(calls) > 0 {
+						events = state.releaseCapturedText(events, prefix)
 						events = append(events, Event{ToolCalls: calls})
-						if suffix != "" {
-							state.noteText(suffix)
-							events = append(events, Event{Content: suffix})
-						}
+						events = state.releaseCapturedText(events, suffix)
 					} else {
 						// If capture never resolved into a real tool call, release
-						// the buffered text instead of swallowing it.
-						state.noteText(content)
-						events = append(events, Event{Content: content})
+						// the buffered prose without its tool markup.
+						events = state.releaseCapturedText(events, content)
 					}
 				} else {
 					// If capture never resolved into a real tool call, release the
-					// buffered text instead of swallowing it.
-					state.noteText(content)
-					events = append(events, Event{Content: content})
+					// buffered prose without its tool markup.
+					events = state.releaseCapturedText(events, content)
 				}
 			}
 		}
@@ -164,11 +147,30 @@ func Flush(state *State, toolNames []string) []Event {
 	return events
 }
 
+// releaseCapturedText emits text that came out of a tool capture without
+// becoming a call. Captures start at a tool tag, so whatever markup is left
+// (a malformed or cut-off block, an orphan closing tag) is stripped first and
+// never reaches the client.
+func (s *State) releaseCapturedText(events []Event, text string) []Event {
+	text = toolcall.StripToolMarkup(text)
+	if text == "" {
+		return events
+	}
+	s.noteText(text)
+	return append(events, Event{Content: text})
+}
+
 func splitSafeContentForToolDetection(state *State, s string) (safe, hold string) {
 	if s == "" {
 		return "", ""
 	}
-	if xmlIdx := findPartialXMLToolTagStart(s); xmlIdx >= 0 {
+	xmlIdx := findPartialXMLToolTagStart(s)
+	if state.CallFormat() == toolcall.CallFormatJSON {
+		if jsonIdx := toolcall.PartialJSONToolCallsStart(s); jsonIdx >= 0 && (xmlIdx < 0 || jsonIdx < xmlIdx) {
+			xmlIdx = jsonIdx
+		}
+	}
+	if xmlIdx >= 0 {
 		if insideCodeFenceWithState(state, s[:xmlIdx]) {
 			return s, ""
 		}
@@ -192,6 +194,18 @@ func splitSafeContentForToolDetection(state *State, s string) (safe, hold string
 const holdToolSegmentStart = -2
 
 func findToolSegmentStart(state *State, s string) int {
+	start := findMarkupToolSegmentStart(state, s)
+	if start == holdToolSegmentStart || state.CallFormat() != toolcall.CallFormatJSON {
+		return start
+	}
+	jsonStart := findJSONToolSegmentStart(state, s)
+	if jsonStart == holdToolSegmentStart || (jsonStart >= 0 && (start < 0 || jsonStart < start)) {
+		return jsonStart
+	}
+	return start
+}
+
+func findMarkupToolSegmentStart(state *State, s string) int {
 	if s == "" {
 		return -1
 	}
@@ -295,8 +309,10 @@ func consumeToolCapture(state *State, toolNames []string) (prefix string, calls
 		return "", nil, "", false
 	}
 
-	// XML tool call extraction only.
-	if xmlPrefix, xmlCalls, xmlSuffix, xmlReady := consumeXMLToolCapture(captured, toolNames); xmlReady {
+	if state.CallFormat() == toolcall.CallFormatJSON && captured[0] == '{' {
+		return consumeJSONToolCapture(captured)
+	}
+	if xmlPrefix, xmlCalls, xmlSuffix, xmlReady := consumeXMLToolCapture(captured, toolNames, state.CallFormat()); xmlReady {
 		return xmlPrefix, xmlCalls, xmlSuffix, true
 	}
 	// If XML tags are present but block is incomplete, keep buffering.
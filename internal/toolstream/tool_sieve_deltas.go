@@ -0,0 +1,58 @@
+package toolstream
+
+import (
+	"encoding/json"
+	"strings"
+
+	"ds2api/internal/toolcall"
+)
+
+// collectInvokeDeltas announces each invoke name once its opening tag is
+// complete and then streams its arguments one closed parameter at a time, so
+// clients can render the pending call before the whole block closes. The
+// fragments form an unterminated JSON object (`{"a":1` then `,"b":2`); the
+// closing tool_calls frame supplies the rest.
+func collectInvokeDeltas(state *State) []ToolCallDelta {
+	if state.disableDeltas {
+		return nil
+	}
+	invokes := toolcall.StreamingInvokes(state.capture.String())
+	var deltas []ToolCallDelta
+	for i, inv := range invokes {
+		delta := ToolCallDelta{Index: i}
+		if i >= state.invokeNamesSent {
+			delta.Name = inv.Name
+			state.invokeNamesSent = i + 1
+		}
+		for len(state.invokeParamsSent) <= i {
+			state.invokeParamsSent = append(state.invokeParamsSent, 0)
+		}
+		delta.Arguments = streamedParamsFragment(inv.Params[state.invokeParamsSent[i]:], state.invokeParamsSent[i] == 0)
+		state.invokeParamsSent[i] = len(inv.Params)
+		if delta.Name != "" || delta.Arguments != "" {
+			deltas = append(deltas, delta)
+		}
+	}
+	return deltas
+}
+
+func streamedParamsFragment(params []toolcall.StreamingParam, first bool) string {
+	var b strings.Builder
+	for _, p := range params {
+		key, _ := json.Marshal(p.Name)
+		value, err := json.Marshal(p.Value)
+		if err != nil {
+			value = []byte(`null`)
+		}
+		if first {
+			b.WriteByte('{')
+			first = false
+		} else {
+			b.WriteByte(',')
+		}
+		b.Write(key)
+		b.WriteByte(':')
+		b.Write(value)
+	}
+	return b.String()
+}
@@ -0,0 +1,97 @@
+package toolstream
+
+import (
+	"strings"
+	"testing"
+
+	"ds2api/internal/toolcall"
+)
+
+func TestProcessToolSieveInterceptsJSONFormatToolCall(t *testing.T) {
+	toolcall.ConfigurePrompt(toolcall.CallFormatJSON, nil)
+	t.Cleanup(func() { toolcall.ConfigurePrompt("", nil) })
+
+	var state State
+	chunks := []string{
+		"Checking the file. {",
+		`"tool_calls": [{"name": "read_file", `,
+		`"arguments": {"path": "README.md"}}]`,
+		"}",
+	}
+	var events []Event
+	for _, c := range chunks {
+		events = append(events, ProcessChunk(&state, c, []string{"read_file"})...)
+	}
+	events = append(events, Flush(&state, []string{"read_file"})...)
+
+	var text strings.Builder
+	var calls []toolcall.ParsedToolCall
+	for _, evt := range events {
+		text.WriteString(evt.Content)
+		calls = append(calls, evt.ToolCalls...)
+	}
+	if got := text.String(); got != "Checking the file. " {
+		t.Fatalf("unexpected visible text %q", got)
+	}
+	if len(calls) != 1 || calls[0].Name != "read_file" || calls[0].Input["path"] != "README.md" {
+		t.Fatalf("unexpected calls %#v", calls)
+	}
+}
+
+func TestProcessToolSieveReleasesJSONThatIsNotAToolCall(t *testing.T) {
+	toolcall.ConfigurePrompt(toolcall.CallFormatJSON, nil)
+	t.Cleanup(func() { toolcall.ConfigurePrompt("", nil) })
+
+	var state State
+	input := `Result: {"tool_calls": "none"} done {"a": 1}`
+	events := ProcessChunk(&state, input, []string{"read_file"})
+	events = append(events, Flush(&state, []string{"read_file"})...)
+	var text strings.Builder
+	for _, evt := range events {
+		text.WriteString(evt.Content)
+		if len(evt.ToolCalls) > 0 {
+			t.Fatalf("unexpected tool calls %#v", evt.ToolCalls)
+		}
+	}
+	if text.String() != input {
+		t.Fatalf("expected the text to pass through unchanged, got %q", text.String())
+	}
+}
+
+func TestProcessToolSieveKeepsCallFormatAcrossPromptReload(t *testing.T) {
+	t.Cleanup(func() { toolcall.ConfigurePrompt("", nil) })
+
+	var pinned, captured State
+	captured.SetCallFormat(toolcall.CallFormatJSON)
+	toolcall.ConfigurePrompt(toolcall.CallFormatJSON, nil)
+	collect := func(state *State, chunk string) []toolcall.ParsedToolCall {
+		var calls []toolcall.ParsedToolCall
+		for _, evt := range ProcessChunk(state, chunk, []string{"read_file"}) {
+			calls = append(calls, evt.ToolCalls...)
+		}
+		return calls
+	}
+	first := `Checking. {"tool_calls": [{"name": "read_file", `
+	if calls := collect(&pinned, first); len(calls) != 0 {
+		t.Fatalf("unexpected early calls %#v", calls)
+	}
+	collect(&captured, first)
+
+	// A reload between chunks must not switch either stream to the DSML
+	// parser: one captured its format up front, the other pinned it on its
+	// first chunk.
+	toolcall.ConfigurePrompt(toolcall.CallFormatDSML, nil)
+	rest := `"arguments": {"path": "README.md"}}]}`
+	for name, state := range map[string]*State{"pinned": &pinned, "captured": &captured} {
+		calls := collect(state, rest)
+		for _, evt := range Flush(state, []string{"read_file"}) {
+			calls = append(calls, evt.ToolCalls...)
+		}
+		if state.CallFormat() != toolcall.CallFormatJSON {
+			t.Fatalf("%s: expected json call format, got %q", name, state.CallFormat())
+		}
+		if len(calls) != 1 || calls[0].Input["path"] != "README.md" {
+			t.Fatalf("%s: expected the JSON call to survive the reload, got %#v", name, calls)
+		}
+	}
+}
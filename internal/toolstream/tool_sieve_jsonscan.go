@@ -1,6 +1,10 @@
 package toolstream
 
-import "strings"
+import (
+	"strings"
+
+	"ds2api/internal/toolcall"
+)
 
 func trimWrappingJSONFence(prefix, suffix string) (string, string) {
 	trimmedPrefix := strings.TrimRight(prefix, " \t\r\n")
@@ -25,3 +29,49 @@ func trimWrappingJSONFence(prefix, suffix string) (string, string) {
 	consumedLeading := len(suffix) - len(trimmedSuffix)
 	return trimmedPrefix[:fenceIdx], suffix[consumedLeading+3:]
 }
+
+// findJSONToolSegmentStart locates a `{"tool_calls":` opener for the json
+// call format, skipping code fences and inline code like the markup scan.
+func findJSONToolSegmentStart(state *State, s string) int {
+	for offset := 0; ; {
+		start := toolcall.FindJSONToolCallsStart(s, offset)
+		if start < 0 {
+			return -1
+		}
+		if insideCodeFenceWithState(state, s[:start]) {
+			offset = start + 1
+			continue
+		}
+		markdown := markdownCodeSpanStateAt(state, s[:start])
+		if markdown.ticks == 0 {
+			return start
+		}
+		if markdownCodeSpanCloses(s[start:], markdown.ticks) {
+			offset = start + 1
+			continue
+		}
+		if markdown.fromPrior {
+			return holdToolSegmentStart
+		}
+		return start
+	}
+}
+
+// consumeJSONToolCapture waits for the captured JSON object to close, then
+// returns its calls and whatever followed it. An object that is not a valid
+// tool call is released as text.
+func consumeJSONToolCapture(captured string) (prefix string, calls []toolcall.ParsedToolCall, suffix string, ready bool) {
+	parsed, end, complete := toolcall.DecodeJSONToolCalls(captured)
+	if !complete {
+		return "", nil, "", false
+	}
+	for _, call := range parsed {
+		if call.Name != "" {
+			calls = append(calls, call)
+		}
+	}
+	if len(calls) == 0 {
+		return captured, nil, "", true
+	}
+	return "", calls, captured[end:], true
+}
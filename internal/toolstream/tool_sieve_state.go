@@ -6,6 +6,7 @@ import (
 )
 
 type State struct {
+	callFormat             string
 	pending                strings.Builder
 	capture                strings.Builder
 	capturing              bool
@@ -23,6 +24,8 @@ type State struct {
 	toolArgsSent           int
 	toolArgsString         bool
 	toolArgsDone           bool
+	invokeNamesSent        int
+	invokeParamsSent       []int
 }
 
 type Event struct {
@@ -37,6 +40,30 @@ type ToolCallDelta struct {
 	Arguments string
 }
 
+// SetCallFormat fixes the tool call format the stream parses, normally the
+// one the request captured when it started (StandardRequest.ToolPrompt). An
+// empty format leaves it to CallFormat to pin the active one.
+func (s *State) SetCallFormat(format string) {
+	if strings.TrimSpace(format) == "" {
+		s.callFormat = ""
+		return
+	}
+	s.callFormat = toolcall.NormalizeCallFormat(format)
+}
+
+// CallFormat returns the tool call format the stream parses. When none was
+// set the active format is pinned on first use, so one stream never switches
+// parsers midway.
+func (s *State) CallFormat() string {
+	if s == nil {
+		return toolcall.ActiveCallFormat()
+	}
+	if s.callFormat == "" {
+		s.callFormat = toolcall.ActiveCallFormat()
+	}
+	return s.callFormat
+}
+
 func (s *State) resetIncrementalToolState() {
 	s.disableDeltas = false
 	s.toolNameSent = false
@@ -45,6 +72,8 @@ func (s *State) resetIncrementalToolState() {
 	s.toolArgsSent = -1
 	s.toolArgsString = false
 	s.toolArgsDone = false
+	s.invokeNamesSent = 0
+	s.invokeParamsSent = nil
 }
 
 func (s *State) noteText(content string) {
@@ -6,7 +6,7 @@ import (
 )
 
 // consumeXMLToolCapture tries to extract complete XML tool call blocks from captured text.
-func consumeXMLToolCapture(captured string, toolNames []string) (prefix string, calls []toolcall.ParsedToolCall, suffix string, ready bool) {
+func consumeXMLToolCapture(captured string, toolNames []string, format string) (prefix string, calls []toolcall.ParsedToolCall, suffix string, ready bool) {
 	anyOpenFound := false
 	type candidate struct {
 		start  int
@@ -44,7 +44,7 @@ func consumeXMLToolCapture(captured string, toolNames []string) (prefix string,
 		xmlBlock := captured[tag.Start : closeTag.End+1]
 		prefixPart := captured[:tag.Start]
 		suffixPart := captured[closeTag.End+1:]
-		parsed := toolcall.ParseStandaloneToolCallsDetailed(xmlBlock, toolNames)
+		parsed := toolcall.ParseStandaloneToolCallsDetailedForFormat(xmlBlock, toolNames, format)
 		if len(parsed.Calls) > 0 {
 			prefixPart, suffixPart = trimWrappingJSONFence(prefixPart, suffixPart)
 			if best == nil || tag.Start < best.start {
@@ -82,7 +82,7 @@ func consumeXMLToolCapture(captured string, toolNames []string) (prefix string,
 				xmlBlock := "<tool_calls>" + captured[invokeTag.Start:closeTag.End+1]
 				prefixPart := captured[:invokeTag.Start]
 				suffixPart := captured[closeTag.End+1:]
-				parsed := toolcall.ParseStandaloneToolCallsDetailed(xmlBlock, toolNames)
+				parsed := toolcall.ParseStandaloneToolCallsDetailedForFormat(xmlBlock, toolNames, format)
 				if len(parsed.Calls) > 0 {
 					prefixPart, suffixPart = trimWrappingJSONFence(prefixPart, suffixPart)
 					return prefixPart, parsed.Calls, suffixPart, true
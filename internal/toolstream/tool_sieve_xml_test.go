@@ -2,6 +2,7 @@ package toolstream
 
 import (
 	"ds2api/internal/toolcall"
+	"encoding/json"
 	"strings"
 	"testing"
 )
@@ -584,7 +585,7 @@ func TestProcessToolSieveNonToolXMLKeepsSuffixForToolParsing(t *testing.T) {
 	}
 }
 
-func TestProcessToolSieveReleasesMalformedExecutableXMLBlock(t *testing.T) {
+func TestProcessToolSieveDropsMalformedExecutableXMLBlock(t *testing.T) {
 	var state State
 	chunk := `<tool_calls><invoke name="read_file"><param>{"path":"README.md"}</param></invoke></tool_calls>`
 	events := ProcessChunk(&state, chunk, []string{"read_file"})
@@ -600,8 +601,8 @@ func TestProcessToolSieveReleasesMalformedExecutableXMLBlock(t *testing.T) {
 	if toolCalls != 0 {
 		t.Fatalf("expected malformed executable-looking XML not to become a tool call, got %d events=%#v", toolCalls, events)
 	}
-	if textContent.String() != chunk {
-		t.Fatalf("expected malformed executable-looking XML to be released as text, got %q", textContent.String())
+	if textContent.String() != "" {
+		t.Fatalf("expected malformed executable-looking XML not to leak into text, got %q", textContent.String())
 	}
 }
 
@@ -897,11 +898,12 @@ func TestProcessToolSieveTokenByTokenXMLNoLeak(t *testing.T) {
 	}
 }
 
-// Test that Flush on incomplete XML falls back to raw text.
-func TestFlushToolSieveIncompleteXMLFallsBackToText(t *testing.T) {
+// Test that Flush on incomplete XML drops the cut-off call but keeps the prose.
+func TestFlushToolSieveIncompleteXMLDropsCutOffCall(t *testing.T) {
 	var state State
 	// XML block starts but stream ends before completion.
 	chunks := []string{
+		"Reading it now.\n",
 		"<tool_calls>\n",
 		"  <invoke name=\"read_file\">\n",
 	}
@@ -919,8 +921,8 @@ func TestFlushToolSieveIncompleteXMLFallsBackToText(t *testing.T) {
 		}
 	}
 
-	if textContent != strings.Join(chunks, "") {
-		t.Fatalf("expected incomplete XML to fall back to raw text, got %q", textContent)
+	if textContent != "Reading it now.\n" {
+		t.Fatalf("expected the cut-off call to be dropped, got %q", textContent)
 	}
 }
 
@@ -1498,3 +1500,116 @@ func TestProcessToolSieveKeepsConfusableNearMissWrapperAsText(t *testing.T) {
 		t.Fatalf("expected confusable near-miss wrapper to pass through unchanged, got %q", got)
 	}
 }
+
+func TestProcessToolSieveAnnouncesInvokeNameBeforeArgumentsClose(t *testing.T) {
+	var state State
+	toolNames := []string{"read_file", "search"}
+	chunks := []string{
+		"<|DSML|tool_calls>\n",
+		`  <|DSML|invoke name="read_file">` + "\n",
+		`    <|DSML|parameter name="path">READ`,
+		"ME.MD</|DSML|parameter>\n  </|DSML|invoke>\n",
+		`  <|DSML|invoke name="search">` + "\n",
+		`    <|DSML|parameter name="q">golang</|DSML|parameter>` + "\n  </|DSML|invoke>\n",
+		"</|DSML|tool_calls>",
+	}
+	var names []string
+	var namesBeforeFinal []string
+	finalCalls := 0
+	for _, c := range chunks {
+		for _, evt := range ProcessChunk(&state, c, toolNames) {
+			for _, d := range evt.ToolCallDeltas {
+				if d.Name != "" {
+					names = append(names, d.Name)
+				}
+			}
+			finalCalls += len(evt.ToolCalls)
+		}
+		if finalCalls == 0 {
+			namesBeforeFinal = append(namesBeforeFinal[:0], names...)
+		}
+	}
+	for _, evt := range Flush(&state, toolNames) {
+		finalCalls += len(evt.ToolCalls)
+	}
+
+	if got := strings.Join(names, ","); got != "read_file,search" {
+		t.Fatalf("expected each invoke name announced once in order, got %q", got)
+	}
+	if got := strings.Join(namesBeforeFinal, ","); got != "read_file,search" {
+		t.Fatalf("expected names announced before the block closed, got %q", got)
+	}
+	if finalCalls != 2 {
+		t.Fatalf("expected two final tool calls, got %d", finalCalls)
+	}
+}
+
+func TestProcessToolSieveStripsLeftoverMarkupSplitAcrossChunks(t *testing.T) {
+	var state State
+	chunks := []string{"Done.</|DS", "ML|tool_", "calls> Next: <|DSML|tool_calls>\n<|DSML|inv", "oke name=\"Bash\">\n<|DSML|parameter name=\"command\"><![CDATA[l"}
+	var events []Event
+	for _, c := range chunks {
+		got := ProcessChunk(&state, c, []string{"Bash"})
+		for _, evt := range got {
+			if strings.Contains(evt.Content, "<") {
+				t.Fatalf("expected no partial tool tag to be emitted, got %q", evt.Content)
+			}
+		}
+		events = append(events, got...)
+	}
+	events = append(events, Flush(&state, []string{"Bash"})...)
+
+	var textContent strings.Builder
+	for _, evt := range events {
+		textContent.WriteString(evt.Content)
+		if len(evt.ToolCalls) > 0 {
+			t.Fatalf("expected the cut-off call not to become a tool call, got %#v", evt.ToolCalls)
+		}
+	}
+	if got := textContent.String(); got != "Done. Next: " {
+		t.Fatalf("expected leftover markup to be stripped, got %q", got)
+	}
+}
+
+func TestProcessToolSieveStreamsArgumentsAsParametersClose(t *testing.T) {
+	var state State
+	toolNames := []string{"read_file"}
+	chunks := []string{
+		"<tool_calls>\n  <invoke name=\"read_file\">\n",
+		`    <parameter name="path">READ`,
+		"ME.MD</parameter>\n",
+		`    <parameter name="limit">10</parameter>` + "\n",
+		"  </invoke>\n</tool_calls>",
+	}
+	var fragments []string
+	var args strings.Builder
+	finalCalls := 0
+	for _, c := range chunks {
+		for _, evt := range ProcessChunk(&state, c, toolNames) {
+			for _, d := range evt.ToolCallDeltas {
+				if d.Index != 0 {
+					t.Fatalf("unexpected delta index %d", d.Index)
+				}
+				if d.Arguments != "" {
+					fragments = append(fragments, d.Arguments)
+					args.WriteString(d.Arguments)
+				}
+			}
+			finalCalls += len(evt.ToolCalls)
+		}
+	}
+	for _, evt := range Flush(&state, toolNames) {
+		finalCalls += len(evt.ToolCalls)
+	}
+
+	if got := strings.Join(fragments, "|"); got != `{"path":"README.MD"|,"limit":10` {
+		t.Fatalf("expected one fragment per closed parameter, got %q", got)
+	}
+	var decoded map[string]any
+	if err := json.Unmarshal([]byte(args.String()+"}"), &decoded); err != nil {
+		t.Fatalf("expected fragments to form an open JSON object, got %q: %v", args.String(), err)
+	}
+	if finalCalls != 1 {
+		t.Fatalf("expected one final tool call, got %d", finalCalls)
+	}
+}
@@ -0,0 +1,207 @@
+// Package upstreamhook lets a deployment adjust the DeepSeek completion call
+// without patching the request pipeline: hooks registered at startup see the
+// final prompt, the other payload fields and the request headers before the
+// call is sent, and the response before it is consumed.
+//
+// Hooks are registered from an init function in a file added to the binary's
+// main package, for example cmd/ds2api/hooks.go:
+//
+//	func init() {
+//		upstreamhook.Register(preambleHook{})
+//	}
+//
+// With nothing registered the call is sent exactly as built.
+package upstreamhook
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+)
+
+// Request is the completion call as a BeforeUpstream hook sees it. Prompt is
+// the final prompt built for the surface (the output of the prompt builder,
+// tool instructions included); Params holds every other payload field, such
+// as model_type, thinking_enabled and ref_file_ids; Header holds the request
+// headers, authorization and PoW answer included. Hooks may change all three
+// in place.
+type Request struct {
+	Prompt string
+	Params map[string]any
+	Header http.Header
+}
+
+// Hook is one upstream middleware. BeforeUpstream runs before every
+// completion call, retries and failover attempts included; AfterUpstream runs
+// on the response before its body is read. A hook that returns an error
+// stops the call: later hooks do not run and the client receives an error
+// response instead of a completion. Return an *Error to choose the status
+// and code; any other error becomes a 502 with code "upstream_hook_error".
+type Hook interface {
+	BeforeUpstream(ctx context.Context, req *Request) error
+	AfterUpstream(ctx context.Context, resp *http.Response) error
+}
+
+// Nop implements Hook without changing anything. Embed it to implement only
+// one side.
+type Nop struct{}
+
+func (Nop) BeforeUpstream(context.Context, *Request) error      { return nil }
+func (Nop) AfterUpstream(context.Context, *http.Response) error { return nil }
+
+// Error is a hook's deliberate refusal of a call, reported to the client as
+// an OpenAI-style error with the given status, code and message.
+type Error struct {
+	Status  int
+	Code    string
+	Message string
+}
+
+func (e *Error) Error() string {
+	if e == nil {
+		return ""
+	}
+	return e.Message
+}
+
+// CodeHookError is the error code clients see when a hook fails with an
+// error that is not an *Error.
+const CodeHookError = "upstream_hook_error"
+
+// AsError maps a hook failure to the status, code and message sent to the
+// client. Errors that are not an *Error are reported as 502
+// upstream_hook_error.
+func AsError(err error) *Error {
+	var hookErr *Error
+	if errors.As(err, &hookErr) && hookErr != nil {
+		out := *hookErr
+		if out.Status < 400 || out.Status > 599 {
+			out.Status = http.StatusBadGateway
+		}
+		if out.Code == "" {
+			out.Code = CodeHookError
+		}
+		if out.Message == "" {
+			out.Message = http.StatusText(out.Status)
+		}
+		return &out
+	}
+	return &Error{Status: http.StatusBadGateway, Code: CodeHookError, Message: "upstream hook failed: " + err.Error()}
+}
+
+// failure wraps errors returned by hooks so callers can tell them apart from
+// transport errors with IsHookError.
+type failure struct {
+	err error
+}
+
+func (f *failure) Error() string { return f.err.Error() }
+func (f *failure) Unwrap() error { return f.err }
+
+// IsHookError reports whether err came from a registered hook. Such errors
+// are final: retrying the call or switching accounts cannot change them.
+func IsHookError(err error) bool {
+	var f *failure
+	return errors.As(err, &f)
+}
+
+var (
+	mu    sync.RWMutex
+	hooks []*registration
+)
+
+type registration struct {
+	hook Hook
+}
+
+// Register adds h after the hooks already registered; hooks run in
+// registration order. The returned function removes h again, which tests
+// use to restore the default.
+func Register(h Hook) (unregister func()) {
+	if h == nil {
+		return func() {}
+	}
+	reg := &registration{hook: h}
+	mu.Lock()
+	hooks = append(hooks, reg)
+	mu.Unlock()
+	return func() {
+		mu.Lock()
+		defer mu.Unlock()
+		for i, item := range hooks {
+			if item == reg {
+				hooks = append(hooks[:i:i], hooks[i+1:]...)
+				return
+			}
+		}
+	}
+}
+
+// Active reports whether any hook is registered.
+func Active() bool {
+	mu.RLock()
+	defer mu.RUnlock()
+	return len(hooks) > 0
+}
+
+func registered() []Hook {
+	mu.RLock()
+	defer mu.RUnlock()
+	out := make([]Hook, 0, len(hooks))
+	for _, reg := range hooks {
+		out = append(out, reg.hook)
+	}
+	return out
+}
+
+// RunBefore passes req through every BeforeUpstream hook in order, stopping
+// at the first error.
+func RunBefore(ctx context.Context, req *Request) error {
+	for _, h := range registered() {
+		if err := h.BeforeUpstream(ctx, req); err != nil {
+			return &failure{err: err}
+		}
+	}
+	return nil
+}
+
+// RunAfter passes resp through every AfterUpstream hook in order, stopping at
+// the first error. The caller still owns resp and closes it on error.
+func RunAfter(ctx context.Context, resp *http.Response) error {
+	for _, h := range registered() {
+		if err := h.AfterUpstream(ctx, resp); err != nil {
+			return &failure{err: err}
+		}
+	}
+	return nil
+}
+
+// ApplyToPayload runs the BeforeUpstream hooks on a completion payload and
+// returns the payload the hooks produced; payload itself is not modified.
+// header may be nil when the caller only forwards the payload.
+func ApplyToPayload(ctx context.Context, payload map[string]any, header http.Header) (map[string]any, error) {
+	if !Active() {
+		return payload, nil
+	}
+	if header == nil {
+		header = http.Header{}
+	}
+	req := &Request{Params: make(map[string]any, len(payload)), Header: header}
+	for k, v := range payload {
+		if k == "prompt" {
+			req.Prompt, _ = v.(string)
+			continue
+		}
+		req.Params[k] = v
+	}
+	if err := RunBefore(ctx, req); err != nil {
+		return nil, err
+	}
+	out := make(map[string]any, len(req.Params)+1)
+	for k, v := range req.Params {
+		out[k] = v
+	}
+	out["prompt"] = req.Prompt
+	return out, nil
+}
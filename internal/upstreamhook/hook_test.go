@@ -0,0 +1,88 @@
+package upstreamhook
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+)
+
+type recordingHook struct {
+	Nop
+	name  string
+	calls *[]string
+	err   error
+}
+
+func (h recordingHook) BeforeUpstream(_ context.Context, req *Request) error {
+	*h.calls = append(*h.calls, h.name)
+	req.Prompt += "+" + h.name
+	return h.err
+}
+
+func TestRunBeforeRunsHooksInRegistrationOrderAndStopsAtFirstError(t *testing.T) {
+	var calls []string
+	t.Cleanup(Register(recordingHook{name: "a", calls: &calls}))
+	t.Cleanup(Register(recordingHook{name: "b", calls: &calls, err: errors.New("stop")}))
+	t.Cleanup(Register(recordingHook{name: "c", calls: &calls}))
+
+	req := &Request{Prompt: "p"}
+	err := RunBefore(context.Background(), req)
+	if !IsHookError(err) {
+		t.Fatalf("expected a hook error, got %v", err)
+	}
+	if len(calls) != 2 || calls[0] != "a" || calls[1] != "b" {
+		t.Fatalf("unexpected hook order: %v", calls)
+	}
+	if req.Prompt != "p+a+b" {
+		t.Fatalf("unexpected prompt: %q", req.Prompt)
+	}
+}
+
+func TestRegisterUnregisterRestoresDefault(t *testing.T) {
+	var calls []string
+	unregister := Register(recordingHook{name: "a", calls: &calls})
+	if !Active() {
+		t.Fatal("expected a registered hook")
+	}
+	unregister()
+	if Active() {
+		t.Fatal("expected no hooks after unregister")
+	}
+	payload := map[string]any{"prompt": "p"}
+	out, err := ApplyToPayload(context.Background(), payload, nil)
+	if err != nil || out["prompt"] != "p" || len(calls) != 0 {
+		t.Fatalf("expected the payload unchanged, got %#v, %v, %v", out, err, calls)
+	}
+}
+
+func TestApplyToPayloadSplitsPromptFromParams(t *testing.T) {
+	var calls []string
+	t.Cleanup(Register(recordingHook{name: "a", calls: &calls}))
+	payload := map[string]any{"prompt": "p", "thinking_enabled": true}
+	out, err := ApplyToPayload(context.Background(), payload, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if out["prompt"] != "p+a" || out["thinking_enabled"] != true {
+		t.Fatalf("unexpected payload: %#v", out)
+	}
+	if payload["prompt"] != "p" {
+		t.Fatalf("input payload was modified: %#v", payload)
+	}
+}
+
+func TestAsErrorMapsHookErrors(t *testing.T) {
+	got := AsError(&failure{err: &Error{Status: http.StatusForbidden, Code: "policy", Message: "no"}})
+	if got.Status != http.StatusForbidden || got.Code != "policy" || got.Message != "no" {
+		t.Fatalf("unexpected mapping: %#v", got)
+	}
+	got = AsError(&failure{err: &Error{Status: http.StatusOK}})
+	if got.Status != http.StatusBadGateway || got.Code != CodeHookError || got.Message == "" {
+		t.Fatalf("expected an invalid status to fall back to 502, got %#v", got)
+	}
+	got = AsError(&failure{err: errors.New("boom")})
+	if got.Status != http.StatusBadGateway || got.Code != CodeHookError || got.Message != "upstream hook failed: boom" {
+		t.Fatalf("unexpected mapping for a plain error: %#v", got)
+	}
+}
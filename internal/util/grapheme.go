@@ -0,0 +1,78 @@
+package util
+
+import (
+	"unicode"
+	"unicode/utf8"
+)
+
+// ClusterStart reports whether byte offset i of text starts a user-perceived
+// character, so cutting the text there keeps every character whole. Besides
+// UTF-8 code point boundaries it keeps combining marks, variation selectors,
+// emoji skin-tone modifiers and tag sequences on their base character, emoji
+// joined by ZWJ together, regional-indicator flags in pairs and CRLF intact.
+// It is a compact subset of the UAX #29 extended grapheme cluster rules that
+// covers the sequences chat text actually carries.
+func ClusterStart(text string, i int) bool {
+	if i <= 0 || i >= len(text) {
+		return true
+	}
+	if !utf8.RuneStart(text[i]) {
+		return false
+	}
+	prev, _ := utf8.DecodeLastRuneInString(text[:i])
+	next, _ := utf8.DecodeRuneInString(text[i:])
+	switch {
+	case prev == '\r' && next == '\n':
+		return false
+	case prev == '\r' || prev == '\n' || next == '\r' || next == '\n':
+		return true
+	case isClusterExtender(next) || prev == zeroWidthJoiner:
+		return false
+	case isRegionalIndicator(prev) && isRegionalIndicator(next):
+		// Flags pair up from the start of a run, so the boundary falls after
+		// an even number of indicators.
+		run := 0
+		for j := i; j > 0; {
+			r, size := utf8.DecodeLastRuneInString(text[:j])
+			if !isRegionalIndicator(r) {
+				break
+			}
+			run++
+			j -= size
+		}
+		return run%2 == 0
+	}
+	return true
+}
+
+// ClusterFloor returns the largest offset at or below i where ClusterStart
+// holds.
+func ClusterFloor(text string, i int) int {
+	if i >= len(text) {
+		return len(text)
+	}
+	for i > 0 && !ClusterStart(text, i) {
+		i--
+	}
+	return max(i, 0)
+}
+
+const zeroWidthJoiner = '\u200d'
+
+func isClusterExtender(r rune) bool {
+	switch {
+	case r == zeroWidthJoiner:
+		return true
+	case r >= 0xFE00 && r <= 0xFE0F, r >= 0xE0100 && r <= 0xE01EF:
+		return true // variation selectors
+	case r >= 0x1F3FB && r <= 0x1F3FF:
+		return true // emoji skin-tone modifiers
+	case r >= 0xE0020 && r <= 0xE007F:
+		return true // tag characters of subdivision flags
+	}
+	return unicode.Is(unicode.M, r)
+}
+
+func isRegionalIndicator(r rune) bool {
+	return r >= 0x1F1E6 && r <= 0x1F1FF
+}
@@ -0,0 +1,104 @@
+package util
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+const (
+	family     = "\U0001F468\u200d\U0001F469\u200d\U0001F467"
+	thumbsUp   = "\U0001F44D\U0001F3FD"
+	flagsCNJP  = "\U0001F1E8\U0001F1F3\U0001F1EF\U0001F1F5"
+	heart      = "\u2764\ufe0f"
+	decomposed = "e\u0301"
+)
+
+func TestClusterStartKeepsSequencesWhole(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		text string
+	}{
+		{"4-byte emoji", "😀"},
+		{"zwj family", family},
+		{"skin tone", thumbsUp},
+		{"flag", "\U0001F1E8\U0001F1F3"},
+		{"variation selector", heart},
+		{"combining mark", decomposed},
+		{"devanagari virama", "\u0915\u094d"},
+		{"crlf", "\r\n"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			for i := 1; i < len(tc.text); i++ {
+				if ClusterStart(tc.text, i) {
+					t.Fatalf("unexpected cluster start at byte %d of %q", i, tc.text)
+				}
+			}
+		})
+	}
+}
+
+func TestClusterStartSplitsBetweenCharacters(t *testing.T) {
+	text := "a" + family + "中" + flagsCNJP + "x" + decomposed + "😀"
+	var clusters []string
+	last := 0
+	for i := 1; i <= len(text); i++ {
+		if i == len(text) || ClusterStart(text, i) {
+			clusters = append(clusters, text[last:i])
+			last = i
+		}
+	}
+	want := []string{"a", family, "中", "\U0001F1E8\U0001F1F3", "\U0001F1EF\U0001F1F5", "x", decomposed, "😀"}
+	if strings.Join(clusters, "|") != strings.Join(want, "|") {
+		t.Fatalf("unexpected clusters: %q", clusters)
+	}
+}
+
+func TestTruncateUTF8BytesNeverSplitsEmojiAtBoundary(t *testing.T) {
+	text := strings.Repeat("ab", 3) + "😀" + "中文" + family
+	for limit := 0; limit <= len(text); limit++ {
+		got, _ := TruncateUTF8Bytes(text, limit)
+		if len(got) > limit || !utf8.ValidString(got) || !strings.HasPrefix(text, got) {
+			t.Fatalf("limit %d: got %q", limit, got)
+		}
+		if rest := text[len(got):]; rest != "" && !ClusterStart(text, len(got)) {
+			t.Fatalf("limit %d: cut %q inside a character", limit, got)
+		}
+	}
+	if got, _ := TruncateUTF8Bytes("ab😀", 5); got != "ab" {
+		t.Fatalf("expected the emoji to be dropped whole, got %q", got)
+	}
+}
+
+func TestTruncateRunesKeepsClustersWhole(t *testing.T) {
+	for _, tc := range []struct {
+		text  string
+		limit int
+		want  string
+	}{
+		{"日本語テキスト", 3, "日本語"},
+		{"ok" + thumbsUp + "!", 3, "ok"},
+		{"hi" + family + "!", 4, "hi"},
+		{"caf" + decomposed + "s", 4, "caf"},
+		{"русский 中文 عربي", 9, "русский 中"},
+	} {
+		got, truncated := TruncateRunes(tc.text, tc.limit)
+		if got != tc.want || !truncated {
+			t.Fatalf("TruncateRunes(%q, %d) = %q, %v; want %q", tc.text, tc.limit, got, truncated, tc.want)
+		}
+	}
+}
+
+func TestCountTokensOnMixedScripts(t *testing.T) {
+	text := "Hello 世界 " + family + " مرحبا " + flagsCNJP + " naïve " + decomposed
+	if n := CountPromptTokens(text, "deepseek-v4-flash"); n <= EstimateTokens("Hello") {
+		t.Fatalf("expected a mixed-script prompt to count more tokens, got %d", n)
+	}
+	prompt := MessagesPrepare([]map[string]any{
+		{"role": "system", "content": "答えは日本語で。"},
+		{"role": "user", "content": text},
+	})
+	if !utf8.ValidString(prompt) || !strings.Contains(prompt, text) || !strings.Contains(prompt, "答えは日本語で。") {
+		t.Fatalf("expected the prompt to carry mixed-script text verbatim, got %q", prompt)
+	}
+}
@@ -1,8 +1,8 @@
 package util
 
-import "unicode/utf8"
-
-// TruncateRunes trims a string to at most limit Unicode code points.
+// TruncateRunes trims a string to at most limit Unicode code points. The cut
+// backs off to a ClusterStart, so an emoji sequence or a letter with combining
+// marks is dropped whole rather than split.
 func TruncateRunes(text string, limit int) (string, bool) {
 	if limit < 0 {
 		return text, false
@@ -14,7 +14,7 @@ func TruncateRunes(text string, limit int) (string, bool) {
 	count := 0
 	for i := range text {
 		if count == limit {
-			return text[:i], true
+			return text[:ClusterFloor(text, i)], true
 		}
 		count++
 	}
@@ -22,7 +22,7 @@ func TruncateRunes(text string, limit int) (string, bool) {
 }
 
 // TruncateUTF8Bytes trims a string to fit within limit bytes without cutting
-// through a UTF-8 code point boundary.
+// through a UTF-8 code point or a user-perceived character (see ClusterStart).
 func TruncateUTF8Bytes(text string, limit int) (string, bool) {
 	if limit < 0 {
 		return text, false
@@ -34,13 +34,5 @@ func TruncateUTF8Bytes(text string, limit int) (string, bool) {
 		return "", true
 	}
 
-	raw := []byte(text)
-	cut := limit
-	if cut > len(raw) {
-		cut = len(raw)
-	}
-	for cut > 0 && cut < len(raw) && !utf8.RuneStart(raw[cut]) {
-		cut--
-	}
-	return string(raw[:cut]), true
+	return text[:ClusterFloor(text, limit)], true
 }
@@ -2,6 +2,26 @@
 
 package util
 
+import (
+	"sync"
+
+	"ds2api/internal/config"
+)
+
+var tokenizerFallbackOnce sync.Once
+
+// countWithTokenizer has no tokenizer on these targets; usage is estimated
+// and the fallback is logged once so approximate counts are not mistaken for
+// exact ones.
 func countWithTokenizer(_, _ string) int {
+	tokenizerFallbackOnce.Do(func() {
+		config.Logger.Warn("[token_count] tokenizer not built for this platform, usage uses approximate counts")
+	})
 	return 0
 }
+
+// DecodeTokenID has no tokenizer on these targets, so token IDs cannot be
+// turned back into text.
+func DecodeTokenID(_ int, _ string) (string, bool) {
+	return "", false
+}
@@ -7,6 +7,8 @@ import (
 	"sync"
 
 	tiktoken "github.com/hupe1980/go-tiktoken"
+
+	"ds2api/internal/config"
 )
 
 var (
@@ -46,7 +48,9 @@ func tokenizerEncodingForCount(model string) (*tiktoken.Encoding, func()) {
 
 	encoding, err := tiktoken.NewEncodingForModel(model)
 	if err != nil {
-		tokenEncodingUnsupported.Store(model, struct{}{})
+		if _, loaded := tokenEncodingUnsupported.LoadOrStore(model, struct{}{}); !loaded {
+			config.Logger.Warn("[token_count] tokenizer unavailable, usage falls back to approximate counts", "model", model, "error", err)
+		}
 		return nil, func() {}
 	}
 	pool := &sync.Pool{
@@ -96,3 +100,22 @@ func tokenizerModelForCount(model string) string {
 		return defaultTokenizerModel
 	}
 }
+
+// DecodeTokenID returns the text of one token ID in the tokenizer used for
+// model, e.g. to interpret OpenAI `logit_bias` keys. ok is false when there is
+// no tokenizer or the ID is unknown.
+func DecodeTokenID(id int, model string) (string, bool) {
+	if id < 0 {
+		return "", false
+	}
+	encoding, release := tokenizerEncodingForCount(tokenizerModelForCount(model))
+	if encoding == nil {
+		return "", false
+	}
+	defer release()
+	text := encoding.Decode([]uint{uint(id)})
+	if len(text) == 0 {
+		return "", false
+	}
+	return string(text), true
+}
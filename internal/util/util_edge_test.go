@@ -184,6 +184,7 @@ func TestMessagesPrepareAssistantMarkers(t *testing.T) {
 	messages := []map[string]any{
 		{"role": "user", "content": "Hi"},
 		{"role": "assistant", "content": "Hello!"},
+		{"role": "user", "content": "Thanks"},
 	}
 	got := MessagesPrepare(messages)
 	if !strings.Contains(got, "<|Assistant|>") {
@@ -12,8 +12,11 @@ const {
   flushToolSieve,
 } = require('../../internal/js/helpers/stream-tool-sieve.js');
 const {
-  setCorsHeaders,
+  copyCorsHeaders,
+  buildInternalGoHeaders,
+  adoptTraceID,
 } = require('../../internal/js/chat-stream/http_internal.js');
+const { writeOpenAIError } = require('../../internal/js/chat-stream/error_shape.js');
 
 const {
   parseChunkForContent,
@@ -27,6 +30,8 @@ const {
   estimateTokens,
   shouldSkipPath,
   isNodeStreamSupportedPath,
+  usesLegacyFunctions,
+  usesJSONResponseFormat,
   extractPathname,
   trimContinuationOverlap,
 } = handler.__test;
@@ -102,7 +107,7 @@ function sseResponse(lines) {
   return new Response(new ReadableStream({
     start(controller) {
       for (const line of lines) {
-        controller.enqueue(encoder.encode(line));
+        controller.enqueue(typeof line === 'string' ? encoder.encode(line) : line);
       }
       controller.close();
     },
@@ -120,11 +125,11 @@ function parseSSEDataFrames(body) {
     .map((frame) => frame.slice(5).trim());
 }
 
-async function runMockVercelStream(upstreamLines, prepareOverrides = {}) {
-  return runMockVercelStreamSequence([upstreamLines], prepareOverrides);
+async function runMockVercelStream(upstreamLines, prepareOverrides = {}, payloadOverrides = {}) {
+  return runMockVercelStreamSequence([upstreamLines], prepareOverrides, payloadOverrides);
 }
 
-async function runMockVercelStreamSequence(upstreamSequences, prepareOverrides = {}) {
+async function runMockVercelStreamSequence(upstreamSequences, prepareOverrides = {}, payloadOverrides = {}) {
   const originalFetch = global.fetch;
   const fetchURLs = [];
   const fetchBodies = [];
@@ -170,7 +175,7 @@ async function runMockVercelStreamSequence(upstreamSequences, prepareOverrides =
   try {
     const req = new MockStreamRequest();
     const res = new MockStreamResponse();
-    const payload = { model: 'gpt-test', stream: true };
+    const payload = { model: 'gpt-test', stream: true, ...payloadOverrides };
     await handleVercelStream(req, res, Buffer.from(JSON.stringify(payload)), payload);
     return { res, frames: parseSSEDataFrames(res.bodyText()), fetchURLs, fetchBodies };
   } finally {
@@ -312,6 +317,35 @@ test('vercel stream coalesces many small content deltas while keeping one choice
   }
 });
 
+test('vercel stream decodes multi-byte characters split across network chunks', async () => {
+  const chunks = ['😀', '混合 scripts: русский, العربية', '\u{1F468}\u200d\u{1F469}\u200d\u{1F467} e\u0301 \u{1F1E8}\u{1F1F3}', '{"城市": "東京"}'];
+  const raw = chunks.map((v) => `data: ${JSON.stringify({ p: 'response/content', v })}\n\n`).join('') + 'data: [DONE]\n\n';
+  const bytes = new TextEncoder().encode(raw);
+  const oneByteChunks = Array.from(bytes, (b) => Uint8Array.of(b));
+  const { frames } = await runMockVercelStream(oneByteChunks);
+  const parsed = frames.filter((frame) => frame !== '[DONE]').map((frame) => JSON.parse(frame));
+  const content = parsed.map((item) => item.choices?.[0]?.delta?.content || '').join('');
+  assert.equal(content, chunks.join(''));
+});
+
+test('output token limiter cuts between emoji sequences, not inside them', () => {
+  const { createOutputTokenLimiter } = require('../../internal/js/chat-stream/output_limit.js');
+  const { splitClusters } = require('../../internal/js/chat-stream/grapheme.js');
+  const family = '\u{1F468}\u200d\u{1F469}\u200d\u{1F467}';
+  const text = `ok ${family} \u{1F44D}\u{1F3FD} e\u0301 `.repeat(8);
+  const clusters = splitClusters(text);
+  assert.deepEqual(
+    splitClusters(`a${family}\u{1F1E8}\u{1F1F3}\u{1F1EF}\u{1F1F5}e\u0301\r\n`),
+    ['a', family, '\u{1F1E8}\u{1F1F3}', '\u{1F1EF}\u{1F1F5}', 'e\u0301', '\r\n'],
+  );
+  for (let budget = 1; budget <= 12; budget += 1) {
+    const out = createOutputTokenLimiter(budget).push(text);
+    assert.ok(text.startsWith(out), `budget ${budget}`);
+    const kept = splitClusters(out);
+    assert.deepEqual(kept, clusters.slice(0, kept.length), `budget ${budget} cut inside a character: ${JSON.stringify(out)}`);
+  }
+});
+
 test('vercel stream flushes reasoning before content and before stop', async () => {
   const { frames } = await runMockVercelStream([
     `data: ${JSON.stringify({ p: 'response/fragments', o: 'APPEND', v: [
@@ -382,6 +416,21 @@ test('vercel stream usage completion_tokens does not double-count visible output
   assert.equal(terminal.usage.completion_tokens, 5);
 });
 
+test('vercel stream include_usage reports usage only on the trailing chunk', async () => {
+  const { frames } = await runMockVercelStream([
+    'data: {"p":"response/content","v":"hello"}\n\n',
+    'data: [DONE]\n\n',
+  ], {}, { stream_options: { include_usage: true } });
+  const parsed = frames.filter((frame) => frame !== '[DONE]').map((frame) => JSON.parse(frame));
+  const last = parsed[parsed.length - 1];
+  assert.deepEqual(last.choices, []);
+  assert.ok(last.usage && last.usage.total_tokens > 0);
+  for (const frame of parsed.slice(0, -1)) {
+    assert.ok(Object.hasOwn(frame, 'usage'));
+    assert.equal(frame.usage, null);
+  }
+});
+
 test('vercel stream reuses prior PoW when refresh fails', async () => {
   const originalFetch = global.fetch;
   const fetchURLs = [];
@@ -446,17 +495,31 @@ test('vercel stream emits content_filter failure when upstream filters empty out
   assert.equal(frames[1], '[DONE]');
 });
 
-test('vercel stream keeps stop finish when content_filter arrives after visible text', async () => {
+test('vercel stream reports content_filter finish when the filter arrives after visible text', async () => {
   const { frames } = await runMockVercelStream([
     'data: {"p":"response/content","v":"hello"}\n\n',
     'data: {"code":"content_filter"}\n\n',
   ]);
   const parsed = frames.filter((frame) => frame !== '[DONE]').map((frame) => JSON.parse(frame));
   assert.equal(parsed[0].choices[0].delta.content, 'hello');
-  assert.equal(parsed[1].choices[0].finish_reason, 'stop');
+  assert.equal(parsed[1].choices[0].finish_reason, 'content_filter');
   assert.equal(parsed[1].usage.completion_tokens, 1);
 });
 
+test('vercel stream truncates at stop sequence split across chunks', async () => {
+  const { frames } = await runMockVercelStream([
+    'data: {"p":"response/content","v":"hello </e"}\n\n',
+    'data: {"p":"response/content","v":"nd> ignored"}\n\n',
+    'data: {"p":"response/content","v":" more"}\n\n',
+    'data: [DONE]\n\n',
+  ], {}, { stop: ['</end>'] });
+  const parsed = frames.filter((frame) => frame !== '[DONE]').map((frame) => JSON.parse(frame));
+  const content = parsed.map((frame) => (frame.choices[0] && frame.choices[0].delta.content) || '').join('');
+  assert.equal(content, 'hello ');
+  assert.equal(parsed[parsed.length - 1].choices[0].finish_reason, 'stop');
+  assert.ok(!frames.some((frame) => frame.includes('</e') || frame.includes('ignored')));
+});
+
 test('resolveToolcallPolicy defaults to feature-match + early emit when prepare flags missing', () => {
   const policy = resolveToolcallPolicy(
     {},
@@ -481,6 +544,13 @@ test('resolveToolcallPolicy ignores prepare flags and keeps early emit enabled',
   assert.equal(policy.emitEarlyToolDeltas, true);
 });
 
+test('resolveToolcallPolicy keeps non-DSML tool call formats off the Node sieve', () => {
+  const tools = [{ type: 'function', function: { name: 'read_file', parameters: { type: 'object' } } }];
+  assert.equal(resolveToolcallPolicy({}, tools).nodeSieveSupported, true);
+  assert.equal(resolveToolcallPolicy({ tool_call_format: 'dsml' }, tools).nodeSieveSupported, true);
+  assert.equal(resolveToolcallPolicy({ tool_call_format: 'json' }, tools).nodeSieveSupported, false);
+});
+
 test('normalizePreparedToolNames filters empty values', () => {
   assert.deepEqual(normalizePreparedToolNames([' a ', '', null, 'b']), ['a', 'b']);
 });
@@ -877,36 +947,108 @@ test('node stream path guard allows OpenAI v1 and root alias chat completions pa
   assert.equal(isNodeStreamSupportedPath('/anthropic/v1/messages'), false);
 });
 
+test('legacy functions requests are routed to the Go stream path', () => {
+  assert.equal(usesLegacyFunctions({ functions: [{ name: 'get_weather' }] }), true);
+  assert.equal(usesLegacyFunctions({ function_call: 'auto' }), true);
+  assert.equal(usesLegacyFunctions({ functions: [{ name: 'a' }], tools: [{ type: 'function' }] }), false);
+  assert.equal(usesLegacyFunctions({ messages: [] }), false);
+});
+
+test('JSON-mode response_format requests are routed to the Go stream path', () => {
+  assert.equal(usesJSONResponseFormat({ response_format: { type: 'json_object' } }), true);
+  assert.equal(usesJSONResponseFormat({ response_format: { type: 'json_schema', json_schema: { schema: {} } } }), true);
+  assert.equal(usesJSONResponseFormat({ response_format: { type: 'text' } }), false);
+  assert.equal(usesJSONResponseFormat({ messages: [] }), false);
+});
+
 test('extractPathname strips query only', () => {
   assert.equal(extractPathname('/v1/chat/completions?stream=true'), '/v1/chat/completions');
   assert.equal(extractPathname('/v1beta/models/gemini-2.5-flash:streamGenerateContent?key=1'), '/v1beta/models/gemini-2.5-flash:streamGenerateContent');
   assert.equal(extractPathname('/chat/completions?stream=true'), '/chat/completions');
 });
 
-test('setCorsHeaders reflects requested third-party headers and blocks internal-only headers', () => {
-  const res = createMockResponse();
-  setCorsHeaders(res, {
+test('buildInternalGoHeaders forwards CORS request headers so Go decides the policy', () => {
+  const headers = buildInternalGoHeaders({
     headers: {
-      origin: 'app://obsidian.md',
-      'access-control-request-headers': 'authorization, x-stainless-os, x-stainless-runtime, x-ds2-internal-token',
-      'access-control-request-private-network': 'true',
+      host: 'example.test',
+      origin: 'https://app.example.com',
+      'access-control-request-headers': 'authorization',
+      'access-control-request-method': 'POST',
     },
   });
+  assert.equal(headers.origin, 'https://app.example.com');
+  assert.equal(headers['access-control-request-headers'], 'authorization');
+  assert.equal(headers['access-control-request-method'], 'POST');
+  assert.equal('access-control-request-private-network' in headers, false);
+});
 
-  assert.equal(res.getHeader('access-control-allow-origin'), 'app://obsidian.md');
-  assert.equal(res.getHeader('access-control-allow-private-network'), 'true');
-  assert.equal(res.getHeader('access-control-max-age'), '600');
+test('trace ID from Go prepare is echoed and forwarded on later internal calls', () => {
+  const req = { headers: { host: 'example.test', traceparent: '00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01' } };
+  assert.equal(buildInternalGoHeaders(req).traceparent, '00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01');
+  const res = createMockResponse();
+  adoptTraceID(req, res, new Headers({ 'X-Request-Id': '4bf92f3577b34da6a3ce929d0e0e4736' }));
+  assert.equal(res.getHeader('x-request-id'), '4bf92f3577b34da6a3ce929d0e0e4736');
+  assert.equal(buildInternalGoHeaders(req)['x-request-id'], '4bf92f3577b34da6a3ce929d0e0e4736');
+
+  let body = '';
+  res.end = (text) => {
+    body = text;
+  };
+  writeOpenAIError(res, 500, 'boom');
+  assert.equal(JSON.parse(body).request_id, '4bf92f3577b34da6a3ce929d0e0e4736');
+});
 
-  const allowHeaders = String(res.getHeader('access-control-allow-headers') || '').toLowerCase();
-  assert.equal(allowHeaders.includes('authorization'), true);
-  assert.equal(allowHeaders.includes('x-stainless-os'), true);
-  assert.equal(allowHeaders.includes('x-stainless-runtime'), true);
-  assert.equal(allowHeaders.includes('x-ds2-internal-token'), false);
+test('copyCorsHeaders copies only Access-Control headers and merges Vary', () => {
+  const res = createMockResponse();
+  res.setHeader('Vary', 'Accept-Encoding');
+  copyCorsHeaders(res, new Headers({
+    'Access-Control-Allow-Origin': 'https://app.example.com',
+    'Access-Control-Expose-Headers': 'X-Request-Id',
+    Vary: 'Origin, Access-Control-Request-Headers',
+    'Content-Type': 'application/json',
+  }));
+  assert.equal(res.getHeader('access-control-allow-origin'), 'https://app.example.com');
+  assert.equal(res.getHeader('access-control-expose-headers'), 'X-Request-Id');
+  assert.equal(res.getHeader('vary'), 'Accept-Encoding, Origin, Access-Control-Request-Headers');
+  assert.equal(res.getHeader('content-type'), undefined);
+});
 
-  const vary = String(res.getHeader('vary') || '').toLowerCase();
-  assert.equal(vary.includes('origin'), true);
-  assert.equal(vary.includes('access-control-request-headers'), true);
-  assert.equal(vary.includes('access-control-request-private-network'), true);
+test('vercel stream carries the CORS headers from the Go prepare response', async () => {
+  const originalFetch = global.fetch;
+  global.fetch = async (url) => {
+    const textURL = String(url);
+    if (textURL.includes('__stream_prepare=1')) {
+      return new Response(JSON.stringify({
+        session_id: 'chatcmpl-test',
+        lease_id: 'lease-test',
+        model: 'gpt-test',
+        final_prompt: 'hello',
+        tool_names: [],
+        deepseek_token: 'deepseek-token',
+        pow_header: 'pow-header',
+        payload: { prompt: 'hello' },
+      }), {
+        status: 200,
+        headers: { 'content-type': 'application/json', 'access-control-allow-origin': 'https://app.example.com', vary: 'Origin' },
+      });
+    }
+    if (textURL.includes('__stream_release=1')) {
+      return jsonResponse({ success: true });
+    }
+    return sseResponse(['data: {"p":"response/content","v":"hi"}\n\n', 'data: [DONE]\n\n']);
+  };
+  try {
+    const req = new MockStreamRequest();
+    req.headers.origin = 'https://app.example.com';
+    const res = new MockStreamResponse();
+    const payload = { model: 'gpt-test', stream: true };
+    await handleVercelStream(req, res, Buffer.from(JSON.stringify(payload)), payload);
+    assert.equal(res.getHeader('access-control-allow-origin'), 'https://app.example.com');
+    assert.equal(String(res.getHeader('vary')).includes('Origin'), true);
+    assert.equal(res.bodyText().includes('"hi"'), true);
+  } finally {
+    global.fetch = originalFetch;
+  }
 });
 
 test('trimContinuationOverlap preserves short normal tokens and trims long snapshots', () => {
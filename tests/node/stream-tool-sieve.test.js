@@ -483,6 +483,45 @@ test('parseToolCalls treats single-item CDATA body as array', () => {
   assert.deepEqual(calls[0].input.todos, ['one']);
 });
 
+test('parseToolCalls repairs malformed JSON arguments (Go parity)', () => {
+  const want = { name: 'demo', tags: ['a', 'b'], enabled: true };
+  for (const [label, body] of [
+    ['trailing commas', '{"name": "demo", "tags": ["a", "b",], "enabled": true,}'],
+    ['unquoted keys', '{name: "demo", tags: ["a", "b"], enabled: true}'],
+    ['single quotes', "{'name': 'demo', 'tags': ['a', 'b'], 'enabled': true}"],
+    ['python literals', '{"name": "demo", "tags": ["a", "b"], "enabled": True}'],
+    ['all at once in cdata', "<![CDATA[{name: 'demo', tags: ['a', 'b',], enabled: True,}]]>"],
+  ]) {
+    const payload = `<|DSML|tool_calls><|DSML|invoke name="configure"><|DSML|parameter name="options">${body}</|DSML|parameter></|DSML|invoke></|DSML|tool_calls>`;
+    const calls = parseToolCalls(payload, ['configure']);
+    assert.equal(calls.length, 1, label);
+    assert.deepEqual(calls[0].input.options, want, label);
+  }
+});
+
+test('parseToolCalls repairs JSON arguments with multi-byte text (Go parity)', () => {
+  const family = '\u{1F468}\u200d\u{1F469}\u200d\u{1F467}';
+  const want = { 城市: '東京 🗼', clé: 'naïve e\u0301', emoji: family, note_1: '混合 scripts' };
+  for (const [label, body] of [
+    ['unquoted keys in any script', `{城市: "東京 🗼", clé: "naïve e\u0301", emoji: "${family}", note_1: "混合 scripts"}`],
+    ['single quotes and trailing comma', `{'城市': '東京 🗼', 'clé': 'naïve e\u0301', 'emoji': '${family}', 'note_1': '混合 scripts',}`],
+  ]) {
+    const payload = `<|DSML|tool_calls><|DSML|invoke name="configure"><|DSML|parameter name="options">${body}</|DSML|parameter></|DSML|invoke></|DSML|tool_calls>`;
+    const calls = parseToolCalls(payload, ['configure']);
+    assert.equal(calls.length, 1, label);
+    assert.deepEqual(calls[0].input.options, want, label);
+  }
+});
+
+test('parseToolCalls repair keeps nested JSON strings and free-text parameters verbatim', () => {
+  const nested = String.raw`{\"a\": [1, 2,], 'b': {c: True}}`;
+  const payload = `<tool_calls><invoke name="configure"><parameter name="options">{payload: "${nested}",}</parameter><parameter name="code"><![CDATA[{ greeting: 'hi', }]]></parameter></invoke></tool_calls>`;
+  const calls = parseToolCalls(payload, ['configure']);
+  assert.equal(calls.length, 1);
+  assert.equal(calls[0].input.options.payload, `{"a": [1, 2,], 'b': {c: True}}`);
+  assert.equal(calls[0].input.code, "{ greeting: 'hi', }");
+});
+
 test('parseToolCalls treats loose JSON list as array', () => {
   for (const [label, body] of [
     ['plain text', '{"content":"Test TodoWrite tool","status":"completed"}, {"content":"Another task","status":"pending"}'],
@@ -1215,13 +1254,13 @@ test('sieve keeps embedded invalid tool-like json as normal text to avoid stream
   assert.equal(leakedText.toLowerCase().includes('tool_calls'), true);
 });
 
-test('sieve releases malformed executable-looking XML wrappers as text', () => {
+test('sieve drops malformed executable-looking XML wrappers instead of leaking them', () => {
   const chunk = '<tool_calls><invoke name="read_file"><param>{"path":"README.MD"}</param></invoke></tool_calls>';
   const events = runSieve([chunk], ['read_file']);
   const leakedText = collectText(events);
   const hasToolCalls = events.some((evt) => evt.type === 'tool_calls' && evt.calls?.length > 0);
   assert.equal(hasToolCalls, false);
-  assert.equal(leakedText, chunk);
+  assert.equal(leakedText, '');
 });
 
 test('sieve keeps bare tool_call XML as plain text without wrapper', () => {
@@ -1233,7 +1272,7 @@ test('sieve keeps bare tool_call XML as plain text without wrapper', () => {
   assert.equal(leakedText, chunk);
 });
 
-test('sieve flushes incomplete captured XML tool blocks by falling back to raw text', () => {
+test('sieve flushes incomplete captured XML tool blocks by dropping the cut-off call', () => {
   const events = runSieve(
     [
       '前置正文G。',
@@ -1243,7 +1282,7 @@ test('sieve flushes incomplete captured XML tool blocks by falling back to raw t
     ['read_file'],
   );
   const leakedText = collectText(events);
-  const expected = ['前置正文G。', '<tool_calls>\n', '  <invoke name="read_file">\n'].join('');
+  const expected = '前置正文G。';
   const hasToolCalls = events.some((evt) => evt.type === 'tool_calls' && evt.calls?.length > 0);
   assert.equal(hasToolCalls, false);
   assert.equal(leakedText, expected);
@@ -1351,3 +1390,19 @@ test('parseToolCalls rejects mismatched markup tags', () => {
   const calls = parseToolCalls(payload, ['read_file']);
   assert.equal(calls.length, 0);
 });
+
+test('sieve strips leftover markup split across chunks without emitting half tags', () => {
+  const chunks = ['Done.</|DS', 'ML|tool_', 'calls> Next: <|DSML|tool_calls>\n<|DSML|inv', 'oke name="Bash">\n<|DSML|parameter name="command"><![CDATA[l'];
+  const state = createToolSieveState();
+  const events = [];
+  for (const chunk of chunks) {
+    const got = processToolSieveChunk(state, chunk, ['Bash']);
+    for (const evt of got) {
+      assert.equal(evt.type === 'text' && evt.text.includes('<'), false);
+    }
+    events.push(...got);
+  }
+  events.push(...flushToolSieve(state, ['Bash']));
+  assert.equal(events.some((evt) => evt.type === 'tool_calls'), false);
+  assert.equal(collectText(events), 'Done. Next: ');
+});
@@ -68,6 +68,17 @@ export default function AddKeyModal({ show, t, editingKey, newKey, setNewKey, lo
                             onChange={e => setNewKey({ ...newKey, remark: e.target.value })}
                         />
                     </div>
+                    <div>
+                        <label className="block text-sm font-medium mb-1.5">{t('accountManager.modelsOptional')}</label>
+                        <input
+                            type="text"
+                            className="input-field"
+                            placeholder={t('accountManager.modelsPlaceholder')}
+                            value={newKey.models || ''}
+                            onChange={e => setNewKey({ ...newKey, models: e.target.value })}
+                        />
+                        <p className="text-xs text-muted-foreground mt-1.5">{t('accountManager.modelsHint')}</p>
+                    </div>
                     <div className="flex justify-end gap-2 pt-2">
                         <button onClick={onClose} className="px-4 py-2 rounded-lg border border-border hover:bg-secondary transition-colors text-sm font-medium">{t('actions.cancel')}</button>
                         <button onClick={onAdd} disabled={loading} className="px-4 py-2 bg-primary text-primary-foreground rounded-lg hover:bg-primary/90 transition-colors text-sm font-medium disabled:opacity-50">
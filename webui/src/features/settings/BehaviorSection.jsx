@@ -28,6 +28,70 @@ export default function BehaviorSection({ t, form, setForm }) {
                         className="w-full bg-background border border-border rounded-lg px-3 py-2"
                     />
                 </label>
+                <label className="text-sm space-y-2">
+                    <span className="text-muted-foreground">{t('settings.embeddingsBaseURL')}</span>
+                    <input
+                        type="text"
+                        placeholder="https://api.openai.com/v1"
+                        value={form.embeddings.base_url}
+                        onChange={(e) => setForm((prev) => ({
+                            ...prev,
+                            embeddings: { ...prev.embeddings, base_url: e.target.value },
+                        }))}
+                        className="w-full bg-background border border-border rounded-lg px-3 py-2"
+                    />
+                </label>
+                <label className="text-sm space-y-2">
+                    <span className="text-muted-foreground">{t('settings.embeddingsModel')}</span>
+                    <input
+                        type="text"
+                        value={form.embeddings.model}
+                        onChange={(e) => setForm((prev) => ({
+                            ...prev,
+                            embeddings: { ...prev.embeddings, model: e.target.value },
+                        }))}
+                        className="w-full bg-background border border-border rounded-lg px-3 py-2"
+                    />
+                </label>
+                <label className="text-sm space-y-2">
+                    <span className="text-muted-foreground">{t('settings.embeddingsAPIKey')}</span>
+                    <input
+                        type="password"
+                        value={form.embeddings.api_key}
+                        placeholder={form.embeddings.has_api_key ? t('settings.embeddingsAPIKeySet') : ''}
+                        onChange={(e) => setForm((prev) => ({
+                            ...prev,
+                            embeddings: { ...prev.embeddings, api_key: e.target.value },
+                        }))}
+                        className="w-full bg-background border border-border rounded-lg px-3 py-2"
+                    />
+                </label>
+                <label className="text-sm space-y-2">
+                    <span className="text-muted-foreground">{t('settings.embeddingsMaxInputs')}</span>
+                    <input
+                        type="number"
+                        min={1}
+                        value={form.embeddings.max_inputs}
+                        onChange={(e) => setForm((prev) => ({
+                            ...prev,
+                            embeddings: { ...prev.embeddings, max_inputs: Number(e.target.value || 2048) },
+                        }))}
+                        className="w-full bg-background border border-border rounded-lg px-3 py-2"
+                    />
+                </label>
+                <label className="text-sm space-y-2">
+                    <span className="text-muted-foreground">{t('settings.embeddingsMaxTokens')}</span>
+                    <input
+                        type="number"
+                        min={1}
+                        value={form.embeddings.max_tokens}
+                        onChange={(e) => setForm((prev) => ({
+                            ...prev,
+                            embeddings: { ...prev.embeddings, max_tokens: Number(e.target.value || 300000) },
+                        }))}
+                        className="w-full bg-background border border-border rounded-lg px-3 py-2"
+                    />
+                </label>
                 <label className="flex items-start gap-3 rounded-lg border border-border bg-background/60 p-4">
                     <input
                         type="checkbox"
@@ -1,4 +1,9 @@
 export default function ModelSection({ t, form, setForm }) {
+    const routing = form.model_routing || {}
+    const setRouting = (key, value) => setForm((prev) => ({
+        ...prev,
+        model_routing: { ...(prev.model_routing || {}), [key]: value },
+    }))
     return (
         <div className="bg-card border border-border rounded-xl p-5 space-y-4">
             <h3 className="font-semibold">{t('settings.modelTitle')}</h3>
@@ -11,6 +16,30 @@ export default function ModelSection({ t, form, setForm }) {
                     className="w-full bg-background border border-border rounded-lg px-3 py-2 font-mono text-xs"
                 />
             </label>
+            <div className="grid grid-cols-1 md:grid-cols-2 gap-4">
+                <label className="text-sm space-y-2 block">
+                    <span className="text-muted-foreground">{t('settings.modelDefault')}</span>
+                    <input
+                        type="text"
+                        value={routing.default_model || ''}
+                        placeholder="deepseek-v4-flash"
+                        onChange={(e) => setRouting('default_model', e.target.value)}
+                        className="w-full bg-background border border-border rounded-lg px-3 py-2 text-sm"
+                    />
+                    <p className="text-xs text-muted-foreground">{t('settings.modelDefaultHelp')}</p>
+                </label>
+                <label className="text-sm space-y-2 block">
+                    <span className="text-muted-foreground">{t('settings.modelResponseName')}</span>
+                    <select
+                        value={routing.response_model || 'requested'}
+                        onChange={(e) => setRouting('response_model', e.target.value)}
+                        className="w-full rounded-lg border border-border bg-background px-3 py-2 text-sm leading-5 focus:outline-none focus:ring-1 focus:ring-ring"
+                    >
+                        <option value="requested">{t('settings.modelResponseRequested')}</option>
+                        <option value="resolved">{t('settings.modelResponseResolved')}</option>
+                    </select>
+                </label>
+            </div>
         </div>
     )
 }
@@ -57,6 +57,215 @@ export default function RuntimeSection({ t, form, setForm }) {
                         className="w-full bg-background border border-border rounded-lg px-3 py-2"
                     />
                 </label>
+                <label className="text-sm space-y-2">
+                    <span className="text-muted-foreground">{t('settings.upstreamRetryMaxAttempts')}</span>
+                    <input
+                        type="number"
+                        min={1}
+                        max={10}
+                        step={1}
+                        value={form.runtime.upstream_retry_max_attempts}
+                        onChange={(e) => setForm((prev) => ({
+                            ...prev,
+                            runtime: { ...prev.runtime, upstream_retry_max_attempts: Number(e.target.value || 1) },
+                        }))}
+                        className="w-full bg-background border border-border rounded-lg px-3 py-2"
+                    />
+                </label>
+                <label className="text-sm space-y-2">
+                    <span className="text-muted-foreground">{t('settings.requestTimeoutSeconds')}</span>
+                    <input
+                        type="number"
+                        min={30}
+                        max={86400}
+                        step={1}
+                        value={form.runtime.request_timeout_seconds}
+                        onChange={(e) => setForm((prev) => ({
+                            ...prev,
+                            runtime: { ...prev.runtime, request_timeout_seconds: Number(e.target.value || 30) },
+                        }))}
+                        className="w-full bg-background border border-border rounded-lg px-3 py-2"
+                    />
+                </label>
+                <label className="text-sm space-y-2">
+                    <span className="text-muted-foreground">{t('settings.maxCompletionChoices')}</span>
+                    <input
+                        type="number"
+                        min={1}
+                        max={16}
+                        step={1}
+                        value={form.runtime.max_completion_choices}
+                        onChange={(e) => setForm((prev) => ({
+                            ...prev,
+                            runtime: { ...prev.runtime, max_completion_choices: Number(e.target.value || 1) },
+                        }))}
+                        className="w-full bg-background border border-border rounded-lg px-3 py-2"
+                    />
+                </label>
+                <label className="text-sm space-y-2">
+                    <span className="text-muted-foreground">{t('settings.readinessCacheSeconds')}</span>
+                    <input
+                        type="number"
+                        min={1}
+                        max={3600}
+                        step={1}
+                        value={form.runtime.readiness_cache_seconds}
+                        onChange={(e) => setForm((prev) => ({
+                            ...prev,
+                            runtime: { ...prev.runtime, readiness_cache_seconds: Number(e.target.value || 1) },
+                        }))}
+                        className="w-full bg-background border border-border rounded-lg px-3 py-2"
+                    />
+                </label>
+                <label className="text-sm space-y-2">
+                    <span className="text-muted-foreground">{t('settings.maxRequestBodyMB')}</span>
+                    <input
+                        type="number"
+                        min={1}
+                        max={100}
+                        step={1}
+                        value={form.runtime.max_request_body_mb}
+                        onChange={(e) => setForm((prev) => ({
+                            ...prev,
+                            runtime: { ...prev.runtime, max_request_body_mb: Number(e.target.value || 1) },
+                        }))}
+                        className="w-full bg-background border border-border rounded-lg px-3 py-2"
+                    />
+                </label>
+                <label className="text-sm space-y-2">
+                    <span className="text-muted-foreground">{t('settings.shutdownGraceSeconds')}</span>
+                    <input
+                        type="number"
+                        min={1}
+                        max={3600}
+                        step={1}
+                        value={form.runtime.shutdown_grace_seconds}
+                        onChange={(e) => setForm((prev) => ({
+                            ...prev,
+                            runtime: { ...prev.runtime, shutdown_grace_seconds: Number(e.target.value || 1) },
+                        }))}
+                        className="w-full bg-background border border-border rounded-lg px-3 py-2"
+                    />
+                </label>
+                <label className="text-sm space-y-2">
+                    <span className="text-muted-foreground">{t('settings.upstreamMaxInflight')}</span>
+                    <input
+                        type="number"
+                        min={0}
+                        max={200000}
+                        step={1}
+                        value={form.runtime.upstream_max_inflight}
+                        onChange={(e) => setForm((prev) => ({
+                            ...prev,
+                            runtime: { ...prev.runtime, upstream_max_inflight: Number(e.target.value || 0) },
+                        }))}
+                        className="w-full bg-background border border-border rounded-lg px-3 py-2"
+                    />
+                </label>
+                <label className="text-sm space-y-2">
+                    <span className="text-muted-foreground">{t('settings.upstreamMaxQueue')}</span>
+                    <input
+                        type="number"
+                        min={0}
+                        max={200000}
+                        step={1}
+                        value={form.runtime.upstream_max_queue}
+                        onChange={(e) => setForm((prev) => ({
+                            ...prev,
+                            runtime: { ...prev.runtime, upstream_max_queue: Number(e.target.value || 0) },
+                        }))}
+                        className="w-full bg-background border border-border rounded-lg px-3 py-2"
+                    />
+                </label>
+                <label className="text-sm space-y-2">
+                    <span className="text-muted-foreground">{t('settings.contextMaxTokens')}</span>
+                    <input
+                        type="number"
+                        min={0}
+                        max={10000000}
+                        step={1}
+                        value={form.runtime.context_max_tokens}
+                        onChange={(e) => setForm((prev) => ({
+                            ...prev,
+                            runtime: { ...prev.runtime, context_max_tokens: Number(e.target.value || 0) },
+                        }))}
+                        className="w-full bg-background border border-border rounded-lg px-3 py-2"
+                    />
+                </label>
+                <label className="text-sm space-y-2">
+                    <span className="text-muted-foreground">{t('settings.contextTrimStrategy')}</span>
+                    <select
+                        value={form.runtime.context_trim_strategy || 'drop_oldest'}
+                        onChange={(e) => setForm((prev) => ({
+                            ...prev,
+                            runtime: { ...prev.runtime, context_trim_strategy: e.target.value },
+                        }))}
+                        className="w-full bg-background border border-border rounded-lg px-3 py-2"
+                    >
+                        <option value="drop_oldest">{t('settings.contextTrimDropOldest')}</option>
+                        <option value="summarize_oldest">{t('settings.contextTrimSummarizeOldest')}</option>
+                    </select>
+                </label>
+                <label className="flex items-start gap-3 rounded-lg border border-border bg-background/60 p-4">
+                    <input
+                        type="checkbox"
+                        checked={Boolean(form.runtime.require_api_key)}
+                        onChange={(e) => setForm((prev) => ({
+                            ...prev,
+                            runtime: { ...prev.runtime, require_api_key: e.target.checked },
+                        }))}
+                        className="mt-1 h-4 w-4 rounded border-border"
+                    />
+                    <div className="space-y-1">
+                        <span className="text-sm font-medium block">{t('settings.requireAPIKey')}</span>
+                        <span className="text-xs text-muted-foreground block">{t('settings.requireAPIKeyDesc')}</span>
+                    </div>
+                </label>
+                <label className="flex items-start gap-3 rounded-lg border border-border bg-background/60 p-4">
+                    <input
+                        type="checkbox"
+                        checked={Boolean(form.runtime.strict_sampling_params)}
+                        onChange={(e) => setForm((prev) => ({
+                            ...prev,
+                            runtime: { ...prev.runtime, strict_sampling_params: e.target.checked },
+                        }))}
+                        className="mt-1 h-4 w-4 rounded border-border"
+                    />
+                    <div className="space-y-1">
+                        <span className="text-sm font-medium block">{t('settings.strictSamplingParams')}</span>
+                        <span className="text-xs text-muted-foreground block">{t('settings.strictSamplingParamsDesc')}</span>
+                    </div>
+                </label>
+                <label className="flex items-start gap-3 rounded-lg border border-border bg-background/60 p-4">
+                    <input
+                        type="checkbox"
+                        checked={Boolean(form.runtime.remote_image_fetch)}
+                        onChange={(e) => setForm((prev) => ({
+                            ...prev,
+                            runtime: { ...prev.runtime, remote_image_fetch: e.target.checked },
+                        }))}
+                        className="mt-1 h-4 w-4 rounded border-border"
+                    />
+                    <div className="space-y-1">
+                        <span className="text-sm font-medium block">{t('settings.remoteImageFetch')}</span>
+                        <span className="text-xs text-muted-foreground block">{t('settings.remoteImageFetchDesc')}</span>
+                    </div>
+                </label>
+                <label className="flex items-start gap-3 rounded-lg border border-border bg-background/60 p-4">
+                    <input
+                        type="checkbox"
+                        checked={Boolean(form.runtime.merge_consecutive_messages)}
+                        onChange={(e) => setForm((prev) => ({
+                            ...prev,
+                            runtime: { ...prev.runtime, merge_consecutive_messages: e.target.checked },
+                        }))}
+                        className="mt-1 h-4 w-4 rounded border-border"
+                    />
+                    <div className="space-y-1">
+                        <span className="text-sm font-medium block">{t('settings.mergeConsecutiveMessages')}</span>
+                        <span className="text-xs text-muted-foreground block">{t('settings.mergeConsecutiveMessagesDesc')}</span>
+                    </div>
+                </label>
             </div>
         </div>
     )
@@ -12,13 +12,14 @@ const MAX_AUTO_FETCH_FAILURES = 3
 
 const DEFAULT_FORM = {
     admin: { jwt_expire_hours: 24 },
-    runtime: { account_max_inflight: 2, account_max_queue: 10, global_max_inflight: 10, token_refresh_interval_hours: 6 },
+    runtime: { account_max_inflight: 2, account_max_queue: 10, global_max_inflight: 10, token_refresh_interval_hours: 6, upstream_retry_max_attempts: 3, request_timeout_seconds: 900, max_completion_choices: 4, readiness_cache_seconds: 10, max_request_body_mb: 8, shutdown_grace_seconds: 10, upstream_max_inflight: 0, upstream_max_queue: 0, context_max_tokens: 0, context_trim_strategy: 'drop_oldest', require_api_key: false, strict_sampling_params: false, remote_image_fetch: false, merge_consecutive_messages: true },
     responses: { store_ttl_seconds: 900 },
-    embeddings: { provider: '' },
+    embeddings: { provider: '', base_url: '', model: '', api_key: '', has_api_key: false, max_inputs: 2048, max_tokens: 300000 },
     auto_delete: { mode: 'none' },
     current_input_file: { enabled: true, min_chars: 0 },
     thinking_injection: { enabled: true, prompt: '', default_prompt: '' },
     model_aliases_text: '{}',
+    model_routing: { default_model: '', response_model: 'requested' },
 }
 
 function parseJSONMap(raw, fieldName, t) {
@@ -58,12 +59,32 @@ function fromServerForm(data) {
             account_max_queue: Number(data.runtime?.account_max_queue || 10),
             global_max_inflight: Number(data.runtime?.global_max_inflight || 10),
             token_refresh_interval_hours: Number(data.runtime?.token_refresh_interval_hours || 6),
+            upstream_retry_max_attempts: Number(data.runtime?.upstream_retry_max_attempts || 3),
+            request_timeout_seconds: Number(data.runtime?.request_timeout_seconds || 900),
+            max_completion_choices: Number(data.runtime?.max_completion_choices || 4),
+            readiness_cache_seconds: Number(data.runtime?.readiness_cache_seconds || 10),
+            max_request_body_mb: Number(data.runtime?.max_request_body_mb || 8),
+            shutdown_grace_seconds: Number(data.runtime?.shutdown_grace_seconds || 10),
+            upstream_max_inflight: Number(data.runtime?.upstream_max_inflight || 0),
+            upstream_max_queue: Number(data.runtime?.upstream_max_queue || 0),
+            context_max_tokens: Number(data.runtime?.context_max_tokens || 0),
+            context_trim_strategy: data.runtime?.context_trim_strategy || 'drop_oldest',
+            require_api_key: Boolean(data.runtime?.require_api_key),
+            strict_sampling_params: Boolean(data.runtime?.strict_sampling_params),
+            remote_image_fetch: Boolean(data.runtime?.remote_image_fetch),
+            merge_consecutive_messages: data.runtime?.merge_consecutive_messages !== false,
         },
         responses: {
             store_ttl_seconds: Number(data.responses?.store_ttl_seconds || 900),
         },
         embeddings: {
             provider: data.embeddings?.provider || '',
+            base_url: data.embeddings?.base_url || '',
+            model: data.embeddings?.model || '',
+            api_key: '',
+            has_api_key: Boolean(data.embeddings?.has_api_key),
+            max_inputs: Number(data.embeddings?.max_inputs || 2048),
+            max_tokens: Number(data.embeddings?.max_tokens || 300000),
         },
         auto_delete: {
             mode: normalizeAutoDeleteMode(data.auto_delete),
@@ -78,6 +99,10 @@ function fromServerForm(data) {
             default_prompt: data.thinking_injection?.default_prompt || '',
         },
         model_aliases_text: JSON.stringify(data.model_aliases || {}, null, 2),
+        model_routing: {
+            default_model: data.model_routing?.default_model || '',
+            response_model: data.model_routing?.response_model === 'resolved' ? 'resolved' : 'requested',
+        },
     }
 }
 
@@ -90,9 +115,30 @@ function toServerPayload(form) {
             account_max_queue: Number(form.runtime.account_max_queue),
             global_max_inflight: Number(form.runtime.global_max_inflight),
             token_refresh_interval_hours: Number(form.runtime.token_refresh_interval_hours),
+            upstream_retry_max_attempts: Number(form.runtime.upstream_retry_max_attempts),
+            request_timeout_seconds: Number(form.runtime.request_timeout_seconds),
+            max_completion_choices: Number(form.runtime.max_completion_choices),
+            readiness_cache_seconds: Number(form.runtime.readiness_cache_seconds),
+            max_request_body_mb: Number(form.runtime.max_request_body_mb),
+            shutdown_grace_seconds: Number(form.runtime.shutdown_grace_seconds),
+            upstream_max_inflight: Number(form.runtime.upstream_max_inflight),
+            upstream_max_queue: Number(form.runtime.upstream_max_queue),
+            context_max_tokens: Number(form.runtime.context_max_tokens),
+            context_trim_strategy: form.runtime.context_trim_strategy || 'drop_oldest',
+            require_api_key: Boolean(form.runtime.require_api_key),
+            strict_sampling_params: Boolean(form.runtime.strict_sampling_params),
+            remote_image_fetch: Boolean(form.runtime.remote_image_fetch),
+            merge_consecutive_messages: Boolean(form.runtime.merge_consecutive_messages),
         },
         responses: { store_ttl_seconds: Number(form.responses.store_ttl_seconds) },
-        embeddings: { provider: String(form.embeddings.provider || '').trim() },
+        embeddings: {
+            provider: String(form.embeddings.provider || '').trim(),
+            base_url: String(form.embeddings.base_url || '').trim(),
+            model: String(form.embeddings.model || '').trim(),
+            max_inputs: Number(form.embeddings.max_inputs),
+            max_tokens: Number(form.embeddings.max_tokens),
+            ...(String(form.embeddings.api_key || '').trim() ? { api_key: String(form.embeddings.api_key).trim() } : {}),
+        },
         auto_delete: { mode: normalizeAutoDeleteMode(form.auto_delete) },
         current_input_file: {
             enabled: currentInputFileEnabled,
@@ -102,6 +148,10 @@ function toServerPayload(form) {
             enabled: Boolean(form.thinking_injection?.enabled ?? true),
             prompt: String(form.thinking_injection?.prompt || '').trim(),
         },
+        model_routing: {
+            default_model: String(form.model_routing?.default_model || '').trim(),
+            response_model: form.model_routing?.response_model === 'resolved' ? 'resolved' : 'requested',
+        },
     }
 }
 